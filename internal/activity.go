@@ -27,7 +27,9 @@ type (
 		BroadcastTaskProgressUpdate(id uuid.UUID) error
 		BroadcastWorkflowUpdate(id uuid.UUID) error
 		BroadcastMediaUpdate(id uuid.UUID) error
+		BroadcastWatchTargetReady(id uuid.UUID) error
 		BroadcastIngestUpdate(id uuid.UUID) error
+		BroadcastConsistencyReport(id uuid.UUID) error
 	}
 
 	eventKey struct {
@@ -61,7 +63,8 @@ func (service *activityService) Run(ctx context.Context) error {
 		event.IngestUpdateEvent, event.IngestCompleteEvent, event.TranscodeUpdateEvent,
 		event.TranscodeTaskProgressEvent, event.TranscodeCompleteEvent, event.WorkflowUpdateEvent,
 		event.DownloadUpdateEvent, event.DownloadCompleteEvent, event.DownloadProgressEvent,
-		event.NewMediaEvent, event.DeleteMediaEvent,
+		event.NewMediaEvent, event.UpdateMediaEvent, event.DeleteMediaEvent, event.DegradedMediaEvent,
+		event.CorruptedMediaEvent, event.WatchTargetReadyEvent, event.ConsistencyCheckCompleteEvent,
 	)
 
 	log.Emit(logger.NEW, "Activity service started\n")
@@ -102,8 +105,20 @@ func (service *activityService) handleEvent(ev event.HandlerEvent) error {
 		service.scheduleEventBroadcast(resourceKey, service.BroadcastWorkflowUpdate)
 	case event.NewMediaEvent:
 		service.scheduleEventBroadcast(resourceKey, service.BroadcastMediaUpdate)
+	case event.UpdateMediaEvent:
+		service.scheduleEventBroadcast(resourceKey, service.BroadcastMediaUpdate)
 	case event.DeleteMediaEvent:
 		service.scheduleEventBroadcast(resourceKey, service.BroadcastMediaUpdate)
+	case event.DegradedMediaEvent:
+		service.scheduleEventBroadcast(resourceKey, service.BroadcastMediaUpdate)
+	case event.CorruptedMediaEvent:
+		service.scheduleEventBroadcast(resourceKey, service.BroadcastMediaUpdate)
+	case event.WatchTargetReadyEvent:
+		service.scheduleRapidEventBroadcast(resourceKey, service.BroadcastWatchTargetReady)
+	case event.ConsistencyCheckCompleteEvent:
+		service.scheduleEventBroadcast(resourceKey, service.BroadcastConsistencyReport)
+	case event.TargetUpdateEvent:
+		fallthrough
 	case event.DownloadUpdateEvent:
 		fallthrough
 	case event.DownloadCompleteEvent:
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/cache"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+)
+
+var ErrUnknownUserCommand = errors.New("unknown user command, expected one of: create, list, set-password, set-permissions")
+
+// userCommandFlags are the flags shared by the 'user' subcommands.
+type userCommandFlags struct {
+	username       string
+	password       string
+	permissions    string
+	allPermissions bool
+}
+
+// RunUserCommand runs the 'user' admin subcommand described by the arguments provided
+// (e.g. 'create -username bob -permissions media:access'). The commands operate directly
+// against the database described by the configuration, and so can be used to bootstrap
+// accounts or recover from a lockout without Thea (or it's API) running. The database itself
+// must be running; when using the embedded database, it is only started alongside Thea.
+//
+// If a password is required but not provided as a flag, it is read from stdin so that
+// it is not recorded in the shell history.
+func RunUserCommand(config TheaConfig, args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrUnknownUserCommand
+	}
+
+	command := args[0]
+	opts := &userCommandFlags{}
+	fs := flag.NewFlagSet("user "+command, flag.ContinueOnError)
+	fs.SetOutput(out)
+	switch command {
+	case "create", "set-password", "set-permissions":
+		fs.StringVar(&opts.username, "username", "", "The username of the user")
+	case "list":
+	default:
+		return ErrUnknownUserCommand
+	}
+	if command == "create" || command == "set-password" {
+		fs.StringVar(&opts.password, "password", "", "The password of the user (read from stdin if omitted)")
+	}
+	if command == "create" || command == "set-permissions" {
+		fs.StringVar(&opts.permissions, "permissions", "", fmt.Sprintf("A comma-separated list of permissions to grant, from: %s", strings.Join(permissions.All(), ", ")))
+		fs.BoolVar(&opts.allPermissions, "all-permissions", false, "Grant the user all permissions")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if command != "list" && opts.username == "" {
+		return errors.New("-username is required")
+	}
+
+	perms, err := opts.parsePermissions()
+	if err != nil {
+		return err
+	}
+
+	db := database.New()
+	if err := db.Connect(config.Database); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	store, err := newStoreOrchestrator(db, event.New(), cache.Disabled(), config.Passwords)
+	if err != nil {
+		return err
+	}
+	// Ensure all permissions exist, in case Thea has never been started against this database
+	if err := store.createPermissions(permissions.All()...); err != nil {
+		return fmt.Errorf("failed to sync permissions: %w", err)
+	}
+
+	switch command {
+	case "create":
+		password, err := opts.readPassword(in, out)
+		if err != nil {
+			return err
+		}
+
+		user, err := store.CreateUser([]byte(opts.username), password, perms...)
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		fmt.Fprintf(out, "Created user '%s' (%s) with %d permission(s)\n", user.Username, user.ID, len(perms))
+	case "list":
+		users, err := store.ListUsers()
+		if err != nil {
+			return fmt.Errorf("failed to list users: %w", err)
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUSERNAME\tLAST LOGIN\tPERMISSIONS")
+		for _, u := range users {
+			lastLogin := "never"
+			if u.LastLoginAt != nil {
+				lastLogin = u.LastLoginAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", u.ID, u.Username, lastLogin, len(u.Permissions))
+		}
+		return w.Flush()
+	case "set-password":
+		user, err := store.GetUserWithUsername([]byte(opts.username))
+		if err != nil {
+			return err
+		}
+		password, err := opts.readPassword(in, out)
+		if err != nil {
+			return err
+		}
+
+		if err := store.UpdateUserPassword(user.ID, password); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Updated password of user '%s'\n", user.Username)
+	case "set-permissions":
+		user, err := store.GetUserWithUsername([]byte(opts.username))
+		if err != nil {
+			return err
+		}
+
+		if err := store.UpdateUserPermissions(user.ID, perms); err != nil {
+			return fmt.Errorf("failed to update permissions: %w", err)
+		}
+		fmt.Fprintf(out, "Granted %d permission(s) to user '%s' (permissions granted by roles are unchanged)\n", len(perms), user.Username)
+	}
+
+	return nil
+}
+
+// parsePermissions returns the permissions requested, returning an
+// error if any of the permissions are not known to Thea.
+func (opts *userCommandFlags) parsePermissions() ([]string, error) {
+	if opts.allPermissions {
+		return permissions.All(), nil
+	} else if opts.permissions == "" {
+		return []string{}, nil
+	}
+
+	perms := strings.Split(opts.permissions, ",")
+	for k, perm := range perms {
+		perms[k] = strings.TrimSpace(perm)
+		if !slices.Contains(permissions.All(), perms[k]) {
+			return nil, fmt.Errorf("unknown permission '%s'", perms[k])
+		}
+	}
+
+	return perms, nil
+}
+
+// readPassword returns the password provided as a flag, or reads
+// the password from the first line of input if none was provided.
+func (opts *userCommandFlags) readPassword(in io.Reader, out io.Writer) ([]byte, error) {
+	password := opts.password
+	if password == "" {
+		if in == os.Stdin {
+			fmt.Fprintf(out, "Password for '%s': ", opts.username)
+		}
+
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if password == "" {
+		return nil, errors.New("password must not be empty")
+	}
+
+	return []byte(password), nil
+}
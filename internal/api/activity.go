@@ -2,12 +2,15 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/api/controllers/ingests"
-	"github.com/hbomb79/Thea/internal/api/controllers/transcodes"
+	"github.com/hbomb79/Thea/internal/api/controllers/system"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/http/websocket"
 	"github.com/hbomb79/Thea/internal/user/permissions"
 )
@@ -15,27 +18,72 @@ import (
 const (
 	TitleIngestUpdate            = "INGEST_UPDATE"
 	TitleMediaUpdate             = "MEDIA_UPDATE"
+	TitleWatchTargetReady        = "WATCH_TARGET_READY"
 	TitleTranscodeUpdate         = "TRANSCODE_TASK_UPDATE"
 	TitleTranscodeProgressUpdate = "TRANSCODE_TASK_PROGRESS_UPDATE"
+	TitleConsistencyReport       = "CONSISTENCY_REPORT"
+	TitleWorkflowUpdate          = "WORKFLOW_UPDATE"
+	TitleShutdown                = "SHUTDOWN"
+
+	// TitleReplayComplete is sent to clients which connect with a 'since' sequence
+	// number once all the missed activity has been replayed. If 'complete' is false,
+	// some of the missed activity was no longer available to replay.
+	TitleReplayComplete = "REPLAY_COMPLETE"
+
+	// CommandSubscribe can be sent by clients to select which activity messages they
+	// receive, by title and/or by the ID of the resource the message pertains to. An
+	// empty selection matches all messages, and so sending the command with
+	// no arguments removes any existing filter.
+	CommandSubscribe       = "SUBSCRIBE"
+	TitleSubscriptionReply = "SUBSCRIPTION_UPDATED"
 )
 
+var activityTitles = []string{TitleIngestUpdate, TitleMediaUpdate, TitleWatchTargetReady, TitleTranscodeUpdate, TitleTranscodeProgressUpdate, TitleConsistencyReport, TitleWorkflowUpdate}
+
 type broadcaster struct {
-	socketHub        *websocket.SocketHub
-	ingestService    ingests.IngestService
-	transcodeService TranscodeService
-	store            Store
+	socketHub          *websocket.SocketHub
+	ingestService      ingests.IngestService
+	transcodeService   TranscodeService
+	consistencyService system.ConsistencyService
+	store              Store
+
+	clientScopes  map[authScope][]uuid.UUID
+	clientFilters map[uuid.UUID]subscriptionFilter
+	clientMutex   *sync.Mutex
+	history       *activityHistory
+}
+
+// subscriptionFilter restricts the activity messages sent to a client. A
+// nil slice matches everything.
+type subscriptionFilter struct {
+	titles      []string
+	resourceIDs []uuid.UUID
+}
 
-	clientScopes map[authScope][]uuid.UUID
-	clientMutex  *sync.Mutex
+func (filter subscriptionFilter) matches(title string, resourceID uuid.UUID) bool {
+	return (filter.titles == nil || slices.Contains(filter.titles, title)) &&
+		(filter.resourceIDs == nil || slices.Contains(filter.resourceIDs, resourceID))
 }
 
 func newBroadcaster(
 	socketHub *websocket.SocketHub,
 	ingestService ingests.IngestService,
 	transcodeService TranscodeService,
+	consistencyService system.ConsistencyService,
 	store Store,
+	historySize int,
 ) *broadcaster {
-	return &broadcaster{socketHub, ingestService, transcodeService, store, make(map[authScope][]uuid.UUID, 0), &sync.Mutex{}}
+	return &broadcaster{
+		socketHub,
+		ingestService,
+		transcodeService,
+		consistencyService,
+		store,
+		make(map[authScope][]uuid.UUID, 0),
+		make(map[uuid.UUID]subscriptionFilter),
+		&sync.Mutex{},
+		newActivityHistory(historySize),
+	}
 }
 
 type authScope int
@@ -44,12 +92,16 @@ const (
 	mediaScope authScope = iota
 	transcodeScope
 	ingestScope
+	systemScope
+	workflowScope
 )
 
 var scopePerms = map[authScope][]string{
 	mediaScope:     {permissions.AccessMediaPermission},
 	transcodeScope: {permissions.AccessTranscodePermission},
 	ingestScope:    {permissions.AccessIngestsPermission},
+	systemScope:    {permissions.ReadSystemPermission},
+	workflowScope:  {permissions.AccessWorkflowPermission},
 }
 
 // sliceContainsAll returns true if the slice 'a' contains
@@ -64,15 +116,48 @@ func sliceContainsAll[T comparable](a, b []T) bool {
 	return true
 }
 
-func (hub *broadcaster) RegisterClient(clientID uuid.UUID, permissions []string) {
+// RegisterClient registers the client so that it receives the activity messages its permissions
+// allow. If a sequence number is provided, the messages broadcast after it which are still
+// in the history are replayed to the client, followed by a REPLAY_COMPLETE message.
+func (hub *broadcaster) RegisterClient(clientID uuid.UUID, permissions []string, since *uint64) {
 	hub.clientMutex.Lock()
 	defer hub.clientMutex.Unlock()
 
+	scopes := make([]authScope, 0, len(scopePerms))
 	for scope, requiredPerms := range scopePerms {
 		if sliceContainsAll(permissions, requiredPerms) {
 			hub.clientScopes[scope] = append(hub.clientScopes[scope], clientID)
+			scopes = append(scopes, scope)
 		}
 	}
+
+	if since == nil {
+		return
+	}
+
+	// The client mutex is held while replaying, so no new messages can be
+	// broadcast (and therefore missed/duplicated) until the replay completes.
+	entries, complete := hub.history.since(*since)
+	for _, entry := range entries {
+		if slices.Contains(scopes, entry.scope) {
+			hub.socketHub.Send(&websocket.SocketMessage{
+				Target:  &clientID,
+				Seq:     entry.seq,
+				Version: activityVersion(entry.title),
+				Title:   entry.title,
+				Body:    entry.body,
+				Type:    websocket.Update,
+			})
+		}
+	}
+	hub.socketHub.Send(&websocket.SocketMessage{
+		Target:  &clientID,
+		Seq:     hub.history.seq,
+		Version: activityVersion(TitleReplayComplete),
+		Title:   TitleReplayComplete,
+		Body:    map[string]interface{}{"complete": complete},
+		Type:    websocket.Update,
+	})
 }
 
 func (hub *broadcaster) DeregisterClient(clientID uuid.UUID) {
@@ -82,27 +167,115 @@ func (hub *broadcaster) DeregisterClient(clientID uuid.UUID) {
 	for k, clients := range hub.clientScopes {
 		hub.clientScopes[k] = slices.DeleteFunc(clients, func(id uuid.UUID) bool { return id == clientID })
 	}
+	delete(hub.clientFilters, clientID)
+}
+
+// HandleSubscribeCommand replaces the subscription filter of the client which sent the command. The
+// command accepts optional 'titles' and 'resource_ids' arguments, each an array of strings. For example,
+// {"titles": ["TRANSCODE_TASK_PROGRESS_UPDATE"], "resource_ids": ["<task id>"]} will result in the client
+// only receiving progress updates for a single transcode task.
+func (hub *broadcaster) HandleSubscribeCommand(socket *websocket.SocketHub, command *websocket.SocketMessage) error {
+	if command.Origin == nil {
+		return errors.New("command has no origin")
+	}
+
+	titles, err := stringSliceArgument(command.Body, "titles")
+	if err != nil {
+		return err
+	}
+	for _, title := range titles {
+		if !slices.Contains(activityTitles, title) {
+			return fmt.Errorf("unknown title '%s'", title)
+		}
+	}
+
+	rawIDs, err := stringSliceArgument(command.Body, "resource_ids")
+	if err != nil {
+		return err
+	}
+	var resourceIDs []uuid.UUID
+	if rawIDs != nil {
+		resourceIDs = make([]uuid.UUID, len(rawIDs))
+		for i, raw := range rawIDs {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				return fmt.Errorf("resource ID '%s' is not a valid UUID", raw)
+			}
+			resourceIDs[i] = id
+		}
+	}
+
+	hub.clientMutex.Lock()
+	if titles == nil && resourceIDs == nil {
+		delete(hub.clientFilters, *command.Origin)
+	} else {
+		hub.clientFilters[*command.Origin] = subscriptionFilter{titles, resourceIDs}
+	}
+	hub.clientMutex.Unlock()
+
+	reply := command.FormReply(TitleSubscriptionReply, map[string]interface{}{"titles": titles, "resource_ids": resourceIDs}, websocket.Response)
+	reply.Version = activityVersion(TitleSubscriptionReply)
+	socket.Send(reply)
+	return nil
+}
+
+// stringSliceArgument returns the value of the socket message argument with the given key
+// as a slice of strings. A nil slice is returned if the argument is not present.
+func stringSliceArgument(body map[string]interface{}, key string) ([]string, error) {
+	raw, ok := body[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument '%s' must be an array of strings", key)
+	}
+
+	out := make([]string, len(values))
+	for i, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("argument '%s' must be an array of strings", key)
+		}
+		out[i] = str
+	}
+
+	return out, nil
 }
 
-func (hub *broadcaster) protectedSend(scope authScope, title string, body map[string]interface{}) {
+// protectedSend sends the message to the clients which have the permissions required
+// by the scope provided, excluding those whose subscription filter does not match
+// the title or resource ID of the message.
+func (hub *broadcaster) protectedSend(scope authScope, resourceID uuid.UUID, title string, body map[string]interface{}) {
+	hub.clientMutex.Lock()
+	defer hub.clientMutex.Unlock()
+
+	seq := hub.history.record(scope, title, body)
 	clients := hub.clientScopes[scope]
 	for _, client := range clients {
+		if filter, ok := hub.clientFilters[client]; ok && !filter.matches(title, resourceID) {
+			continue
+		}
+
 		// TODO: this could cause quite the number of messages to be sent. Probably fine for
 		// now, but maybe a queue + worker pool might make sense?
 		hub.socketHub.Send(&websocket.SocketMessage{
-			Target: &client,
-			Title:  title,
-			Body:   body,
-			Type:   websocket.Update,
+			Target:  &client,
+			Seq:     seq,
+			Version: activityVersion(title),
+			Title:   title,
+			Body:    body,
+			Type:    websocket.Update,
 		})
 	}
 }
 
 func (hub *broadcaster) BroadcastTranscodeUpdate(id uuid.UUID) error {
 	item := hub.transcodeService.Task(id)
-	hub.protectedSend(transcodeScope, TitleTranscodeUpdate, map[string]interface{}{
+	hub.protectedSend(transcodeScope, id, TitleTranscodeUpdate, map[string]interface{}{
 		"id":        id,
-		"transcode": nullsafeNewDto(item, transcodes.NewDtoFromTask),
+		"transcode": nullsafeNewDto(item, dto.FromTranscodeTask),
 	})
 	return nil
 }
@@ -113,7 +286,7 @@ func (hub *broadcaster) BroadcastTaskProgressUpdate(id uuid.UUID) error {
 		return nil
 	}
 
-	hub.protectedSend(transcodeScope, TitleTranscodeProgressUpdate, map[string]interface{}{
+	hub.protectedSend(transcodeScope, id, TitleTranscodeProgressUpdate, map[string]interface{}{
 		"transcode_id": id,
 		"progress":     item.LastProgress(),
 	})
@@ -122,20 +295,28 @@ func (hub *broadcaster) BroadcastTaskProgressUpdate(id uuid.UUID) error {
 
 func (hub *broadcaster) BroadcastIngestUpdate(id uuid.UUID) error {
 	item := hub.ingestService.GetIngest(id)
-	hub.protectedSend(ingestScope, TitleIngestUpdate, map[string]interface{}{
+	hub.protectedSend(ingestScope, id, TitleIngestUpdate, map[string]interface{}{
 		"ingest_id": id,
-		"ingest":    nullsafeNewDto(item, ingests.NewDto),
+		"ingest":    nullsafeNewDto(item, dto.FromIngest),
 	})
 	return nil
 }
 
+// BroadcastWorkflowUpdate notifies clients that the workflow with the ID provided has been
+// created, modified or deleted. The workflow is nil if it has been deleted.
 func (hub *broadcaster) BroadcastWorkflowUpdate(id uuid.UUID) error {
-	return errors.New("not yet implemented")
+	workflow := hub.store.GetWorkflow(id)
+	hub.protectedSend(workflowScope, id, TitleWorkflowUpdate, map[string]interface{}{
+		"workflow_id": id,
+		"workflow":    nullsafeNewDto(workflow, dto.FromWorkflow),
+	})
+
+	return nil
 }
 
 func (hub *broadcaster) BroadcastMediaUpdate(id uuid.UUID) error {
 	media := hub.store.GetMedia(id)
-	hub.protectedSend(mediaScope, TitleMediaUpdate, map[string]interface{}{
+	hub.protectedSend(mediaScope, id, TitleMediaUpdate, map[string]interface{}{
 		"media_id": id,
 		"media":    media,
 	})
@@ -143,6 +324,50 @@ func (hub *broadcaster) BroadcastMediaUpdate(id uuid.UUID) error {
 	return nil
 }
 
+// BroadcastWatchTargetReady notifies clients that the target of the completed transcode with the
+// ID provided is now watchable for it's media, allowing UIs to mark the watch target as ready without
+// re-fetching the media. The media ID is used as the resource ID of the message, so that clients
+// subscribed to a specific media receive it.
+func (hub *broadcaster) BroadcastWatchTargetReady(id uuid.UUID) error {
+	transcode := hub.store.GetTranscode(id)
+	if transcode == nil {
+		return nil
+	}
+
+	hub.protectedSend(mediaScope, transcode.MediaID, TitleWatchTargetReady, map[string]interface{}{
+		"transcode_id": id,
+		"media_id":     transcode.MediaID,
+		"target_id":    transcode.TargetID,
+		"version_id":   transcode.VersionID,
+	})
+
+	return nil
+}
+
+// BroadcastConsistencyReport notifies clients of the report of a completed consistency check. If
+// the report provided is no longer the most recent report, then the most recent report is sent.
+func (hub *broadcaster) BroadcastConsistencyReport(id uuid.UUID) error {
+	report := hub.consistencyService.LastReport()
+	hub.protectedSend(systemScope, id, TitleConsistencyReport, map[string]interface{}{
+		"report_id": id,
+		"report":    nullsafeNewDto(report, dto.FromConsistencyReport),
+	})
+
+	return nil
+}
+
+// BroadcastShutdown notifies all connected clients that Thea is shutting down. The
+// drain timeout provided indicates how long running transcodes may continue
+// for before being cancelled.
+func (hub *broadcaster) BroadcastShutdown(drainTimeout time.Duration) {
+	hub.socketHub.Send(&websocket.SocketMessage{
+		Version: activityVersion(TitleShutdown),
+		Title:   TitleShutdown,
+		Body:    map[string]interface{}{"drain_timeout_seconds": drainTimeout.Seconds()},
+		Type:    websocket.Update,
+	})
+}
+
 // nullsafeNewDto returns nil if the given model is nil, else it will call the
 // provided generator with the model as it's only parameter. This is basically
 // shorthand for "only try and create a DTO if the 'model' isn't nil".
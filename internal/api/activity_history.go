@@ -0,0 +1,67 @@
+package api
+
+// activityHistory is a fixed-size ring buffer of the most recent activity
+// messages broadcast to clients. Each message is assigned a monotonically
+// increasing sequence number, which clients can use when reconnecting to
+// replay the activity they missed while disconnected.
+//
+// Sequence numbers are not persisted, and so restart from 1 when Thea restarts.
+type activityHistory struct {
+	entries []activityHistoryEntry
+	next    int
+	seq     uint64
+}
+
+type activityHistoryEntry struct {
+	seq   uint64
+	scope authScope
+	title string
+	body  map[string]interface{}
+}
+
+func newActivityHistory(size int) *activityHistory {
+	return &activityHistory{entries: make([]activityHistoryEntry, 0, max(size, 0))}
+}
+
+// record adds a message to the history, evicting the oldest entry if the history is full,
+// and returns the sequence number assigned to the message.
+func (history *activityHistory) record(scope authScope, title string, body map[string]interface{}) uint64 {
+	history.seq++
+	if cap(history.entries) == 0 {
+		return history.seq
+	}
+
+	entry := activityHistoryEntry{history.seq, scope, title, body}
+	if len(history.entries) < cap(history.entries) {
+		history.entries = append(history.entries, entry)
+	} else {
+		history.entries[history.next] = entry
+	}
+	history.next = (history.next + 1) % cap(history.entries)
+
+	return history.seq
+}
+
+// since returns the entries with a sequence number greater than the one provided, oldest first. The
+// boolean returned is false if some of the requested entries have already been evicted, in which
+// case the client should re-fetch the state of any resources it is interested in.
+//
+// A sequence number newer than the latest recorded indicates that the client last connected
+// to a previous run of Thea, and so every entry in the history is returned.
+func (history *activityHistory) since(seq uint64) ([]activityHistoryEntry, bool) {
+	if seq > history.seq {
+		seq = 0
+	}
+
+	// The oldest entry is at 'next' (which is len(entries) until the buffer wraps)
+	ordered := append(append([]activityHistoryEntry{}, history.entries[history.next:]...), history.entries[:history.next]...)
+	out := make([]activityHistoryEntry, 0)
+	for _, entry := range ordered {
+		if entry.seq > seq {
+			out = append(out, entry)
+		}
+	}
+
+	oldestAvailable := history.seq - uint64(len(history.entries)) + 1
+	return out, seq+1 >= oldestAvailable
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func historySeqs(entries []activityHistoryEntry) []uint64 {
+	out := make([]uint64, len(entries))
+	for i, e := range entries {
+		out[i] = e.seq
+	}
+	return out
+}
+
+func Test_ActivityHistory_Since(t *testing.T) {
+	history := newActivityHistory(3)
+	for i := 0; i < 5; i++ {
+		history.record(mediaScope, TitleMediaUpdate, nil)
+	}
+
+	// Entries 1 and 2 have been evicted
+	entries, complete := history.since(3)
+	assert.Equal(t, []uint64{4, 5}, historySeqs(entries))
+	assert.True(t, complete)
+
+	entries, complete = history.since(1)
+	assert.Equal(t, []uint64{3, 4, 5}, historySeqs(entries))
+	assert.False(t, complete)
+
+	entries, complete = history.since(5)
+	assert.Empty(t, entries)
+	assert.True(t, complete)
+
+	// A sequence from a previous run of Thea replays everything retained
+	entries, complete = history.since(100)
+	assert.Equal(t, []uint64{3, 4, 5}, historySeqs(entries))
+	assert.False(t, complete)
+}
+
+func Test_ActivityHistory_Disabled(t *testing.T) {
+	history := newActivityHistory(0)
+	assert.Equal(t, uint64(1), history.record(mediaScope, TitleMediaUpdate, nil))
+	assert.Equal(t, uint64(2), history.record(mediaScope, TitleMediaUpdate, nil))
+
+	entries, complete := history.since(2)
+	assert.Empty(t, entries)
+	assert.True(t, complete)
+
+	_, complete = history.since(1)
+	assert.False(t, complete)
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/http/websocket"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/labstack/echo/v4"
+)
+
+// activityEnvelopeVersion is the version of the envelope which all activity messages are sent in (i.e. the
+// fields of websocket.SocketMessage). It is incremented only if the envelope changes incompatibly; changes to
+// the arguments of a message instead increment the version of that message in the activityCatalog.
+const activityEnvelopeVersion = 1
+
+var (
+	ErrActivityTitleUnknown     = errors.New("activity message title is not in the schema catalog")
+	ErrActivityVersionMismatch  = errors.New("activity message version does not match the schema catalog")
+	ErrActivityArgumentsInvalid = errors.New("activity message arguments do not match the schema catalog")
+)
+
+type (
+	// activityArgument describes a single argument of an activity message. Ref is the name of the component
+	// schema in the OpenAPI spec which the argument conforms to, and is empty if the argument is a primitive
+	// or is not described by the spec.
+	activityArgument struct {
+		Name        string
+		Type        string
+		Format      string
+		Items       *activityArgument
+		Ref         string
+		Nullable    bool
+		Description string
+	}
+
+	// activitySchema describes an activity message, identified by it's title. The version is
+	// incremented whenever the arguments of the message change incompatibly, so that clients
+	// can detect messages they're unable to parse.
+	activitySchema struct {
+		Title       string
+		Version     int
+		Type        websocket.SocketMessageType
+		Permissions []string
+		Description string
+		Arguments   []activityArgument
+	}
+
+	activityCatalogResponse struct {
+		EnvelopeVersion int                     `json:"envelope_version"`
+		Messages        []activityMessageSchema `json:"messages"`
+	}
+
+	activityMessageSchema struct {
+		Title       string         `json:"title"`
+		Version     int            `json:"version"`
+		Type        int            `json:"type"`
+		Permissions []string       `json:"permissions"`
+		Description string         `json:"description"`
+		Arguments   map[string]any `json:"arguments"`
+	}
+)
+
+// activityCatalog is the schema of every message sent over the activity websocket. Broadcasts must
+// use a title from this catalog, and the arguments of the broadcast must match the schema.
+var activityCatalog = []activitySchema{
+	{
+		Title: TitleIngestUpdate, Version: 1, Type: websocket.Update,
+		Permissions: []string{permissions.AccessIngestsPermission},
+		Description: "An ingest has been created, updated or removed",
+		Arguments: []activityArgument{
+			{Name: "ingest_id", Type: "string", Format: "uuid"},
+			{Name: "ingest", Type: "object", Ref: "Ingest", Nullable: true, Description: "Null if the ingest has been removed"},
+		},
+	},
+	{
+		Title: TitleMediaUpdate, Version: 1, Type: websocket.Update,
+		Permissions: []string{permissions.AccessMediaPermission},
+		Description: "A movie or episode has been created, updated or deleted",
+		Arguments: []activityArgument{
+			{Name: "media_id", Type: "string", Format: "uuid"},
+			{Name: "media", Type: "object", Nullable: true, Description: "Null if the media has been deleted"},
+		},
+	},
+	{
+		Title: TitleWatchTargetReady, Version: 1, Type: websocket.Update,
+		Permissions: []string{permissions.AccessMediaPermission},
+		Description: "A transcode has completed, and the target is now watchable for the media",
+		Arguments: []activityArgument{
+			{Name: "transcode_id", Type: "string", Format: "uuid"},
+			{Name: "media_id", Type: "string", Format: "uuid"},
+			{Name: "target_id", Type: "string", Format: "uuid"},
+			{Name: "version_id", Type: "string", Format: "uuid", Nullable: true, Description: "Null if the primary source of the media was transcoded"},
+		},
+	},
+	{
+		Title: TitleTranscodeUpdate, Version: 1, Type: websocket.Update,
+		Permissions: []string{permissions.AccessTranscodePermission},
+		Description: "A transcode task has been created, updated or removed",
+		Arguments: []activityArgument{
+			{Name: "id", Type: "string", Format: "uuid"},
+			{Name: "transcode", Type: "object", Ref: "TranscodeTask", Nullable: true, Description: "Null if the task has been removed"},
+		},
+	},
+	{
+		Title: TitleTranscodeProgressUpdate, Version: 1, Type: websocket.Update,
+		Permissions: []string{permissions.AccessTranscodePermission},
+		Description: "FFmpeg has reported progress for a transcode task",
+		Arguments: []activityArgument{
+			{Name: "transcode_id", Type: "string", Format: "uuid"},
+			{Name: "progress", Type: "object", Nullable: true},
+		},
+	},
+	{
+		Title: TitleConsistencyReport, Version: 1, Type: websocket.Update,
+		Permissions: []string{permissions.ReadSystemPermission},
+		Description: "A consistency check has completed",
+		Arguments: []activityArgument{
+			{Name: "report_id", Type: "string", Format: "uuid"},
+			{Name: "report", Type: "object", Ref: "ConsistencyReport", Nullable: true},
+		},
+	},
+	{
+		Title: TitleWorkflowUpdate, Version: 1, Type: websocket.Update,
+		Permissions: []string{permissions.AccessWorkflowPermission},
+		Description: "A workflow has been created, updated or deleted",
+		Arguments: []activityArgument{
+			{Name: "workflow_id", Type: "string", Format: "uuid"},
+			{Name: "workflow", Type: "object", Ref: "Workflow", Nullable: true, Description: "Null if the workflow has been deleted"},
+		},
+	},
+	{
+		Title: TitleReplayComplete, Version: 1, Type: websocket.Update,
+		Description: "All missed activity has been replayed to a reconnecting client",
+		Arguments: []activityArgument{
+			{Name: "complete", Type: "boolean", Description: "False if some of the missed activity was no longer available to replay"},
+		},
+	},
+	{
+		Title: TitleShutdown, Version: 1, Type: websocket.Update,
+		Description: "Thea is shutting down",
+		Arguments: []activityArgument{
+			{Name: "drain_timeout_seconds", Type: "number", Description: "How long running transcodes may continue for before being cancelled"},
+		},
+	},
+	{
+		Title: TitleSubscriptionReply, Version: 1, Type: websocket.Response,
+		Description: "The subscription filter of the client has been replaced (see the SUBSCRIBE command)",
+		Arguments: []activityArgument{
+			{Name: "titles", Type: "array", Items: &activityArgument{Type: "string"}, Nullable: true},
+			{Name: "resource_ids", Type: "array", Items: &activityArgument{Type: "string", Format: "uuid"}, Nullable: true},
+			{Name: "command", Type: "object", Nullable: true, Description: "The arguments of the SUBSCRIBE command"},
+		},
+	},
+}
+
+// registerActivitySchemaRoute registers the activity schema catalog at the path provided. The catalog
+// describes every message sent over the activity websocket as a JSON schema, so that client generators can
+// validate payloads. The catalog contains no user data, and so does not require authentication.
+func registerActivitySchemaRoute(ec *echo.Echo, path string) {
+	catalog := activityCatalogResponse{
+		EnvelopeVersion: activityEnvelopeVersion,
+		Messages:        make([]activityMessageSchema, len(activityCatalog)),
+	}
+	for i, schema := range activityCatalog {
+		catalog.Messages[i] = activityMessageSchema{
+			Title:       schema.Title,
+			Version:     schema.Version,
+			Type:        int(schema.Type),
+			Permissions: schema.Permissions,
+			Description: schema.Description,
+			Arguments:   schema.jsonSchema(),
+		}
+	}
+
+	ec.GET(path, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, catalog)
+	})
+}
+
+// activityVersion returns the version of the activity message with the title provided,
+// or zero if the title is not in the catalog.
+func activityVersion(title string) int {
+	if schema := findActivitySchema(title); schema != nil {
+		return schema.Version
+	}
+
+	return 0
+}
+
+func findActivitySchema(title string) *activitySchema {
+	idx := slices.IndexFunc(activityCatalog, func(schema activitySchema) bool { return schema.Title == title })
+	if idx == -1 {
+		return nil
+	}
+
+	return &activityCatalog[idx]
+}
+
+// ValidateActivityMessage returns an error if the activity message provided, as received
+// by a client (i.e. after being decoded from JSON), does not match the schema catalog.
+func ValidateActivityMessage(message *websocket.SocketMessage) error {
+	schema := findActivitySchema(message.Title)
+	if schema == nil {
+		return fmt.Errorf("%w: %s", ErrActivityTitleUnknown, message.Title)
+	}
+	if message.Version != schema.Version {
+		return fmt.Errorf("%w: %s is version %d, expected %d", ErrActivityVersionMismatch, message.Title, message.Version, schema.Version)
+	}
+
+	errs := make([]error, 0)
+	for key := range message.Body {
+		if !slices.ContainsFunc(schema.Arguments, func(arg activityArgument) bool { return arg.Name == key }) {
+			errs = append(errs, fmt.Errorf("unexpected argument '%s'", key))
+		}
+	}
+	for _, arg := range schema.Arguments {
+		value, ok := message.Body[arg.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("argument '%s' is missing", arg.Name))
+		} else if err := arg.validate(value); err != nil {
+			errs = append(errs, fmt.Errorf("argument '%s' %w", arg.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %s: %w", ErrActivityArgumentsInvalid, message.Title, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (arg *activityArgument) validate(value any) error {
+	if value == nil {
+		if arg.Nullable {
+			return nil
+		}
+
+		return errors.New("must not be null")
+	}
+
+	valid := true
+	switch arg.Type {
+	case "string":
+		str, ok := value.(string)
+		valid = ok
+		if ok && arg.Format == "uuid" {
+			_, err := uuid.Parse(str)
+			valid = err == nil
+		}
+	case "number":
+		_, valid = value.(float64)
+	case "boolean":
+		_, valid = value.(bool)
+	case "object":
+		_, valid = value.(map[string]any)
+	case "array":
+		items, ok := value.([]any)
+		valid = ok
+		for _, item := range items {
+			if err := arg.Items.validate(item); err != nil {
+				return fmt.Errorf("item %w", err)
+			}
+		}
+	}
+
+	if !valid {
+		if arg.Format != "" {
+			return fmt.Errorf("must be a %s (%s)", arg.Type, arg.Format)
+		}
+
+		return fmt.Errorf("must be a %s", arg.Type)
+	}
+
+	return nil
+}
+
+// jsonSchema returns the JSON schema of the arguments of the activity message.
+func (schema *activitySchema) jsonSchema() map[string]any {
+	properties := make(map[string]any, len(schema.Arguments))
+	required := make([]string, len(schema.Arguments))
+	for i, arg := range schema.Arguments {
+		properties[arg.Name] = arg.jsonSchema()
+		required[i] = arg.Name
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func (arg *activityArgument) jsonSchema() map[string]any {
+	out := map[string]any{"type": arg.Type}
+	if arg.Nullable {
+		out["type"] = []string{arg.Type, "null"}
+	}
+	if arg.Format != "" {
+		out["format"] = arg.Format
+	}
+	if arg.Items != nil {
+		out["items"] = arg.Items.jsonSchema()
+	}
+	if arg.Ref != "" {
+		out["x-openapi-schema"] = arg.Ref
+	}
+	if arg.Description != "" {
+		out["description"] = arg.Description
+	}
+
+	return out
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/http/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeActivity round-trips the message provided through JSON, as clients would receive it.
+func decodeActivity(t *testing.T, message *websocket.SocketMessage) *websocket.SocketMessage {
+	raw, err := json.Marshal(message)
+	assert.NoError(t, err)
+
+	var decoded websocket.SocketMessage
+	assert.NoError(t, json.Unmarshal(raw, &decoded))
+	return &decoded
+}
+
+func Test_ActivityCatalog_CoversTitles(t *testing.T) {
+	for _, title := range append(activityTitles, TitleReplayComplete, TitleShutdown, TitleSubscriptionReply) {
+		assert.NotNil(t, findActivitySchema(title), "title %s is missing from the activity catalog", title)
+	}
+}
+
+func Test_ValidateActivityMessage(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name    string
+		message websocket.SocketMessage
+		err     error
+	}{
+		{
+			name:    "Valid",
+			message: websocket.SocketMessage{Title: TitleWatchTargetReady, Version: 1, Body: map[string]any{"transcode_id": id, "media_id": id, "target_id": id, "version_id": nil}},
+		},
+		{
+			name:    "NullableObject",
+			message: websocket.SocketMessage{Title: TitleIngestUpdate, Version: 1, Body: map[string]any{"ingest_id": id, "ingest": nil}},
+		},
+		{
+			name:    "UnknownTitle",
+			message: websocket.SocketMessage{Title: "NOT_A_TITLE", Version: 1},
+			err:     ErrActivityTitleUnknown,
+		},
+		{
+			name:    "WrongVersion",
+			message: websocket.SocketMessage{Title: TitleReplayComplete, Version: 2, Body: map[string]any{"complete": true}},
+			err:     ErrActivityVersionMismatch,
+		},
+		{
+			name:    "MissingArgument",
+			message: websocket.SocketMessage{Title: TitleIngestUpdate, Version: 1, Body: map[string]any{"ingest_id": id}},
+			err:     ErrActivityArgumentsInvalid,
+		},
+		{
+			name:    "UnexpectedArgument",
+			message: websocket.SocketMessage{Title: TitleReplayComplete, Version: 1, Body: map[string]any{"complete": true, "extra": 1}},
+			err:     ErrActivityArgumentsInvalid,
+		},
+		{
+			name:    "InvalidUUID",
+			message: websocket.SocketMessage{Title: TitleIngestUpdate, Version: 1, Body: map[string]any{"ingest_id": "not-a-uuid", "ingest": nil}},
+			err:     ErrActivityArgumentsInvalid,
+		},
+		{
+			name:    "InvalidArrayItem",
+			message: websocket.SocketMessage{Title: TitleSubscriptionReply, Version: 1, Body: map[string]any{"titles": []string{"A"}, "resource_ids": []int{1}, "command": nil}},
+			err:     ErrActivityArgumentsInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateActivityMessage(decodeActivity(t, &test.message))
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"reflect"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/audit"
+	"github.com/labstack/echo/v4"
+)
+
+// auditedOperations contains the IDs of the operations which are
+// considered privileged, and so must be recorded in the audit log
+// when they complete successfully.
+var auditedOperations = map[string]struct{}{
+	"Register":                {},
+	"CreateUser":              {},
+	"UpdateUserPermissions":   {},
+	"UpdateUserRoles":         {},
+	"RevokeUserSession":       {},
+	"CreateRole":              {},
+	"UpdateRole":              {},
+	"DeleteRole":              {},
+	"CreateInvite":            {},
+	"DeleteInvite":            {},
+	"CreateShareLink":         {},
+	"DeleteShareLink":         {},
+	"DeleteMovie":             {},
+	"DeleteSeries":            {},
+	"SetSeriesMonitored":      {},
+	"DeleteSeason":            {},
+	"DeleteEpisode":           {},
+	"RestoreFromTrash":        {},
+	"UpdateMediaVersion":      {},
+	"CreateCollection":        {},
+	"UpdateCollection":        {},
+	"DeleteCollection":        {},
+	"CreateTag":               {},
+	"UpdateTag":               {},
+	"DeleteTag":               {},
+	"DeleteIngest":            {},
+	"ResolveIngest":           {},
+	"BulkResolveIngests":      {},
+	"PauseIngest":             {},
+	"ResumeIngest":            {},
+	"PrioritizeIngest":        {},
+	"ReleaseIngest":           {},
+	"DeleteQuarantinedIngest": {},
+	"CreateTranscodeTask":     {},
+	"DeleteTranscodeTask":     {},
+	"PauseTranscodeTask":      {},
+	"ResumeTranscodeTask":     {},
+	"PauseTranscodeQueue":     {},
+	"ResumeTranscodeQueue":    {},
+	"CreateWorkflow":          {},
+	"UpdateWorkflow":          {},
+	"DeleteWorkflow":          {},
+	"ApplyWorkflow":           {},
+	"CreateTarget":            {},
+	"UpdateTarget":            {},
+	"DeleteTarget":            {},
+	"CreateBackup":            {},
+	"RunConsistencyCheck":     {},
+	"VerifyIntegrity":         {},
+	"ExportLibrary":           {},
+	"ReloadConfig":            {},
+	"SetMaintenanceMode":      {},
+}
+
+type AuditStore interface {
+	RecordAuditEntry(entry *audit.Entry) error
+}
+
+// newAuditMiddleware returns a strict middleware which records an audit entry
+// for every successful request to an operation in auditedOperations. Failure
+// to record an entry is logged, but does not fail the request as the action
+// has already been performed.
+func newAuditMiddleware(store AuditStore) gen.StrictMiddlewareFunc {
+	return func(f gen.StrictHandlerFunc, operationID string) gen.StrictHandlerFunc {
+		if _, ok := auditedOperations[operationID]; !ok {
+			return f
+		}
+
+		return func(ec echo.Context, request interface{}) (interface{}, error) {
+			response, err := f(ec, request)
+			if err != nil {
+				return response, err
+			}
+
+			entry := &audit.Entry{
+				ID:         uuid.New(),
+				Action:     operationID,
+				Method:     ec.Request().Method,
+				Path:       ec.Request().URL.Path,
+				RequestID:  ec.Response().Header().Get(echo.HeaderXRequestID),
+				ResourceID: auditResourceID(request, response),
+			}
+			if user, ok := ec.Get("user").(*jwt.AuthenticatedUser); ok {
+				entry.UserID = &user.UserID
+			}
+
+			if err := store.RecordAuditEntry(entry); err != nil {
+				log.Errorf("Failed to record audit entry for %s (request %s): %v\n", operationID, entry.RequestID, err)
+			}
+
+			return response, nil
+		}
+	}
+}
+
+// auditResourceID returns the ID of the resource affected by an audited operation. This is
+// the ID in the path of the request (e.g. DELETE /movies/{id}), or otherwise the ID of the
+// resource in the response (e.g. the role created by POST /roles). Operations which do not
+// affect a single resource (e.g. SetMaintenanceMode) have no resource ID, and so nil is returned.
+func auditResourceID(request interface{}, response interface{}) *uuid.UUID {
+	for _, v := range []interface{}{request, response} {
+		value := reflect.Indirect(reflect.ValueOf(v))
+		if value.Kind() != reflect.Struct {
+			continue
+		}
+
+		if field := value.FieldByName("Id"); field.IsValid() {
+			if id, ok := field.Interface().(uuid.UUID); ok && id != uuid.Nil {
+				return &id
+			}
+		}
+	}
+
+	return nil
+}
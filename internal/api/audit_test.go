@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/audit"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockAuditStore struct{ entries []*audit.Entry }
+
+func (store *mockAuditStore) RecordAuditEntry(entry *audit.Entry) error {
+	store.entries = append(store.entries, entry)
+	return nil
+}
+
+func Test_AuditResourceID(t *testing.T) {
+	t.Parallel()
+	requestID, responseID := uuid.New(), uuid.New()
+
+	assert.Equal(t, &requestID, auditResourceID(gen.DeleteMovieRequestObject{Id: requestID}, gen.DeleteMovie201Response{}), "ID in the request path should be used")
+	assert.Equal(t, &requestID, auditResourceID(gen.UpdateRoleRequestObject{Id: requestID}, gen.UpdateRole200JSONResponse{Id: responseID}), "ID in the request path should be preferred")
+	assert.Equal(t, &responseID, auditResourceID(gen.CreateRoleRequestObject{}, gen.CreateRole201JSONResponse{Id: responseID}), "ID of the created resource should be used")
+	assert.Nil(t, auditResourceID(gen.SetMaintenanceModeRequestObject{}, gen.SetMaintenanceMode200JSONResponse{}))
+	assert.Nil(t, auditResourceID(nil, nil))
+}
+
+func Test_AuditMiddleware_RecordsResourceID(t *testing.T) {
+	t.Parallel()
+	store := &mockAuditStore{}
+	middleware := newAuditMiddleware(store)
+	movieID := uuid.New()
+
+	handler := func(echo.Context, interface{}) (interface{}, error) { return gen.DeleteMovie201Response{}, nil }
+	ec := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/movies/"+movieID.String(), nil), httptest.NewRecorder())
+	_, err := middleware(handler, "DeleteMovie")(ec, gen.DeleteMovieRequestObject{Id: movieID})
+	assert.NoError(t, err)
+
+	assert.Len(t, store.entries, 1)
+	assert.Equal(t, "DeleteMovie", store.entries[0].Action)
+	assert.Equal(t, &movieID, store.entries[0].ResourceID)
+
+	// Operations which are not audited are not recorded
+	_, err = middleware(handler, "ListMedia")(ec, gen.DeleteMovieRequestObject{Id: movieID})
+	assert.NoError(t, err)
+	assert.Len(t, store.entries, 1)
+}
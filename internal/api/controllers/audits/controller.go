@@ -0,0 +1,41 @@
+package audits
+
+import (
+	"net/http"
+
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/audit"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	Store interface {
+		ListAuditEntries(filter audit.Filter) ([]*audit.Entry, error)
+	}
+
+	AuditController struct{ store Store }
+)
+
+func New(store Store) *AuditController {
+	return &AuditController{store: store}
+}
+
+func (controller *AuditController) ListAuditEntries(ec echo.Context, request gen.ListAuditEntriesRequestObject) (gen.ListAuditEntriesResponseObject, error) {
+	filter := audit.Filter{
+		UserID:     request.Params.UserId,
+		ResourceID: request.Params.ResourceId,
+		Action:     request.Params.Action,
+		Since:      request.Params.Since,
+		Offset:     util.NotNilOrDefault(request.Params.Offset, 0),
+		Limit:      util.NotNilOrDefault(request.Params.Limit, 0),
+	}
+
+	entries, err := controller.store.ListAuditEntries(filter)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListAuditEntries200JSONResponse(util.ApplyConversion(entries, dto.FromAuditEntry)), nil
+}
@@ -1,11 +1,16 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/api/gen"
 	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/preferences"
+	"github.com/hbomb79/Thea/internal/trakt"
 	"github.com/hbomb79/Thea/internal/user"
 	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/labstack/echo/v4"
@@ -22,24 +27,44 @@ type (
 		RecordUserRefresh(userID uuid.UUID) error
 		GetUserWithUsernameAndPassword(username []byte, rawPassword []byte) (*user.User, error)
 		GetUserWithID(ID uuid.UUID) (*user.User, error)
+		RegisterUserWithInvite(token string, username []byte, rawPassword []byte) (*user.User, error)
+		GetUserPreferences(userID uuid.UUID) (*preferences.Preferences, error)
+		SaveUserPreferences(prefs *preferences.Preferences) error
 	}
 
 	AuthProvider interface {
-		RefreshTokens(allegedRefreshToken string) (*http.Cookie, *http.Cookie, error)
-		GenerateTokenCookies(userID uuid.UUID) (*http.Cookie, *http.Cookie, error)
+		RefreshTokens(allegedRefreshToken string, userAgent string, ip string) (*http.Cookie, *http.Cookie, error)
+		GenerateTokenCookies(userID uuid.UUID, userAgent string, ip string) (*http.Cookie, *http.Cookie, error)
 		GetAuthenticatedUserFromContext(ec echo.Context) (*jwt.AuthenticatedUser, error)
 		RevokeTokensInContext(ec echo.Context) (*http.Cookie, *http.Cookie)
 		RevokeAllForUser(userID uuid.UUID) (*http.Cookie, *http.Cookie)
+		Sessions(userID uuid.UUID) []jwt.Session
+		RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error
+	}
+
+	// TraktService links users to their Trakt accounts.
+	TraktService interface {
+		Link(ctx context.Context, userID uuid.UUID) (*trakt.Link, error)
+		Status(userID uuid.UUID) (*trakt.Link, error)
+		Unlink(ctx context.Context, userID uuid.UUID) error
+	}
+
+	// TrustedHeaderAuthenticator authenticates requests using the
+	// header set by an authenticating reverse proxy (if enabled).
+	TrustedHeaderAuthenticator interface {
+		Authenticate(request *http.Request) (*user.User, error)
 	}
 
 	AuthController struct {
-		store        Store
-		authProvider AuthProvider
+		store             Store
+		authProvider      AuthProvider
+		trustedHeaderAuth TrustedHeaderAuthenticator
+		traktService      TraktService
 	}
 )
 
-func New(authProvider AuthProvider, store Store) *AuthController {
-	return &AuthController{store, authProvider}
+func New(authProvider AuthProvider, store Store, trustedHeaderAuth TrustedHeaderAuthenticator, traktService TraktService) *AuthController {
+	return &AuthController{store, authProvider, trustedHeaderAuth, traktService}
 }
 
 // Login accepts a POST request containing the
@@ -60,12 +85,48 @@ func (controller *AuthController) Login(ec echo.Context, request gen.LoginReques
 		return nil, gen.ErrAPIUnauthorized
 	}
 
-	authTokenCookie, refreshTokenCookie, err := controller.authProvider.GenerateTokenCookies(user.ID)
+	authTokenCookie, refreshTokenCookie, err := controller.authProvider.GenerateTokenCookies(user.ID, ec.Request().UserAgent(), ec.RealIP())
+	if err != nil {
+		log.Warnf("Failed to authenticate due to error: %v\n", err)
+		return nil, gen.ErrAPIUnauthorized
+	}
+	return LoginResponse{User: dto.FromUser(user), AuthToken: *authTokenCookie, RefreshToken: *refreshTokenCookie}, nil
+}
+
+// LoginWithTrustedHeader logs in as the user named by the header set by an authenticating
+// reverse proxy (e.g. Authelia/Authentik), and generates an auth token and refresh token
+// the same as Login. The header is only trusted if the request came from a trusted proxy.
+func (controller *AuthController) LoginWithTrustedHeader(ec echo.Context, request gen.LoginWithTrustedHeaderRequestObject) (gen.LoginWithTrustedHeaderResponseObject, error) {
+	user, err := controller.trustedHeaderAuth.Authenticate(ec.Request())
+	if err != nil {
+		log.Warnf("Failed to authenticate using trusted header due to error: %v\n", err)
+		return nil, err
+	}
+
+	authTokenCookie, refreshTokenCookie, err := controller.authProvider.GenerateTokenCookies(user.ID, ec.Request().UserAgent(), ec.RealIP())
 	if err != nil {
 		log.Warnf("Failed to authenticate due to error: %v\n", err)
 		return nil, gen.ErrAPIUnauthorized
 	}
-	return LoginResponse{User: userToDto(user), AuthToken: *authTokenCookie, RefreshToken: *refreshTokenCookie}, nil
+	return LoginResponse{User: dto.FromUser(user), AuthToken: *authTokenCookie, RefreshToken: *refreshTokenCookie}, nil
+}
+
+// Register accepts a POST request containing the username and password
+// for a new user, and an invite token in the query params. The new user is
+// granted the permissions/roles of the invite, and the invite is consumed.
+// The new user is NOT logged in; clients should login as usual.
+func (controller *AuthController) Register(ec echo.Context, request gen.RegisterRequestObject) (gen.RegisterResponseObject, error) {
+	newUser, err := controller.store.RegisterUserWithInvite(request.Params.Invite, []byte(request.Body.Username), []byte(request.Body.Password))
+	if err != nil {
+		if errors.Is(err, user.ErrInviteInvalid) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, user.ErrInviteInvalid)
+		}
+
+		log.Warnf("Failed to register user due to error: %v\n", err)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Failed to register user")
+	}
+
+	return gen.Register201JSONResponse(dto.FromUser(newUser)), nil
 }
 
 func (controller *AuthController) LogoutSession(ec echo.Context, request gen.LogoutSessionRequestObject) (gen.LogoutSessionResponseObject, error) {
@@ -92,7 +153,7 @@ func (controller *AuthController) Refresh(ec echo.Context, request gen.RefreshRe
 		return nil, echo.ErrUnauthorized
 	}
 
-	authTokenCookie, refreshTokenCookie, err := controller.authProvider.RefreshTokens(cookieToken.Value)
+	authTokenCookie, refreshTokenCookie, err := controller.authProvider.RefreshTokens(cookieToken.Value, ec.Request().UserAgent(), ec.RealIP())
 	if err != nil {
 		log.Errorf("Failed to refresh: %s\n", err)
 		return nil, echo.ErrForbidden
@@ -114,5 +175,151 @@ func (controller *AuthController) GetCurrentUser(ec echo.Context, request gen.Ge
 		return nil, errUnauthorized
 	}
 
-	return gen.GetCurrentUser200JSONResponse(userToDto(u)), nil
+	return gen.GetCurrentUser200JSONResponse(dto.FromUser(u)), nil
+}
+
+// GetOwnTraktLink returns the link between the authenticated user and their Trakt account.
+func (controller *AuthController) GetOwnTraktLink(ec echo.Context, _ gen.GetOwnTraktLinkRequestObject) (gen.GetOwnTraktLinkResponseObject, error) {
+	authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	link, err := controller.traktService.Status(authUser.UserID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetOwnTraktLink200JSONResponse(dto.FromTraktLink(link)), nil
+}
+
+// LinkOwnTrakt starts linking the authenticated user to their Trakt account, returning
+// the code the user must approve to complete the link.
+func (controller *AuthController) LinkOwnTrakt(ec echo.Context, _ gen.LinkOwnTraktRequestObject) (gen.LinkOwnTraktResponseObject, error) {
+	authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	link, err := controller.traktService.Link(ec.Request().Context(), authUser.UserID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.LinkOwnTrakt202JSONResponse(dto.FromTraktLink(link)), nil
+}
+
+// UnlinkOwnTrakt cancels the pending Trakt link of the authenticated user, or unlinks their Trakt account.
+func (controller *AuthController) UnlinkOwnTrakt(ec echo.Context, _ gen.UnlinkOwnTraktRequestObject) (gen.UnlinkOwnTraktResponseObject, error) {
+	authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	if err := controller.traktService.Unlink(ec.Request().Context(), authUser.UserID); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.UnlinkOwnTrakt204Response{}, nil
+}
+
+// ListOwnSessions returns the active sessions of the authenticated user, marking
+// the session the request was made with as the current session.
+func (controller *AuthController) ListOwnSessions(ec echo.Context, request gen.ListOwnSessionsRequestObject) (gen.ListOwnSessionsResponseObject, error) {
+	authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return gen.ListOwnSessions200JSONResponse(controller.sessions(authUser.UserID, authUser.SessionID)), nil
+}
+
+// RevokeOwnSession revokes a session of the authenticated user. Revoking the
+// session the request was made with is equivalent to logging out.
+func (controller *AuthController) RevokeOwnSession(ec echo.Context, request gen.RevokeOwnSessionRequestObject) (gen.RevokeOwnSessionResponseObject, error) {
+	authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	if err := controller.authProvider.RevokeSession(authUser.UserID, request.Id); err != nil {
+		return nil, err
+	}
+
+	return gen.RevokeOwnSession204Response{}, nil
+}
+
+// GetOwnPreferences returns the playback preferences of the authenticated user.
+func (controller *AuthController) GetOwnPreferences(ec echo.Context, _ gen.GetOwnPreferencesRequestObject) (gen.GetOwnPreferencesResponseObject, error) {
+	authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	prefs, err := controller.store.GetUserPreferences(authUser.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.GetOwnPreferences200JSONResponse(dto.FromPlaybackPreferences(prefs)), nil
+}
+
+// UpdateOwnPreferences replaces the playback preferences of the authenticated user.
+func (controller *AuthController) UpdateOwnPreferences(ec echo.Context, request gen.UpdateOwnPreferencesRequestObject) (gen.UpdateOwnPreferencesResponseObject, error) {
+	authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	prefs := &preferences.Preferences{
+		UserID:              authUser.UserID,
+		AudioLanguage:       request.Body.AudioLanguage,
+		SubtitleLanguage:    request.Body.SubtitleLanguage,
+		MaxBitrateKbps:      request.Body.MaxBitrateKbps,
+		AutoplayNextEpisode: request.Body.AutoplayNextEpisode,
+	}
+	if err := controller.store.SaveUserPreferences(prefs); err != nil {
+		return nil, err
+	}
+
+	updated, err := controller.store.GetUserPreferences(authUser.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.UpdateOwnPreferences200JSONResponse(dto.FromPlaybackPreferences(updated)), nil
+}
+
+// ListUserSessions returns the active sessions of any user.
+func (controller *AuthController) ListUserSessions(ec echo.Context, request gen.ListUserSessionsRequestObject) (gen.ListUserSessionsResponseObject, error) {
+	if _, err := controller.store.GetUserWithID(request.Id); err != nil {
+		return nil, err
+	}
+
+	var currentSessionID uuid.UUID
+	if authUser, err := controller.authProvider.GetAuthenticatedUserFromContext(ec); err == nil {
+		currentSessionID = authUser.SessionID
+	}
+
+	return gen.ListUserSessions200JSONResponse(controller.sessions(request.Id, currentSessionID)), nil
+}
+
+// RevokeUserSession revokes a session of any user.
+func (controller *AuthController) RevokeUserSession(ec echo.Context, request gen.RevokeUserSessionRequestObject) (gen.RevokeUserSessionResponseObject, error) {
+	if err := controller.authProvider.RevokeSession(request.Id, request.SessionId); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Session %s of user %s has been revoked\n", request.SessionId, request.Id)
+	return gen.RevokeUserSession204Response{}, nil
+}
+
+func (controller *AuthController) sessions(userID uuid.UUID, currentSessionID uuid.UUID) []gen.LoginSession {
+	sessions := controller.authProvider.Sessions(userID)
+	output := make([]gen.LoginSession, len(sessions))
+	for k, session := range sessions {
+		output[k] = dto.FromLoginSession(session, currentSessionID)
+	}
+
+	return output
 }
@@ -32,6 +32,10 @@ func (response LoginResponse) VisitLoginResponse(w http.ResponseWriter) error {
 	return json.NewEncoder(w).Encode(response.User)
 }
 
+func (response LoginResponse) VisitLoginWithTrustedHeaderResponse(w http.ResponseWriter) error {
+	return response.VisitLoginResponse(w)
+}
+
 func (response SetTokenCookiesResponse) setTokensInResponse(w http.ResponseWriter) error {
 	http.SetCookie(w, &response.AuthToken)
 	http.SetCookie(w, &response.RefreshToken)
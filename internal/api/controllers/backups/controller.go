@@ -0,0 +1,56 @@
+package backups
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	BackupService interface {
+		Create() (string, error)
+	}
+
+	BackupController struct{ service BackupService }
+)
+
+func New(service BackupService) *BackupController {
+	return &BackupController{service: service}
+}
+
+func (controller *BackupController) CreateBackup(ec echo.Context, _ gen.CreateBackupRequestObject) (gen.CreateBackupResponseObject, error) {
+	path, err := controller.service.Create()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Errorf("failed to create backup: %w", err))
+	}
+
+	file, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return backupArchiveResponse{file: file, name: filepath.Base(path)}, nil
+}
+
+// backupArchiveResponse streams the backup archive to the client
+// as an attachment, closing the file once the response is written.
+type backupArchiveResponse struct {
+	file *os.File
+	name string
+}
+
+func (response backupArchiveResponse) VisitCreateBackupResponse(w http.ResponseWriter) error {
+	defer response.file.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", response.name))
+	w.WriteHeader(http.StatusOK)
+
+	_, err := io.Copy(w, response.file)
+	return err
+}
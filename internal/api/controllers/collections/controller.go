@@ -0,0 +1,97 @@
+package collections
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	Store interface {
+		CreateCollection(title string, description string, mediaIDs []uuid.UUID) (*media.InflatedCollection, error)
+		UpdateCollection(collectionID uuid.UUID, title *string, description *string, mediaIDs *[]uuid.UUID) (*media.InflatedCollection, error)
+		GetCollection(collectionID uuid.UUID) (*media.InflatedCollection, error)
+		ListCollections() ([]*media.Collection, error)
+		DeleteCollection(collectionID uuid.UUID) error
+	}
+
+	CollectionController struct{ store Store }
+)
+
+func New(store Store) *CollectionController {
+	return &CollectionController{store: store}
+}
+
+func (controller *CollectionController) ListCollections(ec echo.Context, _ gen.ListCollectionsRequestObject) (gen.ListCollectionsResponseObject, error) {
+	collections, err := controller.store.ListCollections()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListCollections200JSONResponse(util.ApplyConversion(collections, dto.FromCollection)), nil
+}
+
+func (controller *CollectionController) CreateCollection(ec echo.Context, request gen.CreateCollectionRequestObject) (gen.CreateCollectionResponseObject, error) {
+	description := ""
+	if request.Body.Description != nil {
+		description = *request.Body.Description
+	}
+
+	mediaIDs := []uuid.UUID{}
+	if request.Body.MediaIds != nil {
+		mediaIDs = *request.Body.MediaIds
+	}
+
+	collection, err := controller.store.CreateCollection(request.Body.Title, description, mediaIDs)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create new collection: %w", err))
+	}
+
+	return gen.CreateCollection201JSONResponse(dto.FromInflatedCollection(collection)), nil
+}
+
+func (controller *CollectionController) GetCollection(ec echo.Context, request gen.GetCollectionRequestObject) (gen.GetCollectionResponseObject, error) {
+	collection, err := controller.store.GetCollection(request.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.ErrNotFound
+		}
+
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetCollection200JSONResponse(dto.FromInflatedCollection(collection)), nil
+}
+
+func (controller *CollectionController) UpdateCollection(ec echo.Context, request gen.UpdateCollectionRequestObject) (gen.UpdateCollectionResponseObject, error) {
+	collection, err := controller.store.UpdateCollection(request.Id, request.Body.Title, request.Body.Description, request.Body.MediaIds)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.ErrNotFound
+		}
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to update collection: %w", err))
+	}
+
+	return gen.UpdateCollection200JSONResponse(dto.FromInflatedCollection(collection)), nil
+}
+
+func (controller *CollectionController) DeleteCollection(ec echo.Context, request gen.DeleteCollectionRequestObject) (gen.DeleteCollectionResponseObject, error) {
+	if err := controller.store.DeleteCollection(request.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.ErrNotFound
+		}
+
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.DeleteCollection204Response{}, nil
+}
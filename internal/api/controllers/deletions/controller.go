@@ -0,0 +1,63 @@
+package deletions
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/deletion"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	DeletionService interface {
+		CreatePreview(filter media.DeletionFilter, deleteSources bool) (*deletion.Job, error)
+		Execute(id uuid.UUID) (*deletion.Job, error)
+		Job(id uuid.UUID) *deletion.Job
+		Progress(job *deletion.Job) deletion.JobProgress
+	}
+
+	DeletionController struct{ service DeletionService }
+)
+
+func New(service DeletionService) *DeletionController {
+	return &DeletionController{service: service}
+}
+
+func (controller *DeletionController) CreateBulkDeletion(ec echo.Context, request gen.CreateBulkDeletionRequestObject) (gen.CreateBulkDeletionResponseObject, error) {
+	filter := media.DeletionFilter{
+		CreatedBefore:  request.Body.CreatedBefore,
+		MaxFrameHeight: request.Body.MaxFrameHeight,
+	}
+	if request.Body.GenreIds != nil {
+		filter.GenreIDs = *request.Body.GenreIds
+	}
+	deleteSources := request.Body.DeleteSourceFiles != nil && *request.Body.DeleteSourceFiles
+
+	job, err := controller.service.CreatePreview(filter, deleteSources)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.CreateBulkDeletion200JSONResponse(dto.FromBulkDeletion(job, controller.service.Progress(job))), nil
+}
+
+func (controller *DeletionController) GetBulkDeletion(ec echo.Context, request gen.GetBulkDeletionRequestObject) (gen.GetBulkDeletionResponseObject, error) {
+	job := controller.service.Job(request.Id)
+	if job == nil {
+		return nil, fmt.Errorf("%w: %s", deletion.ErrJobNotFound, request.Id)
+	}
+
+	return gen.GetBulkDeletion200JSONResponse(dto.FromBulkDeletion(job, controller.service.Progress(job))), nil
+}
+
+func (controller *DeletionController) ExecuteBulkDeletion(ec echo.Context, request gen.ExecuteBulkDeletionRequestObject) (gen.ExecuteBulkDeletionResponseObject, error) {
+	job, err := controller.service.Execute(request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.ExecuteBulkDeletion202JSONResponse(dto.FromBulkDeletion(job, controller.service.Progress(job))), nil
+}
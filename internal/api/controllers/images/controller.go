@@ -0,0 +1,64 @@
+package images
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/artwork"
+	"github.com/labstack/echo/v4"
+)
+
+// imageCacheControl allows clients to cache images indefinitely, as TMDB images never change. The
+// response is private as the endpoint requires authentication.
+const imageCacheControl = "private, max-age=31536000, immutable"
+
+type (
+	ArtworkService interface {
+		Image(imagePath string, width int) (*artwork.Image, error)
+	}
+
+	ImageController struct{ artwork ArtworkService }
+)
+
+func New(artwork ArtworkService) *ImageController {
+	return &ImageController{artwork: artwork}
+}
+
+func (controller *ImageController) GetTmdbImage(ec echo.Context, request gen.GetTmdbImageRequestObject) (gen.GetTmdbImageResponseObject, error) {
+	width := 0
+	if request.Params.Width != nil {
+		width = *request.Params.Width
+	}
+
+	image, err := controller.artwork.Image("/"+request.File, width)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(image.Path)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return imageResponse{file: file, etag: image.ETag, request: ec.Request()}, nil
+}
+
+// imageResponse serves the cached image to the client, honouring conditional requests
+// using the ETag of the image. The file is closed once the response is written.
+type imageResponse struct {
+	file    *os.File
+	etag    string
+	request *http.Request
+}
+
+func (response imageResponse) VisitGetTmdbImageResponse(w http.ResponseWriter) error {
+	defer response.file.Close()
+
+	w.Header().Set("Cache-Control", imageCacheControl)
+	w.Header().Set("ETag", response.etag)
+	http.ServeContent(w, response.request, filepath.Base(response.file.Name()), time.Time{}, response.file)
+	return nil
+}
@@ -1,12 +1,18 @@
 package ingests
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
 	"github.com/hbomb79/Thea/internal/ingest"
-	"github.com/hbomb79/Thea/pkg/logger"
+	"github.com/hbomb79/Thea/internal/workflow"
 	"github.com/labstack/echo/v4"
 )
 
@@ -16,7 +22,26 @@ type (
 		GetIngest(ingestID uuid.UUID) *ingest.IngestItem
 		RemoveIngest(ingestID uuid.UUID) error
 		DiscoverNewFiles()
-		ResolveTroubledIngest(itemID uuid.UUID, method ingest.ResolutionType, context map[string]string) error
+		ResolveTroubledIngest(ctx context.Context, itemID uuid.UUID, method ingest.ResolutionType, context map[string]string) error
+		GetAllIngestGroups() []*ingest.IngestGroup
+		RemoveIngestGroup(groupID uuid.UUID) error
+		ResolveTroubledGroup(ctx context.Context, groupID uuid.UUID, method ingest.ResolutionType, context map[string]string) error
+		PauseIngest(itemID uuid.UUID) error
+		ResumeIngest(itemID uuid.UUID) error
+		PrioritizeIngest(itemID uuid.UUID) error
+		GetQuarantinedIngests() []*ingest.IngestItem
+		ReleaseIngest(itemID uuid.UUID) error
+		DeleteQuarantinedIngest(itemID uuid.UUID) error
+		DryRunIngest(ctx context.Context, path string) (*ingest.DryRun, error)
+		StageMetrics() []ingest.StageMetrics
+		SetParallelism(parallelism int) error
+		Parallelism() int
+		WatcherStatus() ingest.WatcherStatus
+		NotifyDownloadComplete(ctx context.Context, path string, infoHash *string) ([]*ingest.IngestItem, error)
+	}
+
+	WorkflowStore interface {
+		GetAllWorkflows() []*workflow.Workflow
 	}
 
 	// IngestsController is the struct which is responsible for defining the
@@ -24,24 +49,19 @@ type (
 	// the store used to retrieve information about ingests from Thea.
 	IngestsController struct {
 		service IngestService
+		store   WorkflowStore
 	}
 )
 
-var controllerLogger = logger.Get("IngestsController")
-
-func New(serv IngestService) *IngestsController {
-	return &IngestsController{service: serv}
+func New(serv IngestService, store WorkflowStore) *IngestsController {
+	return &IngestsController{service: serv, store: store}
 }
 
 // ListIngests returns all the ingests - represented as DTOs - from the underlying store.
 func (controller *IngestsController) ListIngests(ec echo.Context, _ gen.ListIngestsRequestObject) (gen.ListIngestsResponseObject, error) {
 	items := controller.service.GetAllIngests()
-	dtos := make([]gen.Ingest, len(items))
-	for k, v := range items {
-		dtos[k] = NewDto(v)
-	}
 
-	return gen.ListIngests200JSONResponse(dtos), nil
+	return gen.ListIngests200JSONResponse(util.ApplyConversion(items, dto.FromIngest)), nil
 }
 
 // GetIngest uses the 'id' path param from the context and retrieves the ingest from the
@@ -52,14 +72,14 @@ func (controller *IngestsController) GetIngest(ec echo.Context, request gen.GetI
 		return nil, echo.ErrNotFound
 	}
 
-	return gen.GetIngest200JSONResponse(NewDto(item)), nil
+	return gen.GetIngest200JSONResponse(dto.FromIngest(item)), nil
 }
 
 // DeleteIngest uses the 'id' path param from the context and retrieves the ingest from the
 // underlying store. If found, the Ingest is cancelled.
 func (controller *IngestsController) DeleteIngest(ec echo.Context, request gen.DeleteIngestRequestObject) (gen.DeleteIngestResponseObject, error) {
 	if err := controller.service.RemoveIngest(request.Id); err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
 	return gen.DeleteIngest200Response{}, nil
@@ -74,16 +94,228 @@ func (controller *IngestsController) ResolveIngest(ec echo.Context, request gen.
 	}
 
 	if err := controller.service.ResolveTroubledIngest(
+		ec.Request().Context(),
 		request.Id,
 		troubleResolutionDtoMethodToModel(request.Body.Method),
 		request.Body.Context,
 	); err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
 	return gen.ResolveIngest200Response{}, nil
 }
 
+// BulkResolveIngests resolves the troubles of the ingests selected (either by ID, or using the filter
+// provided) using the same resolution method and context. The outcome of the resolution
+// is returned for each ingest, as a failure to resolve one ingest does not prevent
+// the resolution of the others.
+func (controller *IngestsController) BulkResolveIngests(ec echo.Context, request gen.BulkResolveIngestsRequestObject) (gen.BulkResolveIngestsResponseObject, error) {
+	if request.Body.Method == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "JSON body missing mandatory 'method' field")
+	}
+	if (request.Body.Ids == nil) == (request.Body.Filter == nil) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "exactly one of 'ids' or 'filter' must be provided")
+	}
+
+	ids := controller.selectTroubledIngests(request.Body.Filter)
+	if request.Body.Ids != nil {
+		ids = *request.Body.Ids
+	}
+
+	method := troubleResolutionDtoMethodToModel(request.Body.Method)
+	outcomes := make([]gen.IngestResolutionOutcome, len(ids))
+	for k, id := range ids {
+		outcomes[k] = gen.IngestResolutionOutcome{Id: id, Resolved: true}
+		if err := controller.service.ResolveTroubledIngest(ec.Request().Context(), id, method, request.Body.Context); err != nil {
+			msg := err.Error()
+			outcomes[k].Resolved = false
+			outcomes[k].Error = &msg
+		}
+	}
+
+	return gen.BulkResolveIngests200JSONResponse(outcomes), nil
+}
+
+// ListIngestGroups returns all the ingest groups, along with the IDs of the ingests in each group.
+func (controller *IngestsController) ListIngestGroups(ec echo.Context, _ gen.ListIngestGroupsRequestObject) (gen.ListIngestGroupsResponseObject, error) {
+	items := controller.service.GetAllIngests()
+	groups := controller.service.GetAllIngestGroups()
+
+	out := make([]gen.IngestGroup, len(groups))
+	for k, group := range groups {
+		groupItems := make([]*ingest.IngestItem, 0)
+		for _, item := range items {
+			if item.GroupID != nil && *item.GroupID == group.ID {
+				groupItems = append(groupItems, item)
+			}
+		}
+
+		out[k] = dto.FromIngestGroup(group, groupItems)
+	}
+
+	return gen.ListIngestGroups200JSONResponse(out), nil
+}
+
+// DeleteIngestGroup cancels all the ingests of the group with the ID provided.
+func (controller *IngestsController) DeleteIngestGroup(ec echo.Context, request gen.DeleteIngestGroupRequestObject) (gen.DeleteIngestGroupResponseObject, error) {
+	if err := controller.service.RemoveIngestGroup(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return gen.DeleteIngestGroup200Response{}, nil
+}
+
+// ResolveIngestGroup resolves the troubles of all the troubled ingests in the group
+// with the ID provided, using the same resolution method and context.
+func (controller *IngestsController) ResolveIngestGroup(ec echo.Context, request gen.ResolveIngestGroupRequestObject) (gen.ResolveIngestGroupResponseObject, error) {
+	if request.Body.Method == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "JSON body missing mandatory 'method' field")
+	}
+
+	if err := controller.service.ResolveTroubledGroup(
+		ec.Request().Context(),
+		request.Id,
+		troubleResolutionDtoMethodToModel(request.Body.Method),
+		request.Body.Context,
+	); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return gen.ResolveIngestGroup200Response{}, nil
+}
+
+// selectTroubledIngests returns the IDs of the troubled ingests which match the filter provided.
+func (controller *IngestsController) selectTroubledIngests(filter *gen.IngestTroubleFilter) []uuid.UUID {
+	if filter == nil {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0)
+	for _, item := range controller.service.GetAllIngests() {
+		if item.State != ingest.Troubled || item.Trouble == nil {
+			continue
+		}
+		if filter.TroubleType != nil && dto.FromTroubleType(item.Trouble.Type()) != *filter.TroubleType {
+			continue
+		}
+		if filter.PathPrefix != nil && !strings.HasPrefix(item.Path, *filter.PathPrefix) {
+			continue
+		}
+
+		ids = append(ids, item.ID)
+	}
+
+	return ids
+}
+
+// PauseIngest pauses the ingest with the ID provided, preventing it from being claimed by a worker.
+func (controller *IngestsController) PauseIngest(ec echo.Context, request gen.PauseIngestRequestObject) (gen.PauseIngestResponseObject, error) {
+	if err := controller.service.PauseIngest(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return gen.PauseIngest200Response{}, nil
+}
+
+// ResumeIngest resumes the paused ingest with the ID provided.
+func (controller *IngestsController) ResumeIngest(ec echo.Context, request gen.ResumeIngestRequestObject) (gen.ResumeIngestResponseObject, error) {
+	if err := controller.service.ResumeIngest(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return gen.ResumeIngest200Response{}, nil
+}
+
+// PrioritizeIngest moves the ingest with the ID provided to the front of the ingest queue.
+func (controller *IngestsController) PrioritizeIngest(ec echo.Context, request gen.PrioritizeIngestRequestObject) (gen.PrioritizeIngestResponseObject, error) {
+	if err := controller.service.PrioritizeIngest(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return gen.PrioritizeIngest200Response{}, nil
+}
+
+// ListQuarantinedIngests returns the ingests which were quarantined, as their source file failed the sanity checks.
+func (controller *IngestsController) ListQuarantinedIngests(ec echo.Context, _ gen.ListQuarantinedIngestsRequestObject) (gen.ListQuarantinedIngestsResponseObject, error) {
+	items := controller.service.GetQuarantinedIngests()
+
+	return gen.ListQuarantinedIngests200JSONResponse(util.ApplyConversion(items, dto.FromIngest)), nil
+}
+
+// ReleaseIngest releases the quarantined ingest with the ID provided, allowing it to be ingested.
+func (controller *IngestsController) ReleaseIngest(ec echo.Context, request gen.ReleaseIngestRequestObject) (gen.ReleaseIngestResponseObject, error) {
+	if err := controller.service.ReleaseIngest(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return gen.ReleaseIngest200Response{}, nil
+}
+
+// DeleteQuarantinedIngest deletes the source file of the quarantined ingest with the ID provided, and removes the ingest.
+func (controller *IngestsController) DeleteQuarantinedIngest(ec echo.Context, request gen.DeleteQuarantinedIngestRequestObject) (gen.DeleteQuarantinedIngestResponseObject, error) {
+	if err := controller.service.DeleteQuarantinedIngest(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return gen.DeleteQuarantinedIngest200Response{}, nil
+}
+
+// DryRunIngest scrapes and searches for the file at the path provided without ingesting it,
+// returning the media matched along with the workflows (and therefore targets) which
+// would be triggered once the media is ingested.
+func (controller *IngestsController) DryRunIngest(ec echo.Context, request gen.DryRunIngestRequestObject) (gen.DryRunIngestResponseObject, error) {
+	result, err := controller.service.DryRunIngest(ec.Request().Context(), request.Body.Path)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	eligible := make([]*workflow.Workflow, 0)
+	if result.Media != nil {
+		for _, wf := range controller.store.GetAllWorkflows() {
+			if wf.IsMediaEligible(result.Media) {
+				eligible = append(eligible, wf)
+			}
+		}
+	}
+
+	return gen.DryRunIngest200JSONResponse(dto.FromIngestDryRun(result, eligible)), nil
+}
+
+// NotifyDownloadComplete queues the files of a download reported as complete by a download
+// client, ahead of the files discovered by polling the ingest directory.
+func (controller *IngestsController) NotifyDownloadComplete(ec echo.Context, request gen.NotifyDownloadCompleteRequestObject) (gen.NotifyDownloadCompleteResponseObject, error) {
+	items, err := controller.service.NotifyDownloadComplete(ec.Request().Context(), request.Body.Path, request.Body.InfoHash)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "path does not exist")
+		}
+
+		return nil, err
+	}
+
+	return gen.NotifyDownloadComplete202JSONResponse(util.ApplyConversion(items, dto.FromIngest)), nil
+}
+
+// GetIngestMetrics returns the aggregated timing of each stage of ingestion.
+func (controller *IngestsController) GetIngestMetrics(ec echo.Context, _ gen.GetIngestMetricsRequestObject) (gen.GetIngestMetricsResponseObject, error) {
+	return gen.GetIngestMetrics200JSONResponse(util.ApplyConversion(controller.service.StageMetrics(), dto.FromIngestStageMetrics)), nil
+}
+
+// GetIngestParallelism returns the number of workers performing ingestions.
+func (controller *IngestsController) GetIngestParallelism(ec echo.Context, _ gen.GetIngestParallelismRequestObject) (gen.GetIngestParallelismResponseObject, error) {
+	return gen.GetIngestParallelism200JSONResponse(gen.IngestParallelism{Parallelism: controller.service.Parallelism()}), nil
+}
+
+// SetIngestParallelism grows or shrinks the pool of workers performing ingestions. Workers
+// removed from the pool finish their current ingestion before exiting.
+func (controller *IngestsController) SetIngestParallelism(ec echo.Context, request gen.SetIngestParallelismRequestObject) (gen.SetIngestParallelismResponseObject, error) {
+	if err := controller.service.SetParallelism(request.Body.Parallelism); err != nil {
+		return nil, err
+	}
+
+	return gen.SetIngestParallelism200JSONResponse(gen.IngestParallelism{Parallelism: controller.service.Parallelism()}), nil
+}
+
 func (controller *IngestsController) PollIngests(ec echo.Context, _ gen.PollIngestsRequestObject) (gen.PollIngestsResponseObject, error) {
 	controller.service.DiscoverNewFiles()
 
@@ -0,0 +1,87 @@
+package invites
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultInviteLifespan = time.Hour * 24 * 7
+
+type (
+	Store interface {
+		CreateInvite(inviteID uuid.UUID, createdBy uuid.UUID, expiresAt time.Time, permissions []string, roleIDs []uuid.UUID) (*user.Invite, string, error)
+		ListInvites() ([]*user.Invite, error)
+		DeleteInvite(inviteID uuid.UUID) error
+		GetRole(roleID uuid.UUID) (*user.Role, error)
+	}
+
+	InviteController struct{ store Store }
+)
+
+func New(store Store) *InviteController {
+	return &InviteController{store: store}
+}
+
+// CreateInvite creates a new invite bound to the permissions and roles
+// provided. To prevent privilege escalation, the invite must not grant
+// any permission (directly or via a role) which the caller does not hold.
+func (controller *InviteController) CreateInvite(ec echo.Context, request gen.CreateInviteRequestObject) (gen.CreateInviteResponseObject, error) {
+	caller, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	permissions := util.NotNilOrDefault(request.Body.Permissions, []string{})
+	roleIDs := util.NotNilOrDefault(request.Body.RoleIds, []uuid.UUID{})
+	granted := slices.Clone(permissions)
+	for _, roleID := range roleIDs {
+		role, err := controller.store.GetRole(roleID)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to create new invite: role %s cannot be found", roleID))
+		}
+
+		granted = append(granted, role.Permissions...)
+	}
+	for _, perm := range granted {
+		if !slices.Contains(caller.Permissions, perm) {
+			return nil, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("Cannot create invite which grants permission '%s' as you do not hold it", perm))
+		}
+	}
+
+	expiresAt := util.NotNilOrDefault(request.Body.ExpiresAt, time.Now().Add(defaultInviteLifespan))
+	invite, token, err := controller.store.CreateInvite(uuid.New(), caller.UserID, expiresAt, permissions, roleIDs)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create new invite: %w", err))
+	}
+
+	inviteDto := dto.FromInvite(invite)
+	inviteDto.Token = &token
+	return gen.CreateInvite201JSONResponse(inviteDto), nil
+}
+
+func (controller *InviteController) ListInvites(ec echo.Context, _ gen.ListInvitesRequestObject) (gen.ListInvitesResponseObject, error) {
+	invites, err := controller.store.ListInvites()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListInvites200JSONResponse(util.ApplyConversion(invites, dto.FromInvite)), nil
+}
+
+func (controller *InviteController) DeleteInvite(ec echo.Context, request gen.DeleteInviteRequestObject) (gen.DeleteInviteResponseObject, error) {
+	if err := controller.store.DeleteInvite(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.DeleteInvite204Response{}, nil
+}
@@ -0,0 +1,97 @@
+package libraries
+
+import (
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/library"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	Store interface {
+		GetLibrary(id uuid.UUID) (*library.Library, error)
+		GetAllLibraries() ([]*library.Library, error)
+		SaveLibrary(lib *library.Library) error
+		DeleteLibrary(id uuid.UUID) error
+	}
+
+	LibraryController struct{ store Store }
+)
+
+func New(store Store) *LibraryController {
+	return &LibraryController{store: store}
+}
+
+func (controller *LibraryController) ListLibraries(ec echo.Context, _ gen.ListLibrariesRequestObject) (gen.ListLibrariesResponseObject, error) {
+	libraries, err := controller.store.GetAllLibraries()
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.ListLibraries200JSONResponse(util.ApplyConversion(libraries, dto.FromLibrary)), nil
+}
+
+func (controller *LibraryController) CreateLibrary(ec echo.Context, request gen.CreateLibraryRequestObject) (gen.CreateLibraryResponseObject, error) {
+	lib := &library.Library{ID: uuid.New(), Label: request.Body.Label, IngestPath: request.Body.IngestPath}
+	if request.Body.OutputDirectory != nil {
+		lib.OutputDirectory = *request.Body.OutputDirectory
+	}
+
+	if err := controller.store.SaveLibrary(lib); err != nil {
+		return nil, err
+	}
+
+	created, err := controller.store.GetLibrary(lib.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.CreateLibrary201JSONResponse(dto.FromLibrary(created)), nil
+}
+
+func (controller *LibraryController) GetLibrary(ec echo.Context, request gen.GetLibraryRequestObject) (gen.GetLibraryResponseObject, error) {
+	lib, err := controller.store.GetLibrary(request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.GetLibrary200JSONResponse(dto.FromLibrary(lib)), nil
+}
+
+func (controller *LibraryController) UpdateLibrary(ec echo.Context, request gen.UpdateLibraryRequestObject) (gen.UpdateLibraryResponseObject, error) {
+	lib, err := controller.store.GetLibrary(request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Body.Label != nil {
+		lib.Label = *request.Body.Label
+	}
+	if request.Body.IngestPath != nil {
+		lib.IngestPath = *request.Body.IngestPath
+	}
+	if request.Body.OutputDirectory != nil {
+		lib.OutputDirectory = *request.Body.OutputDirectory
+	}
+
+	if err := controller.store.SaveLibrary(lib); err != nil {
+		return nil, err
+	}
+
+	updated, err := controller.store.GetLibrary(lib.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.UpdateLibrary200JSONResponse(dto.FromLibrary(updated)), nil
+}
+
+func (controller *LibraryController) DeleteLibrary(ec echo.Context, request gen.DeleteLibraryRequestObject) (gen.DeleteLibraryResponseObject, error) {
+	if err := controller.store.DeleteLibrary(request.Id); err != nil {
+		return nil, err
+	}
+
+	return gen.DeleteLibrary204Response{}, nil
+}
@@ -1,45 +1,88 @@
 package medias
 
 import (
+	"cmp"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/api/util"
 	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/library"
 	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/preferences"
 	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user/permissions"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	defaultHomeFeedLimit = 20
+	maximumHomeFeedLimit = 50
+)
+
 type (
 	Store interface {
 		GetMedia(mediaID uuid.UUID) *media.Container
 		GetMovie(movieID uuid.UUID) (*media.Movie, error)
 		GetEpisode(episodeID uuid.UUID) (*media.Episode, error)
 		GetInflatedSeries(seriesID uuid.UUID) (*media.InflatedSeries, error)
+		GetSeason(seasonID uuid.UUID) (*media.Season, error)
+		GetEpisodesForSeason(seasonID uuid.UUID) ([]*media.Episode, error)
+		GetMissingEpisodes(seriesID uuid.UUID) ([]*media.CatalogEpisode, error)
+		SetSeriesMonitored(seriesID uuid.UUID, monitored bool) error
+		GetRelatedMovies(movieID uuid.UUID, access *library.Access) ([]*media.RelatedItem, error)
+		GetRelatedSeries(seriesID uuid.UUID, access *library.Access) ([]*media.RelatedItem, error)
+		GetContainers(ids []uuid.UUID) ([]*media.Container, error)
 		GetTranscodesForMedia(mediaID uuid.UUID) ([]*transcode.Transcode, error)
+		GetTranscodesForMedias(mediaIDs []uuid.UUID) ([]*transcode.Transcode, error)
+		GetMediaVersions(mediaID uuid.UUID) ([]*media.Version, error)
+		GetMediaVersionsForMedias(mediaIDs []uuid.UUID) (map[uuid.UUID][]*media.Version, error)
+		UpdateMediaVersionLabel(versionID uuid.UUID, label string) (*media.Version, error)
+		UpdateMetadata(id uuid.UUID, update media.MetadataUpdate, allowedTypes ...media.MetadataItemType) (*media.MetadataItem, error)
+		SetLockedFields(id uuid.UUID, fields []media.MetadataField) (*media.MetadataItem, error)
 		GetAllTargets() []*ffmpeg.Target
 
-		ListMedia(includeTypes []media.MediaListType, titleFilter string, includeGenres []int, orderBy []media.MediaListOrderBy, offset int, limit int) ([]*media.MediaListResult, error)
+		ListMedia(opts media.ListMediaOptions, access *library.Access) (*media.MediaListPage, error)
 		ListGenres() ([]*media.Genre, error)
 
+		GetLibraryAccess(userID uuid.UUID, userPermissions []string) (*library.Access, error)
+		AuthorizeLibraryAccess(access *library.Access, id uuid.UUID) error
+
 		DeleteEpisode(episodeID uuid.UUID) error
 		DeleteSeries(seriesID uuid.UUID) error
 		DeleteSeason(seasonID uuid.UUID) error
 		DeleteMovie(movieID uuid.UUID) error
+
+		ListTrash() ([]*media.TrashedItem, error)
+		RestoreFromTrash(id uuid.UUID) error
+
+		SaveWatchProgress(userID uuid.UUID, mediaID uuid.UUID, positionSeconds int, completed bool) error
+		GetHomeFeed(userID uuid.UUID, access *library.Access, includeTranscodes bool, limit int) (*media.HomeFeed, error)
+
+		GetUserPreferences(userID uuid.UUID) (*preferences.Preferences, error)
 	}
 
 	TranscodeService interface {
 		ActiveTasksForMedia(mediaID uuid.UUID) []*transcode.TranscodeTask
 	}
 
+	// Scrobbler reports the watch progress of users to external services (e.g. Trakt).
+	Scrobbler interface {
+		Scrobble(userID uuid.UUID, mediaID uuid.UUID, positionSeconds int, completed bool)
+	}
+
 	MediaController struct {
 		store            Store
 		transcodeService TranscodeService
+		scrobbler        Scrobbler
 	}
 )
 
@@ -49,6 +92,15 @@ var (
 		"series": media.SeriesType,
 	}
 
+	metadataFieldMapping = map[gen.MediaMetadataField]media.MetadataField{
+		gen.MediaMetadataField("TITLE"):          media.TitleField,
+		gen.MediaMetadataField("OVERVIEW"):       media.OverviewField,
+		gen.MediaMetadataField("ADULT"):          media.AdultField,
+		gen.MediaMetadataField("GENRES"):         media.GenresField,
+		gen.MediaMetadataField("SEASON_NUMBER"):  media.SeasonNumberField,
+		gen.MediaMetadataField("EPISODE_NUMBER"): media.EpisodeNumberField,
+	}
+
 	mediaListOrderColumnMapping = map[string]media.MediaListOrderColumn{
 		"id":        media.IDColumn,
 		"updatedAt": media.UpdatedAtColumn,
@@ -57,28 +109,24 @@ var (
 	}
 )
 
-func New(transcodeService TranscodeService, store Store) *MediaController {
-	return &MediaController{store: store, transcodeService: transcodeService}
+func New(transcodeService TranscodeService, scrobbler Scrobbler, store Store) *MediaController {
+	return &MediaController{store: store, transcodeService: transcodeService, scrobbler: scrobbler}
 }
 
 // ListMedia is an endpoint used to retrieve a list of movies and series which have been
 // updated recently (this includes episodes being added to a series). The caller of this endpoint
 // can specify filtering options such as the type (movie|series), a limit to the number
-// of results, or the genres which apply to the content.
+// of results, the genres which apply to the content, the tags attached to the content, or a collection
+// the content must be a member of. Only the content within the libraries the user may access is listed.
 func (controller *MediaController) ListMedia(ec echo.Context, request gen.ListMediaRequestObject) (gen.ListMediaResponseObject, error) {
-	allowedTypesRaw := []string{}
-	if request.Params.AllowedType != nil {
-		allowedTypesRaw = *request.Params.AllowedType
+	allowedTypes, err := parseMediaListTypes(request.Params.AllowedType)
+	if err != nil {
+		return nil, err
 	}
 
-	allowedTypes := make([]media.MediaListType, len(allowedTypesRaw))
-	for k, v := range allowedTypesRaw {
-		if vv, ok := mediaListTypeMapping[v]; ok {
-			allowedTypes[k] = vv
-			continue
-		}
-
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("allowedType '%v' is not recognized", v))
+	access, err := libraryAccess(ec, controller.store)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
 	allowedGenresRaw := []string{}
@@ -95,31 +143,23 @@ func (controller *MediaController) ListMedia(ec echo.Context, request gen.ListMe
 		allowedGenres[k] = vv
 	}
 
-	orderByRaw := []string{}
-	if request.Params.OrderBy != nil {
-		orderByRaw = *request.Params.OrderBy
-	}
-
-	orderBy := make([]media.MediaListOrderBy, len(orderByRaw))
-	for k, v := range orderByRaw {
-		// If value begins with a '+/-', then this dictates the ordering
-		// and should be stripped from the mapping lookup. Default ordering
-		// is ascending (+).
-		isDecending := false
-		switch v[:1] {
-		case "+":
-			v = v[1:]
-		case "-":
-			v = v[1:]
-			isDecending = true
-		}
-
-		if vv, ok := mediaListOrderColumnMapping[v]; ok {
-			orderBy[k] = media.MediaListOrderBy{Column: vv, Descending: isDecending}
-			continue
+	var tagFilter *media.TagFilter
+	if request.Params.Tag != nil && len(*request.Params.Tag) > 0 {
+		tagFilter = &media.TagFilter{IDs: *request.Params.Tag, MatchAll: true}
+		if request.Params.TagMode != nil {
+			switch *request.Params.TagMode {
+			case "all":
+			case "any":
+				tagFilter.MatchAll = false
+			default:
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("tagMode '%v' is not recognized", *request.Params.TagMode))
+			}
 		}
+	}
 
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("orderBy column '%v' is not recognized", v))
+	orderBy, err := parseMediaListOrderBy(request.Params.OrderBy)
+	if err != nil {
+		return nil, err
 	}
 
 	limit := 0
@@ -128,7 +168,7 @@ func (controller *MediaController) ListMedia(ec echo.Context, request gen.ListMe
 		limit = *request.Params.Limit
 	}
 	if request.Params.Offset != nil && *request.Params.Offset > 0 {
-		limit = *request.Params.Offset
+		offset = *request.Params.Offset
 	}
 
 	titleFilter := ""
@@ -136,17 +176,39 @@ func (controller *MediaController) ListMedia(ec echo.Context, request gen.ListMe
 		titleFilter = *request.Params.TitleFilter
 	}
 
-	results, err := controller.store.ListMedia(allowedTypes, titleFilter, allowedGenres, orderBy, offset, limit)
+	cursor := ""
+	if request.Params.Cursor != nil {
+		cursor = *request.Params.Cursor
+	}
+
+	includeTotal := request.Params.IncludeTotal != nil && *request.Params.IncludeTotal
+	page, err := controller.store.ListMedia(media.ListMediaOptions{
+		Types:        allowedTypes,
+		Title:        titleFilter,
+		Genres:       allowedGenres,
+		CollectionID: request.Params.Collection,
+		Tags:         tagFilter,
+		OrderBy:      orderBy,
+		Offset:       offset,
+		Limit:        limit,
+		Cursor:       cursor,
+		IncludeTotal: includeTotal,
+	}, access)
 	if err != nil {
 		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	dtos, err := newListDtos(results)
+	dtos, err := dto.FromMediaListResults(page.Results)
 	if err != nil {
 		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	return gen.ListMedia200JSONResponse(dtos), nil
+	response := gen.MediaListPage{Items: dtos, HasMore: page.HasMore, TotalCount: page.TotalCount}
+	if page.HasMore {
+		response.NextCursor = &page.NextCursor
+	}
+
+	return gen.ListMedia200JSONResponse(response), nil
 }
 
 func (controller *MediaController) ListGenres(ec echo.Context, _ gen.ListGenresRequestObject) (gen.ListGenresResponseObject, error) {
@@ -155,64 +217,329 @@ func (controller *MediaController) ListGenres(ec echo.Context, _ gen.ListGenresR
 		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	return gen.ListGenres200JSONResponse(genreModelsToDtos(genres)), nil
+	return gen.ListGenres200JSONResponse(dto.FromGenres(genres)), nil
 }
 
 func (controller *MediaController) GetMovie(ec echo.Context, request gen.GetMovieRequestObject) (gen.GetMovieResponseObject, error) {
-	wrap := wrapErrorGenerator("failed to fetch movie")
-	movie, err := controller.store.GetMovie(request.Id)
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, wrapErrorGenerator("failed to fetch movie")(err)
+	}
+
+	movie, err := controller.getMovieDto(ec, request.Id)
 	if err != nil {
-		return nil, wrap(err)
+		return nil, wrapErrorGenerator("failed to fetch movie")(err)
 	}
 
-	watchTargets, err := controller.getMediaWatchTargets(request.Id)
+	access, err := libraryAccess(ec, controller.store)
 	if err != nil {
-		return nil, wrap(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
-	dto := gen.Movie{
-		Id:           movie.ID,
-		TmdbId:       movie.TmdbID,
-		Title:        movie.Title,
-		CreatedAt:    movie.CreatedAt,
-		UpdatedAt:    movie.UpdatedAt,
-		WatchTargets: watchTargets,
+	related, err := controller.store.GetRelatedMovies(request.Id, access)
+	if err != nil {
+		return nil, wrapErrorGenerator("failed to fetch related movies")(err)
 	}
 
-	return gen.GetMovie200JSONResponse(dto), nil
+	relatedDtos := dto.FromRelatedItems(related)
+	movie.Related = &relatedDtos
+	return gen.GetMovie200JSONResponse(movie), nil
 }
 
 func (controller *MediaController) GetEpisode(ec echo.Context, request gen.GetEpisodeRequestObject) (gen.GetEpisodeResponseObject, error) {
-	wrap := wrapErrorGenerator("failed to fetch episode")
-	episode, err := controller.store.GetEpisode(request.Id)
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, wrapErrorGenerator("failed to fetch episode")(err)
+	}
+
+	episode, err := controller.getEpisodeDto(ec, request.Id)
+	if err != nil {
+		return nil, wrapErrorGenerator("failed to fetch episode")(err)
+	}
+
+	return gen.GetEpisode200JSONResponse(episode), nil
+}
+
+func (controller *MediaController) GetSeries(ec echo.Context, request gen.GetSeriesRequestObject) (gen.GetSeriesResponseObject, error) {
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, wrapErrorGenerator("Failed to get series")(err)
+	}
+
+	series, err := controller.store.GetInflatedSeries(request.Id)
 	if err != nil {
-		return nil, wrap(err)
+		return nil, wrapErrorGenerator("Failed to get series")(err)
 	}
 
-	watchTargets, err := controller.getMediaWatchTargets(request.Id)
+	access, err := libraryAccess(ec, controller.store)
 	if err != nil {
-		return nil, wrap(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
-	dto := gen.Episode{
-		Id:           episode.ID,
-		TmdbId:       episode.TmdbID,
-		Title:        episode.Title,
-		CreatedAt:    episode.CreatedAt,
-		UpdatedAt:    episode.UpdatedAt,
-		WatchTargets: watchTargets,
+	related, err := controller.store.GetRelatedSeries(request.Id, access)
+	if err != nil {
+		return nil, wrapErrorGenerator("Failed to get related series")(err)
 	}
 
-	return gen.GetEpisode200JSONResponse(dto), nil
+	seriesDto := dto.FromInflatedSeries(series)
+	relatedDtos := dto.FromRelatedItems(related)
+	seriesDto.Related = &relatedDtos
+	return gen.GetSeries200JSONResponse(seriesDto), nil
 }
 
-func (controller *MediaController) GetSeries(ec echo.Context, request gen.GetSeriesRequestObject) (gen.GetSeriesResponseObject, error) {
+// GetSeriesMissingEpisodes returns the aired episodes of the series which are
+// known to TMDB, but are not present in the library.
+func (controller *MediaController) GetSeriesMissingEpisodes(ec echo.Context, request gen.GetSeriesMissingEpisodesRequestObject) (gen.GetSeriesMissingEpisodesResponseObject, error) {
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, wrapErrorGenerator("failed to get missing episodes")(err)
+	}
+
+	missing, err := controller.store.GetMissingEpisodes(request.Id)
+	if err != nil {
+		return nil, wrapErrorGenerator("failed to get missing episodes")(err)
+	}
+
+	return gen.GetSeriesMissingEpisodes200JSONResponse(util.ApplyConversion(missing, dto.FromMissingEpisode)), nil
+}
+
+// GetSeriesWatchTargets returns the watch targets of every episode of the series, allowing a series
+// page to render the readiness of each episode without a request per episode. The episodes and
+// their completed transcodes/versions are each fetched using a single query.
+func (controller *MediaController) GetSeriesWatchTargets(ec echo.Context, request gen.GetSeriesWatchTargetsRequestObject) (gen.GetSeriesWatchTargetsResponseObject, error) {
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, wrapErrorGenerator("failed to get watch targets")(err)
+	}
+
+	containers, err := controller.store.GetContainers([]uuid.UUID{request.Id})
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	} else if len(containers) == 0 || containers[0].Type != media.SeriesContainerType {
+		return nil, echo.ErrNotFound
+	}
+
+	watchTargets, err := controller.buildEpisodeWatchTargets(ec, containers[0].Seasons)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetSeriesWatchTargets200JSONResponse(watchTargets), nil
+}
+
+// GetSeasonWatchTargets returns the watch targets of every episode of the season.
+func (controller *MediaController) GetSeasonWatchTargets(ec echo.Context, request gen.GetSeasonWatchTargetsRequestObject) (gen.GetSeasonWatchTargetsResponseObject, error) {
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, wrapErrorGenerator("Failed to get season")(err)
+	}
+
+	season, err := controller.store.GetSeason(request.Id)
+	if err != nil {
+		return nil, wrapErrorGenerator("Failed to get season")(err)
+	}
+
+	episodes, err := controller.store.GetEpisodesForSeason(season.ID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	slices.SortFunc(episodes, func(a, b *media.Episode) int { return cmp.Compare(a.EpisodeNumber, b.EpisodeNumber) })
+
+	watchTargets, err := controller.buildEpisodeWatchTargets(ec, []*media.InflatedSeason{{Season: season, Episodes: episodes}})
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetSeasonWatchTargets200JSONResponse(watchTargets), nil
+}
+
+// GetMediaBatch returns the movies, episodes and series with the given IDs. The media and
+// their completed transcodes/versions are each fetched using a single query, rather than one per ID. Any
+// IDs belonging to a library the user may not access are omitted, as if they did not exist.
+func (controller *MediaController) GetMediaBatch(ec echo.Context, request gen.GetMediaBatchRequestObject) (gen.GetMediaBatchResponseObject, error) {
+	access, err := libraryAccess(ec, controller.store)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	containers, err := controller.store.GetContainers(request.Body.Ids)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	containers = slices.DeleteFunc(containers, func(container *media.Container) bool { return !access.Allows(container.LibraryID()) })
+
+	mediaIDs := make([]uuid.UUID, 0, len(containers))
+	for _, container := range containers {
+		if container.Type != media.SeriesContainerType {
+			mediaIDs = append(mediaIDs, container.ID())
+		}
+	}
+	transcodes, err := controller.store.GetTranscodesForMedias(mediaIDs)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	completedTranscodes := make(map[uuid.UUID][]*transcode.Transcode, len(mediaIDs))
+	for _, v := range transcodes {
+		completedTranscodes[v.MediaID] = append(completedTranscodes[v.MediaID], v)
+	}
+	versions, err := controller.store.GetMediaVersionsForMedias(mediaIDs)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	prefs, err := controller.playbackPreferences(ec)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	mask := dto.MaskFromContext(ec)
+	targets := controller.store.GetAllTargets()
+	items := make([]gen.MediaBatchItem, len(containers))
+	for k, container := range containers {
+		//exhaustive:enforce
+		switch container.Type {
+		case media.MovieContainerType:
+			id := container.ID()
+			movie := dto.FromMovie(mask, container.Movie, versions[id], controller.buildMediaWatchTargets(targets, id, versions[id], completedTranscodes[id], prefs))
+			items[k] = gen.MediaBatchItem{Type: gen.MediaBatchItemTypeMOVIE, Movie: &movie}
+		case media.EpisodeContainerType:
+			id := container.ID()
+			episode := dto.FromEpisode(mask, container.Episode, versions[id], controller.buildMediaWatchTargets(targets, id, versions[id], completedTranscodes[id], prefs))
+			items[k] = gen.MediaBatchItem{Type: gen.MediaBatchItemTypeEPISODE, Episode: &episode}
+		case media.SeriesContainerType:
+			series := dto.FromInflatedSeries(&media.InflatedSeries{Series: container.Series, Seasons: container.Seasons})
+			items[k] = gen.MediaBatchItem{Type: gen.MediaBatchItemTypeSERIES, Series: &series}
+		}
+	}
+
+	return gen.GetMediaBatch200JSONResponse(items), nil
+}
+
+// UpdateMediaVersion updates the label of a version of a movie or episode.
+func (controller *MediaController) UpdateMediaVersion(ec echo.Context, request gen.UpdateMediaVersionRequestObject) (gen.UpdateMediaVersionResponseObject, error) {
+	version, err := controller.store.UpdateMediaVersionLabel(request.Id, request.Body.Label)
+	if err != nil {
+		return nil, wrapErrorGenerator("failed to update version")(err)
+	}
+
+	return gen.UpdateMediaVersion200JSONResponse(dto.FromMediaVersion(dto.MaskFromContext(ec), version)), nil
+}
+
+// UpdateMediaMetadata manually corrects the metadata of a movie, episode, season or series,
+// locking the corrected fields so they're not overwritten when the media is re-ingested.
+func (controller *MediaController) UpdateMediaMetadata(ec echo.Context, request gen.UpdateMediaMetadataRequestObject) (gen.UpdateMediaMetadataResponseObject, error) {
+	item, err := controller.store.UpdateMetadata(request.Id, media.MetadataUpdate{
+		Title:    request.Body.Title,
+		Overview: request.Body.Overview,
+		Adult:    request.Body.Adult,
+		Genres:   genreLabels(request.Body.Genres),
+	})
+	if err != nil {
+		return nil, wrapMetadataError("failed to update metadata", err)
+	}
+
+	return gen.UpdateMediaMetadata200JSONResponse(dto.FromMetadataItem(item)), nil
+}
+
+// UpdateMovie manually corrects the metadata of a movie, locking the corrected fields.
+func (controller *MediaController) UpdateMovie(ec echo.Context, request gen.UpdateMovieRequestObject) (gen.UpdateMovieResponseObject, error) {
+	update := media.MetadataUpdate{
+		Title:    request.Body.Title,
+		Overview: request.Body.Overview,
+		Adult:    request.Body.Adult,
+		Genres:   genreLabels(request.Body.Genres),
+	}
+	if _, err := controller.store.UpdateMetadata(request.Id, update, media.MetadataMovie); err != nil {
+		return nil, wrapMetadataError("failed to update movie", err)
+	}
+
+	movie, err := controller.getMovieDto(ec, request.Id)
+	if err != nil {
+		return nil, wrapErrorGenerator("failed to fetch movie")(err)
+	}
+
+	return gen.UpdateMovie200JSONResponse(movie), nil
+}
+
+// UpdateEpisode manually corrects the metadata of an episode, locking the corrected fields.
+func (controller *MediaController) UpdateEpisode(ec echo.Context, request gen.UpdateEpisodeRequestObject) (gen.UpdateEpisodeResponseObject, error) {
+	update := media.MetadataUpdate{
+		Title:         request.Body.Title,
+		Overview:      request.Body.Overview,
+		Adult:         request.Body.Adult,
+		EpisodeNumber: request.Body.EpisodeNumber,
+	}
+	if _, err := controller.store.UpdateMetadata(request.Id, update, media.MetadataEpisode); err != nil {
+		return nil, wrapMetadataError("failed to update episode", err)
+	}
+
+	episode, err := controller.getEpisodeDto(ec, request.Id)
+	if err != nil {
+		return nil, wrapErrorGenerator("failed to fetch episode")(err)
+	}
+
+	return gen.UpdateEpisode200JSONResponse(episode), nil
+}
+
+// UpdateSeason manually corrects the metadata of a season, locking the corrected fields.
+func (controller *MediaController) UpdateSeason(ec echo.Context, request gen.UpdateSeasonRequestObject) (gen.UpdateSeasonResponseObject, error) {
+	update := media.MetadataUpdate{
+		Title:        request.Body.Title,
+		Overview:     request.Body.Overview,
+		SeasonNumber: request.Body.SeasonNumber,
+	}
+	item, err := controller.store.UpdateMetadata(request.Id, update, media.MetadataSeason)
+	if err != nil {
+		return nil, wrapMetadataError("failed to update season", err)
+	}
+
+	return gen.UpdateSeason200JSONResponse(dto.FromMetadataItem(item)), nil
+}
+
+// UpdateSeries manually corrects the metadata of a series, locking the corrected fields.
+func (controller *MediaController) UpdateSeries(ec echo.Context, request gen.UpdateSeriesRequestObject) (gen.UpdateSeriesResponseObject, error) {
+	update := media.MetadataUpdate{
+		Title:    request.Body.Title,
+		Overview: request.Body.Overview,
+		Genres:   genreLabels(request.Body.Genres),
+	}
+	if _, err := controller.store.UpdateMetadata(request.Id, update, media.MetadataSeries); err != nil {
+		return nil, wrapMetadataError("failed to update series", err)
+	}
+
 	series, err := controller.store.GetInflatedSeries(request.Id)
 	if err != nil {
-		return nil, wrapErrorGenerator("Failed to get series")(err)
+		return nil, wrapErrorGenerator("failed to get series")(err)
+	}
+
+	return gen.UpdateSeries200JSONResponse(dto.FromInflatedSeries(series)), nil
+}
+
+// SetSeriesMonitored marks the series as monitored (or unmonitored), controlling how the
+// ingest service treats files which match the episodes of the series.
+func (controller *MediaController) SetSeriesMonitored(ec echo.Context, request gen.SetSeriesMonitoredRequestObject) (gen.SetSeriesMonitoredResponseObject, error) {
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, wrapErrorGenerator("failed to set monitored state of series")(err)
+	}
+
+	if err := controller.store.SetSeriesMonitored(request.Id, request.Body.Monitored); err != nil {
+		return nil, wrapErrorGenerator("failed to set monitored state of series")(err)
+	}
+
+	return gen.SetSeriesMonitored204Response{}, nil
+}
+
+// SetMediaMetadataLocks replaces the locked metadata fields of a movie, episode, season or series.
+func (controller *MediaController) SetMediaMetadataLocks(ec echo.Context, request gen.SetMediaMetadataLocksRequestObject) (gen.SetMediaMetadataLocksResponseObject, error) {
+	fields := make([]media.MetadataField, 0, len(request.Body.LockedFields))
+	for _, field := range request.Body.LockedFields {
+		f, ok := metadataFieldMapping[field]
+		if !ok {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("metadata field '%v' is not recognized", field))
+		}
+		fields = append(fields, f)
 	}
 
-	return gen.GetSeries200JSONResponse(inflatedSeriesToDto(series)), nil
+	item, err := controller.store.SetLockedFields(request.Id, fields)
+	if err != nil {
+		return nil, wrapErrorGenerator("failed to set locked fields")(err)
+	}
+
+	return gen.SetMediaMetadataLocks200JSONResponse(dto.FromMetadataItem(item)), nil
 }
 
 func (controller *MediaController) DeleteMovie(ec echo.Context, request gen.DeleteMovieRequestObject) (gen.DeleteMovieResponseObject, error) {
@@ -247,8 +574,203 @@ func (controller *MediaController) DeleteEpisode(ec echo.Context, request gen.De
 	return gen.DeleteEpisode201Response{}, nil
 }
 
-func (controller *MediaController) getMediaWatchTargets(mediaID uuid.UUID) ([]gen.MediaWatchTarget, error) {
+func (controller *MediaController) ListTrash(ec echo.Context, _ gen.ListTrashRequestObject) (gen.ListTrashResponseObject, error) {
+	trash, err := controller.store.ListTrash()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListTrash200JSONResponse(util.ApplyConversion(trash, dto.FromTrashedItem)), nil
+}
+
+func (controller *MediaController) RestoreFromTrash(ec echo.Context, request gen.RestoreFromTrashRequestObject) (gen.RestoreFromTrashResponseObject, error) {
+	if err := controller.store.RestoreFromTrash(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.RestoreFromTrash200Response{}, nil
+}
+
+// GetHomeFeed returns the personalised home feed of the caller. The recently completed transcodes
+// are only included if the caller may stream transcoded media.
+func (controller *MediaController) GetHomeFeed(ec echo.Context, request gen.GetHomeFeedRequestObject) (gen.GetHomeFeedResponseObject, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	access, err := controller.store.GetLibraryAccess(user.UserID, user.Permissions)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	limit := defaultHomeFeedLimit
+	if request.Params.Limit != nil {
+		limit = min(max(*request.Params.Limit, 1), maximumHomeFeedLimit)
+	}
+
+	includeTranscodes := slices.Contains(user.Permissions, permissions.StreamTranscodedMediaPermission)
+	feed, err := controller.store.GetHomeFeed(user.UserID, access, includeTranscodes, limit)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetHomeFeed200JSONResponse(dto.FromHomeFeed(feed)), nil
+}
+
+// UpdateWatchProgress records the playback position of the caller in the movie/episode provided,
+// and scrobbles it to the external services the caller has linked.
+func (controller *MediaController) UpdateWatchProgress(ec echo.Context, request gen.UpdateWatchProgressRequestObject) (gen.UpdateWatchProgressResponseObject, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	if controller.store.GetMedia(request.Id) == nil {
+		return nil, echo.ErrNotFound
+	}
+	if err := controller.authorize(ec, request.Id); err != nil {
+		return nil, err
+	}
+
+	completed := util.NotNilOrDefault(request.Body.Completed, false)
+	if err := controller.store.SaveWatchProgress(user.UserID, request.Id, request.Body.PositionSeconds, completed); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	controller.scrobbler.Scrobble(user.UserID, request.Id, request.Body.PositionSeconds, completed)
+
+	return gen.UpdateWatchProgress204Response{}, nil
+}
+
+// authorize returns library.ErrAccessDenied if the movie, episode, series or season with the
+// ID provided belongs to a library which the user of the request may not access.
+func (controller *MediaController) authorize(ec echo.Context, id uuid.UUID) error {
+	access, err := libraryAccess(ec, controller.store)
+	if err != nil {
+		return err
+	}
+
+	return controller.store.AuthorizeLibraryAccess(access, id)
+}
+
+func (controller *MediaController) getMovieDto(ec echo.Context, movieID uuid.UUID) (gen.Movie, error) {
+	movie, err := controller.store.GetMovie(movieID)
+	if err != nil {
+		return gen.Movie{}, err
+	}
+
+	versions, err := controller.store.GetMediaVersions(movieID)
+	if err != nil {
+		return gen.Movie{}, err
+	}
+
+	watchTargets, err := controller.getMediaWatchTargets(ec, movieID, versions)
+	if err != nil {
+		return gen.Movie{}, err
+	}
+
+	return dto.FromMovie(dto.MaskFromContext(ec), movie, versions, watchTargets), nil
+}
+
+func (controller *MediaController) getEpisodeDto(ec echo.Context, episodeID uuid.UUID) (gen.Episode, error) {
+	episode, err := controller.store.GetEpisode(episodeID)
+	if err != nil {
+		return gen.Episode{}, err
+	}
+
+	versions, err := controller.store.GetMediaVersions(episodeID)
+	if err != nil {
+		return gen.Episode{}, err
+	}
+
+	watchTargets, err := controller.getMediaWatchTargets(ec, episodeID, versions)
+	if err != nil {
+		return gen.Episode{}, err
+	}
+
+	return dto.FromEpisode(dto.MaskFromContext(ec), episode, versions, watchTargets), nil
+}
+
+func (controller *MediaController) getMediaWatchTargets(ec echo.Context, mediaID uuid.UUID, versions []*media.Version) ([]gen.MediaWatchTarget, error) {
+	completedTranscodes, err := controller.store.GetTranscodesForMedia(mediaID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := controller.playbackPreferences(ec)
+	if err != nil {
+		return nil, err
+	}
+
+	return controller.buildMediaWatchTargets(controller.store.GetAllTargets(), mediaID, versions, completedTranscodes, prefs), nil
+}
+
+// buildEpisodeWatchTargets constructs the watch targets of all the episodes of the seasons provided,
+// fetching the completed transcodes and versions of the episodes using a single query each.
+func (controller *MediaController) buildEpisodeWatchTargets(ec echo.Context, seasons []*media.InflatedSeason) ([]gen.EpisodeWatchTargets, error) {
+	episodeIDs := make([]uuid.UUID, 0)
+	for _, season := range seasons {
+		for _, episode := range season.Episodes {
+			episodeIDs = append(episodeIDs, episode.ID)
+		}
+	}
+
+	transcodes, err := controller.store.GetTranscodesForMedias(episodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	completedTranscodes := make(map[uuid.UUID][]*transcode.Transcode, len(episodeIDs))
+	for _, v := range transcodes {
+		completedTranscodes[v.MediaID] = append(completedTranscodes[v.MediaID], v)
+	}
+	versions, err := controller.store.GetMediaVersionsForMedias(episodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := controller.playbackPreferences(ec)
+	if err != nil {
+		return nil, err
+	}
+
 	targets := controller.store.GetAllTargets()
+	output := make([]gen.EpisodeWatchTargets, 0, len(episodeIDs))
+	for _, season := range seasons {
+		for _, episode := range season.Episodes {
+			output = append(output, gen.EpisodeWatchTargets{
+				EpisodeId:     episode.ID,
+				EpisodeNumber: episode.EpisodeNumber,
+				SeasonId:      season.ID,
+				SeasonNumber:  season.SeasonNumber,
+				WatchTargets:  controller.buildMediaWatchTargets(targets, episode.ID, versions[episode.ID], completedTranscodes[episode.ID], prefs),
+			})
+		}
+	}
+
+	return output, nil
+}
+
+func (controller *MediaController) buildMediaWatchTargets(targets []*ffmpeg.Target, mediaID uuid.UUID, versions []*media.Version, completedTranscodes []*transcode.Transcode, prefs *preferences.Preferences) []gen.MediaWatchTarget {
+	return BuildWatchTargets(targets, versions, completedTranscodes, controller.transcodeService.ActiveTasksForMedia(mediaID), prefs)
+}
+
+// playbackPreferences returns the playback preferences of the user of the request.
+func (controller *MediaController) playbackPreferences(ec echo.Context) (*preferences.Preferences, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	return controller.store.GetUserPreferences(user.UserID)
+}
+
+// BuildWatchTargets constructs the watch targets for a media, using the targets, versions,
+// completed transcodes and active transcode tasks provided. Live transcoding is only offered
+// for the primary source of the media, however each version may be streamed directly.
+//
+// If playback preferences are provided, the targets which exceed the maximum bitrate of the user are
+// disabled, and the ready pre-transcodes using the preferred audio language of the user are listed first.
+// The direct watch targets are never disabled, as the bitrate of a source is only known once it's probed
+// when a playback session is started (which then decides whether the source may be played directly).
+func BuildWatchTargets(targets []*ffmpeg.Target, versions []*media.Version, completedTranscodes []*transcode.Transcode, activeTranscodes []*transcode.TranscodeTask, prefs *preferences.Preferences) []gen.MediaWatchTarget {
 	findTarget := func(tid uuid.UUID) *ffmpeg.Target {
 		for _, v := range targets {
 			if v.ID == tid {
@@ -258,25 +780,42 @@ func (controller *MediaController) getMediaWatchTargets(mediaID uuid.UUID) ([]ge
 
 		panic("Media references a target which does not exist. This should simply be unreachable unless the DB has lost referential integrity")
 	}
+	findVersion := func(vid *uuid.UUID) *media.Version {
+		if vid == nil {
+			return nil
+		}
 
-	activeTranscodes := controller.transcodeService.ActiveTasksForMedia(mediaID)
-	completedTranscodes, err := controller.store.GetTranscodesForMedia(mediaID)
-	if err != nil {
-		return nil, err
+		for _, v := range versions {
+			if v.ID == *vid {
+				return v
+			}
+		}
+
+		panic("Transcode references a version which does not exist. This should simply be unreachable unless the DB has lost referential integrity")
 	}
 
 	// 1. Add completed transcodes as valid pre-transcoded targets
 	targetsNotEligibleForLiveTranscode := make(map[uuid.UUID]struct{}, len(activeTranscodes))
 	watchTargets := make([]gen.MediaWatchTarget, 0, len(completedTranscodes))
 	for _, v := range completedTranscodes {
-		targetsNotEligibleForLiveTranscode[v.TargetID] = struct{}{}
-		watchTargets = append(watchTargets, newWatchTarget(findTarget(v.TargetID), gen.PRETRANSCODE, true))
+		if v.VersionID == nil {
+			targetsNotEligibleForLiveTranscode[v.TargetID] = struct{}{}
+		}
+		target := findTarget(v.TargetID)
+		watchTarget := dto.NewVersionWatchTarget(target, findVersion(v.VersionID), gen.PRETRANSCODE, true)
+		watchTarget.AudioLanguage = v.AudioLanguage
+		watchTarget.Enabled = allowsTarget(prefs, target)
+		watchTargets = append(watchTargets, watchTarget)
 	}
 
 	// 2. Add in-progress transcodes (as not ready to watch)
 	for _, v := range activeTranscodes {
-		targetsNotEligibleForLiveTranscode[v.Target().ID] = struct{}{}
-		watchTargets = append(watchTargets, newWatchTarget(v.Target(), gen.PRETRANSCODE, false))
+		if v.Version() == nil {
+			targetsNotEligibleForLiveTranscode[v.Target().ID] = struct{}{}
+		}
+		watchTarget := dto.NewVersionWatchTarget(v.Target(), v.Version(), gen.PRETRANSCODE, false)
+		watchTarget.Enabled = allowsTarget(prefs, v.Target())
+		watchTargets = append(watchTargets, watchTarget)
 	}
 
 	// 3. Any targets which do NOT have a complete or in-progress pre-transcode are eligible for live transcoding/streaming
@@ -286,14 +825,109 @@ func (controller *MediaController) getMediaWatchTargets(mediaID uuid.UUID) ([]ge
 			continue
 		}
 
-		watchTargets = append(watchTargets, newWatchTarget(v, gen.LIVETRANSCODE, true))
+		watchTarget := dto.NewWatchTarget(v, gen.LIVETRANSCODE, true)
+		watchTarget.Enabled = allowsTarget(prefs, v)
+		watchTargets = append(watchTargets, watchTarget)
 	}
 
 	// 4. We can directly stream the source media itself, so add that too
 	// TODO: at some point we may want this to be configurable
 	watchTargets = append(watchTargets, gen.MediaWatchTarget{DisplayName: "Direct", Ready: true, Type: gen.LIVETRANSCODE, TargetId: nil, Enabled: true})
+	for _, v := range versions {
+		watchTargets = append(watchTargets, gen.MediaWatchTarget{DisplayName: fmt.Sprintf("Direct (%s)", v.Label), Ready: true, Type: gen.LIVETRANSCODE, TargetId: nil, VersionId: &v.ID, Enabled: true})
+	}
+
+	// 5. Prefer the pre-transcodes which use the preferred audio language of the user
+	if prefs != nil && prefs.AudioLanguage != nil {
+		preferred := func(v gen.MediaWatchTarget) bool {
+			return v.Type == gen.PRETRANSCODE && v.Ready && v.Enabled && v.AudioLanguage != nil && *v.AudioLanguage == *prefs.AudioLanguage
+		}
+		slices.SortStableFunc(watchTargets, func(a, b gen.MediaWatchTarget) int {
+			switch {
+			case preferred(a) && !preferred(b):
+				return -1
+			case preferred(b) && !preferred(a):
+				return 1
+			default:
+				return 0
+			}
+		})
+	}
+
+	return watchTargets
+}
+
+// allowsTarget returns false if the output of the target exceeds the maximum bitrate preference
+// of the user. Targets whose bitrate cannot be determined are always allowed.
+func allowsTarget(prefs *preferences.Preferences, target *ffmpeg.Target) bool {
+	if prefs == nil {
+		return true
+	}
+
+	kbps, ok := target.BitrateKbps()
+	return !ok || prefs.AllowsBitrate(kbps)
+}
+
+// libraryAccess returns the libraries which the user of the request may access.
+func libraryAccess(ec echo.Context, store Store) (*library.Access, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	return store.GetLibraryAccess(user.UserID, user.Permissions)
+}
 
-	return watchTargets, nil
+// parseMediaListTypes converts the raw 'allowedType' query parameter of the media
+// list endpoints to the media list types, rejecting any unrecognized types.
+func parseMediaListTypes(raw *[]string) ([]media.MediaListType, error) {
+	if raw == nil {
+		return []media.MediaListType{}, nil
+	}
+
+	allowedTypes := make([]media.MediaListType, len(*raw))
+	for k, v := range *raw {
+		if vv, ok := mediaListTypeMapping[v]; ok {
+			allowedTypes[k] = vv
+			continue
+		}
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("allowedType '%v' is not recognized", v))
+	}
+
+	return allowedTypes, nil
+}
+
+// parseMediaListOrderBy converts the raw 'orderBy' query parameter of the media list
+// endpoints to the ordering of the results, rejecting any unrecognized columns.
+func parseMediaListOrderBy(raw *[]string) ([]media.MediaListOrderBy, error) {
+	if raw == nil {
+		return []media.MediaListOrderBy{}, nil
+	}
+
+	orderBy := make([]media.MediaListOrderBy, len(*raw))
+	for k, v := range *raw {
+		// If value begins with a '+/-', then this dictates the ordering
+		// and should be stripped from the mapping lookup. Default ordering
+		// is ascending (+).
+		isDecending := false
+		switch v[:1] {
+		case "+":
+			v = v[1:]
+		case "-":
+			v = v[1:]
+			isDecending = true
+		}
+
+		if vv, ok := mediaListOrderColumnMapping[v]; ok {
+			orderBy[k] = media.MediaListOrderBy{Column: vv, Descending: isDecending}
+			continue
+		}
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("orderBy column '%v' is not recognized", v))
+	}
+
+	return orderBy, nil
 }
 
 func wrapErrorGenerator(message string) func(err error) error {
@@ -301,6 +935,23 @@ func wrapErrorGenerator(message string) func(err error) error {
 		if errors.Is(err, sql.ErrNoRows) {
 			return echo.ErrNotFound
 		}
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s: %v", message, err))
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("%s: %w", message, err))
+	}
+}
+
+// wrapMetadataError converts an error from a metadata update to an HTTP error. Updates which
+// conflict with the season/episode number of a sibling are mapped to a 409 status by the
+// error mapping middleware of the API.
+func wrapMetadataError(message string, err error) error {
+	return wrapErrorGenerator(message)(err)
+}
+
+// genreLabels returns the genre labels provided, or nil if the genres are not
+// being corrected. An empty (non-nil) slice clears the genres.
+func genreLabels(genres *[]string) []string {
+	if genres == nil {
+		return nil
 	}
+
+	return append([]string{}, *genres...)
 }
@@ -0,0 +1,70 @@
+package medias
+
+import (
+	"net/http"
+
+	"github.com/hbomb79/Thea/internal/api/dto"
+	genv2 "github.com/hbomb79/Thea/internal/api/gen/v2"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/labstack/echo/v4"
+)
+
+// MediaControllerV2 implements the media endpoints of version 2 of the API. The store,
+// and the parsing of the request parameters, are shared with the version 1 MediaController.
+type MediaControllerV2 struct{ store Store }
+
+func NewV2(store Store) *MediaControllerV2 {
+	return &MediaControllerV2{store: store}
+}
+
+// ListMedia is the version 2 counterpart of MediaController.ListMedia. Paging of the results is
+// only possible using the cursor of the previous page, and the end of the results is indicated
+// by the absence of a next cursor (rather than a 'has_more' flag).
+func (controller *MediaControllerV2) ListMedia(ec echo.Context, request genv2.ListMediaRequestObject) (genv2.ListMediaResponseObject, error) {
+	allowedTypes, err := parseMediaListTypes(request.Params.AllowedType)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := libraryAccess(ec, controller.store)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	orderBy, err := parseMediaListOrderBy(request.Params.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := 0
+	if request.Params.Limit != nil && *request.Params.Limit > 0 {
+		limit = *request.Params.Limit
+	}
+
+	titleFilter := ""
+	if request.Params.TitleFilter != nil {
+		titleFilter = *request.Params.TitleFilter
+	}
+
+	cursor := ""
+	if request.Params.Cursor != nil {
+		cursor = *request.Params.Cursor
+	}
+
+	page, err := controller.store.ListMedia(media.ListMediaOptions{Types: allowedTypes, Title: titleFilter, OrderBy: orderBy, Limit: limit, Cursor: cursor}, access)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	dtos, err := dto.FromMediaListResultsV2(page.Results)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	response := genv2.MediaPage{Items: dtos}
+	if page.HasMore {
+		response.NextCursor = &page.NextCursor
+	}
+
+	return genv2.ListMedia200JSONResponse(response), nil
+}
@@ -0,0 +1,130 @@
+package remotes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/remote"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	RemoteSourceService interface {
+		CreateSource(source *remote.Source, password string) error
+		UpdateSource(id uuid.UUID, update remote.SourceUpdate) (*remote.Source, error)
+		GetSource(id uuid.UUID) (*remote.Source, error)
+		ListSources() ([]*remote.Source, error)
+		DeleteSource(id uuid.UUID) error
+		RequestSync(id uuid.UUID) error
+	}
+
+	RemoteSourceController struct{ service RemoteSourceService }
+)
+
+func New(service RemoteSourceService) *RemoteSourceController {
+	return &RemoteSourceController{service: service}
+}
+
+func (controller *RemoteSourceController) ListRemoteSources(ec echo.Context, _ gen.ListRemoteSourcesRequestObject) (gen.ListRemoteSourcesResponseObject, error) {
+	sources, err := controller.service.ListSources()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListRemoteSources200JSONResponse(util.ApplyConversion(sources, dto.FromRemoteSource)), nil
+}
+
+func (controller *RemoteSourceController) CreateRemoteSource(ec echo.Context, request gen.CreateRemoteSourceRequestObject) (gen.CreateRemoteSourceResponseObject, error) {
+	source := &remote.Source{
+		Label:               request.Body.Label,
+		Protocol:            protocolDtoToModel(request.Body.Protocol),
+		URL:                 request.Body.Url,
+		SyncIntervalSeconds: request.Body.SyncIntervalSeconds,
+		Enabled:             true,
+	}
+	if request.Body.Username != nil {
+		source.Username = *request.Body.Username
+	}
+	if request.Body.HostKeyFingerprint != nil {
+		source.HostKeyFingerprint = *request.Body.HostKeyFingerprint
+	}
+	if request.Body.Enabled != nil {
+		source.Enabled = *request.Body.Enabled
+	}
+
+	password := ""
+	if request.Body.Password != nil {
+		password = *request.Body.Password
+	}
+
+	if err := controller.service.CreateSource(source, password); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create remote source: %w", err))
+	}
+
+	created, err := controller.service.GetSource(source.ID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.CreateRemoteSource201JSONResponse(dto.FromRemoteSource(created)), nil
+}
+
+func (controller *RemoteSourceController) GetRemoteSource(ec echo.Context, request gen.GetRemoteSourceRequestObject) (gen.GetRemoteSourceResponseObject, error) {
+	source, err := controller.service.GetSource(request.Id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetRemoteSource200JSONResponse(dto.FromRemoteSource(source)), nil
+}
+
+func (controller *RemoteSourceController) UpdateRemoteSource(ec echo.Context, request gen.UpdateRemoteSourceRequestObject) (gen.UpdateRemoteSourceResponseObject, error) {
+	source, err := controller.service.UpdateSource(request.Id, remote.SourceUpdate{
+		Label:               request.Body.Label,
+		URL:                 request.Body.Url,
+		Username:            request.Body.Username,
+		Password:            request.Body.Password,
+		HostKeyFingerprint:  request.Body.HostKeyFingerprint,
+		SyncIntervalSeconds: request.Body.SyncIntervalSeconds,
+		Enabled:             request.Body.Enabled,
+	})
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to update remote source: %w", err))
+	}
+
+	return gen.UpdateRemoteSource200JSONResponse(dto.FromRemoteSource(source)), nil
+}
+
+func (controller *RemoteSourceController) DeleteRemoteSource(ec echo.Context, request gen.DeleteRemoteSourceRequestObject) (gen.DeleteRemoteSourceResponseObject, error) {
+	if err := controller.service.DeleteSource(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.DeleteRemoteSource204Response{}, nil
+}
+
+func (controller *RemoteSourceController) SyncRemoteSource(ec echo.Context, request gen.SyncRemoteSourceRequestObject) (gen.SyncRemoteSourceResponseObject, error) {
+	if err := controller.service.RequestSync(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.SyncRemoteSource202Response{}, nil
+}
+
+func protocolDtoToModel(protocol gen.RemoteSourceProtocol) remote.Protocol {
+	switch protocol {
+	case gen.RemoteSourceProtocol("SFTP"):
+		return remote.SFTP
+	case gen.RemoteSourceProtocol("SMB"):
+		return remote.SMB
+	case gen.RemoteSourceProtocol("WEBDAV"):
+		return remote.WebDAV
+	}
+
+	// Unknown protocols are rejected when the source is validated
+	return remote.Protocol(protocol)
+}
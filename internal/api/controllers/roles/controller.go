@@ -0,0 +1,73 @@
+package roles
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	Store interface {
+		CreateRole(roleID uuid.UUID, label string, permissions []string) (*user.Role, error)
+		UpdateRole(roleID uuid.UUID, newLabel *string, newPermissions *[]string) (*user.Role, error)
+		GetRole(roleID uuid.UUID) (*user.Role, error)
+		ListRoles() ([]*user.Role, error)
+		DeleteRole(roleID uuid.UUID) error
+	}
+
+	RoleController struct{ store Store }
+)
+
+func New(store Store) *RoleController {
+	return &RoleController{store: store}
+}
+
+func (controller *RoleController) CreateRole(ec echo.Context, request gen.CreateRoleRequestObject) (gen.CreateRoleResponseObject, error) {
+	role, err := controller.store.CreateRole(uuid.New(), request.Body.Label, request.Body.Permissions)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create new role: %w", err))
+	}
+
+	return gen.CreateRole201JSONResponse(dto.FromRole(role)), nil
+}
+
+func (controller *RoleController) ListRoles(ec echo.Context, _ gen.ListRolesRequestObject) (gen.ListRolesResponseObject, error) {
+	roles, err := controller.store.ListRoles()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListRoles200JSONResponse(util.ApplyConversion(roles, dto.FromRole)), nil
+}
+
+func (controller *RoleController) GetRole(ec echo.Context, request gen.GetRoleRequestObject) (gen.GetRoleResponseObject, error) {
+	role, err := controller.store.GetRole(request.Id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetRole200JSONResponse(dto.FromRole(role)), nil
+}
+
+func (controller *RoleController) UpdateRole(ec echo.Context, request gen.UpdateRoleRequestObject) (gen.UpdateRoleResponseObject, error) {
+	role, err := controller.store.UpdateRole(request.Id, request.Body.Label, request.Body.Permissions)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to update role: %w", err))
+	}
+
+	return gen.UpdateRole200JSONResponse(dto.FromRole(role)), nil
+}
+
+func (controller *RoleController) DeleteRole(ec echo.Context, request gen.DeleteRoleRequestObject) (gen.DeleteRoleResponseObject, error) {
+	if err := controller.store.DeleteRole(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.DeleteRole204Response{}, nil
+}
@@ -0,0 +1,159 @@
+package shares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/library"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultShareLinkLifespan = time.Hour * 24
+	maximumShareLinkLifespan = time.Hour * 24 * 30
+)
+
+type (
+	Store interface {
+		CreateShareLink(link *user.ShareLink, password []byte) error
+		ListShareLinks(createdBy uuid.UUID) ([]*user.ShareLink, error)
+		DeleteShareLink(linkID uuid.UUID, createdBy uuid.UUID) error
+		GetMedia(mediaID uuid.UUID) *media.Container
+		GetForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) (*transcode.Transcode, error)
+		GetLibraryAccess(userID uuid.UUID, userPermissions []string) (*library.Access, error)
+		AuthorizeLibraryAccess(access *library.Access, id uuid.UUID) error
+	}
+
+	ShareTokenIssuer interface {
+		GenerateShareToken(shareID uuid.UUID, expiresAt time.Time) (string, error)
+	}
+
+	ShareController struct {
+		store     Store
+		tokens    ShareTokenIssuer
+		routePath string
+	}
+)
+
+// New creates the controller used to manage share links. The route path is
+// the path of the public handler which streams shared media (see the api
+// package), which the token of a share link is appended to.
+func New(store Store, tokens ShareTokenIssuer, routePath string) *ShareController {
+	return &ShareController{store: store, tokens: tokens, routePath: routePath}
+}
+
+// CreateShareLink creates a link which grants access to stream the movie or episode provided
+// without an account. To prevent privilege escalation, the caller must be able to access
+// the media, and hold the permission to stream what the link shares (the source of the
+// media, or a completed transcode).
+func (controller *ShareController) CreateShareLink(ec echo.Context, request gen.CreateShareLinkRequestObject) (gen.CreateShareLinkResponseObject, error) {
+	caller, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	if controller.store.GetMedia(request.Id) == nil {
+		return nil, library.ErrAccessDenied
+	}
+	access, err := controller.store.GetLibraryAccess(caller.UserID, caller.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := controller.store.AuthorizeLibraryAccess(access, request.Id); err != nil {
+		return nil, err
+	}
+
+	required := permissions.StreamSourceMediaPermission
+	if request.Body.TargetId != nil {
+		required = permissions.StreamTranscodedMediaPermission
+		if _, err := controller.store.GetForMediaAndTarget(request.Id, *request.Body.TargetId, nil); err != nil {
+			return nil, user.ErrShareTargetNotTranscoded
+		}
+	}
+	if !slices.Contains(caller.Permissions, required) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("Cannot create share link as you do not hold permission '%s'", required))
+	}
+
+	expiresAt := time.Now().Add(defaultShareLinkLifespan)
+	if request.Body.ExpiresAt != nil {
+		expiresAt = *request.Body.ExpiresAt
+	}
+	if expiresAt.Before(time.Now()) || time.Until(expiresAt) > maximumShareLinkLifespan {
+		return nil, user.ErrShareExpiryInvalid
+	}
+
+	link := &user.ShareLink{ID: uuid.New(), MediaID: request.Id, TargetID: request.Body.TargetId, CreatedBy: caller.UserID, ExpiresAt: expiresAt}
+	var password []byte
+	if request.Body.Password != nil {
+		password = []byte(*request.Body.Password)
+	}
+	if err := controller.store.CreateShareLink(link, password); err != nil {
+		return nil, err
+	}
+
+	linkDto, err := controller.shareLinkDto(link)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.CreateShareLink201JSONResponse(linkDto), nil
+}
+
+// ListShareLinks returns the unexpired share links created by the caller.
+func (controller *ShareController) ListShareLinks(ec echo.Context, _ gen.ListShareLinksRequestObject) (gen.ListShareLinksResponseObject, error) {
+	caller, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	links, err := controller.store.ListShareLinks(caller.UserID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	output := make([]gen.ShareLink, len(links))
+	for k, link := range links {
+		if output[k], err = controller.shareLinkDto(link); err != nil {
+			return nil, err
+		}
+	}
+
+	return gen.ListShareLinks200JSONResponse(output), nil
+}
+
+// DeleteShareLink revokes a share link created by the caller. Streams
+// already in progress using the link are not interrupted.
+func (controller *ShareController) DeleteShareLink(ec echo.Context, request gen.DeleteShareLinkRequestObject) (gen.DeleteShareLinkResponseObject, error) {
+	caller, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	if err := controller.store.DeleteShareLink(request.Id, caller.UserID); err != nil {
+		return nil, err
+	}
+
+	return gen.DeleteShareLink204Response{}, nil
+}
+
+// shareLinkDto converts the share link provided, including the URL which
+// it's shared media can be streamed from.
+func (controller *ShareController) shareLinkDto(link *user.ShareLink) (gen.ShareLink, error) {
+	token, err := controller.tokens.GenerateShareToken(link.ID, link.ExpiresAt)
+	if err != nil {
+		return gen.ShareLink{}, errors.Join(errors.New("failed to sign share link"), err)
+	}
+
+	return dto.FromShareLink(link, fmt.Sprintf("%s/%s", controller.routePath, token)), nil
+}
@@ -0,0 +1,52 @@
+package statistics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultHistoryDays = 30
+	maxHistoryDays     = 365
+)
+
+type (
+	Store interface {
+		GetMediaStatistics() (*media.Statistics, error)
+		GetTranscodeStatistics(since time.Time) (*transcode.Statistics, error)
+	}
+
+	StatisticsController struct{ store Store }
+)
+
+func New(store Store) *StatisticsController {
+	return &StatisticsController{store: store}
+}
+
+func (controller *StatisticsController) GetStatistics(ec echo.Context, request gen.GetStatisticsRequestObject) (gen.GetStatisticsResponseObject, error) {
+	days := util.NotNilOrDefault(request.Params.Days, defaultHistoryDays)
+	if days < 1 || days > maxHistoryDays {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "days must be between 1 and 365")
+	}
+
+	mediaStats, err := controller.store.GetMediaStatistics()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	// History includes the entirety of the oldest day in the window
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	transcodeStats, err := controller.store.GetTranscodeStatistics(since)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetStatistics200JSONResponse(dto.FromStatistics(mediaStats, transcodeStats)), nil
+}
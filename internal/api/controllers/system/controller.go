@@ -0,0 +1,224 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/consistency"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/disk"
+	"github.com/hbomb79/Thea/internal/health"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/maintenance"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/reload"
+	"github.com/hbomb79/Thea/internal/sysinfo"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	ConsistencyService interface {
+		Check(ctx context.Context) (*consistency.Report, error)
+		LastReport() *consistency.Report
+		RequestVerification() error
+		LastIntegrityReport() *consistency.IntegrityReport
+	}
+
+	ExportService interface {
+		RequestExport() error
+	}
+
+	Scraper interface {
+		Metrics() media.ScraperMetrics
+	}
+
+	IngestWatcher interface {
+		WatcherStatus() ingest.WatcherStatus
+	}
+
+	ConfigReloader interface {
+		ReloadConfig() (*reload.Report, error)
+	}
+
+	SystemInfoProvider interface {
+		SystemInfo(ctx context.Context) *sysinfo.Info
+		DatabaseMetrics() *database.Metrics
+	}
+
+	MaintenanceMode interface {
+		State() maintenance.State
+		Enable(reason string)
+		Disable()
+	}
+
+	HealthChecker interface {
+		Check(ctx context.Context) *health.Report
+	}
+
+	Store interface {
+		MigrationStatus() (*database.MigrationStatus, error)
+	}
+
+	// SystemController exposes information about the Thea server itself. The
+	// monitored paths are the directories Thea writes to, keyed by their purpose.
+	SystemController struct {
+		monitoredPaths map[string]string
+		consistency    ConsistencyService
+		exporter       ExportService
+		scraper        Scraper
+		watcher        IngestWatcher
+		reloader       ConfigReloader
+		info           SystemInfoProvider
+		maintenance    MaintenanceMode
+		health         HealthChecker
+		store          Store
+	}
+)
+
+func New(monitoredPaths map[string]string, consistency ConsistencyService, exporter ExportService, scraper Scraper, watcher IngestWatcher, reloader ConfigReloader, info SystemInfoProvider, maintenance MaintenanceMode, health HealthChecker, store Store) *SystemController {
+	return &SystemController{monitoredPaths: monitoredPaths, consistency: consistency, exporter: exporter, scraper: scraper, watcher: watcher, reloader: reloader, info: info, maintenance: maintenance, health: health, store: store}
+}
+
+func (controller *SystemController) GetDiskUsage(ec echo.Context, _ gen.GetDiskUsageRequestObject) (gen.GetDiskUsageResponseObject, error) {
+	names := make([]string, 0, len(controller.monitoredPaths))
+	for name := range controller.monitoredPaths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]gen.DiskUsage, len(names))
+	for i, name := range names {
+		path := controller.monitoredPaths[name]
+		usage, err := disk.GetUsage(path)
+		if err != nil {
+			message := err.Error()
+			out[i] = gen.DiskUsage{Name: name, Path: path, Error: &message}
+			continue
+		}
+
+		out[i] = dto.FromDiskUsage(name, usage)
+	}
+
+	return gen.GetDiskUsage200JSONResponse(out), nil
+}
+
+func (controller *SystemController) GetConsistencyReport(ec echo.Context, _ gen.GetConsistencyReportRequestObject) (gen.GetConsistencyReportResponseObject, error) {
+	report := controller.consistency.LastReport()
+	if report == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "no consistency check has completed yet")
+	}
+
+	return gen.GetConsistencyReport200JSONResponse(dto.FromConsistencyReport(report)), nil
+}
+
+func (controller *SystemController) RunConsistencyCheck(ec echo.Context, _ gen.RunConsistencyCheckRequestObject) (gen.RunConsistencyCheckResponseObject, error) {
+	report, err := controller.consistency.Check(ec.Request().Context())
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Errorf("consistency check failed: %w", err))
+	}
+
+	return gen.RunConsistencyCheck200JSONResponse(dto.FromConsistencyReport(report)), nil
+}
+
+func (controller *SystemController) GetIntegrityReport(ec echo.Context, _ gen.GetIntegrityReportRequestObject) (gen.GetIntegrityReportResponseObject, error) {
+	report := controller.consistency.LastIntegrityReport()
+	if report == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "no integrity verification has completed yet")
+	}
+
+	return gen.GetIntegrityReport200JSONResponse(dto.FromIntegrityReport(report)), nil
+}
+
+func (controller *SystemController) VerifyIntegrity(ec echo.Context, _ gen.VerifyIntegrityRequestObject) (gen.VerifyIntegrityResponseObject, error) {
+	if err := controller.consistency.RequestVerification(); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.VerifyIntegrity202Response{}, nil
+}
+
+func (controller *SystemController) ExportLibrary(ec echo.Context, _ gen.ExportLibraryRequestObject) (gen.ExportLibraryResponseObject, error) {
+	if err := controller.exporter.RequestExport(); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ExportLibrary202Response{}, nil
+}
+
+// GetScraperMetrics returns the number of scrapes performed, and the duration of
+// each scraper provider, along with the state of the ffprobe pool.
+func (controller *SystemController) GetScraperMetrics(ec echo.Context, _ gen.GetScraperMetricsRequestObject) (gen.GetScraperMetricsResponseObject, error) {
+	return gen.GetScraperMetrics200JSONResponse(dto.FromScraperMetrics(controller.scraper.Metrics())), nil
+}
+
+// GetWatcherStatus returns the health of the file system watcher used to detect new files
+// in the ingest directory. While the watcher is unhealthy, new files are only detected
+// by the periodic forced sync.
+func (controller *SystemController) GetWatcherStatus(ec echo.Context, _ gen.GetWatcherStatusRequestObject) (gen.GetWatcherStatusResponseObject, error) {
+	return gen.GetWatcherStatus200JSONResponse(dto.FromWatcherStatus(controller.watcher.WatcherStatus())), nil
+}
+
+// ReloadConfig re-reads Thea's configuration file, applying the changes which do not require
+// a restart, and returns a report of the options which changed.
+func (controller *SystemController) ReloadConfig(ec echo.Context, _ gen.ReloadConfigRequestObject) (gen.ReloadConfigResponseObject, error) {
+	report, err := controller.reloader.ReloadConfig()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to reload configuration: %w", err))
+	}
+
+	return gen.ReloadConfig200JSONResponse(dto.FromConfigReloadReport(report)), nil
+}
+
+// GetMigrationStatus returns the schema version of the database, and which
+// of the migrations known to Thea have been applied.
+func (controller *SystemController) GetMigrationStatus(ec echo.Context, _ gen.GetMigrationStatusRequestObject) (gen.GetMigrationStatusResponseObject, error) {
+	status, err := controller.store.MigrationStatus()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Errorf("failed to determine migration status: %w", err))
+	}
+
+	return gen.GetMigrationStatus200JSONResponse(dto.FromMigrationStatus(status)), nil
+}
+
+// GetSystemInfo describes the environment Thea is running in, so that it can be
+// captured in a single call (e.g. when raising a support request).
+func (controller *SystemController) GetSystemInfo(ec echo.Context, _ gen.GetSystemInfoRequestObject) (gen.GetSystemInfoResponseObject, error) {
+	return gen.GetSystemInfo200JSONResponse(dto.FromSystemInfo(controller.info.SystemInfo(ec.Request().Context()))), nil
+}
+
+// GetDatabaseMetrics returns the statistics of the connection pool, and the metrics
+// of the queries and transactions made against the database since Thea started.
+func (controller *SystemController) GetDatabaseMetrics(ec echo.Context, _ gen.GetDatabaseMetricsRequestObject) (gen.GetDatabaseMetricsResponseObject, error) {
+	return gen.GetDatabaseMetrics200JSONResponse(dto.FromDatabaseMetrics(controller.info.DatabaseMetrics())), nil
+}
+
+// GetHealth runs the readiness probes, and returns the status of each of the dependencies
+// probed. Unlike the unauthenticated readiness endpoint, the reason a dependency is
+// unhealthy is included.
+func (controller *SystemController) GetHealth(ec echo.Context, _ gen.GetHealthRequestObject) (gen.GetHealthResponseObject, error) {
+	return gen.GetHealth200JSONResponse(dto.FromHealthReport(controller.health.Check(ec.Request().Context()))), nil
+}
+
+func (controller *SystemController) GetMaintenanceMode(ec echo.Context, _ gen.GetMaintenanceModeRequestObject) (gen.GetMaintenanceModeResponseObject, error) {
+	return gen.GetMaintenanceMode200JSONResponse(dto.FromMaintenanceState(controller.maintenance.State())), nil
+}
+
+// SetMaintenanceMode enables or disables maintenance mode, during which requests
+// which modify Thea are rejected, and the ingest/transcode queues are paused.
+func (controller *SystemController) SetMaintenanceMode(ec echo.Context, request gen.SetMaintenanceModeRequestObject) (gen.SetMaintenanceModeResponseObject, error) {
+	if request.Body.Enabled {
+		reason := ""
+		if request.Body.Reason != nil {
+			reason = *request.Body.Reason
+		}
+		controller.maintenance.Enable(reason)
+	} else {
+		controller.maintenance.Disable()
+	}
+
+	return gen.SetMaintenanceMode200JSONResponse(dto.FromMaintenanceState(controller.maintenance.State())), nil
+}
@@ -0,0 +1,92 @@
+package tags
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	Store interface {
+		CreateTag(label string, itemIDs []uuid.UUID) (*media.InflatedTag, error)
+		UpdateTag(tagID uuid.UUID, label *string, itemIDs *[]uuid.UUID) (*media.InflatedTag, error)
+		GetTag(tagID uuid.UUID) (*media.InflatedTag, error)
+		ListTags() ([]*media.Tag, error)
+		DeleteTag(tagID uuid.UUID) error
+	}
+
+	TagController struct{ store Store }
+)
+
+func New(store Store) *TagController {
+	return &TagController{store: store}
+}
+
+func (controller *TagController) ListTags(ec echo.Context, _ gen.ListTagsRequestObject) (gen.ListTagsResponseObject, error) {
+	tags, err := controller.store.ListTags()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListTags200JSONResponse(util.ApplyConversion(tags, dto.FromTag)), nil
+}
+
+func (controller *TagController) CreateTag(ec echo.Context, request gen.CreateTagRequestObject) (gen.CreateTagResponseObject, error) {
+	itemIDs := []uuid.UUID{}
+	if request.Body.ItemIds != nil {
+		itemIDs = *request.Body.ItemIds
+	}
+
+	tag, err := controller.store.CreateTag(request.Body.Label, itemIDs)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create new tag: %w", err))
+	}
+
+	return gen.CreateTag201JSONResponse(dto.FromInflatedTag(tag)), nil
+}
+
+func (controller *TagController) GetTag(ec echo.Context, request gen.GetTagRequestObject) (gen.GetTagResponseObject, error) {
+	tag, err := controller.store.GetTag(request.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.ErrNotFound
+		}
+
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetTag200JSONResponse(dto.FromInflatedTag(tag)), nil
+}
+
+func (controller *TagController) UpdateTag(ec echo.Context, request gen.UpdateTagRequestObject) (gen.UpdateTagResponseObject, error) {
+	tag, err := controller.store.UpdateTag(request.Id, request.Body.Label, request.Body.ItemIds)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.ErrNotFound
+		}
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to update tag: %w", err))
+	}
+
+	return gen.UpdateTag200JSONResponse(dto.FromInflatedTag(tag)), nil
+}
+
+func (controller *TagController) DeleteTag(ec echo.Context, request gen.DeleteTagRequestObject) (gen.DeleteTagResponseObject, error) {
+	if err := controller.store.DeleteTag(request.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.ErrNotFound
+		}
+
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.DeleteTag204Response{}, nil
+}
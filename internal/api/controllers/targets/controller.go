@@ -1,12 +1,18 @@
 package targets
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
 	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/transcode"
 	"github.com/labstack/echo/v4"
 	"github.com/mitchellh/mapstructure"
 )
@@ -17,15 +23,38 @@ type (
 		GetTarget(targetID uuid.UUID) *ffmpeg.Target
 		GetAllTargets() []*ffmpeg.Target
 		DeleteTarget(targetID uuid.UUID)
+		GetTargetPopularity() ([]*transcode.TargetPopularity, error)
+	}
+
+	// TargetValidator ensures that a target is supported by the ffmpeg
+	// build in use before it is saved.
+	TargetValidator interface {
+		Validate(target *ffmpeg.Target) error
+	}
+
+	// PreviewService transcodes short samples of media using
+	// a target, so that the output can be evaluated.
+	PreviewService interface {
+		CreatePreview(ctx context.Context, mediaID uuid.UUID, targetID uuid.UUID) (*transcode.Preview, error)
+		Preview(previewID uuid.UUID) *transcode.Preview
+	}
+
+	// BenchmarkService measures the encoding throughput
+	// of targets in the background.
+	BenchmarkService interface {
+		Benchmark(targetID uuid.UUID) error
 	}
 
 	TargetController struct {
-		store Store
+		store            Store
+		validator        TargetValidator
+		previewService   PreviewService
+		benchmarkService BenchmarkService
 	}
 )
 
-func New(store Store) *TargetController {
-	return &TargetController{store: store}
+func New(store Store, validator TargetValidator, previewService PreviewService, benchmarkService BenchmarkService) *TargetController {
+	return &TargetController{store: store, validator: validator, previewService: previewService, benchmarkService: benchmarkService}
 }
 
 func (controller *TargetController) CreateTarget(ec echo.Context, request gen.CreateTargetRequestObject) (gen.CreateTargetResponseObject, error) {
@@ -35,17 +64,35 @@ func (controller *TargetController) CreateTarget(ec echo.Context, request gen.Cr
 	}
 
 	newTarget := ffmpeg.Target{ID: uuid.New(), Label: request.Body.Label, FfmpegOptions: decoded, Ext: request.Body.Extension}
+	if request.Body.RemuxOnly != nil {
+		newTarget.RemuxOnly = *request.Body.RemuxOnly
+	}
+	if request.Body.AudioLanguages != nil {
+		newTarget.AudioLanguages = *request.Body.AudioLanguages
+	}
+	if err := controller.validator.Validate(&newTarget); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create target: %w", err))
+	}
 	if err := controller.store.SaveTarget(&newTarget); err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to create target: %v", err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create target: %w", err))
 	}
 
-	return gen.CreateTarget201JSONResponse(NewDto(&newTarget)), nil
+	return gen.CreateTarget201JSONResponse(dto.FromTarget(&newTarget)), nil
 }
 
 func (controller *TargetController) ListTargets(ec echo.Context, request gen.ListTargetsRequestObject) (gen.ListTargetsResponseObject, error) {
 	targets := controller.store.GetAllTargets()
 
-	return gen.ListTargets200JSONResponse(NewDtos(targets)), nil
+	return gen.ListTargets200JSONResponse(util.ApplyConversion(targets, dto.FromTarget)), nil
+}
+
+func (controller *TargetController) ListTargetPopularity(ec echo.Context, request gen.ListTargetPopularityRequestObject) (gen.ListTargetPopularityResponseObject, error) {
+	popularity, err := controller.store.GetTargetPopularity()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return gen.ListTargetPopularity200JSONResponse(util.ApplyConversion(popularity, dto.FromTargetPopularity)), nil
 }
 
 func (controller *TargetController) GetTarget(ec echo.Context, request gen.GetTargetRequestObject) (gen.GetTargetResponseObject, error) {
@@ -54,7 +101,7 @@ func (controller *TargetController) GetTarget(ec echo.Context, request gen.GetTa
 		return nil, echo.ErrNotFound
 	}
 
-	return gen.GetTarget200JSONResponse(NewDto(target)), nil
+	return gen.GetTarget200JSONResponse(dto.FromTarget(target)), nil
 }
 
 func (controller *TargetController) UpdateTarget(ec echo.Context, request gen.UpdateTargetRequestObject) (gen.UpdateTargetResponseObject, error) {
@@ -65,6 +112,12 @@ func (controller *TargetController) UpdateTarget(ec echo.Context, request gen.Up
 	if request.Body.Label != nil {
 		model.Label = *request.Body.Label
 	}
+	if request.Body.RemuxOnly != nil {
+		model.RemuxOnly = *request.Body.RemuxOnly
+	}
+	if request.Body.AudioLanguages != nil {
+		model.AudioLanguages = *request.Body.AudioLanguages
+	}
 	if request.Body.FfmpegOptions != nil {
 		if opts, err := ffmpegOptsToModel(*request.Body.FfmpegOptions); err == nil {
 			model.FfmpegOptions = opts
@@ -73,11 +126,14 @@ func (controller *TargetController) UpdateTarget(ec echo.Context, request gen.Up
 		}
 	}
 
+	if err := controller.validator.Validate(&model); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to save target: %w", err))
+	}
 	if err := controller.store.SaveTarget(&model); err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to save target: %v", err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to save target: %w", err))
 	}
 
-	return gen.UpdateTarget200JSONResponse(NewDto(&model)), nil
+	return gen.UpdateTarget200JSONResponse(dto.FromTarget(&model)), nil
 }
 
 func (controller *TargetController) DeleteTarget(ec echo.Context, request gen.DeleteTargetRequestObject) (gen.DeleteTargetResponseObject, error) {
@@ -86,38 +142,70 @@ func (controller *TargetController) DeleteTarget(ec echo.Context, request gen.De
 	return gen.DeleteTarget204Response{}, nil
 }
 
-func ffmpegOptsToModel(opts map[string]interface{}) (*ffmpeg.Opts, error) {
-	var decoded ffmpeg.Opts
-	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: &decoded})
-	if err != nil {
-		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to create map decoder: %s", err))
+func (controller *TargetController) BenchmarkTarget(ec echo.Context, request gen.BenchmarkTargetRequestObject) (gen.BenchmarkTargetResponseObject, error) {
+	if err := controller.benchmarkService.Benchmark(request.Id); err != nil {
+		return nil, err
 	}
 
-	if err := decoder.Decode(opts); err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to save target: ffmpeg_options malformed: %s", err))
+	return gen.BenchmarkTarget202Response{}, nil
+}
+
+func (controller *TargetController) CreateTargetPreview(ec echo.Context, request gen.CreateTargetPreviewRequestObject) (gen.CreateTargetPreviewResponseObject, error) {
+	preview, err := controller.previewService.CreatePreview(ec.Request().Context(), request.Body.MediaId, request.Id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Errorf("failed to create preview: %w", err))
 	}
 
-	return &decoded, nil
+	return gen.CreateTargetPreview201JSONResponse{
+		Id:        preview.ID,
+		Url:       fmt.Sprintf("%s/%s", ec.Request().URL.Path, preview.ID),
+		ExpiresAt: preview.ExpiresAt,
+	}, nil
 }
 
-func ffmpegOptsToDto(opts *ffmpeg.Opts) map[string]interface{} {
-	var dto map[string]interface{}
-	if err := mapstructure.Decode(opts, &dto); err != nil {
-		panic("ffmpeg options cannot be decoded to map[string]interface{}")
+func (controller *TargetController) GetTargetPreview(ec echo.Context, request gen.GetTargetPreviewRequestObject) (gen.GetTargetPreviewResponseObject, error) {
+	preview := controller.previewService.Preview(request.PreviewId)
+	if preview == nil || preview.TargetID != request.Id {
+		return nil, echo.ErrNotFound
 	}
 
-	return dto
+	file, err := os.Open(preview.Path)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return previewResponse{file: file, request: ec.Request()}, nil
 }
 
-func NewDto(model *ffmpeg.Target) gen.Target {
-	return gen.Target{Id: model.ID, Label: model.Label, Extension: model.Ext, FfmpegOptions: ffmpegOptsToDto(model.FfmpegOptions)}
+// previewResponse streams the preview to the client, supporting range requests
+// so that the preview can be seeked. The file is closed once the response is written.
+type previewResponse struct {
+	file    *os.File
+	request *http.Request
+}
+
+func (response previewResponse) VisitGetTargetPreviewResponse(w http.ResponseWriter) error {
+	defer response.file.Close()
+
+	info, err := response.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	http.ServeContent(w, response.request, filepath.Base(response.file.Name()), info.ModTime(), response.file)
+	return nil
 }
 
-func NewDtos(models []*ffmpeg.Target) []gen.Target {
-	dtos := make([]gen.Target, len(models))
-	for k, v := range models {
-		dtos[k] = gen.Target{Id: v.ID, Label: v.Label, Extension: v.Ext, FfmpegOptions: ffmpegOptsToDto(v.FfmpegOptions)}
+func ffmpegOptsToModel(opts map[string]interface{}) (*ffmpeg.Opts, error) {
+	var decoded ffmpeg.Opts
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{ErrorUnused: true, Result: &decoded})
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Errorf("failed to create map decoder: %w", err))
 	}
 
-	return dtos
+	if err := decoder.Decode(opts); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to save target: ffmpeg_options malformed: %w", err))
+	}
+
+	return &decoded, nil
 }
@@ -1,27 +1,47 @@
 package transcodes
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
 	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/quota"
 	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/labstack/echo/v4"
 )
 
+var log = logger.Get("TranscodesController")
+
 type (
 	TranscodeService interface {
-		NewTask(mediaID uuid.UUID, targetID uuid.UUID) error
+		NewTask(ctx context.Context, userID uuid.UUID, mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID, important bool) error
+		Quota(userID uuid.UUID) (*quota.Usage, error)
 		CancelTask(id uuid.UUID) error
 		PauseTask(id uuid.UUID) error
 		ResumeTask(id uuid.UUID) error
 		Task(id uuid.UUID) *transcode.TranscodeTask
 		AllTasks() []*transcode.TranscodeTask
 		ActiveTasksForMedia(mediaID uuid.UUID) []*transcode.TranscodeTask
+		PauseQueue(suspendRunning bool)
+		ResumeQueue()
+		IsQueuePaused() bool
+		Batches() []*transcode.Batch
+		Batch(id uuid.UUID) *transcode.Batch
+		BatchProgress(batch *transcode.Batch) transcode.BatchProgress
+		StartPlayback(userID uuid.UUID, mediaID uuid.UUID, versionID *uuid.UUID) (*transcode.PlaybackSession, error)
+		RefreshPlayback(userID uuid.UUID, sessionID uuid.UUID) (*transcode.PlaybackSession, error)
+		EndPlayback(userID uuid.UUID, sessionID uuid.UUID) error
+		PlaybackSessions() []*transcode.PlaybackSession
 	}
 
 	Store interface {
@@ -29,60 +49,133 @@ type (
 		GetTranscode(transcodeID uuid.UUID) *transcode.Transcode
 		GetAllTranscodes() ([]*transcode.Transcode, error)
 		DeleteTranscode(transcodeID uuid.UUID) error
+		RecordTranscodePlaybackStart(transcodeID uuid.UUID) error
+		RecordTranscodePlaybackCompletion(transcodeID uuid.UUID) error
+		GetTranscodeOutput(transcodeID uuid.UUID) (string, error)
+		ListTranscodeHistory(filter transcode.HistoryFilter) ([]*transcode.HistoryEntry, error)
 	}
 
 	TranscodesController struct {
 		transcodeService TranscodeService
 		store            Store
 	}
+
+	// transcodeStreamResponse serves the output of a completed transcode using http.ServeContent,
+	// which handles range (and conditional) requests on our behalf.
+	transcodeStreamResponse struct {
+		request *http.Request
+		file    *os.File
+		info    os.FileInfo
+	}
 )
 
 func New(transcodeService TranscodeService, store Store) *TranscodesController {
 	return &TranscodesController{transcodeService: transcodeService, store: store}
 }
 
+// CreateTranscodeTask creates a transcode task on behalf of the user of the request. If the quota of
+// the user does not permit another task, the error is mapped to a 409 (concurrent limit) or 429
+// (daily limit) response.
 func (controller *TranscodesController) CreateTranscodeTask(ec echo.Context, request gen.CreateTranscodeTaskRequestObject) (gen.CreateTranscodeTaskResponseObject, error) {
-	if err := controller.transcodeService.NewTask(request.Body.MediaId, request.Body.TargetId); err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Task creation failed: %v", err))
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	if err := controller.transcodeService.NewTask(ec.Request().Context(), user.UserID, request.Body.MediaId, request.Body.TargetId, request.Body.VersionId, request.Body.Important != nil && *request.Body.Important); err != nil {
+		if errors.Is(err, transcode.ErrPassthrough) {
+			// The source already satisfies the target, and has been recorded as the transcode
+			return gen.CreateTranscodeTask201Response{}, nil
+		}
+
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("task creation failed: %w", err))
 	}
 
 	return gen.CreateTranscodeTask201Response{}, nil
 }
 
+// GetTranscodeQuota returns the quota of the user of the request, and how much of it remains.
+func (controller *TranscodesController) GetTranscodeQuota(ec echo.Context, _ gen.GetTranscodeQuotaRequestObject) (gen.GetTranscodeQuotaResponseObject, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	usage, err := controller.transcodeService.Quota(user.UserID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetTranscodeQuota200JSONResponse(dto.FromQuotaUsage(usage)), nil
+}
+
 func (controller *TranscodesController) ListActiveTranscodeTasks(ec echo.Context, request gen.ListActiveTranscodeTasksRequestObject) (gen.ListActiveTranscodeTasksResponseObject, error) {
 	tasks := controller.transcodeService.AllTasks()
 
-	return gen.ListActiveTranscodeTasks200JSONResponse(util.ApplyConversion(tasks, NewDtoFromTask)), nil
+	return gen.ListActiveTranscodeTasks200JSONResponse(util.ApplyConversion(tasks, dto.FromTranscodeTask)), nil
 }
 
 func (controller *TranscodesController) ListCompletedTranscodeTasks(ec echo.Context, request gen.ListCompletedTranscodeTasksRequestObject) (gen.ListCompletedTranscodeTasksResponseObject, error) {
 	tasks, err := controller.store.GetAllTranscodes()
 	if err != nil {
-		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
-	return gen.ListCompletedTranscodeTasks200JSONResponse(util.ApplyConversion(tasks, NewDtoFromModel)), nil
+	return gen.ListCompletedTranscodeTasks200JSONResponse(util.ApplyConversion(tasks, dto.FromTranscode)), nil
+}
+
+func (controller *TranscodesController) ListTranscodeHistory(ec echo.Context, request gen.ListTranscodeHistoryRequestObject) (gen.ListTranscodeHistoryResponseObject, error) {
+	filter := transcode.HistoryFilter{
+		Since:  request.Params.Since,
+		Offset: util.NotNilOrDefault(request.Params.Offset, 0),
+		Limit:  util.NotNilOrDefault(request.Params.Limit, 0),
+	}
+	if request.Params.Status != nil {
+		for _, status := range *request.Params.Status {
+			filter.Statuses = append(filter.Statuses, transcode.OutcomeStatus(status))
+		}
+	}
+
+	entries, err := controller.store.ListTranscodeHistory(filter)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.ListTranscodeHistory200JSONResponse(util.ApplyConversion(entries, dto.FromTranscodeHistoryEntry)), nil
 }
 
 func (controller *TranscodesController) GetTranscodeTask(ec echo.Context, request gen.GetTranscodeTaskRequestObject) (gen.GetTranscodeTaskResponseObject, error) {
 	if task := controller.transcodeService.Task(request.Id); task != nil {
-		return gen.GetTranscodeTask200JSONResponse(NewDtoFromTask(task)), nil
+		return gen.GetTranscodeTask200JSONResponse(dto.FromTranscodeTask(task)), nil
 	}
 
 	if model := controller.store.GetTranscode(request.Id); model != nil {
-		return gen.GetTranscodeTask200JSONResponse(NewDtoFromModel(model)), nil
+		return gen.GetTranscodeTask200JSONResponse(dto.FromTranscode(model)), nil
 	}
 
 	return nil, echo.ErrNotFound
 }
 
-func (controller *TranscodesController) PauseTranscodeTask(ec echo.Context, request gen.PauseTranscodeTaskRequestObject) (gen.PauseTranscodeTaskResponseObject, error) {
-	if err := controller.transcodeService.PauseTask(request.Id); err != nil {
-		if errors.Is(err, transcode.ErrTaskNotFound) {
+func (controller *TranscodesController) GetTranscodeTaskLogs(ec echo.Context, request gen.GetTranscodeTaskLogsRequestObject) (gen.GetTranscodeTaskLogsResponseObject, error) {
+	if task := controller.transcodeService.Task(request.Id); task != nil {
+		return gen.GetTranscodeTaskLogs200JSONResponse{Output: task.Output()}, nil
+	}
+
+	output, err := controller.store.GetTranscodeOutput(request.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
 			return nil, echo.ErrNotFound
-		} else {
-			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to pause task %s: %s", request.Id, err))
 		}
+
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetTranscodeTaskLogs200JSONResponse{Output: output}, nil
+}
+
+func (controller *TranscodesController) PauseTranscodeTask(ec echo.Context, request gen.PauseTranscodeTaskRequestObject) (gen.PauseTranscodeTaskResponseObject, error) {
+	if err := controller.transcodeService.PauseTask(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to pause task %s: %w", request.Id, err))
 	}
 
 	return gen.PauseTranscodeTask200Response{}, nil
@@ -90,16 +183,48 @@ func (controller *TranscodesController) PauseTranscodeTask(ec echo.Context, requ
 
 func (controller *TranscodesController) ResumeTranscodeTask(ec echo.Context, request gen.ResumeTranscodeTaskRequestObject) (gen.ResumeTranscodeTaskResponseObject, error) {
 	if err := controller.transcodeService.ResumeTask(request.Id); err != nil {
-		if errors.Is(err, transcode.ErrTaskNotFound) {
-			return nil, echo.ErrNotFound
-		} else {
-			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to resume task %s: %s", request.Id, err))
-		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to resume task %s: %w", request.Id, err))
 	}
 
 	return gen.ResumeTranscodeTask200Response{}, nil
 }
 
+func (controller *TranscodesController) GetTranscodeQueueStatus(ec echo.Context, request gen.GetTranscodeQueueStatusRequestObject) (gen.GetTranscodeQueueStatusResponseObject, error) {
+	return gen.GetTranscodeQueueStatus200JSONResponse{Paused: controller.transcodeService.IsQueuePaused()}, nil
+}
+
+func (controller *TranscodesController) PauseTranscodeQueue(ec echo.Context, request gen.PauseTranscodeQueueRequestObject) (gen.PauseTranscodeQueueResponseObject, error) {
+	suspendRunning := request.Params.SuspendRunning != nil && *request.Params.SuspendRunning
+	controller.transcodeService.PauseQueue(suspendRunning)
+
+	return gen.PauseTranscodeQueue200Response{}, nil
+}
+
+func (controller *TranscodesController) ResumeTranscodeQueue(ec echo.Context, request gen.ResumeTranscodeQueueRequestObject) (gen.ResumeTranscodeQueueResponseObject, error) {
+	controller.transcodeService.ResumeQueue()
+
+	return gen.ResumeTranscodeQueue200Response{}, nil
+}
+
+func (controller *TranscodesController) ListTranscodeBatches(ec echo.Context, request gen.ListTranscodeBatchesRequestObject) (gen.ListTranscodeBatchesResponseObject, error) {
+	batches := controller.transcodeService.Batches()
+	out := make([]gen.TranscodeBatch, len(batches))
+	for k, batch := range batches {
+		out[k] = dto.FromTranscodeBatch(batch, controller.transcodeService.BatchProgress(batch))
+	}
+
+	return gen.ListTranscodeBatches200JSONResponse(out), nil
+}
+
+func (controller *TranscodesController) GetTranscodeBatch(ec echo.Context, request gen.GetTranscodeBatchRequestObject) (gen.GetTranscodeBatchResponseObject, error) {
+	batch := controller.transcodeService.Batch(request.Id)
+	if batch == nil {
+		return nil, echo.ErrNotFound
+	}
+
+	return gen.GetTranscodeBatch200JSONResponse(dto.FromTranscodeBatch(batch, controller.transcodeService.BatchProgress(batch))), nil
+}
+
 func (controller *TranscodesController) DeleteTranscodeTask(ec echo.Context, request gen.DeleteTranscodeTaskRequestObject) (gen.DeleteTranscodeTaskResponseObject, error) {
 	// Try cancel active task - if not found, try delete completed task - if both not found
 	// then error 404, else return the first error we encounter.
@@ -110,7 +235,7 @@ func (controller *TranscodesController) DeleteTranscodeTask(ec echo.Context, req
 					return nil, echo.ErrNotFound
 				}
 
-				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to delete completed task %s due to error: %v", request.Id, err))
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to delete completed task %s due to error: %w", request.Id, err))
 			}
 
 			return gen.DeleteTranscodeTask204Response{}, nil
@@ -122,10 +247,99 @@ func (controller *TranscodesController) DeleteTranscodeTask(ec echo.Context, req
 	return gen.DeleteTranscodeTask204Response{}, nil
 }
 
+func (controller *TranscodesController) StreamTranscode(ec echo.Context, request gen.StreamTranscodeRequestObject) (gen.StreamTranscodeResponseObject, error) {
+	model := controller.store.GetTranscode(request.Id)
+	if model == nil {
+		return gen.StreamTranscode404Response{}, nil
+	}
+
+	file, err := os.Open(model.MediaPath)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to open transcode %s: %v", request.Id, err))
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to stat transcode %s: %v", request.Id, err))
+	}
+
+	// Players request ranges as they seek, so only a request starting from the
+	// beginning of the transcode is recorded as the playback starting.
+	if rangeHeader := ec.Request().Header.Get("Range"); rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-") {
+		if err := controller.store.RecordTranscodePlaybackStart(request.Id); err != nil {
+			log.Warnf("Failed to record playback start of transcode %s: %v\n", request.Id, err)
+		}
+	}
+
+	return transcodeStreamResponse{request: ec.Request(), file: file, info: info}, nil
+}
+
+func (controller *TranscodesController) CompleteTranscodeStream(ec echo.Context, request gen.CompleteTranscodeStreamRequestObject) (gen.CompleteTranscodeStreamResponseObject, error) {
+	if controller.store.GetTranscode(request.Id) == nil {
+		return gen.CompleteTranscodeStream404Response{}, nil
+	}
+
+	if err := controller.store.RecordTranscodePlaybackCompletion(request.Id); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return gen.CompleteTranscodeStream204Response{}, nil
+}
+
+func (response transcodeStreamResponse) VisitStreamTranscodeResponse(w http.ResponseWriter) error {
+	defer response.file.Close()
+
+	http.ServeContent(w, response.request, response.info.Name(), response.info.ModTime(), response.file)
+	return nil
+}
+
 // func (controller *TranscodesController) postTroubleResolution(ec echo.Context) error {
 // 	return echo.NewHTTPError(http.StatusNotImplemented, "not yet implemented")
 // }
 
-// func (controller *TranscodesController) stream(ec echo.Context) error {
-// 	return echo.NewHTTPError(http.StatusNotImplemented, "not yet implemented")
-// }
+func (controller *TranscodesController) ListPlaybackSessions(ec echo.Context, _ gen.ListPlaybackSessionsRequestObject) (gen.ListPlaybackSessionsResponseObject, error) {
+	sessions := controller.transcodeService.PlaybackSessions()
+
+	return gen.ListPlaybackSessions200JSONResponse(util.ApplyConversion(sessions, dto.FromPlaybackSession)), nil
+}
+
+func (controller *TranscodesController) StartPlaybackSession(ec echo.Context, request gen.StartPlaybackSessionRequestObject) (gen.StartPlaybackSessionResponseObject, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	session, err := controller.transcodeService.StartPlayback(user.UserID, request.Body.MediaId, request.Body.VersionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.StartPlaybackSession201JSONResponse(dto.FromPlaybackSession(session)), nil
+}
+
+func (controller *TranscodesController) RefreshPlaybackSession(ec echo.Context, request gen.RefreshPlaybackSessionRequestObject) (gen.RefreshPlaybackSessionResponseObject, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	session, err := controller.transcodeService.RefreshPlayback(user.UserID, request.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return gen.RefreshPlaybackSession200JSONResponse(dto.FromPlaybackSession(session)), nil
+}
+
+func (controller *TranscodesController) EndPlaybackSession(ec echo.Context, request gen.EndPlaybackSessionRequestObject) (gen.EndPlaybackSessionResponseObject, error) {
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+
+	if err := controller.transcodeService.EndPlayback(user.UserID, request.Id); err != nil {
+		return nil, err
+	}
+
+	return gen.EndPlaybackSession204Response{}, nil
+}
@@ -5,8 +5,11 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/api/gen"
 	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/library"
+	"github.com/hbomb79/Thea/internal/quota"
 	"github.com/hbomb79/Thea/internal/user"
 	"github.com/labstack/echo/v4"
 )
@@ -16,14 +19,25 @@ type (
 		ListUsers() ([]*user.User, error)
 		GetUserWithID(userID uuid.UUID) (*user.User, error)
 		UpdateUserPermissions(userID uuid.UUID, newPermissions []string) error
+		UpdateUserRoles(userID uuid.UUID, newRoleIDs []uuid.UUID) error
+		GetUserLibraries(userID uuid.UUID) ([]*library.Library, error)
+		UpdateUserLibraries(userID uuid.UUID, libraryIDs []uuid.UUID) error
+		SaveUserQuota(userQuota *quota.Quota) error
 		CreateUser(username []byte, password []byte, permissions ...string) (*user.User, error)
 	}
 
-	UserController struct{ store Store }
+	TranscodeService interface {
+		Quota(userID uuid.UUID) (*quota.Usage, error)
+	}
+
+	UserController struct {
+		store            Store
+		transcodeService TranscodeService
+	}
 )
 
-func NewController(store Store) *UserController {
-	return &UserController{store: store}
+func NewController(store Store, transcodeService TranscodeService) *UserController {
+	return &UserController{store: store, transcodeService: transcodeService}
 }
 
 func (controller *UserController) CreateUser(ec echo.Context, request gen.CreateUserRequestObject) (gen.CreateUserResponseObject, error) {
@@ -32,7 +46,7 @@ func (controller *UserController) CreateUser(ec echo.Context, request gen.Create
 		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
-	return gen.CreateUser200JSONResponse(userToDto(user)), nil
+	return gen.CreateUser200JSONResponse(dto.FromUser(user)), nil
 }
 
 func (controller *UserController) ListUsers(ec echo.Context, _ gen.ListUsersRequestObject) (gen.ListUsersResponseObject, error) {
@@ -41,7 +55,7 @@ func (controller *UserController) ListUsers(ec echo.Context, _ gen.ListUsersRequ
 		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
-	return gen.ListUsers200JSONResponse(util.ApplyConversion(users, userToDto)), nil
+	return gen.ListUsers200JSONResponse(util.ApplyConversion(users, dto.FromUser)), nil
 }
 
 func (controller *UserController) GetUser(ec echo.Context, request gen.GetUserRequestObject) (gen.GetUserResponseObject, error) {
@@ -50,13 +64,84 @@ func (controller *UserController) GetUser(ec echo.Context, request gen.GetUserRe
 		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
-	return gen.GetUser200JSONResponse(userToDto(user)), nil
+	return gen.GetUser200JSONResponse(dto.FromUser(user)), nil
 }
 
 func (controller *UserController) UpdateUserPermissions(ec echo.Context, request gen.UpdateUserPermissionsRequestObject) (gen.UpdateUserPermissionsResponseObject, error) {
 	if err := controller.store.UpdateUserPermissions(request.Id, request.Body.Permissions); err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to apply new permissions for user: %s", err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to apply new permissions for user: %w", err))
 	}
 
 	return gen.UpdateUserPermissions200Response{}, nil
 }
+
+func (controller *UserController) UpdateUserRoles(ec echo.Context, request gen.UpdateUserRolesRequestObject) (gen.UpdateUserRolesResponseObject, error) {
+	if err := controller.store.UpdateUserRoles(request.Id, request.Body.RoleIds); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to apply new roles for user: %w", err))
+	}
+
+	return gen.UpdateUserRoles200Response{}, nil
+}
+
+func (controller *UserController) GetUserLibraries(ec echo.Context, request gen.GetUserLibrariesRequestObject) (gen.GetUserLibrariesResponseObject, error) {
+	if _, err := controller.store.GetUserWithID(request.Id); err != nil {
+		return nil, err
+	}
+
+	libraries, err := controller.store.GetUserLibraries(request.Id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetUserLibraries200JSONResponse(util.ApplyConversion(libraries, dto.FromLibrary)), nil
+}
+
+func (controller *UserController) UpdateUserLibraries(ec echo.Context, request gen.UpdateUserLibrariesRequestObject) (gen.UpdateUserLibrariesResponseObject, error) {
+	if _, err := controller.store.GetUserWithID(request.Id); err != nil {
+		return nil, err
+	}
+
+	if err := controller.store.UpdateUserLibraries(request.Id, request.Body.LibraryIds); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to apply new libraries for user: %w", err))
+	}
+
+	return gen.UpdateUserLibraries200Response{}, nil
+}
+
+func (controller *UserController) GetUserQuota(ec echo.Context, request gen.GetUserQuotaRequestObject) (gen.GetUserQuotaResponseObject, error) {
+	if _, err := controller.store.GetUserWithID(request.Id); err != nil {
+		return nil, err
+	}
+
+	usage, err := controller.transcodeService.Quota(request.Id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.GetUserQuota200JSONResponse(dto.FromQuotaUsage(usage)), nil
+}
+
+// SetUserQuota replaces the quota of the user. The new quota applies only to tasks requested
+// after the change, active tasks which exceed a reduced limit are not cancelled.
+func (controller *UserController) SetUserQuota(ec echo.Context, request gen.SetUserQuotaRequestObject) (gen.SetUserQuotaResponseObject, error) {
+	if _, err := controller.store.GetUserWithID(request.Id); err != nil {
+		return nil, err
+	}
+
+	userQuota := &quota.Quota{
+		UserID:                  request.Id,
+		MaxConcurrentTranscodes: request.Body.MaxConcurrentTranscodes,
+		MaxDailyTranscodes:      request.Body.MaxDailyTranscodes,
+		MaxConcurrentSessions:   request.Body.MaxConcurrentSessions,
+	}
+	if err := controller.store.SaveUserQuota(userQuota); err != nil {
+		return nil, err
+	}
+
+	usage, err := controller.transcodeService.Quota(request.Id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return gen.SetUserQuota200JSONResponse(dto.FromQuotaUsage(usage)), nil
+}
@@ -1,12 +1,19 @@
 package workflows
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
 	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user/permissions"
 	"github.com/hbomb79/Thea/internal/workflow"
 	"github.com/hbomb79/Thea/internal/workflow/match"
 	"github.com/labstack/echo/v4"
@@ -17,36 +24,56 @@ type (
 		DeleteWorkflow(workflowID uuid.UUID)
 		GetWorkflow(workflowID uuid.UUID) *workflow.Workflow
 		GetAllWorkflows() []*workflow.Workflow
-		CreateWorkflow(workflowID uuid.UUID, label string, criteria []match.Criteria, targetIDs []uuid.UUID, enabled bool) (*workflow.Workflow, error)
-		UpdateWorkflow(workflowID uuid.UUID, newLabel *string, newCriteria *[]match.Criteria, newTargetIDs *[]uuid.UUID, newEnabled *bool) (*workflow.Workflow, error)
+		CreateWorkflow(workflowID uuid.UUID, label string, criteria []match.Criteria, targetIDs []uuid.UUID, enabled bool, sequential bool, hooks workflow.Hooks) (*workflow.Workflow, error)
+		UpdateWorkflow(workflowID uuid.UUID, newLabel *string, newCriteria *[]match.Criteria, newTargetIDs *[]uuid.UUID, newEnabled *bool, newSequential *bool, newHooks *workflow.Hooks) (*workflow.Workflow, error)
 	}
 
-	WorkflowController struct{ store Store }
+	TranscodeService interface {
+		ApplyWorkflow(ctx context.Context, label string, workflowID uuid.UUID, targetID *uuid.UUID, filter transcode.BatchFilter) (*transcode.Batch, error)
+		BatchProgress(batch *transcode.Batch) transcode.BatchProgress
+	}
+
+	WorkflowController struct {
+		store            Store
+		transcodeService TranscodeService
+	}
 )
 
-func New(store Store) *WorkflowController {
-	return &WorkflowController{store: store}
+var batchMediaTypeMapping = map[gen.TranscodeBatchMediaType]media.ContainerType{
+	gen.TranscodeBatchMediaTypeMOVIE:   media.MovieContainerType,
+	gen.TranscodeBatchMediaTypeEPISODE: media.EpisodeContainerType,
+}
+
+func New(store Store, transcodeService TranscodeService) *WorkflowController {
+	return &WorkflowController{store: store, transcodeService: transcodeService}
 }
 
 func (controller *WorkflowController) CreateWorkflow(ec echo.Context, request gen.CreateWorkflowRequestObject) (gen.CreateWorkflowResponseObject, error) {
+	hooks, err := hooksToModel(ec, request.Body.Hooks)
+	if err != nil {
+		return nil, err
+	}
+
 	workflow, err := controller.store.CreateWorkflow(
 		uuid.New(),
 		request.Body.Label,
 		util.ApplyConversion(util.NotNilOrDefault(request.Body.Criteria, []gen.WorkflowCriteria{}), criteriaToModel),
 		util.NotNilOrDefault(request.Body.TargetIds, []uuid.UUID{}),
 		request.Body.Enabled,
+		util.NotNilOrDefault(request.Body.Sequential, false),
+		util.NotNilOrDefault(hooks, workflow.Hooks{}),
 	)
 	if err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to create new workflow: %v", err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to create new workflow: %w", err))
 	}
 
-	return gen.CreateWorkflow201JSONResponse(workflowToDto(workflow)), nil
+	return gen.CreateWorkflow201JSONResponse(dto.FromWorkflow(workflow)), nil
 }
 
 func (controller *WorkflowController) ListWorkflows(ec echo.Context, request gen.ListWorkflowsRequestObject) (gen.ListWorkflowsResponseObject, error) {
 	workflowModels := controller.store.GetAllWorkflows()
 
-	return gen.ListWorkflows200JSONResponse(util.ApplyConversion(workflowModels, workflowToDto)), nil
+	return gen.ListWorkflows200JSONResponse(util.ApplyConversion(workflowModels, dto.FromWorkflow)), nil
 }
 
 func (controller *WorkflowController) GetWorkflow(ec echo.Context, request gen.GetWorkflowRequestObject) (gen.GetWorkflowResponseObject, error) {
@@ -55,22 +82,57 @@ func (controller *WorkflowController) GetWorkflow(ec echo.Context, request gen.G
 		return nil, echo.ErrNotFound
 	}
 
-	return gen.GetWorkflow200JSONResponse(workflowToDto(workflow)), nil
+	return gen.GetWorkflow200JSONResponse(dto.FromWorkflow(workflow)), nil
 }
 
 func (controller *WorkflowController) UpdateWorkflow(ec echo.Context, request gen.UpdateWorkflowRequestObject) (gen.UpdateWorkflowResponseObject, error) {
+	hooks, err := hooksToModel(ec, request.Body.Hooks)
+	if err != nil {
+		return nil, err
+	}
+
 	model, err := controller.store.UpdateWorkflow(
 		request.Id,
 		request.Body.Label,
 		util.ApplyOptionalConversion(request.Body.Criteria, criteriaToModel),
 		request.Body.TargetIds,
 		request.Body.Enabled,
+		request.Body.Sequential,
+		hooks,
 	)
 	if err != nil {
-		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to update workflow: %v", err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("failed to update workflow: %w", err))
+	}
+
+	return gen.UpdateWorkflow200JSONResponse(dto.FromWorkflow(model)), nil
+}
+
+// ApplyWorkflow queues transcodes for the workflow against the existing media matching the
+// filter provided, returning the batch which tracks the progress of the queued tasks.
+func (controller *WorkflowController) ApplyWorkflow(ec echo.Context, request gen.ApplyWorkflowRequestObject) (gen.ApplyWorkflowResponseObject, error) {
+	filter := transcode.BatchFilter{}
+	for _, t := range util.NotNilOrDefault(request.Body.MediaTypes, []gen.TranscodeBatchMediaType{}) {
+		containerType, ok := batchMediaTypeMapping[t]
+		if !ok {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("media type '%v' is not recognized", t))
+		}
+		filter.Types = append(filter.Types, containerType)
+	}
+	if request.Body.Criteria != nil {
+		filter.Criteria = util.ApplyConversion(*request.Body.Criteria, criteriaToModel)
+		for _, criteria := range filter.Criteria {
+			if err := criteria.ValidateLegal(); err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Errorf("invalid criteria: %w", err))
+			}
+		}
+	}
+
+	batch, err := controller.transcodeService.ApplyWorkflow(ec.Request().Context(), request.Body.Label, request.Id, request.Body.TargetId, filter)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, fmt.Errorf("failed to apply workflow: %w", err))
 	}
 
-	return gen.UpdateWorkflow200JSONResponse(workflowToDto(model)), nil
+	return gen.ApplyWorkflow201JSONResponse(dto.FromTranscodeBatch(batch, controller.transcodeService.BatchProgress(batch))), nil
 }
 
 func (controller *WorkflowController) DeleteWorkflow(ec echo.Context, request gen.DeleteWorkflowRequestObject) (gen.DeleteWorkflowResponseObject, error) {
@@ -78,3 +140,27 @@ func (controller *WorkflowController) DeleteWorkflow(ec echo.Context, request ge
 
 	return gen.DeleteWorkflow204Response{}, nil
 }
+
+// hooksToModel converts the hooks provided (if any) to their model, returning an error if the
+// caller does not hold the permission required to set the hooks of a workflow, or if any of
+// the hooks are invalid.
+func hooksToModel(ec echo.Context, hooks *[]gen.WorkflowHook) (*workflow.Hooks, error) {
+	if hooks == nil {
+		return nil, nil
+	}
+
+	user, ok := ec.Get("user").(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, echo.ErrUnauthorized
+	}
+	if !slices.Contains(user.Permissions, permissions.ManageWorkflowHooksPermission) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("Cannot set workflow hooks as you do not hold permission '%s'", permissions.ManageWorkflowHooksPermission))
+	}
+
+	model := workflow.Hooks(util.ApplyConversion(*hooks, hookToModel))
+	if err := model.Validate(); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return &model, nil
+}
@@ -4,89 +4,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/api/gen"
 	"github.com/hbomb79/Thea/internal/api/util"
-	"github.com/hbomb79/Thea/internal/ffmpeg"
 	"github.com/hbomb79/Thea/internal/workflow"
 	"github.com/hbomb79/Thea/internal/workflow/match"
 )
 
-func workflowToDto(model *workflow.Workflow) gen.Workflow {
-	return gen.Workflow{
-		Id:        model.ID,
-		Label:     model.Label,
-		Enabled:   model.Enabled,
-		Criteria:  util.ApplyConversion(model.Criteria, criteriaToDto),
-		TargetIds: util.ApplyConversion(model.Targets, getTargetID),
-	}
-}
-
-func criteriaToDto(criteria match.Criteria) gen.WorkflowCriteria {
-	return gen.WorkflowCriteria{
-		CombineType: criteriaCombineTypeToDto(criteria.CombineType),
-		Key:         criteriaKeyToDto(criteria.Key),
-		Type:        criteriaTypeToDto(criteria.Type),
-		Value:       criteria.Value,
-	}
-}
-
-func criteriaCombineTypeToDto(combineType match.CombineType) gen.WorkflowCriteriaCombineType {
-	switch combineType {
-	case match.AND:
-		return gen.AND
-	case match.OR:
-		return gen.OR
-	}
-
-	panic("unreachable")
-}
-
-func criteriaKeyToDto(key match.Key) gen.WorkflowCriteriaKey {
-	switch key {
-	case match.MediaTitleKey:
-		return gen.MEDIATITLE
-	case match.SeasonTitleKey:
-		return gen.SEASONTITLE
-	case match.SeriesTitleKey:
-		return gen.SERIESTITLE
-	case match.ResolutionKey:
-		return gen.RESOLUTION
-	case match.SeasonNumberKey:
-		return gen.SEASONNUMBER
-	case match.EpisodeNumberKey:
-		return gen.EPISODENUMBER
-	case match.SourcePathKey:
-		return gen.SOURCEPATH
-	case match.SourceNameKey:
-		return gen.SOURCENAME
-	case match.SourceExtensionKey:
-		return gen.SOURCEEXTENSION
-	}
-
-	panic("unreachable")
-}
-
-func criteriaTypeToDto(t match.Type) gen.WorkflowCriteriaType {
-	switch t {
-	case match.Equals:
-		return gen.EQUALS
-	case match.NotEquals:
-		return gen.NOTEQUALS
-	case match.Matches:
-		return gen.MATCHES
-	case match.DoesNotMatch:
-		return gen.DOESNOTMATCH
-	case match.LessThan:
-		return gen.LESSTHAN
-	case match.GreaterThan:
-		return gen.GREATERTHAN
-	case match.IsPresent:
-		return gen.ISPRESENT
-	case match.IsNotPresent:
-		return gen.ISNOTPRESENT
-	}
-
-	panic("unreachable")
-}
-
 func criteriaCombineTypeToModel(combineType gen.WorkflowCriteriaCombineType) match.CombineType {
 	switch combineType {
 	case gen.AND:
@@ -100,24 +21,26 @@ func criteriaCombineTypeToModel(combineType gen.WorkflowCriteriaCombineType) mat
 
 func criteriaKeyToModel(key gen.WorkflowCriteriaKey) match.Key {
 	switch key {
-	case gen.MEDIATITLE:
+	case gen.WorkflowCriteriaKeyMEDIATITLE:
 		return match.MediaTitleKey
-	case gen.SERIESTITLE:
+	case gen.WorkflowCriteriaKeySERIESTITLE:
 		return match.SeriesTitleKey
-	case gen.SEASONTITLE:
+	case gen.WorkflowCriteriaKeySEASONTITLE:
 		return match.SeasonTitleKey
-	case gen.RESOLUTION:
+	case gen.WorkflowCriteriaKeyRESOLUTION:
 		return match.ResolutionKey
-	case gen.SEASONNUMBER:
+	case gen.WorkflowCriteriaKeySEASONNUMBER:
 		return match.SeasonNumberKey
-	case gen.EPISODENUMBER:
+	case gen.WorkflowCriteriaKeyEPISODENUMBER:
 		return match.EpisodeNumberKey
-	case gen.SOURCEPATH:
+	case gen.WorkflowCriteriaKeySOURCEPATH:
 		return match.SourcePathKey
-	case gen.SOURCENAME:
+	case gen.WorkflowCriteriaKeySOURCENAME:
 		return match.SourceNameKey
-	case gen.SOURCEEXTENSION:
+	case gen.WorkflowCriteriaKeySOURCEEXTENSION:
 		return match.SourceExtensionKey
+	case gen.WorkflowCriteriaKeyTAG:
+		return match.TagKey
 	}
 
 	panic("unreachable")
@@ -156,4 +79,10 @@ func criteriaToModel(dto gen.WorkflowCriteria) match.Criteria {
 	}
 }
 
-func getTargetID(target *ffmpeg.Target) uuid.UUID { return target.ID }
+func hookToModel(dto gen.WorkflowHook) workflow.Hook {
+	return workflow.Hook{
+		Command:        dto.Command,
+		Args:           util.NotNilOrDefault(dto.Args, []string{}),
+		TimeoutSeconds: util.NotNilOrDefault(dto.TimeoutSeconds, 0),
+	}
+}
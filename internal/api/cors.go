@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// CORSConfig configures the cross-origin requests the API permits, allowing a
+// client hosted on a different origin (e.g. a separately deployed web UI) to
+// use the API. If no origins are configured, cross-origin requests are not permitted.
+type CORSConfig struct {
+	AllowedOrigins []string `toml:"allowed_origins" env:"API_CORS_ALLOWED_ORIGINS" env-separator:","`
+
+	// AllowCredentials permits cross-origin requests to include cookies, which
+	// is required for the client to authenticate.
+	AllowCredentials bool `toml:"allow_credentials" env:"API_CORS_ALLOW_CREDENTIALS" env-default:"false"`
+}
+
+func (config *CORSConfig) Validate() error {
+	if config.AllowCredentials && slices.Contains(config.AllowedOrigins, "*") {
+		return errors.New("cors.allowed_origins: wildcard origin cannot be used when allow_credentials is enabled")
+	}
+
+	return nil
+}
+
+// newCORSMiddleware returns the CORS middleware for the configuration provided. If no
+// origins are permitted, the middleware does nothing (and so browsers will refuse
+// cross-origin requests).
+func newCORSMiddleware(config CORSConfig) echo.MiddlewareFunc {
+	if len(config.AllowedOrigins) == 0 {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins:     config.AllowedOrigins,
+		AllowCredentials: config.AllowCredentials,
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept},
+		AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		ExposeHeaders:    []string{echo.HeaderRetryAfter, headerRateLimitLimit, headerRateLimitRemaining, echo.HeaderXRequestID},
+	})
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	// runtimeStats is a snapshot of the Go runtime statistics, returned
+	// by the debug runtime endpoint.
+	runtimeStats struct {
+		GoVersion      string    `json:"go_version"`
+		NumCPU         int       `json:"num_cpu"`
+		Goroutines     int       `json:"goroutines"`
+		HeapAlloc      uint64    `json:"heap_alloc_bytes"`
+		HeapSys        uint64    `json:"heap_sys_bytes"`
+		HeapObjects    uint64    `json:"heap_objects"`
+		TotalAlloc     uint64    `json:"total_alloc_bytes"`
+		Sys            uint64    `json:"sys_bytes"`
+		NumGC          uint32    `json:"num_gc"`
+		LastGC         time.Time `json:"last_gc"`
+		PauseTotal     int64     `json:"gc_pause_total_ns"`
+		GCCPUFraction  float64   `json:"gc_cpu_fraction"`
+		NextGCHeapSize uint64    `json:"next_gc_bytes"`
+	}
+
+	requestAuthProvider interface {
+		ValidateTokenFromRequest(ec echo.Context, request *http.Request) (*jwt.AuthenticatedUser, error)
+	}
+)
+
+// registerDebugRoutes registers the pprof handlers and a runtime statistics endpoint
+// under the path provided. Like the activity socket, these endpoints are not documented
+// in the OpenAPI spec, so the authentication and authorization (the user
+// must have the debug:read permission) is performed manually.
+func registerDebugRoutes(ec *echo.Echo, path string, authProvider requestAuthProvider) {
+	group := ec.Group(path, newDebugPermissionMiddleware(authProvider))
+	group.GET("/runtime", getRuntimeStats)
+
+	group.GET("/pprof", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	group.GET("/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	group.GET("/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	group.GET("/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	group.POST("/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	group.GET("/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+
+	// The pprof index handler is only able to resolve named profiles (e.g. heap, goroutine) when
+	// mounted at /debug/pprof, so we resolve the named profile handler ourselves.
+	group.GET("/pprof/:profile", func(ec echo.Context) error {
+		pprof.Handler(ec.Param("profile")).ServeHTTP(ec.Response(), ec.Request())
+		return nil
+	})
+
+	log.Warnf("Debug endpoints are enabled at %s, these should not be exposed publicly\n", path)
+}
+
+// newDebugPermissionMiddleware returns a middleware which rejects requests
+// from users which are not authenticated, or which do not have the debug:read permission.
+func newDebugPermissionMiddleware(authProvider requestAuthProvider) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ec echo.Context) error {
+			user, err := authProvider.ValidateTokenFromRequest(ec, ec.Request())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized).SetInternal(err)
+			}
+
+			if !slices.Contains(user.Permissions, permissions.ReadDebugPermission) {
+				log.Warnf("User %s failed permissions check while accessing %s: missing permission '%s'\n", user.UserID, ec.Request().RequestURI, permissions.ReadDebugPermission)
+				return echo.NewHTTPError(http.StatusForbidden).SetInternal(jwt.ErrInsufficientPermissions)
+			}
+
+			return next(ec)
+		}
+	}
+}
+
+func getRuntimeStats(ec echo.Context) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := runtimeStats{
+		GoVersion:      runtime.Version(),
+		NumCPU:         runtime.NumCPU(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAlloc:      mem.HeapAlloc,
+		HeapSys:        mem.HeapSys,
+		HeapObjects:    mem.HeapObjects,
+		TotalAlloc:     mem.TotalAlloc,
+		Sys:            mem.Sys,
+		NumGC:          mem.NumGC,
+		LastGC:         time.Unix(0, int64(mem.LastGC)), //nolint:gosec
+		PauseTotal:     int64(mem.PauseTotalNs),         //nolint:gosec
+		GCCPUFraction:  mem.GCCPUFraction,
+		NextGCHeapSize: mem.NextGC,
+	}
+
+	return ec.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,19 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/audit"
+)
+
+func FromAuditEntry(entry *audit.Entry) gen.AuditEntry {
+	return gen.AuditEntry{
+		Id:         entry.ID,
+		CreatedAt:  entry.CreatedAt,
+		UserId:     entry.UserID,
+		Action:     entry.Action,
+		Method:     entry.Method,
+		Path:       entry.Path,
+		RequestId:  entry.RequestID,
+		ResourceId: entry.ResourceID,
+	}
+}
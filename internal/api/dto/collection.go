@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+// FromCollection converts the collection model to a DTO, without it's members.
+func FromCollection(collection *media.Collection) gen.Collection {
+	return gen.Collection{
+		Id:          collection.ID,
+		Title:       collection.Title,
+		Description: collection.Description,
+		TmdbId:      collection.TmdbID,
+		CreatedAt:   collection.CreatedAt,
+		UpdatedAt:   collection.UpdatedAt,
+	}
+}
+
+// FromInflatedCollection converts the collection model to a DTO, including it's members.
+func FromInflatedCollection(collection *media.InflatedCollection) gen.Collection {
+	dto := FromCollection(collection.Collection)
+	items := util.ApplyConversion(collection.Items, FromCollectionItem)
+	dto.Items = &items
+
+	return dto
+}
+
+func FromCollectionItem(item *media.CollectionItem) gen.CollectionItem {
+	return gen.CollectionItem{MediaId: item.MediaID, Type: item.Type, Title: item.Title}
+}
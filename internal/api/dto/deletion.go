@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/deletion"
+)
+
+func FromBulkDeletion(job *deletion.Job, progress deletion.JobProgress) gen.BulkDeletion {
+	items := make([]gen.BulkDeletionItem, len(job.Items))
+	for k, item := range job.Items {
+		items[k] = gen.BulkDeletionItem{
+			MediaId:          item.ID,
+			Type:             gen.BulkDeletionItemType(strings.ToUpper(item.Type)),
+			Title:            item.Title,
+			SeriesTitle:      item.SeriesTitle,
+			SeasonNumber:     item.SeasonNumber,
+			EpisodeNumber:    item.EpisodeNumber,
+			CreatedAt:        item.CreatedAt,
+			FrameHeight:      item.FrameHeight,
+			TranscodeCount:   item.TranscodeCount,
+			ReclaimableBytes: item.Size(job.DeleteSources),
+		}
+	}
+
+	return gen.BulkDeletion{
+		Id:                job.ID,
+		State:             gen.BulkDeletionState(progress.State),
+		CreatedAt:         job.CreatedAt,
+		ExpiresAt:         job.ExpiresAt(),
+		DeleteSourceFiles: job.DeleteSources,
+		Items:             items,
+		ReclaimableBytes:  job.ReclaimableBytes(),
+		Deleted:           progress.Deleted,
+		Failed:            progress.Failed,
+		ReclaimedBytes:    progress.ReclaimedBytes,
+		StartedAt:         progress.StartedAt,
+		CompletedAt:       progress.CompletedAt,
+	}
+}
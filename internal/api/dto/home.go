@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+func FromHomeFeed(feed *media.HomeFeed) gen.HomeFeed {
+	return gen.HomeFeed{
+		RecentlyAdded:    util.ApplyConversion(feed.RecentlyAdded, FromHomeFeedItem),
+		ContinueWatching: util.ApplyConversion(feed.ContinueWatching, FromHomeFeedItem),
+		NextUp:           util.ApplyConversion(feed.NextUp, FromHomeFeedItem),
+		RecentTranscodes: util.ApplyConversion(feed.RecentTranscodes, FromHomeFeedItem),
+	}
+}
+
+func FromHomeFeedItem(item *media.HomeFeedItem) gen.HomeFeedItem {
+	return gen.HomeFeedItem{
+		MediaId:         item.MediaID,
+		Type:            gen.HomeFeedItemType(strings.ToUpper(item.Type)),
+		Title:           item.Title,
+		CreatedAt:       item.CreatedAt,
+		SeriesId:        item.SeriesID,
+		SeriesTitle:     item.SeriesTitle,
+		SeasonNumber:    item.SeasonNumber,
+		EpisodeNumber:   item.EpisodeNumber,
+		PositionSeconds: item.PositionSeconds,
+		Completed:       item.Completed,
+		WatchedAt:       item.WatchedAt,
+		TargetId:        item.TargetID,
+		TranscodedAt:    item.TranscodedAt,
+	}
+}
@@ -0,0 +1,307 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type TmdbChoiceDTO struct {
+	TmdbID     json.Number `json:"tmdb_id"`
+	Adult      bool        `json:"is_adult"`
+	Title      string      `json:"name"`
+	Plot       string      `json:"overview"`
+	PosterPath string      `json:"poster_url_path"`
+}
+
+var log = logger.Get("DTO")
+
+// FromIngest creates an Ingest DTO using the IngestItem model.
+func FromIngest(item *ingest.IngestItem) gen.Ingest {
+	out := gen.Ingest{
+		Id:       item.ID,
+		Path:     item.Path,
+		State:    FromIngestState(item.State),
+		Trouble:  fromIngestTrouble(item.Trouble),
+		Metadata: scrapedMetadataToDto(item.ScrapedMetadata),
+		Stages:   util.ApplyConversion(item.Stages, fromStageTiming),
+		Priority: item.Priority,
+		Wanted:   item.Wanted,
+		InfoHash: item.InfoHash,
+		GroupId:  item.GroupID,
+		RetryAt:  item.RetryAt,
+	}
+	if stage := item.CurrentStage(); stage != nil {
+		dtoStage := FromIngestStage(*stage)
+		out.Stage = &dtoStage
+	}
+	if item.Quarantine != nil {
+		out.Quarantine = &gen.IngestQuarantine{Reason: item.Quarantine.Reason, QuarantinedAt: item.Quarantine.QuarantinedAt}
+	}
+
+	return out
+}
+
+// FromIngestGroup creates an IngestGroup DTO using the IngestGroup model, and the items in the group.
+func FromIngestGroup(group *ingest.IngestGroup, items []*ingest.IngestItem) gen.IngestGroup {
+	out := gen.IngestGroup{
+		Id:           group.ID,
+		Title:        group.Title,
+		SeasonNumber: group.SeasonNumber,
+		IngestIds:    make([]uuid.UUID, len(items)),
+	}
+	if group.Year != 0 {
+		out.Year = &group.Year
+	}
+	for k, item := range items {
+		out.IngestIds[k] = item.ID
+		if item.State == ingest.Troubled {
+			out.TroubledCount++
+		}
+	}
+
+	return out
+}
+
+// FromIngestStageMetrics creates an IngestStageMetrics DTO using the StageMetrics model.
+func FromIngestStageMetrics(metrics ingest.StageMetrics) gen.IngestStageMetrics {
+	var average int64
+	if metrics.Runs > 0 {
+		average = metrics.TotalDuration.Milliseconds() / metrics.Runs
+	}
+
+	return gen.IngestStageMetrics{
+		Stage:             FromIngestStage(metrics.Stage),
+		Runs:              metrics.Runs,
+		Failures:          metrics.Failures,
+		TotalDurationMs:   metrics.TotalDuration.Milliseconds(),
+		AverageDurationMs: average,
+		MaxDurationMs:     metrics.MaxDuration.Milliseconds(),
+	}
+}
+
+func fromStageTiming(timing ingest.StageTiming) gen.IngestStageTiming {
+	return gen.IngestStageTiming{
+		Stage:       FromIngestStage(timing.Stage),
+		StartedAt:   timing.StartedAt,
+		CompletedAt: timing.CompletedAt,
+		DurationMs:  timing.Duration().Milliseconds(),
+	}
+}
+
+// FromIngestDryRun creates an IngestDryRun DTO using the dry run provided, and the
+// workflows which the media matched is eligible for.
+func FromIngestDryRun(result *ingest.DryRun, eligible []*workflow.Workflow) gen.IngestDryRun {
+	out := gen.IngestDryRun{
+		Path:             result.Path,
+		Metadata:         scrapedMetadataToDto(result.Metadata),
+		Trouble:          fromIngestTrouble(result.Trouble),
+		DuplicateMediaId: result.Duplicate,
+		Workflows:        util.ApplyConversion(eligible, FromWorkflow),
+		Targets:          make([]gen.Target, 0),
+	}
+	if len(eligible) > 0 {
+		out.Targets = util.ApplyConversion(eligible[0].Targets, FromTarget)
+	}
+
+	if container := result.Media; container != nil {
+		out.Media = &gen.DryRunMedia{Type: "MOVIE", TmdbId: container.TmdbID(), Title: container.Title()}
+		if container.Type == media.EpisodeContainerType {
+			season, episode := container.SeasonNumber(), container.EpisodeNumber()
+			out.Media.Type = "EPISODE"
+			out.Media.SeriesTitle = &container.Series.Title
+			out.Media.SeasonNumber = &season
+			out.Media.EpisodeNumber = &episode
+		}
+	}
+
+	return out
+}
+
+func fromIngestTrouble(trouble *ingest.Trouble) *gen.IngestTrouble {
+	if trouble == nil {
+		return nil
+	}
+
+	context, err := extractTroubleContext(trouble)
+	if err != nil {
+		context = map[string]any{
+			"_error": "Context for this trouble may be missing. Consult server logs for more information",
+		}
+		log.Emit(logger.ERROR, "Error whilst creating DTO of ingestion trouble: %v\n", err)
+	}
+
+	return &gen.IngestTrouble{
+		Type:                   FromTroubleType(trouble.Type()),
+		Message:                trouble.Error(),
+		Context:                context,
+		AllowedResolutionTypes: util.ApplyConversion(trouble.AllowedResolutionTypes(), FromResolutionType),
+	}
+}
+
+func extractTroubleContext(trouble *ingest.Trouble) (map[string]any, error) {
+	//exhaustive:ignore
+	switch trouble.Type() {
+	case ingest.TmdbFailureMultipleResults:
+		// Return a context which contains the choices we could make. The client will be expected
+		// to use the unique TMDB ID of the choice when resolving this trouble.
+		modelChoices := trouble.GetTmdbChoices()
+		if modelChoices == nil {
+			return nil, fmt.Errorf("failed to extract trouble context for %w. Type mandates presence of context which is not present, resulting trouble context will be missing expected information", trouble)
+		}
+		dtoChoices := make([]TmdbChoiceDTO, 0)
+		for _, v := range trouble.GetTmdbChoices() {
+			dtoChoices = append(dtoChoices, TmdbChoiceDTO{TmdbID: v.ID, Adult: v.Adult, Title: v.Title, Plot: v.Plot, PosterPath: v.PosterPath})
+		}
+
+		context := map[string]any{"choices": dtoChoices}
+		return context, nil
+	case ingest.DuplicateMedia:
+		// Return a context which describes the existing media, so the client
+		// can decide whether to replace it, keep both, or abort.
+		duplicate := trouble.GetDuplicate()
+		if duplicate == nil {
+			return nil, fmt.Errorf("failed to extract trouble context for %w. Type mandates presence of context which is not present, resulting trouble context will be missing expected information", trouble)
+		}
+
+		context := map[string]any{
+			"existing_media_id":     duplicate.ExistingMediaID,
+			"existing_path":         duplicate.ExistingPath,
+			"existing_frame_width":  duplicate.ExistingResolution.Width,
+			"existing_frame_height": duplicate.ExistingResolution.Height,
+			"incoming_frame_width":  duplicate.IncomingResolution.Width,
+			"incoming_frame_height": duplicate.IncomingResolution.Height,
+		}
+		return context, nil
+	default:
+		// Only multi-choice TMDB and duplicate errors have context, all other ingestion errors are (at the moment)
+		// context-free (i.e. the message and allowed actions alone should suffice).
+		return map[string]any{}, nil
+	}
+}
+
+func scrapedMetadataToDto(metadata *media.FileMediaMetadata) *gen.FileMetadata {
+	if metadata == nil {
+		return nil
+	}
+
+	return &gen.FileMetadata{
+		EpisodeNumber: metadata.EpisodeNumber,
+		Episodic:      metadata.Episodic,
+		FrameHeight:   &metadata.FrameH,
+		FrameWidth:    &metadata.FrameW,
+		Path:          metadata.Path,
+		Runtime:       metadata.Runtime,
+		SeasonNumber:  metadata.SeasonNumber,
+		Title:         metadata.Title,
+		Year:          &metadata.Year,
+		Filename:      parsedFilenameToDto(metadata.Filename),
+	}
+}
+
+func parsedFilenameToDto(parsed *media.ParsedFilename) *gen.ParsedFilename {
+	if parsed == nil {
+		return nil
+	}
+
+	out := &gen.ParsedFilename{
+		Title:           parsed.Title,
+		Season:          parsed.Season,
+		Episodes:        parsed.Episodes,
+		AbsoluteEpisode: parsed.AbsoluteEpisode,
+		Tags:            parsed.Tags,
+	}
+	if parsed.Year != 0 {
+		out.Year = &parsed.Year
+	}
+	if parsed.ReleaseGroup != "" {
+		out.ReleaseGroup = &parsed.ReleaseGroup
+	}
+
+	return out
+}
+
+func FromResolutionType(model ingest.ResolutionType) gen.IngestTroubleResolutionType {
+	//exhaustive:enforce
+	switch model {
+	case ingest.Abort:
+		return gen.ABORT
+	case ingest.SpecifyTmdbID:
+		return gen.SPECIFYTMDBID
+	case ingest.Retry:
+		return gen.RETRY
+	case ingest.ReplaceExisting:
+		return gen.REPLACEEXISTING
+	case ingest.KeepBoth:
+		return gen.KEEPBOTH
+	}
+
+	panic("unreachable")
+}
+
+func FromTroubleType(troubleType ingest.TroubleType) gen.IngestTroubleType {
+	//exhaustive:enforce
+	switch troubleType {
+	case ingest.MetadataFailure:
+		return gen.METADATAFAILURE
+	case ingest.TmdbFailureUnknown:
+		return gen.TMDBFAILUREUNKNOWN
+	case ingest.TmdbFailureNoResults:
+		return gen.TMDBFAILURENORESULT
+	case ingest.TmdbFailureMultipleResults:
+		return gen.TMDBFAILUREMULTIRESULT
+	case ingest.UnknownFailure:
+		return gen.UNKNOWNFAILURE
+	case ingest.DuplicateMedia:
+		return gen.DUPLICATEMEDIA
+	case ingest.UnmonitoredSeries:
+		return gen.UNMONITOREDSERIES
+	}
+
+	panic("unreachable")
+}
+
+func FromIngestState(modelType ingest.IngestItemState) gen.IngestState {
+	//exhaustive:enforce
+	switch modelType {
+	case ingest.Idle:
+		return gen.IngestStateIDLE
+	case ingest.ImportHold:
+		return gen.IngestStateIMPORTHOLD
+	case ingest.Ingesting:
+		return gen.IngestStateINGESTING
+	case ingest.Troubled:
+		return gen.IngestStateTROUBLED
+	case ingest.Complete:
+		return gen.IngestStateCOMPLETE
+	case ingest.Paused:
+		return gen.IngestStatePAUSED
+	case ingest.Quarantined:
+		return gen.IngestStateQUARANTINED
+	case ingest.RetryLater:
+		return gen.IngestStateRETRYLATER
+	}
+
+	panic("unreachable")
+}
+
+func FromIngestStage(stage ingest.IngestStage) gen.IngestStage {
+	//exhaustive:enforce
+	switch stage {
+	case ingest.Scraping:
+		return gen.IngestStage("SCRAPING")
+	case ingest.Searching:
+		return gen.IngestStage("SEARCHING")
+	case ingest.Persisting:
+		return gen.IngestStage("PERSISTING")
+	}
+
+	panic("unreachable")
+}
@@ -0,0 +1,21 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/user"
+)
+
+// FromInvite converts the invite model to a DTO. The token of the invite
+// is never populated, as only it's hash is known after creation.
+func FromInvite(invite *user.Invite) gen.Invite {
+	return gen.Invite{
+		Id:          invite.ID,
+		CreatedAt:   invite.CreatedAt,
+		ExpiresAt:   invite.ExpiresAt,
+		ConsumedAt:  invite.ConsumedAt,
+		CreatedBy:   invite.CreatedBy,
+		ConsumedBy:  invite.ConsumedBy,
+		Permissions: invite.Permissions,
+		RoleIds:     invite.Roles,
+	}
+}
@@ -0,0 +1,17 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/library"
+)
+
+func FromLibrary(lib *library.Library) gen.Library {
+	return gen.Library{
+		Id:              lib.ID,
+		Label:           lib.Label,
+		IngestPath:      lib.IngestPath,
+		OutputDirectory: lib.OutputDirectory,
+		CreatedAt:       lib.CreatedAt,
+		UpdatedAt:       lib.UpdatedAt,
+	}
+}
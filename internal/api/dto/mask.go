@@ -0,0 +1,80 @@
+// Package dto is the single home for converting Thea's internal models in to the
+// DTOs exposed by the API (as defined by the OpenAPI spec). Controllers and the
+// activity broadcaster should use the mappings defined here rather than constructing
+// response structs themselves, so that field-level permission masking is applied
+// consistently regardless of where a model is being returned from.
+package dto
+
+import (
+	"slices"
+
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/labstack/echo/v4"
+)
+
+// Field identifies a DTO field which is subject to masking. A masked
+// field is only populated in the resulting DTO if the caller holds
+// all the permissions declared for it in fieldPermissions.
+type Field int
+
+const (
+	MediaSourcePathField Field = iota
+)
+
+// fieldPermissions declares the permissions a caller must hold in order
+// to see each masked field. Fields which are not present in this mapping
+// are never visible.
+var fieldPermissions = map[Field][]string{
+	MediaSourcePathField: {permissions.StreamSourceMediaPermission},
+}
+
+// Mask is constructed using the permissions of the caller, and is
+// passed to the DTO mappings which contain masked fields.
+type Mask struct {
+	perms []string
+}
+
+// NewMask creates a mask which exposes only the fields which the
+// given permissions satisfy.
+func NewMask(perms []string) Mask {
+	return Mask{perms: perms}
+}
+
+// MaskFromContext creates a mask using the permissions of the user
+// authenticated for the given request. If no user is present in the request
+// context then the returned mask will hide all masked fields.
+func MaskFromContext(ec echo.Context) Mask {
+	if user, ok := ec.Get("user").(*jwt.AuthenticatedUser); ok {
+		return NewMask(user.Permissions)
+	}
+
+	return NewMask(nil)
+}
+
+// Allows returns true if the field provided should be visible
+// to the caller this mask was created for.
+func (mask Mask) Allows(field Field) bool {
+	required, ok := fieldPermissions[field]
+	if !ok {
+		return false
+	}
+
+	for _, perm := range required {
+		if !slices.Contains(mask.perms, perm) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// maskValue returns a pointer to the value provided if the mask allows
+// the field, otherwise nil is returned.
+func maskValue[T any](mask Mask, field Field, value T) *T {
+	if !mask.Allows(field) {
+		return nil
+	}
+
+	return &value
+}
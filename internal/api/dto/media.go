@@ -0,0 +1,240 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+// FromMovie converts the movie model to a DTO. The source path of the movie (and
+// of it's versions) is masked using the MediaSourcePathField.
+func FromMovie(mask Mask, movie *media.Movie, versions []*media.Version, watchTargets []gen.MediaWatchTarget) gen.Movie {
+	return gen.Movie{
+		Id:           movie.ID,
+		TmdbId:       movie.TmdbID,
+		Title:        movie.Title,
+		Overview:     movie.Overview,
+		CreatedAt:    movie.CreatedAt,
+		UpdatedAt:    movie.UpdatedAt,
+		WatchTargets: watchTargets,
+		SourcePath:   maskValue(mask, MediaSourcePathField, movie.SourcePath),
+		Versions:     fromMediaVersions(mask, versions),
+		DegradedAt:   movie.DegradedAt,
+		CorruptedAt:  movie.CorruptedAt,
+		LockedFields: FromMetadataFields(movie.LockedFields),
+		Unverified:   movie.IsUnverified(),
+	}
+}
+
+// FromEpisode converts the episode model to a DTO. The source path of the episode (and
+// of it's versions) is masked using the MediaSourcePathField.
+func FromEpisode(mask Mask, episode *media.Episode, versions []*media.Version, watchTargets []gen.MediaWatchTarget) gen.Episode {
+	return gen.Episode{
+		Id:           episode.ID,
+		TmdbId:       episode.TmdbID,
+		Title:        episode.Title,
+		Overview:     episode.Overview,
+		CreatedAt:    episode.CreatedAt,
+		UpdatedAt:    episode.UpdatedAt,
+		WatchTargets: watchTargets,
+		SourcePath:   maskValue(mask, MediaSourcePathField, episode.SourcePath),
+		Versions:     fromMediaVersions(mask, versions),
+		DegradedAt:   episode.DegradedAt,
+		CorruptedAt:  episode.CorruptedAt,
+		LockedFields: FromMetadataFields(episode.LockedFields),
+		Unverified:   episode.IsUnverified(),
+	}
+}
+
+// FromMediaVersion converts the version model to a DTO. The source path of the
+// version is masked using the MediaSourcePathField.
+func FromMediaVersion(mask Mask, version *media.Version) gen.MediaVersion {
+	return gen.MediaVersion{
+		Id:          version.ID,
+		Label:       version.Label,
+		FrameWidth:  version.Width,
+		FrameHeight: version.Height,
+		VideoCodec:  version.VideoCodec,
+		SourceSize:  version.SourceSize,
+		SourcePath:  maskValue(mask, MediaSourcePathField, version.SourcePath),
+	}
+}
+
+func fromMediaVersions(mask Mask, versions []*media.Version) *[]gen.MediaVersion {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	dtos := util.ApplyConversion(versions, func(v *media.Version) gen.MediaVersion { return FromMediaVersion(mask, v) })
+	return &dtos
+}
+
+func FromEpisodeStub(episode *media.Episode) gen.EpisodeStub {
+	return gen.EpisodeStub{Adult: episode.Adult, Id: episode.ID, Title: episode.Title}
+}
+
+func FromInflatedSeason(season *media.InflatedSeason) gen.Season {
+	return gen.Season{Episodes: util.ApplyConversion(season.Episodes, FromEpisodeStub)}
+}
+
+func FromInflatedSeries(series *media.InflatedSeries) gen.Series {
+	dto := gen.Series{
+		Id:           series.ID,
+		Seasons:      util.ApplyConversion(series.Seasons, FromInflatedSeason),
+		Title:        series.Title,
+		Overview:     series.Overview,
+		TmdbId:       series.TmdbID,
+		Monitored:    series.Monitored,
+		LockedFields: FromMetadataFields(series.LockedFields),
+		Unverified:   series.IsUnverified(),
+	}
+	if series.MissingEpisodes != nil {
+		missing := util.ApplyConversion(series.MissingEpisodes, FromMissingEpisode)
+		dto.MissingEpisodes = &missing
+	}
+
+	return dto
+}
+
+func FromMissingEpisode(episode *media.CatalogEpisode) gen.MissingEpisode {
+	return gen.MissingEpisode{
+		SeasonNumber:  episode.SeasonNumber,
+		EpisodeNumber: episode.EpisodeNumber,
+		Title:         episode.Title,
+		AirDate:       episode.AirDate,
+	}
+}
+
+func FromRelatedItems(items []*media.RelatedItem) []gen.RelatedMedia {
+	return util.ApplyConversion(items, func(item *media.RelatedItem) gen.RelatedMedia {
+		return gen.RelatedMedia{
+			Id:       item.ID,
+			Type:     gen.RelatedMediaType(strings.ToUpper(string(item.Type))),
+			TmdbId:   item.TmdbID,
+			Title:    item.Title,
+			Relation: gen.RelatedMediaRelation(strings.ToUpper(string(item.Relation))),
+		}
+	})
+}
+
+func FromGenres(genres []*media.Genre) []gen.MediaGenre {
+	return util.ApplyConversion(genres, func(genre *media.Genre) gen.MediaGenre {
+		return gen.MediaGenre{Id: fmt.Sprint(genre.ID), Label: genre.Label}
+	})
+}
+
+// FromMediaListResults converts the media list results to DTOs. An error is returned
+// if any of the results are neither a movie or a series.
+func FromMediaListResults(results []*media.MediaListResult) ([]gen.MediaListItem, error) {
+	dtos := make([]gen.MediaListItem, len(results))
+	for k, v := range results {
+		dto, err := FromMediaListResult(v)
+		if err != nil {
+			return nil, err
+		}
+		dtos[k] = *dto
+	}
+
+	return dtos, nil
+}
+
+func FromMediaListResult(result *media.MediaListResult) (*gen.MediaListItem, error) {
+	if result.IsMovie() {
+		movie := result.Movie
+		return &gen.MediaListItem{
+			Type:        gen.MediaListItemTypeMOVIE,
+			Id:          movie.ID,
+			Title:       movie.Title,
+			TmdbId:      movie.TmdbID,
+			UpdatedAt:   movie.UpdatedAt,
+			SeasonCount: nil,
+			Genres:      FromGenres(movie.Genres),
+		}, nil
+	} else if result.IsSeries() {
+		series := result.Series
+		return &gen.MediaListItem{
+			Type:        gen.MediaListItemTypeSERIES,
+			Id:          series.ID,
+			Title:       series.Title,
+			TmdbId:      series.TmdbID,
+			UpdatedAt:   series.UpdatedAt,
+			SeasonCount: &series.SeasonCount,
+			Genres:      FromGenres(series.Genres),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("media %v found during listing has an illegal type. Expected movie or series", result)
+}
+
+var trashedMediaTypeMapping = map[media.TrashedItemType]gen.TrashedMediaType{
+	media.TrashedMovie:   gen.TrashedMediaTypeMOVIE,
+	media.TrashedSeries:  gen.TrashedMediaTypeSERIES,
+	media.TrashedSeason:  gen.TrashedMediaTypeSEASON,
+	media.TrashedEpisode: gen.TrashedMediaTypeEPISODE,
+}
+
+func FromTrashedItem(item *media.TrashedItem) gen.TrashedMedia {
+	return gen.TrashedMedia{
+		Id:        item.ID,
+		Type:      trashedMediaTypeMapping[item.Type],
+		Title:     item.Title,
+		DeletedAt: item.DeletedAt,
+	}
+}
+
+func FromMetadataItem(item *media.MetadataItem) gen.MediaMetadata {
+	return gen.MediaMetadata{
+		Id:           item.ID,
+		Title:        item.Title,
+		Overview:     item.Overview,
+		LockedFields: FromMetadataFields(item.LockedFields),
+	}
+}
+
+func FromMetadataFields(fields []string) []gen.MediaMetadataField {
+	return util.ApplyConversion(fields, func(field string) gen.MediaMetadataField {
+		return FromMetadataField(media.MetadataField(field))
+	})
+}
+
+func FromMetadataField(field media.MetadataField) gen.MediaMetadataField {
+	//exhaustive:enforce
+	switch field {
+	case media.TitleField:
+		return gen.MediaMetadataField("TITLE")
+	case media.OverviewField:
+		return gen.MediaMetadataField("OVERVIEW")
+	case media.AdultField:
+		return gen.MediaMetadataField("ADULT")
+	case media.GenresField:
+		return gen.MediaMetadataField("GENRES")
+	case media.SeasonNumberField:
+		return gen.MediaMetadataField("SEASON_NUMBER")
+	case media.EpisodeNumberField:
+		return gen.MediaMetadataField("EPISODE_NUMBER")
+	}
+
+	panic("unreachable")
+}
+
+// NewWatchTarget creates a watch target DTO for the given transcode target.
+func NewWatchTarget(target *ffmpeg.Target, t gen.MediaWatchTargetType, ready bool) gen.MediaWatchTarget {
+	return gen.MediaWatchTarget{DisplayName: target.Label, Ready: ready, Type: t, TargetId: &target.ID, Enabled: true}
+}
+
+// NewVersionWatchTarget creates a watch target DTO for the given transcode target, applied
+// to the version of the media provided. If the version is nil, the watch target is for the
+// primary source of the media (see NewWatchTarget).
+func NewVersionWatchTarget(target *ffmpeg.Target, version *media.Version, t gen.MediaWatchTargetType, ready bool) gen.MediaWatchTarget {
+	watchTarget := NewWatchTarget(target, t, ready)
+	if version != nil {
+		watchTarget.DisplayName = fmt.Sprintf("%s (%s)", target.Label, version.Label)
+		watchTarget.VersionId = &version.ID
+	}
+
+	return watchTarget
+}
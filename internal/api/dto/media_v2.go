@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"fmt"
+
+	genv2 "github.com/hbomb79/Thea/internal/api/gen/v2"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+// FromMediaListResultsV2 converts the media list results to version 2 DTOs. An error
+// is returned if any of the results are neither a movie or a series.
+func FromMediaListResultsV2(results []*media.MediaListResult) ([]genv2.MediaListItem, error) {
+	dtos := make([]genv2.MediaListItem, len(results))
+	for k, v := range results {
+		dto, err := FromMediaListResultV2(v)
+		if err != nil {
+			return nil, err
+		}
+		dtos[k] = *dto
+	}
+
+	return dtos, nil
+}
+
+func FromMediaListResultV2(result *media.MediaListResult) (*genv2.MediaListItem, error) {
+	if result.IsMovie() {
+		movie := result.Movie
+		return &genv2.MediaListItem{
+			Type:      genv2.MediaListItemType("MOVIE"),
+			Id:        movie.ID,
+			Title:     movie.Title,
+			TmdbId:    movie.TmdbID,
+			CreatedAt: movie.CreatedAt,
+			UpdatedAt: movie.UpdatedAt,
+			Genres:    FromGenresV2(movie.Genres),
+		}, nil
+	} else if result.IsSeries() {
+		series := result.Series
+		return &genv2.MediaListItem{
+			Type:        genv2.MediaListItemType("SERIES"),
+			Id:          series.ID,
+			Title:       series.Title,
+			TmdbId:      series.TmdbID,
+			CreatedAt:   series.CreatedAt,
+			UpdatedAt:   series.UpdatedAt,
+			SeasonCount: &series.SeasonCount,
+			Genres:      FromGenresV2(series.Genres),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("media %v found during listing has an illegal type. Expected movie or series", result)
+}
+
+// FromGenresV2 converts the genres to version 2 DTOs, which (unlike version 1)
+// represent the ID of the genre as an integer.
+func FromGenresV2(genres []*media.Genre) []genv2.MediaGenre {
+	return util.ApplyConversion(genres, func(genre *media.Genre) genv2.MediaGenre {
+		return genv2.MediaGenre{Id: genre.ID, Label: genre.Label}
+	})
+}
@@ -0,0 +1,16 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/preferences"
+)
+
+func FromPlaybackPreferences(prefs *preferences.Preferences) gen.PlaybackPreferences {
+	return gen.PlaybackPreferences{
+		AudioLanguage:       prefs.AudioLanguage,
+		SubtitleLanguage:    prefs.SubtitleLanguage,
+		MaxBitrateKbps:      prefs.MaxBitrateKbps,
+		AutoplayNextEpisode: prefs.AutoplayNextEpisode,
+		UpdatedAt:           prefs.UpdatedAt,
+	}
+}
@@ -0,0 +1,20 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/quota"
+)
+
+func FromQuotaUsage(usage *quota.Usage) gen.UserQuota {
+	return gen.UserQuota{
+		MaxConcurrentTranscodes:       usage.Quota.MaxConcurrentTranscodes,
+		MaxDailyTranscodes:            usage.Quota.MaxDailyTranscodes,
+		ActiveTranscodes:              usage.ActiveTranscodes,
+		RequestedTranscodes:           usage.RequestedTranscodes,
+		RemainingConcurrentTranscodes: usage.RemainingConcurrentTranscodes(),
+		RemainingDailyTranscodes:      usage.RemainingDailyTranscodes(),
+		MaxConcurrentSessions:         usage.Quota.MaxConcurrentSessions,
+		ActiveSessions:                usage.ActiveSessions,
+		RemainingConcurrentSessions:   usage.RemainingConcurrentSessions(),
+	}
+}
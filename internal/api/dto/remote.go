@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/remote"
+)
+
+// FromRemoteSource converts the source provided to it's DTO. The
+// password of the source is omitted, only it's presence is exposed.
+func FromRemoteSource(source *remote.Source) gen.RemoteSource {
+	out := gen.RemoteSource{
+		Id:                  source.ID,
+		Label:               source.Label,
+		Protocol:            FromRemoteSourceProtocol(source.Protocol),
+		Url:                 source.URL,
+		Username:            source.Username,
+		HasPassword:         len(source.EncryptedPassword) > 0,
+		SyncIntervalSeconds: source.SyncIntervalSeconds,
+		Enabled:             source.Enabled,
+		LastSyncedAt:        source.LastSyncedAt,
+		LastError:           source.LastError,
+		CreatedAt:           source.CreatedAt,
+		UpdatedAt:           source.UpdatedAt,
+	}
+	if source.HostKeyFingerprint != "" {
+		out.HostKeyFingerprint = &source.HostKeyFingerprint
+	}
+
+	return out
+}
+
+func FromRemoteSourceProtocol(protocol remote.Protocol) gen.RemoteSourceProtocol {
+	//exhaustive:enforce
+	switch protocol {
+	case remote.SFTP:
+		return gen.RemoteSourceProtocol("SFTP")
+	case remote.SMB:
+		return gen.RemoteSourceProtocol("SMB")
+	case remote.WebDAV:
+		return gen.RemoteSourceProtocol("WEBDAV")
+	}
+
+	panic("unreachable")
+}
@@ -0,0 +1,16 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/user"
+)
+
+func FromRole(role *user.Role) gen.Role {
+	return gen.Role{
+		Id:          role.ID,
+		Label:       role.Label,
+		Permissions: role.Permissions,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
@@ -0,0 +1,20 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/user"
+)
+
+// FromShareLink converts the share link provided, which can be streamed from the URL given.
+func FromShareLink(link *user.ShareLink, url string) gen.ShareLink {
+	return gen.ShareLink{
+		Id:                link.ID,
+		MediaId:           link.MediaID,
+		TargetId:          link.TargetID,
+		CreatedBy:         link.CreatedBy,
+		CreatedAt:         link.CreatedAt,
+		ExpiresAt:         link.ExpiresAt,
+		PasswordProtected: link.PasswordProtected(),
+		Url:               url,
+	}
+}
@@ -0,0 +1,25 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+)
+
+func FromStatistics(mediaStats *media.Statistics, transcodeStats *transcode.Statistics) gen.LibraryStatistics {
+	return gen.LibraryStatistics{
+		MovieCount:     mediaStats.MovieCount,
+		SeriesCount:    mediaStats.SeriesCount,
+		EpisodeCount:   mediaStats.EpisodeCount,
+		SourceBytes:    mediaStats.SourceBytes,
+		TranscodeCount: transcodeStats.TranscodeCount,
+		TranscodeBytes: transcodeStats.TranscodeBytes,
+		Codecs: util.ApplyConversion(mediaStats.Codecs, func(codec *media.CodecCount) gen.CodecStatistic {
+			return gen.CodecStatistic{Codec: codec.Codec, Count: codec.Count}
+		}),
+		TranscodeHistory: util.ApplyConversion(transcodeStats.History, func(outcome *transcode.OutcomeHistory) gen.TranscodeHistoryStatistic {
+			return gen.TranscodeHistoryStatistic{Day: outcome.Day, Succeeded: outcome.Succeeded, Failed: outcome.Failed}
+		}),
+	}
+}
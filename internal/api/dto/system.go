@@ -0,0 +1,227 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/consistency"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/disk"
+	"github.com/hbomb79/Thea/internal/health"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/maintenance"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/reload"
+	"github.com/hbomb79/Thea/internal/sysinfo"
+)
+
+func FromDiskUsage(name string, usage disk.Usage) gen.DiskUsage {
+	total := int64(usage.Total)         //nolint:gosec
+	free := int64(usage.Free)           //nolint:gosec
+	available := int64(usage.Available) //nolint:gosec
+
+	return gen.DiskUsage{
+		Name:           name,
+		Path:           usage.Path,
+		TotalBytes:     &total,
+		FreeBytes:      &free,
+		AvailableBytes: &available,
+	}
+}
+
+func FromConsistencyReport(report *consistency.Report) gen.ConsistencyReport {
+	return gen.ConsistencyReport{
+		Id:                report.ID,
+		StartedAt:         report.StartedAt,
+		CompletedAt:       report.CompletedAt,
+		CheckedSources:    report.CheckedSources,
+		CheckedTranscodes: report.CheckedTranscodes,
+		MissingSources: util.ApplyConversion(report.MissingSources, func(source *consistency.MissingSource) gen.MissingSourceFile {
+			return gen.MissingSourceFile{MediaId: source.MediaID, Path: source.Path}
+		}),
+		MissingTranscodes: util.ApplyConversion(report.MissingTranscodes, func(t *consistency.MissingTranscode) gen.MissingTranscodeFile {
+			return gen.MissingTranscodeFile{TranscodeId: t.TranscodeID, MediaId: t.MediaID, Path: t.Path}
+		}),
+		OrphanedFiles:  report.OrphanedFiles,
+		RemovedOrphans: report.RemovedOrphans,
+	}
+}
+
+func FromIntegrityReport(report *consistency.IntegrityReport) gen.IntegrityReport {
+	return gen.IntegrityReport{
+		Id:          report.ID,
+		StartedAt:   report.StartedAt,
+		CompletedAt: report.CompletedAt,
+		Verified:    report.Verified,
+		Recorded:    report.Recorded,
+		Corrupted: util.ApplyConversion(report.Corrupted, func(f *consistency.CorruptFile) gen.CorruptFile {
+			return gen.CorruptFile{MediaId: f.MediaID, TranscodeId: f.TranscodeID, Path: f.Path}
+		}),
+	}
+}
+
+func FromScraperMetrics(metrics media.ScraperMetrics) gen.ScraperMetrics {
+	out := gen.ScraperMetrics{
+		Providers: util.ApplyConversion(metrics.Providers, func(provider media.ProviderMetrics) gen.ScraperProviderMetrics {
+			var average int64
+			if provider.Scrapes > 0 {
+				average = provider.TotalDuration.Milliseconds() / provider.Scrapes
+			}
+
+			return gen.ScraperProviderMetrics{
+				Name:              provider.Name,
+				Scrapes:           provider.Scrapes,
+				Failures:          provider.Failures,
+				TotalDurationMs:   provider.TotalDuration.Milliseconds(),
+				AverageDurationMs: average,
+				MaxDurationMs:     provider.MaxDuration.Milliseconds(),
+			}
+		}),
+	}
+	if metrics.Probes != nil {
+		out.Probes = &gen.ProbePoolMetrics{
+			Size:     metrics.Probes.Size,
+			Running:  metrics.Probes.Running,
+			Waiting:  metrics.Probes.Waiting,
+			Timeouts: metrics.Probes.Timeouts,
+		}
+	}
+
+	return out
+}
+
+func FromWatcherStatus(status ingest.WatcherStatus) gen.WatcherStatus {
+	out := gen.WatcherStatus{
+		Path:         status.Path,
+		Watching:     status.Watching,
+		LimitReached: status.LimitReached,
+		Events:       status.Events,
+		LastEventAt:  status.LastEventAt,
+		Since:        status.Since,
+	}
+	if status.Error != nil {
+		message := status.Error.Error()
+		out.Error = &message
+	}
+
+	return out
+}
+
+func FromConfigReloadReport(report *reload.Report) gen.ConfigReloadReport {
+	return gen.ConfigReloadReport{Applied: report.Applied, RequiresRestart: report.RequiresRestart}
+}
+
+func FromMigrationStatus(status *database.MigrationStatus) gen.MigrationStatus {
+	return gen.MigrationStatus{
+		CurrentVersion: status.Current,
+		LatestVersion:  status.Latest,
+		Migrations: util.ApplyConversion(status.Migrations, func(m database.MigrationState) gen.Migration {
+			return gen.Migration{Version: m.Version, Name: m.Name, Applied: m.AppliedAt != nil, AppliedAt: m.AppliedAt}
+		}),
+	}
+}
+
+func FromMaintenanceState(state maintenance.State) gen.MaintenanceState {
+	out := gen.MaintenanceState{Enabled: state.Enabled, Since: state.Since}
+	if state.Reason != "" {
+		out.Reason = &state.Reason
+	}
+
+	return out
+}
+
+func FromHealthReport(report *health.Report) gen.HealthReport {
+	return gen.HealthReport{
+		Ready:     report.Ready,
+		CheckedAt: report.CheckedAt,
+		Checks:    util.ApplyConversion(report.Results, FromHealthResult),
+	}
+}
+
+func FromHealthResult(result health.Result) gen.HealthCheck {
+	out := gen.HealthCheck{
+		Name:       result.Name,
+		Healthy:    result.Healthy,
+		Optional:   result.Optional,
+		DurationMs: result.Duration.Milliseconds(),
+	}
+	if result.Message != "" {
+		out.Message = &result.Message
+	}
+
+	return out
+}
+
+func FromSystemInfo(info *sysinfo.Info) gen.SystemInfo {
+	return gen.SystemInfo{
+		Version:       info.Build.Version,
+		Commit:        info.Build.Commit,
+		Modified:      info.Build.Modified,
+		BuiltAt:       info.Build.BuiltAt,
+		GoVersion:     info.Build.GoVersion,
+		Os:            info.Build.OS,
+		Arch:          info.Build.Arch,
+		StartedAt:     info.StartedAt,
+		UptimeSeconds: int64(time.Since(info.StartedAt).Seconds()),
+		Ffmpeg:        FromFfmpegInfo(info.Ffmpeg),
+		Database:      FromDatabaseInfo(info.Database),
+		Paths:         info.Paths,
+		Features:      info.Features,
+	}
+}
+
+func FromFfmpegInfo(info sysinfo.Ffmpeg) gen.FfmpegInfo {
+	out := gen.FfmpegInfo{Path: info.Path, Encoders: info.Encoders, Muxers: info.Muxers}
+	if out.Encoders == nil {
+		out.Encoders = []string{}
+	}
+	if out.Muxers == nil {
+		out.Muxers = []string{}
+	}
+	if info.Version != "" {
+		out.Version = &info.Version
+	}
+	if info.Error != nil {
+		message := info.Error.Error()
+		out.Error = &message
+	}
+
+	return out
+}
+
+func FromDatabaseInfo(info sysinfo.Database) gen.DatabaseInfo {
+	if info.Error != nil {
+		message := info.Error.Error()
+		return gen.DatabaseInfo{Error: &message}
+	}
+
+	return gen.DatabaseInfo{ServerVersion: &info.ServerVersion, SchemaVersion: &info.SchemaVersion}
+}
+
+func FromDatabaseMetrics(metrics *database.Metrics) gen.DatabaseMetrics {
+	return gen.DatabaseMetrics{
+		Pool: gen.DatabasePoolStats{
+			MaxOpenConnections: metrics.Pool.MaxOpenConnections,
+			OpenConnections:    metrics.Pool.OpenConnections,
+			InUse:              metrics.Pool.InUse,
+			Idle:               metrics.Pool.Idle,
+			WaitCount:          metrics.Pool.WaitCount,
+			WaitDurationMs:     metrics.Pool.WaitDuration.Milliseconds(),
+			MaxIdleClosed:      metrics.Pool.MaxIdleClosed,
+			MaxLifetimeClosed:  metrics.Pool.MaxLifetimeClosed,
+		},
+		Queries:      FromDatabaseQueryStats(metrics.Queries),
+		Transactions: FromDatabaseQueryStats(metrics.Transactions),
+	}
+}
+
+func FromDatabaseQueryStats(stats database.QueryStats) gen.DatabaseQueryStats {
+	return gen.DatabaseQueryStats{
+		Count:             stats.Count,
+		Errors:            stats.Errors,
+		Slow:              stats.SlowCount,
+		AverageDurationMs: float64(stats.AverageDuration().Microseconds()) / 1000,
+		MaxDurationMs:     float64(stats.MaxDuration.Microseconds()) / 1000,
+	}
+}
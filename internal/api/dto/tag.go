@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+// FromTag converts the tag model to a DTO, without the items it's attached to.
+func FromTag(tag *media.Tag) gen.Tag {
+	return gen.Tag{
+		Id:        tag.ID,
+		Label:     tag.Label,
+		CreatedAt: tag.CreatedAt,
+		UpdatedAt: tag.UpdatedAt,
+	}
+}
+
+// FromInflatedTag converts the tag model to a DTO, including the items it's attached to.
+func FromInflatedTag(tag *media.InflatedTag) gen.Tag {
+	dto := FromTag(tag.Tag)
+	items := util.ApplyConversion(tag.Items, FromTaggedItem)
+	dto.Items = &items
+
+	return dto
+}
+
+func FromTaggedItem(item *media.TaggedItem) gen.TaggedItem {
+	return gen.TaggedItem{Id: item.ID, Type: item.Type, Title: item.Title}
+}
@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/mitchellh/mapstructure"
+)
+
+func FromTarget(model *ffmpeg.Target) gen.Target {
+	return gen.Target{Id: model.ID, Label: model.Label, Extension: model.Ext, RemuxOnly: model.RemuxOnly, AudioLanguages: audioLanguages(model.AudioLanguages), FfmpegOptions: fromFfmpegOpts(model.FfmpegOptions), Benchmark: fromTargetBenchmark(model.Benchmark)}
+}
+
+func fromTargetBenchmark(benchmark *ffmpeg.TargetBenchmark) *gen.TargetBenchmark {
+	if benchmark == nil {
+		return nil
+	}
+
+	return &gen.TargetBenchmark{
+		BenchmarkedAt:               benchmark.BenchmarkedAt,
+		FramesPerSecond:             float32(benchmark.Fps),
+		SingleThreadFramesPerSecond: float32(benchmark.SingleThreadFps),
+		Threads:                     benchmark.Threads,
+		EstimatedThreads:            benchmark.EstimatedThreads(),
+		RealtimeFactor:              float32(benchmark.RealtimeFactor()),
+	}
+}
+
+func FromTargetPopularity(popularity *transcode.TargetPopularity) gen.TargetPopularity {
+	return gen.TargetPopularity{
+		TargetId:     popularity.TargetID,
+		Transcodes:   popularity.Transcodes,
+		Starts:       popularity.Starts,
+		Completions:  popularity.Completions,
+		LastPlayedAt: popularity.LastPlayedAt,
+	}
+}
+
+func fromFfmpegOpts(opts *ffmpeg.Opts) map[string]interface{} {
+	var dto map[string]interface{}
+	if err := mapstructure.Decode(opts, &dto); err != nil {
+		panic("ffmpeg options cannot be decoded to map[string]interface{}")
+	}
+
+	return dto
+}
+
+func audioLanguages(languages []string) []string {
+	if languages == nil {
+		return []string{}
+	}
+
+	return languages
+}
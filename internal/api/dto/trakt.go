@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/trakt"
+)
+
+func FromTraktLink(link *trakt.Link) gen.TraktLink {
+	return gen.TraktLink{
+		State:           gen.TraktLinkState(link.State),
+		UserCode:        link.UserCode,
+		VerificationUrl: link.VerificationURL,
+		ExpiresAt:       link.ExpiresAt,
+		LinkedAt:        link.LinkedAt,
+		SyncedAt:        link.SyncedAt,
+		LastError:       link.LastError,
+	}
+}
@@ -0,0 +1,170 @@
+package dto
+
+import (
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/transcode"
+)
+
+func FromTranscodeProgress(progress *ffmpeg.Progress) *gen.TranscodeTaskProgress {
+	if progress == nil {
+		return nil
+	}
+
+	return &gen.TranscodeTaskProgress{
+		CurrentBitrate:  progress.CurrentBitrate,
+		CurrentTime:     progress.CurrentTime,
+		FramesProcessed: progress.FramesProcessed,
+		Progress:        float32(progress.Progress),
+		Speed:           progress.Speed,
+		OutputSize:      progress.OutputSize,
+		AverageFps:      float32(progress.AverageFps),
+		EtaSeconds:      progress.EtaSeconds,
+	}
+}
+
+func FromTranscodeStatus(status transcode.TranscodeTaskStatus) gen.TranscodeTaskStatus {
+	switch status {
+	case transcode.WAITING:
+		return gen.TranscodeTaskStatusWAITING
+	case transcode.WORKING:
+		return gen.TranscodeTaskStatusWORKING
+	case transcode.SUSPENDED:
+		return gen.TranscodeTaskStatusSUSPENDED
+	case transcode.CANCELLED:
+		return gen.TranscodeTaskStatusCANCELLED
+	case transcode.COMPLETE:
+		return gen.TranscodeTaskStatusCOMPLETE
+	case transcode.TROUBLED:
+		return gen.TranscodeTaskStatusTROUBLED
+	}
+
+	panic("unreachable")
+}
+
+// FromTranscode converts a completed transcode model to a DTO.
+func FromTranscode(model *transcode.Transcode) gen.TranscodeTask {
+	var pool *string
+	if !model.Passthrough {
+		pool = &model.Pool
+	}
+
+	return gen.TranscodeTask{Id: model.ID, MediaId: model.MediaID, TargetId: model.TargetID, VersionId: model.VersionID, OutputPath: model.MediaPath, Pool: pool, Passthrough: &model.Passthrough, Status: gen.TranscodeTaskStatusCOMPLETE, Progress: nil}
+}
+
+// FromTranscodeBatch converts a batch, and the progress of it's tasks, to a DTO.
+func FromTranscodeBatch(batch *transcode.Batch, progress transcode.BatchProgress) gen.TranscodeBatch {
+	return gen.TranscodeBatch{
+		Id:         batch.ID,
+		Label:      batch.Label,
+		WorkflowId: batch.WorkflowID,
+		TargetId:   batch.TargetID,
+		CreatedAt:  batch.CreatedAt,
+		Matched:    progress.Matched,
+		Skipped:    progress.Skipped,
+		Total:      progress.Total,
+		Waiting:    progress.Waiting,
+		Working:    progress.Working,
+		Completed:  progress.Completed,
+		Failed:     progress.Failed,
+		Cancelled:  progress.Cancelled,
+	}
+}
+
+// FromTranscodeTask converts an active transcode task to a DTO.
+func FromTranscodeTask(model *transcode.TranscodeTask) gen.TranscodeTask {
+	var pool *string
+	if name := model.Pool(); name != "" {
+		pool = &name
+	}
+
+	important := model.IsImportant()
+	return gen.TranscodeTask{
+		Id:         model.ID(),
+		MediaId:    model.Media().ID(),
+		TargetId:   model.Target().ID,
+		VersionId:  model.VersionID(),
+		OutputPath: model.OutputPath(),
+		Pool:       pool,
+		Status:     FromTranscodeStatus(model.Status()),
+		Progress:   FromTranscodeProgress(model.LastProgress()),
+		Trouble:    FromTranscodeTrouble(model.Trouble()),
+		Important:  &important,
+	}
+}
+
+func FromTranscodeTrouble(trouble *transcode.Trouble) *gen.TranscodeTaskTrouble {
+	if trouble == nil {
+		return nil
+	}
+
+	var output *string
+	if out := trouble.Output(); out != "" {
+		output = &out
+	}
+
+	return &gen.TranscodeTaskTrouble{
+		Type:    FromTranscodeTroubleType(trouble.Type()),
+		Message: trouble.Error(),
+		Output:  output,
+	}
+}
+
+func FromTranscodeTroubleType(troubleType transcode.TroubleType) gen.TranscodeTaskTroubleType {
+	//exhaustive:enforce
+	switch troubleType {
+	case transcode.InsufficientDiskSpace:
+		return gen.INSUFFICIENTDISKSPACE
+	case transcode.Stalled:
+		return gen.STALLED
+	case transcode.MissingCodec:
+		return gen.MISSINGCODEC
+	case transcode.CorruptInput:
+		return gen.CORRUPTINPUT
+	case transcode.PermissionDenied:
+		return gen.PERMISSIONDENIED
+	case transcode.ResourcesExhausted:
+		return gen.RESOURCESEXHAUSTED
+	case transcode.UnknownFailure:
+		return gen.UNCLASSIFIEDFAILURE
+	}
+
+	panic("unreachable")
+}
+
+// FromTranscodeHistoryEntry converts an entry in the transcode history to a DTO.
+func FromTranscodeHistoryEntry(entry *transcode.HistoryEntry) gen.TranscodeHistoryEntry {
+	var durationSeconds *int64
+	if duration := entry.Duration(); duration != nil {
+		seconds := int64(duration.Seconds())
+		durationSeconds = &seconds
+	}
+
+	return gen.TranscodeHistoryEntry{
+		Id:              entry.ID,
+		MediaId:         entry.MediaID,
+		TargetId:        entry.TargetID,
+		VersionId:       entry.VersionID,
+		Status:          gen.TranscodeOutcomeStatus(entry.Status),
+		StartedAt:       entry.StartedAt,
+		ConcludedAt:     entry.ConcludedAt,
+		DurationSeconds: durationSeconds,
+		OutputSize:      entry.OutputSize,
+		Error:           entry.Error,
+	}
+}
+
+func FromPlaybackSession(session *transcode.PlaybackSession) gen.PlaybackSession {
+	return gen.PlaybackSession{
+		Id:                  session.ID,
+		UserId:              session.UserID,
+		MediaId:             session.MediaID,
+		VersionId:           session.VersionID,
+		Preferences:         FromPlaybackPreferences(session.Preferences),
+		DirectPlay:          session.DirectPlay,
+		AudioStreamIndex:    session.AudioStreamIndex,
+		SubtitleStreamIndex: session.SubtitleStreamIndex,
+		StartedAt:           session.StartedAt,
+		ExpiresAt:           session.ExpiresAt,
+	}
+}
@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/user"
+)
+
+func FromUser(user *user.User) gen.User {
+	return gen.User{
+		Id:                user.ID,
+		Username:          user.Username,
+		Permissions:       user.Permissions,
+		DirectPermissions: user.DirectPermissions,
+		Roles:             user.Roles,
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
+		LastLogin:         user.LastLoginAt,
+		LastRefresh:       user.LastRefreshAt,
+	}
+}
+
+// FromLoginSession converts the session provided, marking it as current if
+// it's the session with the ID given (the session the request was made with).
+func FromLoginSession(session jwt.Session, currentSessionID uuid.UUID) gen.LoginSession {
+	return gen.LoginSession{
+		Id:          session.ID,
+		UserAgent:   session.UserAgent,
+		Ip:          session.IP,
+		CreatedAt:   session.CreatedAt,
+		LastRefresh: session.RefreshedAt,
+		Current:     session.ID == currentSessionID,
+	}
+}
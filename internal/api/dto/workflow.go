@@ -0,0 +1,98 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/util"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"github.com/hbomb79/Thea/internal/workflow/match"
+)
+
+func FromWorkflow(model *workflow.Workflow) gen.Workflow {
+	return gen.Workflow{
+		Id:         model.ID,
+		Label:      model.Label,
+		Enabled:    model.Enabled,
+		Sequential: model.Sequential,
+		Criteria:   util.ApplyConversion(model.Criteria, criteriaToDto),
+		TargetIds:  util.ApplyConversion(model.Targets, getTargetID),
+		Hooks:      util.ApplyConversion(model.Hooks, hookToDto),
+	}
+}
+
+func hookToDto(hook workflow.Hook) gen.WorkflowHook {
+	return gen.WorkflowHook{Command: hook.Command, Args: &hook.Args, TimeoutSeconds: &hook.TimeoutSeconds}
+}
+
+func criteriaToDto(criteria match.Criteria) gen.WorkflowCriteria {
+	return gen.WorkflowCriteria{
+		CombineType: criteriaCombineTypeToDto(criteria.CombineType),
+		Key:         criteriaKeyToDto(criteria.Key),
+		Type:        criteriaTypeToDto(criteria.Type),
+		Value:       criteria.Value,
+	}
+}
+
+func criteriaCombineTypeToDto(combineType match.CombineType) gen.WorkflowCriteriaCombineType {
+	switch combineType {
+	case match.AND:
+		return gen.AND
+	case match.OR:
+		return gen.OR
+	}
+
+	panic("unreachable")
+}
+
+func criteriaKeyToDto(key match.Key) gen.WorkflowCriteriaKey {
+	switch key {
+	case match.MediaTitleKey:
+		return gen.WorkflowCriteriaKeyMEDIATITLE
+	case match.SeasonTitleKey:
+		return gen.WorkflowCriteriaKeySEASONTITLE
+	case match.SeriesTitleKey:
+		return gen.WorkflowCriteriaKeySERIESTITLE
+	case match.ResolutionKey:
+		return gen.WorkflowCriteriaKeyRESOLUTION
+	case match.SeasonNumberKey:
+		return gen.WorkflowCriteriaKeySEASONNUMBER
+	case match.EpisodeNumberKey:
+		return gen.WorkflowCriteriaKeyEPISODENUMBER
+	case match.SourcePathKey:
+		return gen.WorkflowCriteriaKeySOURCEPATH
+	case match.SourceNameKey:
+		return gen.WorkflowCriteriaKeySOURCENAME
+	case match.SourceExtensionKey:
+		return gen.WorkflowCriteriaKeySOURCEEXTENSION
+	case match.TagKey:
+		return gen.WorkflowCriteriaKeyTAG
+	}
+
+	panic("unreachable")
+}
+
+func criteriaTypeToDto(t match.Type) gen.WorkflowCriteriaType {
+	switch t {
+	case match.Equals:
+		return gen.EQUALS
+	case match.NotEquals:
+		return gen.NOTEQUALS
+	case match.Matches:
+		return gen.MATCHES
+	case match.DoesNotMatch:
+		return gen.DOESNOTMATCH
+	case match.LessThan:
+		return gen.LESSTHAN
+	case match.GreaterThan:
+		return gen.GREATERTHAN
+	case match.IsPresent:
+		return gen.ISPRESENT
+	case match.IsNotPresent:
+		return gen.ISNOTPRESENT
+	}
+
+	panic("unreachable")
+}
+
+func getTargetID(target *ffmpeg.Target) uuid.UUID { return target.ID }
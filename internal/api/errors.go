@@ -0,0 +1,185 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/artwork"
+	"github.com/hbomb79/Thea/internal/benchmark"
+	"github.com/hbomb79/Thea/internal/consistency"
+	"github.com/hbomb79/Thea/internal/deletion"
+	"github.com/hbomb79/Thea/internal/export"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/library"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/preferences"
+	"github.com/hbomb79/Thea/internal/quota"
+	"github.com/hbomb79/Thea/internal/remote"
+	"github.com/hbomb79/Thea/internal/trakt"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"github.com/labstack/echo/v4"
+)
+
+// errorMapping maps a typed error returned by the services/stores of Thea to
+// the HTTP status and stable error code sent to the user.
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings are checked in order, so more specific errors must
+// come before any errors they wrap.
+var errorMappings = []errorMapping{
+	{ingest.ErrIngestNotFound, http.StatusNotFound, "ingest.not_found"},
+	{ingest.ErrIngestGroupNotFound, http.StatusNotFound, "ingest.group_not_found"},
+	{ingest.ErrNoTrouble, http.StatusBadRequest, "ingest.no_trouble"},
+	{ingest.ErrResolutionIncompatible, http.StatusBadRequest, "ingest.resolution_incompatible"},
+	{ingest.ErrResolutionIncomplete, http.StatusBadRequest, "ingest.resolution_incomplete"},
+	{ingest.ErrResolutionContextIncompatible, http.StatusBadRequest, "ingest.resolution_failed"},
+	{ingest.ErrIngestNotPausable, http.StatusConflict, "ingest.not_pausable"},
+	{ingest.ErrIngestNotPaused, http.StatusConflict, "ingest.not_paused"},
+	{ingest.ErrIngestNotQuarantined, http.StatusConflict, "ingest.not_quarantined"},
+	{ingest.ErrInvalidParallelism, http.StatusBadRequest, "ingest.parallelism_invalid"},
+	{ingest.ErrInfoHashInvalid, http.StatusBadRequest, "ingest.info_hash_invalid"},
+	{ingest.ErrPathNotAllowed, http.StatusForbidden, "ingest.path_not_allowed"},
+	{ingest.ErrNoFilesFound, http.StatusConflict, "ingest.no_files_found"},
+
+	{transcode.ErrTaskNotFound, http.StatusNotFound, "transcode.not_found"},
+	{transcode.ErrPlaybackSessionNotFound, http.StatusNotFound, "transcode.playback_session_not_found"},
+	{transcode.ErrPlaybackMediaNotFound, http.StatusNotFound, "media.not_found"},
+	{transcode.ErrDraining, http.StatusServiceUnavailable, "transcode.draining"},
+	{transcode.ErrDuplicate, http.StatusConflict, "transcode.duplicate"},
+	{transcode.ErrBatchWorkflowNotFound, http.StatusNotFound, "workflow.not_found"},
+	{transcode.ErrBatchTargetNotFound, http.StatusBadRequest, "workflow.target_not_in_workflow"},
+	{transcode.ErrPreviewMediaNotFound, http.StatusNotFound, "media.not_found"},
+	{transcode.ErrPreviewTargetNotFound, http.StatusNotFound, "target.not_found"},
+
+	{workflow.ErrTargetIDMissing, http.StatusBadRequest, "workflow.target_missing"},
+
+	{jwt.ErrSessionNotFound, http.StatusNotFound, "auth.session_not_found"},
+	{ErrTrustedHeaderAuthDisabled, http.StatusNotFound, "auth.trusted_header_disabled"},
+	{ErrUntrustedProxy, http.StatusUnauthorized, "auth.untrusted_proxy"},
+	{ErrTrustedHeaderMissing, http.StatusUnauthorized, "auth.trusted_header_missing"},
+	{ErrTrustedUserNotFound, http.StatusUnauthorized, "auth.trusted_user_not_found"},
+
+	{user.ErrUserNotFound, http.StatusNotFound, "user.not_found"},
+	{user.ErrRoleNotFound, http.StatusNotFound, "role.not_found"},
+	{user.ErrRoleIDMissing, http.StatusBadRequest, "user.role_missing"},
+	{user.ErrPermissionsInvalid, http.StatusBadRequest, "user.permissions_invalid"},
+	{user.ErrInviteNotFound, http.StatusNotFound, "invite.not_found"},
+	{user.ErrInviteInvalid, http.StatusBadRequest, "invite.invalid"},
+	{user.ErrShareLinkNotFound, http.StatusNotFound, "share.not_found"},
+	{user.ErrShareTargetNotTranscoded, http.StatusBadRequest, "share.transcode_not_found"},
+	{user.ErrShareExpiryInvalid, http.StatusBadRequest, "share.expiry_invalid"},
+
+	{media.ErrNotRestorable, http.StatusBadRequest, "media.not_restorable"},
+	{media.ErrInvalidCursor, http.StatusBadRequest, "media.cursor_invalid"},
+	{media.ErrFieldNotLockable, http.StatusBadRequest, "media.field_not_lockable"},
+	{media.ErrSiblingConflict, http.StatusConflict, "media.number_conflict"},
+	{media.ErrTagItemNotFound, http.StatusBadRequest, "tag.item_not_found"},
+	{media.ErrTagLabelConflict, http.StatusConflict, "tag.label_conflict"},
+	{media.ErrDeletionFilterEmpty, http.StatusBadRequest, "media.deletion_filter_empty"},
+
+	{deletion.ErrJobNotFound, http.StatusNotFound, "deletion.not_found"},
+	{deletion.ErrJobNotPreview, http.StatusConflict, "deletion.already_executed"},
+	{deletion.ErrPreviewExpired, http.StatusGone, "deletion.preview_expired"},
+
+	{benchmark.ErrTargetNotFound, http.StatusNotFound, "target.not_found"},
+	{benchmark.ErrTargetRemuxOnly, http.StatusConflict, "target.remux_only"},
+
+	{library.ErrLibraryNotFound, http.StatusNotFound, "library.not_found"},
+	{library.ErrLibraryConflict, http.StatusConflict, "library.conflict"},
+	{library.ErrIngestPathInvalid, http.StatusBadRequest, "library.ingest_path_invalid"},
+	{library.ErrOutputDirectoryInvalid, http.StatusBadRequest, "library.output_directory_invalid"},
+	{library.ErrAccessDenied, http.StatusNotFound, "media.not_found"},
+
+	{quota.ErrConcurrentLimitReached, http.StatusConflict, "quota.concurrent_limit_reached"},
+	{quota.ErrDailyLimitReached, http.StatusTooManyRequests, "quota.daily_limit_reached"},
+	{quota.ErrSessionLimitReached, http.StatusConflict, "quota.session_limit_reached"},
+	{quota.ErrLimitInvalid, http.StatusBadRequest, "quota.limit_invalid"},
+
+	{preferences.ErrLanguageInvalid, http.StatusBadRequest, "preferences.language_invalid"},
+	{preferences.ErrBitrateInvalid, http.StatusBadRequest, "preferences.bitrate_invalid"},
+
+	{consistency.ErrCheckInProgress, http.StatusConflict, "consistency.check_in_progress"},
+	{consistency.ErrVerificationInProgress, http.StatusConflict, "consistency.verification_in_progress"},
+	{export.ErrExportInProgress, http.StatusConflict, "export.in_progress"},
+
+	{remote.ErrSourceNotFound, http.StatusNotFound, "remote.not_found"},
+	{remote.ErrSyncInProgress, http.StatusConflict, "remote.sync_in_progress"},
+	{remote.ErrLabelInvalid, http.StatusBadRequest, "remote.label_invalid"},
+	{remote.ErrProtocolInvalid, http.StatusBadRequest, "remote.protocol_invalid"},
+	{remote.ErrHostKeyRequired, http.StatusBadRequest, "remote.host_key_required"},
+	{remote.ErrSyncIntervalTooLow, http.StatusBadRequest, "remote.sync_interval_invalid"},
+	{remote.ErrURLInvalid, http.StatusBadRequest, "remote.url_invalid"},
+	{remote.ErrCredentialKeyMissing, http.StatusBadRequest, "remote.credential_key_missing"},
+
+	{trakt.ErrNotConfigured, http.StatusServiceUnavailable, "trakt.not_configured"},
+	{trakt.ErrNotLinked, http.StatusNotFound, "trakt.not_linked"},
+
+	{artwork.ErrImagePathInvalid, http.StatusBadRequest, "artwork.path_invalid"},
+	{artwork.ErrImageWidthInvalid, http.StatusBadRequest, "artwork.width_invalid"},
+	{artwork.ErrImageUnavailable, http.StatusBadGateway, "artwork.unavailable"},
+}
+
+// newErrorMappingMiddleware returns a middleware which converts the errors returned by
+// the handlers to an APIError (see toAPIError), so that all error responses carry a
+// stable error code which clients can rely on.
+func newErrorMappingMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ec echo.Context) error {
+			if err := next(ec); err != nil {
+				return toAPIError(err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// toAPIError converts the error provided to an APIError. Errors which are (or wrap) one of
+// the errorMappings use the status and code of the mapping, and the message of the error is
+// exposed to the user. Echo HTTP errors whose message is a typed error are mapped the same
+// way, otherwise they are given a generic code for their status. All other errors are
+// treated as internal server errors, and their message is only logged.
+//
+// The internal error of an Echo HTTP error is never mapped, as it may contain
+// the reason authentication failed, which must not be revealed.
+func toAPIError(err error) gen.APIError {
+	var apiErr gen.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		if cause, ok := httpErr.Message.(error); ok {
+			if mapped, ok := mapTypedError(cause); ok {
+				return mapped
+			}
+		}
+
+		return gen.NewAPIErrorFromHTTPError(httpErr)
+	}
+
+	if mapped, ok := mapTypedError(err); ok {
+		return mapped
+	}
+
+	return gen.APIError{Status: http.StatusInternalServerError, InternalMessage: err.Error()}
+}
+
+func mapTypedError(err error) (gen.APIError, bool) {
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			return gen.APIError{Status: mapping.status, Code: mapping.code, Message: err.Error()}, true
+		}
+	}
+
+	return gen.APIError{}, false
+}
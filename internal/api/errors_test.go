@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ToAPIErrorMapsTypedErrors(t *testing.T) {
+	wrapped := fmt.Errorf("failed to create workflow: %w", workflow.ErrTargetIDMissing)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"raw", ingest.ErrIngestNotFound, http.StatusNotFound, "ingest.not_found"},
+		{"wrapped", wrapped, http.StatusBadRequest, "workflow.target_missing"},
+		{"http error with typed message", echo.NewHTTPError(http.StatusBadRequest, ingest.ErrIngestNotFound), http.StatusNotFound, "ingest.not_found"},
+		{"http error with string message", echo.NewHTTPError(http.StatusConflict, "taken"), http.StatusConflict, "resource.conflict"},
+		{"api error", gen.APIError{Status: http.StatusTooManyRequests, Code: "request.rate_limited"}, http.StatusTooManyRequests, "request.rate_limited"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiErr := toAPIError(test.err)
+			assert.Equal(t, test.status, apiErr.Status)
+			assert.Equal(t, test.code, apiErr.Code)
+		})
+	}
+}
+
+func Test_ToAPIErrorHidesInternalErrors(t *testing.T) {
+	apiErr := toAPIError(errors.New("connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, apiErr.Status)
+	assert.Empty(t, apiErr.Message)
+	assert.Equal(t, "connection refused", apiErr.InternalMessage)
+
+	apiErr = toAPIError(echo.NewHTTPError(http.StatusInternalServerError, "query failed"))
+	assert.Equal(t, "server.internal", apiErr.Code)
+	assert.Empty(t, apiErr.Message)
+	assert.Equal(t, "query failed", apiErr.InternalMessage)
+}
@@ -9,19 +9,55 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// ProblemContentType is the media type of the error responses of the API (see RFC 7807).
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the code of an error to form the type of the
+// problem. The type is a stable identifier, and is not dereferenceable.
+const problemTypePrefix = "urn:thea:problem:"
+
 type APIError struct {
-	// Human readable error display message
-	Message string `json:"message"`
+	// Human readable explanation of this occurrence of the error
+	Message string
 
-	// A machine readable and stable identifier for the error case being represented
-	Code string `json:"code"`
+	// A machine readable and stable identifier for the error case being represented,
+	// namespaced by the area of Thea the error originates from (e.g. 'ingest.not_found')
+	Code string
 
 	// Used to alter the HTTP response status in accordance with the error
-	Status int `json:"-"`
+	Status int
 
 	// Additional message for internal logging only. Will not be included in the message
 	// sent to the user.
-	InternalMessage string `json:"-"`
+	InternalMessage string
+
+	// The fields of the request which failed validation, if any
+	Violations []FieldViolation
+}
+
+// FieldViolation describes a single field of a request which failed validation.
+type FieldViolation struct {
+	// Path to the field, prefixed with its location (e.g. 'body.label' or 'query.limit')
+	Field string `json:"field"`
+
+	// The constraint which the field violated (e.g. 'required' or 'minLength')
+	Constraint string `json:"constraint"`
+
+	// Human readable explanation of the violation
+	Message string `json:"message,omitempty"`
+}
+
+// Problem is the 'application/problem+json' representation of an APIError sent
+// to the user, as described by RFC 7807. The code and violations are extension
+// members, the code being the same stable identifier used to form the type.
+type Problem struct {
+	Type       string           `json:"type"`
+	Title      string           `json:"title"`
+	Status     int              `json:"status"`
+	Detail     string           `json:"detail,omitempty"`
+	Instance   string           `json:"instance,omitempty"`
+	Code       string           `json:"code"`
+	Violations []FieldViolation `json:"violations,omitempty"`
 }
 
 // Error satisifies the Go error interface and simply exposes the
@@ -30,42 +66,108 @@ func (err APIError) Error() string {
 	return fmt.Sprintf("api error: %s", err.Message)
 }
 
-var ErrAPIUnauthorized APIError = APIError{Status: 401}
+var ErrAPIUnauthorized APIError = APIError{Status: 401, Code: "auth.unauthorized"}
+
+// genericErrorCodes are the codes used for errors which are not of a more
+// specific type (see NewAPIErrorFromHTTPError).
+var genericErrorCodes = map[int]string{
+	http.StatusBadRequest:            "request.invalid",
+	http.StatusUnauthorized:          "auth.unauthorized",
+	http.StatusForbidden:             "auth.forbidden",
+	http.StatusNotFound:              "resource.not_found",
+	http.StatusMethodNotAllowed:      "request.method_not_allowed",
+	http.StatusConflict:              "resource.conflict",
+	http.StatusRequestEntityTooLarge: "request.too_large",
+	http.StatusUnsupportedMediaType:  "request.unsupported_media_type",
+	http.StatusTooManyRequests:       "request.rate_limited",
+	http.StatusServiceUnavailable:    "server.unavailable",
+}
+
+// NewValidationError returns a 400 APIError containing the violations provided.
+func NewValidationError(violations ...FieldViolation) APIError {
+	return APIError{
+		Status:     http.StatusBadRequest,
+		Code:       "request.validation_failed",
+		Message:    "Request validation failed",
+		Violations: violations,
+	}
+}
+
+// NewAPIErrorFromHTTPError converts the Echo HTTP error provided to an APIError with a
+// generic code for its status. The message of server errors is not exposed to the user,
+// as it often contains the details of an internal failure.
+func NewAPIErrorFromHTTPError(httpErr *echo.HTTPError) APIError {
+	message := fmt.Sprint(httpErr.Message)
+	apiErr := APIError{Status: httpErr.Code, Code: GenericErrorCode(httpErr.Code), Message: message}
+	if httpErr.Code >= http.StatusInternalServerError {
+		apiErr.Message = ""
+		apiErr.InternalMessage = message
+		if httpErr.Internal != nil {
+			apiErr.InternalMessage = fmt.Sprintf("%s (%v)", message, httpErr.Internal)
+		}
+	}
 
-// GetHTTPErrorHandler returns an echo HTTP error handler
-// which understands how to interpret APIError. If an error is
-// provided which is not recognized, it will be passed off to the
-// fallback HTTP handler provided.
-func GetHTTPErrorHandler(fallbackHandler echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return apiErr
+}
+
+// GenericErrorCode returns the code used for errors with the HTTP status provided
+// which are not of a more specific type.
+func GenericErrorCode(status int) string {
+	if code, ok := genericErrorCodes[status]; ok {
+		return code
+	} else if status >= http.StatusInternalServerError {
+		return "server.internal"
+	}
+
+	return "request.failed"
+}
+
+// GetHTTPErrorHandler returns an echo HTTP error handler which responds to
+// the request with the 'application/problem+json' representation of the
+// error. Errors which are not an APIError (e.g. those raised by Echo before
+// routing the request) are converted using the function provided.
+func GetHTTPErrorHandler(toAPIError func(error) APIError) echo.HTTPErrorHandler {
 	logger := logger.Get("API")
 	return func(err error, ctx echo.Context) {
+		if ctx.Response().Committed {
+			return
+		}
+
 		var apiErr APIError
-		if ok := errors.As(err, &apiErr); ok {
-			if apiErr.Status == 0 {
-				apiErr.Status = 500
-			}
-			if len(apiErr.Message) == 0 {
-				apiErr.Message = http.StatusText(apiErr.Status)
-			}
-			if len(apiErr.Code) == 0 {
-				apiErr.Code = http.StatusText(apiErr.Status)
-			}
-			if len(apiErr.InternalMessage) > 0 {
-				logger.Errorf("Request failure, internal error: %s\n", apiErr.InternalMessage)
-			}
-
-			if err := ctx.JSON(apiErr.Status, apiErr); err == nil {
-				return
-			}
+		if ok := errors.As(err, &apiErr); !ok {
+			apiErr = toAPIError(err)
+		}
+		if apiErr.Status == 0 {
+			apiErr.Status = 500
+		}
+		if len(apiErr.Message) == 0 {
+			apiErr.Message = http.StatusText(apiErr.Status)
+		}
+		if len(apiErr.Code) == 0 {
+			apiErr.Code = GenericErrorCode(apiErr.Status)
+		}
+		if len(apiErr.InternalMessage) > 0 {
+			logger.Errorf("Request failure, internal error: %s\n", apiErr.InternalMessage)
 		}
 
-		// This is not an APIError, just let Echo handle it as it normally would
-		// TODO: Consider just 500'ing here, and enforcing that our routes MUST
-		// use the APIError if they want to expose error information.
-		logger.Warnf(
-			"%s request to %s caused error response, however the response does not satisfy the APIError interface. Falling back to default HTTP error handling\n",
-			ctx.Request().Method, ctx.Request().RequestURI,
-		)
-		fallbackHandler(err, ctx)
+		problem := Problem{
+			Type:       problemTypePrefix + apiErr.Code,
+			Title:      http.StatusText(apiErr.Status),
+			Status:     apiErr.Status,
+			Detail:     apiErr.Message,
+			Instance:   ctx.Request().URL.Path,
+			Code:       apiErr.Code,
+			Violations: apiErr.Violations,
+		}
+
+		ctx.Response().Header().Set(echo.HeaderContentType, ProblemContentType)
+		if ctx.Request().Method == http.MethodHead {
+			err = ctx.NoContent(apiErr.Status)
+		} else {
+			err = ctx.JSON(apiErr.Status, problem)
+		}
+		if err != nil {
+			logger.Errorf("Failed to send error response for %s request to %s: %v\n", ctx.Request().Method, ctx.Request().RequestURI, err)
+		}
 	}
 }
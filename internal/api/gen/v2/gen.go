@@ -0,0 +1,7 @@
+// Package v2 contains the models and server generated from version 2 of Thea's OpenAPI
+// spec. The strict server templates, and the error handling of the API, are shared
+// with version 1 (see the parent gen package).
+package v2
+
+//go:generate go run github.com/deepmap/oapi-codegen/v2/cmd/oapi-codegen --config=types.cfg.yaml ../../thea.v2.openapi.yaml
+//go:generate go run github.com/deepmap/oapi-codegen/v2/cmd/oapi-codegen --config=server.cfg.yaml -templates=../templates ../../thea.v2.openapi.yaml
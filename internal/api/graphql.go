@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/controllers/medias"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/graphql"
+	"github.com/hbomb79/Thea/internal/library"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/preferences"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	graphqlStore interface {
+		GetMovie(movieID uuid.UUID) (*media.Movie, error)
+		GetEpisode(episodeID uuid.UUID) (*media.Episode, error)
+		GetInflatedSeries(seriesID uuid.UUID) (*media.InflatedSeries, error)
+		GetTranscodesForMedias(mediaIDs []uuid.UUID) ([]*transcode.Transcode, error)
+		GetMediaVersionsForMedias(mediaIDs []uuid.UUID) (map[uuid.UUID][]*media.Version, error)
+		GetAllTargets() []*ffmpeg.Target
+		GetUserPreferences(userID uuid.UUID) (*preferences.Preferences, error)
+		GetLibraryAccess(userID uuid.UUID, userPermissions []string) (*library.Access, error)
+		AuthorizeLibraryAccess(access *library.Access, id uuid.UUID) error
+	}
+
+	// graphqlResolver resolves the fields of the GraphQL schema. The versions, transcodes and watch
+	// targets of movies and episodes are resolved in batches, such that requesting these fields for
+	// every episode of a series requires the same number of queries as requesting them for one.
+	graphqlResolver struct {
+		store            graphqlStore
+		transcodeService medias.TranscodeService
+	}
+
+	graphqlUserKey struct{}
+)
+
+var errInvalidID = errors.New("id is not a valid UUID")
+
+// registerGraphQLRoute registers the GraphQL endpoint, which allows clients to fetch the media,
+// seasons, episodes, transcodes and watch targets they require in a single request. Like the
+// activity socket, this endpoint is not documented in the OpenAPI spec, so the authentication
+// is performed manually. Users must have the media:access permission to use the endpoint,
+// and the transcode:access permission to query transcodes. Media within libraries the user
+// may not access resolves to null, as if it did not exist.
+func registerGraphQLRoute(ec *echo.Echo, path string, authProvider requestAuthProvider, store graphqlStore, transcodeService medias.TranscodeService) {
+	schema := (&graphqlResolver{store: store, transcodeService: transcodeService}).schema()
+	ec.POST(path, func(ec echo.Context) error {
+		user, err := authProvider.ValidateTokenFromRequest(ec, ec.Request())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized).SetInternal(err)
+		}
+
+		if !slices.Contains(user.Permissions, permissions.AccessMediaPermission) {
+			log.Warnf("User %s failed permissions check while accessing %s: missing permission '%s'\n", user.UserID, ec.Request().RequestURI, permissions.AccessMediaPermission)
+			return echo.NewHTTPError(http.StatusForbidden).SetInternal(jwt.ErrInsufficientPermissions)
+		}
+
+		var request graphql.Request
+		if err := ec.Bind(&request); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "request body must be a JSON encoded GraphQL request")
+		}
+
+		ctx := context.WithValue(ec.Request().Context(), graphqlUserKey{}, user)
+		return ec.JSON(http.StatusOK, schema.Execute(ctx, request))
+	})
+}
+
+func (resolver *graphqlResolver) schema() *graphql.Schema {
+	versionType := &graphql.Object{
+		Name: "Version",
+		Fields: map[string]*graphql.Field{
+			"id":     scalarField(func(v *media.Version) any { return v.ID }),
+			"label":  scalarField(func(v *media.Version) any { return v.Label }),
+			"width":  scalarField(func(v *media.Version) any { return v.Width }),
+			"height": scalarField(func(v *media.Version) any { return v.Height }),
+		},
+	}
+
+	transcodeType := &graphql.Object{
+		Name: "Transcode",
+		Fields: map[string]*graphql.Field{
+			"id":        scalarField(func(t *transcode.Transcode) any { return t.ID }),
+			"targetId":  scalarField(func(t *transcode.Transcode) any { return t.TargetID }),
+			"versionId": scalarField(func(t *transcode.Transcode) any { return t.VersionID }),
+			"size":      scalarField(func(t *transcode.Transcode) any { return t.Size }),
+		},
+	}
+
+	watchTargetType := &graphql.Object{
+		Name: "WatchTarget",
+		Fields: map[string]*graphql.Field{
+			"displayName": scalarField(func(t gen.MediaWatchTarget) any { return t.DisplayName }),
+			"type":        scalarField(func(t gen.MediaWatchTarget) any { return t.Type }),
+			"ready":       scalarField(func(t gen.MediaWatchTarget) any { return t.Ready }),
+			"enabled":     scalarField(func(t gen.MediaWatchTarget) any { return t.Enabled }),
+			"targetId":    scalarField(func(t gen.MediaWatchTarget) any { return t.TargetId }),
+			"versionId":   scalarField(func(t gen.MediaWatchTarget) any { return t.VersionId }),
+		},
+	}
+
+	// Movies and episodes share the fields common to all watchable media
+	watchableFields := func(fields map[string]*graphql.Field) map[string]*graphql.Field {
+		fields["id"] = scalarField(func(m *media.Model) any { return m.ID })
+		fields["tmdbId"] = scalarField(func(m *media.Model) any { return m.TmdbID })
+		fields["title"] = scalarField(func(m *media.Model) any { return m.Title })
+		fields["createdAt"] = scalarField(func(m *media.Model) any { return m.CreatedAt })
+		fields["updatedAt"] = scalarField(func(m *media.Model) any { return m.UpdatedAt })
+		fields["width"] = scalarField(func(w *media.Watchable) any { return w.Width })
+		fields["height"] = scalarField(func(w *media.Watchable) any { return w.Height })
+		fields["versions"] = &graphql.Field{Type: versionType, List: true, BatchResolve: resolver.resolveVersions}
+		fields["transcodes"] = &graphql.Field{Type: transcodeType, List: true, BatchResolve: resolver.resolveTranscodes}
+		fields["watchTargets"] = &graphql.Field{Type: watchTargetType, List: true, BatchResolve: resolver.resolveWatchTargets}
+		return fields
+	}
+
+	movieType := &graphql.Object{
+		Name: "Movie",
+		Fields: watchableFields(map[string]*graphql.Field{
+			"genres": scalarField(func(m *media.Movie) any { return genreLabels(m.Genres) }),
+		}),
+	}
+
+	episodeType := &graphql.Object{
+		Name: "Episode",
+		Fields: watchableFields(map[string]*graphql.Field{
+			"seasonId":      scalarField(func(e *media.Episode) any { return e.SeasonID }),
+			"episodeNumber": scalarField(func(e *media.Episode) any { return e.EpisodeNumber }),
+		}),
+	}
+
+	seasonType := &graphql.Object{
+		Name: "Season",
+		Fields: map[string]*graphql.Field{
+			"id":           scalarField(func(s *media.InflatedSeason) any { return s.ID }),
+			"title":        scalarField(func(s *media.InflatedSeason) any { return s.Title }),
+			"seasonNumber": scalarField(func(s *media.InflatedSeason) any { return s.SeasonNumber }),
+			"episodes":     objectField(episodeType, true, func(s *media.InflatedSeason) any { return s.Episodes }),
+		},
+	}
+
+	seriesType := &graphql.Object{
+		Name: "Series",
+		Fields: map[string]*graphql.Field{
+			"id":        scalarField(func(s *media.InflatedSeries) any { return s.ID }),
+			"tmdbId":    scalarField(func(s *media.InflatedSeries) any { return s.TmdbID }),
+			"title":     scalarField(func(s *media.InflatedSeries) any { return s.Title }),
+			"createdAt": scalarField(func(s *media.InflatedSeries) any { return s.CreatedAt }),
+			"updatedAt": scalarField(func(s *media.InflatedSeries) any { return s.UpdatedAt }),
+			"genres":    scalarField(func(s *media.InflatedSeries) any { return genreLabels(s.Genres) }),
+			"seasons":   objectField(seasonType, true, func(s *media.InflatedSeries) any { return s.Seasons }),
+		},
+	}
+
+	return &graphql.Schema{
+		Query: &graphql.Object{
+			Name: "Query",
+			Fields: map[string]*graphql.Field{
+				"movie":   rootField(movieType, resolver.authorize, resolver.store.GetMovie),
+				"episode": rootField(episodeType, resolver.authorize, resolver.store.GetEpisode),
+				"series":  rootField(seriesType, resolver.authorize, resolver.store.GetInflatedSeries),
+			},
+		},
+	}
+}
+
+// authorize returns library.ErrAccessDenied if the media with the ID provided belongs
+// to a library which the user of the request may not access.
+func (resolver *graphqlResolver) authorize(ctx context.Context, id uuid.UUID) error {
+	user, ok := ctx.Value(graphqlUserKey{}).(*jwt.AuthenticatedUser)
+	if !ok {
+		return jwt.ErrInsufficientPermissions
+	}
+
+	access, err := resolver.store.GetLibraryAccess(user.UserID, user.Permissions)
+	if err != nil {
+		return err
+	}
+
+	return resolver.store.AuthorizeLibraryAccess(access, id)
+}
+
+func (resolver *graphqlResolver) resolveVersions(_ context.Context, parents []any, _ graphql.Args) ([]any, error) {
+	ids := watchableIDs(parents)
+	versions, err := resolver.store.GetMediaVersionsForMedias(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]any, len(ids))
+	for k, id := range ids {
+		out[k] = nonNil(versions[id])
+	}
+
+	return out, nil
+}
+
+func (resolver *graphqlResolver) resolveTranscodes(ctx context.Context, parents []any, _ graphql.Args) ([]any, error) {
+	if user, ok := ctx.Value(graphqlUserKey{}).(*jwt.AuthenticatedUser); !ok || !slices.Contains(user.Permissions, permissions.AccessTranscodePermission) {
+		return nil, jwt.ErrInsufficientPermissions
+	}
+
+	ids := watchableIDs(parents)
+	transcodes, err := resolver.completedTranscodesByMedia(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]any, len(ids))
+	for k, id := range ids {
+		out[k] = nonNil(transcodes[id])
+	}
+
+	return out, nil
+}
+
+func (resolver *graphqlResolver) resolveWatchTargets(ctx context.Context, parents []any, _ graphql.Args) ([]any, error) {
+	user, ok := ctx.Value(graphqlUserKey{}).(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, jwt.ErrInsufficientPermissions
+	}
+	prefs, err := resolver.store.GetUserPreferences(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := watchableIDs(parents)
+	versions, err := resolver.store.GetMediaVersionsForMedias(ids)
+	if err != nil {
+		return nil, err
+	}
+	transcodes, err := resolver.completedTranscodesByMedia(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := resolver.store.GetAllTargets()
+	out := make([]any, len(ids))
+	for k, id := range ids {
+		out[k] = medias.BuildWatchTargets(targets, versions[id], transcodes[id], resolver.transcodeService.ActiveTasksForMedia(id), prefs)
+	}
+
+	return out, nil
+}
+
+func (resolver *graphqlResolver) completedTranscodesByMedia(mediaIDs []uuid.UUID) (map[uuid.UUID][]*transcode.Transcode, error) {
+	transcodes, err := resolver.store.GetTranscodesForMedias(mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byMedia := make(map[uuid.UUID][]*transcode.Transcode, len(mediaIDs))
+	for _, t := range transcodes {
+		byMedia[t.MediaID] = append(byMedia[t.MediaID], t)
+	}
+
+	return byMedia, nil
+}
+
+// rootField returns a field which accepts an 'id' argument, and resolves to the model with
+// that ID using the getter provided. Models which do not exist (or which the user of the
+// request is not authorized to access) resolve to null.
+func rootField[T any](t *graphql.Object, authorize func(context.Context, uuid.UUID) error, get func(uuid.UUID) (*T, error)) *graphql.Field {
+	return &graphql.Field{
+		Type: t,
+		Resolve: func(ctx context.Context, _ any, args graphql.Args) (any, error) {
+			raw, _ := args["id"].(string)
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				return nil, errInvalidID
+			}
+
+			if err := authorize(ctx, id); errors.Is(err, library.ErrAccessDenied) {
+				return nil, nil
+			} else if err != nil {
+				return nil, err
+			}
+
+			model, err := get(id)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil
+			}
+
+			return model, err
+		},
+	}
+}
+
+// scalarField returns a field which resolves using the getter provided. Parents which
+// are not of type T are unwrapped to the watchable model/media they embed (see asType).
+func scalarField[T any](get func(T) any) *graphql.Field {
+	return &graphql.Field{
+		Resolve: func(_ context.Context, parent any, _ graphql.Args) (any, error) {
+			return get(asType[T](parent)), nil
+		},
+	}
+}
+
+func objectField[T any](t *graphql.Object, list bool, get func(T) any) *graphql.Field {
+	return &graphql.Field{
+		Type: t,
+		List: list,
+		Resolve: func(_ context.Context, parent any, _ graphql.Args) (any, error) {
+			return get(parent.(T)), nil
+		},
+	}
+}
+
+// asType converts the parent provided to type T, allowing the fields shared by movies
+// and episodes to be defined once against the Model or Watchable they embed.
+func asType[T any](parent any) T {
+	if v, ok := parent.(T); ok {
+		return v
+	}
+
+	var out any
+	switch m := parent.(type) {
+	case *media.Movie:
+		out = &m.Model
+		if _, ok := out.(T); !ok {
+			out = &m.Watchable
+		}
+	case *media.Episode:
+		out = &m.Model
+		if _, ok := out.(T); !ok {
+			out = &m.Watchable
+		}
+	}
+
+	return out.(T)
+}
+
+func watchableIDs(parents []any) []uuid.UUID {
+	ids := make([]uuid.UUID, len(parents))
+	for k, parent := range parents {
+		ids[k] = asType[*media.Model](parent).ID
+	}
+
+	return ids
+}
+
+func genreLabels(genres []*media.Genre) []string {
+	labels := make([]string, len(genres))
+	for k, g := range genres {
+		labels[k] = g.Label
+	}
+
+	return labels
+}
+
+// nonNil ensures that list fields with no items resolve to an empty
+// list, rather than null.
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+
+	return items
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/hbomb79/Thea/internal/api/controllers/system"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	probeStatusUp   = "UP"
+	probeStatusDown = "DOWN"
+)
+
+// probeResponse is the body of the liveness and readiness probe responses.
+type probeResponse struct {
+	Status string `json:"status"`
+}
+
+// registerHealthRoutes registers the liveness (/healthz) and readiness (/readyz) probes under
+// the path provided, for use by Docker HEALTHCHECKs and Kubernetes probes. These endpoints do
+// not require authentication, and so are not documented in the OpenAPI spec, and only report
+// the overall status. The status of each dependency is available to users with the system:read
+// permission (see SystemController.GetHealth).
+func registerHealthRoutes(ec *echo.Echo, path string, checker system.HealthChecker) {
+	// Liveness only indicates that the process is up and serving requests, the dependencies
+	// are not probed as restarting Thea will not fix an unreachable database.
+	ec.GET(path+"/healthz", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, probeResponse{Status: probeStatusUp})
+	})
+
+	ec.GET(path+"/readyz", func(c echo.Context) error {
+		if !checker.Check(c.Request().Context()).Ready {
+			return c.JSON(http.StatusServiceUnavailable, probeResponse{Status: probeStatusDown})
+		}
+
+		return c.JSON(http.StatusOK, probeResponse{Status: probeStatusUp})
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
@@ -45,17 +46,23 @@ type (
 	AuthenticatedUser struct {
 		UserID      uuid.UUID
 		Permissions []string
+
+		// SessionID is the ID of the session the auth token was issued for. Tokens
+		// issued before sessions were introduced have no session (uuid.Nil).
+		SessionID uuid.UUID
 	}
 
 	authTokenClaims struct {
 		jwt.RegisteredClaims
 		Permissions []string  `json:"permissions"`
 		UserID      uuid.UUID `json:"user_id"`
+		SessionID   uuid.UUID `json:"session_id"`
 	}
 
 	refreshTokenClaims struct {
 		jwt.RegisteredClaims
-		UserID uuid.UUID `json:"user_id"`
+		UserID    uuid.UUID `json:"user_id"`
+		SessionID uuid.UUID `json:"session_id"`
 	}
 
 	Store interface {
@@ -69,6 +76,7 @@ type (
 		store                  Store
 		authTokenSecret        []byte
 		refreshTokenSecret     []byte
+		shareTokenSecret       []byte
 		refreshTokenCookiePath string
 
 		// This map (acting as a set) is used to keep track of
@@ -79,19 +87,12 @@ type (
 		// (which happens automatically some time after their expiration).
 		blacklistedTokens *sync.TypedSyncMap[string, struct{}]
 
-		// This map is used to keep track of which tokens are currently
-		// 'active' for each user. This map is automatically monitored
-		// by the auth provider to clear out tokens shortly after they expire.
-		// When we wish to revoke all tokens associated with a specific user, we
-		// can use this map to fetch the tokens.
-		//
-		// NB: A token does NOT need to exist here in order to be valid, this
-		// is simply a mechanism to track active tokens for the purpose of
-		// revocation if requested.
-		//
-		// NB': Tokens are removed from this map when they are cleaned up
-		// (which happens automatically some time after their expiration).
-		userTokens *sync.TypedSyncMap[uuid.UUID, []string]
+		// This registry is used to keep track of which tokens are currently
+		// 'active' for each session of each user. Tokens are automatically
+		// removed from their session shortly after they expire.
+		// When we wish to revoke a session (or all sessions of a specific user), we
+		// can use this registry to fetch the tokens.
+		sessions *sessionRegistry
 	}
 )
 
@@ -110,22 +111,30 @@ func NewJwtAuth(store Store, refreshRoutePath string, authTokenSecret []byte, re
 		store,
 		authTokenSecret,
 		refreshTokenSecret,
+		deriveShareTokenSecret(authTokenSecret),
 		refreshRoutePath,
 		new(sync.TypedSyncMap[string, struct{}]),
-		new(sync.TypedSyncMap[uuid.UUID, []string]),
+		newSessionRegistry(),
 	}
 }
 
-// generateTokensAndSetCookies generates an auth token and a refresh token
-// using the appropriate secrets and expiries, before storing both of the tokens
-// in the requests cookies.
-func (auth *jwtAuthProvider) GenerateTokenCookies(userID uuid.UUID) (*http.Cookie, *http.Cookie, error) {
-	authToken, authTokenExp, err := auth.generateAccessToken(userID)
+// GenerateTokenCookies begins a new session for the user on the device
+// described by the user agent and IP provided, and generates an auth token
+// and a refresh token for the session using the appropriate secrets and
+// expiries, before storing both of the tokens in the requests cookies.
+func (auth *jwtAuthProvider) GenerateTokenCookies(userID uuid.UUID, userAgent string, ip string) (*http.Cookie, *http.Cookie, error) {
+	return auth.generateSessionTokenCookies(userID, uuid.New(), userAgent, ip)
+}
+
+// generateSessionTokenCookies generates an auth token and a refresh token for
+// the session provided, returning both of the tokens as cookies.
+func (auth *jwtAuthProvider) generateSessionTokenCookies(userID uuid.UUID, sessionID uuid.UUID, userAgent string, ip string) (*http.Cookie, *http.Cookie, error) {
+	authToken, authTokenExp, err := auth.generateAccessToken(userID, sessionID)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	refreshToken, refreshTokenExp, err := auth.generateRefreshToken(userID)
+	refreshToken, refreshTokenExp, err := auth.generateRefreshToken(userID, sessionID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -140,15 +149,11 @@ func (auth *jwtAuthProvider) GenerateTokenCookies(userID uuid.UUID) (*http.Cooki
 		}
 	}()
 
-	// Update our tracked list of tokens for this user, and schedule cleanup
-	// of this token
-	actual, loaded := auth.userTokens.LoadOrStore(userID, []string{authToken, refreshToken})
-	if loaded {
-		auth.userTokens.Store(userID, append(actual, authToken, refreshToken))
-	}
-
-	auth.scheduleUserTokenCleanup(userID, authToken, authTokenExp)
-	auth.scheduleUserTokenCleanup(userID, refreshToken, refreshTokenExp)
+	// Update our tracked list of tokens for this session, and schedule cleanup
+	// of these tokens
+	auth.sessions.track(userID, sessionID, userAgent, ip, authToken, refreshToken)
+	auth.scheduleSessionTokenCleanup(sessionID, authToken, authTokenExp)
+	auth.scheduleSessionTokenCleanup(sessionID, refreshToken, refreshTokenExp)
 
 	// Create and return the token cookies to be set in the response
 	authTokenCookie := createTokenCookie(AuthTokenCookieName, "/", authToken, authTokenExp)
@@ -170,11 +175,18 @@ func (auth *jwtAuthProvider) GetAuthenticatedUserFromContext(ec echo.Context) (*
 }
 
 // RevokeTokensInContext revokes the auth and refresh token in this
-// request context, assuming they are provided. A missing token/cookie
-// is ignored. An expired auth and refresh token is returned, with the intention
-// that they are sent back to the client in the response.
+// request context, assuming they are provided, along with any other tokens
+// issued for the same session. A missing token/cookie is ignored. An expired
+// auth and refresh token is returned, with the intention that they are sent
+// back to the client in the response.
 func (auth *jwtAuthProvider) RevokeTokensInContext(ec echo.Context) (*http.Cookie, *http.Cookie) {
 	if cookie, err := ec.Cookie(AuthTokenCookieName); err == nil && cookie != nil {
+		if user, err := auth.ValidateAuthToken(cookie.Value); err == nil && user.SessionID != uuid.Nil {
+			if err := auth.RevokeSession(user.UserID, user.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+				log.Warnf("Failed to revoke session %s of user %s: %v\n", user.SessionID, user.UserID, err)
+			}
+		}
+
 		auth.revokeToken(cookie.Value)
 	}
 	if cookie, err := ec.Cookie(RefreshTokenCookieName); err == nil && cookie != nil {
@@ -192,10 +204,8 @@ func (auth *jwtAuthProvider) RevokeTokensInContext(ec echo.Context) (*http.Cooki
 // expired auth and refresh cookies with the intention that they are
 // returned to the client in the response.
 func (auth *jwtAuthProvider) RevokeAllForUser(userID uuid.UUID) (*http.Cookie, *http.Cookie) {
-	if grantedTokens, ok := auth.userTokens.Load(userID); ok {
-		for _, granted := range grantedTokens {
-			auth.revokeToken(granted)
-		}
+	for _, granted := range auth.sessions.removeAll(userID) {
+		auth.revokeToken(granted)
 	}
 
 	expired := time.Now().Add(time.Hour * -24)
@@ -204,10 +214,33 @@ func (auth *jwtAuthProvider) RevokeAllForUser(userID uuid.UUID) (*http.Cookie, *
 	return expiredAuthToken, expiredRefreshToken
 }
 
+// Sessions returns the sessions of the user provided which are active, most recently refreshed first.
+func (auth *jwtAuthProvider) Sessions(userID uuid.UUID) []Session {
+	return auth.sessions.list(userID)
+}
+
+// RevokeSession revokes all the tokens issued for the session of the user provided,
+// requiring that the user logs in again on the device the session belongs to. If
+// the user has no such session, ErrSessionNotFound is returned.
+func (auth *jwtAuthProvider) RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error {
+	tokens, err := auth.sessions.remove(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		auth.revokeToken(token)
+	}
+
+	return nil
+}
+
 // RefreshTokens generates new auth and refresh tokens and stores them in
 // the request cookies IF the request contains a valid refresh token. The
-// new cookies are returned to the caller on success.
-func (auth *jwtAuthProvider) RefreshTokens(allegedRefreshToken string) (*http.Cookie, *http.Cookie, error) {
+// new tokens belong to the same session as the refresh token (the device
+// described by the user agent and IP provided is recorded against the
+// session). The new cookies are returned to the caller on success.
+func (auth *jwtAuthProvider) RefreshTokens(allegedRefreshToken string, userAgent string, ip string) (*http.Cookie, *http.Cookie, error) {
 	token, err := auth.validateJWT(allegedRefreshToken, auth.refreshTokenSecret)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to refresh: %w", err)
@@ -222,7 +255,13 @@ func (auth *jwtAuthProvider) RefreshTokens(allegedRefreshToken string) (*http.Co
 		return nil, nil, fmt.Errorf("failed to refresh: %w", err)
 	}
 
-	return auth.GenerateTokenCookies(*userID)
+	// Refresh tokens issued before sessions were introduced begin a new session
+	sessionID := auth.getSessionIDFromClaims(*claims)
+	if sessionID == uuid.Nil {
+		sessionID = uuid.New()
+	}
+
+	return auth.generateSessionTokenCookies(*userID, sessionID, userAgent, ip)
 }
 
 // getSecurityValidator returns a middleware which uses the generated OpenAPI swagger spec to
@@ -230,8 +269,11 @@ func (auth *jwtAuthProvider) RefreshTokens(allegedRefreshToken string) (*http.Co
 // valid. This includes ensuring the request meets the spec, and that
 // the security scheme specified for that request is satisfied (authentication
 // by way of JWT token, and authorization by way of permissions).
-func (auth *jwtAuthProvider) GetSecurityValidatorMiddleware(basePath string) echo.MiddlewareFunc {
-	spec, err := gen.GetSwagger()
+//
+// The spec is provided by the caller (typically the GetSwagger function of a generated
+// package), as each version of the API is served using it's own spec.
+func (auth *jwtAuthProvider) GetSecurityValidatorMiddleware(basePath string, getSpec func() (*openapi3.T, error)) echo.MiddlewareFunc {
+	spec, err := getSpec()
 	if err != nil {
 		panic(fmt.Sprintf("failed to extract swagger spec from generated spec: %s", err))
 	}
@@ -251,6 +293,13 @@ func (auth *jwtAuthProvider) GetSecurityValidatorMiddleware(basePath string) ech
 			return ec.Request().Method == http.MethodOptions
 		},
 		ErrorHandler: func(_ echo.Context, err *echo.HTTPError) error {
+			// Schema validation failures are safe to describe to the user, and
+			// they need the details to be able to correct their request.
+			var reqErr *openapi3filter.RequestError
+			if errors.As(err.Internal, &reqErr) {
+				return gen.NewValidationError(requestErrorViolation(reqErr))
+			}
+
 			// The request validator constructs an Echo HTTPError using
 			// the error our AuthenticationFunc returns. This is
 			// unacceptable as it reveals far too much information about
@@ -265,6 +314,32 @@ func (auth *jwtAuthProvider) GetSecurityValidatorMiddleware(basePath string) ech
 	})
 }
 
+// requestErrorViolation describes the field of the request which caused the
+// request error provided, and the constraint of the OpenAPI schema it violated.
+func requestErrorViolation(err *openapi3filter.RequestError) gen.FieldViolation {
+	path := []string{"body"}
+	if err.Parameter != nil {
+		path = []string{err.Parameter.In, err.Parameter.Name}
+	}
+
+	violation := gen.FieldViolation{Constraint: "schema", Message: err.Reason}
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err.Err, &schemaErr) {
+		path = append(path, schemaErr.JSONPointer()...)
+		violation.Constraint = schemaErr.SchemaField
+		violation.Message = schemaErr.Reason
+	} else if errors.Is(err.Err, openapi3filter.ErrInvalidRequired) || errors.Is(err.Err, openapi3filter.ErrInvalidEmptyValue) {
+		violation.Constraint = "required"
+	}
+
+	if violation.Message == "" && err.Err != nil {
+		violation.Message = err.Err.Error()
+	}
+	violation.Field = strings.Join(path, ".")
+
+	return violation
+}
+
 func (auth *jwtAuthProvider) getPermissionsReferencedBySpec(spec *openapi3.T) map[string]struct{} {
 	referencedPermissions := make(map[string]struct{})
 	for _, security := range spec.Security {
@@ -361,7 +436,7 @@ func (auth *jwtAuthProvider) validateTokenFromAuthInput(ctx context.Context, aut
 	// Insert user info inside of request context to allow for
 	// endpoint handlers to extract user information
 	eCtx := middleware.GetEchoContext(ctx)
-	eCtx.Set("user", &AuthenticatedUser{UserID: *userID, Permissions: userPermissions})
+	eCtx.Set("user", &AuthenticatedUser{UserID: *userID, Permissions: userPermissions, SessionID: auth.getSessionIDFromClaims(*claims)})
 
 	return nil
 }
@@ -379,12 +454,28 @@ func (auth *jwtAuthProvider) ValidateTokenFromRequest(ec echo.Context, request *
 		return nil, ErrAuthTokenMissing
 	}
 
-	token, err := auth.validateJWT(tokenCookie.Value, auth.authTokenSecret)
+	authUser, err := auth.ValidateAuthToken(tokenCookie.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Insert user info inside of request context to allow for
+	// endpoint handlers to extract user information
+	ec.Set("user", authUser)
+
+	return authUser, nil
+}
+
+// ValidateAuthToken validates the auth token provided, returning the user
+// it was issued to, and the permissions they hold. This is used by clients which do
+// not use cookies (e.g. the gRPC API), and so provide the auth token directly.
+func (auth *jwtAuthProvider) ValidateAuthToken(token string) (*AuthenticatedUser, error) {
+	validated, err := auth.validateJWT(token, auth.authTokenSecret)
 	if err != nil {
 		return nil, fmt.Errorf("validation of auth token failed: %w", err)
 	}
 
-	claims, ok := token.Claims.(*jwt.MapClaims)
+	claims, ok := validated.Claims.(*jwt.MapClaims)
 	if !ok {
 		return nil, errors.New("failed to cast JWT claims to MapClaims")
 	}
@@ -395,18 +486,12 @@ func (auth *jwtAuthProvider) ValidateTokenFromRequest(ec echo.Context, request *
 		return nil, err
 	}
 
-	// Grab user permissions so we can store them in the context
 	userPermissions, err := auth.getPermissionsFromClaims(*claims)
 	if err != nil {
 		return nil, err
 	}
 
-	// Insert user info inside of request context to allow for
-	// endpoint handlers to extract user information
-	authUser := &AuthenticatedUser{UserID: *userID, Permissions: userPermissions}
-	ec.Set("user", authUser)
-
-	return authUser, nil
+	return &AuthenticatedUser{UserID: *userID, Permissions: userPermissions, SessionID: auth.getSessionIDFromClaims(*claims)}, nil
 }
 
 func (auth *jwtAuthProvider) getPermissionsFromClaims(claims jwt.MapClaims) ([]string, error) {
@@ -471,11 +556,13 @@ func (auth *jwtAuthProvider) validateJWT(token string, secret []byte) (*jwt.Toke
 // which can be used to authenticate against protected API endpoints. This
 // token also includes the associated user permissions at the time of
 // generation, which allows for the server to restrict access to certain
-// endpoints if the user does not have the required permissions.
+// endpoints if the user does not have the required permissions. The
+// permissions embedded are the resolved set for the user, which includes
+// the permissions granted by any roles the user is a member of.
 //
 // (Shortly) before this token expires, it is expected that the client will
 // refresh their tokens using their refreshToken.
-func (auth *jwtAuthProvider) generateAccessToken(userID uuid.UUID) (string, time.Time, error) {
+func (auth *jwtAuthProvider) generateAccessToken(userID uuid.UUID, sessionID uuid.UUID) (string, time.Time, error) {
 	user, err := auth.store.GetUserWithID(userID)
 	if err != nil {
 		return "", time.Now(), fmt.Errorf("failed to fetch user %s during auth token generation: %w", userID, err)
@@ -484,6 +571,7 @@ func (auth *jwtAuthProvider) generateAccessToken(userID uuid.UUID) (string, time
 	exp := time.Now().Add(AuthTokenLifespan)
 	claims := &authTokenClaims{
 		UserID:      userID,
+		SessionID:   sessionID,
 		Permissions: user.Permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    auth.refreshTokenCookiePath,
@@ -501,8 +589,8 @@ func (auth *jwtAuthProvider) generateAccessToken(userID uuid.UUID) (string, time
 }
 
 // generateRefreshToken accepts a userID and generates a long-life token
-// which can be used to generate more auth tokens by the client.
-func (auth *jwtAuthProvider) generateRefreshToken(userID uuid.UUID) (string, time.Time, error) {
+// which can be used to generate more auth tokens (for the same session) by the client.
+func (auth *jwtAuthProvider) generateRefreshToken(userID uuid.UUID, sessionID uuid.UUID) (string, time.Time, error) {
 	_, err := auth.store.GetUserWithID(userID)
 	if err != nil {
 		return "", time.Now(), fmt.Errorf("failed to fetch user %s during refresh token generation: %w", userID, err)
@@ -510,7 +598,8 @@ func (auth *jwtAuthProvider) generateRefreshToken(userID uuid.UUID) (string, tim
 
 	exp := time.Now().Add(RefreshTokenLifespan)
 	claims := &refreshTokenClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    auth.refreshTokenCookiePath,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -526,26 +615,22 @@ func (auth *jwtAuthProvider) generateRefreshToken(userID uuid.UUID) (string, tim
 	return token, exp, nil
 }
 
-// scheduleUserTokenCleanup will remove the specified token from the users token map
+// scheduleSessionTokenCleanup will remove the specified token from its session
 // at the time specified. This allows for us to store any newly generated
-// user tokens inside the map without worrying about the size of the map
-// growing with no limit.
-func (auth *jwtAuthProvider) scheduleUserTokenCleanup(userID uuid.UUID, token string, expiry time.Time) {
+// tokens inside the session registry without worrying about the size of the registry
+// growing with no limit. Sessions are removed once all of their tokens have expired.
+func (auth *jwtAuthProvider) scheduleSessionTokenCleanup(sessionID uuid.UUID, token string, expiry time.Time) {
 	until := time.Until(expiry.Add(tokenExpiryCleanupDelay))
-	log.Debugf("Scheduling cleanup of a token for user %s in %s\n", userID, until)
+	log.Debugf("Scheduling cleanup of a token for session %s in %s\n", sessionID, until)
 
 	time.AfterFunc(until, func() {
-		log.Debugf("Cleaning up token %s for user %s as it has expired (~5 seconds ago)\n", token, userID)
+		log.Debugf("Cleaning up token %s for session %s as it has expired (~5 seconds ago)\n", token, sessionID)
 
 		// Clear from blacklist as it won't be accepted now due to expiring anyway
 		auth.blacklistedTokens.Delete(token)
 
-		// Clear from our user tokens mapping as the token will not need to be revoked now that it has expired
-		userTokens, ok := auth.userTokens.Load(userID)
-		if ok && len(userTokens) > 0 {
-			newUserTokens := slices.DeleteFunc(userTokens, func(tk string) bool { return tk == token })
-			auth.userTokens.Store(userID, newUserTokens)
-		}
+		// Clear from our session registry as the token will not need to be revoked now that it has expired
+		auth.sessions.forget(sessionID, token)
 	})
 }
 
@@ -561,6 +646,18 @@ func (auth *jwtAuthProvider) getUserIDFromClaims(claims jwt.MapClaims) (*uuid.UU
 	}
 }
 
+// getSessionIDFromClaims returns the ID of the session the token was issued for,
+// or uuid.Nil if the token was issued before sessions were introduced.
+func (auth *jwtAuthProvider) getSessionIDFromClaims(claims jwt.MapClaims) uuid.UUID {
+	if sessionID, ok := claims["session_id"].(string); ok {
+		if id, err := uuid.Parse(sessionID); err == nil {
+			return id
+		}
+	}
+
+	return uuid.Nil
+}
+
 func (auth *jwtAuthProvider) revokeToken(token string) {
 	log.Debugf("Revoking token %s\n", token)
 	auth.blacklistedTokens.Store(token, struct{}{})
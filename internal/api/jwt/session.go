@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type (
+	// Session is a login of a user on a single device (browser, app, etc). A session begins
+	// when the user logs in, and every pair of tokens issued to the device (including those
+	// issued when refreshing) belong to the same session. A session ends when it's revoked
+	// (by logging out), or when all the tokens issued for it have expired.
+	Session struct {
+		ID          uuid.UUID
+		UserID      uuid.UUID
+		UserAgent   string
+		IP          string
+		CreatedAt   time.Time
+		RefreshedAt time.Time
+
+		// The tokens issued for this session which are yet to expire. These are
+		// revoked when the session is, so that the device must login again.
+		tokens []string
+	}
+
+	// sessionRegistry tracks the sessions which are active for each user, so that users
+	// can see where they're logged in, and revoke sessions remotely.
+	//
+	// NB: A token does NOT need to belong to a session in this registry in order to be
+	// valid (the registry is not persisted, so sessions established before Thea was
+	// restarted are unknown until their tokens are next refreshed). The registry is
+	// simply a mechanism to track active tokens for the purpose of revocation.
+	sessionRegistry struct {
+		*sync.Mutex
+		sessions map[uuid.UUID]*Session
+	}
+)
+
+func newSessionRegistry() *sessionRegistry {
+	return &sessionRegistry{Mutex: &sync.Mutex{}, sessions: make(map[uuid.UUID]*Session)}
+}
+
+// track records that the tokens provided have been issued to the device given as part of the
+// session with the ID provided, creating the session if it's not already known.
+func (registry *sessionRegistry) track(userID uuid.UUID, sessionID uuid.UUID, userAgent string, ip string, tokens ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	now := time.Now()
+	session, ok := registry.sessions[sessionID]
+	if !ok || session.UserID != userID {
+		session = &Session{ID: sessionID, UserID: userID, CreatedAt: now}
+		registry.sessions[sessionID] = session
+	}
+
+	session.UserAgent = userAgent
+	session.IP = ip
+	session.RefreshedAt = now
+	session.tokens = append(session.tokens, tokens...)
+}
+
+// forget removes the token provided from the session given (e.g. because the token has
+// expired). The session is removed if none of the tokens issued for it remain.
+func (registry *sessionRegistry) forget(sessionID uuid.UUID, token string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	session, ok := registry.sessions[sessionID]
+	if !ok {
+		return
+	}
+
+	session.tokens = slices.DeleteFunc(session.tokens, func(tk string) bool { return tk == token })
+	if len(session.tokens) == 0 {
+		delete(registry.sessions, sessionID)
+	}
+}
+
+// remove removes the session of the user provided with the ID given, returning the
+// tokens issued for it. If the session is not known, ErrSessionNotFound is returned.
+func (registry *sessionRegistry) remove(userID uuid.UUID, sessionID uuid.UUID) ([]string, error) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	session, ok := registry.sessions[sessionID]
+	if !ok || session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+
+	delete(registry.sessions, sessionID)
+	return session.tokens, nil
+}
+
+// removeAll removes all the sessions of the user provided, returning the tokens issued for them.
+func (registry *sessionRegistry) removeAll(userID uuid.UUID) []string {
+	registry.Lock()
+	defer registry.Unlock()
+
+	tokens := make([]string, 0)
+	for id, session := range registry.sessions {
+		if session.UserID == userID {
+			tokens = append(tokens, session.tokens...)
+			delete(registry.sessions, id)
+		}
+	}
+
+	return tokens
+}
+
+// list returns a copy of the sessions of the user provided, most recently refreshed first.
+func (registry *sessionRegistry) list(userID uuid.UUID) []Session {
+	registry.Lock()
+	defer registry.Unlock()
+
+	sessions := make([]Session, 0)
+	for _, session := range registry.sessions {
+		if session.UserID == userID {
+			copied := *session
+			copied.tokens = nil
+			sessions = append(sessions, copied)
+		}
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int { return b.RefreshedAt.Compare(a.RefreshedAt) })
+	return sessions
+}
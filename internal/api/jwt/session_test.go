@@ -0,0 +1,70 @@
+package jwt
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RevokedSession_RefreshTokenRejected(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+	userID := uuid.New()
+
+	authCookie, refreshCookie, err := auth.GenerateTokenCookies(userID, "firefox", "127.0.0.1")
+	assert.NoError(t, err)
+
+	sessions := auth.Sessions(userID)
+	assert.Len(t, sessions, 1)
+	assert.NoError(t, auth.RevokeSession(userID, sessions[0].ID))
+	assert.Empty(t, auth.Sessions(userID))
+
+	_, _, err = auth.RefreshTokens(refreshCookie.Value, "firefox", "127.0.0.1")
+	assert.Error(t, err, "refresh token of a revoked session should be rejected")
+	_, err = auth.ValidateAuthToken(authCookie.Value)
+	assert.Error(t, err, "auth token of a revoked session should be rejected")
+}
+
+func Test_RefreshedSession_RevokesRefreshedTokens(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+	userID := uuid.New()
+
+	_, refreshCookie, err := auth.GenerateTokenCookies(userID, "firefox", "127.0.0.1")
+	assert.NoError(t, err)
+	_, refreshedCookie, err := auth.RefreshTokens(refreshCookie.Value, "firefox", "127.0.0.1")
+	assert.NoError(t, err)
+
+	// Refreshing continues the existing session, rather than beginning another
+	sessions := auth.Sessions(userID)
+	assert.Len(t, sessions, 1)
+	assert.NoError(t, auth.RevokeSession(userID, sessions[0].ID))
+
+	_, _, err = auth.RefreshTokens(refreshedCookie.Value, "firefox", "127.0.0.1")
+	assert.Error(t, err)
+}
+
+func Test_Session_NotAccessibleToOtherUsers(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+	owner, other := uuid.New(), uuid.New()
+
+	_, refreshCookie, err := auth.GenerateTokenCookies(owner, "firefox", "127.0.0.1")
+	assert.NoError(t, err)
+	_, _, err = auth.GenerateTokenCookies(other, "chrome", "10.0.0.1")
+	assert.NoError(t, err)
+
+	ownerSessions := auth.Sessions(owner)
+	assert.Len(t, ownerSessions, 1)
+	for _, session := range auth.Sessions(other) {
+		assert.Equal(t, other, session.UserID)
+		assert.NotEqual(t, ownerSessions[0].ID, session.ID, "sessions of another user should not be listed")
+	}
+
+	assert.ErrorIs(t, auth.RevokeSession(other, ownerSessions[0].ID), ErrSessionNotFound)
+	assert.Len(t, auth.Sessions(owner), 1, "session should not be revoked by another user")
+
+	_, _, err = auth.RefreshTokens(refreshCookie.Value, "firefox", "127.0.0.1")
+	assert.NoError(t, err)
+}
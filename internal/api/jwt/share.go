@@ -0,0 +1,70 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// shareTokenIssuer is the issuer of share tokens, which is validated when a share token is parsed.
+const shareTokenIssuer = "thea-share-links"
+
+var ErrShareTokenInvalid = errors.New("share token is invalid or has expired")
+
+// shareTokenClaims are the claims of the token used to access a share link (see user.ShareLink).
+type shareTokenClaims struct {
+	jwt.RegisteredClaims
+	ShareID uuid.UUID `json:"share_id"`
+}
+
+// deriveShareTokenSecret returns the secret used to sign share tokens, which is derived from
+// the auth token secret so that a share token can never be accepted as an auth token (or
+// vice versa).
+func deriveShareTokenSecret(authTokenSecret []byte) []byte {
+	mac := hmac.New(sha256.New, authTokenSecret)
+	mac.Write([]byte("thea-share-links"))
+	return mac.Sum(nil)
+}
+
+// GenerateShareToken generates the token used to access the share link with the ID provided, which
+// expires at the time given. The token carries no permissions; it simply identifies the share link.
+func (auth *jwtAuthProvider) GenerateShareToken(shareID uuid.UUID, expiresAt time.Time) (string, error) {
+	claims := &shareTokenClaims{
+		ShareID: shareID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    shareTokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := generateToken(claims, auth.shareTokenSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ValidateShareToken returns the ID of the share link the token provided was generated for, or
+// ErrShareTokenInvalid if the token was not issued for a share link by Thea, or has expired.
+func (auth *jwtAuthProvider) ValidateShareToken(token string) (uuid.UUID, error) {
+	claims := &shareTokenClaims{}
+	tkn, err := jwt.ParseWithClaims(
+		token,
+		claims,
+		func(token *jwt.Token) (interface{}, error) { return auth.shareTokenSecret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(shareTokenIssuer),
+	)
+	if err != nil || tkn == nil || !tkn.Valid || claims.ExpiresAt == nil || claims.ShareID == uuid.Nil {
+		return uuid.Nil, ErrShareTokenInvalid
+	}
+
+	return claims.ShareID, nil
+}
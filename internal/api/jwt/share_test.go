@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockUserStore struct{ Store }
+
+func (store mockUserStore) GetUserWithID(id uuid.UUID) (*user.User, error) {
+	u := &user.User{Permissions: []string{}}
+	u.ID = id
+	return u, nil
+}
+
+func (store mockUserStore) RecordUserLogin(uuid.UUID) error   { return nil }
+func (store mockUserStore) RecordUserRefresh(uuid.UUID) error { return nil }
+
+func newTestAuth() *jwtAuthProvider {
+	return NewJwtAuth(mockUserStore{}, "/api/thea/v1/auth/refresh", []byte("auth-secret"), []byte("refresh-secret"))
+}
+
+func Test_ShareToken_RoundTrip(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+	shareID := uuid.New()
+
+	token, err := auth.GenerateShareToken(shareID, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	validatedID, err := auth.ValidateShareToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, shareID, validatedID)
+}
+
+func Test_ShareToken_RejectedAsAuthToken(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+
+	token, err := auth.GenerateShareToken(uuid.New(), time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	_, err = auth.ValidateAuthToken(token)
+	assert.Error(t, err)
+}
+
+func Test_AuthToken_RejectedAsShareToken(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+
+	token, _, err := auth.generateAccessToken(uuid.New(), uuid.New())
+	assert.NoError(t, err)
+
+	_, err = auth.ValidateShareToken(token)
+	assert.ErrorIs(t, err, ErrShareTokenInvalid)
+}
+
+func Test_ShareToken_RequiresShareIssuer(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+
+	// Signed using the share secret, but not issued for a share link
+	token, err := generateToken(&shareTokenClaims{
+		ShareID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    auth.refreshTokenCookiePath,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}, auth.shareTokenSecret)
+	assert.NoError(t, err)
+
+	_, err = auth.ValidateShareToken(token)
+	assert.ErrorIs(t, err, ErrShareTokenInvalid)
+}
+
+func Test_ShareToken_Expired(t *testing.T) {
+	t.Parallel()
+	auth := newTestAuth()
+
+	token, err := auth.GenerateShareToken(uuid.New(), time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+
+	_, err = auth.ValidateShareToken(token)
+	assert.ErrorIs(t, err, ErrShareTokenInvalid)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/api/controllers/system"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/labstack/echo/v4"
+)
+
+type MaintenanceMode interface {
+	system.MaintenanceMode
+	RetryAfter() time.Duration
+}
+
+// maintenanceExemptOperations are the operations which modify Thea's state, but which
+// remain available while in maintenance mode. Authentication must continue to work, backups
+// are one of the tasks maintenance mode is intended to allow, and playback is unaffected (and
+// so watch progress and playback sessions must continue to be reported).
+var maintenanceExemptOperations = map[string]struct{}{
+	"Login":                  {},
+	"LoginWithTrustedHeader": {},
+	"Refresh":                {},
+	"LogoutSession":          {},
+	"LogoutAll":              {},
+	"RevokeOwnSession":       {},
+	"RevokeUserSession":      {},
+	"SetMaintenanceMode":     {},
+	"CreateBackup":           {},
+	"ReloadConfig":           {},
+	"DryRunIngest":           {},
+	"UpdateWatchProgress":    {},
+
+	"StartPlaybackSession":   {},
+	"RefreshPlaybackSession": {},
+	"EndPlaybackSession":     {},
+}
+
+// newMaintenanceMiddleware returns a strict middleware which, while maintenance mode is
+// enabled, rejects requests to all operations which modify Thea's state (i.e. all
+// requests other than GET/HEAD/OPTIONS, excluding maintenanceExemptOperations)
+// with a 503, indicating when the client should retry.
+func newMaintenanceMiddleware(mode MaintenanceMode) gen.StrictMiddlewareFunc {
+	return func(f gen.StrictHandlerFunc, operationID string) gen.StrictHandlerFunc {
+		if _, ok := maintenanceExemptOperations[operationID]; ok {
+			return f
+		}
+
+		return func(ec echo.Context, request interface{}) (interface{}, error) {
+			switch ec.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return f(ec, request)
+			}
+
+			state := mode.State()
+			if !state.Enabled {
+				return f(ec, request)
+			}
+
+			ec.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(mode.RetryAfter().Seconds())))
+			message := "Thea is in maintenance mode, changes cannot be made until maintenance is complete"
+			if state.Reason != "" {
+				message = fmt.Sprintf("%s (%s)", message, state.Reason)
+			}
+			return nil, gen.APIError{Status: http.StatusServiceUnavailable, Code: "system.maintenance", Message: message}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// parseTrustedProxies parses the trusted proxies provided, each of which
+// may be an IP address or a CIDR range.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	ranges := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("'%s' is not a valid IP address or CIDR range", proxy)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid IP address or CIDR range", proxy)
+		}
+		ranges = append(ranges, ipRange)
+	}
+
+	return ranges, nil
+}
+
+// newIPExtractor returns the extractor used to determine the IP address of the client
+// which made a request. The X-Forwarded-For header is only considered for requests from
+// the trusted proxies, as otherwise a client could spoof it's IP address (e.g. to evade
+// rate limiting). If no proxies are trusted, the address of the connection is used.
+func newIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	ranges, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, ipRange := range ranges {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+
+	// rateLimitSweepInterval is how often buckets which have refilled (and
+	// so are indistinguishable from a new bucket) are discarded.
+	rateLimitSweepInterval = time.Minute
+)
+
+type (
+	// RateLimitConfig configures the token buckets used to limit the rate of requests to the
+	// API. Each client IP address, and each authenticated user, has a bucket holding up to 'burst'
+	// tokens, which refills at 'requests_per_second'. Each request consumes a token, and requests
+	// made while the bucket is empty are rejected. A rate of zero disables the limit.
+	RateLimitConfig struct {
+		IPRequestsPerSecond   float64 `toml:"ip_requests_per_second" env:"API_RATE_LIMIT_IP_RPS" env-default:"20"`
+		IPBurst               int     `toml:"ip_burst" env:"API_RATE_LIMIT_IP_BURST" env-default:"100"`
+		UserRequestsPerSecond float64 `toml:"user_requests_per_second" env:"API_RATE_LIMIT_USER_RPS" env-default:"20"`
+		UserBurst             int     `toml:"user_burst" env:"API_RATE_LIMIT_USER_BURST" env-default:"100"`
+	}
+
+	tokenBucket struct {
+		tokens    float64
+		updatedAt time.Time
+	}
+
+	// rateLimiter maintains a token bucket per key (e.g. IP address).
+	rateLimiter struct {
+		mutex     sync.Mutex
+		rate      float64
+		burst     int
+		buckets   map[string]*tokenBucket
+		lastSweep time.Time
+		now       func() time.Time
+	}
+)
+
+func (config *RateLimitConfig) Validate() error {
+	errs := make([]error, 0)
+	if config.IPRequestsPerSecond < 0 {
+		errs = append(errs, errors.New("rate_limit.ip_requests_per_second: must not be negative"))
+	}
+	if config.IPRequestsPerSecond > 0 && config.IPBurst < 1 {
+		errs = append(errs, errors.New("rate_limit.ip_burst: must be at least 1"))
+	}
+	if config.UserRequestsPerSecond < 0 {
+		errs = append(errs, errors.New("rate_limit.user_requests_per_second: must not be negative"))
+	}
+	if config.UserRequestsPerSecond > 0 && config.UserBurst < 1 {
+		errs = append(errs, errors.New("rate_limit.user_burst: must be at least 1"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+// allow consumes a token from the bucket for the key provided, returning true if a token was
+// available. The number of tokens remaining is returned, along with the time until the next token
+// is available if the request was not allowed.
+func (limiter *rateLimiter) allow(key string) (bool, int, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	limiter.sweep(now)
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limiter.burst), updatedAt: now}
+		limiter.buckets[key] = bucket
+	} else {
+		bucket.tokens = limiter.refill(bucket, now)
+		bucket.updatedAt = now
+	}
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / limiter.rate * float64(time.Second))
+		return false, 0, wait
+	}
+
+	bucket.tokens--
+	return true, int(bucket.tokens), 0
+}
+
+func (limiter *rateLimiter) refill(bucket *tokenBucket, now time.Time) float64 {
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	return math.Min(float64(limiter.burst), bucket.tokens+elapsed*limiter.rate)
+}
+
+// sweep discards the buckets which have fully refilled, so that the
+// limiter does not grow unbounded as new clients make requests.
+func (limiter *rateLimiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+
+	limiter.lastSweep = now
+	for key, bucket := range limiter.buckets {
+		if limiter.refill(bucket, now) >= float64(limiter.burst) {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// limit consumes a token for the key provided, setting the rate limit headers on the
+// response. If the request is not allowed, a 429 error is returned which the caller
+// must return instead of handling the request.
+func (limiter *rateLimiter) limit(ec echo.Context, key string) error {
+	allowed, remaining, wait := limiter.allow(key)
+
+	header := ec.Response().Header()
+	header.Set(headerRateLimitLimit, strconv.Itoa(limiter.burst))
+	header.Set(headerRateLimitRemaining, strconv.Itoa(remaining))
+	if !allowed {
+		header.Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return gen.APIError{Status: http.StatusTooManyRequests, Code: "request.rate_limited", Message: fmt.Sprintf("rate limit exceeded, retry in %s", wait.Round(time.Second))}
+	}
+
+	return nil
+}
+
+// newIPRateLimitMiddleware returns a middleware which limits the rate
+// of requests from each client IP address.
+func newIPRateLimitMiddleware(config RateLimitConfig) echo.MiddlewareFunc {
+	limiter := newRateLimiter(config.IPRequestsPerSecond, config.IPBurst)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ec echo.Context) error {
+			if config.IPRequestsPerSecond <= 0 {
+				return next(ec)
+			}
+			if err := limiter.limit(ec, ec.RealIP()); err != nil {
+				return err
+			}
+
+			return next(ec)
+		}
+	}
+}
+
+// newUserRateLimitMiddleware returns a strict middleware which limits the rate of requests
+// from each authenticated user, regardless of the IP address the requests originate from.
+// Unauthenticated requests are only subject to the IP rate limit.
+func newUserRateLimitMiddleware(config RateLimitConfig) gen.StrictMiddlewareFunc {
+	limiter := newRateLimiter(config.UserRequestsPerSecond, config.UserBurst)
+	return func(f gen.StrictHandlerFunc, operationID string) gen.StrictHandlerFunc {
+		if config.UserRequestsPerSecond <= 0 {
+			return f
+		}
+
+		return func(ec echo.Context, request interface{}) (interface{}, error) {
+			if user, ok := ec.Get("user").(*jwt.AuthenticatedUser); ok {
+				if err := limiter.limit(ec, user.UserID.String()); err != nil {
+					return nil, err
+				}
+			}
+
+			return f(ec, request)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RateLimiterRefillsTokens(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 2; i >= 0; i-- {
+		allowed, remaining, _ := limiter.allow("a")
+		assert.True(t, allowed)
+		assert.Equal(t, i, remaining)
+	}
+
+	allowed, _, wait := limiter.allow("a")
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// Other keys have their own bucket
+	allowed, _, _ = limiter.allow("b")
+	assert.True(t, allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	allowed, remaining, _ := limiter.allow("a")
+	assert.True(t, allowed)
+	assert.Equal(t, 0, remaining)
+}
+
+func Test_RateLimiterSweepsFullBuckets(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(1, 5)
+	limiter.now = func() time.Time { return now }
+
+	limiter.allow("a")
+	limiter.allow("b")
+	assert.Len(t, limiter.buckets, 2)
+
+	now = now.Add(rateLimitSweepInterval)
+	limiter.allow("c")
+	assert.Len(t, limiter.buckets, 1)
+}
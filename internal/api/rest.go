@@ -6,24 +6,41 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/hbomb79/Thea/internal/api/controllers/audits"
 	"github.com/hbomb79/Thea/internal/api/controllers/auth"
+	"github.com/hbomb79/Thea/internal/api/controllers/backups"
+	"github.com/hbomb79/Thea/internal/api/controllers/collections"
+	"github.com/hbomb79/Thea/internal/api/controllers/deletions"
+	"github.com/hbomb79/Thea/internal/api/controllers/images"
 	"github.com/hbomb79/Thea/internal/api/controllers/ingests"
+	"github.com/hbomb79/Thea/internal/api/controllers/invites"
+	"github.com/hbomb79/Thea/internal/api/controllers/libraries"
 	"github.com/hbomb79/Thea/internal/api/controllers/medias"
+	"github.com/hbomb79/Thea/internal/api/controllers/remotes"
+	"github.com/hbomb79/Thea/internal/api/controllers/roles"
+	"github.com/hbomb79/Thea/internal/api/controllers/shares"
+	"github.com/hbomb79/Thea/internal/api/controllers/statistics"
+	"github.com/hbomb79/Thea/internal/api/controllers/system"
+	"github.com/hbomb79/Thea/internal/api/controllers/tags"
 	"github.com/hbomb79/Thea/internal/api/controllers/targets"
 	"github.com/hbomb79/Thea/internal/api/controllers/transcodes"
 	"github.com/hbomb79/Thea/internal/api/controllers/users"
 	"github.com/hbomb79/Thea/internal/api/controllers/workflows"
 	"github.com/hbomb79/Thea/internal/api/gen"
+	genv2 "github.com/hbomb79/Thea/internal/api/gen/v2"
 	"github.com/hbomb79/Thea/internal/api/jwt"
 	"github.com/hbomb79/Thea/internal/http/websocket"
 	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/bytes"
 )
 
 const (
@@ -38,6 +55,42 @@ var (
 type (
 	RestConfig struct {
 		HostAddr string `toml:"host_address" env:"API_HOST_ADDR" env-default:"0.0.0.0:8080"`
+
+		// EnableDebugEndpoints exposes the pprof handlers and runtime statistics
+		// under /debug. Users must have the 'debug:read' permission to access them.
+		EnableDebugEndpoints bool `toml:"enable_debug_endpoints" env:"API_ENABLE_DEBUG_ENDPOINTS" env-default:"false"`
+
+		// EnableGraphQL exposes a GraphQL endpoint at /graphql, allowing clients to fetch
+		// media, seasons, episodes, transcodes and watch targets in a single request.
+		EnableGraphQL bool `toml:"enable_graphql" env:"API_ENABLE_GRAPHQL" env-default:"false"`
+
+		// ActivityHistorySize is the number of recent activity messages retained so
+		// that reconnecting clients can replay the activity they missed.
+		ActivityHistorySize int `toml:"activity_history_size" env:"API_ACTIVITY_HISTORY_SIZE" env-default:"1000"`
+
+		// JwtAuthSecret and JwtRefreshSecret are used to sign the auth and refresh tokens issued
+		// by Thea, and must each be at least 64 bytes long. If omitted, a random secret is generated
+		// each time Thea starts, which invalidates all issued tokens (signing all users out) on restart.
+		JwtAuthSecret    string `toml:"jwt_auth_secret" env:"API_JWT_AUTH_SECRET"`
+		JwtRefreshSecret string `toml:"jwt_refresh_secret" env:"API_JWT_REFRESH_SECRET"`
+
+		// MaxBodySize is the largest request body the API accepts (e.g. '512K', '2M'). Requests
+		// with larger bodies are rejected with a 413.
+		MaxBodySize string          `toml:"max_body_size" env:"API_MAX_BODY_SIZE" env-default:"1M"`
+		RateLimit   RateLimitConfig `toml:"rate_limit"`
+		CORS        CORSConfig      `toml:"cors"`
+
+		// BasePath is the URL prefix Thea is served under (e.g. '/thea'), allowing Thea to
+		// be served alongside other applications on a domain by a reverse proxy which does
+		// not strip the prefix.
+		BasePath string `toml:"base_path" env:"API_BASE_PATH"`
+
+		// TrustedProxies are the IP addresses/CIDR ranges of the reverse proxies in front of
+		// Thea. The client IP is only taken from the X-Forwarded-For header of requests
+		// which arrive via these proxies.
+		TrustedProxies []string `toml:"trusted_proxies" env:"API_TRUSTED_PROXIES" env-separator:","`
+
+		TrustedHeaderAuth TrustedHeaderAuthConfig `toml:"trusted_header_auth"`
 	}
 
 	Controller interface {
@@ -51,14 +104,34 @@ type (
 		workflows.Store
 		transcodes.Store
 		medias.Store
+		collections.Store
+		tags.Store
+		libraries.Store
 		auth.Store
 		users.Store
+		roles.Store
+		invites.Store
+		shares.Store
+		audits.Store
+		statistics.Store
+		system.Store
+		AuditStore
+		TrustedHeaderStore
+		ShareStore
 		jwt.Store
 	}
 
 	TranscodeService interface {
 		medias.TranscodeService
 		transcodes.TranscodeService
+		users.TranscodeService
+		workflows.TranscodeService
+		targets.PreviewService
+	}
+
+	TraktService interface {
+		auth.TraktService
+		medias.Scrobbler
 	}
 
 	// strictServerImpl offers an implementation of the generated
@@ -68,10 +141,30 @@ type (
 		*ingests.IngestsController
 		*auth.AuthController
 		*users.UserController
+		*roles.RoleController
+		*invites.InviteController
+		*shares.ShareController
+		*audits.AuditController
 		*medias.MediaController
+		*collections.CollectionController
+		*tags.TagController
+		*libraries.LibraryController
+		*deletions.DeletionController
 		*transcodes.TranscodesController
 		*targets.TargetController
 		*workflows.WorkflowController
+		*backups.BackupController
+		*statistics.StatisticsController
+		*system.SystemController
+		*remotes.RemoteSourceController
+		*images.ImageController
+	}
+
+	// strictServerImplV2 offers an implementation of the StrictServerInterface
+	// generated from version 2 of the OpenAPI spec. Only endpoints which have
+	// changed since version 1 are present in this version.
+	strictServerImplV2 struct {
+		*medias.MediaControllerV2
 	}
 
 	// The RestGateway is a thin-wrapper around the Echo HTTP router. It's sole responsbility
@@ -79,9 +172,14 @@ type (
 	// and to enforce authc + authz middleware where applicable.
 	RestGateway struct {
 		*broadcaster
-		config *RestConfig
-		ec     *echo.Echo
-		socket *websocket.SocketHub
+		config         *RestConfig
+		ec             *echo.Echo
+		socket         *websocket.SocketHub
+		tokenValidator tokenValidator
+	}
+
+	tokenValidator interface {
+		ValidateAuthToken(token string) (*jwt.AuthenticatedUser, error)
 	}
 )
 
@@ -92,11 +190,29 @@ func NewRestGateway(
 	config *RestConfig,
 	ingestService ingests.IngestService,
 	transcodeService TranscodeService,
+	backupService backups.BackupService,
+	consistencyService system.ConsistencyService,
+	exportService system.ExportService,
+	deletionService deletions.DeletionService,
+	benchmarkService targets.BenchmarkService,
+	scraper system.Scraper,
+	configReloader system.ConfigReloader,
+	systemInfo system.SystemInfoProvider,
+	maintenanceMode MaintenanceMode,
+	healthChecker system.HealthChecker,
+	remoteSourceService remotes.RemoteSourceService,
+	artworkService images.ArtworkService,
+	traktService TraktService,
 	store Store,
+	monitoredPaths map[string]string,
+	targetValidator targets.TargetValidator,
 ) *RestGateway {
 	// -- Setup JWT auth provider --
-	apiBasePath := "/api/thea/v1"
-	authKey, refreshKey, err := newJwtSigningKeys()
+	// Auth endpoints are only served by version 1 of the API, however the
+	// tokens issued are accepted by all versions.
+	apiBasePath := config.basePath() + "/api/thea/v1"
+	apiV2BasePath := config.basePath() + "/api/thea/v2"
+	authKey, refreshKey, err := newJwtSigningKeys(config)
 	if err != nil {
 		panic(err)
 	}
@@ -104,28 +220,43 @@ func NewRestGateway(
 
 	// -- Setup Middleware --
 	ec := echo.New()
-	ec.HTTPErrorHandler = gen.GetHTTPErrorHandler(ec.DefaultHTTPErrorHandler)
+	ec.HTTPErrorHandler = gen.GetHTTPErrorHandler(toAPIError)
 	ec.OnAddRouteHandler = func(_ string, route echo.Route, _ echo.HandlerFunc, _ []echo.MiddlewareFunc) {
 		log.Emit(logger.DEBUG, "Registered new route %s %s\n", route.Method, route.Path)
 	}
+	ipExtractor, err := newIPExtractor(config.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	ec.IPExtractor = ipExtractor
+	trustedHeaderAuth, err := newTrustedHeaderAuthenticator(config.TrustedHeaderAuth, config.TrustedProxies, store)
+	if err != nil {
+		panic(err)
+	}
 	ec.HidePort = true
 	ec.HideBanner = true
 	ec.Pre(middleware.RemoveTrailingSlash())
 	ec.Use(
 		middleware.Recover(),
-		middleware.LoggerWithConfig(middleware.LoggerConfig{
-			Format: "[Request] ${time_rfc3339} :: ${method} ${uri} -> ${status} ${error} {ip=${remote_ip}, user_agent=${user_agent}}\n",
+		middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+			// Store the request ID in the requests context so that any logs emitted
+			// as a result of this request (e.g. by the services) can be correlated.
+			RequestIDHandler: func(ec echo.Context, id string) {
+				ctx := logger.ContextWithFields(ec.Request().Context(), logger.Fields{"request_id": id})
+				ec.SetRequest(ec.Request().WithContext(ctx))
+			},
 		}),
-		// middleware.CORSWithConfig(middleware.CORSConfig{
-		// 	AllowOrigins: []string{"*"},
-		// AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAccessControlAllowOrigin},
-		// AllowMethods: []string{echo.OPTIONS, echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
-		// }),
+		middleware.LoggerWithConfig(middleware.LoggerConfig{Format: requestLogFormat()}),
+		newErrorMappingMiddleware(),
+		newCORSMiddleware(config.CORS),
+		newIPRateLimitMiddleware(config.RateLimit),
+		middleware.BodyLimit(config.MaxBodySize),
 	)
 
 	// -- Setup gateway --
 	socket := websocket.New()
-	broadcaster := newBroadcaster(socket, ingestService, transcodeService, store)
+	broadcaster := newBroadcaster(socket, ingestService, transcodeService, consistencyService, store, config.ActivityHistorySize)
+	socket.BindCommand(CommandSubscribe, broadcaster.HandleSubscribeCommand)
 
 	// The activity service endpoint is not documented in the OpenAPI spec, so it
 	// has a unique setup because:
@@ -135,6 +266,8 @@ func NewRestGateway(
 	//   for this endpoint as we base what information flows through the websocket using the permissions,
 	// 	 so there's no permission specifically-required to access this endpoint (the only requirement is
 	//   that you're authenticated).
+	// Clients reconnecting may provide the sequence number of the last message they received
+	// using the 'since' query parameter, in which case the messages they missed are replayed.
 	ec.GET(apiBasePath+"/activity/ws", func(c echo.Context) error {
 		user, err := authProvider.ValidateTokenFromRequest(c, c.Request())
 		if err != nil {
@@ -143,11 +276,20 @@ func NewRestGateway(
 			return err
 		}
 
+		var since *uint64
+		if param := c.QueryParam("since"); param != "" {
+			seq, err := strconv.ParseUint(param, 10, 64)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "since must be a non-negative integer sequence number")
+			}
+			since = &seq
+		}
+
 		socket.UpgradeToSocket(c.Response(), c.Request(), func(client websocket.SocketClient, event websocket.ClientEvent) {
 			//exhaustive:enforce
 			switch event {
 			case websocket.OPENED:
-				broadcaster.RegisterClient(client.ID, user.Permissions)
+				broadcaster.RegisterClient(client.ID, user.Permissions, since)
 			case websocket.CLOSED:
 				broadcaster.DeregisterClient(client.ID)
 			}
@@ -156,28 +298,68 @@ func NewRestGateway(
 		return nil
 	})
 
+	registerActivitySchemaRoute(ec, apiBasePath+"/activity/schema")
+	registerHealthRoutes(ec, config.basePath(), healthChecker)
+	registerShareRoute(ec, apiBasePath+"/share", authProvider, store)
+	if config.EnableDebugEndpoints {
+		registerDebugRoutes(ec, apiBasePath+"/debug", authProvider)
+	}
+	if config.EnableGraphQL {
+		registerGraphQLRoute(ec, apiBasePath+"/graphql", authProvider, store, transcodeService)
+	}
+
 	gateway := &RestGateway{
-		broadcaster: broadcaster,
-		config:      config,
-		ec:          ec,
-		socket:      socket,
+		broadcaster:    broadcaster,
+		config:         config,
+		ec:             ec,
+		socket:         socket,
+		tokenValidator: authProvider,
 	}
 
+	middlewares := []gen.StrictMiddlewareFunc{newUserRateLimitMiddleware(config.RateLimit), requestBodyValidatorMiddleware, newMaintenanceMiddleware(maintenanceMode), newAuditMiddleware(store)}
 	serverImpl := gen.NewStrictHandler(&strictServerImpl{
-		ingests.New(ingestService),
-		auth.New(authProvider, store),
-		users.NewController(store),
-		medias.New(transcodeService, store),
+		ingests.New(ingestService, store),
+		auth.New(authProvider, store, trustedHeaderAuth, traktService),
+		users.NewController(store, transcodeService),
+		roles.New(store),
+		invites.New(store),
+		shares.New(store, authProvider, apiBasePath+"/share"),
+		audits.New(store),
+		medias.New(transcodeService, traktService, store),
+		collections.New(store),
+		tags.New(store),
+		libraries.New(store),
+		deletions.New(deletionService),
 		transcodes.New(transcodeService, store),
-		targets.New(store),
-		workflows.New(store),
-	}, []gen.StrictMiddlewareFunc{requestBodyValidatorMiddleware})
-
-	authenticatedGroup := ec.Group(apiBasePath, authProvider.GetSecurityValidatorMiddleware(apiBasePath))
+		targets.New(store, targetValidator, transcodeService, benchmarkService),
+		workflows.New(store, transcodeService),
+		backups.New(backupService),
+		statistics.New(store),
+		system.New(monitoredPaths, consistencyService, exportService, scraper, ingestService, configReloader, systemInfo, maintenanceMode, healthChecker, store),
+		remotes.New(remoteSourceService),
+		images.New(artworkService),
+	}, middlewares)
+
+	authenticatedGroup := ec.Group(apiBasePath, authProvider.GetSecurityValidatorMiddleware(apiBasePath, gen.GetSwagger))
 	gen.RegisterHandlers(authenticatedGroup, serverImpl)
+
+	// Version 2 of the API shares the middleware (and the underlying controllers)
+	// of version 1, but is validated against it's own spec.
+	serverImplV2 := genv2.NewStrictHandler(&strictServerImplV2{
+		medias.NewV2(store),
+	}, middlewares)
+
+	authenticatedV2Group := ec.Group(apiV2BasePath, authProvider.GetSecurityValidatorMiddleware(apiV2BasePath, genv2.GetSwagger))
+	genv2.RegisterHandlers(authenticatedV2Group, serverImplV2)
 	return gateway
 }
 
+// ValidateAuthToken validates an auth token issued by this gateway, allowing
+// other APIs (e.g. gRPC) to authenticate users who logged in via this gateway.
+func (gateway *RestGateway) ValidateAuthToken(token string) (*jwt.AuthenticatedUser, error) {
+	return gateway.tokenValidator.ValidateAuthToken(token)
+}
+
 func (gateway *RestGateway) Run(parentCtx context.Context) error {
 	ctx, ctxCancel := context.WithCancelCause(parentCtx)
 	wg := &sync.WaitGroup{}
@@ -216,25 +398,94 @@ func (gateway *RestGateway) Run(parentCtx context.Context) error {
 	return nil
 }
 
+// requestLogFormat returns the format used by the Echo request logger, which
+// matches the output format of Thea's own logger.
+func requestLogFormat() string {
+	if logger.GetOutputFormat() == logger.JSONFormat {
+		return `{"time":"${time_rfc3339}","level":"info","logger":"Request","method":"${method}","uri":"${uri}",` +
+			`"status":${status},"error":"${error}","remote_ip":"${remote_ip}","user_agent":"${user_agent}","request_id":"${id}"}` + "\n"
+	}
+
+	return "[Request] ${time_rfc3339} :: ${method} ${uri} -> ${status} ${error} {ip=${remote_ip}, user_agent=${user_agent}, request_id=${id}}\n"
+}
+
 const jwtSecretLength = 64 // 512 bits
-func newJwtSigningKeys() ([]byte, []byte, error) {
-	authSecret, err := randomSecret(jwtSecretLength)
-	if err != nil {
-		return nil, nil, err
+
+// Validate returns an error if the configured JWT secrets are shorter than
+// the required length, or if the same secret is used for both tokens.
+func (config *RestConfig) Validate() error {
+	errs := make([]error, 0)
+	if config.JwtAuthSecret != "" && len(config.JwtAuthSecret) < jwtSecretLength {
+		errs = append(errs, fmt.Errorf("jwt_auth_secret: must be at least %d bytes long, got %d", jwtSecretLength, len(config.JwtAuthSecret)))
 	}
-	refreshSecret, err := randomSecret(jwtSecretLength)
-	if err != nil {
+	if config.JwtRefreshSecret != "" && len(config.JwtRefreshSecret) < jwtSecretLength {
+		errs = append(errs, fmt.Errorf("jwt_refresh_secret: must be at least %d bytes long, got %d", jwtSecretLength, len(config.JwtRefreshSecret)))
+	}
+	if config.JwtAuthSecret != "" && config.JwtAuthSecret == config.JwtRefreshSecret {
+		errs = append(errs, errors.New("jwt_refresh_secret: must differ from jwt_auth_secret"))
+	}
+	if _, err := bytes.Parse(config.MaxBodySize); err != nil {
+		errs = append(errs, fmt.Errorf("max_body_size: %w", err))
+	}
+	if err := config.RateLimit.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := config.CORS.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if config.BasePath != "" && !strings.HasPrefix(config.BasePath, "/") {
+		errs = append(errs, errors.New("base_path: must begin with '/'"))
+	}
+	if _, err := parseTrustedProxies(config.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("trusted_proxies: %w", err))
+	}
+	if err := config.TrustedHeaderAuth.Validate(config.TrustedProxies); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// basePath returns the configured base path without a trailing slash, such
+// that it can be prefixed to the API routes.
+func (config *RestConfig) basePath() string {
+	return strings.TrimRight(config.BasePath, "/")
+}
+
+// newJwtSigningKeys returns the secrets used to sign auth and refresh tokens. Secrets
+// which are not configured are randomly generated.
+func newJwtSigningKeys(config *RestConfig) ([]byte, []byte, error) {
+	if err := config.Validate(); err != nil {
 		return nil, nil, err
 	}
 
-	return authSecret, refreshSecret, nil
+	secrets := make([][]byte, 2)
+	for k, configured := range []string{config.JwtAuthSecret, config.JwtRefreshSecret} {
+		if configured != "" {
+			secrets[k] = []byte(configured)
+			continue
+		}
+
+		secret, err := randomSecret(jwtSecretLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		secrets[k] = secret
+	}
+
+	return secrets[0], secrets[1], nil
 }
 
 // Middleware to run Echo validator (see newValidator) against all incoming requests.
 func requestBodyValidatorMiddleware(f gen.StrictHandlerFunc, _ string) gen.StrictHandlerFunc {
-	validator := newValidator()
+	validate := newValidator()
 	return func(ctx echo.Context, i interface{}) (interface{}, error) {
-		if err := validator.Struct(i); err != nil {
+		if err := validate.Struct(i); err != nil {
+			var validationErrs validator.ValidationErrors
+			if errors.As(err, &validationErrs) {
+				return nil, gen.NewValidationError(validationViolations(validationErrs)...)
+			}
+
 			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("request body malformed: %s", err))
 		}
 		return f(ctx, i)
@@ -247,6 +498,18 @@ func requestBodyValidatorMiddleware(f gen.StrictHandlerFunc, _ string) gen.Stric
 // built-ins such as 'required').
 func newValidator() *validator.Validate {
 	validate := validator.New()
+
+	// Name fields after their JSON keys, so that violations reference the
+	// field as it appears in the request rather than the Go struct field.
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
 	if err := validate.RegisterValidation("alphaNumericWhitespaceTrimmed", func(fl validator.FieldLevel) bool {
 		str := fl.Field().String()
 		if len(strings.TrimSpace(str)) != len(str) {
@@ -273,3 +536,29 @@ func randomSecret(length uint32) ([]byte, error) {
 
 	return secret, nil
 }
+
+// validationViolations converts the errors of the validator to field violations. The namespace
+// of each error begins with the request object (e.g. 'CreateTagRequestObject.Body.label'), which
+// is stripped so the field path is relative to the request (e.g. 'body.label').
+func validationViolations(errs validator.ValidationErrors) []gen.FieldViolation {
+	violations := make([]gen.FieldViolation, 0, len(errs))
+	for _, fieldErr := range errs {
+		path := strings.Split(fieldErr.Namespace(), ".")[1:]
+		if len(path) > 0 && path[0] == "Body" {
+			path[0] = "body"
+		}
+
+		constraint := fieldErr.Tag()
+		if fieldErr.Param() != "" {
+			constraint = fmt.Sprintf("%s=%s", constraint, fieldErr.Param())
+		}
+
+		violations = append(violations, gen.FieldViolation{
+			Field:      strings.Join(path, "."),
+			Constraint: constraint,
+			Message:    fmt.Sprintf("failed on the '%s' validation", fieldErr.Tag()),
+		})
+	}
+
+	return violations
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hbomb79/Thea/internal/api/gen"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidationViolationsUseJSONFieldPaths(t *testing.T) {
+	type body struct {
+		Label string `json:"label" validate:"required"`
+		Limit int    `json:"limit,omitempty" validate:"min=1"`
+	}
+	type requestObject struct{ Body *body }
+
+	err := newValidator().Struct(requestObject{Body: &body{}})
+
+	var validationErrs validator.ValidationErrors
+	assert.True(t, errors.As(err, &validationErrs))
+	assert.Equal(t, []gen.FieldViolation{
+		{Field: "body.label", Constraint: "required", Message: "failed on the 'required' validation"},
+		{Field: "body.limit", Constraint: "min=1", Message: "failed on the 'min' validation"},
+	}, validationViolations(validationErrs))
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/audit"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+const shareStreamAction = "StreamShareLink"
+
+type (
+	ShareStore interface {
+		GetShareLink(linkID uuid.UUID) (*user.ShareLink, error)
+		VerifySharePassword(link *user.ShareLink, password []byte) error
+		GetMedia(mediaID uuid.UUID) *media.Container
+		GetForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) (*transcode.Transcode, error)
+		AuditStore
+	}
+
+	shareTokenValidator interface {
+		ValidateShareToken(token string) (uuid.UUID, error)
+	}
+)
+
+// registerShareRoute registers the handler which streams the media shared by a share link
+// (see the ShareController). The token of the link is the final segment of the path, and is
+// signed by the API, so this endpoint does not require authentication and is not documented
+// in the OpenAPI spec. Password protected links require the password to be provided using
+// HTTP Basic authentication (the username is ignored).
+func registerShareRoute(ec *echo.Echo, path string, tokens shareTokenValidator, store ShareStore) {
+	handler := func(c echo.Context) error {
+		shareID, err := tokens.ValidateShareToken(c.Param("token"))
+		if err != nil {
+			return user.ErrShareLinkNotFound
+		}
+
+		link, err := store.GetShareLink(shareID)
+		if err != nil {
+			return err
+		}
+
+		_, password, _ := c.Request().BasicAuth()
+		if err := store.VerifySharePassword(link, []byte(password)); err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="Thea share link"`)
+			return echo.ErrUnauthorized
+		}
+
+		mediaPath, err := sharedMediaPath(store, link)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(mediaPath)
+		if err != nil {
+			log.Errorf("Failed to open media %s shared by link %s: %v\n", mediaPath, link.ID, err)
+			return user.ErrShareLinkNotFound
+		}
+		defer file.Close()
+
+		stat, err := file.Stat()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+
+		// Players issue many range requests while streaming, so only the initial
+		// request is recorded. The token is omitted from the path as it grants access.
+		if rng := c.Request().Header.Get("Range"); rng == "" || rng == "bytes=0-" {
+			entry := &audit.Entry{
+				ID:         uuid.New(),
+				Action:     shareStreamAction,
+				Method:     c.Request().Method,
+				Path:       fmt.Sprintf("%s/%s", path, link.ID),
+				RequestID:  c.Response().Header().Get(echo.HeaderXRequestID),
+				ResourceID: &link.ID,
+			}
+			if err := store.RecordAuditEntry(entry); err != nil {
+				log.Errorf("Failed to record audit entry for stream of share link %s (request %s): %v\n", link.ID, entry.RequestID, err)
+			}
+		}
+
+		http.ServeContent(c.Response(), c.Request(), filepath.Base(mediaPath), stat.ModTime(), file)
+		return nil
+	}
+
+	ec.GET(path+"/:token", handler)
+	ec.HEAD(path+"/:token", handler)
+}
+
+// sharedMediaPath returns the path of the file shared by the link provided; the completed
+// transcode for the target of the link if set, otherwise the source of the media.
+func sharedMediaPath(store ShareStore, link *user.ShareLink) (string, error) {
+	if link.TargetID != nil {
+		transcode, err := store.GetForMediaAndTarget(link.MediaID, *link.TargetID, nil)
+		if err != nil {
+			return "", errors.Join(user.ErrShareLinkNotFound, err)
+		}
+
+		return transcode.MediaPath, nil
+	}
+
+	container := store.GetMedia(link.MediaID)
+	if container == nil {
+		return "", user.ErrShareLinkNotFound
+	}
+
+	return container.Source(), nil
+}
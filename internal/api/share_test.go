@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/audit"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSharePath = "/api/thea/share"
+
+type mockShareStore struct {
+	links    map[uuid.UUID]*user.ShareLink
+	password string
+	source   string
+}
+
+func (store *mockShareStore) GetShareLink(linkID uuid.UUID) (*user.ShareLink, error) {
+	if link, ok := store.links[linkID]; ok {
+		return link, nil
+	}
+
+	return nil, user.ErrShareLinkNotFound
+}
+
+func (store *mockShareStore) VerifySharePassword(_ *user.ShareLink, password []byte) error {
+	if store.password != "" && string(password) != store.password {
+		return user.ErrSharePasswordInvalid
+	}
+
+	return nil
+}
+
+func (store *mockShareStore) GetMedia(mediaID uuid.UUID) *media.Container {
+	return &media.Container{Type: media.MovieContainerType, Movie: &media.Movie{Watchable: media.Watchable{SourcePath: store.source}}}
+}
+
+func (store *mockShareStore) GetForMediaAndTarget(uuid.UUID, uuid.UUID, *uuid.UUID) (*transcode.Transcode, error) {
+	return nil, user.ErrShareTargetNotTranscoded
+}
+
+func (store *mockShareStore) RecordAuditEntry(*audit.Entry) error { return nil }
+
+// newShareTestServer returns an Echo instance serving the share route, along with a
+// function returning the error the last request failed with (if any).
+func newShareTestServer(store ShareStore, tokens shareTokenValidator) (*echo.Echo, func() error) {
+	var lastErr error
+	ec := echo.New()
+	ec.HTTPErrorHandler = func(err error, c echo.Context) {
+		lastErr = err
+		ec.DefaultHTTPErrorHandler(err, c)
+	}
+	registerShareRoute(ec, testSharePath, tokens, store)
+
+	return ec, func() error { return lastErr }
+}
+
+func newShareTestStore(t *testing.T) (*mockShareStore, *user.ShareLink) {
+	source := filepath.Join(t.TempDir(), "movie.mkv")
+	assert.NoError(t, os.WriteFile(source, []byte("movie"), 0o600))
+
+	link := &user.ShareLink{ID: uuid.New(), MediaID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+	return &mockShareStore{links: map[uuid.UUID]*user.ShareLink{link.ID: link}, source: source}, link
+}
+
+func Test_ShareRoute_RevokedLinkRejected(t *testing.T) {
+	t.Parallel()
+	auth := jwt.NewJwtAuth(nil, "/refresh", []byte("auth-secret"), []byte("refresh-secret"))
+	store, link := newShareTestStore(t)
+	ec, lastErr := newShareTestServer(store, auth)
+
+	token, err := auth.GenerateShareToken(link.ID, link.ExpiresAt)
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	ec.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, testSharePath+"/"+token, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "movie", rec.Body.String())
+
+	// Revoking a link deletes it, however the token remains valid until it expires
+	delete(store.links, link.ID)
+	rec = httptest.NewRecorder()
+	ec.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, testSharePath+"/"+token, nil))
+	assert.ErrorIs(t, lastErr(), user.ErrShareLinkNotFound)
+}
+
+func Test_ShareRoute_ExpiredLinkRejected(t *testing.T) {
+	t.Parallel()
+	auth := jwt.NewJwtAuth(nil, "/refresh", []byte("auth-secret"), []byte("refresh-secret"))
+	store, link := newShareTestStore(t)
+	ec, lastErr := newShareTestServer(store, auth)
+
+	token, err := auth.GenerateShareToken(link.ID, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	ec.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, testSharePath+"/"+token, nil))
+	assert.ErrorIs(t, lastErr(), user.ErrShareLinkNotFound)
+}
+
+func Test_ShareRoute_WrongPasswordRejected(t *testing.T) {
+	t.Parallel()
+	auth := jwt.NewJwtAuth(nil, "/refresh", []byte("auth-secret"), []byte("refresh-secret"))
+	store, link := newShareTestStore(t)
+	store.password = "hunter2"
+	ec, _ := newShareTestServer(store, auth)
+
+	token, err := auth.GenerateShareToken(link.ID, link.ExpiresAt)
+	assert.NoError(t, err)
+
+	for _, password := range []string{"", "hunter3"} {
+		req := httptest.NewRequest(http.MethodGet, testSharePath+"/"+token, nil)
+		req.SetBasicAuth("", password)
+		rec := httptest.NewRecorder()
+		ec.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get(echo.HeaderWWWAuthenticate))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, testSharePath+"/"+token, nil)
+	req.SetBasicAuth("", "hunter2")
+	rec := httptest.NewRecorder()
+	ec.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+)
+
+var (
+	ErrTrustedHeaderAuthDisabled = errors.New("trusted header authentication is not enabled")
+	ErrUntrustedProxy            = errors.New("request did not arrive via a trusted proxy")
+	ErrTrustedHeaderMissing      = errors.New("request does not contain the trusted header")
+	ErrTrustedUserNotFound       = errors.New("user named by the trusted header does not exist")
+)
+
+type (
+	// TrustedHeaderAuthConfig configures authentication using a header set by an authenticating
+	// reverse proxy (e.g. the 'Remote-User' header set by Authelia or Authentik), as an alternative
+	// to logging in with a password. The header is only trusted for requests which arrive directly
+	// from one of the trusted proxies, and so trusted_proxies must be configured.
+	TrustedHeaderAuthConfig struct {
+		Enabled bool   `toml:"enabled" env:"API_TRUSTED_HEADER_AUTH_ENABLED" env-default:"false"`
+		Header  string `toml:"header" env:"API_TRUSTED_HEADER_AUTH_HEADER" env-default:"Remote-User"`
+
+		// AutoProvision creates a user for usernames which do not match an existing user, granting
+		// them the ProvisionPermissions. Otherwise, such requests are rejected. Provisioned users
+		// are given a random password, and so can only login using the trusted header.
+		AutoProvision        bool     `toml:"auto_provision" env:"API_TRUSTED_HEADER_AUTH_AUTO_PROVISION" env-default:"false"`
+		ProvisionPermissions []string `toml:"provision_permissions" env:"API_TRUSTED_HEADER_AUTH_PROVISION_PERMISSIONS" env-separator:","`
+	}
+
+	TrustedHeaderStore interface {
+		GetUserWithUsername(username []byte) (*user.User, error)
+		CreateUser(username []byte, password []byte, permissions ...string) (*user.User, error)
+	}
+
+	// trustedHeaderAuthenticator maps the username provided in the trusted header of a request
+	// to a Thea user, provisioning the user if enabled.
+	trustedHeaderAuthenticator struct {
+		config  TrustedHeaderAuthConfig
+		proxies []*net.IPNet
+		store   TrustedHeaderStore
+	}
+)
+
+func (config *TrustedHeaderAuthConfig) Validate(trustedProxies []string) error {
+	if !config.Enabled {
+		return nil
+	}
+
+	errs := make([]error, 0)
+	if len(trustedProxies) == 0 {
+		errs = append(errs, errors.New("trusted_header_auth.enabled: trusted_proxies must be configured, otherwise any client could provide the header"))
+	}
+	if strings.TrimSpace(config.Header) == "" {
+		errs = append(errs, errors.New("trusted_header_auth.header: must not be empty"))
+	}
+	for _, perm := range config.ProvisionPermissions {
+		if !slices.Contains(permissions.All(), perm) {
+			errs = append(errs, fmt.Errorf("trusted_header_auth.provision_permissions: unknown permission '%s'", perm))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func newTrustedHeaderAuthenticator(config TrustedHeaderAuthConfig, trustedProxies []string, store TrustedHeaderStore) (*trustedHeaderAuthenticator, error) {
+	proxies, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	return &trustedHeaderAuthenticator{config: config, proxies: proxies, store: store}, nil
+}
+
+// Authenticate returns the user named by the trusted header of the request provided. The
+// request must have arrived directly from a trusted proxy (the X-Forwarded-For header is
+// NOT considered, as the proxy setting the trusted header must be the one Thea sees).
+func (authenticator *trustedHeaderAuthenticator) Authenticate(request *http.Request) (*user.User, error) {
+	if !authenticator.config.Enabled {
+		return nil, ErrTrustedHeaderAuthDisabled
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !slices.ContainsFunc(authenticator.proxies, func(proxy *net.IPNet) bool { return proxy.Contains(ip) }) {
+		log.Warnf("Rejected trusted header authentication from %s as it is not a trusted proxy\n", host)
+		return nil, ErrUntrustedProxy
+	}
+
+	username := strings.TrimSpace(request.Header.Get(authenticator.config.Header))
+	if username == "" {
+		return nil, ErrTrustedHeaderMissing
+	}
+
+	existing, err := authenticator.store.GetUserWithUsername([]byte(username))
+	if err == nil {
+		return existing, nil
+	} else if !errors.Is(err, user.ErrUserNotFound) {
+		return nil, err
+	}
+
+	if !authenticator.config.AutoProvision {
+		return nil, ErrTrustedUserNotFound
+	}
+
+	password, err := randomPassword()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password for provisioned user: %w", err)
+	}
+
+	provisioned, err := authenticator.store.CreateUser([]byte(username), password, authenticator.config.ProvisionPermissions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to provision user '%s': %w", username, err)
+	}
+
+	log.Infof("Provisioned user '%s' (%s) named by trusted header\n", provisioned.Username, provisioned.ID)
+	return provisioned, nil
+}
+
+// randomPassword returns a random password for a provisioned user, which is never revealed.
+func randomPassword() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return []byte(hex.EncodeToString(secret)), nil
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockTrustedHeaderStore struct {
+	users   map[string]*user.User
+	lookups int
+}
+
+func (store *mockTrustedHeaderStore) GetUserWithUsername(username []byte) (*user.User, error) {
+	store.lookups++
+	if u, ok := store.users[string(username)]; ok {
+		return u, nil
+	}
+
+	return nil, user.ErrUserNotFound
+}
+
+func (store *mockTrustedHeaderStore) CreateUser(username []byte, _ []byte, perms ...string) (*user.User, error) {
+	u := newTrustedTestUser(string(username))
+	u.Permissions = perms
+	store.users[string(username)] = u
+
+	return u, nil
+}
+
+func newTrustedTestUser(username string) *user.User {
+	u := &user.User{}
+	u.ID, u.Username = uuid.New(), username
+
+	return u
+}
+
+func newTrustedHeaderRequest(remoteAddr string, username string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/auth/login/trusted", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("Remote-User", username)
+
+	return req
+}
+
+func Test_TrustedHeaderAuth_IgnoresUntrustedRemoteAddr(t *testing.T) {
+	t.Parallel()
+	store := &mockTrustedHeaderStore{users: map[string]*user.User{"alice": newTrustedTestUser("alice")}}
+	config := TrustedHeaderAuthConfig{Enabled: true, Header: "Remote-User"}
+	authenticator, err := newTrustedHeaderAuthenticator(config, []string{"10.0.0.0/8"}, store)
+	assert.NoError(t, err)
+
+	// X-Forwarded-For must not be considered, only the address the request arrived from
+	req := newTrustedHeaderRequest("192.168.1.5:4321", "alice")
+	req.Header.Set(echo.HeaderXForwardedFor, "10.0.0.1")
+	_, err = authenticator.Authenticate(req)
+	assert.ErrorIs(t, err, ErrUntrustedProxy)
+	assert.Zero(t, store.lookups, "header from an untrusted address should not be looked up")
+
+	u, err := authenticator.Authenticate(newTrustedHeaderRequest("10.1.2.3:4321", "alice"))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", u.Username)
+}
+
+func Test_TrustedHeaderAuth_NoTrustedProxies(t *testing.T) {
+	t.Parallel()
+	store := &mockTrustedHeaderStore{users: map[string]*user.User{"alice": newTrustedTestUser("alice")}}
+	config := TrustedHeaderAuthConfig{Enabled: true, Header: "Remote-User"}
+	assert.Error(t, config.Validate(nil), "enabling without trusted proxies should fail validation")
+
+	authenticator, err := newTrustedHeaderAuthenticator(config, nil, store)
+	assert.NoError(t, err)
+
+	for _, addr := range []string{"127.0.0.1:4321", "[::1]:4321", "10.0.0.1:4321"} {
+		_, err := authenticator.Authenticate(newTrustedHeaderRequest(addr, "alice"))
+		assert.ErrorIs(t, err, ErrUntrustedProxy)
+	}
+	assert.Zero(t, store.lookups)
+}
+
+func Test_TrustedHeaderAuth_Disabled(t *testing.T) {
+	t.Parallel()
+	store := &mockTrustedHeaderStore{users: map[string]*user.User{}}
+	authenticator, err := newTrustedHeaderAuthenticator(TrustedHeaderAuthConfig{Header: "Remote-User"}, []string{"127.0.0.1"}, store)
+	assert.NoError(t, err)
+
+	_, err = authenticator.Authenticate(newTrustedHeaderRequest("127.0.0.1:4321", "alice"))
+	assert.ErrorIs(t, err, ErrTrustedHeaderAuthDisabled)
+}
+
+func Test_TrustedHeaderAuth_AutoProvision(t *testing.T) {
+	t.Parallel()
+	store := &mockTrustedHeaderStore{users: map[string]*user.User{}}
+	config := TrustedHeaderAuthConfig{Enabled: true, Header: "Remote-User"}
+	authenticator, err := newTrustedHeaderAuthenticator(config, []string{"127.0.0.1"}, store)
+	assert.NoError(t, err)
+
+	_, err = authenticator.Authenticate(newTrustedHeaderRequest("127.0.0.1:4321", "bob"))
+	assert.ErrorIs(t, err, ErrTrustedUserNotFound, "unknown users should be rejected unless provisioning is enabled")
+	assert.Empty(t, store.users)
+
+	config.AutoProvision = true
+	config.ProvisionPermissions = []string{permissions.AccessMediaPermission}
+	authenticator, err = newTrustedHeaderAuthenticator(config, []string{"127.0.0.1"}, store)
+	assert.NoError(t, err)
+
+	provisioned, err := authenticator.Authenticate(newTrustedHeaderRequest("127.0.0.1:4321", "bob"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", provisioned.Username)
+	assert.Equal(t, []string{permissions.AccessMediaPermission}, provisioned.Permissions)
+
+	// Subsequent requests use the provisioned user rather than creating another
+	existing, err := authenticator.Authenticate(newTrustedHeaderRequest("127.0.0.1:4321", "bob"))
+	assert.NoError(t, err)
+	assert.Equal(t, provisioned.ID, existing.ID)
+	assert.Len(t, store.users, 1)
+}
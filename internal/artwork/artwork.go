@@ -0,0 +1,261 @@
+// Package artwork proxies the artwork hosted by TMDB (posters, backdrops, episode stills, etc), so
+// that clients do not need to hotlink TMDB. Images are cached on disk once fetched, such that they
+// remain available if TMDB cannot be reached, and are resized to the width requested by clients.
+package artwork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+const (
+	originalDirName = "original"
+	touchInterval   = time.Hour
+)
+
+var (
+	log = logger.Get("Artwork")
+
+	ErrImagePathInvalid  = errors.New("image path is not a valid TMDB image path")
+	ErrImageWidthInvalid = errors.New("image width is not supported")
+	ErrImageUnavailable  = errors.New("image could not be fetched from TMDB")
+
+	// imagePathPattern matches the paths of the raster images hosted by TMDB (e.g. the
+	// 'poster_path' of a movie), and prevents paths escaping the cache directory.
+	imagePathPattern = regexp.MustCompile(`^/?[A-Za-z0-9_-]+\.(jpg|jpeg|png)$`)
+
+	// Widths are restricted to a small set (matching the sizes offered by TMDB) so
+	// that clients cannot fill the cache with an unbounded number of renditions.
+	supportedWidths = []int{92, 154, 185, 300, 342, 500, 780, 1280}
+)
+
+type (
+	Config struct {
+		// CachePath is the directory fetched (and resized) images are stored in. Defaults to
+		// a directory inside of the cache directory.
+		CachePath string `toml:"cache_dir" env:"ARTWORK_CACHE_DIR"`
+
+		// Retention is how long a cached image is retained after it was last served. Images
+		// on TMDB never change (a new image is given a new path), so cached images are
+		// never refreshed, only removed once unused.
+		Retention     time.Duration `toml:"retention" env:"ARTWORK_RETENTION" env-default:"2160h"`
+		PruneInterval time.Duration `toml:"prune_interval" env:"ARTWORK_PRUNE_INTERVAL" env-default:"24h"`
+	}
+
+	Fetcher interface {
+		DownloadImage(imagePath string, dest io.Writer) error
+	}
+
+	// Image is a cached image, ready to be served to clients. The ETag of an image
+	// never changes, as the images on TMDB are immutable.
+	Image struct {
+		Path string
+		ETag string
+	}
+
+	// Service fetches TMDB images on-demand, caching the original image and every
+	// rendition (width) of it which is requested. Concurrent requests for the same
+	// image are de-duplicated so that each image is only fetched (or resized) once.
+	Service struct {
+		config  Config
+		fetcher Fetcher
+		locks   *keyedMutex
+	}
+)
+
+func New(config Config, fetcher Fetcher) *Service {
+	return &Service{config: config, fetcher: fetcher, locks: newKeyedMutex()}
+}
+
+// Image returns the cached TMDB image at the path provided (e.g. '/abc123.jpg'), resized to the
+// width provided if not zero, fetching the image from TMDB if it's not already cached. Images are
+// never upscaled, so if the original image is narrower than the width requested, the original
+// image is returned.
+func (service *Service) Image(imagePath string, width int) (*Image, error) {
+	if !imagePathPattern.MatchString(imagePath) {
+		return nil, ErrImagePathInvalid
+	}
+	if width != 0 && !slices.Contains(supportedWidths, width) {
+		return nil, fmt.Errorf("%w: must be one of %v", ErrImageWidthInvalid, supportedWidths)
+	}
+
+	name := filepath.Base(imagePath)
+	original, err := service.cached(filepath.Join(service.config.CachePath, originalDirName, name), func(dest string) error {
+		return service.fetch(imagePath, dest)
+	})
+	if err != nil || width == 0 {
+		return original, err
+	}
+
+	return service.cached(filepath.Join(service.config.CachePath, strconv.Itoa(width), name), func(dest string) error {
+		return resizeImage(original.Path, dest, width)
+	})
+}
+
+// Run periodically removes the cached images which have not been served within the retention period.
+func (service *Service) Run(ctx context.Context) error {
+	if service.config.PruneInterval <= 0 {
+		log.Emit(logger.WARNING, "Artwork prune interval is not positive, cached artwork will never be removed\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(service.config.PruneInterval)
+	defer ticker.Stop()
+
+	log.Emit(logger.NEW, "Artwork cache started (path=%s, retention=%s)\n", service.config.CachePath, service.config.Retention)
+	for {
+		service.prune()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Emit(logger.STOP, "Artwork cache closed\n")
+			return nil
+		}
+	}
+}
+
+// cached returns the image at the path provided, creating it using the function given if
+// it does not exist. The image is created in a temporary file which is renamed once complete,
+// so a partially written image is never served. The modification time of the image is bumped
+// when it's served (at most once per touchInterval), so that images in use are not pruned.
+func (service *Service) cached(path string, create func(dest string) error) (*Image, error) {
+	service.locks.Lock(path)
+	defer service.locks.Unlock(path)
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create artwork cache directory: %w", err)
+		}
+
+		tmp := path + ".tmp"
+		if err := create(tmp); err != nil {
+			_ = os.Remove(tmp)
+			return nil, err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			_ = os.Remove(tmp)
+			return nil, fmt.Errorf("failed to cache image: %w", err)
+		}
+	} else if err != nil {
+		return nil, err
+	} else if time.Since(info.ModTime()) > touchInterval {
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			log.Warnf("Failed to update modification time of cached image %s: %v\n", path, err)
+		}
+	}
+
+	rel, _ := filepath.Rel(service.config.CachePath, path)
+	return &Image{Path: path, ETag: fmt.Sprintf(`"%s"`, filepath.ToSlash(rel))}, nil
+}
+
+// fetch downloads the TMDB image at the path provided to the destination given.
+func (service *Service) fetch(imagePath string, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create image: %w", err)
+	}
+	defer f.Close()
+
+	if err := service.fetcher.DownloadImage(imagePath, f); err != nil {
+		log.Warnf("Failed to fetch image %s from TMDB: %v\n", imagePath, err)
+		return ErrImageUnavailable
+	}
+
+	return nil
+}
+
+// prune removes the cached images (of any width) which have not been served within the
+// retention period. Failures are logged, and will be retried on the next tick.
+func (service *Service) prune() {
+	cutoff := time.Now().Add(-service.config.Retention)
+	pruned := 0
+	err := filepath.WalkDir(service.config.CachePath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil //nolint:nilerr
+		}
+
+		service.locks.Lock(path)
+		defer service.locks.Unlock(path)
+		if err := os.Remove(path); err != nil {
+			log.Warnf("Failed to remove cached image %s: %v\n", path, err)
+			return nil
+		}
+
+		pruned++
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to prune artwork cache: %v\n", err)
+	}
+
+	if pruned > 0 {
+		log.Emit(logger.REMOVE, "Pruned %d unused images from the artwork cache\n", pruned)
+	}
+}
+
+// keyedMutex provides a mutex for each key, allowing work on different keys to proceed
+// concurrently. Mutexes are discarded once no goroutine holds (or is waiting for) them.
+type keyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+func (km *keyedMutex) Lock(key string) {
+	km.mutex.Lock()
+	lock, ok := km.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		km.locks[key] = lock
+	}
+	lock.refs++
+	km.mutex.Unlock()
+
+	lock.Lock()
+}
+
+func (km *keyedMutex) Unlock(key string) {
+	km.mutex.Lock()
+	defer km.mutex.Unlock()
+
+	lock := km.locks[key]
+	lock.refs--
+	if lock.refs == 0 {
+		delete(km.locks, key)
+	}
+	lock.Unlock()
+}
@@ -0,0 +1,81 @@
+package artwork
+
+import (
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFetcher struct {
+	fetches int
+	err     error
+}
+
+func (fetcher *mockFetcher) DownloadImage(_ string, dest io.Writer) error {
+	fetcher.fetches++
+	if fetcher.err != nil {
+		return fetcher.err
+	}
+
+	return png.Encode(dest, image.NewRGBA(image.Rect(0, 0, 600, 900)))
+}
+
+func Test_Image_CachesAndResizes(t *testing.T) {
+	t.Parallel()
+	fetcher := &mockFetcher{}
+	service := New(Config{CachePath: t.TempDir()}, fetcher)
+
+	original, err := service.Image("/poster.png", 0)
+	require.NoError(t, err)
+	assertImageSize(t, original.Path, 600, 900)
+
+	resized, err := service.Image("/poster.png", 342)
+	require.NoError(t, err)
+	assertImageSize(t, resized.Path, 342, 513)
+	assert.NotEqual(t, original.ETag, resized.ETag)
+
+	// Images wider than the original are not upscaled
+	wide, err := service.Image("/poster.png", 780)
+	require.NoError(t, err)
+	assertImageSize(t, wide.Path, 600, 900)
+
+	// Cached images are served when TMDB is unavailable
+	fetcher.err = errors.New("tmdb is down")
+	_, err = service.Image("/poster.png", 342)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetcher.fetches)
+
+	_, err = service.Image("/other.png", 0)
+	assert.ErrorIs(t, err, ErrImageUnavailable)
+}
+
+func Test_Image_RejectsInvalidRequests(t *testing.T) {
+	t.Parallel()
+	service := New(Config{CachePath: t.TempDir()}, &mockFetcher{})
+
+	for _, path := range []string{"/../secret.jpg", "/poster.svg", "/dir/poster.jpg", ""} {
+		_, err := service.Image(path, 0)
+		assert.ErrorIs(t, err, ErrImagePathInvalid, path)
+	}
+
+	_, err := service.Image("/poster.jpg", 123)
+	assert.ErrorIs(t, err, ErrImageWidthInvalid)
+}
+
+func assertImageSize(t *testing.T, path string, width int, height int) {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	require.NoError(t, err)
+	assert.Equal(t, width, config.Width)
+	assert.Equal(t, height, config.Height)
+}
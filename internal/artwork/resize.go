@@ -0,0 +1,79 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+)
+
+const jpegQuality = 85
+
+// resizeImage scales the JPEG/PNG image at the source path provided to the width given (preserving
+// it's aspect ratio), writing it to the destination using the same format. If the image is not wider
+// than the width requested, it's copied unchanged as images are never upscaled.
+func resizeImage(source string, dest string, width int) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	img, format, err := image.Decode(in)
+	if err != nil {
+		return fmt.Errorf("failed to decode image %s: %w", source, err)
+	}
+
+	if img.Bounds().Dx() > width {
+		img = scaleDown(img, width)
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch format {
+	case "png":
+		err = png.Encode(out, img)
+	default:
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode resized image: %w", err)
+	}
+
+	return nil
+}
+
+// scaleDown returns a copy of the image provided scaled down to the width given, using
+// an area average (box filter) of the source pixels covered by each destination pixel.
+func scaleDown(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+type (
+	// Entry represents a single privileged action which was performed
+	// against Thea. The user which performed the action is not
+	// guaranteed to still exist.
+	Entry struct {
+		ID        uuid.UUID  `db:"id"`
+		CreatedAt time.Time  `db:"created_at"`
+		UserID    *uuid.UUID `db:"user_id"`
+		Action    string     `db:"action"`
+		Method    string     `db:"method"`
+		Path      string     `db:"path"`
+		RequestID string     `db:"request_id"`
+
+		// ResourceID is the ID of the resource affected by the action, if any.
+		ResourceID *uuid.UUID `db:"resource_id"`
+	}
+
+	// Filter contains the optional filtering options used when listing
+	// audit entries. A nil field is not used for filtering.
+	Filter struct {
+		UserID     *uuid.UUID
+		ResourceID *uuid.UUID
+		Action     *string
+		Since      *time.Time
+		Offset     int
+		Limit      int
+	}
+
+	Store struct{}
+)
+
+func (store *Store) Record(db database.Queryable, entry *Entry) error {
+	_, err := db.NamedExec(`
+		INSERT INTO audit_log(id, created_at, user_id, action, method, path, request_id, resource_id)
+		VALUES (:id, current_timestamp, :user_id, :action, :method, :path, :request_id, :resource_id)
+	`, entry)
+
+	return err
+}
+
+// List returns the audit entries matching the filter provided, ordered
+// with the most recent entries first. The limit defaults to 50, with
+// a maximum of 500.
+func (store *Store) List(db database.Queryable, filter Filter) ([]*Entry, error) {
+	q := sq.Select("*").From("audit_log").OrderBy("created_at DESC")
+	if filter.UserID != nil {
+		q = q.Where(sq.Eq{"user_id": *filter.UserID})
+	}
+	if filter.ResourceID != nil {
+		q = q.Where(sq.Eq{"resource_id": *filter.ResourceID})
+	}
+	if filter.Action != nil {
+		q = q.Where(sq.Eq{"action": *filter.Action})
+	}
+	if filter.Since != nil {
+		q = q.Where(sq.GtOrEq{"created_at": *filter.Since})
+	}
+
+	if filter.Limit > 0 {
+		q = q.Limit(uint64(min(filter.Limit, 500)))
+	} else {
+		q = q.Limit(50)
+	}
+
+	query, args, err := q.Offset(uint64(max(filter.Offset, 0))).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build audit list query: %w", err)
+	}
+
+	var results []*Entry
+	if err := db.Select(&results, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
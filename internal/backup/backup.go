@@ -0,0 +1,256 @@
+// Package backup is responsible for producing, pruning and restoring backups of
+// Thea's database. Each backup is a gzipped tarball containing a dump of the
+// database, alongside a manifest which describes the schema version of the dump.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+const (
+	manifestFileName = "manifest.json"
+	dumpFileName     = "database.dump"
+
+	backupFilePrefix = "thea-backup-"
+	backupFileSuffix = ".tar.gz"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+var (
+	log = logger.Get("Backup")
+
+	ErrManifestMissing      = errors.New("backup archive does not contain a manifest")
+	ErrDumpMissing          = errors.New("backup archive does not contain a database dump")
+	ErrDialectMismatch      = errors.New("backup was produced for a different database dialect")
+	ErrSchemaVersionTooHigh = errors.New("backup schema version is newer than this version of Thea supports")
+)
+
+type (
+	Config struct {
+		Dir                 string        `toml:"dir" env:"BACKUP_DIR"`
+		Interval            time.Duration `toml:"interval" env:"BACKUP_INTERVAL" env-default:"0s"`
+		Retention           int           `toml:"retention" env:"BACKUP_RETENTION" env-default:"7"`
+		PgDumpBinaryPath    string        `toml:"pg_dump_binary_path" env:"BACKUP_PG_DUMP_BINARY_PATH" env-default:"/usr/bin/pg_dump"`
+		PgRestoreBinaryPath string        `toml:"pg_restore_binary_path" env:"BACKUP_PG_RESTORE_BINARY_PATH" env-default:"/usr/bin/pg_restore"`
+
+		// RestoreFrom is the path to a backup which should be restored when Thea
+		// starts. This is not read from the configuration file, and is instead
+		// populated from the '-restore' command line flag.
+		RestoreFrom string `toml:"-"`
+	}
+
+	// Manifest describes the contents of a backup, and is used to
+	// ensure that a backup is compatible with Thea before restoring it.
+	Manifest struct {
+		CreatedAt     time.Time `json:"created_at"`
+		Dialect       string    `json:"dialect"`
+		SchemaVersion int64     `json:"schema_version"`
+	}
+
+	SchemaVersioner interface {
+		SchemaVersion() (int64, error)
+	}
+
+	// Service produces backups of the database, both on-demand (see Create) and periodically
+	// if a backup interval is configured. Old backups are pruned according to the
+	// configured retention.
+	Service struct {
+		*sync.Mutex
+		config   Config
+		dbConfig database.DatabaseConfig
+		db       SchemaVersioner
+	}
+)
+
+func New(config Config, dbConfig database.DatabaseConfig, db SchemaVersioner) *Service {
+	return &Service{Mutex: &sync.Mutex{}, config: config, dbConfig: dbConfig, db: db}
+}
+
+// Run is the main entry point for this service. If a backup interval is configured,
+// backups will be created periodically until the context provided is cancelled.
+func (service *Service) Run(ctx context.Context) error {
+	if service.config.Interval <= 0 {
+		log.Emit(logger.DEBUG, "No backup interval configured, automatic backups are disabled\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	log.Emit(logger.NEW, "Automatic backups enabled (every %s, retaining %d) to %s\n", service.config.Interval, service.config.Retention, service.config.Dir)
+	ticker := time.NewTicker(service.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := service.Create(); err != nil {
+				log.Errorf("Automatic backup failed: %v\n", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Create produces a new backup in the configured backup directory, returning the path
+// to the backup. Once created, old backups exceeding the configured retention are removed.
+func (service *Service) Create() (string, error) {
+	service.Lock()
+	defer service.Unlock()
+
+	if err := os.MkdirAll(service.config.Dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	schemaVersion, err := service.db.SchemaVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine database schema version: %w", err)
+	}
+
+	dump, err := os.CreateTemp(service.config.Dir, ".thea-dump-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary dump file: %w", err)
+	}
+	defer os.Remove(dump.Name())
+	defer dump.Close()
+
+	// pg_dump runs in a single transaction, so the dump is a consistent
+	// snapshot of the database even if it's being written to.
+	cmd := exec.Command(service.config.PgDumpBinaryPath, //nolint:gosec
+		"--format=custom", "--no-owner",
+		"--host", service.dbConfig.Host, "--port", service.dbConfig.Port,
+		"--username", service.dbConfig.User, "--dbname", service.dbConfig.Name,
+	)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+service.dbConfig.Password)
+	cmd.Stdout = dump
+	if out, err := cmdStderr(cmd); err != nil {
+		return "", fmt.Errorf("pg_dump failed: %w (%s)", err, out)
+	}
+
+	manifest := Manifest{CreatedAt: time.Now().UTC(), Dialect: database.SQLDialect, SchemaVersion: schemaVersion}
+	path := filepath.Join(service.config.Dir, backupFilePrefix+manifest.CreatedAt.Format(backupTimeFormat)+backupFileSuffix)
+	if err := writeArchive(path, manifest, dump); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	log.Emit(logger.SUCCESS, "Created backup %s (schema version %d)\n", path, schemaVersion)
+	if err := service.prune(); err != nil {
+		log.Warnf("Failed to prune old backups: %v\n", err)
+	}
+
+	return path, nil
+}
+
+// prune removes the oldest backups from the backup directory, such that
+// only the configured number of backups are retained. A retention of
+// zero or less disables pruning.
+func (service *Service) prune() error {
+	if service.config.Retention <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(service.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	backups := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), backupFilePrefix) && strings.HasSuffix(entry.Name(), backupFileSuffix) {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	// Backups are named using their creation timestamp, so sorting by name
+	// orders the backups from oldest to newest.
+	slices.Sort(backups)
+	for len(backups) > service.config.Retention {
+		path := filepath.Join(service.config.Dir, backups[0])
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %w", path, err)
+		}
+
+		log.Emit(logger.REMOVE, "Pruned old backup %s\n", path)
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// writeArchive creates a gzipped tarball at the path provided, containing
+// the manifest provided and the contents of the dump file.
+func writeArchive(path string, manifest Manifest, dump *os.File) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup archive: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+	if err := writeArchiveEntry(tw, manifestFileName, int64(len(manifestBytes)), strings.NewReader(string(manifestBytes))); err != nil {
+		return err
+	}
+
+	info, err := dump.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat database dump: %w", err)
+	}
+	if _, err := dump.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind database dump: %w", err)
+	}
+	if err := writeArchiveEntry(tw, dumpFileName, info.Size(), dump); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalise backup archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalise backup archive: %w", err)
+	}
+
+	return out.Close()
+}
+
+func writeArchiveEntry(tw *tar.Writer, name string, size int64, content io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: time.Now()}); err != nil {
+		return fmt.Errorf("failed to write %s header to backup archive: %w", name, err)
+	}
+	if _, err := io.Copy(tw, content); err != nil {
+		return fmt.Errorf("failed to write %s to backup archive: %w", name, err)
+	}
+
+	return nil
+}
+
+// cmdStderr runs the command provided, returning the trimmed
+// stderr output of the command alongside any error.
+func cmdStderr(cmd *exec.Cmd) (string, error) {
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	return strings.TrimSpace(stderr.String()), err
+}
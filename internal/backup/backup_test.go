@@ -0,0 +1,47 @@
+package backup_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/backup"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSchemaVersioner struct{ version int64 }
+
+func (m *mockSchemaVersioner) SchemaVersion() (int64, error) { return m.version, nil }
+
+func Test_CreatePrunesOldBackups(t *testing.T) {
+	dir := t.TempDir()
+
+	// Stand-in for pg_dump which simply writes a fake dump to stdout
+	fakeDump := filepath.Join(t.TempDir(), "pg_dump")
+	assert.NoError(t, os.WriteFile(fakeDump, []byte("#!/bin/sh\necho 'fake dump'\n"), 0o700)) //nolint:gosec
+
+	// Existing backups, named such that they're older than any new backup
+	for _, name := range []string{"thea-backup-20000101T000000Z.tar.gz", "thea-backup-20000102T000000Z.tar.gz", "unrelated.txt"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte{}, 0o600))
+	}
+
+	service := backup.New(backup.Config{Dir: dir, Retention: 2, PgDumpBinaryPath: fakeDump}, database.DatabaseConfig{}, &mockSchemaVersioner{version: 4})
+	path, err := service.Create()
+	assert.NoError(t, err)
+	assert.FileExists(t, path)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	assert.Len(t, names, 3, "expected oldest backup to be pruned, and unrelated files to be retained. Found %s", strings.Join(names, ", "))
+	assert.Contains(t, names, "unrelated.txt")
+	assert.Contains(t, names, "thea-backup-20000102T000000Z.tar.gz")
+	assert.Contains(t, names, filepath.Base(path))
+}
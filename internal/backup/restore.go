@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/pkg/logger"
+	"github.com/jmoiron/sqlx"
+)
+
+// Restore replaces the contents of the database with the backup at the path provided. The
+// backup must have been produced for the same database dialect, and must not have a schema
+// version newer than this version of Thea supports. Once restored, the caller should run any
+// outstanding migrations to bring the restored schema up to date.
+//
+// NOTE: This is a destructive action, all existing data in the database is dropped.
+func Restore(config Config, dbConfig database.DatabaseConfig, db *sqlx.DB, archivePath string) (*Manifest, error) {
+	dump, err := os.CreateTemp("", ".thea-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary dump file: %w", err)
+	}
+	defer os.Remove(dump.Name())
+	defer dump.Close()
+
+	manifest, err := extractArchive(archivePath, dump)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest.Dialect != database.SQLDialect {
+		return nil, fmt.Errorf("%w: backup is for '%s', expected '%s'", ErrDialectMismatch, manifest.Dialect, database.SQLDialect)
+	}
+
+	latest, err := database.LatestSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion > latest {
+		return nil, fmt.Errorf("%w: backup is version %d, latest supported is %d", ErrSchemaVersionTooHigh, manifest.SchemaVersion, latest)
+	}
+
+	log.Warnf("Restoring backup %s (created %s, schema version %d). All existing data will be dropped!\n", archivePath, manifest.CreatedAt, manifest.SchemaVersion)
+	if _, err := db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public;`); err != nil {
+		return nil, fmt.Errorf("failed to reset database schema: %w", err)
+	}
+
+	cmd := exec.Command(config.PgRestoreBinaryPath, //nolint:gosec
+		"--no-owner", "--single-transaction", "--exit-on-error",
+		"--host", dbConfig.Host, "--port", dbConfig.Port,
+		"--username", dbConfig.User, "--dbname", dbConfig.Name,
+		dump.Name(),
+	)
+	cmd.Env = append(os.Environ(), "PGPASSWORD="+dbConfig.Password)
+	if out, err := cmdStderr(cmd); err != nil {
+		return nil, fmt.Errorf("pg_restore failed: %w (%s)", err, out)
+	}
+
+	log.Emit(logger.SUCCESS, "Restored backup %s\n", archivePath)
+	return manifest, nil
+}
+
+// extractArchive reads the backup archive at the path provided, writing the
+// database dump to the file provided and returning the archives manifest.
+func extractArchive(archivePath string, dump *os.File) (*Manifest, error) {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	foundDump := false
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read backup archive: %w", err)
+		}
+
+		switch header.Name {
+		case manifestFileName:
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("failed to decode backup manifest: %w", err)
+			}
+		case dumpFileName:
+			if _, err := io.Copy(dump, tr); err != nil { //nolint:gosec
+				return nil, fmt.Errorf("failed to extract database dump: %w", err)
+			}
+			foundDump = true
+		}
+	}
+
+	if manifest == nil {
+		return nil, ErrManifestMissing
+	} else if !foundDump {
+		return nil, ErrDumpMissing
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,230 @@
+// Package benchmark measures the encoding throughput of each transcode target on this host (see
+// ffmpeg.BenchmarkTarget), so that the threads required by a transcode using the target can be
+// estimated from the measured throughput rather than a static guess. Targets are benchmarked once,
+// and again whenever their ffmpeg options change (which discards the existing benchmark).
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var (
+	log = logger.Get("Benchmark")
+
+	ErrTargetNotFound = errors.New("target not found")
+	// ErrTargetRemuxOnly is returned when a remux-only target is benchmarked, as
+	// these targets do not encode the source, and so have no throughput to measure.
+	ErrTargetRemuxOnly = errors.New("remux-only targets cannot be benchmarked")
+)
+
+// idleRecheckInterval is how often the transcode queue is checked while a benchmark is waiting
+// for the running transcodes to finish, as a benchmark performed alongside them is not representative.
+const idleRecheckInterval = 30 * time.Second
+
+type (
+	Config struct {
+		// Enabled causes targets to be benchmarked automatically. Targets can
+		// still be benchmarked on-demand if disabled (see Service.Benchmark).
+		Enabled bool `toml:"enabled" env:"BENCHMARK_ENABLED" env-default:"true"`
+	}
+
+	Store interface {
+		GetTarget(targetID uuid.UUID) *ffmpeg.Target
+		GetAllTargets() []*ffmpeg.Target
+		SaveTargetBenchmark(targetID uuid.UUID, benchmark *ffmpeg.TargetBenchmark) error
+	}
+
+	TranscodeQueue interface {
+		ConsumedThreads() int
+	}
+
+	// Service benchmarks the targets queued for benchmarking one at a time, waiting for the transcode
+	// queue to be idle before each. Targets are benchmarked using the thread budget of the transcode
+	// service, so the estimated threads of a target never exceed the budget. Targets which fail to be
+	// benchmarked (e.g. because their options require a real input) are not retried automatically
+	// until they're next updated.
+	Service struct {
+		*sync.Mutex
+		config        Config
+		ffmpegBinPath string
+		threads       int
+		store         Store
+		transcodes    TranscodeQueue
+		eventBus      event.EventHandler
+
+		pending []uuid.UUID
+		failed  map[uuid.UUID]struct{}
+		wake    chan struct{}
+	}
+)
+
+func New(config Config, ffmpegBinPath string, threads int, store Store, transcodes TranscodeQueue, eventBus event.EventHandler) *Service {
+	return &Service{
+		Mutex:         &sync.Mutex{},
+		config:        config,
+		ffmpegBinPath: ffmpegBinPath,
+		threads:       threads,
+		store:         store,
+		transcodes:    transcodes,
+		eventBus:      eventBus,
+		pending:       make([]uuid.UUID, 0),
+		failed:        make(map[uuid.UUID]struct{}),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+func (service *Service) Run(ctx context.Context) error {
+	ev := make(event.HandlerChannel, 100)
+	if service.config.Enabled {
+		service.eventBus.RegisterHandlerChannel(ev, event.TargetUpdateEvent)
+		service.queueUnbenchmarked()
+	}
+
+	for {
+		select {
+		case message := <-ev:
+			if targetID, ok := message.Payload.(uuid.UUID); ok {
+				service.Lock()
+				delete(service.failed, targetID)
+				service.Unlock()
+			}
+
+			service.queueUnbenchmarked()
+		case <-service.wake:
+			for targetID, ok := service.next(); ok; targetID, ok = service.next() {
+				if err := service.benchmark(ctx, targetID); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+
+					log.Warnf("Failed to benchmark target %s: %v\n", targetID, err)
+				}
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Benchmark queues the target with the ID provided to be benchmarked, even if it has
+// already been benchmarked. The benchmark is performed once the transcode queue is idle.
+func (service *Service) Benchmark(targetID uuid.UUID) error {
+	target := service.store.GetTarget(targetID)
+	if target == nil {
+		return ErrTargetNotFound
+	}
+	if target.RemuxOnly {
+		return ErrTargetRemuxOnly
+	}
+
+	service.Lock()
+	delete(service.failed, targetID)
+	service.Unlock()
+
+	service.queue(targetID)
+	return nil
+}
+
+// IsPending returns true if the target provided is waiting to be benchmarked, or is being benchmarked.
+func (service *Service) IsPending(targetID uuid.UUID) bool {
+	service.Lock()
+	defer service.Unlock()
+
+	return slices.Contains(service.pending, targetID)
+}
+
+// queueUnbenchmarked queues the targets which have not been benchmarked, excluding remux-only
+// targets, and those which have failed to be benchmarked since they were last updated.
+func (service *Service) queueUnbenchmarked() {
+	for _, target := range service.store.GetAllTargets() {
+		service.Lock()
+		_, failed := service.failed[target.ID]
+		service.Unlock()
+
+		if target.Benchmark == nil && !target.RemuxOnly && !failed {
+			service.queue(target.ID)
+		}
+	}
+}
+
+func (service *Service) queue(targetID uuid.UUID) {
+	service.Lock()
+	defer service.Unlock()
+
+	if !slices.Contains(service.pending, targetID) {
+		service.pending = append(service.pending, targetID)
+	}
+
+	select {
+	case service.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the ID of the next target to be benchmarked. The target remains
+// pending until it's benchmark has been performed (see IsPending).
+func (service *Service) next() (uuid.UUID, bool) {
+	service.Lock()
+	defer service.Unlock()
+
+	if len(service.pending) == 0 {
+		return uuid.Nil, false
+	}
+
+	return service.pending[0], true
+}
+
+func (service *Service) benchmark(ctx context.Context, targetID uuid.UUID) error {
+	defer func() {
+		service.Lock()
+		service.pending = slices.DeleteFunc(service.pending, func(id uuid.UUID) bool { return id == targetID })
+		service.Unlock()
+	}()
+
+	if err := service.awaitIdleQueue(ctx); err != nil {
+		return err
+	}
+
+	// The target may have been deleted, or changed, while awaiting the queue
+	target := service.store.GetTarget(targetID)
+	if target == nil {
+		return nil
+	}
+
+	log.Emit(logger.INFO, "Benchmarking target %s using up to %d threads\n", target, service.threads)
+	benchmark, err := ffmpeg.BenchmarkTarget(ctx, service.ffmpegBinPath, target, service.threads)
+	if err != nil {
+		service.Lock()
+		service.failed[targetID] = struct{}{}
+		service.Unlock()
+		return err
+	}
+
+	log.Emit(logger.SUCCESS, "Benchmarked target %s: %.1f fps (%.1f fps single-threaded), estimated to require %d threads\n", target, benchmark.Fps, benchmark.SingleThreadFps, benchmark.EstimatedThreads())
+	return service.store.SaveTargetBenchmark(targetID, benchmark)
+}
+
+// awaitIdleQueue blocks until no transcodes are running, or the context is cancelled.
+func (service *Service) awaitIdleQueue(ctx context.Context) error {
+	ticker := time.NewTicker(idleRecheckInterval)
+	defer ticker.Stop()
+
+	for service.transcodes.ConsumedThreads() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+// Package cache provides a key-value cache for frequently requested (but rarely changing)
+// data, such as transcode targets and workflows, to reduce the load on the database.
+//
+// Values are stored in their JSON encoded form, so every Get returns a copy of the cached value
+// which the caller is free to mutate. A cache which fails to read or write a value behaves as
+// if the value is not cached, falling back to the caller's data source.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var log = logger.Get("Cache")
+
+type (
+	Cache interface {
+		// Get decodes the value cached for the key in to dest, returning true
+		// if the value was found (and decoded successfully).
+		Get(key string, dest any) bool
+		Set(key string, value any)
+		Delete(keys ...string)
+
+		// DeletePrefix deletes all cached values with a key starting with the prefix.
+		DeletePrefix(prefix string)
+	}
+
+	Backend string
+
+	// Config controls which (if any) backend is used to cache data. The Redis backend
+	// allows the cache to be shared between Thea instances, however as invalidation is
+	// driven by events, the memory backend remains coherent across instances when an
+	// event transport is in use.
+	Config struct {
+		Backend Backend `toml:"backend" env:"CACHE_BACKEND" env-default:"memory"`
+
+		// TTL is the maximum duration a value is cached for. Values are typically invalidated
+		// well before this (when modified), and so this only bounds the staleness of data
+		// which is modified without Thea's knowledge.
+		TTL time.Duration `toml:"ttl" env:"CACHE_TTL" env-default:"10m"`
+
+		// MaxEntries bounds the number of values held by the memory backend.
+		MaxEntries int `toml:"max_entries" env:"CACHE_MAX_ENTRIES" env-default:"10000"`
+
+		RedisAddress string `toml:"redis_address" env:"CACHE_REDIS_ADDRESS" env-default:"localhost:6379"`
+		KeyPrefix    string `toml:"key_prefix" env:"CACHE_KEY_PREFIX" env-default:"thea:cache:"`
+	}
+
+	noopCache struct{}
+)
+
+const (
+	NoBackend     Backend = "none"
+	MemoryBackend Backend = "memory"
+	RedisBackend  Backend = "redis"
+)
+
+// New constructs the cache for the backend specified in the config.
+func New(ctx context.Context, config Config) (Cache, error) {
+	//exhaustive:enforce
+	switch config.Backend {
+	case NoBackend:
+		return noopCache{}, nil
+	case MemoryBackend, "":
+		return newMemoryCache(config), nil
+	case RedisBackend:
+		return newRedisCache(ctx, config)
+	}
+
+	return nil, fmt.Errorf("unknown cache backend '%s'", config.Backend)
+}
+
+// Disabled returns a cache which caches nothing.
+func Disabled() Cache { return noopCache{} }
+
+func (noopCache) Get(_ string, _ any) bool { return false }
+func (noopCache) Set(_ string, _ any)      {}
+func (noopCache) Delete(_ ...string)       {}
+func (noopCache) DeletePrefix(_ string)    {}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// memoryCache is an in-process cache. When the cache is full, expired
+	// values are removed, followed by the value closest to expiring (i.e. the
+	// value which was cached the longest ago).
+	memoryCache struct {
+		sync.Mutex
+		entries    map[string]memoryEntry
+		ttl        time.Duration
+		maxEntries int
+	}
+
+	memoryEntry struct {
+		value     []byte
+		expiresAt time.Time
+	}
+)
+
+func newMemoryCache(config Config) *memoryCache {
+	return &memoryCache{
+		entries:    make(map[string]memoryEntry),
+		ttl:        config.TTL,
+		maxEntries: config.MaxEntries,
+	}
+}
+
+func (cache *memoryCache) Get(key string, dest any) bool {
+	cache.Lock()
+	entry, ok := cache.entries[key]
+	if ok && time.Now().After(entry.expiresAt) {
+		delete(cache.entries, key)
+		ok = false
+	}
+	cache.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	if err := json.Unmarshal(entry.value, dest); err != nil {
+		log.Warnf("Failed to decode cached value for key %s: %v\n", key, err)
+		return false
+	}
+
+	return true
+}
+
+func (cache *memoryCache) Set(key string, value any) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Warnf("Failed to encode value for key %s: %v\n", key, err)
+		return
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+
+	if _, exists := cache.entries[key]; !exists && cache.maxEntries > 0 && len(cache.entries) >= cache.maxEntries {
+		cache.evict()
+	}
+	cache.entries[key] = memoryEntry{value: encoded, expiresAt: time.Now().Add(cache.ttl)}
+}
+
+func (cache *memoryCache) Delete(keys ...string) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for _, key := range keys {
+		delete(cache.entries, key)
+	}
+}
+
+func (cache *memoryCache) DeletePrefix(prefix string) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for key := range cache.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(cache.entries, key)
+		}
+	}
+}
+
+// evict removes the expired entries from the cache. If none of the entries have
+// expired, the entry closest to expiring is removed. The cache must be locked.
+func (cache *memoryCache) evict() {
+	now := time.Now()
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range cache.entries {
+		if now.After(entry.expiresAt) {
+			delete(cache.entries, key)
+		} else if oldestKey == "" || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+
+	if len(cache.entries) >= cache.maxEntries {
+		delete(cache.entries, oldestKey)
+	}
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cachedValue struct {
+	Label  string
+	Values []int
+}
+
+func Test_MemoryCacheReturnsCopies(t *testing.T) {
+	cache := newMemoryCache(Config{TTL: time.Minute})
+	value := &cachedValue{Label: "foo", Values: []int{1, 2}}
+	cache.Set("key", value)
+	value.Values[0] = 10
+
+	var dest *cachedValue
+	assert.True(t, cache.Get("key", &dest))
+	assert.Equal(t, &cachedValue{Label: "foo", Values: []int{1, 2}}, dest)
+
+	dest.Label = "bar"
+	var again *cachedValue
+	assert.True(t, cache.Get("key", &again))
+	assert.Equal(t, "foo", again.Label)
+}
+
+func Test_MemoryCacheExpiresValues(t *testing.T) {
+	cache := newMemoryCache(Config{TTL: time.Millisecond})
+	cache.Set("key", 1)
+	time.Sleep(5 * time.Millisecond)
+
+	var dest int
+	assert.False(t, cache.Get("key", &dest))
+}
+
+func Test_MemoryCacheDeletesPrefix(t *testing.T) {
+	cache := newMemoryCache(Config{TTL: time.Minute})
+	cache.Set("media:movie:1", 1)
+	cache.Set("media:episode:2", 2)
+	cache.Set("genres", 3)
+	cache.DeletePrefix("media:")
+
+	var dest int
+	assert.False(t, cache.Get("media:movie:1", &dest))
+	assert.False(t, cache.Get("media:episode:2", &dest))
+	assert.True(t, cache.Get("genres", &dest))
+	assert.Equal(t, 3, dest)
+}
+
+func Test_MemoryCacheEvictsOldestWhenFull(t *testing.T) {
+	cache := newMemoryCache(Config{TTL: time.Minute, MaxEntries: 2})
+	cache.Set("a", 1)
+	time.Sleep(time.Millisecond)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+
+	var dest int
+	assert.False(t, cache.Get("a", &dest))
+	assert.True(t, cache.Get("b", &dest))
+	assert.True(t, cache.Get("c", &dest))
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisTimeout bounds each operation against Redis, so that an unresponsive
+	// Redis server degrades to cache misses rather than stalling requests.
+	redisTimeout = 250 * time.Millisecond
+
+	redisScanCount = 500
+)
+
+// redisCache stores values in Redis, allowing the cache to be shared
+// between Thea instances. All keys are prefixed with the configured prefix.
+type redisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+	prefix string
+}
+
+func newRedisCache(ctx context.Context, config Config) (*redisCache, error) {
+	client := redis.NewClient(&redis.Options{Addr: config.RedisAddress})
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", config.RedisAddress, err)
+	}
+
+	return &redisCache{client: client, ttl: config.TTL, prefix: config.KeyPrefix}, nil
+}
+
+func (cache *redisCache) Get(key string, dest any) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	encoded, err := cache.client.Get(ctx, cache.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false
+	} else if err != nil {
+		log.Warnf("Failed to read key %s from Redis: %v\n", key, err)
+		return false
+	}
+
+	if err := json.Unmarshal(encoded, dest); err != nil {
+		log.Warnf("Failed to decode cached value for key %s: %v\n", key, err)
+		return false
+	}
+
+	return true
+}
+
+func (cache *redisCache) Set(key string, value any) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Warnf("Failed to encode value for key %s: %v\n", key, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := cache.client.Set(ctx, cache.prefix+key, encoded, cache.ttl).Err(); err != nil {
+		log.Warnf("Failed to write key %s to Redis: %v\n", key, err)
+	}
+}
+
+func (cache *redisCache) Delete(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	prefixed := make([]string, len(keys))
+	for k, key := range keys {
+		prefixed[k] = cache.prefix + key
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := cache.client.Del(ctx, prefixed...).Err(); err != nil {
+		log.Warnf("Failed to delete keys %v from Redis: %v\n", keys, err)
+	}
+}
+
+func (cache *redisCache) DeletePrefix(prefix string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	iter := cache.client.Scan(ctx, 0, cache.prefix+prefix+"*", redisScanCount).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		log.Warnf("Failed to scan Redis for keys with prefix %s: %v\n", prefix, err)
+		return
+	}
+
+	if len(keys) > 0 {
+		if err := cache.client.Del(ctx, keys...).Err(); err != nil {
+			log.Warnf("Failed to delete keys with prefix %s from Redis: %v\n", prefix, err)
+		}
+	}
+}
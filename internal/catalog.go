@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type (
+	catalogStore interface {
+		ListSeries() ([]*media.Series, error)
+		SaveEpisodeCatalog(seriesID uuid.UUID, episodes []*media.CatalogEpisode) error
+	}
+
+	catalogSearcher interface {
+		GetSeries(seriesID string) (*tmdb.Series, error)
+		GetSeason(seriesID string, seasonNumber int) (*tmdb.Season, error)
+	}
+
+	// catalogRefresher periodically fetches the episodes of each series in the
+	// library from TMDB, so that the episodes missing from the library can be
+	// determined without querying TMDB on every request.
+	catalogRefresher struct {
+		config   CatalogConfig
+		searcher catalogSearcher
+		store    catalogStore
+	}
+)
+
+func newCatalogRefresher(config CatalogConfig, searcher catalogSearcher, store catalogStore) *catalogRefresher {
+	return &catalogRefresher{config: config, searcher: searcher, store: store}
+}
+
+func (refresher *catalogRefresher) Run(ctx context.Context) error {
+	if refresher.config.RefreshInterval <= 0 {
+		log.Emit(logger.WARNING, "Catalog refresh interval is not positive, missing episodes will not be detected\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(refresher.config.RefreshInterval)
+	defer ticker.Stop()
+
+	log.Emit(logger.NEW, "Catalog refresher started (interval=%s)\n", refresher.config.RefreshInterval)
+	for {
+		refresher.refresh(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Emit(logger.STOP, "Catalog refresher closed\n")
+			return nil
+		}
+	}
+}
+
+// refresh replaces the episode catalog of every series in the library. Failures
+// are logged, and the affected series will be retried on the next tick.
+func (refresher *catalogRefresher) refresh(ctx context.Context) {
+	series, err := refresher.store.ListSeries()
+	if err != nil {
+		log.Errorf("Failed to list series for catalog refresh: %v\n", err)
+		return
+	}
+
+	refreshed := 0
+	for _, s := range series {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Unverified series have no TMDB ID until they are reconciled (see unverifiedReconciler)
+		if s.IsUnverified() {
+			continue
+		}
+
+		if err := refresher.refreshSeries(s); err != nil {
+			log.Warnf("Failed to refresh episode catalog of series %s: %v\n", s.ID, err)
+			continue
+		}
+		refreshed++
+	}
+
+	log.Emit(logger.DEBUG, "Refreshed episode catalog of %d/%d series\n", refreshed, len(series))
+}
+
+func (refresher *catalogRefresher) refreshSeries(series *media.Series) error {
+	tmdbSeries, err := refresher.searcher.GetSeries(series.TmdbID)
+	if err != nil {
+		return err
+	}
+
+	episodes := make([]*media.CatalogEpisode, 0)
+	for _, stub := range tmdbSeries.Seasons {
+		season, err := refresher.searcher.GetSeason(series.TmdbID, stub.SeasonNumber)
+		if err != nil {
+			return err
+		}
+
+		episodes = append(episodes, tmdb.TmdbSeasonToCatalog(season)...)
+	}
+
+	return refresher.store.SaveEpisodeCatalog(series.ID, episodes)
+}
@@ -1,14 +1,33 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hbomb79/Thea/internal/api"
+	"github.com/hbomb79/Thea/internal/artwork"
+	"github.com/hbomb79/Thea/internal/backup"
+	"github.com/hbomb79/Thea/internal/benchmark"
+	"github.com/hbomb79/Thea/internal/cache"
+	"github.com/hbomb79/Thea/internal/consistency"
 	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/export"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
 	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/maintenance"
+	"github.com/hbomb79/Thea/internal/notification"
+	"github.com/hbomb79/Thea/internal/remote"
+	"github.com/hbomb79/Thea/internal/rpc"
+	"github.com/hbomb79/Thea/internal/trakt"
 	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/user"
+	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
@@ -17,13 +36,40 @@ import (
 // manually inside the code.
 type TheaConfig struct {
 	Format        transcode.Config        `toml:"transcode"`
+	Benchmark     benchmark.Config        `toml:"benchmark"`
 	IngestService ingest.Config           `toml:"ingestion"`
 	Services      DockerConfig            `toml:"docker"`
 	Database      database.DatabaseConfig `toml:"database"`
 	RestConfig    api.RestConfig          `toml:"api"`
+	RPC           rpc.Config              `toml:"rpc"`
+	Bootstrap     BootstrapConfig         `toml:"bootstrap"`
+	Passwords     user.HashConfig         `toml:"password_hashing"`
+	Backup        backup.Config           `toml:"backup"`
+	Trash         TrashConfig             `toml:"trash"`
+	History       HistoryConfig           `toml:"transcode_history"`
+	Catalog       CatalogConfig           `toml:"catalog"`
+	Reconcile     ReconcileConfig         `toml:"reconcile"`
+	Consistency   consistency.Config      `toml:"consistency"`
+	Export        export.Config           `toml:"export"`
+	Notifications notification.Config     `toml:"notifications"`
+	Events        event.TransportConfig   `toml:"events"`
+	Trakt         trakt.Config            `toml:"trakt"`
+	Cache         cache.Config            `toml:"cache"`
+	Maintenance   maintenance.Config      `toml:"maintenance"`
+	Remote        remote.Config           `toml:"remote_sources"`
+	Artwork       artwork.Config          `toml:"artwork"`
+	Tmdb          tmdb.Config             `toml:"tmdb"`
 	TmdbKey       string                  `toml:"tmdb_api_key" env:"TMDB_API_KEY" env-required:"true"`
 	CacheDirPath  string                  `toml:"cache_dir" env:"CACHE_DIR"`
 	ConfigDirPath string                  `toml:"config_dir" env:"CONFIG_DIR"`
+
+	// LogLevel is the minimum level of the messages logged, used when the level is
+	// not provided as a flag (one of verbose, debug, info, important, warning or error).
+	LogLevel string `toml:"log_level" env:"LOG_LEVEL"`
+
+	// path is the file the configuration was loaded from, which is
+	// re-read when the configuration is reloaded.
+	path string
 }
 
 // DockerConfig is used to enable/disable the internal intialisation of
@@ -35,17 +81,155 @@ type DockerConfig struct {
 	EnableFrontend bool `toml:"enable_frontend" env:"SERVICE_ENABLE_UI"`
 }
 
+// BootstrapConfig contains the credentials used to create the initial
+// admin user when Thea is started for the first time (i.e. when no users
+// exist). Once any user exists, this configuration is ignored.
+type BootstrapConfig struct {
+	AdminUsername string `toml:"admin_username" env:"ADMIN_USERNAME" env-default:"admin"`
+	AdminPassword string `toml:"admin_password" env:"ADMIN_PASSWORD" env-default:"admin"`
+}
+
+// TrashConfig controls how long deleted media is retained in the trash before
+// it (and all it's transcodes) are permanently deleted.
+type TrashConfig struct {
+	Retention     time.Duration `toml:"retention" env:"TRASH_RETENTION" env-default:"720h"`
+	PurgeInterval time.Duration `toml:"purge_interval" env:"TRASH_PURGE_INTERVAL" env-default:"1h"`
+}
+
+// HistoryConfig controls how long the history of concluded transcode tasks is retained. A
+// retention of zero retains the history forever.
+type HistoryConfig struct {
+	Retention     time.Duration `toml:"retention" env:"TRANSCODE_HISTORY_RETENTION" env-default:"2160h"`
+	PurgeInterval time.Duration `toml:"purge_interval" env:"TRANSCODE_HISTORY_PURGE_INTERVAL" env-default:"6h"`
+}
+
+// CatalogConfig controls how often the episode catalog of each series is refreshed
+// from TMDB, which is used to detect the episodes missing from the library.
+type CatalogConfig struct {
+	RefreshInterval time.Duration `toml:"refresh_interval" env:"CATALOG_REFRESH_INTERVAL" env-default:"24h"`
+}
+
+// ReconcileConfig controls how often the media ingested while TMDB was unavailable
+// (see ingest.Config.OfflineMode) is searched for in TMDB, so that it can be verified.
+type ReconcileConfig struct {
+	Interval time.Duration `toml:"interval" env:"RECONCILE_INTERVAL" env-default:"15m"`
+}
+
+// secretEnvVars are the environment variables which contain secrets. Each may instead be
+// read from a file, whose path is provided by the same variable suffixed with '_FILE' (e.g.
+// DB_PASSWORD_FILE), so that Docker/Kubernetes secrets can be mounted rather than passed
+// to Thea as environment variables.
+var secretEnvVars = []string{"DB_PASSWORD", "TMDB_API_KEY", "ADMIN_PASSWORD", "API_JWT_AUTH_SECRET", "API_JWT_REFRESH_SECRET", "REMOTE_CREDENTIAL_KEY"}
+
 // LoadFromFile loads a configuration file formatted in TOML in to a
-// TheaConfig struct ready to be passed to Processor.
+// TheaConfig struct ready to be passed to Processor. Secrets provided
+// as files (see secretEnvVars) take precedence over the file.
 func (config *TheaConfig) LoadFromFile(configPath string) error {
-	err := cleanenv.ReadConfig(configPath, config)
+	cleanup, err := exportSecretFiles()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	err = cleanenv.ReadConfig(configPath, config)
 	if err != nil {
-		return fmt.Errorf("failed to load configuration for ProcessorConfig: %w", err)
+		return fmt.Errorf("failed to load configuration from '%s': %w", configPath, err)
 	}
 
+	config.path = configPath
 	return nil
 }
 
+// exportSecretFiles reads the secrets provided as files (see secretEnvVars), exporting the
+// contents of each to the environment variable it replaces so that they are read alongside
+// the rest of the configuration. The cleanup function returned removes the exported variables,
+// so that secrets are not inherited by the processes Thea spawns (e.g. ffmpeg).
+func exportSecretFiles() (func(), error) {
+	exported := make([]string, 0)
+	cleanup := func() {
+		for _, name := range exported {
+			_ = os.Unsetenv(name)
+		}
+	}
+
+	for _, name := range secretEnvVars {
+		path, ok := os.LookupEnv(name + "_FILE")
+		if !ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			cleanup()
+			return nil, fmt.Errorf("only one of %s and %s_FILE may be set", name, name)
+		}
+
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+
+		// Secret files commonly end with a newline, which is not part of the secret
+		if err := os.Setenv(name, strings.TrimRight(string(secret), "\r\n")); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to export %s: %w", name, err)
+		}
+		exported = append(exported, name)
+	}
+
+	return cleanup, nil
+}
+
+// Validate checks the configuration for values which are invalid (rather than missing, which
+// is detected when the configuration is loaded), returning an error describing each of the
+// options (by their TOML key) which must be corrected. Requirements of the environment, such as
+// the existence of directories, are checked separately by the pre-flight checks.
+func (config *TheaConfig) Validate() error {
+	errs := make([]error, 0)
+	if config.LogLevel != "" {
+		if _, err := logger.ParseLevel(config.LogLevel); err != nil {
+			errs = append(errs, fmt.Errorf("log_level: %w", err))
+		}
+	}
+	if config.Format.MaximumThreadConsumption < 1 {
+		errs = append(errs, fmt.Errorf("transcode.max_thread_consumption: must be at least 1, got %d", config.Format.MaximumThreadConsumption))
+	}
+	if err := config.Format.ValidateStoragePools(); err != nil {
+		errs = append(errs, fmt.Errorf("transcode: %w", err))
+	}
+	if err := config.Format.ValidateReclaim(); err != nil {
+		errs = append(errs, fmt.Errorf("transcode.reclaim.%w", err))
+	}
+	if err := config.Format.Priority.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("transcode.priority: %w", err))
+	}
+	if err := config.Format.Contention.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("transcode.contention.%w", err))
+	}
+	if config.IngestService.IngestionParallelism < 1 {
+		errs = append(errs, fmt.Errorf("ingestion.parallelism: must be at least 1, got %d", config.IngestService.IngestionParallelism))
+	}
+	if config.IngestService.ProbeConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("ingestion.probe_concurrency: must be at least 1, got %d", config.IngestService.ProbeConcurrency))
+	}
+	if err := config.IngestService.DuplicatePolicy.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("ingestion.duplicate_policy: %w", err))
+	}
+	if !slices.Contains([]event.TransportBackend{"", event.LocalTransport, event.NatsTransport, event.RedisTransport}, config.Events.Backend) {
+		errs = append(errs, fmt.Errorf("events.backend: unknown event transport backend '%s'", config.Events.Backend))
+	}
+	if err := config.Passwords.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("password_hashing.%w", err))
+	}
+	if err := config.RestConfig.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("api: %w", err))
+	}
+	if err := config.Notifications.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("notifications: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
 // GetCacheDir will return the directory path used for storing cache information. It will first look to
 // in the config for a value, but if none is found, a default value will be returned. If the default
 // cannot be derived due to an error, a panic will occur.
@@ -63,6 +247,68 @@ func (config *TheaConfig) GetCacheDir() string {
 	return filepath.Join(dir, TheaUserDirSuffix)
 }
 
+// GetBackupDir will return the directory path used for storing database backups. If none is
+// configured, then a 'backups' directory inside of the config directory is used.
+func (config *TheaConfig) GetBackupDir() string {
+	if config.Backup.Dir != "" {
+		return config.Backup.Dir
+	}
+
+	return filepath.Join(config.GetConfigDir(), "backups")
+}
+
+// GetRemoteStagingDir will return the directory path which files fetched from remote sources are
+// downloaded to. If none is configured, then a 'remote-staging' directory inside of the cache
+// directory is used.
+func (config *TheaConfig) GetRemoteStagingDir() string {
+	if config.Remote.StagingPath != "" {
+		return config.Remote.StagingPath
+	}
+
+	return filepath.Join(config.GetCacheDir(), "remote-staging")
+}
+
+// GetArtworkCacheDir will return the directory path which artwork proxied from TMDB is cached in. If
+// none is configured, then an 'artwork' directory inside of the cache directory is used.
+func (config *TheaConfig) GetArtworkCacheDir() string {
+	if config.Artwork.CachePath != "" {
+		return config.Artwork.CachePath
+	}
+
+	return filepath.Join(config.GetCacheDir(), "artwork")
+}
+
+// GetTranscodeOutputPaths returns the distinct directories which transcode outputs may be
+// written to, being the default output directory and the path of each storage pool.
+func (config *TheaConfig) GetTranscodeOutputPaths() []string {
+	paths := []string{config.Format.OutputPath}
+	for _, pool := range config.Format.StoragePools {
+		if !slices.Contains(paths, pool.Path) {
+			paths = append(paths, pool.Path)
+		}
+	}
+
+	return paths
+}
+
+// GetMonitoredPaths returns the directories which Thea writes to, keyed by their purpose. The
+// disk usage of the volumes containing these paths is exposed via the API. Storage pools are
+// keyed by their name, prefixed with 'pool_'.
+func (config *TheaConfig) GetMonitoredPaths() map[string]string {
+	paths := map[string]string{
+		"ingest":           config.IngestService.GetIngestPath(),
+		"transcode_output": config.Format.OutputPath,
+		"cache":            config.GetCacheDir(),
+		"backups":          config.GetBackupDir(),
+		"remote_staging":   config.GetRemoteStagingDir(),
+	}
+	for _, pool := range config.Format.StoragePools {
+		paths["pool_"+pool.Name] = pool.Path
+	}
+
+	return paths
+}
+
 // GetConfigDir will return the path used for storing config information. It will first look to
 // in the config for a value, but if none is found, a default value will be returned.
 func (config *TheaConfig) GetConfigDir() string {
@@ -0,0 +1,365 @@
+// Package consistency verifies that the files Thea knows about still exist on disk. The source
+// file of each movie/episode, and the output file of each completed transcode, are checked
+// periodically (and on-demand), with missing files flagged as degraded. Files inside of the
+// transcode output directory which are not known to Thea (orphans) are reported, and
+// optionally removed. On-demand, the checksums of these files can also be verified, with
+// files that no longer match their recorded checksum flagged as corrupted.
+package consistency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var (
+	log = logger.Get("Consistency")
+
+	ErrCheckInProgress = errors.New("a consistency check is already in progress")
+)
+
+type (
+	Config struct {
+		Interval time.Duration `toml:"interval" env:"CONSISTENCY_CHECK_INTERVAL" env-default:"24h"`
+
+		// RemoveOrphans causes orphaned files found inside of the transcode output
+		// directory to be deleted. When disabled, orphans are only reported.
+		RemoveOrphans bool `toml:"remove_orphans" env:"CONSISTENCY_REMOVE_ORPHANS" env-default:"false"`
+	}
+
+	Store interface {
+		ListMediaSourceFiles() ([]*media.SourceFile, error)
+		SetMediaDegraded(mediaID uuid.UUID, degraded bool) error
+		GetAllTranscodes() ([]*transcode.Transcode, error)
+		SetTranscodeDegraded(id uuid.UUID, degraded bool) error
+		SetMediaChecksum(mediaID uuid.UUID, checksum string) error
+		SetMediaCorrupted(mediaID uuid.UUID, corrupted bool) error
+		SetTranscodeChecksum(id uuid.UUID, checksum string) error
+		SetTranscodeCorrupted(id uuid.UUID, corrupted bool) error
+	}
+
+	TaskProvider interface {
+		AllTasks() []*transcode.TranscodeTask
+	}
+
+	// Report describes the outcome of a single consistency check.
+	Report struct {
+		ID                uuid.UUID
+		StartedAt         time.Time
+		CompletedAt       time.Time
+		CheckedSources    int
+		CheckedTranscodes int
+		MissingSources    []*MissingSource
+		MissingTranscodes []*MissingTranscode
+		OrphanedFiles     []string
+		RemovedOrphans    int
+	}
+
+	MissingSource struct {
+		MediaID uuid.UUID
+		Path    string
+	}
+
+	MissingTranscode struct {
+		TranscodeID uuid.UUID
+		MediaID     uuid.UUID
+		Path        string
+	}
+
+	// Service performs consistency checks, both on-demand (see Check) and periodically if a
+	// check interval is configured, as well as on-demand integrity verifications (see
+	// RequestVerification). Only the report of the most recent check/verification is retained.
+	Service struct {
+		checkMutex     *sync.Mutex
+		reportMutex    *sync.Mutex
+		verifying      atomic.Bool
+		verifyRequests chan struct{}
+		config         Config
+		outputPaths    []string
+		store          Store
+		tasks          TaskProvider
+		eventBus       event.EventDispatcher
+
+		lastReport          *Report
+		lastIntegrityReport *IntegrityReport
+	}
+)
+
+func New(config Config, outputPaths []string, store Store, tasks TaskProvider, eventBus event.EventDispatcher) *Service {
+	return &Service{
+		checkMutex:     &sync.Mutex{},
+		reportMutex:    &sync.Mutex{},
+		verifyRequests: make(chan struct{}, 1),
+		config:         config,
+		outputPaths:    outputPaths,
+		store:          store,
+		tasks:          tasks,
+		eventBus:       eventBus,
+	}
+}
+
+// Run is the main entry point for this service. If a check interval is configured, consistency
+// checks are performed periodically. Requested integrity verifications are performed until
+// the context provided is cancelled.
+func (service *Service) Run(ctx context.Context) error {
+	var tick <-chan time.Time
+	if service.config.Interval <= 0 {
+		log.Emit(logger.WARNING, "Consistency check interval is not positive, automatic consistency checks are disabled\n")
+	} else {
+		ticker := time.NewTicker(service.config.Interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+		log.Emit(logger.NEW, "Consistency checker started (interval=%s, remove_orphans=%v)\n", service.config.Interval, service.config.RemoveOrphans)
+		service.automaticCheck(ctx)
+	}
+
+	for {
+		select {
+		case <-tick:
+			service.automaticCheck(ctx)
+		case <-service.verifyRequests:
+			service.verify(ctx)
+		case <-ctx.Done():
+			log.Emit(logger.STOP, "Consistency checker closed\n")
+			return nil
+		}
+	}
+}
+
+func (service *Service) automaticCheck(ctx context.Context) {
+	if _, err := service.Check(ctx); err != nil && !errors.Is(err, ErrCheckInProgress) {
+		log.Errorf("Automatic consistency check failed: %v\n", err)
+	}
+}
+
+// LastReport returns the report of the most recently completed
+// consistency check, or nil if no check has completed yet.
+func (service *Service) LastReport() *Report {
+	service.reportMutex.Lock()
+	defer service.reportMutex.Unlock()
+
+	return service.lastReport
+}
+
+// Check verifies the source file of every movie/episode, and the output file of every transcode,
+// updating the degraded state of each to reflect whether the file exists. The transcode output
+// directory is then searched for orphaned files. Only one check may run at a time, and
+// ErrCheckInProgress is returned if a check is already running.
+func (service *Service) Check(ctx context.Context) (*Report, error) {
+	if !service.checkMutex.TryLock() {
+		return nil, ErrCheckInProgress
+	}
+	defer service.checkMutex.Unlock()
+
+	report := &Report{
+		ID:                uuid.New(),
+		StartedAt:         time.Now(),
+		MissingSources:    make([]*MissingSource, 0),
+		MissingTranscodes: make([]*MissingTranscode, 0),
+		OrphanedFiles:     make([]string, 0),
+	}
+
+	// The output paths of the active tasks must be collected before the transcodes are
+	// listed, as a task which completes in between would otherwise appear to be orphaned.
+	knownPaths := make(map[string]struct{})
+	for _, task := range service.tasks.AllTasks() {
+		knownPaths[filepath.Clean(task.OutputPath())] = struct{}{}
+	}
+
+	changedMedia := make(map[uuid.UUID]struct{})
+	if err := service.checkSources(ctx, report, changedMedia); err != nil {
+		return nil, err
+	}
+	if err := service.checkTranscodes(ctx, report, changedMedia, knownPaths); err != nil {
+		return nil, err
+	}
+	if err := service.findOrphans(ctx, report, knownPaths); err != nil {
+		return nil, err
+	}
+
+	report.CompletedAt = time.Now()
+	log.Emit(logger.SUCCESS, "Consistency check complete: %d/%d sources missing, %d/%d transcodes missing, %d orphaned files (%d removed)\n",
+		len(report.MissingSources), report.CheckedSources, len(report.MissingTranscodes), report.CheckedTranscodes, len(report.OrphanedFiles), report.RemovedOrphans)
+
+	service.reportMutex.Lock()
+	service.lastReport = report
+	service.reportMutex.Unlock()
+
+	for mediaID := range changedMedia {
+		service.eventBus.Dispatch(event.DegradedMediaEvent, mediaID)
+	}
+	service.eventBus.Dispatch(event.ConsistencyCheckCompleteEvent, report.ID)
+
+	return report, nil
+}
+
+func (service *Service) checkSources(ctx context.Context, report *Report, changedMedia map[uuid.UUID]struct{}) error {
+	sources, err := service.store.ListMediaSourceFiles()
+	if err != nil {
+		return fmt.Errorf("failed to list media source files: %w", err)
+	}
+
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		missing, err := fileMissing(source.Path)
+		if err != nil {
+			log.Warnf("Failed to check source file of media %s (path = %s), skipping: %v\n", source.MediaID, source.Path, err)
+			continue
+		}
+
+		report.CheckedSources++
+		if missing {
+			report.MissingSources = append(report.MissingSources, &MissingSource{MediaID: source.MediaID, Path: source.Path})
+		}
+
+		if missing == (source.DegradedAt != nil) {
+			continue
+		}
+		if err := service.store.SetMediaDegraded(source.MediaID, missing); err != nil {
+			log.Errorf("Failed to update degraded state of media %s: %v\n", source.MediaID, err)
+			continue
+		}
+		changedMedia[source.MediaID] = struct{}{}
+	}
+
+	return nil
+}
+
+func (service *Service) checkTranscodes(ctx context.Context, report *Report, changedMedia map[uuid.UUID]struct{}, knownPaths map[string]struct{}) error {
+	transcodes, err := service.store.GetAllTranscodes()
+	if err != nil {
+		return fmt.Errorf("failed to list transcodes: %w", err)
+	}
+
+	for _, t := range transcodes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		knownPaths[filepath.Clean(t.MediaPath)] = struct{}{}
+		missing, err := fileMissing(t.MediaPath)
+		if err != nil {
+			log.Warnf("Failed to check output file of transcode %s (path = %s), skipping: %v\n", t.ID, t.MediaPath, err)
+			continue
+		}
+
+		report.CheckedTranscodes++
+		if missing {
+			report.MissingTranscodes = append(report.MissingTranscodes, &MissingTranscode{TranscodeID: t.ID, MediaID: t.MediaID, Path: t.MediaPath})
+		}
+
+		if missing == (t.DegradedAt != nil) {
+			continue
+		}
+		if err := service.store.SetTranscodeDegraded(t.ID, missing); err != nil {
+			log.Errorf("Failed to update degraded state of transcode %s: %v\n", t.ID, err)
+			continue
+		}
+		changedMedia[t.MediaID] = struct{}{}
+	}
+
+	return nil
+}
+
+// findOrphans walks each of the transcode output directories, reporting any files which are not the output
+// of a known transcode or an active task. Hidden directories (such as the directory previews are
+// written to) are skipped, as are files modified after the check started, as these are likely
+// the output of a task which started during the check.
+func (service *Service) findOrphans(ctx context.Context, report *Report, knownPaths map[string]struct{}) error {
+	for _, outputPath := range service.outputPaths {
+		if err := service.findOrphansIn(ctx, outputPath, report, knownPaths); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findOrphansIn reports the orphaned files inside of the output directory provided (see findOrphans).
+func (service *Service) findOrphansIn(ctx context.Context, outputPath string, report *Report, knownPaths map[string]struct{}) error {
+	if outputPath == "" {
+		return nil
+	}
+
+	err := filepath.WalkDir(outputPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if entry.IsDir() {
+			if path != outputPath && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if _, ok := knownPaths[filepath.Clean(path)]; ok {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			log.Warnf("Failed to stat %s during orphan search, skipping: %v\n", path, err)
+			return nil
+		} else if info.ModTime().After(report.StartedAt) {
+			return nil
+		}
+
+		report.OrphanedFiles = append(report.OrphanedFiles, path)
+		if service.config.RemoveOrphans {
+			if err := os.Remove(path); err != nil {
+				log.Warnf("Failed to remove orphaned file %s: %v\n", path, err)
+				return nil
+			}
+
+			log.Emit(logger.REMOVE, "Removed orphaned file %s\n", path)
+			report.RemovedOrphans++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to search for orphaned files in %s: %w", outputPath, err)
+	}
+
+	return nil
+}
+
+// fileMissing returns true if no file exists at the path provided. An error is returned
+// if the existence of the file could not be determined (e.g. due to permissions).
+func fileMissing(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	return false, nil
+}
@@ -0,0 +1,101 @@
+package consistency_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/consistency"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockStore struct {
+	sources    []*media.SourceFile
+	transcodes []*transcode.Transcode
+	degraded   map[uuid.UUID]bool
+}
+
+func (m *mockStore) ListMediaSourceFiles() ([]*media.SourceFile, error) { return m.sources, nil }
+func (m *mockStore) GetAllTranscodes() ([]*transcode.Transcode, error)  { return m.transcodes, nil }
+func (m *mockStore) SetMediaDegraded(mediaID uuid.UUID, degraded bool) error {
+	m.degraded[mediaID] = degraded
+	return nil
+}
+
+func (m *mockStore) SetTranscodeDegraded(id uuid.UUID, degraded bool) error {
+	m.degraded[id] = degraded
+	return nil
+}
+
+func (m *mockStore) SetMediaChecksum(uuid.UUID, string) error     { return nil }
+func (m *mockStore) SetMediaCorrupted(uuid.UUID, bool) error      { return nil }
+func (m *mockStore) SetTranscodeChecksum(uuid.UUID, string) error { return nil }
+func (m *mockStore) SetTranscodeCorrupted(uuid.UUID, bool) error  { return nil }
+
+type mockTaskProvider struct{}
+
+func (m *mockTaskProvider) AllTasks() []*transcode.TranscodeTask { return nil }
+
+type mockDispatcher struct{ events []event.Event }
+
+func (m *mockDispatcher) Dispatch(ev event.Event, _ event.Payload) { m.events = append(m.events, ev) }
+
+func writeFile(t *testing.T, path string) string {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	assert.NoError(t, os.WriteFile(path, []byte{}, 0o600))
+
+	past := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(path, past, past))
+	return path
+}
+
+func Test_CheckFlagsMissingFilesAndOrphans(t *testing.T) {
+	sourceDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	presentMedia, missingMedia, restoredMedia := uuid.New(), uuid.New(), uuid.New()
+	presentTranscode, missingTranscode := uuid.New(), uuid.New()
+	degradedAt := time.Now()
+
+	store := &mockStore{
+		sources: []*media.SourceFile{
+			{MediaID: presentMedia, Path: writeFile(t, filepath.Join(sourceDir, "present.mkv"))},
+			{MediaID: missingMedia, Path: filepath.Join(sourceDir, "missing.mkv")},
+			{MediaID: restoredMedia, Path: writeFile(t, filepath.Join(sourceDir, "restored.mkv")), DegradedAt: &degradedAt},
+		},
+		transcodes: []*transcode.Transcode{
+			{ID: presentTranscode, MediaID: presentMedia, MediaPath: writeFile(t, filepath.Join(outputDir, "a", "b.mp4"))},
+			{ID: missingTranscode, MediaID: presentMedia, MediaPath: filepath.Join(outputDir, "a", "c.mp4")},
+		},
+		degraded: make(map[uuid.UUID]bool),
+	}
+
+	orphan := writeFile(t, filepath.Join(outputDir, "orphan", "d.mp4"))
+	writeFile(t, filepath.Join(outputDir, ".previews", "e.mp4"))
+
+	dispatcher := &mockDispatcher{}
+	service := consistency.New(consistency.Config{RemoveOrphans: true}, []string{outputDir}, store, &mockTaskProvider{}, dispatcher)
+	report, err := service.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, report, service.LastReport())
+
+	assert.Equal(t, 3, report.CheckedSources)
+	assert.Equal(t, 2, report.CheckedTranscodes)
+	assert.Equal(t, []*consistency.MissingSource{{MediaID: missingMedia, Path: filepath.Join(sourceDir, "missing.mkv")}}, report.MissingSources)
+	assert.Len(t, report.MissingTranscodes, 1)
+	assert.Equal(t, missingTranscode, report.MissingTranscodes[0].TranscodeID)
+	assert.Equal(t, []string{orphan}, report.OrphanedFiles)
+	assert.Equal(t, 1, report.RemovedOrphans)
+	assert.NoFileExists(t, orphan)
+
+	// Only media whose degraded state changed should be updated
+	assert.Equal(t, map[uuid.UUID]bool{missingMedia: true, restoredMedia: false, missingTranscode: true}, store.degraded)
+	assert.Contains(t, dispatcher.events, event.DegradedMediaEvent)
+	assert.Contains(t, dispatcher.events, event.ConsistencyCheckCompleteEvent)
+}
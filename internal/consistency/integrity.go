@@ -0,0 +1,175 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/file"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var ErrVerificationInProgress = errors.New("an integrity verification is already in progress")
+
+type (
+	// IntegrityReport describes the outcome of a single integrity verification, in which
+	// the checksum of each source/transcode file is compared to the checksum recorded
+	// when the file was ingested/transcoded.
+	IntegrityReport struct {
+		ID          uuid.UUID
+		StartedAt   time.Time
+		CompletedAt time.Time
+		Verified    int
+		// Recorded is the number of files which had no checksum recorded (e.g. as they
+		// were ingested before checksums were introduced), and so had their checksum recorded
+		// rather than verified.
+		Recorded  int
+		Corrupted []*CorruptFile
+	}
+
+	// CorruptFile is a source file (in which case TranscodeID is nil), or
+	// a transcode output, which did not match it's recorded checksum.
+	CorruptFile struct {
+		MediaID     uuid.UUID
+		TranscodeID *uuid.UUID
+		Path        string
+	}
+
+	checksummedFile struct {
+		path        string
+		checksum    *string
+		corruptedAt *time.Time
+	}
+)
+
+// RequestVerification queues an integrity verification, which is performed in the background as
+// checksumming the library may take a considerable amount of time. The report can be retrieved
+// using LastIntegrityReport once the verification completes. If a verification is already
+// queued or in progress, ErrVerificationInProgress is returned.
+func (service *Service) RequestVerification() error {
+	if !service.verifying.CompareAndSwap(false, true) {
+		return ErrVerificationInProgress
+	}
+
+	service.verifyRequests <- struct{}{}
+	return nil
+}
+
+// LastIntegrityReport returns the report of the most recently completed
+// integrity verification, or nil if no verification has completed yet.
+func (service *Service) LastIntegrityReport() *IntegrityReport {
+	service.reportMutex.Lock()
+	defer service.reportMutex.Unlock()
+
+	return service.lastIntegrityReport
+}
+
+// verify compares the checksum of every source and transcode file against it's recorded checksum,
+// updating the corrupted state of each. Files which are missing are skipped, as these are flagged
+// as degraded by the consistency check. Files with no recorded checksum have their checksum recorded.
+func (service *Service) verify(ctx context.Context) {
+	defer service.verifying.Store(false)
+
+	report := &IntegrityReport{ID: uuid.New(), StartedAt: time.Now(), Corrupted: make([]*CorruptFile, 0)}
+	changedMedia := make(map[uuid.UUID]struct{})
+	log.Emit(logger.NEW, "Starting integrity verification %s\n", report.ID)
+
+	sources, err := service.store.ListMediaSourceFiles()
+	if err != nil {
+		log.Errorf("Integrity verification failed, unable to list media source files: %v\n", err)
+		return
+	}
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			return
+		}
+
+		corrupt, changed := service.verifyFile(report, checksummedFile{source.Path, source.Checksum, source.CorruptedAt},
+			func(checksum string) error { return service.store.SetMediaChecksum(source.MediaID, checksum) },
+			func(corrupted bool) error { return service.store.SetMediaCorrupted(source.MediaID, corrupted) },
+		)
+		if corrupt {
+			report.Corrupted = append(report.Corrupted, &CorruptFile{MediaID: source.MediaID, Path: source.Path})
+		}
+		if changed {
+			changedMedia[source.MediaID] = struct{}{}
+		}
+	}
+
+	transcodes, err := service.store.GetAllTranscodes()
+	if err != nil {
+		log.Errorf("Integrity verification failed, unable to list transcodes: %v\n", err)
+		return
+	}
+	for _, t := range transcodes {
+		if ctx.Err() != nil {
+			return
+		}
+
+		corrupt, changed := service.verifyFile(report, checksummedFile{t.MediaPath, t.Checksum, t.CorruptedAt},
+			func(checksum string) error { return service.store.SetTranscodeChecksum(t.ID, checksum) },
+			func(corrupted bool) error { return service.store.SetTranscodeCorrupted(t.ID, corrupted) },
+		)
+		if corrupt {
+			report.Corrupted = append(report.Corrupted, &CorruptFile{MediaID: t.MediaID, TranscodeID: &t.ID, Path: t.MediaPath})
+		}
+		if changed {
+			changedMedia[t.MediaID] = struct{}{}
+		}
+	}
+
+	report.CompletedAt = time.Now()
+	log.Emit(logger.SUCCESS, "Integrity verification %s complete: %d files verified, %d checksums recorded, %d corrupt\n",
+		report.ID, report.Verified, report.Recorded, len(report.Corrupted))
+
+	service.reportMutex.Lock()
+	service.lastIntegrityReport = report
+	service.reportMutex.Unlock()
+
+	for mediaID := range changedMedia {
+		service.eventBus.Dispatch(event.CorruptedMediaEvent, mediaID)
+	}
+}
+
+// verifyFile checksums the file provided, recording the checksum if the file has none, or
+// otherwise comparing it to the recorded checksum and updating the corrupted state of the file
+// if it has changed. Returns whether the file is corrupt, and whether it's corrupted state changed.
+func (service *Service) verifyFile(report *IntegrityReport, f checksummedFile, setChecksum func(string) error, setCorrupted func(bool) error) (bool, bool) {
+	checksum, err := file.Checksum(f.path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Warnf("Failed to checksum %s, skipping: %v\n", f.path, err)
+		}
+
+		return false, false
+	}
+
+	if f.checksum == nil {
+		if err := setChecksum(checksum); err != nil {
+			log.Errorf("Failed to record checksum of %s: %v\n", f.path, err)
+			return false, false
+		}
+
+		report.Recorded++
+		return false, false
+	}
+
+	report.Verified++
+	corrupt := checksum != *f.checksum
+	if corrupt {
+		log.Warnf("Checksum of %s does not match the recorded checksum, file is corrupt\n", f.path)
+	}
+
+	if corrupt == (f.corruptedAt != nil) {
+		return corrupt, false
+	}
+	if err := setCorrupted(corrupt); err != nil {
+		log.Errorf("Failed to update corrupted state of %s: %v\n", f.path, err)
+		return corrupt, false
+	}
+
+	return corrupt, true
+}
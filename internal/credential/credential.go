@@ -0,0 +1,68 @@
+// Package credential encrypts the credentials (such as passwords and access tokens) which Thea
+// must store in order to authenticate with other services on behalf of it's users.
+package credential
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrKeyMissing = errors.New("a credential key is required")
+	ErrCorrupt    = errors.New("stored credential could not be decrypted, the credential key may have changed")
+)
+
+// Cipher encrypts credentials using AES-GCM, with a key derived from a
+// configured credential key. The nonce used to encrypt each credential
+// is prepended to the encrypted credential.
+type Cipher struct{ aead cipher.AEAD }
+
+// NewCipher returns a cipher using the key provided, which must not be empty.
+func NewCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, ErrKeyMissing
+	}
+
+	derived := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credential cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credential cipher: %w", err)
+	}
+
+	return &Cipher{aead}, nil
+}
+
+// Encrypt returns the plaintext provided encrypted, prefixed with the nonce used to encrypt it.
+func (c *Cipher) Encrypt(plaintext string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+// Decrypt returns the plaintext of a credential encrypted by Encrypt. ErrCorrupt is returned
+// if the credential cannot be decrypted (e.g. because it was encrypted using another key).
+func (c *Cipher) Decrypt(encrypted []byte) (string, error) {
+	if len(encrypted) < c.aead.NonceSize() {
+		return "", ErrCorrupt
+	}
+
+	nonce, ciphertext := encrypted[:c.aead.NonceSize()], encrypted[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrCorrupt
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,29 @@
+package credential
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cipher_RoundTrip(t *testing.T) {
+	t.Parallel()
+	cipher, err := NewCipher("key")
+	assert.NoError(t, err)
+
+	encrypted, err := cipher.Encrypt("hunter2")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "hunter2")
+
+	decrypted, err := cipher.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", decrypted)
+
+	other, err := NewCipher("another key")
+	assert.NoError(t, err)
+	_, err = other.Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	_, err = NewCipher("")
+	assert.ErrorIs(t, err, ErrKeyMissing)
+}
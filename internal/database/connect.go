@@ -38,8 +38,14 @@ type (
 
 	Manager interface {
 		Connect(config DatabaseConfig) error
+		Migrate() error
+		SchemaVersion() (int64, error)
+		MigrationStatus() (*MigrationStatus, error)
 		GetSqlxDB() *sqlx.DB
+		Queryable() Queryable
+		Metrics() *Metrics
 		WrapTx(wrapper func(tx *sqlx.Tx) error) error
+		WrapReadTx(wrapper func(tx *sqlx.Tx) error) error
 	}
 	// Queryable includes all methods shared by sqlx.DB and sqlx.Tx, allowing
 	// either type to be used interchangeably.
@@ -69,13 +75,14 @@ type (
 	}
 
 	manager struct {
-		rawDB *sql.DB
-		db    *sqlx.DB
+		rawDB    *sql.DB
+		db       *sqlx.DB
+		recorder *queryRecorder
 	}
 )
 
 func New() *manager {
-	return &manager{}
+	return &manager{recorder: &queryRecorder{}}
 }
 
 // Connect will attempt to use the config provided to connect to
@@ -89,13 +96,37 @@ func New() *manager {
 // instances to the newly-connected database, *and* any outstanding migrations
 // are run using [executeMigrations].
 func (db *manager) Connect(config DatabaseConfig) error {
-	dsn := fmt.Sprintf(SQLConnectionString, config.Host, config.User, config.Password, config.Name, config.Port)
+	if err := db.Open(config); err != nil {
+		return err
+	}
+
+	if err := db.executeMigrations(); err != nil {
+		return err
+	}
+
+	dbLogger.Emit(logger.SUCCESS, "Database connection established!\n")
+	return nil
+}
+
+// Open connects to the database in the same way as Connect, however no migrations
+// are run. This allows the migrations to be managed manually (see MigrateTo).
+func (db *manager) Open(config DatabaseConfig) error {
+	dsn := config.DSN()
 	sql, err := sql.Open(SQLDialect, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open postgres connection: %w", err)
 	}
 
 	sql = sqldblogger.OpenDriver(dsn, sql.Driver(), &SQLLogger{dbLogger})
+	if config.MaxOpenConnections > 0 {
+		sql.SetMaxOpenConns(config.MaxOpenConnections)
+	}
+	if config.MaxIdleConnections > 0 {
+		sql.SetMaxIdleConns(config.MaxIdleConnections)
+	}
+	sql.SetConnMaxLifetime(config.ConnectionMaxLifetime)
+	sql.SetConnMaxIdleTime(config.ConnectionMaxIdleTime)
+	db.recorder.slowThreshold = config.SlowQueryThreshold
 
 	attempt := 1
 	for {
@@ -118,11 +149,6 @@ func (db *manager) Connect(config DatabaseConfig) error {
 		break
 	}
 
-	if err := db.executeMigrations(); err != nil {
-		return err
-	}
-
-	dbLogger.Emit(logger.SUCCESS, "Database connection established!\n")
 	return nil
 }
 
@@ -137,10 +163,18 @@ func (db *manager) executeMigrations() error {
 		return fmt.Errorf("cannot execute migrations when DB manager has not yet connected")
 	}
 
-	goose.SetBaseFS(migrations)
-	goose.SetLogger(dbLogger)
-	if err := goose.SetDialect(SQLDialect); err != nil {
-		return fmt.Errorf("failed to set dialect for DB migration: %w", err)
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	// Goose silently ignores migrations it does not know of, however the
+	// queries used by this version of Thea may not work with a newer schema.
+	current, err := goose.GetDBVersion(rawDB)
+	if err != nil {
+		return fmt.Errorf("failed to determine DB schema version: %w", err)
+	} else if current > latest {
+		return fmt.Errorf("%w (schema version %d, latest supported %d): upgrade Thea, or restore a backup taken using this version", ErrSchemaTooNew, current, latest)
 	}
 
 	dbLogger.Emit(logger.INFO, "Checking for pending DB migrations...\n")
@@ -155,12 +189,84 @@ func (db *manager) executeMigrations() error {
 	return nil
 }
 
+// Migrate runs any outstanding migrations against the connected database. Migrations
+// are run automatically when connecting (see Connect), so this is only required
+// if the schema has since been modified externally (e.g. by restoring a backup).
+func (db *manager) Migrate() error {
+	return db.executeMigrations()
+}
+
+// SchemaVersion returns the version of the most recent migration
+// which has been applied to the connected database.
+func (db *manager) SchemaVersion() (int64, error) {
+	if db.rawDB == nil {
+		return 0, errors.New("DB manager has not yet connected")
+	}
+
+	return goose.GetDBVersion(db.rawDB)
+}
+
+// LatestSchemaVersion returns the version of the most recent migration
+// known to Thea (i.e. the version the database will be migrated to).
+func LatestSchemaVersion() (int64, error) {
+	all, err := collectMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	latest, err := all.Last()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find latest DB migration: %w", err)
+	}
+
+	return latest.Version, nil
+}
+
+// AppliedSchemaVersion returns the version of the most recent migration which has been applied
+// to the database provided. Unlike SchemaVersion, this does not create the migration version table
+// if it is missing (i.e. the database has never been migrated), in which case zero is returned.
+func AppliedSchemaVersion(db *sqlx.DB) (int64, error) {
+	var exists bool
+	if err := db.Get(&exists, `SELECT to_regclass($1) IS NOT NULL`, goose.TableName()); err != nil {
+		return 0, fmt.Errorf("failed to check for DB migration table: %w", err)
+	} else if !exists {
+		return 0, nil
+	}
+
+	var version int64
+	query := fmt.Sprintf(`SELECT COALESCE(MAX(version_id), 0) FROM %s WHERE is_applied`, goose.TableName())
+	if err := db.Get(&version, query); err != nil {
+		return 0, fmt.Errorf("failed to query applied DB migrations: %w", err)
+	}
+
+	return version, nil
+}
+
 // GetSqlxDB returns the Goqu database connection if
 // one has been opened using 'Connect'. Otherwise, nil is returned.
 func (db *manager) GetSqlxDB() *sqlx.DB {
 	return db.db
 }
 
+// Queryable returns the connection pool opened using 'Connect', wrapped so that the
+// duration and outcome of each query made is recorded (see Metrics). Queries made
+// inside of a transaction are recorded as part of the transaction instead.
+func (db *manager) Queryable() Queryable {
+	return &instrumentedQueryable{Queryable: db.db, recorder: db.recorder}
+}
+
+// Metrics returns the statistics of the connection pool, along with the
+// metrics of the queries and transactions made since Thea started.
+func (db *manager) Metrics() *Metrics {
+	queries, transactions := db.recorder.snapshot()
+	metrics := &Metrics{Queries: queries, Transactions: transactions}
+	if db.rawDB != nil {
+		metrics.Pool = db.rawDB.Stats()
+	}
+
+	return metrics
+}
+
 // WrapTx is a convinience method around the top-level WrapTx, which simply
 // uses the managers DB instance as the first argument.
 func (db *manager) WrapTx(f func(tx *sqlx.Tx) error) error {
@@ -168,7 +274,23 @@ func (db *manager) WrapTx(f func(tx *sqlx.Tx) error) error {
 		return errors.New("DB manager has not yet connected")
 	}
 
-	return WrapTx(db.db, f)
+	started := time.Now()
+	err := WrapTx(db.db, f)
+	db.recorder.recordTransaction(started, err)
+	return err
+}
+
+// WrapReadTx is a convinience method around the top-level WrapReadTx, which simply
+// uses the managers DB instance as the first argument.
+func (db *manager) WrapReadTx(f func(tx *sqlx.Tx) error) error {
+	if db.db == nil {
+		return errors.New("DB manager has not yet connected")
+	}
+
+	started := time.Now()
+	err := WrapReadTx(db.db, f)
+	db.recorder.recordTransaction(started, err)
+	return err
 }
 
 func (l *SQLLogger) Log(_ context.Context, level sqldblogger.Level, msg string, data map[string]any) {
@@ -207,6 +329,25 @@ func WrapTx(db *sqlx.DB, f func(tx *sqlx.Tx) error) error {
 	return tx.Commit()
 }
 
+// WrapReadTx starts a read-only transaction against the provided DB, and then calls
+// the user provided function. The transaction uses the repeatable read isolation level,
+// so that all the queries made by the function observe the same snapshot of the DB, which
+// is required for reads spanning multiple queries (e.g. a page of results and the total
+// count) to be consistent with one another.
+func WrapReadTx(db *sqlx.DB, f func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint
+
+	if err := f(tx); err != nil {
+		return fmt.Errorf("wrapped DB read transaction failed: %w", err)
+	}
+
+	return tx.Commit()
+}
+
 // InExec is a convinience method which combines sqlx's `In` method
 // and the `Exec` of the output query. Rebinding of the
 // query is handled automatically, and errors resulting from
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	// QueryStats are the aggregated metrics of the queries (or transactions)
+	// made against the database since Thea started.
+	QueryStats struct {
+		Count         int64
+		Errors        int64
+		SlowCount     int64
+		TotalDuration time.Duration
+		MaxDuration   time.Duration
+	}
+
+	// Metrics contains the statistics of the connection pool, as well as the
+	// metrics of the queries and transactions made using the pool.
+	Metrics struct {
+		Pool         sql.DBStats
+		Queries      QueryStats
+		Transactions QueryStats
+	}
+
+	// queryRecorder aggregates the durations and errors of queries. Queries which take longer
+	// than the slow threshold are logged, along with the query itself. A zero threshold
+	// disables the logging of slow queries.
+	queryRecorder struct {
+		sync.Mutex
+		slowThreshold time.Duration
+		queries       QueryStats
+		transactions  QueryStats
+	}
+
+	// instrumentedQueryable wraps a Queryable, recording the duration and outcome of each
+	// query made. Prepared statements are not instrumented, as the statement is executed
+	// after the Queryable has returned it.
+	instrumentedQueryable struct {
+		Queryable
+		recorder *queryRecorder
+	}
+)
+
+// AverageDuration returns the mean duration of the recorded queries,
+// or zero if no queries have been recorded.
+func (stats QueryStats) AverageDuration() time.Duration {
+	if stats.Count == 0 {
+		return 0
+	}
+
+	return stats.TotalDuration / time.Duration(stats.Count)
+}
+
+func (stats *QueryStats) record(duration time.Duration, err error, slow bool) {
+	stats.Count++
+	stats.TotalDuration += duration
+	if duration > stats.MaxDuration {
+		stats.MaxDuration = duration
+	}
+	if err != nil {
+		stats.Errors++
+	}
+	if slow {
+		stats.SlowCount++
+	}
+}
+
+func (recorder *queryRecorder) recordQuery(query string, started time.Time, err error) {
+	duration := time.Since(started)
+	slow := recorder.slowThreshold > 0 && duration >= recorder.slowThreshold
+
+	recorder.Lock()
+	recorder.queries.record(duration, err, slow)
+	recorder.Unlock()
+
+	if slow {
+		dbLogger.Warnf("Slow query took %s (threshold %s) -- %s\n", duration, recorder.slowThreshold, query)
+	}
+}
+
+func (recorder *queryRecorder) recordTransaction(started time.Time, err error) {
+	duration := time.Since(started)
+	slow := recorder.slowThreshold > 0 && duration >= recorder.slowThreshold
+
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.transactions.record(duration, err, slow)
+}
+
+func (recorder *queryRecorder) snapshot() (QueryStats, QueryStats) {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	return recorder.queries, recorder.transactions
+}
+
+// errNoRowsIsNotFailure filters sql.ErrNoRows from the error provided, as
+// a query which matches no rows has not failed.
+func errNoRowsIsNotFailure(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	return err
+}
+
+func (db *instrumentedQueryable) Exec(query string, args ...any) (sql.Result, error) {
+	started := time.Now()
+	res, err := db.Queryable.Exec(query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return res, err
+}
+
+func (db *instrumentedQueryable) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	started := time.Now()
+	res, err := db.Queryable.ExecContext(ctx, query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return res, err
+}
+
+func (db *instrumentedQueryable) MustExec(query string, args ...any) sql.Result {
+	started := time.Now()
+	res, err := db.Queryable.Exec(query, args...)
+	db.recorder.recordQuery(query, started, err)
+	if err != nil {
+		panic(err)
+	}
+
+	return res
+}
+
+func (db *instrumentedQueryable) MustExecContext(ctx context.Context, query string, args ...any) sql.Result {
+	started := time.Now()
+	res, err := db.Queryable.ExecContext(ctx, query, args...)
+	db.recorder.recordQuery(query, started, err)
+	if err != nil {
+		panic(err)
+	}
+
+	return res
+}
+
+func (db *instrumentedQueryable) NamedExec(query string, arg any) (sql.Result, error) {
+	started := time.Now()
+	res, err := db.Queryable.NamedExec(query, arg)
+	db.recorder.recordQuery(query, started, err)
+	return res, err
+}
+
+func (db *instrumentedQueryable) NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error) {
+	started := time.Now()
+	res, err := db.Queryable.NamedExecContext(ctx, query, arg)
+	db.recorder.recordQuery(query, started, err)
+	return res, err
+}
+
+func (db *instrumentedQueryable) Query(query string, args ...any) (*sql.Rows, error) {
+	started := time.Now()
+	rows, err := db.Queryable.Query(query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return rows, err
+}
+
+func (db *instrumentedQueryable) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	started := time.Now()
+	rows, err := db.Queryable.QueryContext(ctx, query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return rows, err
+}
+
+func (db *instrumentedQueryable) Queryx(query string, args ...any) (*sqlx.Rows, error) {
+	started := time.Now()
+	rows, err := db.Queryable.Queryx(query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return rows, err
+}
+
+func (db *instrumentedQueryable) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	started := time.Now()
+	rows, err := db.Queryable.QueryxContext(ctx, query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return rows, err
+}
+
+func (db *instrumentedQueryable) NamedQuery(query string, arg any) (*sqlx.Rows, error) {
+	started := time.Now()
+	rows, err := db.Queryable.NamedQuery(query, arg)
+	db.recorder.recordQuery(query, started, err)
+	return rows, err
+}
+
+func (db *instrumentedQueryable) QueryRow(query string, args ...any) *sql.Row {
+	started := time.Now()
+	row := db.Queryable.QueryRow(query, args...)
+	db.recorder.recordQuery(query, started, errNoRowsIsNotFailure(row.Err()))
+	return row
+}
+
+func (db *instrumentedQueryable) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	started := time.Now()
+	row := db.Queryable.QueryRowContext(ctx, query, args...)
+	db.recorder.recordQuery(query, started, errNoRowsIsNotFailure(row.Err()))
+	return row
+}
+
+func (db *instrumentedQueryable) QueryRowx(query string, args ...any) *sqlx.Row {
+	started := time.Now()
+	row := db.Queryable.QueryRowx(query, args...)
+	db.recorder.recordQuery(query, started, errNoRowsIsNotFailure(row.Err()))
+	return row
+}
+
+func (db *instrumentedQueryable) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	started := time.Now()
+	row := db.Queryable.QueryRowxContext(ctx, query, args...)
+	db.recorder.recordQuery(query, started, errNoRowsIsNotFailure(row.Err()))
+	return row
+}
+
+func (db *instrumentedQueryable) Get(dest any, query string, args ...any) error {
+	started := time.Now()
+	err := db.Queryable.Get(dest, query, args...)
+	db.recorder.recordQuery(query, started, errNoRowsIsNotFailure(err))
+	return err
+}
+
+func (db *instrumentedQueryable) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	started := time.Now()
+	err := db.Queryable.GetContext(ctx, dest, query, args...)
+	db.recorder.recordQuery(query, started, errNoRowsIsNotFailure(err))
+	return err
+}
+
+func (db *instrumentedQueryable) Select(dest any, query string, args ...any) error {
+	started := time.Now()
+	err := db.Queryable.Select(dest, query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return err
+}
+
+func (db *instrumentedQueryable) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	started := time.Now()
+	err := db.Queryable.SelectContext(ctx, dest, query, args...)
+	db.recorder.recordQuery(query, started, err)
+	return err
+}
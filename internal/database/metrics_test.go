@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubQueryable struct {
+	Queryable
+	delay time.Duration
+	err   error
+}
+
+func (db *stubQueryable) Exec(_ string, _ ...any) (sql.Result, error) {
+	time.Sleep(db.delay)
+	return nil, db.err
+}
+
+func (db *stubQueryable) Get(_ any, _ string, _ ...any) error {
+	return db.err
+}
+
+func Test_InstrumentedQueryableRecordsQueries(t *testing.T) {
+	recorder := &queryRecorder{slowThreshold: 5 * time.Millisecond}
+
+	ok := &instrumentedQueryable{Queryable: &stubQueryable{}, recorder: recorder}
+	_, _ = ok.Exec(`SELECT 1`)
+
+	slow := &instrumentedQueryable{Queryable: &stubQueryable{delay: 10 * time.Millisecond}, recorder: recorder}
+	_, _ = slow.Exec(`SELECT pg_sleep(1)`)
+
+	failed := &instrumentedQueryable{Queryable: &stubQueryable{err: errors.New("syntax error")}, recorder: recorder}
+	_, _ = failed.Exec(`SELEC 1`)
+
+	queries, transactions := recorder.snapshot()
+	assert.Equal(t, int64(3), queries.Count)
+	assert.Equal(t, int64(1), queries.Errors)
+	assert.Equal(t, int64(1), queries.SlowCount)
+	assert.GreaterOrEqual(t, queries.MaxDuration, 10*time.Millisecond)
+	assert.Zero(t, transactions.Count)
+}
+
+func Test_InstrumentedQueryableIgnoresNoRows(t *testing.T) {
+	recorder := &queryRecorder{}
+	db := &instrumentedQueryable{Queryable: &stubQueryable{err: sql.ErrNoRows}, recorder: recorder}
+
+	assert.ErrorIs(t, db.Get(nil, `SELECT 1 WHERE false`), sql.ErrNoRows)
+
+	queries, _ := recorder.snapshot()
+	assert.Equal(t, int64(1), queries.Count)
+	assert.Zero(t, queries.Errors)
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+var (
+	ErrSchemaTooNew        = errors.New("database schema is newer than this version of Thea supports")
+	ErrUnknownMigration    = errors.New("migration version is not known to this version of Thea")
+	ErrDowngradeNotAllowed = errors.New("migrating to an older schema version is destructive, and must be explicitly allowed")
+	ErrIrreversible        = errors.New("migration cannot be reversed")
+)
+
+type (
+	// MigrationStatus describes the schema version of a database, and which of
+	// the migrations known to Thea have been applied to it.
+	MigrationStatus struct {
+		Current    int64
+		Latest     int64
+		Migrations []MigrationState
+	}
+
+	MigrationState struct {
+		Version int64
+		Name    string
+		// AppliedAt is nil if the migration has not been applied.
+		AppliedAt *time.Time
+	}
+)
+
+// MigrationStatus returns the migrations known to Thea, and which of them
+// have been applied to the connected database.
+func (db *manager) MigrationStatus() (*MigrationStatus, error) {
+	if db.db == nil {
+		return nil, errors.New("DB manager has not yet connected")
+	}
+
+	known, err := collectMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := AppliedSchemaVersion(db.db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]time.Time)
+	if current > 0 {
+		var rows []struct {
+			Version   int64     `db:"version_id"`
+			AppliedAt time.Time `db:"applied_at"`
+		}
+		query := fmt.Sprintf(`SELECT version_id, MAX(tstamp) AS applied_at FROM %s WHERE is_applied AND version_id > 0 GROUP BY version_id`, goose.TableName())
+		if err := db.db.Select(&rows, query); err != nil {
+			return nil, fmt.Errorf("failed to query applied DB migrations: %w", err)
+		}
+		for _, row := range rows {
+			applied[row.Version] = row.AppliedAt
+		}
+	}
+
+	status := &MigrationStatus{Current: current, Migrations: make([]MigrationState, 0, len(known))}
+	for _, m := range known {
+		state := MigrationState{Version: m.Version, Name: path.Base(m.Source)}
+		if at, ok := applied[m.Version]; ok {
+			state.AppliedAt = &at
+		}
+		status.Migrations = append(status.Migrations, state)
+		status.Latest = max(status.Latest, m.Version)
+	}
+
+	return status, nil
+}
+
+// MigrateTo migrates the connected database to the schema version provided. Migrating
+// to an older version reverses the migrations applied since, which may discard data (e.g.
+// dropping the tables/columns the migration added), and so is only performed if allowDowngrade
+// is true. The initial migration can never be reversed.
+func (db *manager) MigrateTo(version int64, allowDowngrade bool) error {
+	if db.rawDB == nil {
+		return errors.New("DB manager has not yet connected")
+	}
+
+	known, err := collectMigrations()
+	if err != nil {
+		return err
+	}
+	if _, err := known.Current(version); err != nil {
+		return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+
+	current, err := goose.GetDBVersion(db.rawDB)
+	if err != nil {
+		return fmt.Errorf("failed to determine DB schema version: %w", err)
+	}
+
+	if version >= current {
+		if err := goose.UpTo(db.rawDB, "migrations", version); err != nil {
+			return fmt.Errorf("failed to migrate DB: %w", err)
+		}
+
+		return nil
+	}
+
+	if !allowDowngrade {
+		return ErrDowngradeNotAllowed
+	}
+	for _, m := range known {
+		if m.Version <= version || m.Version > current {
+			continue
+		}
+		if reversible, err := isReversible(m.Source); err != nil {
+			return err
+		} else if !reversible {
+			return fmt.Errorf("%w: migration %s has no down migration", ErrIrreversible, path.Base(m.Source))
+		}
+	}
+
+	dbLogger.Warnf("Reversing DB migrations from version %d to %d\n", current, version)
+	if err := goose.DownTo(db.rawDB, "migrations", version); err != nil {
+		return fmt.Errorf("failed to reverse DB migrations: %w", err)
+	}
+
+	return nil
+}
+
+func collectMigrations() (goose.Migrations, error) {
+	goose.SetBaseFS(migrations)
+	goose.SetLogger(dbLogger)
+	if err := goose.SetDialect(SQLDialect); err != nil {
+		return nil, fmt.Errorf("failed to set dialect for DB migration: %w", err)
+	}
+
+	known, err := goose.CollectMigrations("migrations", 0, goose.MaxVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect DB migrations: %w", err)
+	}
+
+	return known, nil
+}
+
+// isReversible returns true if the SQL migration at the path provided contains a down migration.
+func isReversible(source string) (bool, error) {
+	sql, err := fs.ReadFile(migrations, source)
+	if err != nil {
+		return false, fmt.Errorf("failed to read migration %s: %w", source, err)
+	}
+
+	return strings.Contains(string(sql), "-- +goose Down"), nil
+}
@@ -0,0 +1,28 @@
+-- +goose Up
+
+CREATE TABLE roles(
+    id UUID NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    label TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE roles_permissions(
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+
+    CONSTRAINT roles_permissions_uk_role_permission UNIQUE(role_id, permission_id)
+);
+
+CREATE TABLE user_roles(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+
+    CONSTRAINT user_roles_uk_user_role UNIQUE(user_id, role_id)
+);
+
+-- +goose Down
+
+DROP TABLE user_roles;
+DROP TABLE roles_permissions;
+DROP TABLE roles;
@@ -0,0 +1,23 @@
+-- +goose Up
+
+-- The time each transcode was saved, used to decide how long a transcode which has never been
+-- played has been idle for. Transcodes saved before this column existed use the time of migration.
+ALTER TABLE media_transcodes ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp;
+
+-- The number of times each transcode has been streamed (and streamed to completion), along with
+-- when it was last streamed. This ranks the transcodes which are reclaimed first when archiving
+-- or evicting transcodes, so that rarely watched renditions go first.
+CREATE TABLE transcode_playback(
+    transcode_id UUID NOT NULL,
+    starts INT NOT NULL DEFAULT 0,
+    completions INT NOT NULL DEFAULT 0,
+    last_played_at TIMESTAMPTZ NOT NULL,
+
+    CONSTRAINT transcode_playback_pk PRIMARY KEY(transcode_id),
+    CONSTRAINT transcode_playback_fk_transcode_id FOREIGN KEY(transcode_id) REFERENCES media_transcodes(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+
+DROP TABLE transcode_playback;
+ALTER TABLE media_transcodes DROP COLUMN created_at;
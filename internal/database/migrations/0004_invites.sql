@@ -0,0 +1,31 @@
+-- +goose Up
+
+CREATE TABLE invites(
+    id UUID NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    consumed_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE invites_permissions(
+    invite_id UUID NOT NULL REFERENCES invites(id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+
+    CONSTRAINT invites_permissions_uk_invite_permission UNIQUE(invite_id, permission_id)
+);
+
+CREATE TABLE invites_roles(
+    invite_id UUID NOT NULL REFERENCES invites(id) ON DELETE CASCADE,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+
+    CONSTRAINT invites_roles_uk_invite_role UNIQUE(invite_id, role_id)
+);
+
+-- +goose Down
+
+DROP TABLE invites_roles;
+DROP TABLE invites_permissions;
+DROP TABLE invites;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+CREATE TABLE audit_log(
+    id UUID NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    user_id UUID,
+    action TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    request_id TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_idx_created_at ON audit_log(created_at);
+
+-- +goose Down
+
+DROP TABLE audit_log;
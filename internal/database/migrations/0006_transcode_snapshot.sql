@@ -0,0 +1,15 @@
+-- +goose Up
+
+CREATE TABLE transcode_queue_snapshot(
+    media_id UUID NOT NULL,
+    transcode_target_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+
+    CONSTRAINT transcode_queue_snapshot_fk_media_id FOREIGN KEY(media_id) REFERENCES media(id) ON DELETE CASCADE,
+    CONSTRAINT transcode_queue_snapshot_fk_transcode_target_id FOREIGN KEY(transcode_target_id) REFERENCES transcode_target(id) ON DELETE CASCADE,
+    UNIQUE(media_id, transcode_target_id)
+);
+
+-- +goose Down
+
+DROP TABLE transcode_queue_snapshot;
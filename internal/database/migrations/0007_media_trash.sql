@@ -0,0 +1,24 @@
+-- +goose Up
+
+-- Soft-deletion of media. A non-NULL deleted_at indicates that the row is in the
+-- trash, and will be purged once the configured retention window has elapsed.
+-- Children of a trashed series/season are trashed with the same timestamp, so
+-- that restoring the parent only restores the children trashed alongside it.
+ALTER TABLE series ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE season ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE media ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX series_idx_deleted_at ON series(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX season_idx_deleted_at ON season(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX media_idx_deleted_at ON media(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+
+-- Media in the trash cannot be represented without deleted_at, and is purged
+DELETE FROM media WHERE deleted_at IS NOT NULL;
+DELETE FROM season WHERE deleted_at IS NOT NULL;
+DELETE FROM series WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE media DROP COLUMN deleted_at;
+ALTER TABLE season DROP COLUMN deleted_at;
+ALTER TABLE series DROP COLUMN deleted_at;
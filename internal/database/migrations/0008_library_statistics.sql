@@ -0,0 +1,30 @@
+-- +goose Up
+
+-- Source file size/codec of media, populated during ingestion. Media ingested
+-- prior to this migration will report a size of zero and an unknown codec until
+-- it is re-ingested.
+ALTER TABLE media ADD COLUMN source_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE media ADD COLUMN video_codec TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE media_transcodes ADD COLUMN size BIGINT NOT NULL DEFAULT 0;
+
+-- History of all concluded transcode tasks, used to report transcode success/failure
+-- over time. Deliberately has no FK to the media/target tables, as the history
+-- should be retained even after the media/target is deleted.
+CREATE TABLE transcode_outcome(
+    id UUID NOT NULL PRIMARY KEY,
+    media_id UUID NOT NULL,
+    transcode_target_id UUID NOT NULL,
+    succeeded BOOLEAN NOT NULL,
+    concluded_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX transcode_outcome_idx_concluded_at ON transcode_outcome(concluded_at);
+
+-- +goose Down
+
+DROP TABLE transcode_outcome;
+
+ALTER TABLE media_transcodes DROP COLUMN size;
+ALTER TABLE media DROP COLUMN video_codec;
+ALTER TABLE media DROP COLUMN source_size;
@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Tail of the ffmpeg output of each concluded transcode task, allowing
+-- failures to be debugged without access to the server logs.
+ALTER TABLE transcode_outcome ADD COLUMN output TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+
+ALTER TABLE transcode_outcome DROP COLUMN output;
@@ -0,0 +1,24 @@
+-- +goose Up
+
+-- Additional source files for a movie or episode. The primary source of the media
+-- remains on the media row; files which are kept alongside it (e.g. when a duplicate
+-- is ingested and the duplicate policy keeps both) are stored here.
+CREATE TABLE media_versions(
+    id UUID NOT NULL PRIMARY KEY,
+    media_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    source_path TEXT NOT NULL,
+    source_size BIGINT NOT NULL,
+    video_codec TEXT NOT NULL,
+    frame_width INT NOT NULL,
+    frame_height INT NOT NULL,
+
+    CONSTRAINT media_versions_uk_source_path UNIQUE(source_path),
+    CONSTRAINT media_versions_fk_media_id FOREIGN KEY(media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+CREATE INDEX media_versions_idx_media_id ON media_versions(media_id);
+
+-- +goose Down
+
+DROP TABLE media_versions;
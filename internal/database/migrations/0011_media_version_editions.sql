@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- Versions are labelled with the edition they represent (e.g. 'Director's Cut' or '4K Remux').
+ALTER TABLE media_versions ADD COLUMN label TEXT NOT NULL DEFAULT '';
+
+-- Transcodes (and queued transcodes) may be of a specific version of the media,
+-- rather than it's primary source. A NULL version_id indicates the primary source.
+ALTER TABLE media_transcodes ADD COLUMN version_id UUID;
+ALTER TABLE media_transcodes ADD CONSTRAINT media_transcodes_fk_version_id FOREIGN KEY(version_id) REFERENCES media_versions(id) ON DELETE RESTRICT;
+
+ALTER TABLE transcode_queue_snapshot ADD COLUMN version_id UUID;
+ALTER TABLE transcode_queue_snapshot ADD CONSTRAINT transcode_queue_snapshot_fk_version_id FOREIGN KEY(version_id) REFERENCES media_versions(id) ON DELETE CASCADE;
+ALTER TABLE transcode_queue_snapshot DROP CONSTRAINT transcode_queue_snapshot_media_id_transcode_target_id_key;
+CREATE UNIQUE INDEX transcode_queue_snapshot_uk_media_target_version ON transcode_queue_snapshot(media_id, transcode_target_id, COALESCE(version_id, media_id));
+
+-- +goose Down
+
+-- Transcodes of versions cannot be distinguished from transcodes of the primary
+-- source without version_id, and are removed (their files are left on disk)
+DELETE FROM transcode_queue_snapshot WHERE version_id IS NOT NULL;
+DELETE FROM media_transcodes WHERE version_id IS NOT NULL;
+
+DROP INDEX transcode_queue_snapshot_uk_media_target_version;
+ALTER TABLE transcode_queue_snapshot ADD CONSTRAINT transcode_queue_snapshot_media_id_transcode_target_id_key UNIQUE(media_id, transcode_target_id);
+ALTER TABLE transcode_queue_snapshot DROP COLUMN version_id;
+ALTER TABLE media_transcodes DROP COLUMN version_id;
+
+ALTER TABLE media_versions DROP COLUMN label;
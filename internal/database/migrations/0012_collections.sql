@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- Collections are ordered groups of movies and/or episodes. A collection is either curated
+-- by a user, or created automatically from the TMDB collection (e.g. 'The Matrix Collection')
+-- of an ingested movie, in which case the TMDB ID of the collection is stored.
+CREATE TABLE collection(
+    id UUID NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    tmdb_id TEXT,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+
+    CONSTRAINT collection_uk_tmdb_id UNIQUE(tmdb_id)
+);
+
+CREATE TABLE collection_media(
+    collection_id UUID NOT NULL,
+    media_id UUID NOT NULL,
+    position INT NOT NULL,
+
+    CONSTRAINT collection_media_pk PRIMARY KEY(collection_id, media_id),
+    CONSTRAINT collection_media_fk_collection_id FOREIGN KEY(collection_id) REFERENCES collection(id) ON DELETE CASCADE,
+    CONSTRAINT collection_media_fk_media_id FOREIGN KEY(media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+CREATE INDEX collection_media_idx_media_id ON collection_media(media_id);
+
+-- +goose Down
+
+DROP TABLE collection_media;
+DROP TABLE collection;
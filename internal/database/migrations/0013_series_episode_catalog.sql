@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- The episodes of each series which are known to TMDB, refreshed periodically. Episodes
+-- in this catalog which are not present in the library are reported as missing.
+CREATE TABLE series_episode_catalog(
+    series_id UUID NOT NULL,
+    season_number INT NOT NULL,
+    episode_number INT NOT NULL,
+    title TEXT NOT NULL,
+    air_date DATE,
+
+    CONSTRAINT series_episode_catalog_pk PRIMARY KEY(series_id, season_number, episode_number),
+    CONSTRAINT series_episode_catalog_fk_series_id FOREIGN KEY(series_id) REFERENCES series(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+
+DROP TABLE series_episode_catalog;
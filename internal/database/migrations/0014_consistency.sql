@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- Media and transcodes whose file could not be found on disk during the last
+-- consistency check. A NULL degraded_at indicates the file was present (or that
+-- no check has run yet), and is reset if the file is later found again.
+ALTER TABLE media ADD COLUMN degraded_at TIMESTAMPTZ;
+ALTER TABLE media_transcodes ADD COLUMN degraded_at TIMESTAMPTZ;
+
+-- +goose Down
+
+ALTER TABLE media_transcodes DROP COLUMN degraded_at;
+ALTER TABLE media DROP COLUMN degraded_at;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- SHA-256 checksums of media sources (recorded at ingest) and transcode outputs (recorded
+-- on completion). A non-NULL corrupted_at indicates that the file no longer matched it's
+-- checksum when last verified, and is reset if a later verification succeeds.
+ALTER TABLE media ADD COLUMN checksum TEXT;
+ALTER TABLE media ADD COLUMN corrupted_at TIMESTAMPTZ;
+ALTER TABLE media_transcodes ADD COLUMN checksum TEXT;
+ALTER TABLE media_transcodes ADD COLUMN corrupted_at TIMESTAMPTZ;
+
+-- +goose Down
+
+ALTER TABLE media_transcodes DROP COLUMN corrupted_at;
+ALTER TABLE media_transcodes DROP COLUMN checksum;
+ALTER TABLE media DROP COLUMN corrupted_at;
+ALTER TABLE media DROP COLUMN checksum;
@@ -0,0 +1,41 @@
+-- +goose Up
+
+-- The playback position of each movie/episode a user has started watching, as reported by their
+-- client. Media is completed once the user has watched it to the end (at which point the position
+-- is no longer relevant). Progress is scrobbled to the Trakt account of the user (if linked), and
+-- the watched history of their Trakt account is imported here when it is linked.
+CREATE TABLE watch_progress(
+    user_id UUID NOT NULL,
+    media_id UUID NOT NULL,
+    position_seconds INT NOT NULL CHECK (position_seconds >= 0),
+    completed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+
+    CONSTRAINT watch_progress_pk PRIMARY KEY(user_id, media_id),
+    CONSTRAINT watch_progress_fk_user_id FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT watch_progress_fk_media_id FOREIGN KEY(media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+CREATE INDEX watch_progress_idx_user_id_updated_at ON watch_progress(user_id, updated_at DESC);
+
+-- The Trakt account linked to each user, used to scrobble their playback progress to Trakt. Tokens are
+-- encrypted using the configured credential key, and are never returned by the API. The outcome of the
+-- import of the watched history of the account (performed when the account is linked) is recorded
+-- alongside the account.
+CREATE TABLE trakt_account(
+    user_id UUID NOT NULL,
+    encrypted_access_token BYTEA NOT NULL,
+    encrypted_refresh_token BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    linked_at TIMESTAMPTZ NOT NULL,
+    synced_at TIMESTAMPTZ,
+    last_error TEXT,
+
+    CONSTRAINT trakt_account_pk PRIMARY KEY(user_id),
+    CONSTRAINT trakt_account_fk_user_id FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+
+DROP TABLE trakt_account;
+DROP TABLE watch_progress;
@@ -0,0 +1,29 @@
+-- +goose Up
+
+-- Extends the outcomes of concluded transcode tasks in to a history of the tasks Thea has
+-- processed. Cancelled tasks are now also recorded, so the boolean 'succeeded' column is
+-- replaced with a status. The start time, output size and error are NULL for outcomes
+-- recorded prior to this migration (and where not applicable to the outcome).
+ALTER TABLE transcode_outcome ADD COLUMN status TEXT;
+UPDATE transcode_outcome SET status = CASE WHEN succeeded THEN 'COMPLETE' ELSE 'FAILED' END;
+ALTER TABLE transcode_outcome ALTER COLUMN status SET NOT NULL;
+ALTER TABLE transcode_outcome DROP COLUMN succeeded;
+
+ALTER TABLE transcode_outcome ADD COLUMN version_id UUID;
+ALTER TABLE transcode_outcome ADD COLUMN started_at TIMESTAMPTZ;
+ALTER TABLE transcode_outcome ADD COLUMN output_size BIGINT;
+ALTER TABLE transcode_outcome ADD COLUMN error TEXT;
+
+-- +goose Down
+
+ALTER TABLE transcode_outcome DROP COLUMN error;
+ALTER TABLE transcode_outcome DROP COLUMN output_size;
+ALTER TABLE transcode_outcome DROP COLUMN started_at;
+ALTER TABLE transcode_outcome DROP COLUMN version_id;
+
+-- Cancelled tasks were not recorded prior to this migration
+DELETE FROM transcode_outcome WHERE status = 'CANCELLED';
+ALTER TABLE transcode_outcome ADD COLUMN succeeded BOOLEAN;
+UPDATE transcode_outcome SET succeeded = (status = 'COMPLETE');
+ALTER TABLE transcode_outcome ALTER COLUMN succeeded SET NOT NULL;
+ALTER TABLE transcode_outcome DROP COLUMN status;
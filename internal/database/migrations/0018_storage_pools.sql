@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- The name of the storage pool each transcode output was written to. Transcodes
+-- completed prior to storage pools were written to the default output directory.
+ALTER TABLE media_transcodes ADD COLUMN pool TEXT NOT NULL DEFAULT 'default';
+
+-- +goose Down
+
+ALTER TABLE media_transcodes DROP COLUMN pool;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Passthrough transcodes record the source of a media as the transcode of a target, where
+-- the source already satisfied the target. The path of these transcodes is the source itself.
+ALTER TABLE media_transcodes ADD COLUMN passthrough BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+
+DELETE FROM media_transcodes WHERE passthrough;
+ALTER TABLE media_transcodes DROP COLUMN passthrough;
@@ -0,0 +1,40 @@
+-- +goose Up
+
+-- Remote sources are SFTP servers, SMB shares and WebDAV servers which are periodically listed for
+-- new files, which are fetched in to the ingest directory. Passwords are encrypted using the configured
+-- credential key, and are never returned by the API.
+CREATE TABLE remote_source(
+    id UUID NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    label TEXT NOT NULL,
+    protocol TEXT NOT NULL,
+    url TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    encrypted_password BYTEA,
+    host_key_fingerprint TEXT NOT NULL DEFAULT '',
+    sync_interval_seconds INT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_synced_at TIMESTAMPTZ,
+    last_error TEXT,
+
+    CONSTRAINT remote_source_uk_label UNIQUE(label)
+);
+
+-- The files which have been fetched from each remote source, so that only new (or
+-- modified) files are fetched when the source is next synced.
+CREATE TABLE remote_source_file(
+    source_id UUID NOT NULL,
+    path TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    modified_at TIMESTAMPTZ NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL,
+
+    CONSTRAINT remote_source_file_pk PRIMARY KEY(source_id, path),
+    CONSTRAINT remote_source_file_fk_source_id FOREIGN KEY(source_id) REFERENCES remote_source(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+
+DROP TABLE remote_source_file;
+DROP TABLE remote_source;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- Locked fields are the metadata fields (e.g. 'title') of a movie, episode, season or series which
+-- have been manually corrected, and must not be overwritten when the item is re-ingested/refreshed.
+ALTER TABLE media ADD COLUMN locked_fields TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE season ADD COLUMN locked_fields TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE series ADD COLUMN locked_fields TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+
+ALTER TABLE series DROP COLUMN locked_fields;
+ALTER TABLE season DROP COLUMN locked_fields;
+ALTER TABLE media DROP COLUMN locked_fields;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- The overview (synopsis) of each movie, episode, season and series, as provided by TMDB
+-- or manually corrected. Existing rows are populated the next time they're ingested.
+ALTER TABLE media ADD COLUMN overview TEXT NOT NULL DEFAULT '';
+ALTER TABLE season ADD COLUMN overview TEXT NOT NULL DEFAULT '';
+ALTER TABLE series ADD COLUMN overview TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+
+ALTER TABLE series DROP COLUMN overview;
+ALTER TABLE season DROP COLUMN overview;
+ALTER TABLE media DROP COLUMN overview;
@@ -0,0 +1,40 @@
+-- +goose Up
+
+-- Tags are user-defined labels (e.g. 'kids' or 'anime') which can be attached to movies and
+-- series, independently of the genres provided by TMDB. Tags can be used to filter media, and
+-- by workflow criteria to route tagged content to specific transcode targets.
+CREATE TABLE tag(
+    id UUID NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    label TEXT NOT NULL,
+
+    CONSTRAINT tag_uk_label UNIQUE(label)
+);
+
+CREATE TABLE movie_tags(
+    movie_id UUID NOT NULL,
+    tag_id UUID NOT NULL,
+
+    CONSTRAINT movie_tags_pk PRIMARY KEY(movie_id, tag_id),
+    CONSTRAINT movie_tags_fk_movie_id FOREIGN KEY(movie_id) REFERENCES media(id) ON DELETE CASCADE,
+    CONSTRAINT movie_tags_fk_tag_id FOREIGN KEY(tag_id) REFERENCES tag(id) ON DELETE CASCADE
+);
+
+CREATE TABLE series_tags(
+    series_id UUID NOT NULL,
+    tag_id UUID NOT NULL,
+
+    CONSTRAINT series_tags_pk PRIMARY KEY(series_id, tag_id),
+    CONSTRAINT series_tags_fk_series_id FOREIGN KEY(series_id) REFERENCES series(id) ON DELETE CASCADE,
+    CONSTRAINT series_tags_fk_tag_id FOREIGN KEY(tag_id) REFERENCES tag(id) ON DELETE CASCADE
+);
+
+CREATE INDEX movie_tags_idx_tag_id ON movie_tags(tag_id);
+CREATE INDEX series_tags_idx_tag_id ON series_tags(tag_id);
+
+-- +goose Down
+
+DROP TABLE series_tags;
+DROP TABLE movie_tags;
+DROP TABLE tag;
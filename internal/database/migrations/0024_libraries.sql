@@ -0,0 +1,41 @@
+-- +goose Up
+
+-- Libraries partition media (e.g. 'Kids', 'Anime' and 'Main'). Each library is bound to a directory
+-- within the ingest directory, and media ingested from beneath it is assigned to the library. The output
+-- directory (relative to the configured transcode output directory) is where transcodes of the library's
+-- media are placed.
+CREATE TABLE library(
+    id UUID NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    label TEXT NOT NULL,
+    ingest_path TEXT NOT NULL,
+    output_directory TEXT NOT NULL DEFAULT '',
+
+    CONSTRAINT library_uk_label UNIQUE(label),
+    CONSTRAINT library_uk_ingest_path UNIQUE(ingest_path)
+);
+
+-- Users may only access the media within the libraries they've been granted access to (unless they have
+-- the permission to access all libraries). Media which does not belong to a library is visible to all users.
+CREATE TABLE user_libraries(
+    user_id UUID NOT NULL,
+    library_id UUID NOT NULL,
+
+    CONSTRAINT user_libraries_pk PRIMARY KEY(user_id, library_id),
+    CONSTRAINT user_libraries_fk_user_id FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT user_libraries_fk_library_id FOREIGN KEY(library_id) REFERENCES library(id) ON DELETE CASCADE
+);
+
+ALTER TABLE media ADD COLUMN library_id UUID REFERENCES library(id) ON DELETE SET NULL;
+ALTER TABLE series ADD COLUMN library_id UUID REFERENCES library(id) ON DELETE SET NULL;
+
+CREATE INDEX media_idx_library_id ON media(library_id);
+CREATE INDEX series_idx_library_id ON series(library_id);
+
+-- +goose Down
+
+ALTER TABLE series DROP COLUMN library_id;
+ALTER TABLE media DROP COLUMN library_id;
+DROP TABLE user_libraries;
+DROP TABLE library;
@@ -0,0 +1,31 @@
+-- +goose Up
+
+-- Quotas cap the number of manual transcode tasks a user may have active at once, and the
+-- number they may request within a rolling day. A NULL limit is unlimited, and users
+-- without a quota row are not limited at all.
+CREATE TABLE user_quota(
+    user_id UUID NOT NULL PRIMARY KEY,
+    updated_at TIMESTAMPTZ NOT NULL,
+    max_concurrent_transcodes INT,
+    max_daily_transcodes INT,
+
+    CONSTRAINT user_quota_fk_user_id FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT user_quota_ck_limits CHECK(max_concurrent_transcodes >= 0 AND max_daily_transcodes >= 0)
+);
+
+-- The manual transcode tasks requested by each user, used to count the requests made
+-- within the daily window. Rows older than the window are pruned as new requests are recorded.
+CREATE TABLE user_transcode_request(
+    task_id UUID NOT NULL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+
+    CONSTRAINT user_transcode_request_fk_user_id FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX user_transcode_request_idx_user_id_requested_at ON user_transcode_request(user_id, requested_at);
+
+-- +goose Down
+
+DROP TABLE user_transcode_request;
+DROP TABLE user_quota;
@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- The targets of a workflow are ordered, so that quick targets can be queued ahead of slow ones. Existing
+-- targets have no meaningful order, and so are all given the same position.
+ALTER TABLE workflow_transcode_targets ADD COLUMN position INT NOT NULL DEFAULT 0;
+
+-- Sequential workflows queue the task for each target of a media only once the task for
+-- the prior target has finished, rather than queueing all the targets at once.
+ALTER TABLE workflow ADD COLUMN sequential BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+
+ALTER TABLE workflow DROP COLUMN sequential;
+ALTER TABLE workflow_transcode_targets DROP COLUMN position;
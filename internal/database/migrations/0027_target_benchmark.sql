@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- The encoding throughput measured for each target on this host, used to estimate the threads a transcode
+-- using the target consumes. The benchmark is discarded whenever the ffmpeg options of the target change.
+ALTER TABLE transcode_target ADD COLUMN benchmark JSONB;
+
+-- +goose Down
+
+ALTER TABLE transcode_target DROP COLUMN benchmark;
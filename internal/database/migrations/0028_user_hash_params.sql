@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- The Argon2id parameters each password was hashed with, so that the parameters can be tuned without
+-- invalidating existing passwords. Existing hashes (NULL) were generated using the parameters Thea used
+-- before they were configurable, and are re-hashed using the configured parameters on the next login.
+ALTER TABLE users ADD COLUMN hash_params JSONB;
+
+-- +goose Down
+
+-- NB: Passwords re-hashed since this migration was applied can no longer be verified, and must be reset.
+ALTER TABLE users DROP COLUMN hash_params;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- Time-limited links which grant access to stream a single movie/episode (either the source, or the
+-- transcode for a specific target) without an account. The token of a link is not persisted, as it's
+-- signed by Thea. Password-protected links store the hash of the password, the same as users.
+CREATE TABLE share_links(
+    id UUID NOT NULL PRIMARY KEY,
+    media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    target_id UUID REFERENCES transcode_target(id) ON DELETE CASCADE,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    password BYTEA,
+    salt BYTEA,
+    hash_params JSONB
+);
+
+CREATE INDEX share_links_idx_created_by ON share_links(created_by);
+
+-- +goose Down
+
+DROP TABLE share_links;
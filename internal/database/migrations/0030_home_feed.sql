@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- The recently added section of the home feed lists the most recently created media.
+CREATE INDEX media_idx_created_at ON media(created_at DESC) WHERE deleted_at IS NULL;
+
+-- +goose Down
+
+DROP INDEX media_idx_created_at;
@@ -0,0 +1,29 @@
+-- +goose Up
+
+-- The TMDB IDs of the content TMDB considers similar to (or recommends alongside) each
+-- movie/series, replaced each time the movie/series is ingested. The IDs are cross-referenced
+-- against the library when read, so related content ingested later is picked up automatically.
+CREATE TABLE movie_related(
+    movie_id UUID NOT NULL,
+    related_tmdb_id TEXT NOT NULL,
+    relation TEXT NOT NULL CHECK (relation IN ('recommended', 'similar')),
+    rank INT NOT NULL,
+
+    CONSTRAINT movie_related_pk PRIMARY KEY(movie_id, relation, related_tmdb_id),
+    CONSTRAINT movie_related_fk_movie_id FOREIGN KEY(movie_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+CREATE TABLE series_related(
+    series_id UUID NOT NULL,
+    related_tmdb_id TEXT NOT NULL,
+    relation TEXT NOT NULL CHECK (relation IN ('recommended', 'similar')),
+    rank INT NOT NULL,
+
+    CONSTRAINT series_related_pk PRIMARY KEY(series_id, relation, related_tmdb_id),
+    CONSTRAINT series_related_fk_series_id FOREIGN KEY(series_id) REFERENCES series(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+
+DROP TABLE series_related;
+DROP TABLE movie_related;
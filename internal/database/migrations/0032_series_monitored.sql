@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Monitored series have the episodes missing from the library prioritized when they're
+-- found by the ingest service. Series are monitored by default, as they were ingested
+-- because the user wanted them.
+ALTER TABLE series ADD COLUMN monitored BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- +goose Down
+
+ALTER TABLE series DROP COLUMN monitored;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Hooks are the user-provided commands executed, in order, once each transcode
+-- queued by the workflow completes. Each hook is an object containing the
+-- command, it's arguments and (optionally) a timeout.
+ALTER TABLE workflow ADD COLUMN hooks JSONB NOT NULL DEFAULT '[]';
+
+-- The workflow which queued each snapshotted task is retained, so that the
+-- hooks of the workflow are still executed for tasks restored from the snapshot.
+ALTER TABLE transcode_queue_snapshot ADD COLUMN workflow_id UUID;
+ALTER TABLE transcode_queue_snapshot ADD CONSTRAINT transcode_queue_snapshot_fk_workflow_id FOREIGN KEY(workflow_id) REFERENCES workflow(id) ON DELETE SET NULL;
+
+-- +goose Down
+
+ALTER TABLE transcode_queue_snapshot DROP COLUMN workflow_id;
+ALTER TABLE workflow DROP COLUMN hooks;
@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Remux-only targets copy the streams of the source in to the container of
+-- the target without re-encoding them, ignoring their ffmpeg options.
+ALTER TABLE transcode_target ADD COLUMN remux_only BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+
+ALTER TABLE transcode_target DROP COLUMN remux_only;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- The ISO 639-2 codes of the audio languages preferred by each target, from
+-- most to least preferred. The audio stream which best satisfies these
+-- preferences is the only audio stream transcoded using the target.
+ALTER TABLE transcode_target ADD COLUMN audio_languages TEXT[] NOT NULL DEFAULT '{}';
+
+-- The audio stream of the source selected using the audio language preferences
+-- of the target, if any, is recorded against each transcode.
+ALTER TABLE media_transcodes ADD COLUMN audio_stream_index INT;
+ALTER TABLE media_transcodes ADD COLUMN audio_language TEXT;
+
+-- +goose Down
+
+ALTER TABLE media_transcodes DROP COLUMN audio_language;
+ALTER TABLE media_transcodes DROP COLUMN audio_stream_index;
+ALTER TABLE transcode_target DROP COLUMN audio_languages;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- The default playback preferences of each user. A NULL language or bitrate indicates the user
+-- has no preference, and users without a preferences row are given the default preferences.
+CREATE TABLE user_preferences(
+    user_id UUID NOT NULL PRIMARY KEY,
+    updated_at TIMESTAMPTZ NOT NULL,
+    audio_language TEXT,
+    subtitle_language TEXT,
+    max_bitrate_kbps INT,
+    autoplay_next_episode BOOLEAN NOT NULL DEFAULT TRUE,
+
+    CONSTRAINT user_preferences_fk_user_id FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT user_preferences_ck_max_bitrate_kbps CHECK(max_bitrate_kbps > 0)
+);
+
+-- +goose Down
+
+DROP TABLE user_preferences;
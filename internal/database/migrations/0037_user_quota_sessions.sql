@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Caps the number of playback (live-stream) sessions a user may have at once. A NULL limit is unlimited.
+ALTER TABLE user_quota ADD COLUMN max_concurrent_sessions INT;
+ALTER TABLE user_quota ADD CONSTRAINT user_quota_ck_sessions CHECK(max_concurrent_sessions >= 0);
+
+-- +goose Down
+
+ALTER TABLE user_quota DROP CONSTRAINT user_quota_ck_sessions;
+ALTER TABLE user_quota DROP COLUMN max_concurrent_sessions;
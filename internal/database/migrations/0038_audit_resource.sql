@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- The ID of the resource affected by the audited action (e.g. the movie deleted, or the role created), if any.
+ALTER TABLE audit_log ADD COLUMN resource_id UUID;
+CREATE INDEX audit_log_idx_resource_id ON audit_log(resource_id);
+
+-- +goose Down
+
+DROP INDEX audit_log_idx_resource_id;
+ALTER TABLE audit_log DROP COLUMN resource_id;
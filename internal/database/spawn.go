@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
@@ -19,6 +20,26 @@ type DatabaseConfig struct {
 	Name     string `toml:"name" env:"DB_NAME" env-default:"THEA_DB"`
 	Host     string `toml:"host" env:"DB_HOST" env-default:"0.0.0.0"`
 	Port     string `toml:"port" env:"DB_PORT" env-default:"5432"`
+
+	// MaxOpenConnections and MaxIdleConnections limit the size of the connection pool. A zero
+	// value for either uses the default of the database/sql package (unlimited open, 2 idle).
+	MaxOpenConnections int `toml:"max_open_connections" env:"DB_MAX_OPEN_CONNECTIONS" env-default:"25"`
+	MaxIdleConnections int `toml:"max_idle_connections" env:"DB_MAX_IDLE_CONNECTIONS" env-default:"5"`
+
+	// ConnectionMaxLifetime and ConnectionMaxIdleTime are how long a connection of the pool
+	// may be used for, and remain idle for, before it is closed. Zero values disable the limits.
+	ConnectionMaxLifetime time.Duration `toml:"connection_max_lifetime" env:"DB_CONNECTION_MAX_LIFETIME" env-default:"30m"`
+	ConnectionMaxIdleTime time.Duration `toml:"connection_max_idle_time" env:"DB_CONNECTION_MAX_IDLE_TIME" env-default:"5m"`
+
+	// SlowQueryThreshold is the duration above which queries are logged as slow, along
+	// with the query itself. A zero value disables the logging of slow queries.
+	SlowQueryThreshold time.Duration `toml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD" env-default:"500ms"`
+}
+
+// DSN returns the connection string used to connect to the
+// database described by this configuration.
+func (config DatabaseConfig) DSN() string {
+	return fmt.Sprintf(SQLConnectionString, config.Host, config.User, config.Password, config.Name, config.Port)
 }
 
 func InitialiseDockerDatabase(dockerManager docker.DockerManager, config DatabaseConfig, crashHandler func(error)) (docker.DockerContainer, error) {
@@ -0,0 +1,316 @@
+// Package deletion performs bulk deletion of the movies and episodes matching a filter (e.g. all media
+// older than a year, or below 720p). Deletion happens in two steps: a preview is created, listing the
+// media matched and the disk space which would be reclaimed, and the preview is then executed as a
+// background job. Only the media listed in the preview is deleted, even if the media matching the
+// filter has since changed.
+package deletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var (
+	log = logger.Get("Deletion")
+
+	ErrJobNotFound    = errors.New("bulk deletion not found")
+	ErrJobNotPreview  = errors.New("bulk deletion has already been executed")
+	ErrPreviewExpired = errors.New("bulk deletion preview has expired, a new preview must be created")
+)
+
+// previewLifetime is how long a preview may be executed for after it's created. Previews which
+// expire are discarded, as the media they list may no longer match the filter.
+const previewLifetime = 15 * time.Minute
+
+const (
+	Preview   JobState = "PREVIEW"
+	Queued    JobState = "QUEUED"
+	Running   JobState = "RUNNING"
+	Complete  JobState = "COMPLETE"
+	Cancelled JobState = "CANCELLED"
+)
+
+type (
+	Store interface {
+		ListDeletionCandidates(filter media.DeletionFilter) ([]*media.DeletionCandidate, error)
+		GetTranscodesForMedias(mediaIDs []uuid.UUID) ([]*transcode.Transcode, error)
+		PurgeMedia(mediaIDs []uuid.UUID) error
+	}
+
+	JobState string
+
+	// Item is a movie or episode which is deleted by a job, along with the transcodes of the media. The
+	// transcode size excludes passthrough transcodes, as their output is the source of the media.
+	Item struct {
+		*media.DeletionCandidate
+		TranscodeCount int
+		TranscodeSize  int64
+	}
+
+	// Job is a bulk deletion of the items matched by a filter. Jobs are not persisted.
+	Job struct {
+		ID            uuid.UUID
+		CreatedAt     time.Time
+		Filter        media.DeletionFilter
+		DeleteSources bool
+		Items         []*Item
+
+		state       JobState
+		startedAt   *time.Time
+		completedAt *time.Time
+		deleted     int
+		failed      int
+		reclaimed   int64
+	}
+
+	// JobProgress is the state of a job, and the number of it's items which have been deleted (or
+	// failed to be deleted). ReclaimedBytes is the size of the files which have been removed.
+	JobProgress struct {
+		State          JobState
+		StartedAt      *time.Time
+		CompletedAt    *time.Time
+		Deleted        int
+		Failed         int
+		ReclaimedBytes int64
+	}
+
+	// Service creates previews of bulk deletions, and executes them in the background. Only
+	// one job is executed at a time, in the order they were executed.
+	Service struct {
+		*sync.Mutex
+		store Store
+		jobs  []*Job
+		wake  chan struct{}
+	}
+)
+
+func New(store Store) *Service {
+	return &Service{Mutex: &sync.Mutex{}, store: store, jobs: make([]*Job, 0), wake: make(chan struct{}, 1)}
+}
+
+func (service *Service) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-service.wake:
+			for job := service.nextQueued(); job != nil; job = service.nextQueued() {
+				service.run(ctx, job)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Size returns the size of the files which are removed when the item is deleted.
+func (item *Item) Size(deleteSources bool) int64 {
+	if deleteSources {
+		return item.TranscodeSize + item.SourceSize + item.VersionSize
+	}
+
+	return item.TranscodeSize
+}
+
+// ReclaimableBytes returns the size of the files which are removed when the job is executed.
+func (job *Job) ReclaimableBytes() int64 {
+	var total int64
+	for _, item := range job.Items {
+		total += item.Size(job.DeleteSources)
+	}
+
+	return total
+}
+
+func (job *Job) ExpiresAt() time.Time { return job.CreatedAt.Add(previewLifetime) }
+
+// CreatePreview lists the media matching the filter provided, and creates a job which will delete
+// the media (and it's transcodes) if executed. If deleteSources is true, then the source files of the
+// media are also removed. Previews which have expired without being executed are discarded.
+func (service *Service) CreatePreview(filter media.DeletionFilter, deleteSources bool) (*Job, error) {
+	candidates, err := service.store.ListDeletionCandidates(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaIDs := make([]uuid.UUID, len(candidates))
+	items := make([]*Item, len(candidates))
+	itemsByID := make(map[uuid.UUID]*Item, len(candidates))
+	for k, candidate := range candidates {
+		mediaIDs[k] = candidate.ID
+		items[k] = &Item{DeletionCandidate: candidate}
+		itemsByID[candidate.ID] = items[k]
+	}
+
+	if len(mediaIDs) > 0 {
+		transcodes, err := service.store.GetTranscodesForMedias(mediaIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch transcodes of matched media: %w", err)
+		}
+		for _, t := range transcodes {
+			if item, ok := itemsByID[t.MediaID]; ok {
+				item.TranscodeCount++
+				if !t.Passthrough {
+					item.TranscodeSize += t.Size
+				}
+			}
+		}
+	}
+
+	job := &Job{
+		ID:            uuid.New(),
+		CreatedAt:     time.Now(),
+		Filter:        filter,
+		DeleteSources: deleteSources,
+		Items:         items,
+		state:         Preview,
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	service.jobs = slices.DeleteFunc(service.jobs, func(j *Job) bool {
+		return j.state == Preview && time.Now().After(j.ExpiresAt())
+	})
+	service.jobs = append(service.jobs, job)
+
+	return job, nil
+}
+
+// Execute queues the preview with the ID provided for execution. The job returned
+// can be used to track the progress of the deletion (see Progress).
+func (service *Service) Execute(id uuid.UUID) (*Job, error) {
+	service.Lock()
+	defer service.Unlock()
+
+	job := service.job(id)
+	if job == nil {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	if job.state != Preview {
+		return nil, fmt.Errorf("%w: %s is %s", ErrJobNotPreview, id, job.state)
+	}
+	if time.Now().After(job.ExpiresAt()) {
+		return nil, ErrPreviewExpired
+	}
+
+	job.state = Queued
+	select {
+	case service.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// Job returns the job with the ID provided, or nil if no such job exists.
+func (service *Service) Job(id uuid.UUID) *Job {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.job(id)
+}
+
+// Jobs returns all jobs created since Thea started, excluding discarded previews.
+func (service *Service) Jobs() []*Job {
+	service.Lock()
+	defer service.Unlock()
+
+	return slices.Clone(service.jobs)
+}
+
+func (service *Service) Progress(job *Job) JobProgress {
+	service.Lock()
+	defer service.Unlock()
+
+	return JobProgress{
+		State:          job.state,
+		StartedAt:      job.startedAt,
+		CompletedAt:    job.completedAt,
+		Deleted:        job.deleted,
+		Failed:         job.failed,
+		ReclaimedBytes: job.reclaimed,
+	}
+}
+
+// Note: This function does not take ownership of the mutex.
+func (service *Service) job(id uuid.UUID) *Job {
+	idx := slices.IndexFunc(service.jobs, func(j *Job) bool { return j.ID == id })
+	if idx == -1 {
+		return nil
+	}
+
+	return service.jobs[idx]
+}
+
+func (service *Service) nextQueued() *Job {
+	service.Lock()
+	defer service.Unlock()
+
+	idx := slices.IndexFunc(service.jobs, func(j *Job) bool { return j.state == Queued })
+	if idx == -1 {
+		return nil
+	}
+
+	return service.jobs[idx]
+}
+
+// run deletes the items of the job one at a time, so that a failure to delete one item does not prevent
+// the others from being deleted. Source files are only removed once the media has been purged from the
+// database. If the context is cancelled, the remaining items are not deleted and the job is cancelled.
+func (service *Service) run(ctx context.Context, job *Job) {
+	service.Lock()
+	now := time.Now()
+	job.state = Running
+	job.startedAt = &now
+	service.Unlock()
+
+	log.Emit(logger.NEW, "Executing bulk deletion %s of %d movies/episodes (deleteSources=%v)\n", job.ID, len(job.Items), job.DeleteSources)
+	state := Complete
+	for _, item := range job.Items {
+		if ctx.Err() != nil {
+			state = Cancelled
+			break
+		}
+
+		err := service.store.PurgeMedia([]uuid.UUID{item.ID})
+		if err == nil && job.DeleteSources {
+			removeSources(item)
+		}
+
+		service.Lock()
+		if err != nil {
+			log.Warnf("Bulk deletion %s failed to delete media %s: %v\n", job.ID, item.ID, err)
+			job.failed++
+		} else {
+			job.deleted++
+			job.reclaimed += item.Size(job.DeleteSources)
+		}
+		service.Unlock()
+	}
+
+	service.Lock()
+	completedAt := time.Now()
+	job.state = state
+	job.completedAt = &completedAt
+	service.Unlock()
+
+	log.Emit(logger.SUCCESS, "Bulk deletion %s %s, deleted %d/%d movies/episodes\n", job.ID, state, job.deleted, len(job.Items))
+}
+
+// removeSources removes the source file of the item, and the sources of all it's versions. Failures
+// are logged, as the media has already been deleted and so the removal cannot be retried.
+func removeSources(item *Item) {
+	for _, path := range append([]string{item.SourcePath}, item.VersionPaths...) {
+		if err := os.Remove(path); err != nil {
+			log.Warnf("Failed to remove source file '%s' of deleted media %s: %v\n", path, item.ID, err)
+		}
+	}
+}
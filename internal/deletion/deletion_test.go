@@ -0,0 +1,97 @@
+package deletion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	candidates []*media.DeletionCandidate
+	transcodes []*transcode.Transcode
+	purged     chan uuid.UUID
+}
+
+func (store *fakeStore) ListDeletionCandidates(media.DeletionFilter) ([]*media.DeletionCandidate, error) {
+	return store.candidates, nil
+}
+
+func (store *fakeStore) GetTranscodesForMedias([]uuid.UUID) ([]*transcode.Transcode, error) {
+	return store.transcodes, nil
+}
+
+func (store *fakeStore) PurgeMedia(mediaIDs []uuid.UUID) error {
+	for _, id := range mediaIDs {
+		store.purged <- id
+	}
+
+	return nil
+}
+
+func Test_CreatePreview(t *testing.T) {
+	t.Parallel()
+	movie, episode := uuid.New(), uuid.New()
+	store := &fakeStore{
+		candidates: []*media.DeletionCandidate{
+			{ID: movie, Type: "movie", SourceSize: 1000, VersionSize: 500},
+			{ID: episode, Type: "episode", SourceSize: 200},
+		},
+		transcodes: []*transcode.Transcode{
+			{MediaID: movie, Size: 100},
+			{MediaID: movie, Size: 1000, Passthrough: true},
+			{MediaID: episode, Size: 20},
+		},
+	}
+
+	service := New(store)
+	job, err := service.CreatePreview(media.DeletionFilter{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.Items[0].TranscodeCount)
+	assert.Equal(t, int64(120), job.ReclaimableBytes(), "only non-passthrough transcodes are reclaimed when sources are kept")
+
+	job, err = service.CreatePreview(media.DeletionFilter{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1820), job.ReclaimableBytes())
+	assert.Equal(t, Preview, service.Progress(job).State)
+}
+
+func Test_Execute(t *testing.T) {
+	t.Parallel()
+	movie := uuid.New()
+	store := &fakeStore{
+		candidates: []*media.DeletionCandidate{{ID: movie, Type: "movie"}},
+		purged:     make(chan uuid.UUID, 1),
+	}
+
+	service := New(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.Run(ctx) //nolint:errcheck
+
+	_, err := service.Execute(uuid.New())
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	job, err := service.CreatePreview(media.DeletionFilter{}, false)
+	require.NoError(t, err)
+	_, err = service.Execute(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, movie, <-store.purged)
+
+	assert.Eventually(t, func() bool { return service.Progress(job).State == Complete }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, service.Progress(job).Deleted)
+
+	_, err = service.Execute(job.ID)
+	assert.ErrorIs(t, err, ErrJobNotPreview)
+
+	expired, err := service.CreatePreview(media.DeletionFilter{}, false)
+	require.NoError(t, err)
+	expired.CreatedAt = time.Now().Add(-previewLifetime - time.Minute)
+	_, err = service.Execute(expired.ID)
+	assert.ErrorIs(t, err, ErrPreviewExpired)
+}
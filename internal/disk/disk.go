@@ -0,0 +1,70 @@
+// Package disk provides helpers for inspecting the free space available on
+// the volumes Thea writes to, so that work which would fill a volume can be
+// refused before it is started.
+package disk
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const bytesPerMegabyte = 1024 * 1024
+
+// Usage describes the space on the volume which contains Path. Free is the total
+// free space on the volume, whereas Available is the free space available to
+// Thea (which excludes any space reserved for the root user).
+type Usage struct {
+	Path      string
+	Total     uint64
+	Free      uint64
+	Available uint64
+}
+
+// InsufficientSpaceError is returned by EnsureAvailable when the volume containing
+// Path does not have the required amount of space available.
+type InsufficientSpaceError struct {
+	Path      string
+	Available uint64
+	Required  uint64
+}
+
+func (err *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient disk space at '%s': %dMB available, %dMB required", err.Path, err.Available/bytesPerMegabyte, err.Required/bytesPerMegabyte)
+}
+
+// GetUsage returns the usage of the volume which contains the path provided.
+func GetUsage(path string) (Usage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return Usage{}, fmt.Errorf("failed to stat filesystem at '%s': %w", path, err)
+	}
+
+	blockSize := uint64(stat.Bsize) //nolint:gosec
+	return Usage{
+		Path:      path,
+		Total:     stat.Blocks * blockSize,
+		Free:      stat.Bfree * blockSize,
+		Available: stat.Bavail * blockSize,
+	}, nil
+}
+
+// EnsureAvailable returns an InsufficientSpaceError if the volume containing the path
+// provided has less than the required number of megabytes available. A requirement
+// of zero always succeeds without inspecting the volume.
+func EnsureAvailable(path string, requiredMB uint64) error {
+	if requiredMB == 0 {
+		return nil
+	}
+
+	usage, err := GetUsage(path)
+	if err != nil {
+		return err
+	}
+
+	required := requiredMB * bytesPerMegabyte
+	if usage.Available < required {
+		return &InsufficientSpaceError{Path: path, Available: usage.Available, Required: required}
+	}
+
+	return nil
+}
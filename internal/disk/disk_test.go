@@ -0,0 +1,25 @@
+package disk_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/disk"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EnsureAvailable(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, disk.EnsureAvailable(dir, 0))
+	assert.NoError(t, disk.EnsureAvailable(dir, 1))
+
+	err := disk.EnsureAvailable(dir, math.MaxUint64/(1024*1024))
+	var insufficientErr *disk.InsufficientSpaceError
+	if assert.True(t, errors.As(err, &insufficientErr)) {
+		assert.Equal(t, dir, insufficientErr.Path)
+	}
+
+	assert.Error(t, disk.EnsureAvailable("/path/which/does/not/exist", 1))
+}
@@ -3,8 +3,11 @@
 package event
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/pkg/logger"
@@ -42,10 +45,28 @@ type (
 		EventHandler
 	}
 
+	// DistributedEventCoordinator is an EventCoordinator which also relays the RemoteEvents
+	// to/from other Thea instances using a Transport. Events are only relayed while the
+	// coordinator is running.
+	DistributedEventCoordinator interface {
+		EventCoordinator
+		Run(ctx context.Context) error
+
+		// Running returns true while events are being relayed using the transport.
+		Running() bool
+	}
+
 	eventHandler struct {
 		sync.Mutex
 		fnHandlers   map[Event][]handlerMethod
 		chanHandlers map[Event][]HandlerChannel
+
+		// transport is nil unless this coordinator is distributed, in which case
+		// any RemoteEvents dispatched are queued in outbound to be published.
+		transport  Transport
+		instanceID uuid.UUID
+		outbound   chan RemoteEvent
+		running    atomic.Bool
 	}
 
 	handlerMethod struct {
@@ -58,26 +79,100 @@ const (
 	IngestUpdateEvent   Event = "ingest:update"
 	IngestCompleteEvent Event = "ingest:complete"
 
-	NewMediaEvent    Event = "media:new"
-	DeleteMediaEvent Event = "media:delete"
+	NewMediaEvent       Event = "media:new"
+	UpdateMediaEvent    Event = "media:update"
+	DeleteMediaEvent    Event = "media:delete"
+	DegradedMediaEvent  Event = "media:degraded"
+	CorruptedMediaEvent Event = "media:corrupted"
+
+	// WatchTargetReadyEvent is dispatched with the ID of a completed (or passthrough)
+	// transcode, once the target it was created for is watchable for the media.
+	WatchTargetReadyEvent Event = "media:watch_target:ready"
 
 	TranscodeUpdateEvent       Event = "transcode:task:update"
 	TranscodeCompleteEvent     Event = "transcode:task:complete"
 	TranscodeTaskProgressEvent Event = "transcode:task:update:progress"
 
 	WorkflowUpdateEvent Event = "workflow:update"
+	TargetUpdateEvent   Event = "target:update"
+
+	ConsistencyCheckCompleteEvent Event = "system:consistency:complete"
 
 	DownloadUpdateEvent   Event = "download:update"
 	DownloadCompleteEvent Event = "download:complete"
 	DownloadProgressEvent Event = "download:update:progress"
 )
 
+const outboundBufferSize = 1000
+
 func New() EventCoordinator {
+	return newEventHandler(nil)
+}
+
+// NewDistributed returns an event coordinator which, in addition to dispatching events in-process,
+// relays RemoteEvents to/from other Thea instances using the transport provided.
+func NewDistributed(transport Transport) DistributedEventCoordinator {
+	return newEventHandler(transport)
+}
+
+func newEventHandler(transport Transport) *eventHandler {
 	return &eventHandler{
 		Mutex:        sync.Mutex{},
 		fnHandlers:   make(map[Event][]handlerMethod),
 		chanHandlers: make(map[Event][]HandlerChannel),
+		transport:    transport,
+		instanceID:   uuid.New(),
+		outbound:     make(chan RemoteEvent, outboundBufferSize),
+	}
+}
+
+// Run publishes the RemoteEvents dispatched by this instance, and dispatches the RemoteEvents
+// received from other instances, until the context provided is cancelled. The transport is
+// closed when this method returns.
+func (handler *eventHandler) Run(ctx context.Context) error {
+	handler.running.Store(true)
+	defer func() {
+		handler.running.Store(false)
+		if err := handler.transport.Close(); err != nil {
+			log.Warnf("Failed to close event transport: %v\n", err)
+		}
+	}()
+
+	subscriptionErr := make(chan error, 1)
+	go func() { subscriptionErr <- handler.transport.Subscribe(ctx, handler.receive) }()
+
+	log.Emit(logger.NEW, "Relaying events using transport (instance %s)\n", handler.instanceID)
+	for {
+		select {
+		case ev := <-handler.outbound:
+			if err := handler.transport.Publish(ctx, ev); err != nil {
+				log.Warnf("Failed to publish %s event to transport: %v\n", ev.Event, err)
+			}
+		case err := <-subscriptionErr:
+			if err != nil {
+				return fmt.Errorf("event transport subscription failed: %w", err)
+			}
+
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (handler *eventHandler) Running() bool {
+	return handler.running.Load()
+}
+
+// receive dispatches the event received from the transport to the handlers of this
+// instance. Events published by this instance are ignored, as they have already been
+// dispatched locally.
+func (handler *eventHandler) receive(ev RemoteEvent) {
+	if ev.Origin == handler.instanceID || !slices.Contains(RemoteEvents, ev.Event) {
+		return
 	}
+
+	handler.dispatchLocal(ev.Event, ev.Payload)
 }
 
 // RegisterHandlerChannel takes an event type and a channel and will send Event messages on
@@ -121,7 +216,8 @@ func (handler *eventHandler) registerHandlerMethod(event Event, handle handlerMe
 }
 
 // Handle takes an event type and a payload and dispatches the payload to the handler specified
-// for the event type provided.
+// for the event type provided. If this coordinator is distributed, RemoteEvents are also
+// queued to be published to the other Thea instances.
 // Note that this method WILL block if a synchronous handler function is blocking, or if channel
 // handlers are blocked.
 func (handler *eventHandler) Dispatch(event Event, payload Payload) {
@@ -130,6 +226,18 @@ func (handler *eventHandler) Dispatch(event Event, payload Payload) {
 		return
 	}
 
+	handler.dispatchLocal(event, payload)
+	if handler.transport != nil && slices.Contains(RemoteEvents, event) {
+		select {
+		case handler.outbound <- RemoteEvent{Origin: handler.instanceID, Event: event, Payload: payload.(uuid.UUID)}:
+		default:
+			log.Warnf("Outbound event buffer is full, %s event will not be published to other instances\n", event)
+		}
+	}
+}
+
+// dispatchLocal sends the event to the handlers registered with this coordinator.
+func (handler *eventHandler) dispatchLocal(event Event, payload Payload) {
 	if handles, ok := handler.fnHandlers[event]; ok {
 		for _, handle := range handles {
 			if handle.async {
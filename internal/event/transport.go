@@ -0,0 +1,77 @@
+package event
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// Transport relays events between Thea instances, allowing (for example) API replicas
+	// to observe the ingests/transcodes being performed by dedicated worker instances.
+	Transport interface {
+		Publish(ctx context.Context, event RemoteEvent) error
+
+		// Subscribe calls the handler provided for every event received by the transport. This
+		// method blocks until the context provided is cancelled, or the subscription fails.
+		Subscribe(ctx context.Context, handler func(RemoteEvent)) error
+
+		Close() error
+	}
+
+	// RemoteEvent is the form in which events are sent over a Transport. The origin
+	// is the ID of the instance which dispatched the event, which is used by instances
+	// to ignore the events they published themselves.
+	RemoteEvent struct {
+		Origin  uuid.UUID `json:"origin"`
+		Event   Event     `json:"event"`
+		Payload uuid.UUID `json:"payload"`
+	}
+
+	TransportBackend string
+
+	// TransportConfig controls which (if any) transport is used to relay events
+	// between Thea instances. By default, events are only dispatched in-process.
+	TransportConfig struct {
+		Backend TransportBackend `toml:"backend" env:"EVENT_TRANSPORT" env-default:"local"`
+
+		// Channel is the NATS subject, or Redis stream key, which events are published to.
+		Channel      string `toml:"channel" env:"EVENT_TRANSPORT_CHANNEL" env-default:"thea.events"`
+		NatsURL      string `toml:"nats_url" env:"EVENT_TRANSPORT_NATS_URL" env-default:"nats://localhost:4222"`
+		RedisAddress string `toml:"redis_address" env:"EVENT_TRANSPORT_REDIS_ADDRESS" env-default:"localhost:6379"`
+	}
+)
+
+const (
+	LocalTransport TransportBackend = "local"
+	NatsTransport  TransportBackend = "nats"
+	RedisTransport TransportBackend = "redis"
+)
+
+// RemoteEvents are the events which are relayed to/from other Thea instances when
+// a transport is in use. Other events (e.g. NewMediaEvent) are only handled by the
+// instance which dispatched them, to avoid (for example) multiple worker instances
+// starting transcodes for the same media.
+var RemoteEvents = []Event{
+	IngestUpdateEvent, IngestCompleteEvent,
+	TranscodeUpdateEvent, TranscodeCompleteEvent, TranscodeTaskProgressEvent,
+	UpdateMediaEvent, DeleteMediaEvent, DegradedMediaEvent, CorruptedMediaEvent, WatchTargetReadyEvent,
+	WorkflowUpdateEvent, TargetUpdateEvent,
+}
+
+// NewTransport constructs the transport for the backend specified in the config. A nil
+// transport is returned if the config specifies the local (in-process only) backend.
+func NewTransport(ctx context.Context, config TransportConfig) (Transport, error) {
+	//exhaustive:enforce
+	switch config.Backend {
+	case LocalTransport, "":
+		return nil, nil
+	case NatsTransport:
+		return newNatsTransport(config)
+	case RedisTransport:
+		return newRedisTransport(ctx, config)
+	}
+
+	return nil, fmt.Errorf("unknown event transport backend '%s'", config.Backend)
+}
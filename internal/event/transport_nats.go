@@ -0,0 +1,58 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsTransport relays events using NATS core pub/sub. Events published while
+// an instance is disconnected are not received by that instance.
+type natsTransport struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func newNatsTransport(config TransportConfig) (*natsTransport, error) {
+	conn, err := nats.Connect(config.NatsURL, nats.Name("thea"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", config.NatsURL, err)
+	}
+
+	return &natsTransport{conn: conn, subject: config.Channel}, nil
+}
+
+func (transport *natsTransport) Publish(_ context.Context, event RemoteEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event %v: %w", event, err)
+	}
+
+	return transport.conn.Publish(transport.subject, data)
+}
+
+func (transport *natsTransport) Subscribe(ctx context.Context, handler func(RemoteEvent)) error {
+	sub, err := transport.conn.Subscribe(transport.subject, func(msg *nats.Msg) {
+		var event RemoteEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			log.Warnf("Ignoring malformed event received from NATS: %v\n", err)
+			return
+		}
+
+		handler(event)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to NATS subject %s: %w", transport.subject, err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	<-ctx.Done()
+	return nil
+}
+
+func (transport *natsTransport) Close() error {
+	transport.conn.Close()
+	return nil
+}
@@ -0,0 +1,108 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisStreamMaxLen is the (approximate) number of events retained in the stream.
+	redisStreamMaxLen = 10_000
+
+	redisReadBlock = 5 * time.Second
+	redisReadCount = 100
+)
+
+// redisTransport relays events using a Redis stream. Each instance reads the
+// stream independently (i.e. without a consumer group), starting from the
+// events published after the instance subscribed.
+type redisTransport struct {
+	client *redis.Client
+	stream string
+}
+
+func newRedisTransport(ctx context.Context, config TransportConfig) (*redisTransport, error) {
+	client := redis.NewClient(&redis.Options{Addr: config.RedisAddress})
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", config.RedisAddress, err)
+	}
+
+	return &redisTransport{client: client, stream: config.Channel}, nil
+}
+
+func (transport *redisTransport) Publish(ctx context.Context, event RemoteEvent) error {
+	return transport.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: transport.stream,
+		MaxLen: redisStreamMaxLen,
+		Approx: true,
+		Values: map[string]any{
+			"origin":  event.Origin.String(),
+			"event":   string(event.Event),
+			"payload": event.Payload.String(),
+		},
+	}).Err()
+}
+
+func (transport *redisTransport) Subscribe(ctx context.Context, handler func(RemoteEvent)) error {
+	lastID := "$"
+	for {
+		streams, err := transport.client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{transport.stream, lastID},
+			Count:   redisReadCount,
+			Block:   redisReadBlock,
+		}).Result()
+		if ctx.Err() != nil {
+			return nil
+		} else if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read Redis stream %s: %w", transport.stream, err)
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				lastID = message.ID
+
+				event, err := parseRedisMessage(message)
+				if err != nil {
+					log.Warnf("Ignoring malformed event %s received from Redis: %v\n", message.ID, err)
+					continue
+				}
+
+				handler(event)
+			}
+		}
+	}
+}
+
+func (transport *redisTransport) Close() error {
+	return transport.client.Close()
+}
+
+func parseRedisMessage(message redis.XMessage) (RemoteEvent, error) {
+	values := make(map[string]string, len(message.Values))
+	for k, v := range message.Values {
+		str, ok := v.(string)
+		if !ok {
+			return RemoteEvent{}, fmt.Errorf("field %s has unexpected type %T", k, v)
+		}
+		values[k] = str
+	}
+
+	origin, err := uuid.Parse(values["origin"])
+	if err != nil {
+		return RemoteEvent{}, fmt.Errorf("invalid origin: %w", err)
+	}
+	payload, err := uuid.Parse(values["payload"])
+	if err != nil {
+		return RemoteEvent{}, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	return RemoteEvent{Origin: origin, Event: Event(values["event"]), Payload: payload}, nil
+}
@@ -0,0 +1,204 @@
+// Package export writes Kodi-compatible NFO files (and artwork) alongside the source files of the
+// movies and episodes in Thea's library, so that other media servers using the same files
+// (such as Jellyfin, Emby or Kodi) can read the metadata Thea has already matched.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var (
+	log = logger.Get("Export")
+
+	ErrExportInProgress = errors.New("a library export is already in progress")
+)
+
+type (
+	Config struct {
+		// OnIngest causes the NFO files of each movie/episode to be
+		// written as soon as the media is ingested.
+		OnIngest bool `toml:"on_ingest" env:"EXPORT_ON_INGEST" env-default:"false"`
+
+		// Artwork causes the poster of each movie, and the thumbnail of each
+		// episode, to be downloaded from TMDB alongside the NFO files.
+		Artwork bool `toml:"artwork" env:"EXPORT_ARTWORK" env-default:"true"`
+	}
+
+	Searcher interface {
+		GetMovie(movieID string) (*tmdb.Movie, error)
+		GetEpisode(seriesID string, seasonNumber int, episodeNumber int) (*tmdb.Episode, error)
+		DownloadImage(imagePath string, dest io.Writer) error
+	}
+
+	Store interface {
+		GetMedia(mediaID uuid.UUID) *media.Container
+		ListMediaSourceFiles() ([]*media.SourceFile, error)
+	}
+
+	// Service exports the NFO files of newly ingested media (if enabled), as well as exporting
+	// the entire library on-demand (see RequestExport). The NFO of a movie/episode is written
+	// next to it's source file, using the same name as the source file (e.g. 'Movie (2010).mkv'
+	// is exported to 'Movie (2010).nfo'), as expected by Kodi and Jellyfin.
+	Service struct {
+		config         Config
+		searcher       Searcher
+		store          Store
+		eventBus       event.EventHandler
+		exporting      atomic.Bool
+		exportRequests chan struct{}
+	}
+)
+
+func New(config Config, searcher Searcher, store Store, eventBus event.EventHandler) *Service {
+	return &Service{
+		config:         config,
+		searcher:       searcher,
+		store:          store,
+		eventBus:       eventBus,
+		exportRequests: make(chan struct{}, 1),
+	}
+}
+
+func (service *Service) Run(ctx context.Context) error {
+	handlerChannelSize := 100
+	ev := make(event.HandlerChannel, handlerChannelSize)
+	if service.config.OnIngest {
+		service.eventBus.RegisterHandlerChannel(ev, event.NewMediaEvent)
+		log.Emit(logger.NEW, "Exporting NFO files of newly ingested media (artwork=%v)\n", service.config.Artwork)
+	}
+
+	for {
+		select {
+		case message := <-ev:
+			mediaID, ok := message.Payload.(uuid.UUID)
+			if !ok {
+				log.Emit(logger.ERROR, "failed to extract UUID from %s event (payload %#v)\n", message.Event, message.Payload)
+				continue
+			}
+
+			if err := service.Export(mediaID); err != nil {
+				log.Warnf("Failed to export media %s: %v\n", mediaID, err)
+			}
+		case <-service.exportRequests:
+			service.exportAll(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// RequestExport queues an export of the entire library, which is performed in the background as
+// it requires querying TMDB for every movie/episode. If an export is already queued or in
+// progress, ErrExportInProgress is returned.
+func (service *Service) RequestExport() error {
+	if !service.exporting.CompareAndSwap(false, true) {
+		return ErrExportInProgress
+	}
+
+	service.exportRequests <- struct{}{}
+	return nil
+}
+
+// Export writes the NFO file (and artwork, if enabled) of the movie/episode with the ID
+// provided. Existing NFO files and artwork are overwritten.
+func (service *Service) Export(mediaID uuid.UUID) error {
+	container := service.store.GetMedia(mediaID)
+	if container == nil {
+		return fmt.Errorf("media %s not found", mediaID)
+	}
+
+	base := strings.TrimSuffix(container.Source(), filepath.Ext(container.Source()))
+
+	//exhaustive:ignore
+	switch container.Type {
+	case media.MovieContainerType:
+		movie, err := service.searcher.GetMovie(container.TmdbID())
+		if err != nil {
+			return err
+		}
+
+		if err := writeNfo(base+".nfo", newMovieNfo(movie)); err != nil {
+			return fmt.Errorf("failed to write NFO: %w", err)
+		}
+
+		return service.exportArtwork(movie.PosterPath, base+"-poster")
+	case media.EpisodeContainerType:
+		if container.Series == nil {
+			return errors.New("series of episode not found")
+		}
+
+		episode, err := service.searcher.GetEpisode(container.Series.TmdbID, container.SeasonNumber(), container.EpisodeNumber())
+		if err != nil {
+			return err
+		}
+
+		if err := writeNfo(base+".nfo", newEpisodeNfo(episode, container)); err != nil {
+			return fmt.Errorf("failed to write NFO: %w", err)
+		}
+
+		return service.exportArtwork(episode.StillPath, base+"-thumb")
+	default:
+		return fmt.Errorf("media %s is neither a movie or an episode", mediaID)
+	}
+}
+
+func (service *Service) exportAll(ctx context.Context) {
+	defer service.exporting.Store(false)
+
+	sources, err := service.store.ListMediaSourceFiles()
+	if err != nil {
+		log.Errorf("Library export failed, unable to list media: %v\n", err)
+		return
+	}
+
+	log.Emit(logger.NEW, "Exporting %d movies/episodes\n", len(sources))
+	exported := 0
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := service.Export(source.MediaID); err != nil {
+			log.Warnf("Failed to export media %s: %v\n", source.MediaID, err)
+			continue
+		}
+		exported++
+	}
+
+	log.Emit(logger.SUCCESS, "Exported %d/%d movies/episodes\n", exported, len(sources))
+}
+
+// exportArtwork downloads the TMDB image at the path provided (if artwork is enabled), writing it to
+// the destination provided. The extension of the image is appended to the destination.
+func (service *Service) exportArtwork(imagePath string, dest string) error {
+	if !service.config.Artwork || imagePath == "" {
+		return nil
+	}
+
+	dest += filepath.Ext(imagePath)
+	f, err := os.Create(dest) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("failed to create artwork: %w", err)
+	}
+	defer f.Close()
+
+	if err := service.searcher.DownloadImage(imagePath, f); err != nil {
+		_ = os.Remove(dest)
+		return fmt.Errorf("failed to download artwork: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+type (
+	// uniqueID identifies the media in an external database. Only the
+	// TMDB ID is known to Thea, and so it is always the default.
+	uniqueID struct {
+		Type    string `xml:"type,attr"`
+		Default bool   `xml:"default,attr"`
+		Value   string `xml:",chardata"`
+	}
+
+	movieSet struct {
+		Name string `xml:"name"`
+	}
+
+	// movieNfo is the Kodi movie NFO format, which is also read by Jellyfin and Emby.
+	// See https://kodi.wiki/view/NFO_files/Movies.
+	movieNfo struct {
+		XMLName   xml.Name  `xml:"movie"`
+		Title     string    `xml:"title"`
+		Tagline   string    `xml:"tagline,omitempty"`
+		Plot      string    `xml:"plot,omitempty"`
+		Premiered string    `xml:"premiered,omitempty"`
+		Year      string    `xml:"year,omitempty"`
+		Genres    []string  `xml:"genre"`
+		Set       *movieSet `xml:"set,omitempty"`
+		UniqueID  uniqueID  `xml:"uniqueid"`
+	}
+
+	// episodeNfo is the Kodi episode NFO format.
+	// See https://kodi.wiki/view/NFO_files/Episodes.
+	episodeNfo struct {
+		XMLName   xml.Name `xml:"episodedetails"`
+		Title     string   `xml:"title"`
+		ShowTitle string   `xml:"showtitle,omitempty"`
+		Season    int      `xml:"season"`
+		Episode   int      `xml:"episode"`
+		Plot      string   `xml:"plot,omitempty"`
+		Aired     string   `xml:"aired,omitempty"`
+		UniqueID  uniqueID `xml:"uniqueid"`
+	}
+)
+
+func newMovieNfo(movie *tmdb.Movie) *movieNfo {
+	nfo := &movieNfo{
+		Title:     movie.Name,
+		Tagline:   movie.Tagline,
+		Plot:      movie.Overview,
+		Premiered: movie.ReleaseDate,
+		Genres:    make([]string, len(movie.Genres)),
+		UniqueID:  uniqueID{Type: "tmdb", Default: true, Value: movie.ID.String()},
+	}
+	if year, _, ok := strings.Cut(movie.ReleaseDate, "-"); ok {
+		nfo.Year = year
+	}
+	for k, v := range movie.Genres {
+		nfo.Genres[k] = v.Name
+	}
+	if movie.Collection != nil {
+		nfo.Set = &movieSet{Name: movie.Collection.Name}
+	}
+
+	return nfo
+}
+
+func newEpisodeNfo(episode *tmdb.Episode, container *media.Container) *episodeNfo {
+	nfo := &episodeNfo{
+		Title:    episode.Name,
+		Season:   container.SeasonNumber(),
+		Episode:  container.EpisodeNumber(),
+		Plot:     episode.Overview,
+		Aired:    episode.AirDate,
+		UniqueID: uniqueID{Type: "tmdb", Default: true, Value: episode.ID.String()},
+	}
+	if container.Series != nil {
+		nfo.ShowTitle = container.Series.Title
+	}
+
+	return nfo
+}
+
+// writeNfo marshals the NFO provided as indented XML, and writes it to the path provided.
+func writeNfo(path string, nfo any) error {
+	out, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append([]byte(xml.Header), out...), 0o644) //nolint:gosec
+}
@@ -0,0 +1,40 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WriteMovieNfo(t *testing.T) {
+	movie := &tmdb.Movie{
+		ID:          "603",
+		Name:        "The Matrix",
+		ReleaseDate: "1999-03-30",
+		Overview:    "Set in the 22nd century...",
+		Genres:      []tmdb.Genre{{ID: "28", Name: "Action"}, {ID: "878", Name: "Science Fiction"}},
+		Collection:  &tmdb.Collection{ID: "2344", Name: "The Matrix Collection"},
+	}
+
+	path := filepath.Join(t.TempDir(), "The Matrix (1999).nfo")
+	assert.NoError(t, writeNfo(path, newMovieNfo(movie)))
+
+	out, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<movie>
+  <title>The Matrix</title>
+  <plot>Set in the 22nd century...</plot>
+  <premiered>1999-03-30</premiered>
+  <year>1999</year>
+  <genre>Action</genre>
+  <genre>Science Fiction</genre>
+  <set>
+    <name>The Matrix Collection</name>
+  </set>
+  <uniqueid type="tmdb" default="true">603</uniqueid>
+</movie>`, string(out))
+}
@@ -0,0 +1,179 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/floostack/transcoder"
+)
+
+// ErrAudioLanguageInvalid is returned when a target is saved with an audio language
+// preference which is not a three-letter (ISO 639-2) language code.
+var ErrAudioLanguageInvalid = errors.New("audio language must be a three-letter (ISO 639-2) language code")
+
+// bibliographicLanguages maps the bibliographic ISO 639-2 codes to their terminology equivalent,
+// as both are commonly found in the language tags of media (e.g. 'ger' and 'deu' for German).
+var bibliographicLanguages = map[string]string{
+	"alb": "sqi", "arm": "hye", "baq": "eus", "bur": "mya", "chi": "zho", "cze": "ces", "dut": "nld",
+	"fre": "fra", "geo": "kat", "ger": "deu", "gre": "ell", "ice": "isl", "mac": "mkd", "mao": "mri",
+	"may": "msa", "per": "fas", "rum": "ron", "slo": "slk", "tib": "bod", "wel": "cym",
+}
+
+// AudioStream describes an audio stream of a source, as reported by ffprobe. The Language is
+// the (normalized) language tag of the stream, and is empty if the stream is not tagged.
+type AudioStream struct {
+	Index    int
+	Codec    string
+	Language string
+	Default  bool
+}
+
+// ProbeAudioStreams lists the audio streams of the media file at the path provided.
+func ProbeAudioStreams(path string, probePath string) ([]AudioStream, error) {
+	out, err := exec.Command(probePath, //nolint:gosec
+		"-v", "error", "-select_streams", "a", "-of", "json",
+		"-show_entries", "stream=index,codec_name:stream_tags=language:stream_disposition=default",
+		path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe audio streams of %s using ffprobe: %w", path, err)
+	}
+
+	return parseAudioStreams(out)
+}
+
+// SelectAudioStream returns the audio stream which best satisfies the language preferences provided, which are
+// ordered from most to least preferred. Where several streams share a preferred language, the default stream
+// is preferred. If no stream satisfies any of the preferences, the default (or otherwise, the first) stream
+// is selected instead. Nil is returned if there are no audio streams.
+func SelectAudioStream(streams []AudioStream, languages []string) *AudioStream {
+	if len(streams) == 0 {
+		return nil
+	}
+
+	for _, language := range languages {
+		language = normalizeLanguage(language)
+
+		var selected *AudioStream
+		for k, stream := range streams {
+			if stream.Language != language {
+				continue
+			}
+			if selected == nil || (!selected.Default && stream.Default) {
+				selected = &streams[k]
+			}
+		}
+		if selected != nil {
+			return selected
+		}
+	}
+
+	for k, stream := range streams {
+		if stream.Default {
+			return &streams[k]
+		}
+	}
+
+	return &streams[0]
+}
+
+// SelectTargetAudio probes the audio streams of the source at the path provided, and selects the stream
+// which best satisfies the audio language preferences of the target (see SelectAudioStream). Nil is
+// returned if the target has no preferences, or if the audio streams of the source cannot be probed.
+func SelectTargetAudio(sourcePath string, probePath string, target *Target) *AudioStream {
+	if len(target.AudioLanguages) == 0 {
+		return nil
+	}
+
+	streams, err := ProbeAudioStreams(sourcePath, probePath)
+	if err != nil {
+		log.Warnf("Unable to probe audio streams of %s, audio language preferences of target %s will not be applied: %v\n", sourcePath, target, err)
+		return nil
+	}
+
+	return SelectAudioStream(streams, target.AudioLanguages)
+}
+
+// WithAudioStream returns ffmpeg options which apply the options given, but only map the audio stream
+// provided (and the primary video stream) of the source to the output, marking the audio stream as the
+// default. If the audio stream is nil, the options are returned unchanged.
+func WithAudioStream(opts transcoder.Options, audio *AudioStream) transcoder.Options {
+	if audio == nil {
+		return opts
+	}
+
+	return &audioMappedOpts{opts: opts, audio: audio}
+}
+
+// ValidateAudioLanguages returns an error if any of the audio language preferences are not
+// three-letter (ISO 639-2) language codes.
+func ValidateAudioLanguages(languages []string) error {
+	for _, language := range languages {
+		if len(language) != 3 || strings.IndexFunc(language, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+			return fmt.Errorf("%w: '%s'", ErrAudioLanguageInvalid, language)
+		}
+	}
+
+	return nil
+}
+
+func (stream *AudioStream) String() string {
+	return fmt.Sprintf("AudioStream{Index=%d Codec=%s Language=%s}", stream.Index, stream.Codec, stream.Language)
+}
+
+type audioMappedOpts struct {
+	opts  transcoder.Options
+	audio *AudioStream
+}
+
+func (opts *audioMappedOpts) GetStrArguments() []string {
+	args := []string{"-map", "0:V:0?", "-map", "0:" + strconv.Itoa(opts.audio.Index)}
+	args = append(args, opts.opts.GetStrArguments()...)
+	return append(args, "-disposition:a:0", "default")
+}
+
+// parseAudioStreams parses the JSON output of ffprobe, listing the audio streams of a source.
+func parseAudioStreams(out []byte) ([]AudioStream, error) {
+	var probed struct {
+		Streams []struct {
+			Index       int               `json:"index"`
+			CodecName   string            `json:"codec_name"`
+			Tags        map[string]string `json:"tags"`
+			Disposition map[string]int    `json:"disposition"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probed); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	streams := make([]AudioStream, 0, len(probed.Streams))
+	for _, stream := range probed.Streams {
+		streams = append(streams, AudioStream{
+			Index:    stream.Index,
+			Codec:    stream.CodecName,
+			Language: normalizeLanguage(stream.Tags["language"]),
+			Default:  stream.Disposition["default"] == 1,
+		})
+	}
+
+	return streams, nil
+}
+
+// normalizeLanguage lowercases the language code provided, converting bibliographic
+// ISO 639-2 codes to their terminology equivalent. Undetermined ('und') languages
+// are normalized to an empty string.
+func normalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "und" {
+		return ""
+	}
+	if terminology, ok := bibliographicLanguages[language]; ok {
+		return terminology
+	}
+
+	return language
+}
@@ -0,0 +1,80 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseAudioStreams(t *testing.T) {
+	streams, err := parseAudioStreams([]byte(`{"streams": [
+		{"index": 1, "codec_name": "eac3", "disposition": {"default": 1}, "tags": {"language": "ENG"}},
+		{"index": 2, "codec_name": "aac", "disposition": {"default": 0}, "tags": {"language": "ger"}},
+		{"index": 3, "codec_name": "aac", "disposition": {"default": 0}}
+	]}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []AudioStream{
+		{Index: 1, Codec: "eac3", Language: "eng", Default: true},
+		{Index: 2, Codec: "aac", Language: "deu"},
+		{Index: 3, Codec: "aac"},
+	}, streams)
+}
+
+func Test_SelectAudioStream(t *testing.T) {
+	streams := []AudioStream{
+		{Index: 1, Language: "eng", Default: true},
+		{Index: 2, Language: "jpn"},
+		{Index: 3, Language: "jpn", Default: true},
+		{Index: 4, Language: "deu"},
+	}
+
+	assert.Equal(t, 3, SelectAudioStream(streams, []string{"jpn", "eng"}).Index, "default stream preferred amongst streams of the same language")
+	assert.Equal(t, 4, SelectAudioStream(streams, []string{"ger", "eng"}).Index, "bibliographic codes are normalized")
+	assert.Equal(t, 1, SelectAudioStream(streams, []string{"fra"}).Index, "default stream used when no preference is satisfied")
+	assert.Equal(t, 2, SelectAudioStream([]AudioStream{{Index: 2}, {Index: 5}}, []string{"fra"}).Index, "first stream used when there is no default")
+	assert.Nil(t, SelectAudioStream(nil, []string{"eng"}))
+}
+
+func Test_WithAudioStream(t *testing.T) {
+	codec := "libx264"
+	opts := &Opts{VideoCodec: &codec}
+
+	assert.Equal(t, opts, WithAudioStream(opts, nil))
+	assert.Equal(t,
+		[]string{"-map", "0:V:0?", "-map", "0:3", "-c:v", "libx264", "-disposition:a:0", "default"},
+		WithAudioStream(opts, &AudioStream{Index: 3}).GetStrArguments(),
+	)
+}
+
+func Test_ValidateAudioLanguages(t *testing.T) {
+	assert.NoError(t, ValidateAudioLanguages([]string{"jpn", "eng"}))
+	assert.ErrorIs(t, ValidateAudioLanguages([]string{"jpn", "en"}), ErrAudioLanguageInvalid)
+	assert.ErrorIs(t, ValidateAudioLanguages([]string{"ENG"}), ErrAudioLanguageInvalid)
+}
+
+func Test_ParsePlaybackSource(t *testing.T) {
+	source, err := parsePlaybackSource([]byte(`{
+		"streams": [
+			{"index": 0, "codec_name": "h264", "codec_type": "video", "disposition": {"default": 1}},
+			{"index": 1, "codec_name": "aac", "codec_type": "audio", "disposition": {"default": 1}, "tags": {"language": "eng"}},
+			{"index": 2, "codec_name": "subrip", "codec_type": "subtitle", "disposition": {"forced": 1}, "tags": {"language": "fre"}},
+			{"index": 3, "codec_name": "subrip", "codec_type": "subtitle", "disposition": {"forced": 0}, "tags": {"language": "fra"}}
+		],
+		"format": {"bit_rate": "8123456"}
+	}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 8123, source.BitrateKbps)
+	assert.Equal(t, []AudioStream{{Index: 1, Codec: "aac", Language: "eng", Default: true}}, source.Audio)
+	assert.Equal(t, []SubtitleStream{{Index: 2, Codec: "subrip", Language: "fra", Forced: true}, {Index: 3, Codec: "subrip", Language: "fra"}}, source.Subtitles)
+
+	assert.Equal(t, 3, SelectSubtitleStream(source.Subtitles, "fre").Index, "full subtitles preferred over forced subtitles")
+	assert.Nil(t, SelectSubtitleStream(source.Subtitles, "eng"))
+	assert.Nil(t, SelectSubtitleStream(source.Subtitles, ""))
+
+	limit := 8000
+	assert.False(t, source.AllowsDirectPlay(&limit))
+	assert.True(t, source.AllowsDirectPlay(nil))
+	assert.True(t, (&PlaybackSource{}).AllowsDirectPlay(&limit), "sources of unknown bitrate are allowed")
+}
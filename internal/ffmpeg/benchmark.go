@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	// benchmarkSource is the synthetic input encoded by a benchmark, so that the
+	// throughput measured is not influenced by the decoding of any real media.
+	benchmarkSource    = "testsrc2=size=1920x1080:rate=30"
+	benchmarkSourceFps = 30
+	benchmarkFrames    = 300
+)
+
+// TargetBenchmark is the encoding throughput measured for a target on this host, using a
+// synthetic 1080p source. The target is encoded once using a single thread, and once using
+// the number of threads given, so that the number of threads the encoder actually puts to
+// use can be estimated (see EstimatedThreads).
+type TargetBenchmark struct {
+	BenchmarkedAt   time.Time `json:"benchmarked_at"`
+	SingleThreadFps float64   `json:"single_thread_fps"`
+	Threads         int       `json:"threads"`
+	Fps             float64   `json:"fps"`
+}
+
+// Scan scan value into Jsonb, implements sql.Scanner interface.
+func (benchmark *TargetBenchmark) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("Failed to unmarshal JSONB value:", value))
+	}
+
+	result := TargetBenchmark{}
+	err := json.Unmarshal(bytes, &result)
+	*benchmark = result
+	return err
+}
+
+// Value return json value, implement driver.Valuer interface.
+func (benchmark TargetBenchmark) Value() (driver.Value, error) {
+	return json.Marshal(benchmark)
+}
+
+// EstimatedThreads returns the number of threads the encoder makes use of, based on the speedup
+// measured when encoding with multiple threads rather than one. An encoder which barely benefits
+// from additional threads is therefore not reserved more threads than it can use.
+func (benchmark *TargetBenchmark) EstimatedThreads() int {
+	if benchmark.SingleThreadFps <= 0 {
+		return max(1, benchmark.Threads)
+	}
+
+	speedup := int(math.Round(benchmark.Fps / benchmark.SingleThreadFps))
+	return min(max(1, speedup), max(1, benchmark.Threads))
+}
+
+// RealtimeFactor returns how many seconds of 30fps footage the target encodes per second. A transcode
+// of media using the target is expected to take the duration of the media divided by this factor.
+func (benchmark *TargetBenchmark) RealtimeFactor() float64 {
+	return benchmark.Fps / benchmarkSourceFps
+}
+
+// BenchmarkTarget measures the encoding throughput of the target provided using the ffmpeg
+// binary at the path given, once using a single thread and once using the threads given.
+// The output of the encodes is discarded.
+func BenchmarkTarget(ctx context.Context, ffmpegBinPath string, target *Target, threads int) (*TargetBenchmark, error) {
+	threads = max(1, threads)
+	singleFps, err := measureFps(ctx, ffmpegBinPath, target, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to benchmark target %s using a single thread: %w", target, err)
+	}
+
+	fps := singleFps
+	if threads > 1 {
+		if fps, err = measureFps(ctx, ffmpegBinPath, target, threads); err != nil {
+			return nil, fmt.Errorf("failed to benchmark target %s using %d threads: %w", target, threads, err)
+		}
+	}
+
+	return &TargetBenchmark{BenchmarkedAt: time.Now(), SingleThreadFps: singleFps, Threads: threads, Fps: fps}, nil
+}
+
+// measureFps encodes the benchmark source using the target and number of threads provided,
+// returning the average number of frames encoded per second.
+func measureFps(ctx context.Context, ffmpegBinPath string, target *Target, threads int) (float64, error) {
+	args := []string{"-hide_banner", "-nostdin", "-f", "lavfi", "-i", benchmarkSource}
+	if target.FfmpegOptions != nil {
+		args = append(args, target.FfmpegOptions.GetStrArguments()...)
+	}
+	args = append(args, "-an", "-frames:v", strconv.Itoa(benchmarkFrames), "-threads", strconv.Itoa(threads), "-f", "null", "-")
+
+	startedAt := time.Now()
+	output, err := exec.CommandContext(ctx, ffmpegBinPath, args...).CombinedOutput() //nolint:gosec
+	if err != nil {
+		return 0, fmt.Errorf("ffmpeg exited with error: %w (output: %s)", err, trimOutput(output, 512))
+	}
+
+	return benchmarkFrames / time.Since(startedAt).Seconds(), nil
+}
+
+func trimOutput(output []byte, size int) []byte {
+	if len(output) <= size {
+		return output
+	}
+
+	return output[len(output)-size:]
+}
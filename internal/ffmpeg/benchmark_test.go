@@ -0,0 +1,19 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TargetBenchmark_EstimatedThreads(t *testing.T) {
+	assert.Equal(t, 4, (&TargetBenchmark{SingleThreadFps: 10, Threads: 8, Fps: 41}).EstimatedThreads())
+	assert.Equal(t, 8, (&TargetBenchmark{SingleThreadFps: 10, Threads: 8, Fps: 120}).EstimatedThreads(), "estimate is limited to the threads benchmarked")
+	assert.Equal(t, 1, (&TargetBenchmark{SingleThreadFps: 10, Threads: 8, Fps: 9}).EstimatedThreads(), "at least one thread is required")
+	assert.Equal(t, 8, (&TargetBenchmark{Threads: 8}).EstimatedThreads())
+}
+
+func Test_Target_RequiredThreads(t *testing.T) {
+	assert.Equal(t, defaultThreads, (&Target{}).RequiredThreads())
+	assert.Equal(t, 3, (&Target{Benchmark: &TargetBenchmark{SingleThreadFps: 20, Threads: 8, Fps: 60}}).RequiredThreads())
+}
@@ -0,0 +1,248 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type (
+	// Capabilities describes the encoders, muxers and filters supported
+	// by an ffmpeg build.
+	Capabilities struct {
+		Encoders []string
+		Muxers   []string
+		Filters  []string
+	}
+
+	// TargetValidator validates targets against the capabilities of the ffmpeg binary
+	// at the path provided, so that targets which will inevitably fail at runtime
+	// can be rejected when they are saved. The capabilities are probed on first use.
+	TargetValidator struct {
+		ffmpegBinPath string
+		probeOnce     sync.Once
+		capabilities  *Capabilities
+	}
+
+	// UnsupportedTargetError is returned when a target uses encoders, muxers
+	// or filters which are not supported by the ffmpeg build.
+	UnsupportedTargetError struct {
+		BinPath  string
+		Problems []string
+	}
+)
+
+var (
+	encoderFlags = []string{"-c:v", "-c:a", "-vcodec", "-acodec", "-codec:v", "-codec:a"}
+	filterFlags  = []string{"-vf", "-af", "-filter:v", "-filter:a", "-filter_complex"}
+
+	// extensionMuxers maps the extensions of common containers to the name of
+	// the muxer ffmpeg uses for them, where the two differ.
+	extensionMuxers = map[string]string{"mkv": "matroska", "ts": "mpegts", "m4a": "ipod"}
+)
+
+func (err *UnsupportedTargetError) Error() string {
+	return fmt.Sprintf("target is not supported by the ffmpeg build at '%s': %s", err.BinPath, strings.Join(err.Problems, "; "))
+}
+
+func NewTargetValidator(ffmpegBinPath string) *TargetValidator {
+	return &TargetValidator{ffmpegBinPath: ffmpegBinPath}
+}
+
+// Validate returns an UnsupportedTargetError if the target provided uses any encoders, filters
+// or containers which the ffmpeg build does not support. If the capabilities of the ffmpeg build
+// cannot be determined, the target is assumed to be valid (the preflight checks will have
+// already reported the problem with the ffmpeg installation). ErrExtensionUnsupported is
+// returned if the target outputs to a container which Thea does not support, and
+// ErrAudioLanguageInvalid if any of the audio language preferences are malformed.
+func (validator *TargetValidator) Validate(target *Target) error {
+	if !SupportsExtension(target.Ext) {
+		return fmt.Errorf("%w: '%s' (supported extensions are %s)", ErrExtensionUnsupported, target.Ext, strings.Join(containerExtensions, ", "))
+	}
+	if err := ValidateAudioLanguages(target.AudioLanguages); err != nil {
+		return err
+	}
+
+	validator.probeOnce.Do(func() {
+		capabilities, err := ProbeCapabilities(validator.ffmpegBinPath)
+		if err != nil {
+			log.Warnf("Unable to determine capabilities of ffmpeg, targets will not be validated: %v\n", err)
+			return
+		}
+
+		validator.capabilities = capabilities
+	})
+
+	if validator.capabilities == nil {
+		return nil
+	}
+
+	problems := validator.capabilities.unsupported(target)
+	if len(problems) > 0 {
+		return &UnsupportedTargetError{BinPath: validator.ffmpegBinPath, Problems: problems}
+	}
+
+	return nil
+}
+
+// ProbeCapabilities lists the encoders, muxers and filters supported by the ffmpeg binary provided.
+func ProbeCapabilities(ffmpegBinPath string) (*Capabilities, error) {
+	list := func(arg string) ([]byte, error) {
+		out, err := exec.Command(ffmpegBinPath, "-hide_banner", arg).Output() //nolint:gosec
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s using ffmpeg at '%s': %w", strings.TrimPrefix(arg, "-"), ffmpegBinPath, err)
+		}
+
+		return out, nil
+	}
+
+	encoders, err := list("-encoders")
+	if err != nil {
+		return nil, err
+	}
+	muxers, err := list("-muxers")
+	if err != nil {
+		return nil, err
+	}
+	filters, err := list("-filters")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Capabilities{
+		Encoders: parseCapabilityList(encoders),
+		Muxers:   parseCapabilityList(muxers),
+		Filters:  parseFilterList(filters),
+	}, nil
+}
+
+// ProbeVersion returns the version reported by the ffmpeg binary provided, e.g. 'n6.1.1'.
+func ProbeVersion(ffmpegBinPath string) (string, error) {
+	out, err := exec.Command(ffmpegBinPath, "-version").Output() //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to execute ffmpeg at '%s': %w", ffmpegBinPath, err)
+	}
+
+	return parseVersion(out)
+}
+
+// unsupported returns a description of each of the encoders, filters or containers used
+// by the target which are not supported. The ffmpeg options of remux-only targets are
+// ignored when transcoding, and so only the container of these targets is considered.
+func (capabilities *Capabilities) unsupported(target *Target) []string {
+	problems := make([]string, 0)
+	args := make([]string, 0)
+	if target.FfmpegOptions != nil && !target.RemuxOnly {
+		args = target.FfmpegOptions.GetStrArguments()
+	}
+	for i := 0; i+1 < len(args); i++ {
+		flag, value := args[i], args[i+1]
+		switch {
+		case slices.Contains(encoderFlags, flag):
+			if value != "copy" && !slices.Contains(capabilities.Encoders, value) {
+				problems = append(problems, fmt.Sprintf("encoder '%s' is not available", value))
+			}
+		case slices.Contains(filterFlags, flag):
+			for _, filter := range filterNames(value) {
+				if !slices.Contains(capabilities.Filters, filter) {
+					problems = append(problems, fmt.Sprintf("filter '%s' is not available", filter))
+				}
+			}
+		case flag == "-f":
+			if !slices.Contains(capabilities.Muxers, value) {
+				problems = append(problems, fmt.Sprintf("container format '%s' is not available", value))
+			}
+		}
+	}
+
+	muxer := target.Ext
+	if alias, ok := extensionMuxers[muxer]; ok {
+		muxer = alias
+	}
+	if !slices.Contains(capabilities.Muxers, muxer) {
+		problems = append(problems, fmt.Sprintf("no muxer is available for extension '%s'", target.Ext))
+	}
+
+	return problems
+}
+
+// filterNames extracts the names of the filters used in the filtergraph provided,
+// e.g. '[0:v]scale=1280:-2,fps=30[out]' uses the 'scale' and 'fps' filters.
+func filterNames(filtergraph string) []string {
+	names := make([]string, 0)
+	for _, chain := range strings.Split(filtergraph, ";") {
+		for _, filter := range strings.Split(chain, ",") {
+			filter = strings.TrimSpace(filter)
+			for strings.HasPrefix(filter, "[") {
+				end := strings.Index(filter, "]")
+				if end < 0 {
+					break
+				}
+				filter = filter[end+1:]
+			}
+
+			name, _, _ := strings.Cut(filter, "=")
+			if name, _, _ = strings.Cut(name, "["); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// parseCapabilityList extracts the names from the output of 'ffmpeg -encoders' or 'ffmpeg -muxers'. Each
+// entry is listed on it's own line after a separator line (e.g. ' ------'), in the format
+// ' V....D libx264              libx264 H.264 / AVC / ...'.
+func parseCapabilityList(output []byte) []string {
+	names := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	seenSeparator := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if !seenSeparator {
+			seenSeparator = len(fields) == 1 && strings.HasPrefix(fields[0], "--")
+			continue
+		}
+
+		if len(fields) >= 2 {
+			// Muxers may have multiple comma-separated names (e.g. 'mov,mp4,m4a')
+			names = append(names, strings.Split(fields[1], ",")...)
+		}
+	}
+
+	return names
+}
+
+// parseFilterList extracts the names from the output of 'ffmpeg -filters'. Each filter is listed
+// on it's own line in the format ' TSC scale             V->V       Scale the input video size...'.
+func parseFilterList(output []byte) []string {
+	names := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			names = append(names, fields[1])
+		}
+	}
+
+	return names
+}
+
+// parseVersion extracts the version from the output of 'ffmpeg -version', the first line
+// of which is in the format 'ffmpeg version n6.1.1 Copyright (c) 2000-2023 the FFmpeg developers'.
+func parseVersion(output []byte) (string, error) {
+	line, _, _ := bytes.Cut(output, []byte("\n"))
+	fields := strings.Fields(string(line))
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "version" {
+			return fields[i+1], nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to determine version from output '%s'", line)
+}
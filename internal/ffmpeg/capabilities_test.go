@@ -0,0 +1,54 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseCapabilityList(t *testing.T) {
+	output := []byte(`Muxers:
+ E = Muxing supported
+ --
+ E matroska        Matroska
+ E mp4             MP4 (MPEG-4 Part 14)
+`)
+	assert.Equal(t, []string{"matroska", "mp4"}, parseCapabilityList(output))
+}
+
+func Test_ParseFilterList(t *testing.T) {
+	output := []byte(`Filters:
+  T.. = Timeline support
+  | = Source or sink filter
+ ..C scale             V->V       Scale the input video size and/or convert the image format.
+ TSC yadif             V->V       Deinterlace the input image.
+`)
+	assert.Equal(t, []string{"scale", "yadif"}, parseFilterList(output))
+}
+
+func Test_ParseVersion(t *testing.T) {
+	version, err := parseVersion([]byte("ffmpeg version n6.1.1 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13.2.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "n6.1.1", version)
+
+	_, err = parseVersion([]byte("not ffmpeg"))
+	assert.Error(t, err)
+}
+
+func Test_FilterNames(t *testing.T) {
+	assert.Equal(t, []string{"scale", "fps"}, filterNames("scale=1280:-2,fps=30"))
+	assert.Equal(t, []string{"scale", "overlay"}, filterNames("[0:v]scale=640:-1[a];[a][1:v]overlay[out]"))
+}
+
+func Test_UnsupportedTarget(t *testing.T) {
+	capabilities := &Capabilities{Encoders: []string{"libx264"}, Muxers: []string{"mp4", "matroska"}}
+
+	codec := "libx264"
+	assert.Empty(t, capabilities.unsupported(&Target{Ext: "mkv", FfmpegOptions: &Opts{VideoCodec: &codec}}))
+
+	missingCodec := "libx265"
+	assert.Equal(t,
+		[]string{"encoder 'libx265' is not available", "no muxer is available for extension 'avi'"},
+		capabilities.unsupported(&Target{Ext: "avi", FfmpegOptions: &Opts{VideoCodec: &missingCodec}}),
+	)
+}
@@ -0,0 +1,118 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/floostack/transcoder"
+)
+
+// Compliance describes whether a source already satisfies the requirements of a target.
+type Compliance int
+
+const (
+	// NonCompliant sources must be transcoded using the target.
+	NonCompliant Compliance = iota
+
+	// RemuxCompliant sources already use the codecs and resolution required by the target,
+	// and so only need to be remuxed (without re-encoding) in to the container of the target.
+	RemuxCompliant
+
+	// Compliant sources already satisfy the target entirely, and need not be transcoded.
+	Compliant
+)
+
+// encoderCodecs maps the encoders which may be used by a target to the name of the
+// codec they produce, as reported by ffprobe.
+var encoderCodecs = map[string]string{
+	"libx264":    "h264",
+	"h264_nvenc": "h264",
+	"h264_qsv":   "h264",
+	"h264_vaapi": "h264",
+	"libx265":    "hevc",
+	"hevc_nvenc": "hevc",
+	"hevc_qsv":   "hevc",
+	"hevc_vaapi": "hevc",
+	"libvpx-vp9": "vp9",
+	"libaom-av1": "av1",
+	"libsvtav1":  "av1",
+	"aac":        "aac",
+	"libfdk_aac": "aac",
+	"libopus":    "opus",
+	"libmp3lame": "mp3",
+	"ac3":        "ac3",
+	"eac3":       "eac3",
+	"flac":       "flac",
+}
+
+// CheckCompliance compares the codecs, resolution and container of the source (described by the
+// metadata and path provided) against the target provided. Only the codecs, resolution and container
+// are considered, so targets which apply filters are never considered satisfied, as the effect of
+// the filters cannot be determined. Sources smaller than the resolution of the target satisfy it.
+// Remux-only targets are satisfied by any source, and so only the container is considered. Sources
+// are never entirely compliant with targets which have audio language preferences, as the audio
+// streams of the source must be remuxed to apply the preferences.
+func CheckCompliance(metadata transcoder.Metadata, sourcePath string, target *Target) Compliance {
+	sameContainer := strings.EqualFold(strings.TrimPrefix(filepath.Ext(sourcePath), "."), target.Ext) && len(target.AudioLanguages) == 0
+	if target.RemuxOnly {
+		if sameContainer {
+			return Compliant
+		}
+
+		return RemuxCompliant
+	}
+
+	opts := target.FfmpegOptions
+	if opts == nil || opts.VideoFilter != nil || opts.AudioFilter != nil {
+		return NonCompliant
+	}
+
+	var videoCodec, audioCodec string
+	var width, height int
+	for _, stream := range metadata.GetStreams() {
+		switch stream.GetCodecType() {
+		case "video":
+			if videoCodec == "" {
+				videoCodec = stream.GetCodecName()
+				width, height = stream.GetWidth(), stream.GetHeight()
+			}
+		case "audio":
+			if audioCodec == "" {
+				audioCodec = stream.GetCodecName()
+			}
+		}
+	}
+
+	if !codecSatisfies(opts.VideoCodec, videoCodec) || !codecSatisfies(opts.AudioCodec, audioCodec) {
+		return NonCompliant
+	}
+
+	if opts.Resolution != nil {
+		var targetWidth, targetHeight int
+		if _, err := fmt.Sscanf(*opts.Resolution, "%dx%d", &targetWidth, &targetHeight); err != nil {
+			return NonCompliant
+		}
+		if width > targetWidth || height > targetHeight {
+			return NonCompliant
+		}
+	}
+
+	if !sameContainer {
+		return RemuxCompliant
+	}
+
+	return Compliant
+}
+
+// codecSatisfies returns true if the codec of the source stream satisfies the encoder required by
+// the target. Targets which do not specify an encoder, or which copy the stream, are satisfied
+// by any codec. Encoders which are not known are never satisfied.
+func codecSatisfies(encoder *string, codec string) bool {
+	if encoder == nil || *encoder == "copy" {
+		return true
+	}
+
+	expected, ok := encoderCodecs[*encoder]
+	return ok && expected == codec
+}
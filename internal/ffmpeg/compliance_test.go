@@ -0,0 +1,16 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CodecSatisfies(t *testing.T) {
+	x264, x265, copyCodec, unknown := "libx264", "libx265", "copy", "libunknown"
+	assert.True(t, codecSatisfies(nil, "h264"))
+	assert.True(t, codecSatisfies(&copyCodec, "vp9"))
+	assert.True(t, codecSatisfies(&x264, "h264"))
+	assert.False(t, codecSatisfies(&x265, "h264"))
+	assert.False(t, codecSatisfies(&unknown, "h264"))
+}
@@ -1,6 +1,8 @@
 package ffmpeg
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -9,20 +11,39 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/floostack/transcoder"
-	"github.com/floostack/transcoder/ffmpeg"
 	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/mitchellh/go-homedir"
 )
 
-var log = logger.Get("FFmpeg")
+var (
+	log = logger.Get("FFmpeg")
+
+	progressFieldRegex = regexp.MustCompile(`(\w+)=\s*(\S+)`)
+)
+
+// defaultOutputTailSize is the maximum number of bytes of ffmpeg output retained
+// per command, if no size is specified in the config.
+const defaultOutputTailSize = 64 * 1024
 
 type Config struct {
 	FfmpegBinPath       string
 	FfprobeBinPath      string
 	OutputBaseDirectory string
+
+	// OutputTailSize is the maximum number of bytes of output retained from
+	// each ffmpeg command (see TranscodeCmd.OutputTail).
+	OutputTailSize int
+
+	// Priority is the scheduling priority of each ffmpeg command, and Cgroup is the cgroup
+	// v2 directory in which the cgroup of each command is created (see Priority.Apply).
+	Priority Priority
+	Cgroup   string
 }
 
 func (config *Config) GetOutputBaseDirectory() string {
@@ -41,6 +62,30 @@ type Progress struct {
 	CurrentBitrate  string
 	Progress        float64
 	Speed           string
+
+	// OutputSize is the number of bytes written to the output so far, and AverageFps is
+	// the average number of frames processed per second since the command was started.
+	// EtaSeconds is the estimated number of seconds until the command finishes, and is
+	// nil until an estimate can be made.
+	OutputSize int64
+	AverageFps float64
+	EtaSeconds *int64
+}
+
+// estimate populates the average fps and ETA of the progress, using the time
+// elapsed since the command was started.
+func (progress *Progress) estimate(elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return
+	}
+
+	frames, _ := strconv.Atoi(progress.FramesProcessed)
+	progress.AverageFps = float64(frames) / seconds
+	if progress.Progress > 0 {
+		eta := int64(seconds * (100 - progress.Progress) / progress.Progress)
+		progress.EtaSeconds = &eta
+	}
 }
 
 type TranscodeCmd struct {
@@ -48,49 +93,192 @@ type TranscodeCmd struct {
 	outputPath      string
 	transcodeConfig Config
 	runningCommand  *exec.Cmd
+	output          *outputTail
+
+	// offset and length restrict the transcode to a sample of the input. A
+	// zero length transcodes the entire input.
+	offset time.Duration
+	length time.Duration
 }
 
 func NewCmd(input string, output string, config Config) *TranscodeCmd {
-	return &TranscodeCmd{input, output, config, nil}
+	tailSize := config.OutputTailSize
+	if tailSize <= 0 {
+		tailSize = defaultOutputTailSize
+	}
+
+	return &TranscodeCmd{inputPath: input, outputPath: output, transcodeConfig: config, output: newOutputTail(tailSize)}
 }
 
-func (cmd *TranscodeCmd) Run(ctx context.Context, ffmpegConfig transcoder.Options, updateHandler func(*Progress)) error {
-	transcoder := ffmpeg.
-		New(&ffmpeg.Config{
-			ProgressEnabled: true,
-			FfmpegBinPath:   cmd.transcodeConfig.FfmpegBinPath,
-			FfprobeBinPath:  cmd.transcodeConfig.FfprobeBinPath,
-		}).
-		Input(cmd.inputPath).
-		Output(cmd.outputPath).
-		WithContext(&ctx)
+// NewSampleCmd creates a command which only transcodes the given length of the
+// input, starting at the offset provided.
+func NewSampleCmd(input string, output string, config Config, offset time.Duration, length time.Duration) *TranscodeCmd {
+	cmd := NewCmd(input, output, config)
+	cmd.offset = offset
+	cmd.length = length
+
+	return cmd
+}
 
+func (cmd *TranscodeCmd) Run(ctx context.Context, ffmpegConfig transcoder.Options, updateHandler func(*Progress)) error {
 	if err := os.MkdirAll(filepath.Dir(cmd.outputPath), os.ModeDir); err != nil {
 		return err
 	}
 
-	progressChannel, err := transcoder.Start(ffmpegConfig)
+	// The duration of the input is required to calculate the progress percentage. If
+	// it cannot be determined, the transcode can still proceed, but progress will be 0.
+	duration := cmd.length.Seconds()
+	if duration == 0 {
+		if inputDuration, err := ProbeDuration(cmd.inputPath, cmd.transcodeConfig.FfprobeBinPath); err == nil {
+			duration = inputDuration.Seconds()
+		} else {
+			log.Warnf("Unable to determine duration of %s, progress will not be reported: %v\n", cmd.inputPath, err)
+		}
+	}
+
+	args := []string{"-hide_banner"}
+	if cmd.length > 0 {
+		args = append(args, "-ss", formatSeconds(cmd.offset), "-t", formatSeconds(cmd.length))
+	}
+	args = append(args, "-i", cmd.inputPath)
+	args = append(args, ffmpegConfig.GetStrArguments()...)
+	args = append(args, cmd.outputPath)
+	runningCommand := exec.CommandContext(ctx, cmd.transcodeConfig.FfmpegBinPath, args...) //nolint:gosec
+	runningCommand.Stdout = cmd.output
+	stderr, err := runningCommand.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	// ffmpeg writes both it's diagnostics and it's progress (stats) to stderr, so stderr
+	// is scanned to report progress while retaining the tail of the output for diagnostics.
+	if err := runningCommand.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	cmd.runningCommand = runningCommand
+	startedAt := time.Now()
+
+	release, err := cmd.transcodeConfig.Priority.Apply(runningCommand.Process.Pid, cmd.transcodeConfig.Cgroup)
 	if err != nil {
-		return ParseFfmpegError(err)
+		log.Warnf("Unable to fully apply priority to ffmpeg command %s: %v\n", cmd, err)
+	}
+	defer release()
+
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanOutputLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		progress := parseProgressLine(line, duration)
+		cmd.output.write(line, progress != nil)
+		if progress != nil {
+			progress.estimate(time.Since(startedAt))
+			updateHandler(progress)
+		}
+	}
+
+	if err := runningCommand.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg exited with error: %w", err)
+	}
+
+	log.Emit(logger.DEBUG, "FFmpeg command %s has exited\n", cmd)
+	return nil
+}
+
+// parseProgressLine extracts the progress from an ffmpeg stats line, e.g.
+// 'frame= 1250 fps= 48 q=28.0 size= 5120kB time=00:00:52.10 bitrate= 805.1kbits/s speed=2.01x'.
+// Nil is returned if the line provided is not a stats line.
+func parseProgressLine(line string, duration float64) *Progress {
+	if !strings.HasPrefix(line, "frame=") {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, match := range progressFieldRegex.FindAllStringSubmatch(line, -1) {
+		fields[match[1]] = match[2]
+	}
+
+	progress := &Progress{
+		FramesProcessed: fields["frame"],
+		CurrentTime:     fields["time"],
+		CurrentBitrate:  fields["bitrate"],
+		Speed:           fields["speed"],
+		OutputSize:      parseSize(fields["size"]),
+	}
+	if duration > 0 {
+		progress.Progress = min(100, durationToSeconds(fields["time"])*100/duration)
+	}
+
+	return progress
+}
+
+// parseSize converts an ffmpeg size (e.g. '5120kB', or '5120KiB' in newer versions) in to a
+// number of bytes. Zero is returned if the size is not available (e.g. 'N/A').
+func parseSize(size string) int64 {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{{"KiB", 1024}, {"kB", 1024}, {"MiB", 1024 * 1024}, {"mB", 1024 * 1024}, {"B", 1}}
+	for _, unit := range units {
+		if value, ok := strings.CutSuffix(size, unit.suffix); ok {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0
+			}
+
+			return parsed * unit.multiplier
+		}
 	}
 
-	cmd.runningCommand = transcoder.GetRunningCmdInstance()
+	return 0
+}
 
-	for {
-		prog, ok := <-progressChannel
-		if !ok {
-			log.Emit(logger.DEBUG, "FFmpeg command has closed progress channel... closing report channel\n")
-			return nil
+// durationToSeconds converts an ffmpeg timestamp (HH:MM:SS.ms) in to seconds.
+func durationToSeconds(timestamp string) float64 {
+	seconds := 0.0
+	for _, part := range strings.Split(timestamp, ":") {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
 		}
 
-		updateHandler(&Progress{
-			FramesProcessed: prog.GetFramesProcessed(),
-			CurrentTime:     prog.GetCurrentTime(),
-			CurrentBitrate:  prog.GetCurrentBitrate(),
-			Progress:        prog.GetProgress(),
-			Speed:           prog.GetSpeed(),
-		})
+		seconds = seconds*60 + value
+	}
+
+	return seconds
+}
+
+// formatSeconds formats the duration provided as a number of seconds, as accepted by
+// ffmpeg's time duration options (e.g. '-ss').
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// scanOutputLines is a bufio.SplitFunc which splits on both newlines and carriage returns, as
+// ffmpeg uses carriage returns to overwrite the stats line in the terminal.
+func scanOutputLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
 	}
+
+	return 0, nil, nil
+}
+
+// OutputTail returns the most recent stdout/stderr output from ffmpeg, up to the
+// OutputTailSize configured.
+func (cmd *TranscodeCmd) OutputTail() string {
+	return cmd.output.String()
 }
 
 func (cmd *TranscodeCmd) Suspend() error {
@@ -0,0 +1,61 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseProgressLine(t *testing.T) {
+	line := "frame= 1250 fps= 48 q=28.0 size=    5120kB time=00:01:00.00 bitrate= 805.1kbits/s speed=2.01x"
+	progress := parseProgressLine(line, 240)
+	if assert.NotNil(t, progress) {
+		assert.Equal(t, "1250", progress.FramesProcessed)
+		assert.Equal(t, "00:01:00.00", progress.CurrentTime)
+		assert.Equal(t, "805.1kbits/s", progress.CurrentBitrate)
+		assert.Equal(t, "2.01x", progress.Speed)
+		assert.InDelta(t, 25.0, progress.Progress, 0.001)
+		assert.Equal(t, int64(5120*1024), progress.OutputSize)
+	}
+
+	assert.Nil(t, parseProgressLine("Stream mapping:", 240))
+}
+
+func Test_OutputTail(t *testing.T) {
+	tail := newOutputTail(16)
+	tail.write("first", false)
+	tail.write("frame= 1", true)
+	tail.write("frame= 2", true)
+	assert.Equal(t, "first\nframe= 2", tail.String())
+
+	// Oldest lines are evicted once the limit is exceeded
+	tail.write("error!", false)
+	assert.Equal(t, "frame= 2\nerror!", tail.String())
+}
+
+func Test_FormatSeconds(t *testing.T) {
+	assert.Equal(t, "0.000", formatSeconds(0))
+	assert.Equal(t, "90.500", formatSeconds(90*time.Second+500*time.Millisecond))
+}
+
+func Test_ProgressEstimate(t *testing.T) {
+	progress := &Progress{FramesProcessed: "1200", Progress: 25}
+	progress.estimate(time.Minute)
+
+	assert.InDelta(t, 20.0, progress.AverageFps, 0.001)
+	if assert.NotNil(t, progress.EtaSeconds) {
+		assert.Equal(t, int64(180), *progress.EtaSeconds)
+	}
+
+	pending := &Progress{FramesProcessed: "0"}
+	pending.estimate(time.Second)
+	assert.Nil(t, pending.EtaSeconds)
+}
+
+func Test_ParseSize(t *testing.T) {
+	assert.Equal(t, int64(2048), parseSize("2kB"))
+	assert.Equal(t, int64(2048), parseSize("2KiB"))
+	assert.Equal(t, int64(3*1024*1024), parseSize("3MiB"))
+	assert.Equal(t, int64(0), parseSize("N/A"))
+}
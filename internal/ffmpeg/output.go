@@ -0,0 +1,62 @@
+package ffmpeg
+
+import (
+	"strings"
+	"sync"
+)
+
+// outputTail retains the most recent lines of output from an ffmpeg command, up to
+// a maximum number of bytes. Consecutive progress (stats) lines are collapsed in to
+// the most recent one, as ffmpeg emits these continuously and they would otherwise
+// quickly evict the more useful diagnostic output.
+type outputTail struct {
+	mutex        sync.Mutex
+	lines        []string
+	size         int
+	limit        int
+	lastProgress bool
+}
+
+func newOutputTail(limit int) *outputTail {
+	return &outputTail{lines: make([]string, 0), limit: limit}
+}
+
+func (tail *outputTail) write(line string, isProgress bool) {
+	tail.mutex.Lock()
+	defer tail.mutex.Unlock()
+
+	if isProgress && tail.lastProgress && len(tail.lines) > 0 {
+		last := len(tail.lines) - 1
+		tail.size -= len(tail.lines[last])
+		tail.lines = tail.lines[:last]
+	}
+
+	tail.lines = append(tail.lines, line)
+	tail.size += len(line)
+	tail.lastProgress = isProgress
+
+	for tail.size > tail.limit && len(tail.lines) > 1 {
+		tail.size -= len(tail.lines[0])
+		tail.lines = tail.lines[1:]
+	}
+}
+
+// Write implements io.Writer, so that the tail can be used as the destination
+// of a command's output. Each line written is retained as-is.
+func (tail *outputTail) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(p), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			tail.write(line, false)
+		}
+	}
+
+	return len(p), nil
+}
+
+// String returns the retained output, oldest line first.
+func (tail *outputTail) String() string {
+	tail.mutex.Lock()
+	defer tail.mutex.Unlock()
+
+	return strings.Join(tail.lines, "\n")
+}
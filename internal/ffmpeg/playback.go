@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+type (
+	// PlaybackSource describes the streams and overall bitrate of a source, as reported by
+	// ffprobe, which are used to decide how the source is played to a user.
+	PlaybackSource struct {
+		Audio     []AudioStream
+		Subtitles []SubtitleStream
+
+		// BitrateKbps is the overall bitrate of the source in kilobits
+		// per second, or zero if the bitrate could not be determined.
+		BitrateKbps int
+	}
+
+	// SubtitleStream describes a subtitle stream of a source. The Language is the (normalized)
+	// language tag of the stream, and is empty if the stream is not tagged.
+	SubtitleStream struct {
+		Index    int
+		Codec    string
+		Language string
+		Forced   bool
+	}
+)
+
+// ProbePlaybackSource probes the audio and subtitle streams, and the bitrate, of the media file at the path provided.
+func ProbePlaybackSource(path string, probePath string) (*PlaybackSource, error) {
+	out, err := exec.Command(probePath, //nolint:gosec
+		"-v", "error", "-of", "json",
+		"-show_entries", "stream=index,codec_name,codec_type:stream_tags=language:stream_disposition=default,forced:format=bit_rate",
+		path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe playback source %s using ffprobe: %w", path, err)
+	}
+
+	return parsePlaybackSource(out)
+}
+
+// AllowsDirectPlay returns true if the source can be streamed directly without exceeding the maximum
+// bitrate (in kbps) provided. Sources whose bitrate is unknown, or which are streamed without a maximum
+// bitrate (nil), are always allowed.
+func (source *PlaybackSource) AllowsDirectPlay(maxBitrateKbps *int) bool {
+	return maxBitrateKbps == nil || source.BitrateKbps == 0 || source.BitrateKbps <= *maxBitrateKbps
+}
+
+// SelectSubtitleStream returns the subtitle stream using the language provided, preferring full subtitles
+// over forced subtitles (which only cover foreign dialogue). Unlike audio, no subtitle stream is selected
+// if none use the language, and so nil is returned.
+func SelectSubtitleStream(streams []SubtitleStream, language string) *SubtitleStream {
+	language = normalizeLanguage(language)
+
+	var selected *SubtitleStream
+	for k, stream := range streams {
+		if language == "" || stream.Language != language {
+			continue
+		}
+		if selected == nil || (selected.Forced && !stream.Forced) {
+			selected = &streams[k]
+		}
+	}
+
+	return selected
+}
+
+// parsePlaybackSource parses the JSON output of ffprobe, listing the streams and format of a source.
+func parsePlaybackSource(out []byte) (*PlaybackSource, error) {
+	var probed struct {
+		Streams []struct {
+			Index       int               `json:"index"`
+			CodecName   string            `json:"codec_name"`
+			CodecType   string            `json:"codec_type"`
+			Tags        map[string]string `json:"tags"`
+			Disposition map[string]int    `json:"disposition"`
+		} `json:"streams"`
+		Format struct {
+			BitRate string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probed); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	source := &PlaybackSource{Audio: make([]AudioStream, 0), Subtitles: make([]SubtitleStream, 0)}
+	if bitrate, err := strconv.Atoi(probed.Format.BitRate); err == nil {
+		source.BitrateKbps = bitrate / 1000
+	}
+	for _, stream := range probed.Streams {
+		switch stream.CodecType {
+		case "audio":
+			source.Audio = append(source.Audio, AudioStream{
+				Index:    stream.Index,
+				Codec:    stream.CodecName,
+				Language: normalizeLanguage(stream.Tags["language"]),
+				Default:  stream.Disposition["default"] == 1,
+			})
+		case "subtitle":
+			source.Subtitles = append(source.Subtitles, SubtitleStream{
+				Index:    stream.Index,
+				Codec:    stream.CodecName,
+				Language: normalizeLanguage(stream.Tags["language"]),
+				Forced:   stream.Disposition["forced"] == 1,
+			})
+		}
+	}
+
+	return source, nil
+}
@@ -0,0 +1,137 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// I/O scheduling classes, see ioprio_set(2).
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var ioClasses = map[string]int{IOClassRealtime: 1, IOClassBestEffort: 2, IOClassIdle: 3}
+
+// Priority is the CPU and I/O scheduling priority ffmpeg processes are run with, so that transcodes
+// do not starve other processes on the host (such as streaming sessions) of CPU time or disk access.
+//
+// If a CPU weight is provided, and the cgroup v2 directory provided to Apply has the cpu controller
+// available, the process is placed in it's own child cgroup with that weight. Otherwise, the niceness
+// of the process is used. Zero values leave the priority of the process unchanged.
+type Priority struct {
+	// Nice is the niceness of the process, from -20 (highest priority) to 19 (lowest).
+	Nice int
+
+	// IOClass is the I/O scheduling class of the process (one of IOClassRealtime, IOClassBestEffort
+	// or IOClassIdle), and IOLevel is the priority within that class, from 0 (highest) to 7 (lowest).
+	IOClass string
+	IOLevel int
+
+	// CPUWeight is the cgroup v2 cpu.weight of the process, from 1 to 10000 (default 100).
+	CPUWeight int
+}
+
+// Validate returns an error if any of the values of the priority are out of range.
+func (priority Priority) Validate() error {
+	if priority.Nice < -20 || priority.Nice > 19 {
+		return fmt.Errorf("nice value %d must be between -20 and 19", priority.Nice)
+	}
+	if _, ok := ioClasses[priority.IOClass]; priority.IOClass != "" && !ok {
+		return fmt.Errorf("io class '%s' must be one of '%s', '%s' or '%s'", priority.IOClass, IOClassRealtime, IOClassBestEffort, IOClassIdle)
+	}
+	if priority.IOLevel < 0 || priority.IOLevel > 7 {
+		return fmt.Errorf("io level %d must be between 0 and 7", priority.IOLevel)
+	}
+	if priority.CPUWeight < 0 || priority.CPUWeight > 10000 {
+		return fmt.Errorf("cpu weight %d must be between 1 and 10000", priority.CPUWeight)
+	}
+
+	return nil
+}
+
+// Apply applies the priority to the process with the PID provided. This must be called as soon as the
+// process has started, as the threads ffmpeg spawns to encode inherit the priority of the main thread
+// when they're created. The function returned removes the cgroup created for the process (if any),
+// and must be called once the process has exited.
+func (priority Priority) Apply(pid int, cgroup string) (func(), error) {
+	release := func() {}
+
+	var errs []error
+	placed := false
+	if priority.CPUWeight > 0 && cgroup != "" {
+		if path, err := placeInCgroup(pid, cgroup, priority.CPUWeight); err == nil {
+			placed = true
+			release = func() {
+				if err := os.Remove(path); err != nil {
+					log.Warnf("Failed to remove cgroup %s of ffmpeg process %d: %v\n", path, pid, err)
+				}
+			}
+		} else {
+			errs = append(errs, fmt.Errorf("failed to set cpu weight (falling back to niceness): %w", err))
+		}
+	}
+
+	if priority.Nice != 0 && !placed {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, priority.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set niceness: %w", err))
+		}
+	}
+
+	if class, ok := ioClasses[priority.IOClass]; ok {
+		prio := class<<ioprioClassShift | priority.IOLevel
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+			errs = append(errs, fmt.Errorf("failed to set io priority: %w", errno))
+		}
+	}
+
+	return release, errors.Join(errs...)
+}
+
+// placeInCgroup creates a child of the cgroup v2 directory provided for the process with the PID given,
+// and moves the process in to it using the CPU weight provided. The cgroup must have been delegated
+// to Thea (i.e. be writable by Thea), and must have the cpu controller available.
+func placeInCgroup(pid int, cgroup string, weight int) (string, error) {
+	controllers, err := os.ReadFile(filepath.Join(cgroup, "cgroup.controllers"))
+	if err != nil {
+		return "", fmt.Errorf("cgroup v2 is not available at %s: %w", cgroup, err)
+	}
+	if !slices.Contains(strings.Fields(string(controllers)), "cpu") {
+		return "", fmt.Errorf("cpu controller is not available in cgroup %s", cgroup)
+	}
+
+	// The cpu controller must be enabled for the children of the cgroup, which may already be the case
+	if err := os.WriteFile(filepath.Join(cgroup, "cgroup.subtree_control"), []byte("+cpu"), 0o644); err != nil {
+		log.Emit(logger.DEBUG, "Unable to enable cpu controller for children of cgroup %s: %v\n", cgroup, err)
+	}
+
+	path := filepath.Join(cgroup, "ffmpeg-"+strconv.Itoa(pid))
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(path, "cpu.weight"), []byte(strconv.Itoa(weight)), 0o644); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0o644); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
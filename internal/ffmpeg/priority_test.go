@@ -0,0 +1,16 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Priority_Validate(t *testing.T) {
+	assert.NoError(t, Priority{}.Validate())
+	assert.NoError(t, Priority{Nice: 10, IOClass: IOClassBestEffort, IOLevel: 7, CPUWeight: 50}.Validate())
+	assert.Error(t, Priority{Nice: 20}.Validate())
+	assert.Error(t, Priority{IOClass: "background"}.Validate())
+	assert.Error(t, Priority{IOClass: IOClassIdle, IOLevel: 8}.Validate())
+	assert.Error(t, Priority{CPUWeight: 10001}.Validate())
+}
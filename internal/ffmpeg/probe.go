@@ -2,6 +2,8 @@ package ffmpeg
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/floostack/transcoder"
 	"github.com/floostack/transcoder/ffmpeg"
@@ -16,3 +18,18 @@ func ProbeFile(path string, probePath string) (transcoder.Metadata, error) {
 
 	return metadata, nil
 }
+
+// ProbeDuration returns the duration of the media file at the path provided.
+func ProbeDuration(path string, probePath string) (time.Duration, error) {
+	metadata, err := ProbeFile(path, probePath)
+	if err != nil {
+		return 0, err
+	}
+
+	seconds, err := strconv.ParseFloat(metadata.GetFormat().GetDuration(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration of %s: %w", path, err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,93 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/floostack/transcoder"
+)
+
+var ErrProbeTimeout = errors.New("ffprobe did not complete before the timeout")
+
+type (
+	// ProbePool bounds the number of ffprobe processes which may run concurrently, so
+	// that discovering a directory containing thousands of files does not spawn a process
+	// for each. A probe which exceeds the timeout is abandoned (and ErrProbeTimeout returned),
+	// however it continues to occupy it's slot until the ffprobe process exits so
+	// that the number of processes remains bounded.
+	ProbePool struct {
+		probePath string
+		timeout   time.Duration
+		slots     chan struct{}
+		waiting   atomic.Int64
+		timeouts  atomic.Int64
+	}
+
+	ProbePoolStats struct {
+		Size     int
+		Running  int
+		Waiting  int
+		Timeouts int64
+	}
+
+	probeResult struct {
+		metadata transcoder.Metadata
+		err      error
+	}
+)
+
+// NewProbePool creates a pool which runs at most size ffprobe processes at once. If
+// the timeout is zero, probes are never abandoned.
+func NewProbePool(probePath string, size int, timeout time.Duration) *ProbePool {
+	return &ProbePool{probePath: probePath, timeout: timeout, slots: make(chan struct{}, max(size, 1))}
+}
+
+// Probe waits for a slot in the pool to become available, and then probes the file at
+// the path provided (see ProbeFile). If the context is cancelled while waiting, or while
+// the probe is running, the context error is returned.
+func (pool *ProbePool) Probe(ctx context.Context, path string) (transcoder.Metadata, error) {
+	pool.waiting.Add(1)
+	select {
+	case pool.slots <- struct{}{}:
+		pool.waiting.Add(-1)
+	case <-ctx.Done():
+		pool.waiting.Add(-1)
+		return nil, ctx.Err()
+	}
+
+	done := make(chan probeResult, 1)
+	go func() {
+		defer func() { <-pool.slots }()
+
+		metadata, err := ProbeFile(path, pool.probePath)
+		done <- probeResult{metadata, err}
+	}()
+
+	var timeout <-chan time.Time
+	if pool.timeout > 0 {
+		timer := time.NewTimer(pool.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case result := <-done:
+		return result.metadata, result.err
+	case <-timeout:
+		pool.timeouts.Add(1)
+		return nil, ErrProbeTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (pool *ProbePool) Stats() ProbePoolStats {
+	return ProbePoolStats{
+		Size:     cap(pool.slots),
+		Running:  len(pool.slots),
+		Waiting:  int(pool.waiting.Load()),
+		Timeouts: pool.timeouts.Load(),
+	}
+}
@@ -0,0 +1,142 @@
+package ffmpeg
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+
+	"github.com/floostack/transcoder"
+)
+
+// ErrExtensionUnsupported is returned when a target outputs to a container which is not supported.
+var ErrExtensionUnsupported = errors.New("target extension is not supported")
+
+var (
+	// containerExtensions are the extensions of the containers which targets may output to.
+	containerExtensions = []string{"mp4", "mov", "mkv", "webm"}
+
+	// containerSubtitleCodecs maps the extensions of the containers which cannot store every subtitle
+	// codec to the codec text-based subtitles are converted to when remuxing in to the container. Image-based
+	// subtitles (e.g. PGS) cannot be converted, and so are dropped when remuxing in to these containers.
+	containerSubtitleCodecs = map[string]string{"mp4": "mov_text", "mov": "mov_text", "webm": "webvtt"}
+
+	// textSubtitleCodecs are the subtitle codecs which can be converted to other text-based subtitle codecs.
+	textSubtitleCodecs = []string{"subrip", "srt", "ass", "ssa", "mov_text", "webvtt", "text"}
+
+	// attachedPictureCodecs are the codecs of 'video' streams which are typically cover art
+	// attached to the source, rather than video, and so are not copied when remuxing.
+	attachedPictureCodecs = []string{"mjpeg", "png", "bmp", "gif"}
+
+	// fastStartExtensions are the extensions of containers which support moving their index
+	// to the start of the file, so that playback can begin before the file is fully downloaded.
+	fastStartExtensions = []string{"mp4", "mov"}
+)
+
+// RemuxOpts are the ffmpeg options used to remux a source in to another container, copying
+// (rather than re-encoding) the streams of the source which the container is able to store.
+type RemuxOpts struct {
+	// Streams are the indices of the source streams copied to the output. If nil, the
+	// streams of the source are unknown, and so all video and audio streams are copied.
+	Streams []int
+
+	// SubtitleCodec is the codec the subtitle streams are converted to, or
+	// empty if the subtitle streams are copied without conversion.
+	SubtitleCodec string
+
+	// FastStart moves the index of the output to the start of the file.
+	FastStart bool
+
+	// DefaultAudio marks the first audio stream of the output as the default audio
+	// stream, as it's the stream selected using the language preferences of the target.
+	DefaultAudio bool
+}
+
+// SupportsExtension returns true if targets can output to the container with the extension provided.
+func SupportsExtension(ext string) bool {
+	return slices.Contains(containerExtensions, ext)
+}
+
+// RemuxOptions returns the options used to remux a source (described by the metadata provided) in to the
+// container with the extension given. All video (excluding attached pictures) and audio streams are copied,
+// and subtitle streams are copied or converted depending on what the container supports. If an audio stream
+// is provided (see SelectAudioStream), it's placed before the other audio streams and marked as the default.
+// If the metadata is nil, the subtitles of the source are not copied, and the audio stream provided is not
+// preferred, as the streams of the source cannot be determined.
+func RemuxOptions(metadata transcoder.Metadata, ext string, audio *AudioStream) *RemuxOpts {
+	opts := &RemuxOpts{FastStart: slices.Contains(fastStartExtensions, ext)}
+	if metadata == nil {
+		return opts
+	}
+
+	subtitleCodec, convertSubtitles := containerSubtitleCodecs[ext]
+	opts.Streams = make([]int, 0)
+	firstAudio := -1
+	for _, stream := range metadata.GetStreams() {
+		switch stream.GetCodecType() {
+		case "video":
+			if slices.Contains(attachedPictureCodecs, stream.GetCodecName()) {
+				continue
+			}
+		case "audio":
+			if audio != nil && stream.GetIndex() == audio.Index {
+				opts.DefaultAudio = true
+				if firstAudio >= 0 {
+					opts.Streams = slices.Insert(opts.Streams, firstAudio, stream.GetIndex())
+					continue
+				}
+			}
+			if firstAudio < 0 {
+				firstAudio = len(opts.Streams)
+			}
+		case "subtitle":
+			if convertSubtitles && !slices.Contains(textSubtitleCodecs, stream.GetCodecName()) {
+				continue
+			}
+		default:
+			continue
+		}
+
+		opts.Streams = append(opts.Streams, stream.GetIndex())
+	}
+	if convertSubtitles {
+		opts.SubtitleCodec = subtitleCodec
+	}
+
+	return opts
+}
+
+// ProbeRemuxOptions probes the source at the path provided, and returns the options used to remux
+// it in to the container with the extension given (see RemuxOptions). If the source cannot be probed,
+// the video and audio streams of the source are copied without subtitles.
+func ProbeRemuxOptions(sourcePath string, probePath string, ext string, audio *AudioStream) *RemuxOpts {
+	metadata, err := ProbeFile(sourcePath, probePath)
+	if err != nil {
+		log.Warnf("Unable to probe streams of %s, subtitles will not be remuxed: %v\n", sourcePath, err)
+		return RemuxOptions(nil, ext, audio)
+	}
+
+	return RemuxOptions(metadata, ext, audio)
+}
+
+func (opts *RemuxOpts) GetStrArguments() []string {
+	args := make([]string, 0)
+	if opts.Streams == nil {
+		args = append(args, "-map", "0:V?", "-map", "0:a?")
+	}
+	for _, index := range opts.Streams {
+		args = append(args, "-map", "0:"+strconv.Itoa(index))
+	}
+
+	args = append(args, "-c", "copy")
+	if opts.SubtitleCodec != "" {
+		args = append(args, "-c:s", opts.SubtitleCodec)
+	}
+	if opts.DefaultAudio {
+		args = append(args, "-disposition:a", "0", "-disposition:a:0", "default")
+	}
+	if opts.FastStart {
+		args = append(args, "-movflags", "+faststart")
+	}
+
+	return args
+}
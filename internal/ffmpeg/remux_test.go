@@ -0,0 +1,82 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	probe "github.com/floostack/transcoder/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RemuxOptions(t *testing.T) {
+	metadata := probe.Metadata{Streams: []probe.Streams{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "audio", CodecName: "eac3"},
+		{Index: 2, CodecType: "audio", CodecName: "aac"},
+		{Index: 3, CodecType: "subtitle", CodecName: "subrip"},
+		{Index: 4, CodecType: "subtitle", CodecName: "hdmv_pgs_subtitle"},
+		{Index: 5, CodecType: "video", CodecName: "mjpeg"},
+		{Index: 6, CodecType: "attachment", CodecName: "ttf"},
+	}}
+
+	t.Run("MP4 converts text subtitles and uses fast-start", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"-map", "0:0", "-map", "0:1", "-map", "0:2", "-map", "0:3", "-c", "copy", "-c:s", "mov_text", "-movflags", "+faststart"},
+			RemuxOptions(metadata, "mp4", nil).GetStrArguments(),
+		)
+	})
+
+	t.Run("MKV copies all subtitles", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"-map", "0:0", "-map", "0:1", "-map", "0:2", "-map", "0:3", "-map", "0:4", "-c", "copy"},
+			RemuxOptions(metadata, "mkv", nil).GetStrArguments(),
+		)
+	})
+
+	t.Run("Selected audio stream is placed first and marked default", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"-map", "0:0", "-map", "0:2", "-map", "0:1", "-map", "0:3", "-map", "0:4", "-c", "copy", "-disposition:a", "0", "-disposition:a:0", "default"},
+			RemuxOptions(metadata, "mkv", &AudioStream{Index: 2}).GetStrArguments(),
+		)
+	})
+
+	t.Run("Unknown streams copy video and audio only", func(t *testing.T) {
+		assert.Equal(t,
+			[]string{"-map", "0:V?", "-map", "0:a?", "-c", "copy", "-movflags", "+faststart"},
+			RemuxOptions(nil, "mp4", nil).GetStrArguments(),
+		)
+	})
+}
+
+func Test_CheckCompliance_RemuxOnly(t *testing.T) {
+	target := &Target{Ext: "mp4", RemuxOnly: true}
+	assert.Equal(t, RemuxCompliant, CheckCompliance(probe.Metadata{}, "/media/movie.mkv", target))
+	assert.Equal(t, Compliant, CheckCompliance(probe.Metadata{}, "/media/movie.MP4", target))
+
+	target.AudioLanguages = []string{"jpn"}
+	assert.Equal(t, RemuxCompliant, CheckCompliance(probe.Metadata{}, "/media/movie.mp4", target), "audio preferences require remuxing")
+}
+
+func Test_SupportsExtension(t *testing.T) {
+	assert.True(t, SupportsExtension("mkv"))
+	assert.False(t, SupportsExtension("avi"))
+}
+
+func Test_TargetBitrateKbps(t *testing.T) {
+	bitrate := func(b string) *Target { return &Target{FfmpegOptions: &Opts{VideoBitRate: &b}} }
+	maxrate := 6_000_000
+
+	for input, expected := range map[string]int{"2500k": 2500, "5M": 5000, "800000": 800, "1.5M": 1500} {
+		kbps, ok := bitrate(input).BitrateKbps()
+		assert.True(t, ok, input)
+		assert.Equal(t, expected, kbps, input)
+	}
+
+	kbps, ok := (&Target{FfmpegOptions: &Opts{VideoMaxBitRate: &maxrate}}).BitrateKbps()
+	assert.True(t, ok)
+	assert.Equal(t, 6000, kbps)
+
+	_, ok = (&Target{FfmpegOptions: &Opts{}}).BitrateKbps()
+	assert.False(t, ok)
+	_, ok = (&Target{RemuxOnly: true, FfmpegOptions: &Opts{VideoMaxBitRate: &maxrate}}).BitrateKbps()
+	assert.False(t, ok)
+}
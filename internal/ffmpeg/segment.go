@@ -0,0 +1,248 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/floostack/transcoder"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// segmentableVideoCodecs are the video encoders whose output can be losslessly concatenated when
+// the input is encoded as separate time segments. Stream copies are excluded, as the input can
+// only be split on it's keyframes, which would leave gaps/overlaps between the segments.
+var segmentableVideoCodecs = []string{
+	"libx264", "libx265", "libvpx-vp9", "libaom-av1", "libsvtav1",
+	"h264_nvenc", "hevc_nvenc", "h264_qsv", "hevc_qsv", "h264_vaapi", "hevc_vaapi",
+}
+
+// SupportsSegmentation returns true if the target provided can be transcoded in segments (see
+// SegmentedCmd). Targets which do not specify a video encoder, or whose encoder cannot be
+// segmented, must be transcoded in a single pass.
+func SupportsSegmentation(target *Target) bool {
+	if target.FfmpegOptions == nil || target.FfmpegOptions.VideoCodec == nil {
+		return false
+	}
+
+	return slices.Contains(segmentableVideoCodecs, *target.FfmpegOptions.VideoCodec)
+}
+
+// SegmentedCmd transcodes an input by splitting it in to time segments which are encoded in
+// parallel, before concatenating the encoded segments (without re-encoding) in to the output.
+// The progress of each segment is aggregated in to the progress of the command as a whole.
+//
+// The segments are written to a hidden directory alongside the output, which is removed
+// once the command exits.
+type SegmentedCmd struct {
+	inputPath       string
+	outputPath      string
+	duration        time.Duration
+	transcodeConfig Config
+	segments        []*TranscodeCmd
+	concatOutput    *outputTail
+
+	progressMutex sync.Mutex
+	progress      []*Progress
+	startedAt     time.Time
+}
+
+// NewSegmentedCmd creates a command which transcodes the input (of the duration provided)
+// as the given number of segments, each of which are encoded in parallel.
+func NewSegmentedCmd(input string, output string, config Config, duration time.Duration, segmentCount int) *SegmentedCmd {
+	cmd := &SegmentedCmd{
+		inputPath:       input,
+		outputPath:      output,
+		duration:        duration,
+		transcodeConfig: config,
+		segments:        make([]*TranscodeCmd, segmentCount),
+		progress:        make([]*Progress, segmentCount),
+		concatOutput:    newOutputTail(defaultOutputTailSize),
+	}
+
+	segmentLength := duration / time.Duration(segmentCount)
+	for k := range cmd.segments {
+		offset := segmentLength * time.Duration(k)
+		length := segmentLength
+		if k == segmentCount-1 {
+			// The final segment absorbs any rounding of the segment length
+			length = duration - offset
+		}
+
+		cmd.segments[k] = NewSampleCmd(input, cmd.segmentPath(k), config, offset, length)
+	}
+
+	return cmd
+}
+
+func (cmd *SegmentedCmd) Run(ctx context.Context, ffmpegConfig transcoder.Options, updateHandler func(*Progress)) error {
+	segmentDir := cmd.segmentDirectory()
+	if err := os.MkdirAll(segmentDir, 0o777); err != nil {
+		return fmt.Errorf("failed to create segment directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(segmentDir); err != nil {
+			log.Warnf("Failed to remove segment directory %s: %v\n", segmentDir, err)
+		}
+	}()
+
+	log.Emit(logger.DEBUG, "Transcoding %s in %d segments\n", cmd.inputPath, len(cmd.segments))
+	cmd.startedAt = time.Now()
+	// If any segment fails, the remaining segments are cancelled
+	segmentCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	errs := make([]error, len(cmd.segments))
+	for k, segment := range cmd.segments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := segment.Run(segmentCtx, ffmpegConfig, func(progress *Progress) {
+				updateHandler(cmd.recordProgress(k, progress))
+			})
+			if err != nil {
+				errs[k] = fmt.Errorf("segment %d: %w", k, err)
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	return cmd.concat(ctx)
+}
+
+// concat losslessly concatenates the encoded segments in to the output.
+func (cmd *SegmentedCmd) concat(ctx context.Context) error {
+	var list strings.Builder
+	for k := range cmd.segments {
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(cmd.segmentPath(k), "'", `'\''`))
+	}
+
+	listPath := filepath.Join(cmd.segmentDirectory(), "segments.txt")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write segment list: %w", err)
+	}
+
+	concatCommand := exec.CommandContext(ctx, cmd.transcodeConfig.FfmpegBinPath, //nolint:gosec
+		"-hide_banner", "-y", "-f", "concat", "-safe", "0", "-i", listPath, "-map", "0", "-c", "copy", cmd.outputPath)
+	concatCommand.Stdout = cmd.concatOutput
+	concatCommand.Stderr = cmd.concatOutput
+	if err := concatCommand.Run(); err != nil {
+		return fmt.Errorf("ffmpeg failed to concatenate segments: %w", err)
+	}
+
+	return nil
+}
+
+// recordProgress records the progress of the segment with the index provided, returning the
+// aggregated progress of all segments. The progress percentage is weighted by the length of
+// each segment, and the speed and output size are the totals of all the segments.
+func (cmd *SegmentedCmd) recordProgress(index int, progress *Progress) *Progress {
+	cmd.progressMutex.Lock()
+	defer cmd.progressMutex.Unlock()
+
+	cmd.progress[index] = progress
+
+	var frames int
+	var size int64
+	var seconds, percentage, speed float64
+	for k, p := range cmd.progress {
+		if p == nil {
+			continue
+		}
+
+		segmentFrames, _ := strconv.Atoi(p.FramesProcessed)
+		segmentSpeed, _ := strconv.ParseFloat(strings.TrimSuffix(p.Speed, "x"), 64)
+		frames += segmentFrames
+		size += p.OutputSize
+		speed += segmentSpeed
+		seconds += durationToSeconds(p.CurrentTime)
+		if cmd.duration > 0 {
+			percentage += p.Progress * cmd.segments[k].length.Seconds() / cmd.duration.Seconds()
+		}
+	}
+
+	aggregated := &Progress{
+		FramesProcessed: strconv.Itoa(frames),
+		CurrentTime:     formatTimestamp(seconds),
+		CurrentBitrate:  progress.CurrentBitrate,
+		Progress:        min(100, percentage),
+		Speed:           fmt.Sprintf("%.2fx", speed),
+		OutputSize:      size,
+	}
+	aggregated.estimate(time.Since(cmd.startedAt))
+
+	return aggregated
+}
+
+// OutputTail returns the output tail of each segment, followed by the
+// output of the concatenation of the segments.
+func (cmd *SegmentedCmd) OutputTail() string {
+	var out strings.Builder
+	for k, segment := range cmd.segments {
+		fmt.Fprintf(&out, "--- segment %d ---\n%s\n", k, segment.OutputTail())
+	}
+	fmt.Fprintf(&out, "--- concat ---\n%s", cmd.concatOutput.String())
+
+	return out.String()
+}
+
+// Suspend suspends all of the running segments.
+func (cmd *SegmentedCmd) Suspend() error {
+	return cmd.signalSegments((*TranscodeCmd).Suspend)
+}
+
+// Continue resumes all of the suspended segments.
+func (cmd *SegmentedCmd) Continue() error {
+	return cmd.signalSegments((*TranscodeCmd).Continue)
+}
+
+// signalSegments applies the signal provided to all segments which have been started.
+func (cmd *SegmentedCmd) signalSegments(signal func(*TranscodeCmd) error) error {
+	errs := make([]error, 0)
+	for k, segment := range cmd.segments {
+		if segment.RunningCommand() == nil {
+			continue
+		}
+
+		if err := signal(segment); err != nil {
+			errs = append(errs, fmt.Errorf("segment %d: %w", k, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// segmentDirectory returns the hidden directory, alongside the output, which the segments are written to.
+func (cmd *SegmentedCmd) segmentDirectory() string {
+	return filepath.Join(filepath.Dir(cmd.outputPath), fmt.Sprintf(".%s.segments", filepath.Base(cmd.outputPath)))
+}
+
+func (cmd *SegmentedCmd) segmentPath(index int) string {
+	return filepath.Join(cmd.segmentDirectory(), fmt.Sprintf("%03d%s", index, filepath.Ext(cmd.outputPath)))
+}
+
+func (cmd *SegmentedCmd) String() string {
+	return fmt.Sprintf("{ffmpeg segments=%d | in_path=%s | out_path = %s}", len(cmd.segments), cmd.inputPath, cmd.outputPath)
+}
+
+// formatTimestamp formats the number of seconds provided as an ffmpeg timestamp (HH:MM:SS.ms).
+func formatTimestamp(seconds float64) string {
+	hours := int(seconds / 3600)
+	minutes := int(seconds/60) % 60
+	return fmt.Sprintf("%02d:%02d:%05.2f", hours, minutes, seconds-float64(hours*3600+minutes*60))
+}
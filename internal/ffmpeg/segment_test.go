@@ -0,0 +1,37 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SegmentedCmd_Segments(t *testing.T) {
+	cmd := NewSegmentedCmd("/in.mkv", "/out/a.mp4", Config{}, 100*time.Second, 3)
+	if assert.Len(t, cmd.segments, 3) {
+		assert.Equal(t, time.Duration(0), cmd.segments[0].offset)
+		assert.Equal(t, 100*time.Second/3, cmd.segments[1].length)
+		assert.Equal(t, 100*time.Second, cmd.segments[2].offset+cmd.segments[2].length)
+		assert.Equal(t, "/out/.a.mp4.segments/002.mp4", cmd.segments[2].OutputPath())
+	}
+}
+
+func Test_SegmentedCmd_RecordProgress(t *testing.T) {
+	cmd := NewSegmentedCmd("/in.mkv", "/out/a.mp4", Config{}, 100*time.Second, 2)
+	cmd.recordProgress(0, &Progress{FramesProcessed: "100", CurrentTime: "00:00:10.00", Speed: "1.5x", Progress: 20})
+	progress := cmd.recordProgress(1, &Progress{FramesProcessed: "50", CurrentTime: "00:00:05.00", Speed: "1.0x", Progress: 10})
+
+	assert.Equal(t, "150", progress.FramesProcessed)
+	assert.Equal(t, "00:00:15.00", progress.CurrentTime)
+	assert.Equal(t, "2.50x", progress.Speed)
+	assert.InDelta(t, 15.0, progress.Progress, 0.001)
+}
+
+func Test_SupportsSegmentation(t *testing.T) {
+	x264, copyCodec := "libx264", "copy"
+	assert.True(t, SupportsSegmentation(&Target{FfmpegOptions: &Opts{VideoCodec: &x264}}))
+	assert.False(t, SupportsSegmentation(&Target{FfmpegOptions: &Opts{VideoCodec: &copyCodec}}))
+	assert.False(t, SupportsSegmentation(&Target{FfmpegOptions: &Opts{}}))
+	assert.False(t, SupportsSegmentation(&Target{}))
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/database"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 const (
@@ -12,14 +13,29 @@ const (
 
 type Store struct{}
 
+// Save upserts the target provided. The benchmark of the target is not saved (see SaveBenchmark), and
+// the existing benchmark is discarded if the ffmpeg options of the target have changed.
 func (store *Store) Save(db database.Queryable, target *Target) error {
+	saved := *target
+	if saved.AudioLanguages == nil {
+		saved.AudioLanguages = pq.StringArray{}
+	}
+
 	_, err := db.NamedExec(`
-		INSERT INTO transcode_target(id, label, ffmpeg_options, extension)
-		VALUES (:id, :label, :ffmpeg_options, :extension)
+		INSERT INTO transcode_target(id, label, ffmpeg_options, extension, remux_only, audio_languages)
+		VALUES (:id, :label, :ffmpeg_options, :extension, :remux_only, :audio_languages)
 		ON CONFLICT(id) DO UPDATE
-		SET (label, ffmpeg_options, extension) = (EXCLUDED.label, EXCLUDED.ffmpeg_options, EXCLUDED.extension)
-	`, target)
+		SET (label, ffmpeg_options, extension, remux_only, audio_languages, benchmark) = (
+			EXCLUDED.label, EXCLUDED.ffmpeg_options, EXCLUDED.extension, EXCLUDED.remux_only, EXCLUDED.audio_languages,
+			CASE WHEN transcode_target.ffmpeg_options = EXCLUDED.ffmpeg_options THEN transcode_target.benchmark ELSE NULL END
+		)
+	`, &saved)
+
+	return err
+}
 
+func (store *Store) SaveBenchmark(db database.Queryable, targetID uuid.UUID, benchmark *TargetBenchmark) error {
+	_, err := db.Exec(`UPDATE transcode_target SET benchmark=$2 WHERE id=$1`, targetID, benchmark)
 	return err
 }
 
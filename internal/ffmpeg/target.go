@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/floostack/transcoder/ffmpeg"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type (
@@ -18,6 +21,20 @@ type (
 		// NB: These JSON struct tags are important! It's used when unmarhsalling the JSON coalesced rows from the DB
 		FfmpegOptions *Opts  `db:"ffmpeg_options" json:"ffmpeg_options"`
 		Ext           string `db:"extension" json:"extension"`
+
+		// RemuxOnly targets copy the streams of the source in to the container of the target
+		// without re-encoding them (see RemuxOptions), and so their ffmpeg options are ignored.
+		RemuxOnly bool `db:"remux_only" json:"remux_only"`
+
+		// AudioLanguages are the ISO 639-2 codes of the audio languages preferred by the target,
+		// from most to least preferred (e.g. ["jpn", "eng"]). If provided, only the audio stream
+		// of the source which best satisfies these preferences is transcoded, and it's marked as
+		// the default audio stream of the output (see SelectAudioStream).
+		AudioLanguages pq.StringArray `db:"audio_languages" json:"audio_languages"`
+
+		// Benchmark is the throughput measured for the target on this host, and is nil if
+		// the target has not been benchmarked since it's ffmpeg options were last changed.
+		Benchmark *TargetBenchmark `db:"benchmark" json:"benchmark"`
 	}
 
 	Opts ffmpeg.Options
@@ -88,4 +105,51 @@ func (target *Target) String() string {
 	return fmt.Sprintf("Target{ID=%s Label=%s}", target.ID, target.Label)
 }
 
-func (target *Target) RequiredThreads() int { return defaultThreads }
+// RequiredThreads returns the number of threads a transcode using the target is expected to
+// consume. This is estimated from the benchmark of the target, if it has been benchmarked. Remux-only
+// targets only consume a single thread, as they do not re-encode the source.
+func (target *Target) RequiredThreads() int {
+	if target.RemuxOnly {
+		return 1
+	}
+	if target.Benchmark == nil {
+		return defaultThreads
+	}
+
+	return target.Benchmark.EstimatedThreads()
+}
+
+// BitrateKbps returns the maximum (or otherwise, the target) video bitrate of the target in kilobits per
+// second. False is returned if the bitrate of the output cannot be determined from the ffmpeg options
+// of the target (e.g. for remux-only targets, or targets which use a constant quality).
+func (target *Target) BitrateKbps() (int, bool) {
+	if target.RemuxOnly || target.FfmpegOptions == nil {
+		return 0, false
+	}
+	if target.FfmpegOptions.VideoMaxBitRate != nil {
+		return *target.FfmpegOptions.VideoMaxBitRate / 1000, true
+	}
+	if target.FfmpegOptions.VideoBitRate != nil {
+		return parseBitrateKbps(*target.FfmpegOptions.VideoBitRate)
+	}
+
+	return 0, false
+}
+
+// parseBitrateKbps converts an ffmpeg bitrate (e.g. '2500k', '5M' or '800000') in to kilobits per second.
+func parseBitrateKbps(bitrate string) (int, bool) {
+	multiplier := 0.001
+	switch {
+	case strings.HasSuffix(bitrate, "k"), strings.HasSuffix(bitrate, "K"):
+		multiplier = 1
+	case strings.HasSuffix(bitrate, "M"):
+		multiplier = 1000
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimRight(bitrate, "kKM"), 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+
+	return int(value * multiplier), true
+}
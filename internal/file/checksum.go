@@ -0,0 +1,27 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Checksum returns the hex-encoded SHA-256 digest of the contents of the file
+// at the path provided. The file is streamed, so this is safe to use on large
+// media files, however it will take some time to complete for such files.
+func Checksum(path string) (string, error) {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s for checksum: %w", path, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s for checksum: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
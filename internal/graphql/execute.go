@@ -0,0 +1,573 @@
+// Package graphql implements a small GraphQL executor, supporting the subset of the language
+// used by Thea's clients: queries (with variables, aliases, fragments and the @skip/@include
+// directives) against a schema of objects whose fields are resolved by Go functions. Mutations,
+// subscriptions and introspection are not supported.
+//
+// Fields are resolved breadth-first: each field is resolved for every object at the same
+// depth of the response at once, allowing fields to batch the lookups they require (e.g.
+// fetching the transcodes of every episode in a season using a single query).
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+const defaultMaxDepth = 10
+
+type (
+	// Object is a GraphQL object type, with a set of fields which may be selected.
+	Object struct {
+		Name   string
+		Fields map[string]*Field
+	}
+
+	// Field is a field of an Object. Scalar fields (those with no Type) may resolve to any
+	// value which can be marshalled to JSON. Fields with a Type must resolve to a value (or
+	// a slice of values, if List is true) which is accepted by the fields of the Type.
+	//
+	// Exactly one of Resolve or BatchResolve must be provided.
+	Field struct {
+		Type *Object
+		List bool
+
+		// Resolve resolves the field for a single parent object.
+		Resolve func(ctx context.Context, parent any, args Args) (any, error)
+
+		// BatchResolve resolves the field for all the parent objects at the same depth in the
+		// response at once. The values returned must correspond to the parents provided.
+		BatchResolve func(ctx context.Context, parents []any, args Args) ([]any, error)
+	}
+
+	Args map[string]any
+
+	Schema struct {
+		Query *Object
+
+		// MaxDepth is the maximum depth of nested selection sets a query may
+		// contain. Defaults to 10.
+		MaxDepth int
+	}
+
+	Request struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+	}
+
+	Response struct {
+		Data   *OrderedMap `json:"data"`
+		Errors []*Error    `json:"errors,omitempty"`
+	}
+
+	Error struct {
+		Message string `json:"message"`
+		Path    []any  `json:"path,omitempty"`
+	}
+
+	// OrderedMap is a JSON object which retains the order of it's keys, as GraphQL
+	// responses must contain the fields in the order they were selected.
+	OrderedMap struct {
+		keys   []string
+		values map[string]any
+	}
+
+	execution struct {
+		ctx       context.Context
+		schema    *Schema
+		fragments map[string]*fragment
+		variables map[string]any
+		errors    []*Error
+	}
+
+	// collectedField is a field selected in a selection set. A field may be selected
+	// more than once (e.g. via fragments), in which case the selection sets are merged.
+	collectedField struct {
+		responseKey string
+		fields      []*field
+	}
+)
+
+// Execute parses and executes the query provided. Errors encountered while resolving fields are
+// included in the response (and the field is null), while errors which prevent the query
+// from being executed at all (e.g. syntax errors) result in a response with no data.
+func (schema *Schema) Execute(ctx context.Context, request Request) *Response {
+	doc, err := parse(request.Query)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	op, err := selectOperation(doc, request.OperationName)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if op.kind != "query" {
+		return errorResponse(fmt.Errorf("%s operations are not supported", op.kind))
+	}
+
+	variables, err := coerceVariables(op, request.Variables)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	exec := &execution{ctx: ctx, schema: schema, fragments: doc.fragments, variables: variables}
+	if err := exec.validate(schema.Query, op.selectionSet, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	data := exec.executeSelectionSet(schema.Query, []any{nil}, op.selectionSet, []any{})
+	return &Response{Data: data[0], Errors: exec.errors}
+}
+
+func errorResponse(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("operationName is required when the document contains multiple operations")
+		}
+
+		return doc.operations[0], nil
+	}
+
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown operation '%s'", name)
+}
+
+func coerceVariables(op *operation, provided map[string]any) (map[string]any, error) {
+	variables := make(map[string]any, len(op.variables))
+	for _, def := range op.variables {
+		if v, ok := provided[def.name]; ok && v != nil {
+			variables[def.name] = v
+			continue
+		}
+
+		if def.defaultValue != nil {
+			v, err := def.defaultValue.resolve(nil)
+			if err != nil {
+				return nil, err
+			}
+			variables[def.name] = v
+		} else if def.nonNull {
+			return nil, fmt.Errorf("variable '$%s' is required", def.name)
+		}
+	}
+
+	return variables, nil
+}
+
+// validate ensures that all the fields selected exist, that selection sets are provided for
+// (and only for) object fields, and that the query does not exceed the maximum depth.
+func (exec *execution) validate(obj *Object, set []selection, depth int) error {
+	maxDepth := exec.schema.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+	if depth > maxDepth {
+		return fmt.Errorf("query exceeds the maximum depth of %d", maxDepth)
+	}
+
+	fields, err := exec.collectFields(obj, set, map[string]struct{}{})
+	if err != nil {
+		return err
+	}
+
+	for _, collected := range fields {
+		f := collected.fields[0]
+		if f.name == "__typename" {
+			continue
+		}
+
+		def, ok := obj.Fields[f.name]
+		if !ok {
+			return fmt.Errorf("cannot query field '%s' on type '%s'", f.name, obj.Name)
+		}
+
+		if def.Type == nil {
+			if len(f.selectionSet) > 0 {
+				return fmt.Errorf("field '%s' on type '%s' is a scalar, and cannot have a selection set", f.name, obj.Name)
+			}
+			continue
+		}
+
+		childSet := mergeSelectionSets(collected.fields)
+		if len(childSet) == 0 {
+			return fmt.Errorf("field '%s' on type '%s' must have a selection set", f.name, obj.Name)
+		}
+		if err := exec.validate(def.Type, childSet, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// executeSelectionSet resolves the selection set provided for each of the parent objects.
+func (exec *execution) executeSelectionSet(obj *Object, parents []any, set []selection, path []any) []*OrderedMap {
+	results := make([]*OrderedMap, len(parents))
+	for k := range results {
+		results[k] = newOrderedMap()
+	}
+
+	// Fields have already been validated, so collecting them again cannot fail
+	fields, _ := exec.collectFields(obj, set, map[string]struct{}{})
+	for _, collected := range fields {
+		f := collected.fields[0]
+		fieldPath := append(append([]any{}, path...), collected.responseKey)
+		if f.name == "__typename" {
+			for _, result := range results {
+				result.Set(collected.responseKey, obj.Name)
+			}
+			continue
+		}
+
+		def := obj.Fields[f.name]
+		values, err := exec.resolveField(def, parents, f)
+		if err != nil {
+			exec.errors = append(exec.errors, &Error{Message: err.Error(), Path: fieldPath})
+			for _, result := range results {
+				result.Set(collected.responseKey, nil)
+			}
+			continue
+		}
+
+		if def.Type != nil {
+			values = exec.completeObjects(def, values, mergeSelectionSets(collected.fields), fieldPath)
+		}
+		for k, result := range results {
+			result.Set(collected.responseKey, values[k])
+		}
+	}
+
+	return results
+}
+
+func (exec *execution) resolveField(def *Field, parents []any, f *field) ([]any, error) {
+	args, err := exec.resolveArguments(f.arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	if def.BatchResolve != nil {
+		values, err := def.BatchResolve(exec.ctx, parents, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(parents) {
+			return nil, fmt.Errorf("field '%s' resolved %d values for %d objects", f.name, len(values), len(parents))
+		}
+
+		return values, nil
+	}
+
+	values := make([]any, len(parents))
+	for k, parent := range parents {
+		v, err := def.Resolve(exec.ctx, parent, args)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = v
+	}
+
+	return values, nil
+}
+
+// completeObjects executes the selection set of an object field against the values it
+// resolved to. The values of list fields are flattened, such that the selection set is
+// executed for every item of every list at once.
+func (exec *execution) completeObjects(def *Field, values []any, set []selection, path []any) []any {
+	if !def.List {
+		present := make([]any, 0, len(values))
+		for _, v := range values {
+			if !isNil(v) {
+				present = append(present, v)
+			}
+		}
+
+		completed := exec.executeSelectionSet(def.Type, present, set, path)
+		out := make([]any, len(values))
+		for k, v := range values {
+			if !isNil(v) {
+				out[k], completed = completed[0], completed[1:]
+			}
+		}
+		return out
+	}
+
+	lists := make([][]any, len(values))
+	flattened := make([]any, 0)
+	for k, v := range values {
+		lists[k] = toSlice(v)
+		flattened = append(flattened, lists[k]...)
+	}
+
+	completed := exec.executeSelectionSet(def.Type, flattened, set, path)
+	out := make([]any, len(values))
+	for k, list := range lists {
+		if list == nil {
+			continue
+		}
+
+		items := make([]any, len(list))
+		for i := range list {
+			items[i], completed = completed[0], completed[1:]
+		}
+		out[k] = items
+	}
+
+	return out
+}
+
+// collectFields returns the fields selected by the selection set, expanding fragments which apply
+// to the object type provided and omitting fields excluded by the @skip/@include directives.
+func (exec *execution) collectFields(obj *Object, set []selection, visitedFragments map[string]struct{}) ([]*collectedField, error) {
+	fields := make([]*collectedField, 0, len(set))
+	byKey := make(map[string]*collectedField, len(set))
+	add := func(f *field) error {
+		key := f.name
+		if f.alias != "" {
+			key = f.alias
+		}
+
+		if existing, ok := byKey[key]; ok {
+			if existing.fields[0].name != f.name {
+				return fmt.Errorf("fields '%s' and '%s' conflict as they share the response key '%s'", existing.fields[0].name, f.name, key)
+			}
+
+			existing.fields = append(existing.fields, f)
+			return nil
+		}
+
+		collected := &collectedField{responseKey: key, fields: []*field{f}}
+		byKey[key] = collected
+		fields = append(fields, collected)
+		return nil
+	}
+
+	for _, sel := range set {
+		include, err := exec.shouldInclude(sel.directives)
+		if err != nil {
+			return nil, err
+		} else if !include {
+			continue
+		}
+
+		var nested []selection
+		switch {
+		case sel.field != nil:
+			if err := add(sel.field); err != nil {
+				return nil, err
+			}
+			continue
+		case sel.inline:
+			if sel.typeCondition != "" && sel.typeCondition != obj.Name {
+				continue
+			}
+			nested = sel.selectionSet
+		default:
+			if _, ok := visitedFragments[sel.fragmentName]; ok {
+				continue
+			}
+			visitedFragments[sel.fragmentName] = struct{}{}
+
+			frag, ok := exec.fragments[sel.fragmentName]
+			if !ok {
+				return nil, fmt.Errorf("unknown fragment '%s'", sel.fragmentName)
+			}
+			if frag.typeCondition != obj.Name {
+				continue
+			}
+			nested = frag.selectionSet
+		}
+
+		nestedFields, err := exec.collectFields(obj, nested, visitedFragments)
+		if err != nil {
+			return nil, err
+		}
+		for _, collected := range nestedFields {
+			for _, f := range collected.fields {
+				if err := add(f); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+func (exec *execution) shouldInclude(directives []*directive) (bool, error) {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			return false, fmt.Errorf("unknown directive '@%s'", d.name)
+		}
+
+		args, err := exec.resolveArguments(d.arguments)
+		if err != nil {
+			return false, err
+		}
+		condition, ok := args["if"].(bool)
+		if !ok {
+			return false, fmt.Errorf("directive '@%s' requires a boolean 'if' argument", d.name)
+		}
+
+		if (d.name == "skip") == condition {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (exec *execution) resolveArguments(args []*argument) (Args, error) {
+	out := make(Args, len(args))
+	for _, arg := range args {
+		v, err := arg.value.resolve(exec.variables)
+		if err != nil {
+			return nil, err
+		}
+		out[arg.name] = v
+	}
+
+	return out, nil
+}
+
+func (v *value) resolve(variables map[string]any) (any, error) {
+	switch v.kind {
+	case valueVariable:
+		return variables[v.raw], nil
+	case valueInt:
+		return strconv.Atoi(v.raw)
+	case valueFloat:
+		return strconv.ParseFloat(v.raw, 64)
+	case valueString, valueEnum:
+		return v.raw, nil
+	case valueBoolean:
+		return v.raw == "true", nil
+	case valueNull:
+		return nil, nil
+	case valueList:
+		list := make([]any, len(v.list))
+		for k, item := range v.list {
+			resolved, err := item.resolve(variables)
+			if err != nil {
+				return nil, err
+			}
+			list[k] = resolved
+		}
+		return list, nil
+	case valueObject:
+		obj := make(map[string]any, len(v.object))
+		for _, f := range v.object {
+			resolved, err := f.value.resolve(variables)
+			if err != nil {
+				return nil, err
+			}
+			obj[f.name] = resolved
+		}
+		return obj, nil
+	}
+
+	panic("unreachable")
+}
+
+func mergeSelectionSets(fields []*field) []selection {
+	if len(fields) == 1 {
+		return fields[0].selectionSet
+	}
+
+	merged := make([]selection, 0)
+	for _, f := range fields {
+		merged = append(merged, f.selectionSet...)
+	}
+
+	return merged
+}
+
+// toSlice converts a slice of any type to a []any, returning nil if the value is nil.
+func toSlice(v any) []any {
+	if isNil(v) {
+		return nil
+	}
+	if s, ok := v.([]any); ok {
+		return s
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		panic(fmt.Sprintf("list field resolved to non-slice type %T", v))
+	}
+
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+
+	return out
+}
+
+// isNil returns true if the value is nil, or is a nil pointer/slice/map.
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+func newOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]any)}
+}
+
+func (m *OrderedMap) Set(key string, v any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+func (m *OrderedMap) Get(key string) any { return m.values[key] }
+
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for k, key := range m.keys {
+		if k > 0 {
+			buf.WriteByte(',')
+		}
+
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		encodedValue, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
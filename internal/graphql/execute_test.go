@@ -0,0 +1,169 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	series  struct{ ID, Title string }
+	episode struct {
+		ID       string
+		SeriesID string
+		Number   int
+	}
+)
+
+var allEpisodes = []*episode{{"e1", "s1", 1}, {"e2", "s1", 2}, {"e3", "s2", 1}}
+
+func newSchema(batches *int) *graphql.Schema {
+	episodeType := &graphql.Object{
+		Name: "Episode",
+		Fields: map[string]*graphql.Field{
+			"id":     {Resolve: func(_ context.Context, parent any, _ graphql.Args) (any, error) { return parent.(*episode).ID, nil }},
+			"number": {Resolve: func(_ context.Context, parent any, _ graphql.Args) (any, error) { return parent.(*episode).Number, nil }},
+			"broken": {Resolve: func(_ context.Context, _ any, _ graphql.Args) (any, error) { return nil, errors.New("broken") }},
+		},
+	}
+
+	seriesType := &graphql.Object{
+		Name: "Series",
+		Fields: map[string]*graphql.Field{
+			"id":    {Resolve: func(_ context.Context, parent any, _ graphql.Args) (any, error) { return parent.(*series).ID, nil }},
+			"title": {Resolve: func(_ context.Context, parent any, _ graphql.Args) (any, error) { return parent.(*series).Title, nil }},
+			"episodes": {
+				Type: episodeType,
+				List: true,
+				BatchResolve: func(_ context.Context, parents []any, _ graphql.Args) ([]any, error) {
+					*batches++
+					out := make([]any, len(parents))
+					for k, parent := range parents {
+						eps := make([]*episode, 0)
+						for _, ep := range allEpisodes {
+							if ep.SeriesID == parent.(*series).ID {
+								eps = append(eps, ep)
+							}
+						}
+						out[k] = eps
+					}
+					return out, nil
+				},
+			},
+		},
+	}
+
+	return &graphql.Schema{
+		Query: &graphql.Object{
+			Name: "Query",
+			Fields: map[string]*graphql.Field{
+				"series": {
+					Type: seriesType,
+					Resolve: func(_ context.Context, _ any, args graphql.Args) (any, error) {
+						if args["id"] == "missing" {
+							return nil, nil
+						}
+						return &series{ID: args["id"].(string), Title: "Series " + args["id"].(string)}, nil
+					},
+				},
+			},
+		},
+		MaxDepth: 3,
+	}
+}
+
+func execute(t *testing.T, schema *graphql.Schema, request graphql.Request) (string, []*graphql.Error) {
+	response := schema.Execute(context.Background(), request)
+	if response.Data == nil {
+		return "", response.Errors
+	}
+
+	encoded, err := json.Marshal(response.Data)
+	assert.NoError(t, err)
+	return string(encoded), response.Errors
+}
+
+func Test_ExecuteSelectsFieldsInOrder(t *testing.T) {
+	batches := 0
+	data, errs := execute(t, newSchema(&batches), graphql.Request{
+		Query: `{ series(id: "s1") { title, id, episodes { number } } }`,
+	})
+
+	assert.Empty(t, errs)
+	assert.Equal(t, `{"series":{"title":"Series s1","id":"s1","episodes":[{"number":1},{"number":2}]}}`, data)
+}
+
+func Test_ExecuteBatchesFieldsAtSameDepth(t *testing.T) {
+	batches := 0
+	data, errs := execute(t, newSchema(&batches), graphql.Request{
+		Query: `query Both($second: String!) {
+			first: series(id: "s1") { ...eps }
+			second: series(id: $second) { ...eps }
+		}
+		fragment eps on Series { episodes { id } }`,
+		Variables: map[string]any{"second": "s2"},
+	})
+
+	assert.Empty(t, errs)
+	assert.Equal(t, `{"first":{"episodes":[{"id":"e1"},{"id":"e2"}]},"second":{"episodes":[{"id":"e3"}]}}`, data)
+	assert.Equal(t, 2, batches, "each aliased field should resolve it's episodes once")
+}
+
+func Test_ExecuteDirectivesAndTypename(t *testing.T) {
+	batches := 0
+	data, errs := execute(t, newSchema(&batches), graphql.Request{
+		Query:     `query($withTitle: Boolean = false) { series(id: "s1") { __typename id title @include(if: $withTitle) episodes @skip(if: true) { id } } }`,
+		Variables: map[string]any{},
+	})
+
+	assert.Empty(t, errs)
+	assert.Equal(t, `{"series":{"__typename":"Series","id":"s1"}}`, data)
+	assert.Zero(t, batches)
+}
+
+func Test_ExecuteResolverErrorsAreReported(t *testing.T) {
+	batches := 0
+	data, errs := execute(t, newSchema(&batches), graphql.Request{
+		Query: `{ series(id: "s2") { episodes { id broken } } missing: series(id: "missing") { id } }`,
+	})
+
+	assert.Equal(t, `{"series":{"episodes":[{"id":"e3","broken":null}]},"missing":null}`, data)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "broken", errs[0].Message)
+		assert.Equal(t, []any{"series", "episodes", "broken"}, errs[0].Path)
+	}
+}
+
+func Test_ExecuteRejectsInvalidQueries(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"syntax error", `{ series(id: "s1") { id }`},
+		{"unknown field", `{ series(id: "s1") { nope } }`},
+		{"missing selection set", `{ series(id: "s1") }`},
+		{"scalar selection set", `{ series(id: "s1") { id { foo } } }`},
+		{"too deep", `{ series(id: "s1") { ...a } } fragment a on Series { episodes { id } }`},
+		{"mutation", `mutation { series(id: "s1") { id } }`},
+		{"missing variable", `query($id: String!) { series(id: $id) { id } }`},
+		{"conflicting fields", `{ series(id: "s1") { id: title id } }`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			batches := 0
+			schema := newSchema(&batches)
+			if test.name == "too deep" {
+				schema.MaxDepth = 2
+			}
+
+			data, errs := execute(t, schema, graphql.Request{Query: test.query})
+			assert.Empty(t, data)
+			assert.Len(t, errs, 1)
+		})
+	}
+}
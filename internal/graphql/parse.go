@@ -0,0 +1,679 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	tokenKind int
+
+	token struct {
+		kind  tokenKind
+		value string
+		pos   int
+	}
+
+	lexer struct {
+		source string
+		pos    int
+	}
+
+	parser struct {
+		lexer *lexer
+		token token
+	}
+
+	document struct {
+		operations []*operation
+		fragments  map[string]*fragment
+	}
+
+	operation struct {
+		kind         string
+		name         string
+		variables    []*variableDefinition
+		selectionSet []selection
+	}
+
+	variableDefinition struct {
+		name         string
+		nonNull      bool
+		defaultValue *value
+	}
+
+	fragment struct {
+		name          string
+		typeCondition string
+		directives    []*directive
+		selectionSet  []selection
+	}
+
+	// selection is a field, fragment spread (in which case only fragmentName is set)
+	// or inline fragment (in which case only the typeCondition/selectionSet is set).
+	selection struct {
+		field         *field
+		fragmentName  string
+		typeCondition string
+		inline        bool
+		directives    []*directive
+		selectionSet  []selection
+	}
+
+	field struct {
+		alias        string
+		name         string
+		arguments    []*argument
+		selectionSet []selection
+		pos          int
+	}
+
+	argument struct {
+		name  string
+		value *value
+	}
+
+	directive struct {
+		name      string
+		arguments []*argument
+	}
+
+	valueKind int
+
+	value struct {
+		kind   valueKind
+		raw    string
+		list   []*value
+		object []*argument
+	}
+)
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+const (
+	valueVariable valueKind = iota
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueNull
+	valueEnum
+	valueList
+	valueObject
+)
+
+// SyntaxError is returned when a query document cannot be parsed.
+type SyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", err.Line, err.Column, err.Message)
+}
+
+func parse(source string) (*document, error) {
+	p := &parser{lexer: &lexer{source: strings.TrimPrefix(source, "\uFEFF")}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunctuator, "{"):
+			set, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selectionSet: set})
+		case p.peek(tokenName, "fragment"):
+			frag, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[frag.name]; ok {
+				return nil, p.errorf("fragment '%s' is defined more than once", frag.name)
+			}
+			doc.fragments[frag.name] = frag
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, &SyntaxError{Message: "document does not contain an operation", Line: 1, Column: 1}
+	}
+
+	return doc, nil
+}
+
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{kind: p.token.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	if p.token.kind == tokenName {
+		op.name = p.token.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek(tokenPunctuator, "(") {
+		vars, err := p.parseVariableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.variables = vars
+	}
+
+	// Directives on operations are accepted, but have no effect
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	set, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selectionSet = set
+
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]*variableDefinition, error) {
+	if err := p.expect(tokenPunctuator, "("); err != nil {
+		return nil, err
+	}
+
+	defs := make([]*variableDefinition, 0)
+	for !p.peek(tokenPunctuator, ")") {
+		if err := p.expect(tokenPunctuator, "$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunctuator, ":"); err != nil {
+			return nil, err
+		}
+
+		def := &variableDefinition{name: name}
+		if def.nonNull, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if p.peek(tokenPunctuator, "=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.defaultValue, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		defs = append(defs, def)
+	}
+
+	return defs, p.expect(tokenPunctuator, ")")
+}
+
+// parseType consumes a type reference (e.g. '[ID!]!'), returning true if the type is non-null.
+func (p *parser) parseType() (bool, error) {
+	if p.peek(tokenPunctuator, "[") {
+		if err := p.advance(); err != nil {
+			return false, err
+		}
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect(tokenPunctuator, "]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return false, err
+	}
+
+	if p.peek(tokenPunctuator, "!") {
+		return true, p.advance()
+	}
+
+	return false, nil
+}
+
+func (p *parser) parseFragment() (*fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenName, "on"); err != nil {
+		return nil, err
+	}
+	typeCondition, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	set, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	return &fragment{name: name, typeCondition: typeCondition, directives: directives, selectionSet: set}, nil
+}
+
+func (p *parser) parseSelectionSet() ([]selection, error) {
+	if err := p.expect(tokenPunctuator, "{"); err != nil {
+		return nil, err
+	}
+
+	set := make([]selection, 0)
+	for !p.peek(tokenPunctuator, "}") {
+		sel, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+	}
+	if len(set) == 0 {
+		return nil, p.errorf("selection set must not be empty")
+	}
+
+	return set, p.expect(tokenPunctuator, "}")
+}
+
+func (p *parser) parseSelection() (selection, error) {
+	if !p.peek(tokenPunctuator, "...") {
+		f, directives, err := p.parseField()
+		return selection{field: f, directives: directives}, err
+	}
+
+	if err := p.advance(); err != nil {
+		return selection{}, err
+	}
+
+	// Fragment spread
+	if p.token.kind == tokenName && p.token.value != "on" {
+		name := p.token.value
+		if err := p.advance(); err != nil {
+			return selection{}, err
+		}
+		directives, err := p.parseDirectives()
+		return selection{fragmentName: name, directives: directives}, err
+	}
+
+	// Inline fragment
+	sel := selection{inline: true}
+	if p.peek(tokenName, "on") {
+		if err := p.advance(); err != nil {
+			return sel, err
+		}
+		typeCondition, err := p.expectName()
+		if err != nil {
+			return sel, err
+		}
+		sel.typeCondition = typeCondition
+	}
+
+	var err error
+	if sel.directives, err = p.parseDirectives(); err != nil {
+		return sel, err
+	}
+	sel.selectionSet, err = p.parseSelectionSet()
+	return sel, err
+}
+
+func (p *parser) parseField() (*field, []*directive, error) {
+	f := &field{pos: p.token.pos}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.peek(tokenPunctuator, ":") {
+		if err := p.advance(); err != nil {
+			return nil, nil, err
+		}
+		f.alias = name
+		if name, err = p.expectName(); err != nil {
+			return nil, nil, err
+		}
+	}
+	f.name = name
+
+	if p.peek(tokenPunctuator, "(") {
+		if f.arguments, err = p.parseArguments(false); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.peek(tokenPunctuator, "{") {
+		if f.selectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return f, directives, nil
+}
+
+func (p *parser) parseArguments(constant bool) ([]*argument, error) {
+	if err := p.expect(tokenPunctuator, "("); err != nil {
+		return nil, err
+	}
+
+	args := make([]*argument, 0)
+	for !p.peek(tokenPunctuator, ")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunctuator, ":"); err != nil {
+			return nil, err
+		}
+		val, err := p.parseValue(constant)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &argument{name: name, value: val})
+	}
+
+	return args, p.expect(tokenPunctuator, ")")
+}
+
+func (p *parser) parseDirectives() ([]*directive, error) {
+	directives := make([]*directive, 0)
+	for p.peek(tokenPunctuator, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+
+		d := &directive{name: name}
+		if p.peek(tokenPunctuator, "(") {
+			if d.arguments, err = p.parseArguments(false); err != nil {
+				return nil, err
+			}
+		}
+		directives = append(directives, d)
+	}
+
+	return directives, nil
+}
+
+func (p *parser) parseValue(constant bool) (*value, error) {
+	tok := p.token
+	switch tok.kind {
+	case tokenPunctuator:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, p.errorf("variables are not allowed here")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.expectName()
+			return &value{kind: valueVariable, raw: name}, err
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			list := &value{kind: valueList, list: make([]*value, 0)}
+			for !p.peek(tokenPunctuator, "]") {
+				item, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				list.list = append(list.list, item)
+			}
+			return list, p.expect(tokenPunctuator, "]")
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			obj := &value{kind: valueObject, object: make([]*argument, 0)}
+			for !p.peek(tokenPunctuator, "}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(tokenPunctuator, ":"); err != nil {
+					return nil, err
+				}
+				val, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				obj.object = append(obj.object, &argument{name: name, value: val})
+			}
+			return obj, p.expect(tokenPunctuator, "}")
+		}
+	case tokenInt:
+		return &value{kind: valueInt, raw: tok.value}, p.advance()
+	case tokenFloat:
+		return &value{kind: valueFloat, raw: tok.value}, p.advance()
+	case tokenString:
+		return &value{kind: valueString, raw: tok.value}, p.advance()
+	case tokenName:
+		switch tok.value {
+		case "true", "false":
+			return &value{kind: valueBoolean, raw: tok.value}, p.advance()
+		case "null":
+			return &value{kind: valueNull}, p.advance()
+		default:
+			return &value{kind: valueEnum, raw: tok.value}, p.advance()
+		}
+	case tokenEOF:
+	}
+
+	return nil, p.unexpected()
+}
+
+func (p *parser) peek(kind tokenKind, val string) bool {
+	return p.token.kind == kind && p.token.value == val
+}
+
+func (p *parser) expect(kind tokenKind, val string) error {
+	if !p.peek(kind, val) {
+		return p.errorf("expected '%s', found %s", val, p.describe())
+	}
+
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.token.kind != tokenName {
+		return "", p.errorf("expected name, found %s", p.describe())
+	}
+
+	name := p.token.value
+	return name, p.advance()
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	p.token = tok
+	return nil
+}
+
+func (p *parser) describe() string {
+	if p.token.kind == tokenEOF {
+		return "end of document"
+	}
+
+	return fmt.Sprintf("'%s'", p.token.value)
+}
+
+func (p *parser) unexpected() error {
+	return p.errorf("unexpected %s", p.describe())
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return p.lexer.errorAt(p.token.pos, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token in the source, skipping whitespace, commas and comments.
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.source) {
+		c := l.source[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.pos++
+		} else if c == '#' {
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' && l.source[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	start := l.pos
+	if l.pos >= len(l.source) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.source[l.pos]
+	switch {
+	case strings.ContainsRune("!$()=:@[]{}|&", rune(c)):
+		l.pos++
+		return token{kind: tokenPunctuator, value: string(c), pos: start}, nil
+	case c == '.':
+		if strings.HasPrefix(l.source[l.pos:], "...") {
+			l.pos += 3
+			return token{kind: tokenPunctuator, value: "...", pos: start}, nil
+		}
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.source) && (l.source[l.pos] == '_' || isLetter(l.source[l.pos]) || isDigit(l.source[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.source[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.readNumber()
+	case c == '"':
+		return l.readString()
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.source[l.pos:])
+	return token{}, l.errorAt(start, fmt.Sprintf("unexpected character %q", r))
+}
+
+func (l *lexer) readNumber() (token, error) {
+	start := l.pos
+	if l.source[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		from := l.pos
+		for l.pos < len(l.source) && isDigit(l.source[l.pos]) {
+			l.pos++
+		}
+		return l.pos - from
+	}
+
+	if digits() == 0 {
+		return token{}, l.errorAt(start, "invalid number")
+	}
+
+	kind := tokenInt
+	if l.pos < len(l.source) && l.source[l.pos] == '.' {
+		kind = tokenFloat
+		l.pos++
+		if digits() == 0 {
+			return token{}, l.errorAt(start, "invalid number")
+		}
+	}
+	if l.pos < len(l.source) && (l.source[l.pos] == 'e' || l.source[l.pos] == 'E') {
+		kind = tokenFloat
+		l.pos++
+		if l.pos < len(l.source) && (l.source[l.pos] == '+' || l.source[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return token{}, l.errorAt(start, "invalid number")
+		}
+	}
+
+	return token{kind: kind, value: l.source[start:l.pos], pos: start}, nil
+}
+
+// readString reads a (single-line) string literal. Block strings are not supported.
+func (l *lexer) readString() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.source[l.pos:], `"""`) {
+		return token{}, l.errorAt(start, "block strings are not supported")
+	}
+
+	l.pos++
+	for l.pos < len(l.source) {
+		switch l.source[l.pos] {
+		case '\\':
+			l.pos += 2
+		case '\n', '\r':
+			return token{}, l.errorAt(start, "unterminated string")
+		case '"':
+			l.pos++
+			unquoted, err := strconv.Unquote(l.source[start:l.pos])
+			if err != nil {
+				return token{}, l.errorAt(start, "invalid string")
+			}
+			return token{kind: tokenString, value: unquoted, pos: start}, nil
+		default:
+			l.pos++
+		}
+	}
+
+	return token{}, l.errorAt(start, "unterminated string")
+}
+
+func (l *lexer) errorAt(pos int, message string) error {
+	line, column := 1, 1
+	for _, r := range l.source[:min(pos, len(l.source))] {
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	return &SyntaxError{Message: message, Line: line, Column: column}
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/health"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+)
+
+// readinessProbes returns the probes used to determine whether Thea is ready to serve
+// requests. The database must be reachable and fully migrated, the ffmpeg binary must be
+// present, and the event bus must be running (including the relay of events to other
+// Thea instances, if an event transport is configured). TMDB is probed using the state of the
+// circuit breaker protecting requests to it, and is optional as ingestions are retried once
+// TMDB recovers.
+func (thea *theaImpl) readinessProbes(db database.Manager) []health.Probe {
+	ffmpegPath := thea.config.Format.FfmpegBinaryPath
+	return []health.Probe{
+		{Name: "database", Check: func(ctx context.Context) error {
+			return db.GetSqlxDB().PingContext(ctx)
+		}},
+		{Name: "migrations", Check: func(_ context.Context) error {
+			latest, err := database.LatestSchemaVersion()
+			if err != nil {
+				return fmt.Errorf("unable to determine latest migration: %w", err)
+			}
+
+			applied, err := database.AppliedSchemaVersion(db.GetSqlxDB())
+			if err != nil {
+				return fmt.Errorf("unable to determine applied migrations: %w", err)
+			}
+
+			if applied != latest {
+				return fmt.Errorf("database schema version %d does not match the version required (%d)", applied, latest)
+			}
+
+			return nil
+		}},
+		{Name: "ffmpeg", Check: func(_ context.Context) error {
+			if _, err := exec.LookPath(ffmpegPath); err != nil {
+				return fmt.Errorf("ffmpeg binary not found: %w", err)
+			}
+
+			return nil
+		}},
+		{Name: "event-bus", Check: func(_ context.Context) error {
+			if !thea.running.Load() {
+				return errors.New("thea services are not running")
+			} else if thea.eventRelay != nil && !thea.eventRelay.Running() {
+				return errors.New("events are not being relayed using the event transport")
+			}
+
+			return nil
+		}},
+		{Name: "tmdb", Optional: true, Check: func(_ context.Context) error {
+			status := thea.searcher.BreakerStatus()
+			switch status.State {
+			case tmdb.BreakerOpen:
+				return fmt.Errorf("circuit breaker is open after %d consecutive failures, requests resume at %s", status.ConsecutiveFailures, status.OpenUntil.Format(time.RFC3339))
+			case tmdb.BreakerHalfOpen:
+				return errors.New("circuit breaker is half-open, waiting for a trial request to TMDB to succeed")
+			case tmdb.BreakerClosed:
+			}
+
+			return nil
+		}},
+	}
+}
@@ -0,0 +1,106 @@
+// Package health determines whether Thea is ready to serve requests, by probing the
+// dependencies Thea requires (e.g. the database). The readiness is exposed by the API
+// for use by container orchestrators, such as Docker's HEALTHCHECK or Kubernetes probes.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultProbeTimeout = 5 * time.Second
+
+type (
+	// Probe checks a single dependency of Thea, returning an error if the
+	// dependency is unavailable. Probes must respect the context provided.
+	// Optional dependencies are reported, but do not affect readiness, as Thea
+	// is able to serve requests (in a degraded state) without them.
+	Probe struct {
+		Name     string
+		Check    func(ctx context.Context) error
+		Optional bool
+	}
+
+	// Result is the outcome of a single probe. The Message is only
+	// populated if the dependency is unhealthy.
+	Result struct {
+		Name     string
+		Healthy  bool
+		Optional bool
+		Message  string
+		Duration time.Duration
+	}
+
+	// Report contains the results of all probes, in the order the probes were
+	// registered. Thea is only ready if all the (non-optional) probes were healthy.
+	Report struct {
+		Ready     bool
+		CheckedAt time.Time
+		Results   []Result
+	}
+
+	Checker struct {
+		probes  []Probe
+		timeout time.Duration
+	}
+)
+
+// New returns a checker which runs the probes provided. Each probe is
+// cancelled if it does not complete within the timeout.
+func New(timeout time.Duration, probes ...Probe) *Checker {
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+
+	return &Checker{probes: probes, timeout: timeout}
+}
+
+// Check runs all the probes concurrently, and returns a report of their results.
+func (checker *Checker) Check(ctx context.Context) *Report {
+	report := &Report{Ready: true, CheckedAt: time.Now(), Results: make([]Result, len(checker.probes))}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(checker.probes))
+	for k, probe := range checker.probes {
+		go func(k int, probe Probe) {
+			defer wg.Done()
+			report.Results[k] = checker.run(ctx, probe)
+		}(k, probe)
+	}
+	wg.Wait()
+
+	for _, result := range report.Results {
+		if !result.Healthy && !result.Optional {
+			report.Ready = false
+		}
+	}
+
+	return report
+}
+
+func (checker *Checker) run(ctx context.Context, probe Probe) Result {
+	probeCtx, cancel := context.WithTimeout(ctx, checker.timeout)
+	defer cancel()
+
+	// Probes which do not respect the context are abandoned once the timeout
+	// elapses, so that a single hung dependency cannot hang the check.
+	started := time.Now()
+	errChan := make(chan error, 1)
+	go func() { errChan <- probe.Check(probeCtx) }()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-probeCtx.Done():
+		err = fmt.Errorf("probe did not complete: %w", probeCtx.Err())
+	}
+
+	result := Result{Name: probe.Name, Healthy: err == nil, Optional: probe.Optional, Duration: time.Since(started)}
+	if err != nil {
+		result.Message = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,56 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckReportsUnhealthyProbes(t *testing.T) {
+	checker := health.New(time.Second,
+		health.Probe{Name: "a", Check: func(_ context.Context) error { return nil }},
+		health.Probe{Name: "b", Check: func(_ context.Context) error { return errors.New("unreachable") }},
+	)
+
+	report := checker.Check(context.Background())
+	assert.False(t, report.Ready)
+	assert.Len(t, report.Results, 2)
+	assert.Equal(t, "a", report.Results[0].Name)
+	assert.True(t, report.Results[0].Healthy)
+	assert.Equal(t, "b", report.Results[1].Name)
+	assert.False(t, report.Results[1].Healthy)
+	assert.Equal(t, "unreachable", report.Results[1].Message)
+}
+
+func Test_CheckAbandonsHungProbes(t *testing.T) {
+	hung := make(chan struct{})
+	defer close(hung)
+
+	checker := health.New(10*time.Millisecond,
+		health.Probe{Name: "hung", Check: func(_ context.Context) error { <-hung; return nil }},
+	)
+
+	report := checker.Check(context.Background())
+	assert.False(t, report.Ready)
+	assert.Contains(t, report.Results[0].Message, context.DeadlineExceeded.Error())
+}
+
+func Test_CheckIgnoresOptionalProbesForReadiness(t *testing.T) {
+	checker := health.New(time.Second,
+		health.Probe{Name: "a", Check: func(_ context.Context) error { return nil }},
+		health.Probe{Name: "b", Check: func(_ context.Context) error { return errors.New("degraded") }, Optional: true},
+	)
+
+	report := checker.Check(context.Background())
+	assert.True(t, report.Ready)
+	assert.False(t, report.Results[1].Healthy)
+	assert.True(t, report.Results[1].Optional)
+}
+
+func Test_CheckWithoutProbesIsReady(t *testing.T) {
+	assert.True(t, health.New(time.Second).Check(context.Background()).Ready)
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type (
+	historyPruner interface {
+		PruneTranscodeHistory(concludedBefore time.Time) (int, error)
+	}
+
+	// historyJanitor periodically prunes the history of transcode tasks
+	// which concluded longer ago than the configured retention window.
+	historyJanitor struct {
+		config HistoryConfig
+		store  historyPruner
+	}
+)
+
+func newHistoryJanitor(config HistoryConfig, store historyPruner) *historyJanitor {
+	return &historyJanitor{config: config, store: store}
+}
+
+func (janitor *historyJanitor) Run(ctx context.Context) error {
+	if janitor.config.Retention <= 0 || janitor.config.PurgeInterval <= 0 {
+		log.Emit(logger.INFO, "Transcode history retention is disabled, history will be retained forever\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(janitor.config.PurgeInterval)
+	defer ticker.Stop()
+
+	log.Emit(logger.NEW, "History janitor started (retention=%s)\n", janitor.config.Retention)
+	for {
+		janitor.prune()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Emit(logger.STOP, "History janitor closed\n")
+			return nil
+		}
+	}
+}
+
+// prune deletes the transcode history whose retention window has
+// elapsed. Failures are logged, and will be retried on the next tick.
+func (janitor *historyJanitor) prune() {
+	pruned, err := janitor.store.PruneTranscodeHistory(time.Now().Add(-janitor.config.Retention))
+	if err != nil {
+		log.Errorf("Failed to prune expired transcode history: %v\n", err)
+		return
+	}
+
+	if pruned > 0 {
+		log.Emit(logger.REMOVE, "Pruned %d expired entries from the transcode history\n", pruned)
+	}
+}
@@ -0,0 +1,161 @@
+package tmdb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+// ErrUnavailable is wrapped by the errors returned when TMDB could not be reached, responded
+// with a server error (or rate limited the request), or when the circuit breaker is open
+// following repeated failures. Requests which fail with this error should be retried later.
+var ErrUnavailable = errors.New("TMDB is unavailable")
+
+type (
+	// BreakerState is the state of the circuit breaker protecting requests to TMDB. While
+	// OPEN, requests fail immediately. Once the cooldown has elapsed the breaker is
+	// HALF_OPEN, and a single trial request is permitted to determine whether
+	// TMDB has recovered.
+	BreakerState int
+
+	// BreakerStatus describes the current state of the circuit breaker. OpenUntil
+	// is only populated while the breaker is OPEN.
+	BreakerStatus struct {
+		State               BreakerState
+		ConsecutiveFailures int
+		OpenUntil           *time.Time
+	}
+
+	// circuitBreaker stops requests being made to TMDB once the number of consecutive
+	// requests which failed as TMDB is unavailable reaches the threshold, until the
+	// cooldown has elapsed. A threshold of zero disables the breaker.
+	circuitBreaker struct {
+		*sync.Mutex
+		threshold int
+		cooldown  time.Duration
+
+		state     BreakerState
+		failures  int
+		openUntil time.Time
+		trialing  bool
+	}
+
+	// concurrencyLimiter restricts the number of requests made to TMDB at once. A
+	// nil limiter permits unlimited requests.
+	concurrencyLimiter chan struct{}
+)
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{Mutex: &sync.Mutex{}, threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns an error wrapping ErrUnavailable if a request cannot be made as the
+// breaker is open. Once the cooldown has elapsed, only one (trial) request is allowed
+// until the outcome of the trial has been recorded.
+func (breaker *circuitBreaker) allow() error {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	if breaker.state == BreakerOpen && time.Now().After(breaker.openUntil) {
+		breaker.state = BreakerHalfOpen
+	}
+
+	switch breaker.state {
+	case BreakerOpen:
+		return fmt.Errorf("%w: circuit breaker is open until %s after %d consecutive failures", ErrUnavailable, breaker.openUntil.Format(time.RFC3339), breaker.failures)
+	case BreakerHalfOpen:
+		if breaker.trialing {
+			return fmt.Errorf("%w: circuit breaker is waiting for a trial request to complete", ErrUnavailable)
+		}
+		breaker.trialing = true
+	case BreakerClosed:
+	}
+
+	return nil
+}
+
+// record records the outcome of a request allowed by the breaker, opening the breaker if the
+// request failed as TMDB is unavailable and the threshold has been reached (or the request
+// was the trial request), and closing the breaker if the request succeeded.
+func (breaker *circuitBreaker) record(unavailable bool) {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	breaker.trialing = false
+	if !unavailable {
+		if breaker.state != BreakerClosed {
+			log.Infof("TMDB request succeeded, closing circuit breaker\n")
+		}
+
+		breaker.state = BreakerClosed
+		breaker.failures = 0
+		return
+	}
+
+	breaker.failures++
+	if breaker.threshold > 0 && (breaker.state == BreakerHalfOpen || breaker.failures >= breaker.threshold) {
+		breaker.state = BreakerOpen
+		breaker.openUntil = time.Now().Add(breaker.cooldown)
+		log.Warnf("TMDB is unavailable after %d consecutive failures, opening circuit breaker for %s\n", breaker.failures, breaker.cooldown)
+	}
+}
+
+func (breaker *circuitBreaker) status() BreakerStatus {
+	breaker.Lock()
+	defer breaker.Unlock()
+
+	status := BreakerStatus{State: breaker.state, ConsecutiveFailures: breaker.failures}
+	if breaker.state == BreakerOpen {
+		openUntil := breaker.openUntil
+		status.OpenUntil = &openUntil
+	}
+
+	return status
+}
+
+func newConcurrencyLimiter(limit int) concurrencyLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	return make(concurrencyLimiter, limit)
+}
+
+func (limiter concurrencyLimiter) acquire() {
+	if limiter != nil {
+		limiter <- struct{}{}
+	}
+}
+
+func (limiter concurrencyLimiter) release() {
+	if limiter != nil {
+		<-limiter
+	}
+}
+
+// isUnavailableStatus returns true if the HTTP status code provided indicates
+// that TMDB is unavailable (or is rate limiting requests).
+func isUnavailableStatus(code int) bool {
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+}
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "CLOSED"
+	case BreakerOpen:
+		return "OPEN"
+	case BreakerHalfOpen:
+		return "HALF_OPEN"
+	default:
+		return fmt.Sprintf("UNKNOWN[%d]", s)
+	}
+}
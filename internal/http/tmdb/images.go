@@ -0,0 +1,33 @@
+package tmdb
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// tmdbImageBaseURL is the base URL which the image paths returned by TMDB
+// (e.g. a movies 'poster_path') are relative to.
+const tmdbImageBaseURL = "https://image.tmdb.org/t/p/original"
+
+// DownloadImage fetches the TMDB image at the path provided (e.g. the
+// 'PosterPath' of a movie), writing the image to the writer provided.
+func (searcher *tmdbSearcher) DownloadImage(imagePath string, dest io.Writer) error {
+	url := tmdbImageBaseURL + imagePath
+	log.Verbosef("GET -> %s\n", url)
+	resp, err := http.Get(url) //nolint
+	if err != nil {
+		return &UnknownRequestError{fmt.Sprintf("failed to perform GET(%s) to TMDB: %v", url, err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &FailedRequestError{httpCode: resp.StatusCode, message: "image could not be downloaded", tmdbCode: -1}
+	}
+
+	if _, err := io.Copy(dest, resp.Body); err != nil {
+		return &UnknownRequestError{fmt.Sprintf("failed to read image %s: %v", imagePath, err)}
+	}
+
+	return nil
+}
@@ -1,22 +1,43 @@
 package tmdb
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/media"
 )
 
 func TmdbEpisodeToMedia(ep *Episode, isSeasonAdult bool, metadata *media.FileMediaMetadata) *media.Episode {
 	return &media.Episode{
-		Model: media.Model{ID: uuid.New(), TmdbID: ep.ID.String(), Title: ep.Name},
+		Model: media.Model{ID: uuid.New(), TmdbID: ep.ID.String(), Title: ep.Name, Overview: ep.Overview},
 		Watchable: media.Watchable{
 			MediaResolution: media.MediaResolution{Width: metadata.FrameW, Height: metadata.FrameH},
 			SourcePath:      metadata.Path,
+			SourceSize:      metadata.Size,
+			VideoCodec:      metadata.VideoCodec,
+			Checksum:        &metadata.Checksum,
 			Adult:           isSeasonAdult,
 		},
 		EpisodeNumber: metadata.EpisodeNumber,
 	}
 }
 
+// TmdbSeasonToCatalog converts the episodes of the TMDB season to catalog entries. Episodes
+// with an air date which cannot be parsed (e.g. unannounced episodes) have a nil air date.
+func TmdbSeasonToCatalog(season *Season) []*media.CatalogEpisode {
+	episodes := make([]*media.CatalogEpisode, len(season.Episodes))
+	for k, v := range season.Episodes {
+		episode := &media.CatalogEpisode{SeasonNumber: season.SeasonNumber, EpisodeNumber: v.EpisodeNumber, Title: v.Name}
+		if airDate, err := time.Parse(time.DateOnly, v.AirDate); err == nil {
+			episode.AirDate = &airDate
+		}
+
+		episodes[k] = episode
+	}
+
+	return episodes
+}
+
 func TmdbGenresToMedia(genres []Genre) []*media.Genre {
 	gs := make([]*media.Genre, len(genres))
 	for k, v := range genres {
@@ -28,24 +49,63 @@ func TmdbGenresToMedia(genres []Genre) []*media.Genre {
 
 func TmdbSeriesToMedia(series *Series) *media.Series {
 	return &media.Series{
-		Model:  media.Model{ID: uuid.New(), TmdbID: series.ID.String(), Title: series.Name},
-		Genres: TmdbGenresToMedia(series.Genres),
+		Model:   media.Model{ID: uuid.New(), TmdbID: series.ID.String(), Title: series.Name, Overview: series.Overview},
+		Genres:  TmdbGenresToMedia(series.Genres),
+		Related: TmdbRelationsToMedia(series.Recommendations, series.Similar),
 	}
 }
 
 func TmdbSeasonToMedia(season *Season) *media.Season {
 	return &media.Season{
-		Model: media.Model{ID: uuid.New(), TmdbID: season.ID.String(), Title: season.Name},
+		Model: media.Model{ID: uuid.New(), TmdbID: season.ID.String(), Title: season.Name, Overview: season.Overview},
+	}
+}
+
+// TmdbCollectionToMedia converts the TMDB collection to a model, returning nil if
+// the collection is nil (i.e. the movie does not belong to a collection).
+func TmdbCollectionToMedia(collection *Collection) *media.Collection {
+	if collection == nil {
+		return nil
 	}
+
+	tmdbID := collection.ID.String()
+	return &media.Collection{ID: uuid.New(), TmdbID: &tmdbID, Title: collection.Name}
+}
+
+// TmdbRelationsToMedia converts the related content of a TMDB movie/series to a model, returning
+// nil if neither were included in the response (in which case any existing relations are retained).
+func TmdbRelationsToMedia(recommendations *RelatedResults, similar *RelatedResults) *media.TmdbRelations {
+	if recommendations == nil && similar == nil {
+		return nil
+	}
+
+	ids := func(related *RelatedResults) []string {
+		if related == nil {
+			return []string{}
+		}
+
+		out := make([]string, len(related.Results))
+		for k, v := range related.Results {
+			out[k] = v.ID.String()
+		}
+		return out
+	}
+
+	return &media.TmdbRelations{Recommended: ids(recommendations), Similar: ids(similar)}
 }
 
 func TmdbMovieToMedia(movie *Movie, metadata *media.FileMediaMetadata) *media.Movie {
 	return &media.Movie{
-		Model:  media.Model{ID: uuid.New(), TmdbID: movie.ID.String(), Title: movie.Name},
-		Genres: TmdbGenresToMedia(movie.Genres),
+		Model:      media.Model{ID: uuid.New(), TmdbID: movie.ID.String(), Title: movie.Name, Overview: movie.Overview},
+		Genres:     TmdbGenresToMedia(movie.Genres),
+		Collection: TmdbCollectionToMedia(movie.Collection),
+		Related:    TmdbRelationsToMedia(movie.Recommendations, movie.Similar),
 		Watchable: media.Watchable{
 			MediaResolution: media.MediaResolution{Width: metadata.FrameW, Height: metadata.FrameH},
 			SourcePath:      metadata.Path,
+			SourceSize:      metadata.Size,
+			VideoCodec:      metadata.VideoCodec,
+			Checksum:        &metadata.Checksum,
 			Adult:           movie.Adult,
 		},
 	}
@@ -2,10 +2,12 @@ package tmdb
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/adrg/strutil"
@@ -20,8 +22,8 @@ const (
 	tmdbSearchMovieTemplate  = "%s/search/movie?query=%s&api_key=%s"
 	tmdbSearchSeriesTemplate = "%s/search/tv?query=%s&api_key=%s"
 
-	tmdbGetMovieTemplate   = "%s/movie/%s?api_key=%s"
-	tmdbGetSeriesTemplate  = "%s/tv/%s?api_key=%s"
+	tmdbGetMovieTemplate   = "%s/movie/%s?api_key=%s&append_to_response=recommendations,similar"
+	tmdbGetSeriesTemplate  = "%s/tv/%s?api_key=%s&append_to_response=recommendations,similar"
 	tmdbGetSeasonTemplate  = "%s/tv/%s/season/%d?api_key=%s"
 	tmdbGetEpisodeTemplate = "%s/tv/%s/season/%d/episode/%d?api_key=%s"
 )
@@ -31,7 +33,19 @@ var log = logger.Get("TMDB")
 type (
 	Date   struct{ time.Time }
 	Config struct {
-		APIKey string
+		// APIKey is populated using the 'tmdb_api_key' option of the Thea config.
+		APIKey string `toml:"-"`
+
+		// MaxConcurrentRequests limits the number of requests made to TMDB at once, with
+		// further requests waiting until a request completes. Zero removes the limit.
+		MaxConcurrentRequests int `toml:"max_concurrent_requests" env:"TMDB_MAX_CONCURRENT_REQUESTS" env-default:"8"`
+
+		// Once BreakerThreshold consecutive requests fail as TMDB is unavailable, requests fail
+		// immediately (without contacting TMDB) until BreakerCooldown has elapsed, after which
+		// a trial request is made to determine whether TMDB has recovered. A threshold of
+		// zero disables the circuit breaker.
+		BreakerThreshold int           `toml:"breaker_threshold" env:"TMDB_BREAKER_THRESHOLD" env-default:"5"`
+		BreakerCooldown  time.Duration `toml:"breaker_cooldown" env:"TMDB_BREAKER_COOLDOWN" env-default:"30s"`
 	}
 
 	Genre struct {
@@ -63,39 +77,122 @@ type (
 		Tagline     string      `json:"tagline"`
 		Overview    string      `json:"overview"`
 		Genres      []Genre     `json:"genres"`
+		Collection  *Collection `json:"belongs_to_collection"`
+		PosterPath  string      `json:"poster_path"`
+
+		Recommendations *RelatedResults `json:"recommendations"`
+		Similar         *RelatedResults `json:"similar"`
+	}
+
+	// RelatedResults is the first page of the movies/series which TMDB recommends
+	// alongside (or considers similar to) a movie/series, ordered by relevance.
+	RelatedResults struct {
+		Results []struct {
+			ID json.Number `json:"id"`
+		} `json:"results"`
+	}
+
+	// Collection is a group of related movies (e.g. 'The Matrix Collection').
+	Collection struct {
+		ID   json.Number `json:"id"`
+		Name string      `json:"name"`
 	}
 
 	Episode struct {
-		ID       json.Number `json:"id"`
-		Name     string      `json:"name"`
-		Overview string      `json:"overview"`
+		ID            json.Number `json:"id"`
+		Name          string      `json:"name"`
+		Overview      string      `json:"overview"`
+		EpisodeNumber int         `json:"episode_number"`
+		StillPath     string      `json:"still_path"`
+		// AirDate is not parsed as a Date, as TMDB returns an
+		// empty string for episodes without a known air date.
+		AirDate string `json:"air_date"`
 	}
 
 	Season struct {
-		ID       json.Number `json:"id"`
-		Name     string      `json:"name"`
-		Overview string      `json:"overview"`
+		ID           json.Number `json:"id"`
+		Name         string      `json:"name"`
+		Overview     string      `json:"overview"`
+		SeasonNumber int         `json:"season_number"`
+		Episodes     []Episode   `json:"episodes"`
+	}
+
+	// SeasonStub is the summary of a season which is included in a series.
+	SeasonStub struct {
+		ID           json.Number `json:"id"`
+		SeasonNumber int         `json:"season_number"`
+		EpisodeCount int         `json:"episode_count"`
 	}
 
 	Series struct {
-		ID       json.Number `json:"id"`
-		Adult    bool        `json:"adult"`
-		Name     string      `json:"name"`
-		Overview string      `json:"overview"`
-		Genres   []Genre     `json:"genres"`
+		ID         json.Number  `json:"id"`
+		Adult      bool         `json:"adult"`
+		Name       string       `json:"name"`
+		Overview   string       `json:"overview"`
+		Genres     []Genre      `json:"genres"`
+		Seasons    []SeasonStub `json:"seasons"`
+		PosterPath string       `json:"poster_path"`
+
+		Recommendations *RelatedResults `json:"recommendations"`
+		Similar         *RelatedResults `json:"similar"`
 	}
 
 	// tmdbSearcher is the primary search method for the Ingest and
 	// Download service to find content on the TMDB API.
 	// See https://developer.themoviedb.org/reference/intro/getting-started for
 	// information on the TMDB API.
+	//
+	// All requests to the TMDB API are subject to the concurrency limit, and are protected
+	// by a circuit breaker (see Config).
 	tmdbSearcher struct {
-		config Config
+		config      Config
+		configMutex sync.RWMutex
+		limiter     concurrencyLimiter
+		breaker     *circuitBreaker
 	}
 )
 
 func NewSearcher(config Config) *tmdbSearcher {
-	return &tmdbSearcher{config}
+	return &tmdbSearcher{
+		config:  config,
+		limiter: newConcurrencyLimiter(config.MaxConcurrentRequests),
+		breaker: newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown),
+	}
+}
+
+// ApplyConfig replaces the configuration of the searcher (e.g. to change the API key),
+// taking effect from the next request made to TMDB. Changes to the concurrency limit
+// and circuit breaker only take effect once the searcher is re-created.
+func (searcher *tmdbSearcher) ApplyConfig(config Config) {
+	searcher.configMutex.Lock()
+	defer searcher.configMutex.Unlock()
+	searcher.config = config
+}
+
+func (searcher *tmdbSearcher) apiKey() string {
+	searcher.configMutex.RLock()
+	defer searcher.configMutex.RUnlock()
+	return searcher.config.APIKey
+}
+
+// BreakerStatus returns the current state of the circuit breaker protecting requests to TMDB.
+func (searcher *tmdbSearcher) BreakerStatus() BreakerStatus {
+	return searcher.breaker.status()
+}
+
+// get performs a GET request to the TMDB API (see httpGetJSONResponse), subject to the
+// concurrency limit and circuit breaker of the searcher.
+func (searcher *tmdbSearcher) get(urlPath string, targetInterface interface{}) error {
+	searcher.limiter.acquire()
+	defer searcher.limiter.release()
+
+	if err := searcher.breaker.allow(); err != nil {
+		return err
+	}
+
+	err := httpGetJSONResponse(urlPath, targetInterface)
+	searcher.breaker.record(errors.Is(err, ErrUnavailable))
+	return err
 }
 
 // SearchForEpisode will search the TMDB API for a match using the
@@ -114,9 +211,9 @@ func (searcher *tmdbSearcher) SearchForSeries(metadata *media.FileMediaMetadata)
 	}
 
 	// Search for the series
-	path := fmt.Sprintf(tmdbSearchSeriesTemplate, tmdbBaseURL, url.QueryEscape(metadata.Title), searcher.config.APIKey)
+	path := fmt.Sprintf(tmdbSearchSeriesTemplate, tmdbBaseURL, url.QueryEscape(metadata.Title), searcher.apiKey())
 	var searchResult SearchResult
-	if err := httpGetJSONResponse(path, &searchResult); err != nil {
+	if err := searcher.get(path, &searchResult); err != nil {
 		return "", err
 	}
 
@@ -139,9 +236,9 @@ func (searcher *tmdbSearcher) SearchForMovie(metadata *media.FileMediaMetadata)
 	}
 
 	// Search for the movie stub
-	path := fmt.Sprintf(tmdbSearchMovieTemplate, tmdbBaseURL, url.QueryEscape(metadata.Title), searcher.config.APIKey)
+	path := fmt.Sprintf(tmdbSearchMovieTemplate, tmdbBaseURL, url.QueryEscape(metadata.Title), searcher.apiKey())
 	var searchResult SearchResult
-	if err := httpGetJSONResponse(path, &searchResult); err != nil {
+	if err := searcher.get(path, &searchResult); err != nil {
 		return "", err
 	}
 
@@ -155,9 +252,9 @@ func (searcher *tmdbSearcher) SearchForMovie(metadata *media.FileMediaMetadata)
 // GetMovie will query the TMDB API for the movie with the provided string ID. This ID
 // must be a valid TMDB ID, or else an error will be returned.
 func (searcher *tmdbSearcher) GetMovie(movieID string) (*Movie, error) {
-	path := fmt.Sprintf(tmdbGetMovieTemplate, tmdbBaseURL, movieID, searcher.config.APIKey)
+	path := fmt.Sprintf(tmdbGetMovieTemplate, tmdbBaseURL, movieID, searcher.apiKey())
 	var movie Movie
-	if err := httpGetJSONResponse(path, &movie); err != nil {
+	if err := searcher.get(path, &movie); err != nil {
 		return nil, err
 	}
 
@@ -167,9 +264,9 @@ func (searcher *tmdbSearcher) GetMovie(movieID string) (*Movie, error) {
 // GetSeries will query TMDB API for the series with the provided string ID. This ID
 // must be a valid TMDB ID, or else an error will be returned.
 func (searcher *tmdbSearcher) GetSeries(seriesID string) (*Series, error) {
-	path := fmt.Sprintf(tmdbGetSeriesTemplate, tmdbBaseURL, seriesID, searcher.config.APIKey)
+	path := fmt.Sprintf(tmdbGetSeriesTemplate, tmdbBaseURL, seriesID, searcher.apiKey())
 	var series Series
-	if err := httpGetJSONResponse(path, &series); err != nil {
+	if err := searcher.get(path, &series); err != nil {
 		return nil, err
 	}
 
@@ -179,9 +276,9 @@ func (searcher *tmdbSearcher) GetSeries(seriesID string) (*Series, error) {
 // GetEpisode queries TMDB using the seriesID combined with the season and episode number. It is expected
 // that the seriesID provided is a valid TMDB ID, else the request will fail.
 func (searcher *tmdbSearcher) GetEpisode(seriesID string, seasonNumber int, episodeNumber int) (*Episode, error) {
-	path := fmt.Sprintf(tmdbGetEpisodeTemplate, tmdbBaseURL, seriesID, seasonNumber, episodeNumber, searcher.config.APIKey)
+	path := fmt.Sprintf(tmdbGetEpisodeTemplate, tmdbBaseURL, seriesID, seasonNumber, episodeNumber, searcher.apiKey())
 	var episode Episode
-	if err := httpGetJSONResponse(path, &episode); err != nil {
+	if err := searcher.get(path, &episode); err != nil {
 		return nil, err
 	}
 
@@ -191,9 +288,9 @@ func (searcher *tmdbSearcher) GetEpisode(seriesID string, seasonNumber int, epis
 // GetSeason will query TMDB API for the season with the provided string ID. This ID
 // must be a valid TMDB ID, or else an error will be returned.
 func (searcher *tmdbSearcher) GetSeason(seriesID string, seasonNumber int) (*Season, error) {
-	path := fmt.Sprintf(tmdbGetSeasonTemplate, tmdbBaseURL, seriesID, seasonNumber, searcher.config.APIKey)
+	path := fmt.Sprintf(tmdbGetSeasonTemplate, tmdbBaseURL, seriesID, seasonNumber, searcher.apiKey())
 	var season Season
-	if err := httpGetJSONResponse(path, &season); err != nil {
+	if err := searcher.get(path, &season); err != nil {
 		return nil, err
 	}
 
@@ -280,18 +377,22 @@ func httpGetJSONResponse(urlPath string, targetInterface interface{}) error {
 	log.Verbosef("GET -> %s\n", urlPath)
 	resp, err := http.Get(urlPath) //nolint
 	if err != nil {
-		return &UnknownRequestError{fmt.Sprintf("failed to perform GET(%s) to TMDB: %v", urlPath, err)}
+		return fmt.Errorf("%w: %w", ErrUnavailable, &UnknownRequestError{fmt.Sprintf("failed to perform GET(%s) to TMDB: %v", urlPath, err)})
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		failure := &FailedRequestError{httpCode: resp.StatusCode, message: "non-OK response could not be unmarshalled", tmdbCode: -1}
 		var tmdbError tmdbError
-		if err := json.NewDecoder(resp.Body).Decode(&tmdbError); err != nil {
-			return &FailedRequestError{httpCode: resp.StatusCode, message: "non-OK response could not be unmarshalled", tmdbCode: -1}
+		if err := json.NewDecoder(resp.Body).Decode(&tmdbError); err == nil {
+			failure = &FailedRequestError{httpCode: resp.StatusCode, message: tmdbError.StatusMessage, tmdbCode: tmdbError.StatusCode}
 		}
 
-		return &FailedRequestError{httpCode: resp.StatusCode, message: tmdbError.StatusMessage, tmdbCode: tmdbError.StatusCode}
+		if isUnavailableStatus(resp.StatusCode) {
+			return fmt.Errorf("%w: %w", ErrUnavailable, failure)
+		}
+		return failure
 	}
 
 	if err != nil {
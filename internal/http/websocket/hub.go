@@ -183,9 +183,6 @@ func (hub *SocketHub) UpgradeToSocket(w http.ResponseWriter, r *http.Request, ca
 
 	// Register the client and open the read loop
 	hub.registerCh <- client
-	if callback != nil {
-		callback(*client, OPENED)
-	}
 
 	// Send welcome message to this client with a composed
 	// map of new-client properties.
@@ -206,6 +203,12 @@ func (hub *SocketHub) UpgradeToSocket(w http.ResponseWriter, r *http.Request, ca
 		Type:   Welcome,
 	})
 
+	// The callback is notified after the welcome message is sent, so that any
+	// messages it sends to the client are received after the welcome.
+	if callback != nil {
+		callback(*client, OPENED)
+	}
+
 	// Ensure the client is deregistered once it's read loop closes
 	// If client.Start finishes, it's either because the client disconnected
 	// or an error occurred - either way, we need to deregister it.
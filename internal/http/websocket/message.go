@@ -22,14 +22,20 @@ const (
 // so the receiving client is aware of which message this reply
 // is for. Origin is much for the same - it allows us to
 // send the reply to the websocket attached to the client
-// with the matching UUID.
+// with the matching UUID. Seq is the sequence number of
+// activity updates, which clients can use to replay the
+// updates they missed when reconnecting. Version is the
+// schema version of the arguments of the message, which
+// clients can use to detect messages they cannot parse.
 type SocketMessage struct {
-	Title  string                 `json:"title"`
-	Body   map[string]interface{} `json:"arguments"`
-	ID     int                    `json:"id"`
-	Type   SocketMessageType      `json:"type"`
-	Origin *uuid.UUID             `json:"-"`
-	Target *uuid.UUID             `json:"-"`
+	Seq     uint64                 `json:"seq,omitempty"`
+	Version int                    `json:"version,omitempty"`
+	Title   string                 `json:"title"`
+	Body    map[string]interface{} `json:"arguments"`
+	ID      int                    `json:"id"`
+	Type    SocketMessageType      `json:"type"`
+	Origin  *uuid.UUID             `json:"-"`
+	Target  *uuid.UUID             `json:"-"`
 }
 
 func (message *SocketMessage) ValidateArguments(required map[string]string) error {
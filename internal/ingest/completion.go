@@ -0,0 +1,184 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// infoHashPattern matches the info hash of a BitTorrent v1 (SHA-1) or v2 (SHA-256) torrent, hex encoded.
+var infoHashPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+var (
+	ErrPathNotAllowed  = errors.New("path is not inside of the ingest directory or a configured download root")
+	ErrInfoHashInvalid = errors.New("info hash must be a 40 or 64 character hexadecimal string")
+	ErrNoFilesFound    = errors.New("no files which have not already been ingested were found at the path")
+)
+
+// NotifyDownloadComplete is called when a download client (e.g. qBittorrent or SABnzbd) reports
+// that a download has completed. The path may be a single file, or a directory (in which case all
+// files inside are ingested), and must be inside of the ingest directory or one of the configured
+// download roots. The files are queued ahead of those discovered by polling/watching the ingest
+// directory, and are ingested once their modtime has settled (see Config.DownloadSettleSeconds)
+// rather than waiting for the usual modtime threshold.
+//
+// The optional info hash of the download is recorded against each item, and repeated notifications
+// for the same download return the existing items rather than queueing the files again.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) NotifyDownloadComplete(ctx context.Context, path string, infoHash *string) ([]*IngestItem, error) {
+	var hash *string
+	if infoHash != nil {
+		normalized := strings.ToLower(strings.TrimSpace(*infoHash))
+		if !infoHashPattern.MatchString(normalized) {
+			return nil, ErrInfoHashInvalid
+		}
+		hash = &normalized
+	}
+
+	path, err := service.resolveDownloadPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := collectFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list downloaded files: %w", err)
+	}
+
+	sourcePaths, err := service.dataStore.GetAllMediaSourcePaths()
+	if err != nil {
+		return nil, fmt.Errorf("could not query DB for existing source paths: %w", err)
+	}
+
+	service.Lock()
+	defer service.Unlock()
+
+	ingested := make(map[string]struct{}, len(sourcePaths)+len(service.rejectedPaths))
+	for _, p := range sourcePaths {
+		ingested[p] = struct{}{}
+	}
+	for p := range service.rejectedPaths {
+		ingested[p] = struct{}{}
+	}
+
+	items := make([]*IngestItem, 0, len(paths))
+	for _, p := range paths {
+		if _, ok := ingested[p]; ok {
+			continue
+		}
+
+		idx := slices.IndexFunc(service.items, func(item *IngestItem) bool { return item.Path == p })
+		if idx == -1 {
+			item := &IngestItem{ID: uuid.New(), Path: p, State: ImportHold}
+			item.log = newItemLogger(ctx, item.ID)
+			service.items = append(service.items, item)
+			idx = len(service.items) - 1
+		}
+
+		item := service.items[idx]
+		if hash != nil {
+			item.InfoHash = hash
+		}
+		items = append(items, item)
+
+		// Items already being ingested (or which have been paused/raised a trouble) are left as-is
+		if item.State != Idle && item.State != ImportHold {
+			continue
+		}
+
+		if !item.Priority {
+			item.Priority = true
+			service.enqueuePriorityItem(item)
+			item.log.Emit(logger.INFO, "Download of item %s completed, prioritizing\n", item)
+		}
+		if item.State == ImportHold {
+			service.scheduleImportHoldTimer(item.ID, 0)
+		}
+		service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+	}
+
+	if len(items) == 0 {
+		return nil, ErrNoFilesFound
+	}
+
+	return items, nil
+}
+
+// resolveDownloadPath returns the absolute path of the path provided, ensuring that it exists
+// and is inside of one of the allowed roots. Symlinks are resolved when checking the path
+// against the roots, so that a symlink cannot be used to escape the roots, however the
+// returned path is not resolved, matching the paths found by DiscoverNewFiles.
+func (service *ingestService) resolveDownloadPath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, root := range service.config.downloadRoots() {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(realRoot, realPath)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return absPath, nil
+		}
+	}
+
+	return "", ErrPathNotAllowed
+}
+
+// enqueuePriorityItem moves the item provided behind the other priority items in the queue,
+// ahead of all items which were discovered by polling/watching the ingest directory.
+func (service *ingestService) enqueuePriorityItem(item *IngestItem) {
+	if idx := slices.Index(service.items, item); idx != -1 {
+		service.items = slices.Delete(service.items, idx, idx+1)
+	}
+
+	pos := slices.IndexFunc(service.items, func(other *IngestItem) bool { return !other.Priority })
+	if pos == -1 {
+		pos = len(service.items)
+	}
+
+	service.items = slices.Insert(service.items, pos, item)
+}
+
+// collectFiles returns the path provided if it's a file, or the paths of all the files
+// inside of it (including nested directories) if it's a directory.
+func collectFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	found, err := recursivelyWalkFileSystem(path, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(found))
+	for p := range found {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+
+	return paths, nil
+}
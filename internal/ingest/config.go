@@ -15,6 +15,11 @@ type Config struct {
 	// to protect against the watcher failing.
 	ForceSyncSeconds int `toml:"force_sync_seconds" env-default:"500"`
 
+	// Changes to the ingest directory are coalesced for this long before
+	// the directory is scanned, so that a burst of changes (e.g. a
+	// season being moved in to the directory) results in a single scan.
+	WatchDebounce time.Duration `toml:"watch_debounce" env:"INGEST_WATCH_DEBOUNCE" env-default:"2s"`
+
 	// The path to the directory the service should monitor
 	// for new files
 	IngestPath string `toml:"dir_path" env:"INGEST_DIR" env-required:"true"`
@@ -30,17 +35,88 @@ type Config struct {
 	// the item to be at least this long in the past before processing
 	RequiredModTimeAgeSeconds int `toml:"modtime_threshold_seconds" env:"INGEST_MODTIME_THRESHOLD_SECONDS" env-default:"120"`
 
+	// DownloadRoots are the directories (in addition to the ingest directory) which
+	// download clients may report completed downloads inside of. Files reported as
+	// complete only need to be unmodified for DownloadSettleSeconds before being
+	// ingested, as the download client has confirmed the download is complete.
+	DownloadRoots         []string `toml:"download_roots" env:"INGEST_DOWNLOAD_ROOTS" env-separator:","`
+	DownloadSettleSeconds int      `toml:"download_settle_seconds" env:"INGEST_DOWNLOAD_SETTLE_SECONDS" env-default:"10"`
+
 	// Controls the number of workers that can perform ingestions. Reducing
 	// to 1 means one ingestion at a time.
 	// Caution should be taken to not increase this value too high, as ingestion
 	// involves talking to external APIs which may impose rate limits
 	IngestionParallelism int `toml:"parallelism" env-default:"2"`
+
+	// Controls the number of ffprobe processes which can run at once when scraping
+	// files, and how long a probe can run before it is abandoned and the
+	// ingestion of the file fails.
+	ProbeConcurrency int           `toml:"probe_concurrency" env:"INGEST_PROBE_CONCURRENCY" env-default:"4"`
+	ProbeTimeout     time.Duration `toml:"probe_timeout" env:"INGEST_PROBE_TIMEOUT" env-default:"1m"`
+
+	// Controls how a file is handled when it resolves to a movie or episode
+	// which already exists in the library with a different source file. One of
+	// 'ask' (raise a trouble), 'reject', 'keep_both' or 'replace_if_better'.
+	DuplicatePolicy DuplicatePolicy `toml:"duplicate_policy" env:"INGEST_DUPLICATE_POLICY" env-default:"ask"`
+
+	// Controls whether the episodes of series which have been marked as unmonitored are
+	// rejected (by raising an UnmonitoredSeries trouble) rather than ingested. Episodes
+	// of series which are not yet in the library are always ingested.
+	RejectUnmonitored bool `toml:"reject_unmonitored" env:"INGEST_REJECT_UNMONITORED" env-default:"false"`
+
+	// Files smaller than QuarantineMinSize bytes (zero disables this check), executables, archives
+	// using the extension of another file type, and ZIP archives containing executables are
+	// quarantined rather than ingested. Quarantined files whose name matches any of the
+	// QuarantineAutoDelete regular expressions are deleted instead.
+	QuarantineMinSize    int64    `toml:"quarantine_min_size" env:"INGEST_QUARANTINE_MIN_SIZE" env-default:"1048576"`
+	QuarantineAutoDelete []string `toml:"quarantine_auto_delete" env:"INGEST_QUARANTINE_AUTO_DELETE" env-separator:","`
+
+	// Items which cannot be ingested because TMDB is unavailable are retried after
+	// RetryBackoff, doubling with each consecutive attempt up to RetryMaxBackoff.
+	RetryBackoff    time.Duration `toml:"retry_backoff" env:"INGEST_RETRY_BACKOFF" env-default:"30s"`
+	RetryMaxBackoff time.Duration `toml:"retry_max_backoff" env:"INGEST_RETRY_MAX_BACKOFF" env-default:"30m"`
+
+	// When enabled, items which cannot be ingested because TMDB is unavailable are ingested
+	// using only the metadata parsed from their filename (rather than being retried later), and
+	// the media is marked as unverified until it is reconciled with TMDB once it's available.
+	OfflineMode bool `toml:"offline_mode" env:"INGEST_OFFLINE_MODE" env-default:"false"`
 }
 
 func (config *Config) RequiredModTimeAgeDuration() time.Duration {
 	return time.Duration(config.RequiredModTimeAgeSeconds) * time.Second
 }
 
+func (config *Config) DownloadSettleDuration() time.Duration {
+	return time.Duration(config.DownloadSettleSeconds) * time.Second
+}
+
+// retryBackoff returns the duration to wait before retrying an item which has been
+// deferred the number of times provided (see RetryBackoff).
+func (config *Config) retryBackoff(attempts int) time.Duration {
+	backoff := config.RetryBackoff
+	for range attempts {
+		if backoff >= config.RetryMaxBackoff {
+			break
+		}
+		backoff *= 2
+	}
+
+	return min(backoff, config.RetryMaxBackoff)
+}
+
+// downloadRoots returns the directories which completed downloads may be reported
+// inside of, being the ingest directory and the configured download roots.
+func (config *Config) downloadRoots() []string {
+	roots := []string{config.GetIngestPath()}
+	for _, root := range config.DownloadRoots {
+		if expanded, err := homedir.Expand(root); err == nil {
+			roots = append(roots, expanded)
+		}
+	}
+
+	return roots
+}
+
 func (config *Config) GetIngestPath() string {
 	out, err := homedir.Expand(config.IngestPath)
 	if err != nil {
@@ -0,0 +1,113 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+// DryRun is the outcome of scraping and searching for a file without ingesting it.
+type DryRun struct {
+	Path     string
+	Metadata *media.FileMediaMetadata
+	// Trouble is the trouble the file would raise if it were ingested, in which
+	// case the Media is nil.
+	Trouble *Trouble
+	// Media is the movie/episode the file matched. The media is NOT persisted,
+	// and so it's ID is not the ID the media will have once ingested.
+	Media *media.Container
+	// Duplicate is the ID of the existing media which has the same TMDB ID as
+	// the media matched (but a different source file), if any.
+	Duplicate *uuid.UUID
+}
+
+// DryRunIngest scrapes the file at the path provided, and searches TMDB for the movie/episode
+// it contains, exactly as an ingestion would, without persisting anything. The path does not
+// need to reside in the ingest directory, allowing configuration to be validated before
+// the ingest directory is changed. An error is returned if the path is not a file.
+func (service *ingestService) DryRunIngest(ctx context.Context, path string) (*DryRun, error) {
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		return nil, fmt.Errorf("path %s is a directory", path)
+	}
+
+	item := &IngestItem{ID: uuid.New(), Path: path}
+	item.log = newItemLogger(ctx, item.ID)
+	result := &DryRun{Path: path}
+
+	meta, err := service.scraper.ScrapeFileForMediaInfo(path)
+	if err != nil {
+		result.Trouble = &Trouble{error: err, tType: MetadataFailure}
+		return result, nil
+	}
+	result.Metadata = meta
+
+	var existingID uuid.UUID
+	var existingSource string
+	if meta.Episodic {
+		series, season, episode, err := item.findEpisode(meta, service.searcher)
+		if err != nil {
+			return result.withTrouble(err), nil
+		}
+		if service.config.RejectUnmonitored {
+			if err := checkMonitored(series, service.dataStore); err != nil {
+				return result.withTrouble(err), nil
+			}
+		}
+
+		ep := tmdb.TmdbEpisodeToMedia(episode, series.Adult, meta)
+		result.Media = &media.Container{
+			Type:    media.EpisodeContainerType,
+			Episode: ep,
+			Season:  tmdb.TmdbSeasonToMedia(season),
+			Series:  tmdb.TmdbSeriesToMedia(series),
+		}
+
+		existing, err := service.dataStore.GetEpisodeWithTmdbID(ep.TmdbID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		} else if existing != nil {
+			existingID, existingSource = existing.ID, existing.SourcePath
+		}
+	} else {
+		movie, err := item.findMovie(meta, service.searcher)
+		if err != nil {
+			return result.withTrouble(err), nil
+		}
+
+		mov := tmdb.TmdbMovieToMedia(movie, meta)
+		result.Media = &media.Container{Type: media.MovieContainerType, Movie: mov}
+
+		existing, err := service.dataStore.GetMovieWithTmdbID(mov.TmdbID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		} else if existing != nil {
+			existingID, existingSource = existing.ID, existing.SourcePath
+		}
+	}
+
+	if existingSource != "" && existingSource != path {
+		result.Duplicate = &existingID
+	}
+
+	return result, nil
+}
+
+func (result *DryRun) withTrouble(err error) *DryRun {
+	//nolint
+	if trbl, ok := err.(Trouble); ok {
+		result.Trouble = &trbl
+	} else {
+		trbl := newTrouble(err)
+		result.Trouble = &trbl
+	}
+
+	return result
+}
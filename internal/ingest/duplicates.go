@@ -0,0 +1,133 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+type (
+	// DuplicatePolicy controls how an ingest is handled when it resolves to a movie
+	// or episode which already exists in the library with a different source file.
+	DuplicatePolicy string
+
+	// Duplicate describes the existing media which an ingest duplicates, and
+	// is attached to DuplicateMedia troubles so the user can make a choice.
+	Duplicate struct {
+		ExistingMediaID    uuid.UUID
+		ExistingPath       string
+		ExistingResolution media.MediaResolution
+		IncomingResolution media.MediaResolution
+	}
+
+	duplicateAction int
+)
+
+const (
+	// AskDuplicatePolicy raises a DuplicateMedia trouble, allowing the user to choose
+	// whether to replace the existing source, keep both, or abort the ingest.
+	AskDuplicatePolicy DuplicatePolicy = "ask"
+
+	// RejectDuplicatePolicy discards the ingest, leaving the existing media untouched.
+	RejectDuplicatePolicy DuplicatePolicy = "reject"
+
+	// KeepBothDuplicatePolicy keeps the existing source, and stores the
+	// ingested file as an additional version of the media.
+	KeepBothDuplicatePolicy DuplicatePolicy = "keep_both"
+
+	// ReplaceIfBetterDuplicatePolicy replaces the existing source if the ingested file has
+	// a higher resolution, otherwise the ingest is discarded.
+	ReplaceIfBetterDuplicatePolicy DuplicatePolicy = "replace_if_better"
+)
+
+const (
+	askDuplicate duplicateAction = iota
+	rejectDuplicate
+	keepBothDuplicate
+	replaceDuplicate
+)
+
+// ErrDuplicateRejected is returned from an ingestion when the item duplicates existing
+// media, and the duplicate policy determined that the item should be discarded.
+var ErrDuplicateRejected = errors.New("ingest duplicates existing media and was rejected by the duplicate policy")
+
+// Validate returns an error if the policy is not one of the known policies. An
+// empty policy is valid, and is treated as AskDuplicatePolicy.
+func (policy DuplicatePolicy) Validate() error {
+	switch policy {
+	case "", AskDuplicatePolicy, RejectDuplicatePolicy, KeepBothDuplicatePolicy, ReplaceIfBetterDuplicatePolicy:
+		return nil
+	default:
+		return fmt.Errorf("unknown duplicate policy '%s'", policy)
+	}
+}
+
+func (policy DuplicatePolicy) action(existing *media.Watchable, incoming *media.Watchable) duplicateAction {
+	//exhaustive:ignore
+	switch policy {
+	case RejectDuplicatePolicy:
+		return rejectDuplicate
+	case KeepBothDuplicatePolicy:
+		return keepBothDuplicate
+	case ReplaceIfBetterDuplicatePolicy:
+		if incoming.Width*incoming.Height > existing.Width*existing.Height {
+			return replaceDuplicate
+		}
+
+		return rejectDuplicate
+	default:
+		return askDuplicate
+	}
+}
+
+// resolveDuplicate determines how the item should be handled given that it duplicates the existing
+// media provided. If the user has already made a choice (by resolving a DuplicateMedia trouble) then
+// that is used, otherwise the action is decided by the policy. If the policy leaves the choice
+// to the user, a DuplicateMedia trouble is returned.
+func (item *IngestItem) resolveDuplicate(existingID uuid.UUID, existing *media.Watchable, incoming *media.Watchable, policy DuplicatePolicy) (duplicateAction, error) {
+	if item.duplicateOverride != nil {
+		action := *item.duplicateOverride
+		item.duplicateOverride = nil
+
+		return action, nil
+	}
+
+	action := policy.action(existing, incoming)
+	if action == askDuplicate {
+		return action, Trouble{
+			error: fmt.Errorf("media %s already exists with source '%s' (%dx%d), ingested file is %dx%d",
+				existingID, existing.SourcePath, existing.Width, existing.Height, incoming.Width, incoming.Height),
+			tType: DuplicateMedia,
+			duplicate: &Duplicate{
+				ExistingMediaID:    existingID,
+				ExistingPath:       existing.SourcePath,
+				ExistingResolution: existing.MediaResolution,
+				IncomingResolution: incoming.MediaResolution,
+			},
+		}
+	}
+
+	return action, nil
+}
+
+// saveDuplicate stores the item as an additional version of the existing media. The version
+// is labelled using it's resolution (e.g. '2160p'), which can later be changed by the user
+// to describe the edition the version represents.
+func (item *IngestItem) saveDuplicate(existingID uuid.UUID, incoming *media.Watchable, data DataStore) error {
+	version := &media.Version{
+		ID:              uuid.New(),
+		MediaID:         existingID,
+		Label:           fmt.Sprintf("%dp", incoming.Height),
+		MediaResolution: incoming.MediaResolution,
+		SourcePath:      incoming.SourcePath,
+		SourceSize:      incoming.SourceSize,
+		VideoCodec:      incoming.VideoCodec,
+	}
+	if err := data.SaveMediaVersion(version); err != nil {
+		return newTrouble(err)
+	}
+
+	return nil
+}
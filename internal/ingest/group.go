@@ -0,0 +1,288 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var ErrIngestGroupNotFound = errors.New("no ingest group could be found")
+
+type (
+	// IngestGroup is a set of items which were detected to be episodes of the same season
+	// of a series (e.g. the files of a season pack). Items are assigned to a group once
+	// their metadata has been scraped, and the group is removed once it has no items.
+	//
+	// The items of a group share their TMDB series/season lookups, so that a season
+	// pack performs a single search rather than one per episode.
+	IngestGroup struct {
+		ID           uuid.UUID
+		Title        string
+		Year         int
+		SeasonNumber int
+
+		key      groupKey
+		searcher *groupSearcher
+	}
+
+	// groupKey identifies the series/season detected for an item. The title
+	// is normalised, as the casing of filenames within a pack often differs.
+	groupKey struct {
+		title  string
+		year   int
+		season int
+	}
+
+	// groupSearcher is a Searcher which caches the series and season lookups
+	// of the items in a group. Episode and movie lookups are not cached, as
+	// they differ for each item. Lookups which fail are not cached, so that
+	// retrying a troubled item repeats the lookup.
+	groupSearcher struct {
+		Searcher
+		*sync.Mutex
+		seriesID string
+		series   map[string]*tmdb.Series
+		seasons  map[string]*tmdb.Season
+	}
+)
+
+func newIngestGroup(meta *media.FileMediaMetadata, searcher Searcher) *IngestGroup {
+	return &IngestGroup{
+		ID:           uuid.New(),
+		Title:        meta.Title,
+		Year:         meta.Year,
+		SeasonNumber: meta.SeasonNumber,
+		key:          newGroupKey(meta),
+		searcher: &groupSearcher{
+			Searcher: searcher,
+			Mutex:    &sync.Mutex{},
+			series:   make(map[string]*tmdb.Series),
+			seasons:  make(map[string]*tmdb.Season),
+		},
+	}
+}
+
+func newGroupKey(meta *media.FileMediaMetadata) groupKey {
+	return groupKey{
+		title:  strings.ToLower(strings.Join(strings.Fields(meta.Title), " ")),
+		year:   meta.Year,
+		season: meta.SeasonNumber,
+	}
+}
+
+// SearchForSeries returns the series ID found by the first successful search
+// of the group, as all items of the group belong to the same series.
+func (searcher *groupSearcher) SearchForSeries(metadata *media.FileMediaMetadata) (string, error) {
+	searcher.Lock()
+	defer searcher.Unlock()
+
+	if searcher.seriesID != "" {
+		return searcher.seriesID, nil
+	}
+
+	seriesID, err := searcher.Searcher.SearchForSeries(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	searcher.seriesID = seriesID
+	return seriesID, nil
+}
+
+func (searcher *groupSearcher) GetSeries(seriesID string) (*tmdb.Series, error) {
+	searcher.Lock()
+	defer searcher.Unlock()
+
+	if series, ok := searcher.series[seriesID]; ok {
+		return series, nil
+	}
+
+	series, err := searcher.Searcher.GetSeries(seriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	searcher.series[seriesID] = series
+	return series, nil
+}
+
+func (searcher *groupSearcher) GetSeason(seriesID string, seasonNumber int) (*tmdb.Season, error) {
+	searcher.Lock()
+	defer searcher.Unlock()
+
+	key := fmt.Sprintf("%s/%d", seriesID, seasonNumber)
+	if season, ok := searcher.seasons[key]; ok {
+		return season, nil
+	}
+
+	season, err := searcher.Searcher.GetSeason(seriesID, seasonNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	searcher.seasons[key] = season
+	return season, nil
+}
+
+// overrideSeries sets the series ID used by the items of the group which
+// have yet to search for their series (see ResolveTroubledGroup).
+func (searcher *groupSearcher) overrideSeries(seriesID string) {
+	searcher.Lock()
+	defer searcher.Unlock()
+
+	searcher.seriesID = seriesID
+}
+
+// searcherFor returns the Searcher to be used to ingest the item provided. Episodes are assigned
+// to the group of the series/season detected for them (creating the group if it does not yet
+// exist), and use the searcher of that group. Movies are not grouped.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) searcherFor(item *IngestItem) Searcher {
+	service.Lock()
+	defer service.Unlock()
+
+	if item.ScrapedMetadata == nil || !item.ScrapedMetadata.Episodic {
+		return service.searcher
+	}
+
+	key := newGroupKey(item.ScrapedMetadata)
+	for _, group := range service.groups {
+		if group.key == key {
+			item.GroupID = &group.ID
+			return group.searcher
+		}
+	}
+
+	group := newIngestGroup(item.ScrapedMetadata, service.searcher)
+	service.groups = append(service.groups, group)
+	item.GroupID = &group.ID
+	item.log.Emit(logger.INFO, "Item %s assigned to new ingest group %s (%s, season %d)\n", item, group.ID, group.Title, group.SeasonNumber)
+
+	return group.searcher
+}
+
+// GetIngestGroup returns the group with the ID provided, or nil if it cannot be found.
+func (service *ingestService) GetIngestGroup(groupID uuid.UUID) *IngestGroup {
+	for _, group := range service.groups {
+		if group.ID == groupID {
+			return group
+		}
+	}
+
+	return nil
+}
+
+// GetAllIngestGroups returns all the groups of the items being processed by this service.
+func (service *ingestService) GetAllIngestGroups() []*IngestGroup {
+	return service.groups
+}
+
+// ResolveTroubledGroup resolves the trouble of each troubled item in the group provided using the
+// same resolution method and context. Items whose trouble does not permit the resolution method
+// (e.g. a duplicate within a group being assigned a TMDB ID) are left troubled. If a TMDB ID is
+// specified, it's also used by the items of the group which have yet to search for their series.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) ResolveTroubledGroup(ctx context.Context, groupID uuid.UUID, method ResolutionType, context map[string]string) error {
+	service.Lock()
+	defer service.Unlock()
+
+	group := service.GetIngestGroup(groupID)
+	if group == nil {
+		return ErrIngestGroupNotFound
+	}
+
+	resolved := 0
+	for _, item := range service.groupItems(groupID) {
+		if item.Trouble == nil || item.State != Troubled {
+			continue
+		}
+
+		res, err := item.Trouble.GenerateResolution(method, context)
+		if errors.Is(err, ErrResolutionIncompatible) {
+			continue
+		} else if res == nil || err != nil {
+			return fmt.Errorf("failed to resolve with method %v: %w", method, err)
+		}
+
+		item.log = newItemLogger(ctx, item.ID)
+		item.log.Emit(logger.INFO, "Resolving trouble for item %s using %T (group %s)\n", item, res, groupID)
+		if err := service.applyResolution(item, res); err != nil {
+			return err
+		}
+		resolved++
+	}
+
+	if resolved == 0 {
+		return ErrNoTrouble
+	}
+
+	if method == SpecifyTmdbID {
+		group.searcher.overrideSeries(context["tmdb_id"])
+	}
+
+	return nil
+}
+
+// RemoveIngestGroup removes all the items of the group provided. This fails if any
+// of the items are being ingested, in which case no items are removed.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) RemoveIngestGroup(groupID uuid.UUID) error {
+	service.Lock()
+	defer service.Unlock()
+
+	if service.GetIngestGroup(groupID) == nil {
+		return ErrIngestGroupNotFound
+	}
+
+	items := service.groupItems(groupID)
+	for _, item := range items {
+		if item.State == Ingesting {
+			return fmt.Errorf("cannot remove group %v as a worker is currently ingesting item %v", groupID, item.ID)
+		}
+	}
+
+	for _, item := range items {
+		service.clearImportHoldTimer(item.ID)
+		if err := service.removeIngest(item.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// groupItems returns the items in the group provided.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *ingestService) groupItems(groupID uuid.UUID) []*IngestItem {
+	items := make([]*IngestItem, 0)
+	for _, item := range service.items {
+		if item.GroupID != nil && *item.GroupID == groupID {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// pruneGroups removes groups which no longer contain any items.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *ingestService) pruneGroups() {
+	service.groups = slices.DeleteFunc(service.groups, func(group *IngestGroup) bool {
+		return !slices.ContainsFunc(service.items, func(item *IngestItem) bool {
+			return item.GroupID != nil && *item.GroupID == group.ID
+		})
+	})
+}
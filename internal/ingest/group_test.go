@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSearcher counts the series/season lookups performed, and fails
+// the series search if searchErr is set.
+type countingSearcher struct {
+	Searcher
+	searches, series, seasons int
+	searchErr                 error
+}
+
+func (searcher *countingSearcher) SearchForSeries(*media.FileMediaMetadata) (string, error) {
+	searcher.searches++
+	if searcher.searchErr != nil {
+		return "", searcher.searchErr
+	}
+
+	return "123", nil
+}
+
+func (searcher *countingSearcher) GetSeries(seriesID string) (*tmdb.Series, error) {
+	searcher.series++
+	return &tmdb.Series{ID: json.Number(seriesID)}, nil
+}
+
+func (searcher *countingSearcher) GetSeason(string, int) (*tmdb.Season, error) {
+	searcher.seasons++
+	return &tmdb.Season{ID: json.Number("456")}, nil
+}
+
+func Test_GroupSearcher_SharesLookups(t *testing.T) {
+	t.Parallel()
+	counter := &countingSearcher{searchErr: errors.New("test: search failed")}
+	meta := &media.FileMediaMetadata{Title: "Show", Episodic: true, SeasonNumber: 1}
+	group := newIngestGroup(meta, counter)
+
+	_, err := group.searcher.SearchForSeries(meta)
+	require.Error(t, err)
+
+	counter.searchErr = nil
+	for range 3 {
+		seriesID, err := group.searcher.SearchForSeries(meta)
+		require.NoError(t, err)
+		_, err = group.searcher.GetSeries(seriesID)
+		require.NoError(t, err)
+		_, err = group.searcher.GetSeason(seriesID, 1)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, counter.searches, "failed searches are not cached")
+	assert.Equal(t, 1, counter.series)
+	assert.Equal(t, 1, counter.seasons)
+
+	group.searcher.overrideSeries("789")
+	seriesID, err := group.searcher.SearchForSeries(meta)
+	require.NoError(t, err)
+	assert.Equal(t, "789", seriesID)
+}
+
+func Test_SearcherFor_GroupsEpisodesBySeason(t *testing.T) {
+	t.Parallel()
+	service := &ingestService{Mutex: &sync.Mutex{}, searcher: &countingSearcher{}}
+	newItem := func(meta media.FileMediaMetadata) *IngestItem {
+		item := &IngestItem{ID: uuid.New(), ScrapedMetadata: &meta, log: logger.Get("Test")}
+		service.items = append(service.items, item)
+		return item
+	}
+
+	first := newItem(media.FileMediaMetadata{Title: "The Show", Episodic: true, SeasonNumber: 1, EpisodeNumber: 1})
+	second := newItem(media.FileMediaMetadata{Title: "the  show", Episodic: true, SeasonNumber: 1, EpisodeNumber: 2})
+	otherSeason := newItem(media.FileMediaMetadata{Title: "The Show", Episodic: true, SeasonNumber: 2, EpisodeNumber: 1})
+	movie := newItem(media.FileMediaMetadata{Title: "The Movie"})
+
+	assert.Same(t, service.searcherFor(first), service.searcherFor(second))
+	assert.NotSame(t, service.searcherFor(first), service.searcherFor(otherSeason))
+	assert.Equal(t, service.searcher, service.searcherFor(movie))
+
+	require.NotNil(t, first.GroupID)
+	assert.Equal(t, *first.GroupID, *second.GroupID)
+	assert.Nil(t, movie.GroupID)
+	assert.Len(t, service.groups, 2)
+
+	require.NoError(t, service.removeIngest(otherSeason.ID))
+	assert.Len(t, service.groups, 1, "groups without items are removed")
+	require.NoError(t, service.RemoveIngestGroup(*first.GroupID))
+	assert.Empty(t, service.groups)
+	assert.Equal(t, []*IngestItem{movie}, service.items)
+}
@@ -1,6 +1,7 @@
 package ingest
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"os"
@@ -22,6 +23,48 @@ type (
 		Trouble         *Trouble
 		ScrapedMetadata *media.FileMediaMetadata
 		OverrideTmdbID  *string
+
+		// GroupID is the ID of the group this item belongs to (see IngestGroup). Items
+		// are only grouped once their metadata has been scraped.
+		GroupID *uuid.UUID
+
+		// Priority is set when a download client reports that the download of the file
+		// has completed, and causes the item to be ingested ahead of other items.
+		Priority bool
+		InfoHash *string
+
+		// Wanted is set when the filename of the item matches an episode missing from a
+		// monitored series, and causes the item to be ingested ahead of other items
+		// discovered in the ingest directory (but behind Priority items).
+		Wanted bool
+
+		// Quarantine is set when the item failed the sanity checks performed before it's
+		// ingested, and describes why the item was quarantined.
+		Quarantine *Quarantine
+
+		// RetryAt is set while the item is on RETRY_LATER, and is the time
+		// the ingestion of the item will be retried.
+		RetryAt *time.Time
+
+		// Stages records the timing of each stage performed by the
+		// most recent attempt to ingest this item.
+		Stages []StageTiming
+
+		// duplicateOverride is the action chosen by the user when resolving
+		// a DuplicateMedia trouble, and is used instead of the duplicate policy.
+		duplicateOverride *duplicateAction
+
+		// retryAttempts is the number of consecutive times the ingestion of the item has
+		// been deferred as TMDB is unavailable, and determines the backoff.
+		retryAttempts int
+
+		// released is set when the item is released from quarantine, and
+		// prevents the sanity checks being performed again.
+		released bool
+
+		// log is scoped to this item, so that all log lines
+		// emitted while ingesting it can be correlated.
+		log logger.Logger
 	}
 )
 
@@ -31,6 +74,9 @@ const (
 	Ingesting
 	Troubled
 	Complete
+	Paused
+	Quarantined
+	RetryLater
 )
 
 var (
@@ -39,123 +85,223 @@ var (
 	ErrResolutionIncompatible        = errors.New("provided resolution method is not valid for ingestion trouble")
 	ErrResolutionIncomplete          = errors.New("provided resolution context is missing information required to resolve the trouble")
 	ErrResolutionContextIncompatible = errors.New("trouble resolution failed, consult logs for further information")
+	ErrIngestNotPausable             = errors.New("only idle or import held ingests can be paused")
+	ErrIngestNotPaused               = errors.New("ingest is not paused")
+	ErrIngestNotQuarantined          = errors.New("ingest is not quarantined")
+	ErrInvalidParallelism            = errors.New("ingest parallelism must be at least 1")
 )
 
-// ingest is the main task for an ingest task which:
-// - Scrapes the metadata from the file
-// - Searches TMDB for a match
-// - Saves the episode/movie to the database
-// Any of the above can encounter an error - if the error can be cast to the
-// IngestItemTrouble type then it should be raised as a TROUBLE on the item.
-func (item *IngestItem) ingest(eventBus event.EventCoordinator, scraper Scraper, searcher Searcher, data DataStore) error {
-	log.Emit(logger.NEW, "Beginning ingestion of item %s\n", item)
+// scrape is the first step of ingesting an item, which scrapes the metadata from the file (unless
+// it was scraped by a previous attempt). The metadata is required to assign the item to it's
+// group (see IngestGroup) before the remainder of the ingestion is performed. Any error
+// returned is an IngestItemTrouble.
+func (item *IngestItem) scrape(eventBus event.EventCoordinator, scraper Scraper) error {
+	item.log.Emit(logger.NEW, "Beginning ingestion of item %s\n", item)
+	item.Stages = make([]StageTiming, 0, len(allStages))
 	if item.ScrapedMetadata == nil {
-		log.Emit(logger.DEBUG, "Performing file system scrape of %s\n", item.Path)
+		item.beginStage(Scraping, eventBus)
+		item.log.Emit(logger.DEBUG, "Performing file system scrape of %s\n", item.Path)
 		if meta, err := scraper.ScrapeFileForMediaInfo(item.Path); err != nil {
 			return Trouble{error: err, tType: MetadataFailure}
 		} else if meta == nil {
 			return Trouble{error: errors.New("metadata scrape returned no error, but nil payload received"), tType: MetadataFailure}
 		} else {
-			log.Emit(logger.WARNING, "Scraped metadata for item %s:\n%s\n", item, meta)
+			item.log.Emit(logger.WARNING, "Scraped metadata for item %s:\n%s\n", item, meta)
 			item.ScrapedMetadata = meta
 		}
 	}
 
+	return nil
+}
+
+// ingest is the main task for an ingest task which, once the item has been scraped:
+// - Searches TMDB for a match
+// - Rejects episodes of unmonitored series (if configured to)
+// - Saves the episode/movie to the database
+// Any of the above can encounter an error - if the error can be cast to the
+// IngestItemTrouble type then it should be raised as a TROUBLE on the item.
+func (item *IngestItem) ingest(eventBus event.EventCoordinator, searcher Searcher, data DataStore, duplicatePolicy DuplicatePolicy, rejectUnmonitored bool) error {
 	meta := item.ScrapedMetadata
 	if item.ScrapedMetadata.Episodic {
-		return item.ingestEpisode(meta, data, searcher, eventBus)
+		return item.ingestEpisode(meta, data, searcher, eventBus, duplicatePolicy, rejectUnmonitored)
 	} else {
-		return item.ingestMovie(meta, data, searcher, eventBus)
+		return item.ingestMovie(meta, data, searcher, eventBus, duplicatePolicy)
+	}
+}
+
+func (item *IngestItem) ingestEpisode(meta *media.FileMediaMetadata, data DataStore, searcher Searcher, eventBus event.EventDispatcher, duplicatePolicy DuplicatePolicy, rejectUnmonitored bool) error {
+	item.beginStage(Searching, eventBus)
+	series, season, episode, err := item.findEpisode(meta, searcher)
+	if err != nil {
+		return err
+	}
+
+	if rejectUnmonitored {
+		if err := checkMonitored(series, data); err != nil {
+			return err
+		}
+	}
+
+	item.beginStage(Persisting, eventBus)
+
+	ep := tmdb.TmdbEpisodeToMedia(episode, series.Adult, item.ScrapedMetadata)
+	existing, err := data.GetEpisodeWithTmdbID(ep.TmdbID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return newTrouble(err)
+	}
+	if existing != nil && existing.SourcePath != ep.SourcePath {
+		if handled, err := item.handleDuplicate(existing.ID, &existing.Watchable, &ep.Watchable, data, duplicatePolicy); handled || err != nil {
+			return err
+		}
+	}
+
+	item.log.Emit(logger.DEBUG, "Saving TMDB EPISODE: %v\nSEASON: %v\nSERIES: %v\n", episode, season, series)
+	if err := data.SaveEpisode(
+		ep,
+		tmdb.TmdbSeasonToMedia(season),
+		tmdb.TmdbSeriesToMedia(series),
+	); err != nil {
+		return newTrouble(err)
+	}
+
+	item.log.Emit(logger.SUCCESS, "Saved newly ingested episode %v\n", ep)
+	eventBus.Dispatch(event.NewMediaEvent, ep.ID)
+	return nil
+}
+
+func (item *IngestItem) ingestMovie(meta *media.FileMediaMetadata, data DataStore, searcher Searcher, eventBus event.EventDispatcher, duplicatePolicy DuplicatePolicy) error {
+	item.beginStage(Searching, eventBus)
+	movie, err := item.findMovie(meta, searcher)
+	if err != nil {
+		return err
 	}
+
+	item.beginStage(Persisting, eventBus)
+
+	mov := tmdb.TmdbMovieToMedia(movie, meta)
+	existing, err := data.GetMovieWithTmdbID(mov.TmdbID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return newTrouble(err)
+	}
+	if existing != nil && existing.SourcePath != mov.SourcePath {
+		if handled, err := item.handleDuplicate(existing.ID, &existing.Watchable, &mov.Watchable, data, duplicatePolicy); handled || err != nil {
+			return err
+		}
+	}
+
+	item.log.Emit(logger.DEBUG, "Saving newly ingested MOVIE: %v\n", movie)
+	if err := data.SaveMovie(mov); err != nil {
+		return newTrouble(err)
+	}
+
+	item.log.Emit(logger.SUCCESS, "Saved newly ingested movie %v\n", mov)
+	eventBus.Dispatch(event.NewMediaEvent, mov.ID)
+
+	return nil
 }
 
-func (item *IngestItem) ingestEpisode(meta *media.FileMediaMetadata, data DataStore, searcher Searcher, eventBus event.EventDispatcher) error {
+// findEpisode searches TMDB for the series, season and episode described by the metadata
+// provided, unless the item has a TMDB ID override (from a trouble resolution), in which case
+// the series with that ID is used. Any error returned is an IngestItemTrouble.
+func (item *IngestItem) findEpisode(meta *media.FileMediaMetadata, searcher Searcher) (*tmdb.Series, *tmdb.Season, *tmdb.Episode, error) {
 	var series *tmdb.Series
 	if item.OverrideTmdbID != nil {
 		// This item WAS troubled, but a resolution has provided a new value for the TMDB ID which we should use now.
 		tmdbID := *item.OverrideTmdbID
 		item.OverrideTmdbID = nil
 
-		log.Emit(logger.INFO, "Retrying ingestion item %s with provided TMDB ID override (from trouble resolution) of %s\n", item, tmdbID)
+		item.log.Emit(logger.INFO, "Retrying ingestion item %s with provided TMDB ID override (from trouble resolution) of %s\n", item, tmdbID)
 		if found, err := searcher.GetSeries(tmdbID); err != nil {
-			return newTrouble(err)
+			return nil, nil, nil, newTrouble(err)
 		} else {
 			series = found
 		}
 	} else {
 		seriesID, err := searcher.SearchForSeries(meta)
 		if err != nil {
-			return newTrouble(err)
+			return nil, nil, nil, newTrouble(err)
 		}
 
 		found, err := searcher.GetSeries(seriesID)
 		if err != nil {
-			return newTrouble(err)
+			return nil, nil, nil, newTrouble(err)
 		}
 		series = found
 	}
 
 	season, err := searcher.GetSeason(series.ID.String(), meta.SeasonNumber)
 	if err != nil {
-		return newTrouble(err)
+		return nil, nil, nil, newTrouble(err)
 	}
 
 	episode, err := searcher.GetEpisode(series.ID.String(), meta.SeasonNumber, meta.EpisodeNumber)
 	if err != nil {
-		return newTrouble(err)
-	}
-
-	log.Emit(logger.DEBUG, "Saving TMDB EPISODE: %v\nSEASON: %v\nSERIES: %v\n", episode, season, series)
-	ep := tmdb.TmdbEpisodeToMedia(episode, series.Adult, item.ScrapedMetadata)
-	if err := data.SaveEpisode(
-		ep,
-		tmdb.TmdbSeasonToMedia(season),
-		tmdb.TmdbSeriesToMedia(series),
-	); err != nil {
-		return newTrouble(err)
+		return nil, nil, nil, newTrouble(err)
 	}
 
-	log.Emit(logger.SUCCESS, "Saved newly ingested episode %v\n", ep)
-	eventBus.Dispatch(event.NewMediaEvent, ep.ID)
-	return nil
+	return series, season, episode, nil
 }
 
-func (item *IngestItem) ingestMovie(meta *media.FileMediaMetadata, data DataStore, searcher Searcher, eventBus event.EventDispatcher) error {
-	var movie *tmdb.Movie
+// findMovie searches TMDB for the movie described by the metadata provided, unless the item has a
+// TMDB ID override (from a trouble resolution), in which case the movie with that ID is used. Any
+// error returned is an IngestItemTrouble.
+func (item *IngestItem) findMovie(meta *media.FileMediaMetadata, searcher Searcher) (*tmdb.Movie, error) {
 	if item.OverrideTmdbID != nil {
 		// This item WAS troubled, but a resolution has provided a new value for the TMDB ID which we should use now.
 		tmdbID := *item.OverrideTmdbID
 		item.OverrideTmdbID = nil
 
-		log.Emit(logger.INFO, "Retrying ingestion item %s with provided TMDB ID override (from trouble resolution) of %s\n", item, tmdbID)
-		if found, err := searcher.GetMovie(tmdbID); err != nil {
-			return newTrouble(err)
-		} else {
-			movie = found
-		}
-	} else {
-		movieID, err := searcher.SearchForMovie(item.ScrapedMetadata)
+		item.log.Emit(logger.INFO, "Retrying ingestion item %s with provided TMDB ID override (from trouble resolution) of %s\n", item, tmdbID)
+		movie, err := searcher.GetMovie(tmdbID)
 		if err != nil {
-			return newTrouble(err)
+			return nil, newTrouble(err)
 		}
 
-		found, err := searcher.GetMovie(movieID)
-		if err != nil {
-			return newTrouble(err)
-		}
-		movie = found
+		return movie, nil
 	}
 
-	log.Emit(logger.DEBUG, "Saving newly ingested MOVIE: %v\n", movie)
-	mov := tmdb.TmdbMovieToMedia(movie, meta)
-	if err := data.SaveMovie(mov); err != nil {
-		return newTrouble(err)
+	movieID, err := searcher.SearchForMovie(meta)
+	if err != nil {
+		return nil, newTrouble(err)
 	}
 
-	log.Emit(logger.SUCCESS, "Saved newly ingested movie %v\n", mov)
-	eventBus.Dispatch(event.NewMediaEvent, mov.ID)
+	movie, err := searcher.GetMovie(movieID)
+	if err != nil {
+		return nil, newTrouble(err)
+	}
 
-	return nil
+	return movie, nil
+}
+
+// handleDuplicate decides how to handle the item given that it duplicates the existing media provided
+// (see resolveDuplicate). True is returned if the duplicate has been handled (kept as an additional
+// version of the existing media), in which case the media should not be saved. If the existing
+// source should be replaced, false is returned and the media should be saved as normal.
+func (item *IngestItem) handleDuplicate(existingID uuid.UUID, existing *media.Watchable, incoming *media.Watchable, data DataStore, policy DuplicatePolicy) (bool, error) {
+	action, err := item.resolveDuplicate(existingID, existing, incoming, policy)
+	if err != nil {
+		return true, err
+	}
+
+	//exhaustive:enforce
+	switch action {
+	case replaceDuplicate:
+		item.log.Emit(logger.INFO, "Item %s duplicates media %s, replacing existing source '%s'\n", item, existingID, existing.SourcePath)
+		return false, nil
+	case keepBothDuplicate:
+		if err := item.saveDuplicate(existingID, incoming, data); err != nil {
+			return true, err
+		}
+
+		item.log.Emit(logger.SUCCESS, "Item %s duplicates media %s, saved as an additional version\n", item, existingID)
+		return true, nil
+	case rejectDuplicate:
+		return true, fmt.Errorf("%w: media %s already exists with source '%s'", ErrDuplicateRejected, existingID, existing.SourcePath)
+	case askDuplicate:
+		// resolveDuplicate raises a trouble rather than returning this action
+	}
+
+	panic("unreachable")
 }
 
 func (item *IngestItem) modtimeDiff() (*time.Duration, error) {
@@ -184,6 +330,12 @@ func (s IngestItemState) String() string {
 		return fmt.Sprintf("TROUBLED[%d]", s)
 	case Complete:
 		return fmt.Sprintf("COMPLETE[%d]", s)
+	case Paused:
+		return fmt.Sprintf("PAUSED[%d]", s)
+	case Quarantined:
+		return fmt.Sprintf("QUARANTINED[%d]", s)
+	case RetryLater:
+		return fmt.Sprintf("RETRY_LATER[%d]", s)
 	default:
 		return fmt.Sprintf("UNKNOWN[%d]", s)
 	}
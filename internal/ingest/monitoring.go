@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+type (
+	// wantedEpisodes is the set of episodes missing from monitored series, used to
+	// prioritize newly discovered files which match one of these episodes.
+	wantedEpisodes map[wantedKey]struct{}
+
+	// wantedKey identifies an episode using the normalised title of it's series, as the
+	// title parsed from a filename rarely matches the title of the series exactly.
+	wantedKey struct {
+		title   string
+		season  int
+		episode int
+	}
+)
+
+// loadWantedEpisodes returns the episodes missing from monitored series. Failure to load the
+// episodes is logged, and results in no items being prioritized.
+func (service *ingestService) loadWantedEpisodes() wantedEpisodes {
+	episodes, err := service.dataStore.ListWantedEpisodes()
+	if err != nil {
+		log.Warnf("Failed to list wanted episodes, discovered items will not be prioritized: %v\n", err)
+		return nil
+	}
+
+	wanted := make(wantedEpisodes, len(episodes))
+	for _, ep := range episodes {
+		wanted[wantedKey{normaliseTitle(ep.SeriesTitle), ep.SeasonNumber, ep.EpisodeNumber}] = struct{}{}
+	}
+
+	return wanted
+}
+
+// matches returns true if the filename of the path provided describes an episode (or, for multi-episode
+// files, any of the episodes) which is wanted. Only the filename is considered, as the file has
+// not been scraped when it's discovered.
+func (wanted wantedEpisodes) matches(path string) bool {
+	if len(wanted) == 0 {
+		return false
+	}
+
+	parsed, err := media.ParseFilename(filepath.Base(path))
+	if err != nil || !parsed.Episodic() || parsed.AbsoluteEpisode {
+		return false
+	}
+
+	title := normaliseTitle(parsed.Title)
+	for _, episode := range parsed.Episodes {
+		if _, ok := wanted[wantedKey{title, parsed.Season, episode}]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enqueueWantedItem moves the item provided behind the priority and wanted items in the
+// queue, ahead of all other items discovered by polling/watching the ingest directory.
+func (service *ingestService) enqueueWantedItem(item *IngestItem) {
+	if idx := slices.Index(service.items, item); idx != -1 {
+		service.items = slices.Delete(service.items, idx, idx+1)
+	}
+
+	pos := slices.IndexFunc(service.items, func(other *IngestItem) bool { return !other.Priority && !other.Wanted })
+	if pos == -1 {
+		pos = len(service.items)
+	}
+
+	service.items = slices.Insert(service.items, pos, item)
+}
+
+// checkMonitored returns an UnmonitoredSeries trouble if the series provided exists in the
+// library and has been marked as unmonitored. Any error returned is an IngestItemTrouble.
+func checkMonitored(series *tmdb.Series, data DataStore) error {
+	existing, err := data.GetSeriesWithTmdbID(series.ID.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return newTrouble(err)
+	}
+
+	if existing.Monitored {
+		return nil
+	}
+
+	return Trouble{
+		error: fmt.Errorf("series %s (%s) is not monitored, monitor the series and retry to ingest this episode", existing.ID, existing.Title),
+		tType: UnmonitoredSeries,
+	}
+}
+
+// normaliseTitle lowercases the title provided, and removes all characters besides
+// letters and digits (e.g. 'Marvel's Agents of S.H.I.E.L.D.' becomes 'marvelsagentsofshield').
+func normaliseTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return -1
+	}, title)
+}
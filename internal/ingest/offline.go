@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// ingestOffline saves the item using only the metadata scraped from the file (i.e. the title, year,
+// season and episode parsed from the filename), for use when TMDB is unavailable and offline mode
+// is enabled (see Config.OfflineMode). The media is saved with a placeholder TMDB ID, marking it
+// as unverified until it is reconciled with TMDB once TMDB is available. Any error
+// returned is an IngestItemTrouble.
+func (item *IngestItem) ingestOffline(eventBus event.EventDispatcher, data DataStore, duplicatePolicy DuplicatePolicy) error {
+	item.beginStage(Persisting, eventBus)
+
+	meta := item.ScrapedMetadata
+	title := normaliseTitle(meta.Title)
+	watchable := media.Watchable{
+		MediaResolution: media.MediaResolution{Width: meta.FrameW, Height: meta.FrameH},
+		SourcePath:      meta.Path,
+		SourceSize:      meta.Size,
+		VideoCodec:      meta.VideoCodec,
+		Checksum:        &meta.Checksum,
+	}
+
+	if !meta.Episodic {
+		movie := &media.Movie{
+			Model:     media.Model{ID: uuid.New(), TmdbID: media.UnverifiedTmdbID("movie", title, meta.Year), Title: meta.Title},
+			Watchable: watchable,
+		}
+
+		existing, err := data.GetMovieWithTmdbID(movie.TmdbID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return newTrouble(err)
+		}
+		if existing != nil && existing.SourcePath != movie.SourcePath {
+			if handled, err := item.handleDuplicate(existing.ID, &existing.Watchable, &movie.Watchable, data, duplicatePolicy); handled || err != nil {
+				return err
+			}
+		}
+
+		if err := data.SaveMovie(movie); err != nil {
+			return newTrouble(err)
+		}
+
+		item.log.Emit(logger.SUCCESS, "Saved newly ingested movie %v without TMDB metadata (unverified)\n", movie)
+		eventBus.Dispatch(event.NewMediaEvent, movie.ID)
+		return nil
+	}
+
+	series := &media.Series{
+		Model: media.Model{ID: uuid.New(), TmdbID: media.UnverifiedTmdbID("series", title), Title: meta.Title},
+	}
+	season := &media.Season{
+		Model:        media.Model{ID: uuid.New(), TmdbID: media.UnverifiedTmdbID("season", title, meta.SeasonNumber), Title: fmt.Sprintf("Season %d", meta.SeasonNumber)},
+		SeasonNumber: meta.SeasonNumber,
+	}
+	episode := &media.Episode{
+		Model:         media.Model{ID: uuid.New(), TmdbID: media.UnverifiedTmdbID("episode", title, meta.SeasonNumber, meta.EpisodeNumber), Title: fmt.Sprintf("Episode %d", meta.EpisodeNumber)},
+		Watchable:     watchable,
+		EpisodeNumber: meta.EpisodeNumber,
+	}
+
+	existing, err := data.GetEpisodeWithTmdbID(episode.TmdbID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return newTrouble(err)
+	}
+	if existing != nil && existing.SourcePath != episode.SourcePath {
+		if handled, err := item.handleDuplicate(existing.ID, &existing.Watchable, &episode.Watchable, data, duplicatePolicy); handled || err != nil {
+			return err
+		}
+	}
+
+	if err := data.SaveEpisode(episode, season, series); err != nil {
+		return newTrouble(err)
+	}
+
+	item.log.Emit(logger.SUCCESS, "Saved newly ingested episode %v without TMDB metadata (unverified)\n", episode)
+	eventBus.Dispatch(event.NewMediaEvent, episode.ID)
+	return nil
+}
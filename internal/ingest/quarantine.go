@@ -0,0 +1,253 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type (
+	// Quarantine describes why an item failed the sanity checks performed before it's
+	// ingested (see sanityCheck). Quarantined items are not ingested until they are
+	// released, and their source file is never modified unless deleted.
+	Quarantine struct {
+		Reason        string
+		QuarantinedAt time.Time
+	}
+
+	// signature is the sequence of bytes found at the start of a particular kind of file.
+	signature struct {
+		kind  string
+		magic []byte
+	}
+)
+
+// magicHeaderSize is the number of bytes read from the start of a file to detect
+// it's type, which must be large enough for the longest signature below.
+const magicHeaderSize = 8
+
+var (
+	executableSignatures = []signature{
+		{"Windows executable", []byte("MZ")},
+		{"ELF executable", []byte("\x7fELF")},
+		{"Mach-O executable", []byte{0xfe, 0xed, 0xfa, 0xce}},
+		{"Mach-O executable", []byte{0xce, 0xfa, 0xed, 0xfe}},
+		{"Mach-O executable", []byte{0xfe, 0xed, 0xfa, 0xcf}},
+		{"Mach-O executable", []byte{0xcf, 0xfa, 0xed, 0xfe}},
+		{"Mach-O universal binary", []byte{0xca, 0xfe, 0xba, 0xbe}},
+		{"script", []byte("#!")},
+	}
+
+	zipSignature      = signature{"ZIP archive", []byte("PK\x03\x04")}
+	archiveSignatures = []signature{
+		zipSignature,
+		{"RAR archive", []byte("Rar!\x1a\x07")},
+		{"7z archive", []byte("7z\xbc\xaf\x27\x1c")},
+		{"gzip archive", []byte{0x1f, 0x8b}},
+	}
+
+	// archiveExtensions are the extensions which truthfully describe an archive, all other
+	// extensions (e.g. '.mkv') are considered fake when the file is an archive.
+	archiveExtensions = map[string]struct{}{
+		".zip": {}, ".rar": {}, ".7z": {}, ".gz": {}, ".tgz": {},
+	}
+
+	// executableExtensions are the extensions of files which are considered
+	// executable when found inside of an archive.
+	executableExtensions = map[string]struct{}{
+		".exe": {}, ".msi": {}, ".bat": {}, ".cmd": {}, ".com": {}, ".scr": {}, ".pif": {},
+		".vbs": {}, ".js": {}, ".jar": {}, ".ps1": {}, ".sh": {}, ".lnk": {}, ".dll": {},
+	}
+)
+
+// sanityCheck inspects the file at the path provided, returning a (human readable)
+// reason for the file to be quarantined if it appears suspicious, or an empty
+// string if the file can be ingested. A file is suspicious if:
+// - It's smaller than the minimum size provided (zero disables this check)
+// - It's content is an executable, regardless of it's extension
+// - It's content is an archive, but it's extension is not that of an archive
+// - It's a ZIP archive which contains executables
+func sanityCheck(path string, minSize int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if minSize > 0 && info.Size() < minSize {
+		return fmt.Sprintf("file is %d bytes, which is smaller than the minimum size of %d bytes", info.Size(), minSize), nil
+	}
+
+	header := make([]byte, magicHeaderSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	header = header[:n]
+
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(header, sig.magic) {
+			return fmt.Sprintf("file is a %s", sig.kind), nil
+		}
+	}
+
+	for _, sig := range archiveSignatures {
+		if !bytes.HasPrefix(header, sig.magic) {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if _, ok := archiveExtensions[ext]; !ok {
+			return fmt.Sprintf("file is a %s, but has the extension '%s'", sig.kind, ext), nil
+		}
+
+		if sig.kind == zipSignature.kind {
+			return checkZipEntries(file, info.Size())
+		}
+
+		return "", nil
+	}
+
+	return "", nil
+}
+
+// checkZipEntries returns a reason for the ZIP archive provided to be quarantined
+// if any of the files inside of it are executables.
+func checkZipEntries(file io.ReaderAt, size int64) (string, error) {
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Sprintf("file is a malformed ZIP archive: %v", err), nil
+	}
+
+	for _, entry := range archive.File {
+		if _, ok := executableExtensions[strings.ToLower(filepath.Ext(entry.Name))]; ok {
+			return fmt.Sprintf("archive contains the executable '%s'", entry.Name), nil
+		}
+	}
+
+	return "", nil
+}
+
+// compileAutoDeletePatterns compiles the patterns provided, which are
+// matched against the name of each quarantined file (see quarantine).
+func compileAutoDeletePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("quarantine auto-delete pattern '%s' is invalid: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// quarantine places the item provided in to quarantine for the reason given. If the name of
+// the item's source file matches any of the auto-delete patterns, the file is deleted and the
+// item completed instead. Should the deletion fail, the item is quarantined as normal.
+func (service *ingestService) quarantine(item *IngestItem, reason string) {
+	name := filepath.Base(item.Path)
+	for _, pattern := range service.autoDelete {
+		if !pattern.MatchString(name) {
+			continue
+		}
+
+		if err := os.Remove(item.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			item.log.Emit(logger.ERROR, "Failed to auto-delete suspicious item %s (matched pattern '%s'): %v\n", item, pattern, err)
+			break
+		}
+
+		item.log.Emit(logger.WARNING, "Deleted suspicious item %s (matched pattern '%s'): %s\n", item, pattern, reason)
+		item.State = Complete
+		service.eventBus.Dispatch(event.IngestCompleteEvent, item.ID)
+		return
+	}
+
+	item.log.Emit(logger.WARNING, "Quarantined suspicious item %s: %s\n", item, reason)
+	item.Quarantine = &Quarantine{Reason: reason, QuarantinedAt: time.Now()}
+	item.State = Quarantined
+	service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+}
+
+// GetQuarantinedIngests returns the items which are currently quarantined.
+func (service *ingestService) GetQuarantinedIngests() []*IngestItem {
+	service.Lock()
+	defer service.Unlock()
+
+	quarantined := make([]*IngestItem, 0)
+	for _, item := range service.items {
+		if item.State == Quarantined {
+			quarantined = append(quarantined, item)
+		}
+	}
+
+	return quarantined
+}
+
+// ReleaseIngest releases the quarantined item with the ID provided, allowing it
+// to be ingested without being subjected to the sanity checks again.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) ReleaseIngest(itemID uuid.UUID) error {
+	service.Lock()
+	defer service.Unlock()
+
+	item := service.GetIngest(itemID)
+	if item == nil {
+		return ErrIngestNotFound
+	}
+
+	if item.State != Quarantined {
+		return ErrIngestNotQuarantined
+	}
+
+	item.State = Idle
+	item.Quarantine = nil
+	item.released = true
+	item.log.Emit(logger.INFO, "Released item %s from quarantine\n", item)
+
+	service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+	service.wakeupWorkerPool()
+	return nil
+}
+
+// DeleteQuarantinedIngest deletes the source file of the quarantined item with
+// the ID provided from the file system, and removes the item.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) DeleteQuarantinedIngest(itemID uuid.UUID) error {
+	service.Lock()
+	defer service.Unlock()
+
+	item := service.GetIngest(itemID)
+	if item == nil {
+		return ErrIngestNotFound
+	}
+
+	if item.State != Quarantined {
+		return ErrIngestNotQuarantined
+	}
+
+	if err := os.Remove(item.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete quarantined file '%s': %w", item.Path, err)
+	}
+
+	item.log.Emit(logger.INFO, "Deleted quarantined item %s\n", item)
+	return service.removeIngest(item.ID)
+}
@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// deferItem places the item provided on RETRY_LATER, as it could not be ingested because TMDB is
+// unavailable. Rather than raising a trouble (which would require the user to retry the item once
+// TMDB recovers), the item is automatically retried once the backoff has elapsed. The backoff
+// doubles with each consecutive attempt, up to the maximum configured (see Config.RetryBackoff).
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) deferItem(item *IngestItem, err error) {
+	service.Lock()
+	defer service.Unlock()
+
+	delay := service.config.retryBackoff(item.retryAttempts)
+	item.retryAttempts++
+
+	retryAt := time.Now().Add(delay)
+	item.RetryAt = &retryAt
+	item.State = RetryLater
+	item.log.Emit(logger.WARNING, "Ingestion of item %s deferred as TMDB is unavailable (attempt %d), retrying in %s: %v\n", item, item.retryAttempts, delay, err)
+
+	service.clearRetryTimer(item.ID)
+	service.retryTimers[item.ID] = time.AfterFunc(delay, func() {
+		service.retryDeferredItem(item.ID)
+	})
+}
+
+// retryDeferredItem returns the item with the ID provided to IDLE, if it's still
+// on RETRY_LATER, so that it is claimed by a worker.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) retryDeferredItem(id uuid.UUID) {
+	service.Lock()
+	defer service.Unlock()
+
+	delete(service.retryTimers, id)
+	item := service.GetIngest(id)
+	if item == nil || item.State != RetryLater {
+		return
+	}
+
+	item.State = Idle
+	item.RetryAt = nil
+	service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+	service.wakeupWorkerPool()
+}
+
+// clearRetryTimer cancels and deletes the retry timer associated with the item ID specified.
+func (service *ingestService) clearRetryTimer(id uuid.UUID) {
+	if timer, ok := service.retryTimers[id]; ok {
+		timer.Stop()
+		delete(service.retryTimers, id)
+	}
+}
+
+// clearAllRetryTimers cancels and deletes the retry timers for all items.
+func (service *ingestService) clearAllRetryTimers() {
+	for key, timer := range service.retryTimers {
+		timer.Stop()
+		delete(service.retryTimers, key)
+	}
+}
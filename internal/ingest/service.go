@@ -7,6 +7,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sync"
 	"time"
 
@@ -16,7 +18,6 @@ import (
 	"github.com/hbomb79/Thea/internal/media"
 	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/hbomb79/Thea/pkg/worker"
-	"github.com/rjeczalik/notify"
 )
 
 var log = logger.Get("IngestServ")
@@ -40,9 +41,12 @@ type (
 		GetSeasonWithTmdbID(seasonID string) (*media.Season, error)
 		GetSeriesWithTmdbID(seriesID string) (*media.Series, error)
 		GetEpisodeWithTmdbID(episodeID string) (*media.Episode, error)
+		GetMovieWithTmdbID(movieID string) (*media.Movie, error)
+		ListWantedEpisodes() ([]*media.WantedEpisode, error)
 
 		SaveEpisode(episode *media.Episode, season *media.Season, series *media.Series) error
 		SaveMovie(movie *media.Movie) error
+		SaveMediaVersion(version *media.Version) error
 	}
 
 	// ingestService is responsible for managing the automatic detection
@@ -61,8 +65,28 @@ type (
 
 		config           Config
 		items            []*IngestItem
+		groups           []*IngestGroup
 		importHoldTimers map[uuid.UUID]*time.Timer
-		workerPool       worker.WorkerPool
+		retryTimers      map[uuid.UUID]*time.Timer
+		workerPool       *worker.WorkerPool
+		workersCreated   int
+
+		// rejectedPaths contains the paths of files which were rejected as duplicates of
+		// existing media, so that they are not rediscovered and ingested again.
+		rejectedPaths map[string]struct{}
+
+		// autoDelete are the compiled Config.QuarantineAutoDelete patterns.
+		autoDelete []*regexp.Regexp
+
+		// queuePaused prevents workers from claiming items (see PauseQueue).
+		queuePaused bool
+
+		// stages aggregates the timing of each stage of ingestion (see StageMetrics).
+		stages *stageRecorder
+
+		// watcher detects changes to the ingest directory, so that new files
+		// can be discovered without waiting for the forced sync.
+		watcher *watcher
 	}
 )
 
@@ -88,6 +112,15 @@ func New(config Config, searcher Searcher, scraper Scraper, store DataStore, eve
 		return nil, fmt.Errorf("ingestion path '%s' could not be accessed: %w", ingestionPath, err)
 	}
 
+	if err := config.DuplicatePolicy.Validate(); err != nil {
+		return nil, err
+	}
+
+	autoDelete, err := compileAutoDeletePatterns(config.QuarantineAutoDelete)
+	if err != nil {
+		return nil, err
+	}
+
 	service := &ingestService{
 		Mutex:            &sync.Mutex{},
 		scraper:          scraper,
@@ -95,18 +128,19 @@ func New(config Config, searcher Searcher, scraper Scraper, store DataStore, eve
 		dataStore:        store,
 		config:           config,
 		items:            make([]*IngestItem, 0),
+		groups:           make([]*IngestGroup, 0),
 		importHoldTimers: make(map[uuid.UUID]*time.Timer),
-		workerPool:       *worker.NewWorkerPool(),
+		retryTimers:      make(map[uuid.UUID]*time.Timer),
+		workerPool:       worker.NewWorkerPool(),
+		rejectedPaths:    make(map[string]struct{}),
 		eventBus:         eventBus,
+		autoDelete:       autoDelete,
+		stages:           newStageRecorder(),
+		watcher:          newWatcher(ingestionPath, config.WatchDebounce),
 	}
 
-	for i := 0; i < config.IngestionParallelism; i++ {
-		label := fmt.Sprintf("ingest-worker-%d", i)
-		worker := worker.NewWorker(label, service.PerformItemIngest)
-
-		if err := service.workerPool.PushWorker(worker); err != nil {
-			return nil, fmt.Errorf("failed to push worker to pool: %w", err)
-		}
+	if err := service.pushWorkers(config.IngestionParallelism); err != nil {
+		return nil, err
 	}
 
 	return service, nil
@@ -120,10 +154,17 @@ func New(config Config, searcher Searcher, scraper Scraper, store DataStore, eve
 // To kill the service, the calling code should cancel the context
 // provided.
 func (service *ingestService) Run(ctx context.Context) error {
-	fsNotifyChannel := make(chan notify.EventInfo)
 	forceIngestChannel := time.NewTicker(time.Second * time.Duration(service.config.ForceSyncSeconds)).C
 
+	service.watcher.start()
+	defer service.watcher.stop()
+
+	// pendingDiscovery fires once the file system events received
+	// have been coalesced (see Config.WatchDebounce)
+	var pendingDiscovery <-chan time.Time
+
 	defer service.clearAllImportHoldTimers()
+	defer service.clearAllRetryTimers()
 
 	if err := service.workerPool.Start(); err != nil {
 		return fmt.Errorf("failed to construct worker pool: %w", err)
@@ -138,9 +179,14 @@ func (service *ingestService) Run(ctx context.Context) error {
 
 	for {
 		select {
-		case <-fsNotifyChannel:
+		case ev := <-service.watcher.events:
+			pendingDiscovery = service.watcher.recordEvent(ev, pendingDiscovery)
+		case <-pendingDiscovery:
+			pendingDiscovery = nil
 			service.DiscoverNewFiles()
 		case <-forceIngestChannel:
+			// Retry the watch, in case it previously failed (e.g. due to the inotify watch limit)
+			service.watcher.start()
 			service.DiscoverNewFiles()
 		case message := <-ev:
 			ev := message.Event
@@ -165,7 +211,10 @@ func (service *ingestService) Run(ctx context.Context) error {
 
 // PerformItemIngest is the worker function for the IngestService, which is called
 // by the services WorkerPool.
-// This function will claim the first IDLE item it finds and attempt to ingest it.
+// This function will claim the first IDLE item it finds and attempt to ingest it. Items
+// which fail the sanity checks (see sanityCheck) are quarantined rather than ingested, and
+// items which cannot be ingested as TMDB is unavailable are retried later (see deferItem), or
+// ingested using only the metadata from the filename when in offline mode (see ingestOffline).
 // If the ingestion fails with an IngestTrouble, then it will be set on
 // the item and it's state set to TROUBLED.
 func (service *ingestService) PerformItemIngest(w worker.Worker) (bool, error) {
@@ -174,23 +223,57 @@ func (service *ingestService) PerformItemIngest(w worker.Worker) (bool, error) {
 		return true, nil
 	}
 
-	log.Emit(logger.DEBUG, "Item %s claimed by worker %s for ingestion\n", item, w)
+	item.log.Emit(logger.DEBUG, "Item %s claimed by worker %s for ingestion\n", item, w)
 	service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
 
-	if err := item.ingest(service.eventBus, service.scraper, service.searcher, service.dataStore); err != nil {
+	if !item.released {
+		if reason, err := sanityCheck(item.Path, service.config.QuarantineMinSize); err != nil {
+			item.log.Emit(logger.WARNING, "Failed to perform sanity checks on item %s: %v\n", item, err)
+		} else if reason != "" {
+			service.quarantine(item, reason)
+			return false, nil
+		}
+	}
+
+	err := item.scrape(service.eventBus, service.scraper)
+	if err == nil {
+		err = item.ingest(service.eventBus, service.searcherFor(item), service.dataStore, service.config.DuplicatePolicy, service.config.RejectUnmonitored)
+	}
+	item.completeStage(time.Now())
+	service.stages.record(item.Stages, err != nil && !errors.Is(err, ErrDuplicateRejected))
+
+	if errors.Is(err, tmdb.ErrUnavailable) {
+		if !service.config.OfflineMode {
+			service.deferItem(item, err)
+			service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+			return false, nil
+		}
+
+		item.log.Emit(logger.WARNING, "TMDB is unavailable, ingesting item %s using metadata from the filename (offline mode): %v\n", item, err)
+		err = item.ingestOffline(service.eventBus, service.dataStore, service.config.DuplicatePolicy)
+		item.completeStage(time.Now())
+	}
+
+	item.retryAttempts = 0
+	if err != nil {
 		service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
 		//nolint
 		if trbl, ok := err.(Trouble); ok {
 			item.Trouble = &trbl
 			item.State = Troubled
 
-			log.Emit(logger.ERROR, "Ingestion of item %s failed, raising trouble {message='%s' type=%s}\n", item, item.Trouble, item.Trouble.Type())
+			item.log.Emit(logger.ERROR, "Ingestion of item %s failed, raising trouble {message='%s' type=%s}\n", item, item.Trouble, item.Trouble.Type())
+		} else if errors.Is(err, ErrDuplicateRejected) {
+			item.log.Emit(logger.WARNING, "Ingestion of item %s rejected: %v\n", item, err)
+			service.rejectPath(item.Path)
+			item.State = Complete
+			service.eventBus.Dispatch(event.IngestCompleteEvent, item.ID)
 		} else {
-			log.Emit(logger.FATAL, "Ingestion of item %s returned an unexpected error (%#v) (not a trouble)! Worker will crash\n", item, err)
+			item.log.Emit(logger.FATAL, "Ingestion of item %s returned an unexpected error (%#v) (not a trouble)! Worker will crash\n", item, err)
 			return false, err
 		}
 	} else {
-		log.Emit(logger.SUCCESS, "Ingestion of item %s complete!\n", item)
+		item.log.Emit(logger.SUCCESS, "Ingestion of item %s complete!\n", item)
 		item.State = Complete
 		service.eventBus.Dispatch(event.IngestCompleteEvent, item.ID)
 	}
@@ -198,6 +281,12 @@ func (service *ingestService) PerformItemIngest(w worker.Worker) (bool, error) {
 	return false, nil
 }
 
+// StageMetrics returns the aggregated timing of each stage of ingestion,
+// in the order the stages are performed.
+func (service *ingestService) StageMetrics() []StageMetrics {
+	return service.stages.snapshot()
+}
+
 // DiscoverNewFiles will scan the host file system at the path
 // configured and check for items that need to be ingested (as
 // in no database row for these items already exist, and
@@ -223,6 +312,9 @@ func (service *ingestService) DiscoverNewFiles() {
 	for _, item := range service.items {
 		sourcePathsLookup[item.Path] = true
 	}
+	for path := range service.rejectedPaths {
+		sourcePathsLookup[path] = true
+	}
 
 	newItems, err := recursivelyWalkFileSystem(service.config.GetIngestPath(), sourcePathsLookup)
 	if err != nil {
@@ -230,6 +322,11 @@ func (service *ingestService) DiscoverNewFiles() {
 		return
 	}
 
+	var wanted wantedEpisodes
+	if len(newItems) > 0 {
+		wanted = service.loadWantedEpisodes()
+	}
+
 	minModtimeAge := service.config.RequiredModTimeAgeDuration()
 	dirty := false
 	for itemPath, itemInfo := range newItems {
@@ -246,9 +343,15 @@ func (service *ingestService) DiscoverNewFiles() {
 			ID:    itemID,
 			Path:  itemPath,
 			State: itemState,
+			log:   newItemLogger(context.Background(), itemID),
 		}
 
 		service.items = append(service.items, ingestItem)
+		if wanted.matches(itemPath) {
+			ingestItem.Wanted = true
+			service.enqueueWantedItem(ingestItem)
+			ingestItem.log.Emit(logger.INFO, "Item %s matches an episode missing from a monitored series, prioritizing\n", ingestItem)
+		}
 		if itemState == ImportHold {
 			service.scheduleImportHoldTimer(itemID, minModtimeAge-timeDiff)
 		}
@@ -259,6 +362,17 @@ func (service *ingestService) DiscoverNewFiles() {
 	}
 }
 
+// rejectPath prevents the file at the path provided from being discovered
+// again (see DiscoverNewFiles).
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) rejectPath(path string) {
+	service.Lock()
+	defer service.Unlock()
+
+	service.rejectedPaths[path] = struct{}{}
+}
+
 // RemoveItem looks for an item with the ID provided in the services
 // state, and removes it if it's found.
 // This method *fails* if the item is currently 'INGESTING' as interrupting
@@ -285,6 +399,7 @@ func (service *ingestService) removeIngest(itemID uuid.UUID) error {
 		}
 	}
 
+	service.pruneGroups()
 	return nil
 }
 
@@ -300,7 +415,11 @@ func (service *ingestService) GetIngest(itemID uuid.UUID) *IngestItem {
 	return nil
 }
 
-func (service *ingestService) ResolveTroubledIngest(itemID uuid.UUID, method ResolutionType, context map[string]string) error {
+// ResolveTroubledIngest attempts to resolve the trouble on the item with the ID provided
+// using the resolution method and context given. Any logging fields stored in
+// the context provided are included in the log lines emitted for the item
+// from this point onwards.
+func (service *ingestService) ResolveTroubledIngest(ctx context.Context, itemID uuid.UUID, method ResolutionType, context map[string]string) error {
 	service.Lock()
 	defer service.Unlock()
 
@@ -318,11 +437,27 @@ func (service *ingestService) ResolveTroubledIngest(itemID uuid.UUID, method Res
 		return fmt.Errorf("failed to resolve with method %v: %w", method, err)
 	}
 
+	item.log = newItemLogger(ctx, item.ID)
+	item.log.Emit(logger.INFO, "Resolving trouble for item %s using %T\n", item, res)
+
+	return service.applyResolution(item, res)
+}
+
+// applyResolution applies the resolution provided to the troubled item.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *ingestService) applyResolution(item *IngestItem, res interface{}) error {
 	switch v := res.(type) {
 	case *AbortResolution:
 		if err := service.removeIngest(item.ID); err != nil {
 			return err
 		}
+
+		// Aborting a duplicate (or an episode of an unmonitored series) rejects it, otherwise
+		// the file would simply be rediscovered
+		if item.Trouble.Type() == DuplicateMedia || item.Trouble.Type() == UnmonitoredSeries {
+			service.rejectedPaths[item.Path] = struct{}{}
+		}
 	case *RetryResolution:
 		item.State = Idle
 		item.Trouble = nil
@@ -334,6 +469,12 @@ func (service *ingestService) ResolveTroubledIngest(itemID uuid.UUID, method Res
 		item.OverrideTmdbID = &v.tmdbID
 		// An item has been updated, so we need to inform the service to check for work to be done
 		service.wakeupWorkerPool()
+	case *DuplicateResolution:
+		item.State = Idle
+		item.Trouble = nil
+		item.duplicateOverride = &v.action
+		// An item has been updated, so we need to inform the service to check for work to be done
+		service.wakeupWorkerPool()
 	default:
 		return fmt.Errorf("trouble resolution type of %T was not expected. This is likely a bug/should be unreachable", res)
 	}
@@ -342,6 +483,162 @@ func (service *ingestService) ResolveTroubledIngest(itemID uuid.UUID, method Res
 	return nil
 }
 
+// PauseIngest pauses the ingest with the ID provided, preventing workers from claiming it
+// until it is resumed. Only items which are IDLE or on IMPORT_HOLD can be paused, as an
+// ingestion already in progress cannot be interrupted.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) PauseIngest(itemID uuid.UUID) error {
+	service.Lock()
+	defer service.Unlock()
+
+	item := service.GetIngest(itemID)
+	if item == nil {
+		return ErrIngestNotFound
+	}
+
+	if item.State != Idle && item.State != ImportHold {
+		return ErrIngestNotPausable
+	}
+
+	service.clearImportHoldTimer(item.ID)
+	item.State = Paused
+	item.log.Emit(logger.INFO, "Paused item %s\n", item)
+
+	service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+	return nil
+}
+
+// ResumeIngest resumes a paused ingest. The item is placed back on IMPORT_HOLD, and
+// so the modtime of the source file is re-evaluated before the item becomes IDLE.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) ResumeIngest(itemID uuid.UUID) error {
+	service.Lock()
+	defer service.Unlock()
+
+	item := service.GetIngest(itemID)
+	if item == nil {
+		return ErrIngestNotFound
+	}
+
+	if item.State != Paused {
+		return ErrIngestNotPaused
+	}
+
+	item.State = ImportHold
+	item.log.Emit(logger.INFO, "Resumed item %s\n", item)
+	service.scheduleImportHoldTimer(item.ID, 0)
+
+	service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+	return nil
+}
+
+// PrioritizeIngest moves the ingest with the ID provided to the front of the
+// queue, so that it is the next item claimed by a worker once it is IDLE.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *ingestService) PrioritizeIngest(itemID uuid.UUID) error {
+	service.Lock()
+	defer service.Unlock()
+
+	idx := slices.IndexFunc(service.items, func(item *IngestItem) bool { return item.ID == itemID })
+	if idx == -1 {
+		return ErrIngestNotFound
+	}
+
+	item := service.items[idx]
+	service.items = slices.Insert(slices.Delete(service.items, idx, idx+1), 0, item)
+	item.log.Emit(logger.INFO, "Prioritized item %s\n", item)
+
+	service.eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+	return nil
+}
+
+// PauseQueue prevents workers from claiming any further items for ingestion, until the
+// queue is resumed. New files continue to be discovered, and ingestions already in
+// progress are unaffected.
+func (service *ingestService) PauseQueue() {
+	service.Lock()
+	defer service.Unlock()
+
+	service.queuePaused = true
+	log.Emit(logger.INFO, "Ingest queue paused\n")
+}
+
+// ResumeQueue resumes a queue paused by PauseQueue, waking the workers so that
+// any IDLE items are claimed.
+func (service *ingestService) ResumeQueue() {
+	service.Lock()
+	service.queuePaused = false
+	service.Unlock()
+
+	log.Emit(logger.INFO, "Ingest queue resumed\n")
+	service.wakeupWorkerPool()
+}
+
+// IsQueuePaused returns true if the queue has been paused (see PauseQueue).
+func (service *ingestService) IsQueuePaused() bool {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.queuePaused
+}
+
+// SetParallelism grows or shrinks the pool of workers performing ingestions to the size
+// provided. Workers removed from the pool finish the ingestion they are performing (if
+// any) before exiting, so the number of ingestions in progress may briefly exceed
+// the new parallelism.
+func (service *ingestService) SetParallelism(parallelism int) error {
+	if parallelism < 1 {
+		return fmt.Errorf("%w: got %d", ErrInvalidParallelism, parallelism)
+	}
+
+	service.Lock()
+	defer service.Unlock()
+
+	current := service.workerPool.Size()
+	if parallelism > current {
+		if err := service.pushWorkers(parallelism - current); err != nil {
+			return err
+		}
+	} else if parallelism < current {
+		removed := service.workerPool.RemoveWorkers(current - parallelism)
+		log.Emit(logger.DEBUG, "Draining %d ingest workers\n", len(removed))
+	}
+
+	service.config.IngestionParallelism = parallelism
+	log.Emit(logger.INFO, "Ingest parallelism changed from %d to %d\n", current, parallelism)
+	return nil
+}
+
+// WatcherStatus returns the health of the file system watcher used
+// to detect new files in the ingest directory.
+func (service *ingestService) WatcherStatus() WatcherStatus {
+	return service.watcher.Status()
+}
+
+// Parallelism returns the number of workers which perform ingestions.
+func (service *ingestService) Parallelism() int {
+	return service.workerPool.Size()
+}
+
+// pushWorkers creates the number of workers provided and pushes
+// them to the worker pool, starting them if the pool is running.
+func (service *ingestService) pushWorkers(count int) error {
+	for range count {
+		label := fmt.Sprintf("ingest-worker-%d", service.workersCreated)
+		worker := worker.NewWorker(label, service.PerformItemIngest)
+
+		if err := service.workerPool.PushWorker(worker); err != nil {
+			return fmt.Errorf("failed to push worker to pool: %w", err)
+		}
+		service.workersCreated++
+	}
+
+	return nil
+}
+
 // AllItems returns a pointer to the array containing all
 // the IngestItems being processed by this service.
 func (service *ingestService) GetAllIngests() []*IngestItem {
@@ -355,7 +652,8 @@ func (service *ingestService) GetAllIngests() []*IngestItem {
 // If the item exists, but it's source file no longer exists, the item is removed
 // from the services state.
 // If the item exists and it's source still does not meet modtime requirements, then
-// a new timer will be scheduled to re-evaluate the item hold.
+// a new timer will be scheduled to re-evaluate the item hold. Items reported as
+// downloaded (see NotifyDownloadComplete) use the shorter download settle duration.
 //
 // Note: this function takes ownership of the mutex, and releases it when returning.
 func (service *ingestService) evaluateItemHold(id uuid.UUID) {
@@ -375,6 +673,9 @@ func (service *ingestService) evaluateItemHold(id uuid.UUID) {
 	}
 
 	thresholdModTime := service.config.RequiredModTimeAgeDuration()
+	if item.Priority {
+		thresholdModTime = service.config.DownloadSettleDuration()
+	}
 	if *timeDiff < thresholdModTime {
 		service.scheduleImportHoldTimer(id, thresholdModTime-*timeDiff)
 		return
@@ -415,12 +716,17 @@ func (service *ingestService) clearAllImportHoldTimers() {
 // claimIdleItem will try and find an IDLE item in the ingest service,
 // and set it's state to 'INGESTING' to prevent another
 // worker from claiming it once the mutex lock is released.
+// Items are claimed in the order they appear in the queue (see PrioritizeIngest).
 //
 // Note: This function takes ownership of the mutex, and releases it when returning.
 func (service *ingestService) claimIdleItem() *IngestItem {
 	service.Lock()
 	defer service.Unlock()
 
+	if service.queuePaused {
+		return nil
+	}
+
 	for _, item := range service.items {
 		if item.State == Idle {
 			item.State = Ingesting
@@ -467,3 +773,9 @@ func recursivelyWalkFileSystem(rootDirPath string, known map[string]bool) (map[s
 
 	return foundItems, nil
 }
+
+// newItemLogger returns a logger for the ingest item with the ID provided, including
+// any logging fields stored in the context given.
+func newItemLogger(ctx context.Context, itemID uuid.UUID) logger.Logger {
+	return log.WithContext(ctx).WithFields(logger.Fields{"ingest_id": itemID})
+}
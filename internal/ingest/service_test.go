@@ -7,9 +7,12 @@ package ingest_test
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"testing"
@@ -41,6 +44,9 @@ func init() {
 type Service interface {
 	DiscoverNewFiles()
 	GetAllIngests() []*ingest.IngestItem
+	WatcherStatus() ingest.WatcherStatus
+	NotifyDownloadComplete(ctx context.Context, path string, infoHash *string) ([]*ingest.IngestItem, error)
+	DeleteQuarantinedIngest(itemID uuid.UUID) error
 }
 
 func startServiceWithBus(
@@ -115,6 +121,7 @@ func Test_EpisodeImports_CorrectlySaved(t *testing.T) {
 	expectedSeason := &tmdb.Season{ID: json.Number(seasonID), Name: "Test Season", Overview: "..."}
 	expectedEpisode := &tmdb.Episode{ID: json.Number(episodeID), Name: "Test Episode", Overview: "..."}
 
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
 	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
 
 	// Allow ingestion to get metadata for this episode
@@ -126,6 +133,9 @@ func Test_EpisodeImports_CorrectlySaved(t *testing.T) {
 	searcherMock.EXPECT().GetSeason(seriesID, expectedMetdata.SeasonNumber).Return(expectedSeason, nil).Once()
 	searcherMock.EXPECT().GetEpisode(seriesID, expectedMetdata.SeasonNumber, expectedMetdata.EpisodeNumber).Return(expectedEpisode, nil).Once()
 
+	// No existing episode, so the ingestion is not a duplicate
+	storeMock.EXPECT().GetEpisodeWithTmdbID(episodeID).Return(nil, sql.ErrNoRows).Once()
+
 	// match a save call, but with custom matchers to ignore generated UUIDs
 	var savedUUID *uuid.UUID = nil
 	storeMock.EXPECT().SaveEpisode(
@@ -211,6 +221,7 @@ func Test_MovieImports_CorrectlySaved(t *testing.T) {
 		},
 	}
 
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
 	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
 
 	// Allow ingestion to get metadata for this episode
@@ -220,6 +231,9 @@ func Test_MovieImports_CorrectlySaved(t *testing.T) {
 	searcherMock.EXPECT().SearchForMovie(&expectedMetdata).Return(movieID, nil).Once()
 	searcherMock.EXPECT().GetMovie(movieID).Return(expectedMovie, nil).Once()
 
+	// No existing movie, so the ingestion is not a duplicate
+	storeMock.EXPECT().GetMovieWithTmdbID(movieID).Return(nil, sql.ErrNoRows).Once()
+
 	// match a save call, but with custom matchers to ignore generated UUIDs
 	var savedUUID *uuid.UUID = nil
 	storeMock.EXPECT().SaveMovie(
@@ -262,6 +276,101 @@ func Test_MovieImports_CorrectlySaved(t *testing.T) {
 	}, time.Second*2, time.Millisecond*100)
 }
 
+// startDuplicateMovieIngest starts an ingest service which will ingest a single movie that duplicates an existing movie
+// (with a different source file). The existing movie has a resolution of 10x10, and the ingested file has the
+// resolution provided. The channel returned is closed once the ingestion completes.
+func startDuplicateMovieIngest(t *testing.T, policy ingest.DuplicatePolicy, frameSize int, storeMock *mocks.MockDataStore) (Service, string, <-chan struct{}) {
+	tempDir, files := helpers.TempDirWithEmptyFiles(t, []string{"movie"})
+
+	cfg := ingest.Config{ForceSyncSeconds: 100, IngestPath: tempDir, IngestionParallelism: 1, DuplicatePolicy: policy}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+
+	movieID := "123"
+	metadata := media.FileMediaMetadata{Title: "Test Movie", Runtime: "69420", FrameW: frameSize, FrameH: frameSize, Path: files[0]}
+	existing := &media.Movie{
+		Model:     media.Model{ID: uuid.New(), TmdbID: movieID, Title: "Test Movie"},
+		Watchable: media.Watchable{MediaResolution: media.MediaResolution{Width: 10, Height: 10}, SourcePath: "/existing/movie.mkv"},
+	}
+
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+	scraperMock.EXPECT().ScrapeFileForMediaInfo(files[0]).Return(&metadata, nil).Once()
+	searcherMock.EXPECT().SearchForMovie(&metadata).Return(movieID, nil).Once()
+	searcherMock.EXPECT().GetMovie(movieID).Return(&tmdb.Movie{ID: json.Number(movieID), Name: "Test Movie"}, nil).Once()
+	storeMock.EXPECT().GetMovieWithTmdbID(movieID).Return(existing, nil).Once()
+
+	bus := event.New()
+	completed := make(chan struct{})
+	closeOnce := sync.Once{}
+	bus.RegisterHandlerFunction(event.IngestCompleteEvent, func(_ event.Event, _ event.Payload) {
+		closeOnce.Do(func() { close(completed) })
+	})
+
+	return startServiceWithBus(t, cfg, searcherMock, scraperMock, storeMock, bus), files[0], completed
+}
+
+func Test_DuplicateImport_AskPolicy_RaisesTrouble(t *testing.T) {
+	t.Parallel()
+	storeMock := mocks.NewMockDataStore(t)
+	srv, _, _ := startDuplicateMovieIngest(t, ingest.AskDuplicatePolicy, 20, storeMock)
+
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		all := srv.GetAllIngests()
+		assert.Len(c, all, 1)
+		if len(all) == 1 && assert.NotNil(c, all[0].Trouble) {
+			assert.Equal(c, ingest.Troubled, all[0].State)
+			assert.Equal(c, ingest.DuplicateMedia, all[0].Trouble.Type())
+			assert.ElementsMatch(c, []ingest.ResolutionType{ingest.Abort, ingest.ReplaceExisting, ingest.KeepBoth}, all[0].Trouble.AllowedResolutionTypes())
+			if duplicate := all[0].Trouble.GetDuplicate(); assert.NotNil(c, duplicate) {
+				assert.Equal(c, "/existing/movie.mkv", duplicate.ExistingPath)
+				assert.Equal(c, 20, duplicate.IncomingResolution.Width)
+			}
+		}
+	}, 2*time.Second, 100*time.Millisecond)
+}
+
+func Test_DuplicateImport_KeepBothPolicy_SavesVersion(t *testing.T) {
+	t.Parallel()
+	storeMock := mocks.NewMockDataStore(t)
+
+	saved := make(chan *media.Version, 1)
+	storeMock.EXPECT().SaveMediaVersion(mock.Anything).RunAndReturn(func(version *media.Version) error {
+		saved <- version
+		return nil
+	}).Once()
+	_, path, _ := startDuplicateMovieIngest(t, ingest.KeepBothDuplicatePolicy, 20, storeMock)
+
+	select {
+	case version := <-saved:
+		assert.Equal(t, path, version.SourcePath)
+		assert.Equal(t, 20, version.Width)
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "expected duplicate to be saved as a version")
+	}
+}
+
+func Test_DuplicateImport_ReplaceIfBetterPolicy_RejectsWorseFile(t *testing.T) {
+	t.Parallel()
+	storeMock := mocks.NewMockDataStore(t)
+
+	// SaveMovie is not expected, as the ingested file is not better than the existing source
+	srv, _, completed := startDuplicateMovieIngest(t, ingest.ReplaceIfBetterDuplicatePolicy, 5, storeMock)
+
+	select {
+	case <-completed:
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "expected duplicate ingestion to complete")
+	}
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Empty(c, srv.GetAllIngests())
+	}, 2*time.Second, 100*time.Millisecond)
+
+	// The rejected file must not be rediscovered
+	srv.DiscoverNewFiles()
+	assert.Empty(t, srv.GetAllIngests())
+}
+
 func Test_NewFile_IgnoredIfAlreadyImported(t *testing.T) {
 	t.Parallel()
 	tempDir, files := helpers.TempDirWithEmptyFiles(t, []string{"anynameworks"})
@@ -271,6 +380,7 @@ func Test_NewFile_IgnoredIfAlreadyImported(t *testing.T) {
 	scraperMock := mocks.NewMockScraper(t)
 	storeMock := mocks.NewMockDataStore(t)
 
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
 	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{files[0]}, nil)
 
 	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
@@ -292,6 +402,7 @@ func Test_NewFile_CorrectlyHeld(t *testing.T) {
 	storeMock := mocks.NewMockDataStore(t)
 
 	scraperMock.EXPECT().ScrapeFileForMediaInfo(files[0]).Return(nil, errExpected)
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
 	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
 
 	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
@@ -334,6 +445,7 @@ func Test_PollsFilesystemPeriodically(t *testing.T) {
 	storeMock := mocks.NewMockDataStore(t)
 
 	calls := 0
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
 	storeMock.EXPECT().GetAllMediaSourcePaths().RunAndReturn(func() ([]string, error) {
 		calls++
 		return []string{}, nil
@@ -343,3 +455,230 @@ func Test_PollsFilesystemPeriodically(t *testing.T) {
 	time.Sleep(4 * time.Second)
 	assert.GreaterOrEqual(t, calls, 3, "Expected at least calls to 'GetAllMediaSourcePaths'")
 }
+
+func Test_WatchesFilesystemForNewFiles(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+
+	// Forced sync is disabled for the duration of the test, so that only the watcher can discover the file
+	cfg := ingest.Config{ForceSyncSeconds: 100, IngestPath: tempDir, RequiredModTimeAgeSeconds: 100, IngestionParallelism: 1, WatchDebounce: 100 * time.Millisecond}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+	storeMock := mocks.NewMockDataStore(t)
+
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+
+	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
+	assert.Eventually(t, func() bool { return srv.WatcherStatus().Watching }, time.Second, 50*time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "episode"), []byte{}, 0o600))
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		all := srv.GetAllIngests()
+		assert.Len(c, all, 1)
+		if len(all) == 1 {
+			assert.Equal(c, ingest.ImportHold, all[0].State)
+		}
+	}, 2*time.Second, 100*time.Millisecond)
+}
+
+func Test_DownloadComplete_PrioritizesFiles(t *testing.T) {
+	t.Parallel()
+	tempDir, files := helpers.TempDirWithEmptyFiles(t, []string{"polled", "downloaded"})
+
+	// Both thresholds are high so that the items remain held for the duration of the test
+	cfg := ingest.Config{ForceSyncSeconds: 100, IngestPath: tempDir, RequiredModTimeAgeSeconds: 100, DownloadSettleSeconds: 100, IngestionParallelism: 1}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+	storeMock := mocks.NewMockDataStore(t)
+
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+
+	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
+	srv.DiscoverNewFiles()
+
+	hash := "0123456789ABCDEF0123456789ABCDEF01234567"
+	items, err := srv.NotifyDownloadComplete(context.Background(), files[1], &hash)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	all := srv.GetAllIngests()
+	assert.Len(t, all, 2)
+	assert.Equal(t, files[1], all[0].Path)
+	assert.True(t, all[0].Priority)
+	if assert.NotNil(t, all[0].InfoHash) {
+		assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", *all[0].InfoHash)
+	}
+	assert.False(t, all[1].Priority)
+
+	invalid := "not-a-hash"
+	_, err = srv.NotifyDownloadComplete(context.Background(), files[1], &invalid)
+	assert.ErrorIs(t, err, ingest.ErrInfoHashInvalid)
+
+	outside, _ := helpers.TempDirWithEmptyFiles(t, []string{"outside"})
+	_, err = srv.NotifyDownloadComplete(context.Background(), outside, nil)
+	assert.ErrorIs(t, err, ingest.ErrPathNotAllowed)
+}
+
+func Test_WantedEpisodes_PrioritizesFiles(t *testing.T) {
+	t.Parallel()
+	// The files are created by name, as the temporary files created by the helpers have a random prefix
+	tempDir := t.TempDir()
+	files := []string{filepath.Join(tempDir, "Other.Show.S01E01.mkv"), filepath.Join(tempDir, "Marvels.Agents.of.SHIELD.S02E03.1080p.mkv")}
+	for _, file := range files {
+		assert.NoError(t, os.WriteFile(file, []byte{}, 0o600))
+	}
+
+	// The modtime threshold is high so that the items remain held for the duration of the test
+	cfg := ingest.Config{ForceSyncSeconds: 100, IngestPath: tempDir, RequiredModTimeAgeSeconds: 100, IngestionParallelism: 1}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+	storeMock := mocks.NewMockDataStore(t)
+
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{
+		{SeriesID: uuid.New(), SeriesTitle: "Marvel's Agents of S.H.I.E.L.D.", SeasonNumber: 2, EpisodeNumber: 3},
+		{SeriesID: uuid.New(), SeriesTitle: "Other Show", SeasonNumber: 1, EpisodeNumber: 2},
+	}, nil)
+
+	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		all := srv.GetAllIngests()
+		if assert.Len(c, all, 2) {
+			assert.Equal(c, files[1], all[0].Path)
+			assert.True(c, all[0].Wanted)
+			assert.False(c, all[1].Wanted)
+		}
+	}, 2*time.Second, 100*time.Millisecond)
+}
+
+func Test_SuspiciousFiles_Quarantined(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	fakeVideo := filepath.Join(tempDir, "Sample.S01E01.mkv")
+	assert.NoError(t, os.WriteFile(fakeVideo, []byte("PK\x03\x04notavideo"), 0o600))
+
+	cfg := ingest.Config{ForceSyncSeconds: 100, IngestPath: tempDir, RequiredModTimeAgeSeconds: 0, IngestionParallelism: 1}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+	storeMock := mocks.NewMockDataStore(t)
+
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+
+	// The scraper mock has no expectations, and so will fail the test if the quarantined file is scraped
+	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
+	var item *ingest.IngestItem
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		all := srv.GetAllIngests()
+		if assert.Len(c, all, 1) {
+			item = all[0]
+			assert.Equal(c, ingest.Quarantined, item.State)
+			if assert.NotNil(c, item.Quarantine) {
+				assert.Contains(c, item.Quarantine.Reason, "ZIP archive")
+			}
+		}
+	}, 5*time.Second, 100*time.Millisecond)
+	if item == nil {
+		return
+	}
+
+	assert.NoError(t, srv.DeleteQuarantinedIngest(item.ID))
+	assert.Empty(t, srv.GetAllIngests())
+	assert.NoFileExists(t, fakeVideo)
+}
+
+func Test_SuspiciousFiles_AutoDeleted(t *testing.T) {
+	t.Parallel()
+	tempDir := t.TempDir()
+	executable := filepath.Join(tempDir, "Sample.S01E01.exe")
+	assert.NoError(t, os.WriteFile(executable, []byte("MZnotavideo"), 0o600))
+
+	cfg := ingest.Config{
+		ForceSyncSeconds:          100,
+		IngestPath:                tempDir,
+		RequiredModTimeAgeSeconds: 0,
+		IngestionParallelism:      1,
+		QuarantineAutoDelete:      []string{`(?i)\.exe$`},
+	}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+	storeMock := mocks.NewMockDataStore(t)
+
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+
+	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.NoFileExists(c, executable)
+		assert.Empty(c, srv.GetAllIngests())
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func Test_TmdbUnavailable_DefersItem(t *testing.T) {
+	t.Parallel()
+	tempDir, files := helpers.TempDirWithEmptyFiles(t, []string{"movie"})
+
+	// The backoff is long enough that the item is not retried for the duration of the test
+	cfg := ingest.Config{ForceSyncSeconds: 100, IngestPath: tempDir, IngestionParallelism: 1, RetryBackoff: time.Minute, RetryMaxBackoff: time.Hour}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+	storeMock := mocks.NewMockDataStore(t)
+
+	metadata := media.FileMediaMetadata{Title: "Test Movie", Episodic: false, Path: files[0]}
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+	scraperMock.EXPECT().ScrapeFileForMediaInfo(files[0]).Return(&metadata, nil).Once()
+	searcherMock.EXPECT().SearchForMovie(&metadata).Return("", fmt.Errorf("%w: circuit breaker is open", tmdb.ErrUnavailable)).Once()
+
+	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		all := srv.GetAllIngests()
+		if assert.Len(c, all, 1) {
+			assert.Equal(c, ingest.RetryLater, all[0].State)
+			assert.Nil(c, all[0].Trouble)
+			if assert.NotNil(c, all[0].RetryAt) {
+				assert.WithinDuration(c, time.Now().Add(time.Minute), *all[0].RetryAt, 5*time.Second)
+			}
+		}
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func Test_TmdbUnavailable_OfflineMode_IngestsUnverified(t *testing.T) {
+	t.Parallel()
+	tempDir, files := helpers.TempDirWithEmptyFiles(t, []string{"movie"})
+
+	cfg := ingest.Config{ForceSyncSeconds: 100, IngestPath: tempDir, IngestionParallelism: 1, OfflineMode: true}
+	searcherMock := mocks.NewMockSearcher(t)
+	scraperMock := mocks.NewMockScraper(t)
+	storeMock := mocks.NewMockDataStore(t)
+
+	metadata := media.FileMediaMetadata{Title: "Test Movie", Year: 2020, Episodic: false, Path: files[0]}
+	storeMock.EXPECT().ListWantedEpisodes().Return([]*media.WantedEpisode{}, nil).Maybe()
+	storeMock.EXPECT().GetAllMediaSourcePaths().Return([]string{}, nil)
+	scraperMock.EXPECT().ScrapeFileForMediaInfo(files[0]).Return(&metadata, nil).Once()
+	searcherMock.EXPECT().SearchForMovie(&metadata).Return("", fmt.Errorf("%w: circuit breaker is open", tmdb.ErrUnavailable)).Once()
+
+	// The movie is saved using the metadata from the filename, with a placeholder TMDB ID
+	tmdbID := media.UnverifiedTmdbID("movie", "testmovie", 2020)
+	storeMock.EXPECT().GetMovieWithTmdbID(tmdbID).Return(nil, sql.ErrNoRows).Once()
+	saved := make(chan struct{})
+	storeMock.EXPECT().SaveMovie(mock.MatchedBy(func(movie *media.Movie) bool {
+		return movie.TmdbID == tmdbID && movie.Title == metadata.Title && movie.SourcePath == files[0] && movie.IsUnverified()
+	})).Run(func(_ *media.Movie) { close(saved) }).Return(nil).Once()
+
+	srv := startService(t, cfg, searcherMock, scraperMock, storeMock)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		select {
+		case <-saved:
+		default:
+			assert.Fail(c, "unverified movie was never saved")
+		}
+
+		for _, item := range srv.GetAllIngests() {
+			assert.Equal(c, ingest.Complete, item.State)
+			assert.Nil(c, item.RetryAt)
+		}
+	}, 5*time.Second, 100*time.Millisecond)
+}
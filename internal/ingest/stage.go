@@ -0,0 +1,153 @@
+package ingest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type (
+	// IngestStage is a step performed while an item is INGESTING.
+	IngestStage int
+
+	// StageTiming records when an item entered a stage of ingestion, and when
+	// that stage was completed. CompletedAt is nil while the stage is in progress.
+	StageTiming struct {
+		Stage       IngestStage
+		StartedAt   time.Time
+		CompletedAt *time.Time
+	}
+
+	// StageMetrics is the aggregated timing of a stage across all ingestions,
+	// used to identify which stage (e.g. a slow TMDB search) is slowing ingestion.
+	StageMetrics struct {
+		Stage         IngestStage
+		Runs          int64
+		Failures      int64
+		TotalDuration time.Duration
+		MaxDuration   time.Duration
+	}
+
+	// stageRecorder aggregates the timing of the stages completed by each ingestion.
+	stageRecorder struct {
+		sync.Mutex
+		metrics map[IngestStage]*StageMetrics
+	}
+)
+
+const (
+	// Scraping is the stage in which the metadata of the file is scraped from the file system.
+	Scraping IngestStage = iota
+	// Searching is the stage in which TMDB is searched for the media described by the metadata.
+	Searching
+	// Persisting is the stage in which the media found is saved to the database.
+	Persisting
+)
+
+var allStages = []IngestStage{Scraping, Searching, Persisting}
+
+// beginStage completes the stage in progress (if any) and starts the stage provided,
+// dispatching an update event so that clients are informed of the items progress.
+func (item *IngestItem) beginStage(stage IngestStage, eventBus event.EventDispatcher) {
+	now := time.Now()
+	item.completeStage(now)
+	item.Stages = append(item.Stages, StageTiming{Stage: stage, StartedAt: now})
+
+	item.log.Emit(logger.VERBOSE, "Item %s entering stage %s\n", item, stage)
+	eventBus.Dispatch(event.IngestUpdateEvent, item.ID)
+}
+
+// completeStage marks the stage in progress (if any) as completed at the time provided.
+func (item *IngestItem) completeStage(at time.Time) {
+	if len(item.Stages) == 0 {
+		return
+	}
+
+	if current := &item.Stages[len(item.Stages)-1]; current.CompletedAt == nil {
+		current.CompletedAt = &at
+	}
+}
+
+// CurrentStage returns the stage of ingestion which is in progress, or nil if
+// the item is not being ingested.
+func (item *IngestItem) CurrentStage() *IngestStage {
+	if item.State != Ingesting || len(item.Stages) == 0 {
+		return nil
+	}
+
+	current := item.Stages[len(item.Stages)-1]
+	if current.CompletedAt != nil {
+		return nil
+	}
+
+	return &current.Stage
+}
+
+// Duration returns how long the stage took, or how long it has been in progress for.
+func (timing StageTiming) Duration() time.Duration {
+	if timing.CompletedAt == nil {
+		return time.Since(timing.StartedAt)
+	}
+
+	return timing.CompletedAt.Sub(timing.StartedAt)
+}
+
+func newStageRecorder() *stageRecorder {
+	metrics := make(map[IngestStage]*StageMetrics, len(allStages))
+	for _, stage := range allStages {
+		metrics[stage] = &StageMetrics{Stage: stage}
+	}
+
+	return &stageRecorder{metrics: metrics}
+}
+
+// record aggregates the completed stages provided. If the ingestion failed, the
+// final stage is the one which failed and is counted as a failure.
+func (recorder *stageRecorder) record(stages []StageTiming, failed bool) {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	for k, timing := range stages {
+		if timing.CompletedAt == nil {
+			continue
+		}
+
+		duration := timing.Duration()
+		metrics := recorder.metrics[timing.Stage]
+		metrics.Runs++
+		metrics.TotalDuration += duration
+		metrics.MaxDuration = max(metrics.MaxDuration, duration)
+		if failed && k == len(stages)-1 {
+			metrics.Failures++
+		}
+	}
+}
+
+// snapshot returns a copy of the metrics of each stage, in the order the stages are performed.
+func (recorder *stageRecorder) snapshot() []StageMetrics {
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	out := make([]StageMetrics, len(allStages))
+	for k, stage := range allStages {
+		out[k] = *recorder.metrics[stage]
+	}
+
+	return out
+}
+
+func (s IngestStage) String() string {
+	switch s {
+	case Scraping:
+		return fmt.Sprintf("SCRAPING[%d]", s)
+	case Searching:
+		return fmt.Sprintf("SEARCHING[%d]", s)
+	case Persisting:
+		return fmt.Sprintf("PERSISTING[%d]", s)
+	default:
+		return fmt.Sprintf("UNKNOWN[%d]", s)
+	}
+}
@@ -0,0 +1,49 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BeginStage_CompletesPreviousStage(t *testing.T) {
+	t.Parallel()
+	item := &IngestItem{State: Ingesting, log: logger.Get("Test")}
+	bus := event.New()
+
+	item.beginStage(Scraping, bus)
+	assert.Equal(t, Scraping, *item.CurrentStage())
+
+	item.beginStage(Searching, bus)
+	assert.Equal(t, Searching, *item.CurrentStage())
+	assert.Len(t, item.Stages, 2)
+	assert.NotNil(t, item.Stages[0].CompletedAt)
+	assert.Nil(t, item.Stages[1].CompletedAt)
+
+	item.completeStage(time.Now())
+	assert.Nil(t, item.CurrentStage())
+}
+
+func Test_StageRecorder_CountsFinalStageAsFailure(t *testing.T) {
+	t.Parallel()
+	started := time.Now()
+	at := func(d time.Duration) *time.Time { v := started.Add(d); return &v }
+
+	recorder := newStageRecorder()
+	recorder.record([]StageTiming{
+		{Stage: Scraping, StartedAt: started, CompletedAt: at(time.Second)},
+		{Stage: Searching, StartedAt: *at(time.Second), CompletedAt: at(4 * time.Second)},
+	}, true)
+	recorder.record([]StageTiming{
+		{Stage: Searching, StartedAt: started, CompletedAt: at(time.Second)},
+		{Stage: Persisting, StartedAt: *at(time.Second), CompletedAt: at(2 * time.Second)},
+	}, false)
+
+	metrics := recorder.snapshot()
+	assert.Equal(t, StageMetrics{Stage: Scraping, Runs: 1, TotalDuration: time.Second, MaxDuration: time.Second}, metrics[0])
+	assert.Equal(t, StageMetrics{Stage: Searching, Runs: 2, Failures: 1, TotalDuration: 4 * time.Second, MaxDuration: 3 * time.Second}, metrics[1])
+	assert.Equal(t, StageMetrics{Stage: Persisting, Runs: 1, TotalDuration: time.Second, MaxDuration: time.Second}, metrics[2])
+}
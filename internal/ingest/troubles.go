@@ -18,12 +18,17 @@ type (
 		// choices is a nullable list of search results; only populated
 		// if the trouble type is TMDB_FAILURE_MULTI
 		choices *[]tmdb.SearchResultItem
+
+		// duplicate describes the existing media; only populated
+		// if the trouble type is DUPLICATE_MEDIA
+		duplicate *Duplicate
 	}
 
-	ResolutionType   int
-	RetryResolution  struct{}
-	AbortResolution  struct{}
-	TmdbIDResolution struct{ tmdbID string }
+	ResolutionType      int
+	RetryResolution     struct{}
+	AbortResolution     struct{}
+	TmdbIDResolution    struct{ tmdbID string }
+	DuplicateResolution struct{ action duplicateAction }
 )
 
 const (
@@ -32,12 +37,16 @@ const (
 	TmdbFailureMultipleResults
 	TmdbFailureNoResults
 	UnknownFailure
+	DuplicateMedia
+	UnmonitoredSeries
 )
 
 const (
 	Retry ResolutionType = iota
 	SpecifyTmdbID
 	Abort
+	ReplaceExisting
+	KeepBoth
 )
 
 var allowedResolutionTypes = map[TroubleType][]ResolutionType{
@@ -46,6 +55,8 @@ var allowedResolutionTypes = map[TroubleType][]ResolutionType{
 	TmdbFailureUnknown:         {Abort, Retry, SpecifyTmdbID},
 	TmdbFailureMultipleResults: {Abort, Retry, SpecifyTmdbID},
 	TmdbFailureNoResults:       {Abort, Retry, SpecifyTmdbID},
+	DuplicateMedia:             {Abort, ReplaceExisting, KeepBoth},
+	UnmonitoredSeries:          {Abort, Retry, SpecifyTmdbID},
 }
 
 func newTrouble(err error) Trouble {
@@ -70,6 +81,8 @@ func newTrouble(err error) Trouble {
 
 func (t *Trouble) Type() TroubleType { return t.tType }
 
+func (t Trouble) Unwrap() error { return t.error }
+
 func (t *Trouble) AllowedResolutionTypes() []ResolutionType {
 	if allowed, ok := allowedResolutionTypes[t.tType]; ok {
 		return allowed
@@ -104,6 +117,10 @@ func (t *Trouble) GenerateResolution(resolutionMethod ResolutionType, context ma
 		}
 
 		return nil, ErrResolutionContextIncompatible
+	case ReplaceExisting:
+		return &DuplicateResolution{action: replaceDuplicate}, nil
+	case KeepBoth:
+		return &DuplicateResolution{action: keepBothDuplicate}, nil
 	default:
 		return nil, ErrResolutionIncompatible
 	}
@@ -121,6 +138,16 @@ func (t *Trouble) GetTmdbChoices() []tmdb.SearchResultItem {
 	return nil
 }
 
+// GetDuplicate returns the details of the existing media IF and ONLY IF the
+// trouble type is DUPLICATE_MEDIA. Otherwise, `nil` is returned.
+func (t *Trouble) GetDuplicate() *Duplicate {
+	if t.tType == DuplicateMedia {
+		return t.duplicate
+	}
+
+	return nil
+}
+
 func (t TroubleType) String() string {
 	//exhaustive:enforce
 	switch t {
@@ -134,6 +161,10 @@ func (t TroubleType) String() string {
 		return fmt.Sprintf("TMDB_FAILURE_NONE[%d]", t)
 	case UnknownFailure:
 		return fmt.Sprintf("UNKNOWN_FAILURE[%d]", t)
+	case DuplicateMedia:
+		return fmt.Sprintf("DUPLICATE_MEDIA[%d]", t)
+	case UnmonitoredSeries:
+		return fmt.Sprintf("UNMONITORED_SERIES[%d]", t)
 	}
 
 	panic("unreachable")
@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+	"github.com/rjeczalik/notify"
+)
+
+// watchEventBufferSize is the number of file system events which can be buffered
+// before further events are dropped. Dropped events are harmless, as a discovery
+// is already pending when the buffer is full.
+const watchEventBufferSize = 256
+
+type (
+	// WatcherStatus describes the health of the file system watcher used to detect new
+	// files in the ingest directory. When the watcher is not healthy, new files are only
+	// detected by the periodic forced sync of the ingest directory.
+	WatcherStatus struct {
+		Path     string
+		Watching bool
+		// LimitReached is true if the watch could not be established because the
+		// inotify watch limit of the host was exceeded.
+		LimitReached bool
+		Error        error
+		Events       int64
+		LastEventAt  *time.Time
+		Since        time.Time
+	}
+
+	// watcher recursively watches the ingest directory for changes, and
+	// coalesces the events received in to a single discovery.
+	watcher struct {
+		mutex    sync.Mutex
+		path     string
+		events   chan notify.EventInfo
+		debounce time.Duration
+		status   WatcherStatus
+	}
+)
+
+func newWatcher(path string, debounce time.Duration) *watcher {
+	return &watcher{
+		path:     path,
+		events:   make(chan notify.EventInfo, watchEventBufferSize),
+		debounce: debounce,
+		status:   WatcherStatus{Path: path, Since: time.Now()},
+	}
+}
+
+// start establishes a recursive watch of the directory. If the watch cannot be
+// established (e.g. the inotify watch limit has been reached) the error is recorded
+// in the status of the watcher, and false is returned.
+func (w *watcher) start() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.status.Watching {
+		return true
+	}
+
+	err := notify.Watch(filepath.Join(w.path, "..."), w.events, notify.Create, notify.Write, notify.Rename, notify.Remove)
+	w.status.Since = time.Now()
+	if err != nil {
+		// Partially established watches are not removed by notify on failure
+		notify.Stop(w.events)
+
+		w.status.Error = err
+		w.status.LimitReached = errors.Is(err, syscall.ENOSPC)
+		if w.status.LimitReached {
+			log.Warnf("Unable to watch ingest directory '%s' as the inotify watch limit has been reached, falling back to polling\n", w.path)
+		} else {
+			log.Warnf("Unable to watch ingest directory '%s', falling back to polling: %v\n", w.path, err)
+		}
+
+		return false
+	}
+
+	log.Emit(logger.INFO, "Watching ingest directory '%s' for changes\n", w.path)
+	w.status = WatcherStatus{Path: w.path, Watching: true, Events: w.status.Events, LastEventAt: w.status.LastEventAt, Since: w.status.Since}
+	return true
+}
+
+// stop removes the watch, if established.
+func (w *watcher) stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	notify.Stop(w.events)
+	w.status.Watching = false
+	w.status.Since = time.Now()
+}
+
+// recordEvent records that an event was received, returning the channel which
+// fires once the events received have been coalesced. If a discovery is already
+// pending, the pending channel provided is returned unchanged.
+func (w *watcher) recordEvent(ev notify.EventInfo, pending <-chan time.Time) <-chan time.Time {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	w.status.Events++
+	w.status.LastEventAt = &now
+	log.Emit(logger.VERBOSE, "Ingest directory event %s for '%s'\n", ev.Event(), ev.Path())
+
+	if pending != nil {
+		return pending
+	}
+
+	return time.After(w.debounce)
+}
+
+func (w *watcher) Status() WatcherStatus {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.status
+}
@@ -0,0 +1,244 @@
+// Package library partitions media in to named libraries (e.g. 'Kids', 'Anime' and 'Main'). Each
+// library is bound to an ingest path; media ingested from beneath the path is assigned to the library,
+// and it's transcodes are placed in the library's output directory. Users are granted access to
+// libraries individually, and may only see the media within the libraries they've been granted.
+package library
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/lib/pq"
+)
+
+type (
+	Library struct {
+		ID        uuid.UUID `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+		Label     string    `db:"label"`
+
+		// IngestPath is the absolute path of the directory which media
+		// must be ingested from beneath to be assigned to this library.
+		IngestPath string `db:"ingest_path"`
+
+		// OutputDirectory is the directory (relative to the transcode output
+		// directory) that transcodes of this library's media are placed in.
+		OutputDirectory string `db:"output_directory"`
+	}
+
+	// Access describes the libraries a user may access. Media which
+	// does not belong to a library can be accessed by all users.
+	Access struct {
+		All        bool
+		LibraryIDs []uuid.UUID
+	}
+
+	Store struct{}
+)
+
+var (
+	ErrLibraryNotFound        = errors.New("library not found")
+	ErrLibraryConflict        = errors.New("library label and ingest path must be unique")
+	ErrIngestPathInvalid      = errors.New("ingest path must be an absolute path")
+	ErrOutputDirectoryInvalid = errors.New("output directory must be a relative path which does not leave the transcode output directory")
+
+	// ErrAccessDenied is returned when a user attempts to access media
+	// in a library they've not been granted access to. The message
+	// is intentionally indistinguishable from the media not existing.
+	ErrAccessDenied = errors.New("media not found")
+)
+
+// FullAccess is the access of users with permission to access all libraries.
+func FullAccess() *Access { return &Access{All: true} }
+
+// Allows returns true if the access permits the media belonging to
+// the library provided (which is nil if the media has no library).
+func (access *Access) Allows(libraryID *uuid.UUID) bool {
+	return access == nil || access.All || libraryID == nil || slices.Contains(access.LibraryIDs, *libraryID)
+}
+
+// Validate returns an error if the library cannot be saved.
+func (library *Library) Validate() error {
+	errs := make([]error, 0)
+	if library.Label == "" {
+		errs = append(errs, errors.New("label is required"))
+	}
+	if !filepath.IsAbs(library.IngestPath) {
+		errs = append(errs, ErrIngestPathInvalid)
+	}
+	if dir := library.OutputDirectory; dir != "" {
+		if filepath.IsAbs(dir) || !filepath.IsLocal(dir) {
+			errs = append(errs, ErrOutputDirectoryInvalid)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (library *Library) String() string {
+	return fmt.Sprintf("Library{ID=%s label=%s ingestPath=%s}", library.ID, library.Label, library.IngestPath)
+}
+
+// Save creates or updates the library provided. The ingest path is cleaned
+// (e.g. to remove trailing separators) before being saved.
+func (store *Store) Save(db database.Queryable, library *Library) error {
+	library.IngestPath = filepath.Clean(library.IngestPath)
+	library.OutputDirectory = strings.TrimPrefix(filepath.Clean("/"+library.OutputDirectory), "/")
+
+	_, err := db.NamedExec(`
+		INSERT INTO library(id, created_at, updated_at, label, ingest_path, output_directory)
+		VALUES (:id, current_timestamp, current_timestamp, :label, :ingest_path, :output_directory)
+		ON CONFLICT(id) DO UPDATE
+		SET (updated_at, label, ingest_path, output_directory) =
+			(current_timestamp, EXCLUDED.label, EXCLUDED.ingest_path, EXCLUDED.output_directory)
+	`, library)
+
+	return wrapConflictError(err)
+}
+
+func (store *Store) Get(db database.Queryable, id uuid.UUID) (*Library, error) {
+	var result Library
+	if err := db.Get(&result, `SELECT * FROM library WHERE id=$1`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLibraryNotFound
+		}
+
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (store *Store) GetAll(db database.Queryable) ([]*Library, error) {
+	var results []*Library
+	if err := db.Select(&results, `SELECT * FROM library ORDER BY label`); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Delete deletes the library with the ID provided. The media within
+// the library is retained, but no longer belongs to any library.
+func (store *Store) Delete(db database.Queryable, id uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM library WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrLibraryNotFound
+	}
+
+	return nil
+}
+
+// MatchPath returns the ID of the library which the file at the path provided should
+// be assigned to, being the library with the longest ingest path containing the file. If
+// the file is not within any library, nil is returned.
+func (store *Store) MatchPath(db database.Queryable, path string) (*uuid.UUID, error) {
+	var id uuid.UUID
+	if err := db.Get(&id, `
+		SELECT id FROM library
+		WHERE starts_with($1, ingest_path || '/')
+		ORDER BY length(ingest_path) DESC
+		LIMIT 1
+	`, filepath.Clean(path)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &id, nil
+}
+
+// GetItemLibraryID returns the ID of the library which the movie, episode, series or season
+// with the ID provided belongs to. Nil is returned if the item does not belong to a library (or
+// does not exist), as such items are not restricted.
+func (store *Store) GetItemLibraryID(db database.Queryable, id uuid.UUID) (*uuid.UUID, error) {
+	var results []*uuid.UUID
+	if err := db.Select(&results, `
+		SELECT library_id FROM media WHERE id=$1
+		UNION ALL SELECT library_id FROM series WHERE id=$1
+		UNION ALL SELECT series.library_id FROM season INNER JOIN series ON series.id=season.series_id WHERE season.id=$1
+	`, id); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	return results[0], nil
+}
+
+// GetUserLibraries returns the libraries the user with the ID provided has been granted access to.
+func (store *Store) GetUserLibraries(db database.Queryable, userID uuid.UUID) ([]*Library, error) {
+	var results []*Library
+	if err := db.Select(&results, `
+		SELECT library.* FROM library
+		INNER JOIN user_libraries ul ON ul.library_id = library.id
+		WHERE ul.user_id=$1
+		ORDER BY library.label
+	`, userID); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetUserLibraryIDs returns the IDs of the libraries the user with the ID provided has been granted access to.
+func (store *Store) GetUserLibraryIDs(db database.Queryable, userID uuid.UUID) ([]uuid.UUID, error) {
+	results := make([]uuid.UUID, 0)
+	if err := db.Select(&results, `SELECT library_id FROM user_libraries WHERE user_id=$1`, userID); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SetUserLibraries replaces the libraries the user with the ID provided has been granted access to.
+func (store *Store) SetUserLibraries(db database.Queryable, userID uuid.UUID, libraryIDs []uuid.UUID) error {
+	if _, err := db.Exec(`DELETE FROM user_libraries WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+	if len(libraryIDs) == 0 {
+		return nil
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO user_libraries(user_id, library_id)
+		SELECT $1, library_id FROM unnest($2::UUID[]) AS library_id
+		ON CONFLICT DO NOTHING
+	`, userID, pq.Array(libraryIDs)); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Constraint == "user_libraries_fk_library_id" {
+			return fmt.Errorf("%w: %s", ErrLibraryNotFound, pqErr.Detail)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// wrapConflictError wraps the error provided with ErrLibraryConflict if it's
+// a violation of the unique constraints on the label or ingest path of libraries.
+func wrapConflictError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && (pqErr.Constraint == "library_uk_label" || pqErr.Constraint == "library_uk_ingest_path") {
+		return fmt.Errorf("%w: %s", ErrLibraryConflict, pqErr.Detail)
+	}
+
+	return err
+}
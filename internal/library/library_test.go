@@ -0,0 +1,50 @@
+package library
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LibraryValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		library Library
+		err     error
+	}{
+		{name: "Valid", library: Library{Label: "Kids", IngestPath: "/ingest/kids", OutputDirectory: "kids"}},
+		{name: "NoOutputDirectory", library: Library{Label: "Kids", IngestPath: "/ingest/kids"}},
+		{name: "RelativeIngestPath", library: Library{Label: "Kids", IngestPath: "ingest/kids"}, err: ErrIngestPathInvalid},
+		{name: "AbsoluteOutputDirectory", library: Library{Label: "Kids", IngestPath: "/ingest/kids", OutputDirectory: "/kids"}, err: ErrOutputDirectoryInvalid},
+		{name: "EscapingOutputDirectory", library: Library{Label: "Kids", IngestPath: "/ingest/kids", OutputDirectory: "../kids"}, err: ErrOutputDirectoryInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			err := test.library.Validate()
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+
+	assert.Error(t, (&Library{IngestPath: "/ingest"}).Validate(), "label is required")
+}
+
+func Test_AccessAllows(t *testing.T) {
+	t.Parallel()
+	kids, anime := uuid.New(), uuid.New()
+
+	restricted := &Access{LibraryIDs: []uuid.UUID{kids}}
+	assert.True(t, restricted.Allows(nil), "media without a library is visible to all users")
+	assert.True(t, restricted.Allows(&kids))
+	assert.False(t, restricted.Allows(&anime))
+
+	assert.True(t, FullAccess().Allows(&anime))
+	assert.False(t, (&Access{}).Allows(&kids))
+}
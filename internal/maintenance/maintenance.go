@@ -0,0 +1,125 @@
+// Package maintenance implements Thea's read-only maintenance mode. While enabled, the API
+// rejects requests which modify Thea's state, and ingestion and the transcode queue are paused,
+// so that backups and migrations can be performed safely. Browsing and streaming continue.
+package maintenance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var log = logger.Get("Maintenance")
+
+type (
+	Config struct {
+		// Enabled starts Thea in maintenance mode, which remains enabled until disabled via the API.
+		Enabled bool   `toml:"enabled" env:"MAINTENANCE_MODE" env-default:"false"`
+		Reason  string `toml:"reason" env:"MAINTENANCE_REASON"`
+
+		// RetryAfter is the delay clients are asked to wait before retrying
+		// a request which was rejected due to maintenance.
+		RetryAfter time.Duration `toml:"retry_after" env:"MAINTENANCE_RETRY_AFTER" env-default:"5m"`
+	}
+
+	IngestQueue interface {
+		PauseQueue()
+		ResumeQueue()
+		IsQueuePaused() bool
+	}
+
+	TranscodeQueue interface {
+		PauseQueue(suspendRunning bool)
+		ResumeQueue()
+		IsQueuePaused() bool
+	}
+
+	State struct {
+		Enabled bool
+		Reason  string
+		// Since is the time maintenance mode was enabled, and is nil if not enabled.
+		Since *time.Time
+	}
+
+	// Mode tracks whether maintenance mode is enabled, pausing (and later resuming) the
+	// ingest and transcode queues accordingly. Queues which were already paused when
+	// maintenance mode was enabled remain paused when it is disabled.
+	Mode struct {
+		mutex      sync.Mutex
+		state      State
+		retryAfter time.Duration
+
+		ingests          IngestQueue
+		transcodes       TranscodeQueue
+		pausedIngests    bool
+		pausedTranscodes bool
+	}
+)
+
+func New(config Config, ingests IngestQueue, transcodes TranscodeQueue) *Mode {
+	mode := &Mode{retryAfter: config.RetryAfter, ingests: ingests, transcodes: transcodes}
+	if config.Enabled {
+		mode.Enable(config.Reason)
+	}
+
+	return mode
+}
+
+// Enable places Thea in maintenance mode. If maintenance mode is already
+// enabled, only the reason is updated.
+func (mode *Mode) Enable(reason string) {
+	mode.mutex.Lock()
+	defer mode.mutex.Unlock()
+
+	mode.state.Reason = reason
+	if mode.state.Enabled {
+		return
+	}
+
+	now := time.Now()
+	mode.state.Enabled = true
+	mode.state.Since = &now
+
+	if !mode.ingests.IsQueuePaused() {
+		mode.ingests.PauseQueue()
+		mode.pausedIngests = true
+	}
+	if !mode.transcodes.IsQueuePaused() {
+		mode.transcodes.PauseQueue(false)
+		mode.pausedTranscodes = true
+	}
+
+	log.Warnf("Maintenance mode enabled (reason: '%s'), requests which modify Thea will be rejected\n", reason)
+}
+
+// Disable takes Thea out of maintenance mode, resuming the queues paused when it was enabled.
+func (mode *Mode) Disable() {
+	mode.mutex.Lock()
+	defer mode.mutex.Unlock()
+
+	if !mode.state.Enabled {
+		return
+	}
+	mode.state = State{}
+
+	if mode.pausedIngests {
+		mode.ingests.ResumeQueue()
+		mode.pausedIngests = false
+	}
+	if mode.pausedTranscodes {
+		mode.transcodes.ResumeQueue()
+		mode.pausedTranscodes = false
+	}
+
+	log.Emit(logger.SUCCESS, "Maintenance mode disabled\n")
+}
+
+func (mode *Mode) State() State {
+	mode.mutex.Lock()
+	defer mode.mutex.Unlock()
+
+	return mode.state
+}
+
+func (mode *Mode) RetryAfter() time.Duration { return mode.retryAfter }
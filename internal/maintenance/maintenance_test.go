@@ -0,0 +1,51 @@
+package maintenance_test
+
+import (
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/maintenance"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	ingestQueue    struct{ paused bool }
+	transcodeQueue struct{ paused bool }
+)
+
+func (q *ingestQueue) PauseQueue()         { q.paused = true }
+func (q *ingestQueue) ResumeQueue()        { q.paused = false }
+func (q *ingestQueue) IsQueuePaused() bool { return q.paused }
+
+func (q *transcodeQueue) PauseQueue(_ bool)   { q.paused = true }
+func (q *transcodeQueue) ResumeQueue()        { q.paused = false }
+func (q *transcodeQueue) IsQueuePaused() bool { return q.paused }
+
+func Test_EnablePausesQueues(t *testing.T) {
+	ingests, transcodes := &ingestQueue{}, &transcodeQueue{}
+	mode := maintenance.New(maintenance.Config{}, ingests, transcodes)
+	assert.False(t, mode.State().Enabled)
+
+	mode.Enable("backup")
+	state := mode.State()
+	assert.True(t, state.Enabled)
+	assert.Equal(t, "backup", state.Reason)
+	assert.NotNil(t, state.Since)
+	assert.True(t, ingests.paused)
+	assert.True(t, transcodes.paused)
+
+	mode.Disable()
+	assert.Equal(t, maintenance.State{}, mode.State())
+	assert.False(t, ingests.paused)
+	assert.False(t, transcodes.paused)
+}
+
+func Test_DisableLeavesQueuesPausedBeforeMaintenance(t *testing.T) {
+	ingests, transcodes := &ingestQueue{}, &transcodeQueue{paused: true}
+	mode := maintenance.New(maintenance.Config{Enabled: true, Reason: "migration"}, ingests, transcodes)
+	assert.True(t, mode.State().Enabled)
+	assert.True(t, ingests.paused)
+
+	mode.Disable()
+	assert.False(t, ingests.paused)
+	assert.True(t, transcodes.paused, "transcode queue was paused before maintenance, and should remain paused")
+}
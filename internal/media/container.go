@@ -14,13 +14,20 @@ type (
 	// an Episode. This is indicated using the 'Type' enum. If
 	// container is holding an 'Episode' type, then the 'Season'
 	// and 'Series' that the episode belongs to will also be populated
-	// if available.
+	// if available. A container holding a 'Series' may also be
+	// populated with the (inflated) seasons of that series.
+	// The labels of the tags attached to the movie/series (or the
+	// series of the episode) are also populated, if available, as
+	// is the output directory of the library the media belongs to.
 	Container struct {
-		Type    ContainerType
-		Movie   *Movie
-		Episode *Episode
-		Series  *Series
-		Season  *Season
+		Type            ContainerType
+		Movie           *Movie
+		Episode         *Episode
+		Series          *Series
+		Season          *Season
+		Seasons         []*InflatedSeason
+		Tags            []string
+		OutputDirectory string
 	}
 )
 
@@ -41,6 +48,10 @@ func (cont *Container) CreatedAt() time.Time { return cont.model().CreatedAt }
 func (cont *Container) UpdatedAt() time.Time { return cont.model().UpdatedAt }
 func (cont *Container) Source() string       { return cont.watchable().SourcePath }
 
+// LibraryID returns the ID of the library the media belongs
+// to, or nil if the media does not belong to a library.
+func (cont *Container) LibraryID() *uuid.UUID { return cont.model().LibraryID }
+
 // EpisodeNumber returns the episode number for the media IF it is an Episode. -1
 // is returned if the container is holding a Movie.
 func (cont *Container) EpisodeNumber() int {
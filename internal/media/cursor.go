@@ -0,0 +1,119 @@
+package media
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("media list cursor is malformed, or does not match the requested ordering")
+
+// mediaListColumnTypes maps each of the columns which media can be ordered by
+// to the SQL type used when comparing the column against a cursor value.
+var mediaListColumnTypes = map[MediaListOrderColumn]string{
+	IDColumn:        "uuid",
+	UpdatedAtColumn: "timestamptz",
+	CreatedAtColumn: "timestamptz",
+	TitleColumn:     "text",
+}
+
+// mediaListCursor is the decoded form of the opaque cursor returned by ListMedia. It
+// contains the values of the order columns for the last row of the page, as well as
+// the ordering itself so that a cursor cannot be used with a different ordering.
+type mediaListCursor struct {
+	Order  string   `json:"o"`
+	Values []string `json:"v"`
+}
+
+// withStableOrdering returns the ordering provided, with an additional ordering on the ID column
+// (if not already present) which acts as a tie-breaker for rows with identical values for the
+// other order columns. This is required for cursors to reliably identify a position in the list.
+func withStableOrdering(orderBy []MediaListOrderBy) []MediaListOrderBy {
+	for _, ord := range orderBy {
+		if ord.Column == IDColumn {
+			return orderBy
+		}
+	}
+
+	return append(orderBy, MediaListOrderBy{Column: IDColumn, Descending: false})
+}
+
+func orderSignature(orderBy []MediaListOrderBy) string {
+	parts := make([]string, len(orderBy))
+	for k, ord := range orderBy {
+		parts[k] = ord.String()
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(orderBy []MediaListOrderBy, values []string) (string, error) {
+	raw, err := json.Marshal(mediaListCursor{Order: orderSignature(orderBy), Values: values})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode media list cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string, orderBy []MediaListOrderBy) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded mediaListCursor
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if decoded.Order != orderSignature(orderBy) || len(decoded.Values) != len(orderBy) {
+		return nil, ErrInvalidCursor
+	}
+
+	return decoded.Values, nil
+}
+
+// cursorCondition constructs the WHERE clause which selects only the rows which appear *after*
+// the row described by the cursor values, given the ordering provided. For an ordering of (a ASC, b DESC)
+// this would be: (a > $1) OR (a = $1 AND b < $2).
+func cursorCondition(orderBy []MediaListOrderBy, values []string) sq.Sqlizer {
+	condition := sq.Or{}
+	for k, ord := range orderBy {
+		clause := sq.And{}
+		for j, prev := range orderBy[:k] {
+			clause = append(clause, sq.Expr(fmt.Sprintf("joinedMedia.%s = CAST(? AS %s)", prev.Column, mediaListColumnTypes[prev.Column]), values[j]))
+		}
+
+		op := ">"
+		if ord.Descending {
+			op = "<"
+		}
+		clause = append(clause, sq.Expr(fmt.Sprintf("joinedMedia.%s %s CAST(? AS %s)", ord.Column, op, mediaListColumnTypes[ord.Column]), values[k]))
+		condition = append(condition, clause)
+	}
+
+	return condition
+}
+
+// cursorValue returns the value of the given order column for a row, formatted
+// such that it can be stored in a cursor and later cast back by the database.
+func cursorValue(column MediaListOrderColumn, id uuid.UUID, title string, createdAt time.Time, updatedAt time.Time) string {
+	switch column {
+	case IDColumn:
+		return id.String()
+	case UpdatedAtColumn:
+		return updatedAt.Format(time.RFC3339Nano)
+	case CreatedAtColumn:
+		return createdAt.Format(time.RFC3339Nano)
+	case TitleColumn:
+		return title
+	}
+
+	return ""
+}
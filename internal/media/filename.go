@@ -0,0 +1,209 @@
+package media
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnparsableFilename = errors.New("filename contains no recognisable year, episode or release information")
+
+	videoExtensions = []string{".mkv", ".mp4", ".m4v", ".avi", ".mov", ".wmv", ".webm", ".flv", ".ts", ".m2ts", ".mpg", ".mpeg"}
+
+	leadingGroupMatcher = regexp.MustCompile(`^\s*\[([^\]]+)\]`)
+	bracketMatcher      = regexp.MustCompile(`\[([^\]]*)\]`)
+	separatorMatcher    = regexp.MustCompile(`[\s._()]+`)
+
+	// S01E01, S01E01E02, S01E01-E02 and S01E01-02
+	seasonEpisodeMatcher = regexp.MustCompile(`(?i)^s(\d{1,2})e(\d{1,4})((?:-?e?\d{1,4})*)$`)
+	episodeListMatcher   = regexp.MustCompile(`(?i)(-?)e?(\d{1,4})`)
+	// 1x01 and 1x01-02
+	crossEpisodeMatcher = regexp.MustCompile(`(?i)^(\d{1,2})x(\d{1,3})(?:-(\d{1,3}))?$`)
+	// 01, 001 or 01v2 (anime absolute numbering, where the episode may be re-released as a new version)
+	absoluteEpisodeMatcher = regexp.MustCompile(`(?i)^(\d{1,4})(?:v\d)?$`)
+	yearMatcher            = regexp.MustCompile(`^(?:19|20)\d{2}$`)
+	sceneGroupMatcher      = regexp.MustCompile(`-([A-Za-z0-9]+)$`)
+
+	// releaseTokens are tokens which describe the release rather than the media. Scene naming places
+	// these after the title, year and episode information, and so they mark the end of the title. Words
+	// which commonly appear in titles (e.g. 'Web', 'Extended' or 'Proper') are deliberately excluded.
+	releaseTokens = regexp.MustCompile(`(?i)^(?:\d{3,4}[pi]|4k|uhd|hdr(?:10)?|bluray|blu-ray|bdrip|brrip|remux|web-?dl|webrip|hdtv|pdtv|dvdrip|hdrip|x26[45]|h26[45]|hevc|xvid|divx|10bit|8bit|aac\d?|ac3|eac3|dts|dts-hd|truehd|flac|ddp?\d)$`)
+)
+
+// ParsedFilename is the breakdown of the information contained within the filename of a
+// movie or episode. Scene naming (e.g. 'The.Show.2019.S01E01-E02.1080p.WEB-DL.x264-GROUP.mkv')
+// and fansub naming (e.g. '[Group] The Show - 01v2 [1080p].mkv') are both supported.
+type ParsedFilename struct {
+	Title string
+	Year  int
+	// Season is -1 if the filename contains no season information.
+	Season int
+	// Episodes contains all the episodes contained within a multi-episode file (e.g. S01E01-E03
+	// is episodes 1, 2 and 3). If the episode uses absolute numbering, then it is the only episode.
+	Episodes        []int
+	AbsoluteEpisode bool
+	ReleaseGroup    string
+	// Tags are the tokens describing the release (e.g. resolution, source and codec).
+	Tags []string
+}
+
+func (parsed *ParsedFilename) Episodic() bool { return len(parsed.Episodes) > 0 }
+
+// ParseFilename tokenizes the filename provided, returning the title, year, season/episode
+// information and release information it contains. The title is formed from the tokens preceding
+// the first episode or release token, and when multiple years are present the last is assumed
+// to be the year of release (e.g. 'Blade Runner 2049 2017'). ErrUnparsableFilename is returned
+// if the filename has no year, episode or release tokens, as the title cannot be isolated.
+func ParseFilename(filename string) (*ParsedFilename, error) {
+	parsed := &ParsedFilename{Season: -1, Episodes: make([]int, 0), Tags: make([]string, 0)}
+
+	name := filename
+	if ext := filepath.Ext(name); slices.Contains(videoExtensions, strings.ToLower(ext)) {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	// Fansub releases lead with the group, and place release information (and the
+	// CRC of the file) in brackets, which is never part of the title.
+	if groups := leadingGroupMatcher.FindStringSubmatch(name); groups != nil {
+		parsed.ReleaseGroup = strings.TrimSpace(groups[1])
+		name = name[len(groups[0]):]
+	}
+	for _, groups := range bracketMatcher.FindAllStringSubmatch(name, -1) {
+		parsed.Tags = append(parsed.Tags, strings.Fields(groups[1])...)
+	}
+	name = bracketMatcher.ReplaceAllString(name, " ")
+
+	tokens := slices.DeleteFunc(separatorMatcher.Split(name, -1), func(s string) bool { return s == "" })
+	titleEnd, yearIdx := len(tokens), -1
+	for i, token := range tokens {
+		if parsed.parseEpisodeToken(token) {
+			titleEnd = i
+			break
+		}
+
+		// Anime absolute numbering is separated from the title by a lone hyphen (e.g. 'Show - 01')
+		if i > 0 && tokens[i-1] == "-" && absoluteEpisodeMatcher.MatchString(token) && !isYear(token) {
+			parsed.Episodes = append(parsed.Episodes, convertToInt(absoluteEpisodeMatcher.FindStringSubmatch(token)[1]))
+			parsed.AbsoluteEpisode = true
+			titleEnd = i - 1
+			break
+		}
+
+		if releaseTokens.MatchString(strings.TrimSuffix(token, sceneGroupSuffix(token))) {
+			titleEnd = i
+			break
+		}
+
+		// The year is the last year-like token preceding the end of the title, unless the
+		// title would be empty (e.g. '1917 1080p', in which case 1917 is the title)
+		if i > 0 && isYear(token) {
+			yearIdx = i
+		}
+	}
+
+	if yearIdx >= 0 {
+		parsed.Year = convertToInt(tokens[yearIdx])
+		titleEnd = yearIdx
+	}
+	if titleEnd < len(tokens) {
+		parsed.parseReleaseTokens(tokens[titleEnd:])
+	} else if parsed.Year == 0 {
+		return nil, ErrUnparsableFilename
+	}
+
+	parsed.Title = strings.Trim(strings.Join(tokens[:titleEnd], " "), " -")
+	if parsed.Title == "" {
+		return nil, ErrUnparsableFilename
+	}
+
+	// Absolute numbering carries no season, however the first season is the most likely
+	// match on TMDB (which lists many long-running anime as a single season).
+	if parsed.AbsoluteEpisode {
+		parsed.Season = 1
+	}
+
+	return parsed, nil
+}
+
+// parseEpisodeToken parses the season and episodes from the token provided, returning
+// true if the token contained season/episode information.
+func (parsed *ParsedFilename) parseEpisodeToken(token string) bool {
+	if groups := seasonEpisodeMatcher.FindStringSubmatch(token); groups != nil {
+		parsed.Season = convertToInt(groups[1])
+		parsed.appendEpisode(convertToInt(groups[2]), false)
+		for _, episode := range episodeListMatcher.FindAllStringSubmatch(groups[3], -1) {
+			parsed.appendEpisode(convertToInt(episode[2]), episode[1] == "-")
+		}
+
+		return true
+	}
+
+	if groups := crossEpisodeMatcher.FindStringSubmatch(token); groups != nil {
+		parsed.Season = convertToInt(groups[1])
+		parsed.appendEpisode(convertToInt(groups[2]), false)
+		if groups[3] != "" {
+			parsed.appendEpisode(convertToInt(groups[3]), true)
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// appendEpisode adds the episode provided. If isRangeEnd is true, then all the episodes
+// between the last episode and the episode provided are also added.
+func (parsed *ParsedFilename) appendEpisode(episode int, isRangeEnd bool) {
+	if isRangeEnd && len(parsed.Episodes) > 0 {
+		for e := parsed.Episodes[len(parsed.Episodes)-1] + 1; e < episode; e++ {
+			parsed.Episodes = append(parsed.Episodes, e)
+		}
+	}
+
+	parsed.Episodes = append(parsed.Episodes, episode)
+}
+
+// parseReleaseTokens records the release tokens following the title (excluding the episode
+// tokens), and the scene group which suffixes the final token. If no year preceded the
+// title end, then the first year following it is used (e.g. 'Show S01E01 2019').
+func (parsed *ParsedFilename) parseReleaseTokens(tokens []string) {
+	for i, token := range tokens {
+		if parsed.Year == 0 && isYear(token) {
+			parsed.Year = convertToInt(token)
+			continue
+		}
+
+		isEpisode := seasonEpisodeMatcher.MatchString(token) || crossEpisodeMatcher.MatchString(token)
+		if i == len(tokens)-1 && parsed.ReleaseGroup == "" && !isEpisode {
+			if suffix := sceneGroupSuffix(token); suffix != "" {
+				parsed.ReleaseGroup = suffix[1:]
+				token = strings.TrimSuffix(token, suffix)
+			}
+		}
+
+		if releaseTokens.MatchString(token) {
+			parsed.Tags = append(parsed.Tags, token)
+		}
+	}
+}
+
+// sceneGroupSuffix returns the '-GROUP' suffix of the token provided, or an empty
+// string if the token has no such suffix (or the suffix is part of a release token,
+// such as 'WEB-DL').
+func sceneGroupSuffix(token string) string {
+	suffix := sceneGroupMatcher.FindString(token)
+	if suffix == "" || releaseTokens.MatchString(token) {
+		return ""
+	}
+
+	return suffix
+}
+
+// isYear returns true if the token is a plausible year of release.
+func isYear(token string) bool {
+	return yearMatcher.MatchString(token) && convertToInt(token) <= time.Now().Year()+1
+}
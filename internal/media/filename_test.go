@@ -0,0 +1,93 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected *ParsedFilename
+	}{
+		{
+			filename: "The.Matrix.1999.1080p.BluRay.x264-SPARKS.mkv",
+			expected: &ParsedFilename{Title: "The Matrix", Year: 1999, Season: -1, Episodes: []int{}, ReleaseGroup: "SPARKS", Tags: []string{"1080p", "BluRay", "x264"}},
+		},
+		{
+			filename: "The Matrix (1999).mkv",
+			expected: &ParsedFilename{Title: "The Matrix", Year: 1999, Season: -1, Episodes: []int{}, Tags: []string{}},
+		},
+		{
+			filename: "Spider-Man.No.Way.Home.2021.WEBRip.mkv",
+			expected: &ParsedFilename{Title: "Spider-Man No Way Home", Year: 2021, Season: -1, Episodes: []int{}, Tags: []string{"WEBRip"}},
+		},
+		{
+			filename: "Charlotte's Web 2006.mkv",
+			expected: &ParsedFilename{Title: "Charlotte's Web", Year: 2006, Season: -1, Episodes: []int{}, Tags: []string{}},
+		},
+		{
+			// Year disambiguation: the last year is the year of release
+			filename: "Blade.Runner.2049.2017.1080p.mkv",
+			expected: &ParsedFilename{Title: "Blade Runner 2049", Year: 2017, Season: -1, Episodes: []int{}, Tags: []string{"1080p"}},
+		},
+		{
+			// Year disambiguation: a year in the future is part of the title
+			filename: "Blade Runner 2049 1080p BluRay.mkv",
+			expected: &ParsedFilename{Title: "Blade Runner 2049", Season: -1, Episodes: []int{}, Tags: []string{"1080p", "BluRay"}},
+		},
+		{
+			// Year disambiguation: the title is a year
+			filename: "1917.2019.2160p.UHD.BluRay.x265-TERMiNAL.mkv",
+			expected: &ParsedFilename{Title: "1917", Year: 2019, Season: -1, Episodes: []int{}, ReleaseGroup: "TERMiNAL", Tags: []string{"2160p", "UHD", "BluRay", "x265"}},
+		},
+		{
+			filename: "1917 1080p.mkv",
+			expected: &ParsedFilename{Title: "1917", Season: -1, Episodes: []int{}, Tags: []string{"1080p"}},
+		},
+		{
+			filename: "The.Show.S01E05.720p.HDTV.x264-GROUP.mkv",
+			expected: &ParsedFilename{Title: "The Show", Season: 1, Episodes: []int{5}, ReleaseGroup: "GROUP", Tags: []string{"720p", "HDTV", "x264"}},
+		},
+		{
+			filename: "The.Show.2019.S01E01-E03.1080p.WEB-DL.x264-GROUP.mkv",
+			expected: &ParsedFilename{Title: "The Show", Year: 2019, Season: 1, Episodes: []int{1, 2, 3}, ReleaseGroup: "GROUP", Tags: []string{"1080p", "WEB-DL", "x264"}},
+		},
+		{
+			filename: "The Show S02E05E06 720p HDTV.mp4",
+			expected: &ParsedFilename{Title: "The Show", Season: 2, Episodes: []int{5, 6}, Tags: []string{"720p", "HDTV"}},
+		},
+		{
+			filename: "Show S01E01 2019.mkv",
+			expected: &ParsedFilename{Title: "Show", Year: 2019, Season: 1, Episodes: []int{1}, Tags: []string{}},
+		},
+		{
+			filename: "Show.1x02-03.avi",
+			expected: &ParsedFilename{Title: "Show", Season: 1, Episodes: []int{2, 3}, Tags: []string{}},
+		},
+		{
+			filename: "[SubsPlease] Jujutsu Kaisen - 01v2 (1080p) [ABCD1234].mkv",
+			expected: &ParsedFilename{Title: "Jujutsu Kaisen", Season: 1, Episodes: []int{1}, AbsoluteEpisode: true, ReleaseGroup: "SubsPlease", Tags: []string{"ABCD1234", "1080p"}},
+		},
+		{
+			filename: "[Erai-raws] One Piece - 1071 [1080p][Multiple Subtitle].mkv",
+			expected: &ParsedFilename{Title: "One Piece", Season: 1, Episodes: []int{1071}, AbsoluteEpisode: true, ReleaseGroup: "Erai-raws", Tags: []string{"1080p", "Multiple", "Subtitle"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filename, func(t *testing.T) {
+			parsed, err := ParseFilename(test.filename)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, parsed)
+		})
+	}
+}
+
+func Test_ParseFilenameRejectsUnrecognisableNames(t *testing.T) {
+	for _, filename := range []string{"randomfile.mkv", "[Group] .mkv", "1080p.mkv"} {
+		_, err := ParseFilename(filename)
+		assert.ErrorIs(t, err, ErrUnparsableFilename, filename)
+	}
+}
@@ -1,14 +1,16 @@
 package media
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/file"
 )
 
 type (
@@ -21,29 +23,96 @@ type (
 		Year          int
 		FrameW        int
 		FrameH        int
+		VideoCodec    string
+		Size          int64
 		Path          string
+		Checksum      string
+		// Filename is the breakdown of the information parsed
+		// from the filename, retained for debugging purposes.
+		Filename *ParsedFilename
 	}
 
 	ScraperConfig struct {
 		FfprobeBinPath string
+		// ProbeConcurrency is the maximum number of ffprobe processes
+		// which may run at once, and ProbeTimeout is how long a probe
+		// may run before it is abandoned (see ffmpeg.ProbePool).
+		ProbeConcurrency int
+		ProbeTimeout     time.Duration
 	}
 
+	// MetadataProvider populates some of the metadata of a media file, such as the information
+	// contained within the filename, or the information found by probing the file.
+	MetadataProvider interface {
+		Name() string
+		Scrape(ctx context.Context, path string, output *FileMediaMetadata) error
+	}
+
+	// MetadataScraper scrapes the metadata of a media file by running each of it's providers
+	// in order. If any provider fails, the scrape fails. The duration of each provider is
+	// recorded so that slow scrapes (e.g. due to a slow disk) can be diagnosed.
 	MetadataScraper struct {
-		config ScraperConfig
+		providers    []MetadataProvider
+		probePool    *ffmpeg.ProbePool
+		metricsMutex sync.Mutex
+		metrics      map[string]*ProviderMetrics
+	}
+
+	ScraperMetrics struct {
+		Providers []ProviderMetrics
+		// Probes is nil if the scraper was not constructed with the default providers.
+		Probes *ffmpeg.ProbePoolStats
+	}
+
+	ProviderMetrics struct {
+		Name          string
+		Scrapes       int64
+		Failures      int64
+		TotalDuration time.Duration
+		MaxDuration   time.Duration
 	}
+
+	// filenameProvider extracts the title, year and season/episode
+	// information from the filename (see ParseFilename).
+	filenameProvider struct{}
+
+	// ffprobeProvider uses ffprobe to extract reliable information, such as frame
+	// width/height and runtime. Probes are performed using a bounded pool.
+	ffprobeProvider struct{ pool *ffmpeg.ProbePool }
+
+	// checksumProvider checksums the file so that corruption of
+	// the source (e.g. bit-rot) can be detected later.
+	checksumProvider struct{}
 )
 
+// NewScraper creates a scraper using the default providers, which extract the information
+// contained within the filename, probe the file using ffprobe, and checksum the file.
 func NewScraper(config ScraperConfig) *MetadataScraper {
-	return &MetadataScraper{config: config}
+	pool := ffmpeg.NewProbePool(config.FfprobeBinPath, config.ProbeConcurrency, config.ProbeTimeout)
+	scraper := NewScraperWithProviders(&filenameProvider{}, &ffprobeProvider{pool}, &checksumProvider{})
+	scraper.probePool = pool
+
+	return scraper
+}
+
+// NewScraperWithProviders creates a scraper which uses the providers given, in order.
+func NewScraperWithProviders(providers ...MetadataProvider) *MetadataScraper {
+	metrics := make(map[string]*ProviderMetrics, len(providers))
+	for _, provider := range providers {
+		metrics[provider.Name()] = &ProviderMetrics{Name: provider.Name()}
+	}
+
+	return &MetadataScraper{providers: providers, metrics: metrics}
 }
 
 // ScrapeFileForMediaInfo accepts a file path and tries to extract
 // some standard metadata from it for the purpose of later searching
 // third-party services.
 //
-// This function will first extract as much information as it can from the
-// title (such as the title and episode/season information), and also
-// uses ffprobe information for bitrate/duration.
+// With the default providers, this function will first extract as much
+// information as it can from the title (such as the title and episode/season
+// information), and also uses ffprobe information for bitrate/duration.
+// Finally, the checksum of the file is computed.
 func (scraper *MetadataScraper) ScrapeFileForMediaInfo(path string) (*FileMediaMetadata, error) {
 	output := FileMediaMetadata{
 		SeasonNumber:  -1,
@@ -51,65 +120,83 @@ func (scraper *MetadataScraper) ScrapeFileForMediaInfo(path string) (*FileMediaM
 		Path:          path,
 	}
 
-	// Extract information from title
-	filename := filepath.Base(path)
-	if err := scraper.extractTitleInformation(filename, &output); err != nil {
-		return nil, err
+	ctx := context.Background()
+	for _, provider := range scraper.providers {
+		started := time.Now()
+		err := provider.Scrape(ctx, path, &output)
+		scraper.recordScrape(provider.Name(), time.Since(started), err)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Use ffprobe to extract reliable information, such as frame width/height and bitrate
-	if err := scraper.extractFfprobeInformation(path, &output); err != nil {
-		return nil, err
+	return &output, nil
+}
+
+// Metrics returns the number of scrapes, failures and the durations of each provider.
+func (scraper *MetadataScraper) Metrics() ScraperMetrics {
+	scraper.metricsMutex.Lock()
+	defer scraper.metricsMutex.Unlock()
+
+	out := ScraperMetrics{Providers: make([]ProviderMetrics, len(scraper.providers))}
+	for k, provider := range scraper.providers {
+		out.Providers[k] = *scraper.metrics[provider.Name()]
+	}
+	if scraper.probePool != nil {
+		stats := scraper.probePool.Stats()
+		out.Probes = &stats
 	}
 
-	return &output, nil
+	return out
+}
+
+func (scraper *MetadataScraper) recordScrape(name string, duration time.Duration, err error) {
+	scraper.metricsMutex.Lock()
+	defer scraper.metricsMutex.Unlock()
+
+	metrics := scraper.metrics[name]
+	metrics.Scrapes++
+	metrics.TotalDuration += duration
+	metrics.MaxDuration = max(metrics.MaxDuration, duration)
+	if err != nil {
+		metrics.Failures++
+	}
 }
 
-// extractTitleInformation uses regular expressions to try and find:
+func (provider *filenameProvider) Name() string { return "filename" }
+
+// Scrape parses the filename (see ParseFilename) to find:
 // - Title
 // - Year
 // - Is episode or movie?
 // - Season/episode information.
-func (scraper *MetadataScraper) extractTitleInformation(title string, output *FileMediaMetadata) error {
-	normaliserMatcher := regexp.MustCompile(`(?i)[\.\s\-]`)
-	seasonMatcher := regexp.MustCompile(`(?i)^(.*?)\s?s(\d+)\s?e(\d+)\s*((?:20|19)\d{2})?`)
-	movieMatcher := regexp.MustCompile(`(?i)^(.+?)\s*((?:20|19)\d{2})`)
-
-	normalizedTitle := normaliserMatcher.ReplaceAllString(title, " ")
-
-	// Search for season info and optional year information
-	if seasonGroups := seasonMatcher.FindStringSubmatch(normalizedTitle); len(seasonGroups) >= 1 {
-		output.Episodic = true
-		output.Title = strings.TrimSpace(seasonGroups[1])
-		output.SeasonNumber = convertToInt(seasonGroups[2])
-		output.EpisodeNumber = convertToInt(seasonGroups[3])
-		year := convertToInt(seasonGroups[4])
-		output.Year = year
-
-		return nil
+//
+// Multi-episode files are ingested as the first episode they contain.
+func (provider *filenameProvider) Scrape(_ context.Context, path string, output *FileMediaMetadata) error {
+	parsed, err := ParseFilename(filepath.Base(path))
+	if err != nil {
+		// Return error so that trouble can be raised by the worker.
+		return fmt.Errorf("failed to extract file metadata from title: %w", err)
 	}
 
-	// Try find if it's a movie instead
-	if movieGroups := movieMatcher.FindStringSubmatch(normalizedTitle); len(movieGroups) >= 1 {
-		output.Episodic = false
-		output.Title = strings.TrimSpace(movieGroups[1])
-		output.SeasonNumber = -1
-		output.EpisodeNumber = -1
-		year := convertToInt(movieGroups[2])
-		output.Year = year
-
-		return nil
+	output.Filename = parsed
+	output.Title = parsed.Title
+	output.Year = parsed.Year
+	output.Episodic = parsed.Episodic()
+	if output.Episodic {
+		output.SeasonNumber = parsed.Season
+		output.EpisodeNumber = parsed.Episodes[0]
 	}
 
-	// Didn't match either case; return error so that trouble
-	// can be raised by the worker.
-	return errors.New("failed to extract file metadata from title - regular expressions failed")
+	return nil
 }
 
-// extractFfprobeInformation will read the media metadata using ffprobe. If successful,
-// the frame width/height and the runtime of the media will be populated in the output.
-func (scraper *MetadataScraper) extractFfprobeInformation(path string, output *FileMediaMetadata) error {
-	metadata, err := ffmpeg.ProbeFile(path, scraper.config.FfprobeBinPath)
+func (provider *ffprobeProvider) Name() string { return "ffprobe" }
+
+// Scrape will read the media metadata using ffprobe. If successful, the frame
+// width/height, codec, size and the runtime of the media will be populated in the output.
+func (provider *ffprobeProvider) Scrape(ctx context.Context, path string, output *FileMediaMetadata) error {
+	metadata, err := provider.pool.Probe(ctx, path)
 	if err != nil {
 		return ffmpeg.ParseFfmpegError(err)
 	}
@@ -122,8 +209,27 @@ func (scraper *MetadataScraper) extractFfprobeInformation(path string, output *F
 
 	output.FrameW = width
 	output.FrameH = height
+	output.VideoCodec = stream.GetCodecName()
 	output.Runtime = metadata.GetFormat().GetDuration()
 
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat media source: %w", err)
+	}
+	output.Size = info.Size()
+
+	return nil
+}
+
+func (provider *checksumProvider) Name() string { return "checksum" }
+
+func (provider *checksumProvider) Scrape(_ context.Context, path string, output *FileMediaMetadata) error {
+	checksum, err := file.Checksum(path)
+	if err != nil {
+		return err
+	}
+	output.Checksum = checksum
+
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package media
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -22,6 +23,19 @@ type (
 		CreatedAt time.Time `db:"created_at"`
 		UpdatedAt time.Time `db:"updated_at"`
 		Title     string
+		Overview  string `db:"overview"`
+
+		// DeletedAt is non-nil if this model has been moved to the trash. Trashed
+		// models are omitted from most queries, excluding those concerning the trash.
+		DeletedAt *time.Time `db:"deleted_at"`
+
+		// LockedFields are the metadata fields (see MetadataField) which have been manually
+		// corrected, and so are not overwritten when this model is upserted during ingestion.
+		LockedFields pq.StringArray `db:"locked_fields"`
+
+		// LibraryID is the ID of the library this model was ingested in to, or
+		// nil if it does not belong to a library (in which case all users may access it).
+		LibraryID *uuid.UUID `db:"library_id"`
 	}
 
 	// Media represents the form of both movies and episodes inside the database. It is only after checking the
@@ -40,7 +54,18 @@ type (
 	Watchable struct {
 		MediaResolution
 		SourcePath string `db:"source_path"`
+		SourceSize int64  `db:"source_size"`
+		VideoCodec string `db:"video_codec"`
 		Adult      bool   `db:"adult"`
+
+		// DegradedAt is non-nil if the source file could not be found
+		// on disk during the last consistency check.
+		DegradedAt *time.Time `db:"degraded_at"`
+
+		// Checksum is the SHA-256 digest of the source file, recorded at ingest. CorruptedAt is
+		// non-nil if the source file no longer matched this checksum when last verified.
+		Checksum    *string    `db:"checksum"`
+		CorruptedAt *time.Time `db:"corrupted_at"`
 	}
 
 	MediaResolution struct {
@@ -68,6 +93,14 @@ type (
 	Series struct {
 		Model
 		Genres []*Genre
+
+		// Monitored series have the episodes missing from the library prioritized
+		// during ingestion. Episodes of unmonitored series may be rejected.
+		Monitored bool `db:"monitored"`
+
+		// Related is the content TMDB considers related to this series. This is
+		// only populated during ingestion, and is not read back from the DB.
+		Related *TmdbRelations
 	}
 
 	// SeriesStub is used to package information about a series which doesn't map one-to-one with
@@ -87,6 +120,10 @@ type (
 	InflatedSeries struct {
 		*Series
 		Seasons []*InflatedSeason
+
+		// MissingEpisodes are the aired episodes of the series which
+		// are known to TMDB, but are not present in the library.
+		MissingEpisodes []*CatalogEpisode
 		// TODO: cast members, ratings, etc
 	}
 
@@ -108,21 +145,64 @@ type (
 		Model
 		Watchable
 		Genres []*Genre
+
+		// Collection is the TMDB collection this movie belongs to, if any. This
+		// is only populated during ingestion, and is not read back from the DB.
+		Collection *Collection
+
+		// Related is the content TMDB considers related to this movie. This is
+		// only populated during ingestion, and is not read back from the DB.
+		Related *TmdbRelations
 	}
+
+	// TrashedItem describes a movie, series, season or episode which has been moved
+	// to the trash. Children which were trashed alongside their parent (e.g. the
+	// episodes of a trashed season) are not represented separately.
+	TrashedItem struct {
+		ID        uuid.UUID       `db:"id"`
+		Type      TrashedItemType `db:"type"`
+		Title     string          `db:"title"`
+		DeletedAt time.Time       `db:"deleted_at"`
+	}
+
+	// SourceFile is the source path of a (non-trashed) movie or episode, used to
+	// verify that the source of each media still exists on disk.
+	SourceFile struct {
+		MediaID     uuid.UUID  `db:"id"`
+		Path        string     `db:"source_path"`
+		DegradedAt  *time.Time `db:"degraded_at"`
+		Checksum    *string    `db:"checksum"`
+		CorruptedAt *time.Time `db:"corrupted_at"`
+	}
+)
+
+type TrashedItemType string
+
+const (
+	TrashedMovie   TrashedItemType = "movie"
+	TrashedEpisode TrashedItemType = "episode"
+	TrashedSeason  TrashedItemType = "season"
+	TrashedSeries  TrashedItemType = "series"
 )
 
-var storeLogger = logger.Get("MediaStore")
+var (
+	storeLogger = logger.Get("MediaStore")
+
+	ErrNotRestorable = errors.New("media is not in the trash, or its parent is also in the trash")
+)
 
 const (
 	IDCol     = "id"
 	TmdbIDCol = "tmdb_id"
 
-	MediaTable  = "media"
-	SeriesTable = "series"
-	SeasonTable = "season"
+	MediaTable   = "media"
+	SeriesTable  = "series"
+	SeasonTable  = "season"
+	VersionTable = "media_versions"
 
 	MediaMovieClause   = "AND type='movie'"
 	MediaEpisodeClause = "AND type='episode'"
+	NotTrashedClause   = "AND deleted_at IS NULL"
 )
 
 type MediaListResult struct {
@@ -130,6 +210,15 @@ type MediaListResult struct {
 	Movie  *Movie
 }
 
+// MediaListPage is a single page of results from ListMedia. If there are more
+// results, HasMore will be true and the NextCursor can be used to fetch them.
+type MediaListPage struct {
+	Results    []*MediaListResult
+	NextCursor string
+	HasMore    bool
+	TotalCount *int
+}
+
 func (result *MediaListResult) IsMovie() bool  { return result.Movie != nil && result.Series == nil }
 func (result *MediaListResult) IsSeries() bool { return result.Movie == nil && result.Series != nil }
 
@@ -157,68 +246,119 @@ type MediaListOrderBy struct {
 	Descending bool
 }
 
-type Store struct{ mediaGenreStore }
+// ListMediaOptions are the filtering, ordering and paging options of ListMedia. The zero
+// value lists the first page of all movies and series, ordered by when they were last updated.
+//   - Types -> defaults to movies and series
+//   - Title -> only returns results where their title is 'LIKE' the one provided
+//   - Genres -> defaults to no filtering (any/all genres), if any genre IDs are provided then only
+//     media which is associated with ALL of the genres specified
+//   - CollectionID -> optional collection which results must be a member of. Series are included if any
+//     of their episodes are a member of the collection
+//   - Tags -> optional tags which results must have attached, either ALL or ANY of them (see TagFilter)
+//   - Libraries -> optional libraries which results must belong to (see LibraryFilter)
+//   - OrderBy -> defaults to updated_at in ascending order. The ID is always used as a final tie-breaker
+//   - Offset -> defaults to 0, ignored if a cursor is provided
+//   - Limit -> default to 15, maximum 100
+//   - Cursor -> optional cursor (taken from a previous pages NextCursor) to fetch the results after. The
+//     ordering must match the ordering used to fetch the previous page, else ErrInvalidCursor is returned
+//   - IncludeTotal -> if true, the total number of results matching the filters is included in the page
+type ListMediaOptions struct {
+	Types        []MediaListType
+	Title        string
+	Genres       []int
+	CollectionID *uuid.UUID
+	Tags         *TagFilter
+	Libraries    *LibraryFilter
+	OrderBy      []MediaListOrderBy
+	Offset       int
+	Limit        int
+	Cursor       string
+	IncludeTotal bool
+}
+
+// LibraryFilter restricts the results of ListMedia to the media belonging to one of the
+// libraries with the IDs provided. Media which does not belong to any library is always included.
+type LibraryFilter struct {
+	IDs []uuid.UUID
+}
+
+type Store struct {
+	mediaGenreStore
+	mediaCollectionStore
+	mediaMetadataStore
+	mediaTagStore
+}
 
 // SaveMovie upserts the provided Movie model to the database. Existing models
 // to update are found using the 'TmdbId' as this is expected to be a stable
-// identifier.
+// identifier. Locked fields of an existing model are not overwritten.
 //
-// NOTE: the ID of the media may be UPDATED to match existing DB entry (if any).
+// NOTE: the ID, locked fields and locked values of the media may be UPDATED to match existing DB entry (if any).
 func (store *Store) SaveMovie(db database.Queryable, movie *Movie) error {
 	var updatedMovie Movie
 	if err := db.QueryRowx(`
-		INSERT INTO media(id, type, tmdb_id, title, adult, source_path, frame_width, frame_height, created_at, updated_at)
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8, current_timestamp, current_timestamp)
+		INSERT INTO media(id, type, tmdb_id, title, overview, adult, source_path, source_size, video_codec, frame_width, frame_height, checksum, library_id, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, current_timestamp, current_timestamp)
 		ON CONFLICT(tmdb_id, type) DO UPDATE
-			SET (updated_at, title, adult, source_path, frame_width, frame_height) = (current_timestamp, EXCLUDED.title, EXCLUDED.adult, EXCLUDED.source_path, EXCLUDED.frame_width, EXCLUDED.frame_height)
-		RETURNING id, tmdb_id, title, adult, source_path, created_at, updated_at, frame_width, frame_height;
-	`, movie.ID, "movie", movie.TmdbID, movie.Title, movie.Adult, movie.SourcePath, movie.Width, movie.Height).StructScan(&updatedMovie); err != nil {
+			SET (updated_at, title, overview, adult, source_path, source_size, video_codec, frame_width, frame_height, checksum, library_id, deleted_at, degraded_at, corrupted_at) =
+				(current_timestamp, `+lockedColumn(MediaTable, TitleField)+`, `+lockedColumn(MediaTable, OverviewField)+`, `+lockedColumn(MediaTable, AdultField)+`, EXCLUDED.source_path, EXCLUDED.source_size, EXCLUDED.video_codec, EXCLUDED.frame_width, EXCLUDED.frame_height, EXCLUDED.checksum, EXCLUDED.library_id, NULL, NULL, NULL)
+		RETURNING id, tmdb_id, title, overview, adult, source_path, source_size, video_codec, created_at, updated_at, frame_width, frame_height, locked_fields, library_id;
+	`, movie.ID, "movie", movie.TmdbID, movie.Title, movie.Overview, movie.Adult, movie.SourcePath, movie.SourceSize, movie.VideoCodec, movie.Width, movie.Height, movie.Checksum, movie.LibraryID).StructScan(&updatedMovie); err != nil {
 		return err
 	}
 
 	// Update provided model to ensure ID and FK are accurate (as updating
 	// an existing model doesn't change these as they're immutable)
 	movie.ID = updatedMovie.ID
+	movie.Title = updatedMovie.Title
+	movie.Overview = updatedMovie.Overview
+	movie.Adult = updatedMovie.Adult
+	movie.LockedFields = updatedMovie.LockedFields
 	return nil
 }
 
 // SaveSeries upserts the provided Series model to the database. Existing models
 // to update are found using the 'TmdbID' as this is expected to be a stable
-// identifier.
+// identifier. Locked fields of an existing model are not overwritten.
 //
-// NOTE: the ID of the media may be UPDATED to match existing DB entry (if any).
+// NOTE: the ID, locked fields and locked values of the media may be UPDATED to match existing DB entry (if any).
 func (store *Store) SaveSeries(db database.Queryable, series *Series) error {
 	var updatedSeries Series
 	if err := db.QueryRowx(`
-		INSERT INTO series(id, tmdb_id, title, created_at, updated_at)
-		VALUES($1, $2, $3, current_timestamp, current_timestamp)
+		INSERT INTO series(id, tmdb_id, title, overview, library_id, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, current_timestamp, current_timestamp)
 		ON CONFLICT(tmdb_id) DO UPDATE
-			SET (title, updated_at) = (EXCLUDED.title, current_timestamp)
+			SET (title, overview, library_id, updated_at, deleted_at) = (`+lockedColumn(SeriesTable, TitleField)+`, `+lockedColumn(SeriesTable, OverviewField)+`, EXCLUDED.library_id, current_timestamp, NULL)
 		RETURNING *
-	`, series.ID, series.TmdbID, series.Title).StructScan(&updatedSeries); err != nil {
+	`, series.ID, series.TmdbID, series.Title, series.Overview, series.LibraryID).StructScan(&updatedSeries); err != nil {
 		return err
 	}
 
 	// Update provided model to ensure ID and FK are accurate (as updating
 	// an existing model doesn't change these as they're immutable)
 	series.ID = updatedSeries.ID
+	series.Title = updatedSeries.Title
+	series.Overview = updatedSeries.Overview
+	series.Monitored = updatedSeries.Monitored
+	series.LockedFields = updatedSeries.LockedFields
 	return nil
 }
 
 // SaveSeason upserts the provided Season model to the database. Existing models
 // to update are found using the 'TmdbID' as this is expected to be a stable
-// identifier.
+// identifier. Locked fields of an existing model are not overwritten.
 //
-// NOTE: the PK and FK ID's of the media may be UPDATED to match existing DB entry (if any).
+// NOTE: the PK and FK ID's, locked fields and locked values of the media may be UPDATED to match existing DB entry (if any).
 func (store *Store) SaveSeason(db database.Queryable, season *Season) error {
 	var updatedSeason Season
 	if err := db.QueryRowx(`
-		INSERT INTO season(id, tmdb_id, season_number, title, series_id, created_at, updated_at)
-		VALUES($1, $2, $3, $4, $5, current_timestamp, current_timestamp)
+		INSERT INTO season(id, tmdb_id, season_number, title, overview, series_id, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, current_timestamp, current_timestamp)
 		ON CONFLICT(tmdb_id) DO UPDATE
-			SET (season_number, title, series_id, updated_at) = (EXCLUDED.season_number, EXCLUDED.title, EXCLUDED.series_id, current_timestamp)
+			SET (season_number, title, overview, series_id, updated_at, deleted_at) =
+				(`+lockedColumn(SeasonTable, SeasonNumberField)+`, `+lockedColumn(SeasonTable, TitleField)+`, `+lockedColumn(SeasonTable, OverviewField)+`, EXCLUDED.series_id, current_timestamp, NULL)
 		RETURNING *
-	`, season.ID, season.TmdbID, season.SeasonNumber, season.Title, season.SeriesID).StructScan(&updatedSeason); err != nil {
+	`, season.ID, season.TmdbID, season.SeasonNumber, season.Title, season.Overview, season.SeriesID).StructScan(&updatedSeason); err != nil {
 		return err
 	}
 
@@ -226,24 +366,29 @@ func (store *Store) SaveSeason(db database.Queryable, season *Season) error {
 	// an existing model doesn't change these as they're immutable)
 	season.ID = updatedSeason.ID
 	season.SeriesID = updatedSeason.SeriesID
+	season.SeasonNumber = updatedSeason.SeasonNumber
+	season.Title = updatedSeason.Title
+	season.Overview = updatedSeason.Overview
+	season.LockedFields = updatedSeason.LockedFields
 	return nil
 }
 
 // SaveEpisode transactionally upserts the episode and it's season
 // and series. Existing models are found using the models 'TmdbID'
-// as this is expected to be a stable identifier.
+// as this is expected to be a stable identifier. Locked fields of an
+// existing model are not overwritten.
 //
-// NOTE: the PK and FK ID's of the media may be UPDATED to match existing DB entry (if any).
+// NOTE: the PK and FK ID's, locked fields and locked values of the media may be UPDATED to match existing DB entry (if any).
 func (store *Store) SaveEpisode(db database.Queryable, episode *Episode) error {
 	var updatedEpisode Episode
 	if err := db.QueryRowx(`
-		INSERT INTO media(id, type, tmdb_id, episode_number, title, source_path, season_id, adult, frame_width, frame_height, created_at, updated_at)
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, current_timestamp, current_timestamp)
+		INSERT INTO media(id, type, tmdb_id, episode_number, title, overview, source_path, source_size, video_codec, season_id, adult, frame_width, frame_height, checksum, library_id, created_at, updated_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, current_timestamp, current_timestamp)
 		ON CONFLICT(tmdb_id, type) DO UPDATE
-			SET (episode_number, title, source_path, season_id, updated_at, adult, frame_width, frame_height) =
-				(EXCLUDED.episode_number, EXCLUDED.title, EXCLUDED.source_path, EXCLUDED.season_id, current_timestamp, EXCLUDED.adult, EXCLUDED.frame_width, EXCLUDED.frame_height)
-		RETURNING id, tmdb_id, episode_number, title, source_path, season_id, adult, frame_width, frame_height, created_at, updated_at;
-	`, episode.ID, "episode", episode.TmdbID, episode.EpisodeNumber, episode.Title, episode.SourcePath, episode.SeasonID, episode.Adult, episode.Width, episode.Height).
+			SET (episode_number, title, overview, source_path, source_size, video_codec, season_id, updated_at, adult, frame_width, frame_height, checksum, library_id, deleted_at, degraded_at, corrupted_at) =
+				(`+lockedColumn(MediaTable, EpisodeNumberField)+`, `+lockedColumn(MediaTable, TitleField)+`, `+lockedColumn(MediaTable, OverviewField)+`, EXCLUDED.source_path, EXCLUDED.source_size, EXCLUDED.video_codec, EXCLUDED.season_id, current_timestamp, `+lockedColumn(MediaTable, AdultField)+`, EXCLUDED.frame_width, EXCLUDED.frame_height, EXCLUDED.checksum, EXCLUDED.library_id, NULL, NULL, NULL)
+		RETURNING id, tmdb_id, episode_number, title, overview, source_path, source_size, video_codec, season_id, adult, frame_width, frame_height, created_at, updated_at, locked_fields, library_id;
+	`, episode.ID, "episode", episode.TmdbID, episode.EpisodeNumber, episode.Title, episode.Overview, episode.SourcePath, episode.SourceSize, episode.VideoCodec, episode.SeasonID, episode.Adult, episode.Width, episode.Height, episode.Checksum, episode.LibraryID).
 		StructScan(&updatedEpisode); err != nil {
 		return err
 	}
@@ -252,64 +397,51 @@ func (store *Store) SaveEpisode(db database.Queryable, episode *Episode) error {
 	// an existing model doesn't change these as they're immutable)
 	episode.ID = updatedEpisode.ID
 	episode.SeasonID = updatedEpisode.SeasonID
+	episode.EpisodeNumber = updatedEpisode.EpisodeNumber
+	episode.Title = updatedEpisode.Title
+	episode.Overview = updatedEpisode.Overview
+	episode.Adult = updatedEpisode.Adult
+	episode.LockedFields = updatedEpisode.LockedFields
 	return nil
 }
 
 // GetMedia is a convinience method for requesting either a Movie
-// or an Episode. The ID provided is used to lookup both, and whichever
-// query is successful is used to populate a media Container.
+// or an Episode using a single query (see GetMedias). If the ID provided
+// does not match a movie or episode, nil is returned.
 func (store *Store) GetMedia(db database.Queryable, mediaID uuid.UUID) *Container {
-	if movie, err := store.GetMovie(db, mediaID); err != nil {
-		// TODO: consider wrapping these three in a transaction (probably overkill though)
-		storeLogger.Emit(logger.DEBUG, "Failed to find movie with media ID %s: %v {falling back to searching for episode}\n", mediaID, err)
-		if episode, err := store.GetEpisode(db, mediaID); err != nil {
-			storeLogger.Emit(logger.DEBUG, "Failed to fetch episode with media ID %s: %v\n", mediaID, err)
-			return nil
-		} else {
-			season, err := store.GetSeason(db, episode.SeasonID)
-			if err != nil {
-				storeLogger.Emit(
-					logger.FATAL,
-					"Episode %s found, but error (%v) occurred when fetching referenced season. This may indicate a serious problem with the referential integrity of the DB\n",
-					mediaID,
-					err,
-				)
-				return nil
-			}
-			series, err := store.GetSeries(db, season.SeriesID)
-			if err != nil {
-				storeLogger.Emit(
-					logger.FATAL,
-					"Episode %s and season %s found, but error (%v) occurred when fetching referenced series. This may indicate a serious problem with the referential integrity of the DB\n",
-					mediaID,
-					season.ID,
-					err,
-				)
-				return nil
-			}
-			return &Container{Type: EpisodeContainerType, Episode: episode, Series: series, Season: season}
-		}
-	} else {
-		return &Container{Type: MovieContainerType, Movie: movie}
+	containers, err := store.GetMedias(db, []uuid.UUID{mediaID})
+	if err != nil {
+		storeLogger.Emit(logger.ERROR, "Failed to fetch media with ID %s: %v\n", mediaID, err)
+		return nil
+	} else if len(containers) == 0 {
+		storeLogger.Emit(logger.DEBUG, "No movie or episode found with media ID %s\n", mediaID)
+		return nil
 	}
+
+	return containers[0]
 }
 
-// ListMovie returns the Movie models for all media of type 'movie' in the database, or an error
+// ListMovie returns the Movie models for all (non-trashed) media of type 'movie' in the database, or an error
 // if the underpinning SQL query failed.
-func (store *Store) ListMovie(db *sqlx.DB) ([]*Movie, error) {
-	var dest []*Movie
-	if err := db.Unsafe().Select(&dest, `SELECT * FROM media WHERE type='movie'`); err != nil {
+func (store *Store) ListMovie(db database.Queryable) ([]*Movie, error) {
+	var dest []*media
+	if err := db.Select(&dest, `SELECT * FROM media WHERE type='movie' AND deleted_at IS NULL`); err != nil {
 		return nil, fmt.Errorf("failed to select all movies: %w", err)
 	}
 
-	return dest, nil
+	movies := make([]*Movie, len(dest))
+	for k, m := range dest {
+		movies[k] = mediaToMovie(m)
+	}
+
+	return movies, nil
 }
 
-// ListSeries returns the Series models for series stored in the database, or an error
+// ListSeries returns the Series models for (non-trashed) series stored in the database, or an error
 // if the underpinning SQL query failed.
 func (store *Store) ListSeries(db database.Queryable) ([]*Series, error) {
 	var dest []*Series
-	if err := db.Select(&dest, `SELECT * FROM series`); err != nil {
+	if err := db.Select(&dest, `SELECT * FROM series WHERE deleted_at IS NULL`); err != nil {
 		return nil, fmt.Errorf("failed to select all series: %w", err)
 	}
 
@@ -327,7 +459,7 @@ func (ord *MediaListOrderBy) String() string {
 
 func getMediaListCte(includeTypes []MediaListType) string {
 	movieEnabledClause := "AND false"
-	seriesAllowedClause := "WHERE false"
+	seriesAllowedClause := "AND false"
 	for _, v := range includeTypes {
 		switch v {
 		case MovieType:
@@ -350,22 +482,22 @@ func getMediaListCte(includeTypes []MediaListType) string {
 	}
 
 	return fmt.Sprintf(`
-		WITH joinedMedia(type, id, title, tmdb_id, created_at, updated_at, series_season_count, genres) AS (
+		WITH joinedMedia(type, id, title, tmdb_id, created_at, updated_at, library_id, series_season_count, genres) AS (
 			SELECT 
-				'movie' AS type, id, title, tmdb_id, created_at, updated_at,
+				'movie' AS type, id, title, tmdb_id, created_at, updated_at, library_id,
 				0, -- season_count forced to zero for movies (it's ignored when reading result rows)
 				(%s) -- coalesced genre clause for movies
 			FROM media
-			WHERE type='movie' %s -- movieEnabledClause
+			WHERE type='movie' AND deleted_at IS NULL %s -- movieEnabledClause
 
 			UNION
 
 			SELECT 
-				'series' AS type, id, title, tmdb_id, created_at, updated_at,
-				(SELECT COUNT(*) FROM season WHERE season.series_id = series.id),
+				'series' AS type, id, title, tmdb_id, created_at, updated_at, library_id,
+				(SELECT COUNT(*) FROM season WHERE season.series_id = series.id AND season.deleted_at IS NULL),
 				(%s) -- coalesced genres clause for series
 			FROM series
-			%s -- seriesAllowedClause
+			WHERE deleted_at IS NULL %s -- seriesAllowedClause
 		)
 		`,
 		getCoalescedGenresSQL("movie_genres", "media", "movie_id"),
@@ -374,24 +506,11 @@ func getMediaListCte(includeTypes []MediaListType) string {
 		seriesAllowedClause)
 }
 
-// ListMedia allows for series/movies to be listed (controllable using allowedTypes). The query also
-// allows for an offset/limit to be provided, facilitating simple paging of the results.
-//   - titleFilter -> only returns results where their title is 'LIKE' the one provided
-//   - allowedTypes -> defaults to movies and series
-//   - allowedGenres -> defaults to no filtering (any/all genres), if any genre IDs are provided then only
-//     media which is associated with ALL of the genres specified
-//   - orderBy -> defaults to updated_at in ascending order
-//   - offset -> defaults to 0
-//   - limit -> default to 15, maximum 100
-func (store *Store) ListMedia(
-	db database.Queryable,
-	titleFilter string,
-	allowedTypes []MediaListType,
-	allowedGenres []int,
-	orderBy []MediaListOrderBy,
-	offset int,
-	limit int,
-) ([]*MediaListResult, error) {
+// ListMedia allows for series/movies to be listed, using the filtering, ordering and paging
+// options provided (see ListMediaOptions). The query supports both offset/limit and cursor based
+// paging of the results.
+func (store *Store) ListMedia(db database.Queryable, opts ListMediaOptions) (*MediaListPage, error) {
+	allowedTypes, orderBy, offset, limit := opts.Types, opts.OrderBy, opts.Offset, opts.Limit
 	if len(allowedTypes) == 0 {
 		allowedTypes = []MediaListType{"movie", "series"}
 	}
@@ -399,36 +518,110 @@ func (store *Store) ListMedia(
 	q := sq.Select("*").From("joinedMedia").Prefix(cte)
 
 	// Optional genre filtering
-	if len(allowedGenres) > 0 {
+	if len(opts.Genres) > 0 {
 		q = q.Where(`
 			(
 				SELECT ARRAY_agg(CAST(genre_data->>'id' AS bigint))
 				FROM jsonb_array_elements(joinedMedia.genres)
 				AS genre_data
 			) @> ?`,
-			pq.Array(allowedGenres))
+			pq.Array(opts.Genres))
+	}
+
+	// Optional collection filtering
+	if opts.CollectionID != nil {
+		q = q.Where(`
+			joinedMedia.id IN (
+				SELECT cm.media_id FROM collection_media cm WHERE cm.collection_id = ?
+				UNION
+				SELECT season.series_id FROM collection_media cm
+				INNER JOIN media ON media.id = cm.media_id
+				INNER JOIN season ON season.id = media.season_id
+				WHERE cm.collection_id = ?
+			)`,
+			*opts.CollectionID, *opts.CollectionID)
+	}
+
+	// Optional tag filtering, requiring either ALL or ANY of the tags
+	if opts.Tags != nil && len(opts.Tags.IDs) > 0 {
+		operator := "&&"
+		if opts.Tags.MatchAll {
+			operator = "@>"
+		}
+
+		tagIDs := make(pq.StringArray, len(opts.Tags.IDs))
+		for k, v := range opts.Tags.IDs {
+			tagIDs[k] = v.String()
+		}
+
+		q = q.Where(fmt.Sprintf(`
+			ARRAY(
+				SELECT mt.tag_id FROM movie_tags mt WHERE mt.movie_id = joinedMedia.id
+				UNION
+				SELECT st.tag_id FROM series_tags st WHERE st.series_id = joinedMedia.id
+			) %s CAST(? AS uuid[])`, operator),
+			tagIDs)
+	}
+
+	// Optional library filtering. Media which does not belong to a library is never filtered
+	if opts.Libraries != nil {
+		libraryIDs := make(pq.StringArray, len(opts.Libraries.IDs))
+		for k, v := range opts.Libraries.IDs {
+			libraryIDs[k] = v.String()
+		}
+
+		q = q.Where(`(joinedMedia.library_id IS NULL OR joinedMedia.library_id = ANY(CAST(? AS uuid[])))`, libraryIDs)
 	}
 
 	// Optional title filtering
-	trimmedTitleFilter := strings.TrimSpace(titleFilter)
+	trimmedTitleFilter := strings.TrimSpace(opts.Title)
 	if len(trimmedTitleFilter) > 0 {
 		q = q.Where(`LOWER(joinedMedia.title) LIKE LOWER('%' || ? || '%')`, trimmedTitleFilter)
 	}
 
+	// Optional total count, using the filters but not the paging
+	var totalCount *int
+	if opts.IncludeTotal {
+		countQuery, countArgs, err := q.RemoveColumns().Column("COUNT(*)").ToSql()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build media count query: %w", err)
+		}
+
+		var count int
+		if err := db.Get(&count, db.Rebind(countQuery), countArgs...); err != nil {
+			return nil, fmt.Errorf("failed to count media with built query: %w", err)
+		}
+		totalCount = &count
+	}
+
 	// Ordering, defaulting to updated_at ascending
 	if len(orderBy) == 0 {
 		orderBy = append(orderBy, MediaListOrderBy{Column: UpdatedAtColumn, Descending: false})
 	}
+	orderBy = withStableOrdering(orderBy)
 	for _, s := range orderBy {
 		q = q.OrderByClause(s.String())
 	}
 
-	// Optional limiting, maximum of 100, defaulting to 15
+	// Optional cursor, which takes precedence over the offset
+	if opts.Cursor != "" {
+		values, err := decodeCursor(opts.Cursor, orderBy)
+		if err != nil {
+			return nil, err
+		}
+
+		q = q.Where(cursorCondition(orderBy, values))
+		offset = 0
+	}
+
+	// Optional limiting, maximum of 100, defaulting to 15. One additional
+	// row is selected so we can tell if there are more results.
 	if limit > 0 {
-		q = q.Limit(uint64(min(limit, 100)))
+		limit = min(limit, 100)
 	} else {
-		q = q.Limit(15)
+		limit = 15
 	}
+	q = q.Limit(uint64(limit + 1))
 
 	// Optional Offsetting, default to 0
 	query, args, err := q.Offset(uint64(max(offset, 0))).ToSql()
@@ -443,6 +636,7 @@ func (store *Store) ListMedia(
 		TmdbID      string                        `db:"tmdb_id"`
 		CreatedAt   time.Time                     `db:"created_at"`
 		UpdatedAt   time.Time                     `db:"updated_at"`
+		LibraryID   *uuid.UUID                    `db:"library_id"`
 		SeasonCount int                           `db:"series_season_count"`
 		MediaType   string                        `db:"type"`
 		Genres      database.JSONColumn[[]*Genre] `db:"genres"`
@@ -452,20 +646,38 @@ func (store *Store) ListMedia(
 		return nil, fmt.Errorf("failed to query media with built query: %w", err)
 	}
 
-	out := make([]*MediaListResult, len(results))
+	page := &MediaListPage{TotalCount: totalCount}
+	if len(results) > limit {
+		results = results[:limit]
+
+		last := results[len(results)-1]
+		values := make([]string, len(orderBy))
+		for k, ord := range orderBy {
+			values[k] = cursorValue(ord.Column, last.ID, last.Title, last.CreatedAt, last.UpdatedAt)
+		}
+
+		nextCursor, err := encodeCursor(orderBy, values)
+		if err != nil {
+			return nil, err
+		}
+		page.HasMore = true
+		page.NextCursor = nextCursor
+	}
+
+	page.Results = make([]*MediaListResult, len(results))
 	for k, v := range results {
-		model := Model{ID: v.ID, TmdbID: v.TmdbID, CreatedAt: v.CreatedAt, UpdatedAt: v.UpdatedAt, Title: v.Title}
+		model := Model{ID: v.ID, TmdbID: v.TmdbID, CreatedAt: v.CreatedAt, UpdatedAt: v.UpdatedAt, Title: v.Title, LibraryID: v.LibraryID}
 		switch v.MediaType {
 		case "movie":
-			out[k] = &MediaListResult{Movie: &Movie{Model: model, Genres: *v.Genres.Get()}}
+			page.Results[k] = &MediaListResult{Movie: &Movie{Model: model, Genres: *v.Genres.Get()}}
 		case "series":
-			out[k] = &MediaListResult{Series: &SeriesStub{Series: &Series{Model: model, Genres: *v.Genres.Get()}, SeasonCount: v.SeasonCount}}
+			page.Results[k] = &MediaListResult{Series: &SeriesStub{Series: &Series{Model: model, Genres: *v.Genres.Get()}, SeasonCount: v.SeasonCount}}
 		default:
 			return nil, fmt.Errorf("type of list result %v is illegal. Expected 'movie' or 'series', found '%s'", v, v.MediaType)
 		}
 	}
 
-	return out, nil
+	return page, nil
 }
 
 // CountSeasonsInSeries queries the database for the number of seasons associated with
@@ -476,6 +688,7 @@ func (store *Store) CountSeasonsInSeries(db database.Queryable, seriesIDs []uuid
 		SELECT series.id AS id, COUNT(season.*) AS count FROM series
 		LEFT JOIN season
 		  ON season.series_id = series.id
+		 AND season.deleted_at IS NULL
 		WHERE series.id IN (?)
 		GROUP BY series.id`, seriesIDs)
 	if err != nil {
@@ -505,13 +718,7 @@ func (store *Store) CountSeasonsInSeries(db database.Queryable, seriesIDs []uuid
 // series has no seasons, the result will be an empty slice.
 func (store *Store) GetSeasonsForSeries(db database.Queryable, seriesID uuid.UUID) ([]*Season, error) {
 	var dest []*Season
-	if err := db.Select(&dest, `
-		SELECT season.* FROM series
-     	LEFT JOIN season
-	      ON season.series_id = series.id
-	    WHERE series.id=$1`,
-		seriesID,
-	); err != nil {
+	if err := db.Select(&dest, `SELECT * FROM season WHERE series_id=$1 AND deleted_at IS NULL`, seriesID); err != nil {
 		return nil, fmt.Errorf("failed to fetch seasons for series %s: %w", seriesID, err)
 	}
 
@@ -537,9 +744,11 @@ func (store *Store) GetEpisodesForSeries(db database.Queryable, seriesIDs []uuid
 		SELECT series.id AS owning_series_id, media.* FROM series
 		INNER JOIN season
 		  ON season.series_id = series.id
+		 AND season.deleted_at IS NULL
 		INNER JOIN media
 		  ON media.type = 'episode'
 		 AND media.season_id = season.id
+		 AND media.deleted_at IS NULL
 		WHERE series.id IN (?)`, seriesIDs)
 	if err != nil {
 		return nil, wrap(err)
@@ -582,6 +791,7 @@ func (store *Store) GetEpisodesForSeasons(db database.Queryable, seasonIDs []uui
      	INNER JOIN media
 	      ON media.type = 'episode'
 		 AND media.season_id = season.id
+		 AND media.deleted_at IS NULL
 	    WHERE season.id IN (?)`, seasonIDs)
 	if err != nil {
 		return nil, wrap(err)
@@ -617,22 +827,22 @@ func (store *Store) GetMovieWithTmdbID(db database.Queryable, tmdbID string) (*M
 
 // GetSeries searches for an existing series with the Thea PK ID provided.
 func (store *Store) GetSeries(db database.Queryable, seriesID uuid.UUID) (*Series, error) {
-	return queryRow[Series](db, SeriesTable, IDCol, seriesID, "")
+	return queryRow[Series](db, SeriesTable, IDCol, seriesID, NotTrashedClause)
 }
 
 // GetSeriesWithTmdbID searches for an existing series with the TMDB unique ID provided.
 func (store *Store) GetSeriesWithTmdbID(db database.Queryable, tmdbID string) (*Series, error) {
-	return queryRow[Series](db, SeriesTable, TmdbIDCol, tmdbID, "")
+	return queryRow[Series](db, SeriesTable, TmdbIDCol, tmdbID, NotTrashedClause)
 }
 
 // GetSeason searches for an existing season with the Thea PK ID provided.
 func (store *Store) GetSeason(db database.Queryable, seasonID uuid.UUID) (*Season, error) {
-	return queryRow[Season](db, SeasonTable, IDCol, seasonID, "")
+	return queryRow[Season](db, SeasonTable, IDCol, seasonID, NotTrashedClause)
 }
 
 // GetSeasonWithTmdbID searches for an existing season with the TMDB unique ID provided.
 func (store *Store) GetSeasonWithTmdbID(db database.Queryable, tmdbID string) (*Season, error) {
-	return queryRow[Season](db, SeasonTable, TmdbIDCol, tmdbID, "")
+	return queryRow[Season](db, SeasonTable, TmdbIDCol, tmdbID, NotTrashedClause)
 }
 
 // GetEpisode searches for an existing episode with the Thea PK ID provided.
@@ -646,64 +856,297 @@ func (store *Store) GetEpisodeWithTmdbID(db database.Queryable, tmdbID string) (
 }
 
 // GetAllSourcePaths returns all the source paths related
-// to media that is currently known to Thea by polling the database.
-func (store *Store) GetAllSourcePaths(db *sqlx.DB) ([]string, error) {
+// to media that is currently known to Thea by polling the database. This
+// includes the source paths of any additional versions of the media.
+func (store *Store) GetAllSourcePaths(db database.Queryable) ([]string, error) {
 	var paths []string
-	if err := db.Select(&paths, `SELECT source_path FROM media`); err != nil {
+	if err := db.Select(&paths, `SELECT source_path FROM media UNION SELECT source_path FROM media_versions`); err != nil {
 		return nil, err
 	}
 
 	return paths, nil
 }
 
-// DeleteSeries deletes the series with the given ID, including all it's seasons and
-// enclosed episodes.
-//
-// NB: It is important to explicitly delete associated media transcodes for the affected
-// episodes before attempting to delete this resource - failure to do so will cause
-// this query to fail.
-func (store *Store) DeleteSeries(db database.Queryable, seriesID uuid.UUID) error {
-	if _, err := db.Exec(`DELETE FROM series WHERE id=$1`, seriesID); err != nil {
-		return fmt.Errorf("deletion of series %s failed: %w", seriesID, err)
+// ListSourceFiles returns the source file of every movie and episode
+// which is not in the trash.
+func (store *Store) ListSourceFiles(db database.Queryable) ([]*SourceFile, error) {
+	var dest []*SourceFile
+	if err := db.Select(&dest, `SELECT id, source_path, degraded_at, checksum, corrupted_at FROM media WHERE deleted_at IS NULL`); err != nil {
+		return nil, fmt.Errorf("failed to select media source files: %w", err)
+	}
+
+	return dest, nil
+}
+
+// SetMediaDegraded marks the movie/episode with the ID provided as degraded (or not),
+// indicating whether it's source file could be found on disk.
+func (store *Store) SetMediaDegraded(db database.Queryable, mediaID uuid.UUID, degraded bool) error {
+	if _, err := db.Exec(`
+		UPDATE media
+		SET degraded_at=CASE WHEN $2 THEN COALESCE(degraded_at, current_timestamp) ELSE NULL END
+		WHERE id=$1`,
+		mediaID, degraded,
+	); err != nil {
+		return fmt.Errorf("failed to set degraded state of media %s: %w", mediaID, err)
 	}
 
 	return nil
 }
 
-// DeleteSeason deletes the series with the given ID, including all it's enclosed episodes.
-//
-// NB: It is important to explicitly delete associated media transcodes for the affected
-// episodes before attempting to delete this resource - failure to do so will cause
-// this query to fail.
-func (store *Store) DeleteSeason(db database.Queryable, seasonID uuid.UUID) error {
-	if _, err := db.Exec(`DELETE FROM season WHERE id=$1`, seasonID); err != nil {
-		return fmt.Errorf("deletion of season %s failed: %w", seasonID, err)
+// SetMediaChecksum records the checksum of the source file of the movie/episode with the
+// ID provided. This is used to record the checksum of media ingested before checksums
+// were computed at ingest.
+func (store *Store) SetMediaChecksum(db database.Queryable, mediaID uuid.UUID, checksum string) error {
+	if _, err := db.Exec(`UPDATE media SET checksum=$2 WHERE id=$1`, mediaID, checksum); err != nil {
+		return fmt.Errorf("failed to set checksum of media %s: %w", mediaID, err)
 	}
 
 	return nil
 }
 
-// DeleteEpisode deletes the episode with the given ID
-//
-// NB: It is important to explicitly delete associated media transcodes for the affected
-// episode before attempting to delete this resource - failure to do so will cause
-// this query to fail.
-func (store *Store) DeleteEpisode(db database.Queryable, episodeID uuid.UUID) error {
-	if _, err := db.Exec(`DELETE FROM media WHERE type='episode' AND id=$1`, episodeID); err != nil {
-		return fmt.Errorf("deletion of episode %s failed: %w", episodeID, err)
+// SetMediaCorrupted marks the movie/episode with the ID provided as corrupted (or not),
+// indicating whether it's source file matched it's recorded checksum.
+func (store *Store) SetMediaCorrupted(db database.Queryable, mediaID uuid.UUID, corrupted bool) error {
+	if _, err := db.Exec(`
+		UPDATE media
+		SET corrupted_at=CASE WHEN $2 THEN COALESCE(corrupted_at, current_timestamp) ELSE NULL END
+		WHERE id=$1`,
+		mediaID, corrupted,
+	); err != nil {
+		return fmt.Errorf("failed to set corrupted state of media %s: %w", mediaID, err)
+	}
+
+	return nil
+}
+
+// TrashMovie moves the movie with the given ID to the trash.
+func (store *Store) TrashMovie(db database.Queryable, movieID uuid.UUID) error {
+	if _, err := db.Exec(`UPDATE media SET deleted_at=current_timestamp WHERE type='movie' AND id=$1 AND deleted_at IS NULL`, movieID); err != nil {
+		return fmt.Errorf("trashing of movie %s failed: %w", movieID, err)
+	}
+
+	return nil
+}
+
+// TrashEpisode moves the episode with the given ID to the trash.
+func (store *Store) TrashEpisode(db database.Queryable, episodeID uuid.UUID) error {
+	if _, err := db.Exec(`UPDATE media SET deleted_at=current_timestamp WHERE type='episode' AND id=$1 AND deleted_at IS NULL`, episodeID); err != nil {
+		return fmt.Errorf("trashing of episode %s failed: %w", episodeID, err)
+	}
+
+	return nil
+}
+
+// TrashSeason moves the season with the given ID to the trash, including all it's enclosed
+// episodes which are not already in the trash.
+func (store *Store) TrashSeason(db database.Queryable, seasonID uuid.UUID) error {
+	if _, err := db.Exec(`
+		WITH trashed_season AS (
+			UPDATE season SET deleted_at=current_timestamp
+			WHERE id=$1 AND deleted_at IS NULL
+			RETURNING id
+		)
+		UPDATE media SET deleted_at=current_timestamp
+		FROM trashed_season
+		WHERE media.season_id = trashed_season.id
+		  AND media.deleted_at IS NULL`,
+		seasonID,
+	); err != nil {
+		return fmt.Errorf("trashing of season %s failed: %w", seasonID, err)
+	}
+
+	return nil
+}
+
+// TrashSeries moves the series with the given ID to the trash, including all it's seasons
+// and enclosed episodes which are not already in the trash.
+func (store *Store) TrashSeries(db database.Queryable, seriesID uuid.UUID) error {
+	if _, err := db.Exec(`
+		WITH trashed_series AS (
+			UPDATE series SET deleted_at=current_timestamp
+			WHERE id=$1 AND deleted_at IS NULL
+			RETURNING id
+		), trashed_seasons AS (
+			UPDATE season SET deleted_at=current_timestamp
+			FROM trashed_series
+			WHERE season.series_id = trashed_series.id
+			  AND season.deleted_at IS NULL
+			RETURNING season.id
+		)
+		UPDATE media SET deleted_at=current_timestamp
+		FROM trashed_seasons
+		WHERE media.season_id = trashed_seasons.id
+		  AND media.deleted_at IS NULL`,
+		seriesID,
+	); err != nil {
+		return fmt.Errorf("trashing of series %s failed: %w", seriesID, err)
+	}
+
+	return nil
+}
+
+// ListTrash returns all the items currently in the trash, most recently trashed first. Children
+// which were trashed alongside their parent are omitted, as they will be restored (or purged) with
+// the parent.
+func (store *Store) ListTrash(db database.Queryable) ([]*TrashedItem, error) {
+	var dest []*TrashedItem
+	if err := db.Select(&dest, `
+		SELECT id, 'series' AS type, title, deleted_at FROM series
+		WHERE deleted_at IS NOT NULL
+
+		UNION ALL
+
+		SELECT season.id, 'season' AS type, season.title, season.deleted_at FROM season
+		INNER JOIN series
+		  ON series.id = season.series_id
+		WHERE season.deleted_at IS NOT NULL
+		  AND series.deleted_at IS DISTINCT FROM season.deleted_at
+
+		UNION ALL
+
+		SELECT media.id, CAST(media.type AS TEXT) AS type, media.title, media.deleted_at FROM media
+		LEFT JOIN season
+		  ON season.id = media.season_id
+		WHERE media.deleted_at IS NOT NULL
+		  AND season.deleted_at IS DISTINCT FROM media.deleted_at
+
+		ORDER BY deleted_at DESC`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	return dest, nil
+}
+
+// RestoreSeries restores the trashed series with the given ID, along with the seasons and
+// episodes which were trashed alongside it. If the series is not in the trash,
+// ErrNotRestorable is returned.
+func (store *Store) RestoreSeries(db database.Queryable, seriesID uuid.UUID) error {
+	var restored int
+	if err := db.Get(&restored, `
+		WITH target AS (
+			SELECT id, deleted_at FROM series
+			WHERE id=$1 AND deleted_at IS NOT NULL
+		), restored_series AS (
+			UPDATE series SET deleted_at=NULL
+			FROM target
+			WHERE series.id = target.id
+		), restored_seasons AS (
+			UPDATE season SET deleted_at=NULL
+			FROM target
+			WHERE season.series_id = target.id
+			  AND season.deleted_at = target.deleted_at
+		), restored_episodes AS (
+			UPDATE media SET deleted_at=NULL
+			FROM target, season
+			WHERE season.series_id = target.id
+			  AND media.season_id = season.id
+			  AND media.deleted_at = target.deleted_at
+		)
+		SELECT COUNT(*) FROM target`,
+		seriesID,
+	); err != nil {
+		return fmt.Errorf("restoration of series %s failed: %w", seriesID, err)
+	}
+
+	if restored == 0 {
+		return ErrNotRestorable
 	}
 
 	return nil
 }
 
-// DeleteMovie deletes the movie with the given ID
+// RestoreSeason restores the trashed season with the given ID, along with the episodes
+// which were trashed alongside it. If the season is not in the trash, or if it's series
+// is in the trash, ErrNotRestorable is returned.
+func (store *Store) RestoreSeason(db database.Queryable, seasonID uuid.UUID) error {
+	var restored int
+	if err := db.Get(&restored, `
+		WITH target AS (
+			SELECT season.id, season.deleted_at FROM season
+			INNER JOIN series
+			  ON series.id = season.series_id
+			 AND series.deleted_at IS NULL
+			WHERE season.id=$1 AND season.deleted_at IS NOT NULL
+		), restored_season AS (
+			UPDATE season SET deleted_at=NULL
+			FROM target
+			WHERE season.id = target.id
+		), restored_episodes AS (
+			UPDATE media SET deleted_at=NULL
+			FROM target
+			WHERE media.season_id = target.id
+			  AND media.deleted_at = target.deleted_at
+		)
+		SELECT COUNT(*) FROM target`,
+		seasonID,
+	); err != nil {
+		return fmt.Errorf("restoration of season %s failed: %w", seasonID, err)
+	}
+
+	if restored == 0 {
+		return ErrNotRestorable
+	}
+
+	return nil
+}
+
+// RestoreMedia restores the trashed movie or episode with the given ID. If the media
+// is not in the trash, or if it's an episode whose season is in the trash, ErrNotRestorable
+// is returned.
+func (store *Store) RestoreMedia(db database.Queryable, mediaID uuid.UUID) error {
+	res, err := db.Exec(`
+		UPDATE media SET deleted_at=NULL
+		WHERE id=$1
+		  AND deleted_at IS NOT NULL
+		  AND (season_id IS NULL OR EXISTS (SELECT 1 FROM season WHERE season.id = media.season_id AND season.deleted_at IS NULL))`,
+		mediaID,
+	)
+	if err != nil {
+		return fmt.Errorf("restoration of media %s failed: %w", mediaID, err)
+	}
+
+	if rows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("restoration of media %s failed: %w", mediaID, err)
+	} else if rows == 0 {
+		return ErrNotRestorable
+	}
+
+	return nil
+}
+
+// ListTrashedMediaIDs returns the IDs of all movies/episodes which were trashed before
+// the given time, either directly or as a result of their season/series being trashed.
+func (store *Store) ListTrashedMediaIDs(db database.Queryable, trashedBefore time.Time) ([]uuid.UUID, error) {
+	var dest []uuid.UUID
+	if err := db.Select(&dest, `
+		SELECT media.id FROM media
+		LEFT JOIN season
+		  ON season.id = media.season_id
+		LEFT JOIN series
+		  ON series.id = season.series_id
+		WHERE media.deleted_at < $1
+		   OR season.deleted_at < $1
+		   OR series.deleted_at < $1`,
+		trashedBefore,
+	); err != nil {
+		return nil, fmt.Errorf("failed to list media trashed before %s: %w", trashedBefore, err)
+	}
+
+	return dest, nil
+}
+
+// PurgeTrash permanently deletes all series, seasons and media which were trashed before
+// the given time. Deletion of a series/season cascades to it's children.
 //
 // NB: It is important to explicitly delete associated media transcodes for the affected
-// movie before attempting to delete this resource - failure to do so will cause
+// media (see ListTrashedMediaIDs) before attempting to purge - failure to do so will cause
 // this query to fail.
-func (store *Store) DeleteMovie(db database.Queryable, movieID uuid.UUID) error {
-	if _, err := db.Exec(`DELETE FROM media WHERE type='movie' AND id=$1`, movieID); err != nil {
-		return fmt.Errorf("deletion of movie %s failed: %w", movieID, err)
+func (store *Store) PurgeTrash(db database.Queryable, trashedBefore time.Time) error {
+	for _, table := range []string{SeriesTable, SeasonTable, MediaTable} {
+		if _, err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE deleted_at < $1`, table), trashedBefore); err != nil {
+			return fmt.Errorf("purge of trashed %s failed: %w", table, err)
+		}
 	}
 
 	return nil
@@ -712,7 +1155,7 @@ func (store *Store) DeleteMovie(db database.Queryable, movieID uuid.UUID) error
 // queryRowMovie extracts a Media row from the database and ensures that the row returned represents
 // a movie (the type must be 'movie', and episode-specific information must be nil).
 func queryRowMovie(db database.Queryable, table string, col string, val any) (*Movie, error) {
-	r, e := queryRow[media](db, table, col, val, MediaMovieClause)
+	r, e := queryRow[media](db, table, col, val, MediaMovieClause+" "+NotTrashedClause)
 	if e != nil {
 		return nil, e
 	}
@@ -721,16 +1164,13 @@ func queryRowMovie(db database.Queryable, table string, col string, val any) (*M
 		return nil, fmt.Errorf("media query for an episode returned malformed data expected ('movie', nil, nil), found (%v, %v, %v)", r.Type, r.EpisodeNumber, r.SeasonID)
 	}
 
-	return &Movie{
-		Model:     r.Model,
-		Watchable: r.Watchable,
-	}, nil
+	return mediaToMovie(r), nil
 }
 
 // queryRowEpisode extracts a Media row from the database and ensures that the row returned represents
 // an episode (the type must be 'episode', and the episode-specific information must be non-nil).
 func queryRowEpisode(db database.Queryable, table string, col string, val any) (*Episode, error) {
-	r, e := queryRow[media](db, table, col, val, MediaEpisodeClause)
+	r, e := queryRow[media](db, table, col, val, MediaEpisodeClause+" "+NotTrashedClause)
 	if e != nil {
 		return nil, e
 	}
@@ -757,6 +1197,13 @@ func queryRow[T any](db database.Queryable, table string, col string, val any, a
 	return &dest, nil
 }
 
+func mediaToMovie(m *media) *Movie {
+	return &Movie{
+		Model:     m.Model,
+		Watchable: m.Watchable,
+	}
+}
+
 func mediaToEpisode(m *media) *Episode {
 	return &Episode{
 		Model:         m.Model,
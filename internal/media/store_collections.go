@@ -0,0 +1,189 @@
+package media
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+type (
+	// Collection is an ordered group of movies and/or episodes. Collections are
+	// either curated by users, or created automatically from the TMDB collection
+	// a movie belongs to (in which case the TmdbID is non-nil).
+	Collection struct {
+		ID          uuid.UUID `db:"id"`
+		TmdbID      *string   `db:"tmdb_id"` // Nullable
+		Title       string    `db:"title"`
+		Description string    `db:"description"`
+		CreatedAt   time.Time `db:"created_at"`
+		UpdatedAt   time.Time `db:"updated_at"`
+	}
+
+	// CollectionItem is a single movie or episode which is a member of a collection.
+	CollectionItem struct {
+		MediaID  uuid.UUID `db:"media_id"`
+		Type     string    `db:"type"`
+		Title    string    `db:"title"`
+		Position int       `db:"position"`
+	}
+
+	// InflatedCollection is a collection along with it's members, in order.
+	InflatedCollection struct {
+		*Collection
+		Items []*CollectionItem
+	}
+
+	mediaCollectionStore struct{}
+)
+
+const CollectionTable = "collection"
+
+// CreateCollection inserts the collection provided. The timestamps of the
+// collection are set to match the inserted row.
+func (store *mediaCollectionStore) CreateCollection(db database.Queryable, collection *Collection) error {
+	if err := db.QueryRowx(`
+		INSERT INTO collection(id, tmdb_id, title, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, current_timestamp, current_timestamp)
+		RETURNING *`,
+		collection.ID, collection.TmdbID, collection.Title, collection.Description,
+	).StructScan(collection); err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	return nil
+}
+
+// SaveTmdbCollection upserts the collection provided. Existing collections to update are
+// found using the 'TmdbID', as this is expected to be a stable identifier.
+//
+// NOTE: the ID of the collection may be UPDATED to match existing DB entry (if any).
+func (store *mediaCollectionStore) SaveTmdbCollection(db database.Queryable, collection *Collection) error {
+	if collection.TmdbID == nil {
+		return fmt.Errorf("collection %s has no TMDB ID", collection.ID)
+	}
+
+	if err := db.QueryRowx(`
+		INSERT INTO collection(id, tmdb_id, title, created_at, updated_at)
+		VALUES ($1, $2, $3, current_timestamp, current_timestamp)
+		ON CONFLICT(tmdb_id) DO UPDATE
+			SET (title, updated_at) = (EXCLUDED.title, current_timestamp)
+		RETURNING *`,
+		collection.ID, collection.TmdbID, collection.Title,
+	).StructScan(collection); err != nil {
+		return fmt.Errorf("failed to save TMDB collection %s: %w", *collection.TmdbID, err)
+	}
+
+	return nil
+}
+
+// UpdateCollection updates the title and/or description of the collection with the ID
+// provided. Nil values are left unchanged.
+func (store *mediaCollectionStore) UpdateCollection(db database.Queryable, collectionID uuid.UUID, title *string, description *string) (*Collection, error) {
+	var dest Collection
+	if err := db.Get(&dest, `
+		UPDATE collection
+		SET title=COALESCE($2, title), description=COALESCE($3, description), updated_at=current_timestamp
+		WHERE id=$1
+		RETURNING *`,
+		collectionID, title, description,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update collection %s: %w", collectionID, err)
+	}
+
+	return &dest, nil
+}
+
+func (store *mediaCollectionStore) GetCollection(db database.Queryable, collectionID uuid.UUID) (*Collection, error) {
+	return queryRow[Collection](db, CollectionTable, IDCol, collectionID, "")
+}
+
+// ListCollections returns all collections, ordered by their title.
+func (store *mediaCollectionStore) ListCollections(db database.Queryable) ([]*Collection, error) {
+	var dest []*Collection
+	if err := db.Select(&dest, `SELECT * FROM collection ORDER BY title, id`); err != nil {
+		return nil, fmt.Errorf("failed to select all collections: %w", err)
+	}
+
+	return dest, nil
+}
+
+// DeleteCollection deletes the collection with the ID provided. The members
+// of the collection are not affected.
+func (store *mediaCollectionStore) DeleteCollection(db database.Queryable, collectionID uuid.UUID) error {
+	var id uuid.UUID
+	if err := db.Get(&id, `DELETE FROM collection WHERE id=$1 RETURNING id`, collectionID); err != nil {
+		return fmt.Errorf("failed to delete collection %s: %w", collectionID, err)
+	}
+
+	return nil
+}
+
+// GetCollectionItems returns the (non-trashed) members of the collection
+// with the ID provided, in order.
+func (store *mediaCollectionStore) GetCollectionItems(db database.Queryable, collectionID uuid.UUID) ([]*CollectionItem, error) {
+	var dest []*CollectionItem
+	if err := db.Select(&dest, `
+		SELECT cm.media_id, cm.position, media.type, media.title FROM collection_media cm
+		INNER JOIN media
+		  ON media.id = cm.media_id
+		 AND media.deleted_at IS NULL
+		WHERE cm.collection_id=$1
+		ORDER BY cm.position`,
+		collectionID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to select items of collection %s: %w", collectionID, err)
+	}
+
+	return dest, nil
+}
+
+// SetCollectionItems replaces the members of the collection with the movies/episodes
+// provided. The position of each member is taken from it's index in the slice.
+//
+// NB: This query will FAIL if any of the given media IDs do not have a row in the media table.
+func (store *mediaCollectionStore) SetCollectionItems(db database.Queryable, collectionID uuid.UUID, mediaIDs []uuid.UUID) error {
+	if _, err := db.Exec(`DELETE FROM collection_media WHERE collection_id=$1`, collectionID); err != nil {
+		return fmt.Errorf("failed to clear items of collection %s: %w", collectionID, err)
+	}
+
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+
+	type collectionMedia struct {
+		CollectionID uuid.UUID `db:"collection_id"`
+		MediaID      uuid.UUID `db:"media_id"`
+		Position     int       `db:"position"`
+	}
+	rows := make([]collectionMedia, len(mediaIDs))
+	for k, v := range mediaIDs {
+		rows[k] = collectionMedia{collectionID, v, k}
+	}
+
+	if _, err := db.NamedExec(`
+		INSERT INTO collection_media(collection_id, media_id, position)
+		VALUES (:collection_id, :media_id, :position)`,
+		rows,
+	); err != nil {
+		return fmt.Errorf("failed to insert items of collection %s: %w", collectionID, err)
+	}
+
+	return nil
+}
+
+// AddToCollection appends the movie/episode provided to the end of the collection. If the media
+// is already a member of the collection, it's position is left unchanged.
+func (store *mediaCollectionStore) AddToCollection(db database.Queryable, collectionID uuid.UUID, mediaID uuid.UUID) error {
+	if _, err := db.Exec(`
+		INSERT INTO collection_media(collection_id, media_id, position)
+		SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM collection_media WHERE collection_id=$1
+		ON CONFLICT(collection_id, media_id) DO NOTHING`,
+		collectionID, mediaID,
+	); err != nil {
+		return fmt.Errorf("failed to add media %s to collection %s: %w", mediaID, collectionID, err)
+	}
+
+	return nil
+}
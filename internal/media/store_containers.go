@@ -0,0 +1,236 @@
+package media
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// containerColumns is the list of columns selected by the container queries. The media/season/series
+// columns are aliased as all three tables are joined together, and any of them may be NULL
+// depending on the type of container the row belongs to. The labels of the tags attached to
+// the movie, or the series of the episode, are selected as 'tags', and the output directory
+// of the library the container belongs to is selected as 'output_directory'.
+const containerColumns = `
+	COALESCE((
+		SELECT library.output_directory FROM library WHERE library.id = COALESCE(media.library_id, series.library_id)
+	), '') AS output_directory,
+	ARRAY(
+		SELECT tag.label FROM movie_tags mt INNER JOIN tag ON tag.id = mt.tag_id WHERE mt.movie_id = media.id
+		UNION
+		SELECT tag.label FROM series_tags st INNER JOIN tag ON tag.id = st.tag_id WHERE st.series_id = series.id
+	) AS tags,
+	media.id AS media_id, media.type AS media_type, media.tmdb_id AS media_tmdb_id, media.title AS media_title,
+	media.created_at AS media_created_at, media.updated_at AS media_updated_at, media.source_path AS media_source_path,
+	media.source_size AS media_source_size, media.video_codec AS media_video_codec, media.adult AS media_adult, media.frame_width AS media_frame_width, media.frame_height AS media_frame_height,
+	media.episode_number AS media_episode_number, media.degraded_at AS media_degraded_at,
+	media.checksum AS media_checksum, media.corrupted_at AS media_corrupted_at, media.library_id AS media_library_id,
+	season.id AS season_id, season.tmdb_id AS season_tmdb_id, season.title AS season_title,
+	season.season_number AS season_season_number, season.created_at AS season_created_at, season.updated_at AS season_updated_at,
+	series.id AS series_id, series.tmdb_id AS series_tmdb_id, series.title AS series_title,
+	series.created_at AS series_created_at, series.updated_at AS series_updated_at, series.library_id AS series_library_id`
+
+// containerRow is a single row returned by the container queries. Each row contains
+// a (nullable) media, season and series which are used to assemble the containers.
+type containerRow struct {
+	RequestedID     uuid.UUID      `db:"requested_id"`
+	Tags            pq.StringArray `db:"tags"`
+	OutputDirectory string         `db:"output_directory"`
+
+	MediaID            *uuid.UUID `db:"media_id"`
+	MediaType          *string    `db:"media_type"`
+	MediaTmdbID        *string    `db:"media_tmdb_id"`
+	MediaTitle         *string    `db:"media_title"`
+	MediaCreatedAt     *time.Time `db:"media_created_at"`
+	MediaUpdatedAt     *time.Time `db:"media_updated_at"`
+	MediaSourcePath    *string    `db:"media_source_path"`
+	MediaSourceSize    *int64     `db:"media_source_size"`
+	MediaVideoCodec    *string    `db:"media_video_codec"`
+	MediaAdult         *bool      `db:"media_adult"`
+	MediaFrameWidth    *int       `db:"media_frame_width"`
+	MediaFrameHeight   *int       `db:"media_frame_height"`
+	MediaEpisodeNumber *int       `db:"media_episode_number"`
+	MediaDegradedAt    *time.Time `db:"media_degraded_at"`
+	MediaChecksum      *string    `db:"media_checksum"`
+	MediaCorruptedAt   *time.Time `db:"media_corrupted_at"`
+	MediaLibraryID     *uuid.UUID `db:"media_library_id"`
+
+	SeasonID        *uuid.UUID `db:"season_id"`
+	SeasonTmdbID    *string    `db:"season_tmdb_id"`
+	SeasonTitle     *string    `db:"season_title"`
+	SeasonNumber    *int       `db:"season_season_number"`
+	SeasonCreatedAt *time.Time `db:"season_created_at"`
+	SeasonUpdatedAt *time.Time `db:"season_updated_at"`
+
+	SeriesID        *uuid.UUID `db:"series_id"`
+	SeriesTmdbID    *string    `db:"series_tmdb_id"`
+	SeriesTitle     *string    `db:"series_title"`
+	SeriesCreatedAt *time.Time `db:"series_created_at"`
+	SeriesUpdatedAt *time.Time `db:"series_updated_at"`
+	SeriesLibraryID *uuid.UUID `db:"series_library_id"`
+}
+
+// mediaContainerQuery selects the media (movies/episodes) with the given IDs, along with the season
+// and series of the episodes. It contains a single bindvar, which should be provided the media IDs.
+var mediaContainerQuery = fmt.Sprintf(`
+	SELECT media.id AS requested_id, %s FROM media
+	LEFT JOIN season
+	  ON season.id = media.season_id
+	LEFT JOIN series
+	  ON series.id = season.series_id
+	WHERE media.id IN (?)
+	  AND media.deleted_at IS NULL`, containerColumns)
+
+// GetMedias fetches the movies and episodes with the given IDs using a single query, populating
+// episode containers with their season and series. The containers are returned in the same
+// order as the IDs provided, with any IDs which do not match a (non-trashed) movie or episode omitted.
+func (store *Store) GetMedias(db database.Queryable, mediaIDs []uuid.UUID) ([]*Container, error) {
+	if len(mediaIDs) == 0 {
+		return []*Container{}, nil
+	}
+
+	query, args, err := sqlx.In(mediaContainerQuery, mediaIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct media query for %v: %w", mediaIDs, err)
+	}
+
+	var rows []*containerRow
+	if err := db.Select(&rows, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to query media %v: %w", mediaIDs, err)
+	}
+
+	containers := make(map[uuid.UUID]*Container, len(rows))
+	for _, row := range rows {
+		containers[row.RequestedID] = row.mediaContainer()
+	}
+
+	output := make([]*Container, 0, len(containers))
+	for _, id := range mediaIDs {
+		if container, ok := containers[id]; ok {
+			output = append(output, container)
+		}
+	}
+
+	return output, nil
+}
+
+// GetContainers fetches the movies, episodes and series with the given IDs using a single query. The
+// containers are returned in the same order as the IDs provided, with any IDs which do not match a
+// (non-trashed) movie, episode or series omitted.
+//
+// Episode containers are populated with their season and series, and series containers
+// are populated with all of their (non-trashed) seasons and episodes.
+func (store *Store) GetContainers(db database.Queryable, ids []uuid.UUID) ([]*Container, error) {
+	if len(ids) == 0 {
+		return []*Container{}, nil
+	}
+
+	query, args, err := sqlx.In(fmt.Sprintf(`
+		%s
+
+		UNION ALL
+
+		SELECT series.id AS requested_id, %s FROM series
+		LEFT JOIN season
+		  ON season.series_id = series.id
+		 AND season.deleted_at IS NULL
+		LEFT JOIN media
+		  ON media.season_id = season.id
+		 AND media.deleted_at IS NULL
+		WHERE series.id IN (?)
+		  AND series.deleted_at IS NULL
+
+		ORDER BY season_season_number, media_episode_number`, mediaContainerQuery, containerColumns),
+		ids, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct container query for %v: %w", ids, err)
+	}
+
+	var rows []*containerRow
+	if err := db.Select(&rows, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to query containers for %v: %w", ids, err)
+	}
+
+	containers := make(map[uuid.UUID]*Container, len(ids))
+	seasons := make(map[uuid.UUID]*InflatedSeason)
+	for _, row := range rows {
+		if row.MediaID != nil && *row.MediaID == row.RequestedID {
+			containers[row.RequestedID] = row.mediaContainer()
+			continue
+		}
+
+		// Row belongs to a requested series, which may have many rows (one per episode)
+		container, ok := containers[row.RequestedID]
+		if !ok {
+			container = &Container{Type: SeriesContainerType, Series: row.series(), Seasons: []*InflatedSeason{}, Tags: row.Tags, OutputDirectory: row.OutputDirectory}
+			containers[row.RequestedID] = container
+		}
+		if row.SeasonID == nil {
+			continue
+		}
+
+		season, ok := seasons[*row.SeasonID]
+		if !ok {
+			season = &InflatedSeason{Season: row.season(), Episodes: []*Episode{}}
+			seasons[*row.SeasonID] = season
+			container.Seasons = append(container.Seasons, season)
+		}
+		if row.MediaID != nil {
+			season.Episodes = append(season.Episodes, row.episode())
+		}
+	}
+
+	output := make([]*Container, 0, len(containers))
+	for _, id := range ids {
+		if container, ok := containers[id]; ok {
+			output = append(output, container)
+		}
+	}
+
+	return output, nil
+}
+
+func (row *containerRow) mediaContainer() *Container {
+	if *row.MediaType == "movie" {
+		return &Container{Type: MovieContainerType, Movie: &Movie{Model: row.mediaModel(), Watchable: row.watchable()}, Tags: row.Tags, OutputDirectory: row.OutputDirectory}
+	}
+
+	return &Container{Type: EpisodeContainerType, Episode: row.episode(), Season: row.season(), Series: row.series(), Tags: row.Tags, OutputDirectory: row.OutputDirectory}
+}
+
+func (row *containerRow) mediaModel() Model {
+	return Model{ID: *row.MediaID, TmdbID: *row.MediaTmdbID, Title: *row.MediaTitle, CreatedAt: *row.MediaCreatedAt, UpdatedAt: *row.MediaUpdatedAt, LibraryID: row.MediaLibraryID}
+}
+
+func (row *containerRow) watchable() Watchable {
+	return Watchable{
+		MediaResolution: MediaResolution{Width: *row.MediaFrameWidth, Height: *row.MediaFrameHeight},
+		SourcePath:      *row.MediaSourcePath,
+		SourceSize:      *row.MediaSourceSize,
+		VideoCodec:      *row.MediaVideoCodec,
+		Adult:           *row.MediaAdult,
+		DegradedAt:      row.MediaDegradedAt,
+		Checksum:        row.MediaChecksum,
+		CorruptedAt:     row.MediaCorruptedAt,
+	}
+}
+
+func (row *containerRow) episode() *Episode {
+	return &Episode{Model: row.mediaModel(), Watchable: row.watchable(), SeasonID: *row.SeasonID, EpisodeNumber: *row.MediaEpisodeNumber}
+}
+
+func (row *containerRow) season() *Season {
+	return &Season{
+		Model:        Model{ID: *row.SeasonID, TmdbID: *row.SeasonTmdbID, Title: *row.SeasonTitle, CreatedAt: *row.SeasonCreatedAt, UpdatedAt: *row.SeasonUpdatedAt},
+		SeasonNumber: *row.SeasonNumber,
+		SeriesID:     *row.SeriesID,
+	}
+}
+
+func (row *containerRow) series() *Series {
+	return &Series{Model: Model{ID: *row.SeriesID, TmdbID: *row.SeriesTmdbID, Title: *row.SeriesTitle, CreatedAt: *row.SeriesCreatedAt, UpdatedAt: *row.SeriesUpdatedAt, LibraryID: row.SeriesLibraryID}}
+}
@@ -0,0 +1,135 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/lib/pq"
+)
+
+var ErrDeletionFilterEmpty = errors.New("at least one deletion criteria must be provided")
+
+type (
+	// DeletionFilter selects the movies and episodes to be bulk deleted. Media must satisfy
+	// all of the criteria provided, and at least one criteria must be provided so that
+	// the entire library cannot be deleted by accident.
+	DeletionFilter struct {
+		// GenreIDs matches movies (or episodes of series) with any of the genres provided.
+		GenreIDs []int
+
+		// CreatedBefore matches media which was first ingested before the time provided.
+		CreatedBefore *time.Time
+
+		// MaxFrameHeight matches media whose source has a vertical resolution of at
+		// most the value provided (e.g. 720 matches SD and 720p sources).
+		MaxFrameHeight *int
+	}
+
+	// DeletionCandidate is a movie or episode matched by a DeletionFilter, along with
+	// the sizes of the source files which would be removed alongside it.
+	DeletionCandidate struct {
+		ID            uuid.UUID      `db:"id"`
+		Type          string         `db:"type"`
+		Title         string         `db:"title"`
+		SeriesTitle   *string        `db:"series_title"`
+		SeasonNumber  *int           `db:"season_number"`
+		EpisodeNumber *int           `db:"episode_number"`
+		CreatedAt     time.Time      `db:"created_at"`
+		FrameHeight   int            `db:"frame_height"`
+		SourcePath    string         `db:"source_path"`
+		SourceSize    int64          `db:"source_size"`
+		VersionPaths  pq.StringArray `db:"version_paths"`
+		VersionSize   int64          `db:"version_size"`
+	}
+)
+
+func (filter *DeletionFilter) IsEmpty() bool {
+	return len(filter.GenreIDs) == 0 && filter.CreatedBefore == nil && filter.MaxFrameHeight == nil
+}
+
+// ListDeletionCandidates returns the (non-trashed) movies and episodes which match the filter
+// provided, oldest first. If the filter is empty, ErrDeletionFilterEmpty is returned.
+func (store *Store) ListDeletionCandidates(db database.Queryable, filter DeletionFilter) ([]*DeletionCandidate, error) {
+	if filter.IsEmpty() {
+		return nil, ErrDeletionFilterEmpty
+	}
+
+	q := sq.Select(
+		"media.id", "CAST(media.type AS TEXT) AS type", "media.title", "series.title AS series_title",
+		"season.season_number", "media.episode_number", "media.created_at", "media.frame_height",
+		"media.source_path", "media.source_size",
+		"ARRAY(SELECT v.source_path FROM media_versions v WHERE v.media_id = media.id) AS version_paths",
+		"(SELECT COALESCE(SUM(v.source_size), 0) FROM media_versions v WHERE v.media_id = media.id) AS version_size",
+	).
+		From("media").
+		LeftJoin("season ON season.id = media.season_id").
+		LeftJoin("series ON series.id = season.series_id").
+		Where("media.deleted_at IS NULL AND season.deleted_at IS NULL AND series.deleted_at IS NULL").
+		OrderBy("media.created_at").
+		PlaceholderFormat(sq.Dollar)
+
+	if len(filter.GenreIDs) > 0 {
+		q = q.Where(`(
+			EXISTS(SELECT 1 FROM movie_genres mg WHERE mg.movie_id = media.id AND mg.genre_id = ANY(?))
+			OR EXISTS(SELECT 1 FROM series_genres sg WHERE sg.series_id = series.id AND sg.genre_id = ANY(?))
+		)`, pq.Array(filter.GenreIDs), pq.Array(filter.GenreIDs))
+	}
+	if filter.CreatedBefore != nil {
+		q = q.Where(sq.Lt{"media.created_at": *filter.CreatedBefore})
+	}
+	if filter.MaxFrameHeight != nil {
+		q = q.Where(sq.LtOrEq{"media.frame_height": *filter.MaxFrameHeight})
+	}
+
+	query, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build deletion candidate query: %w", err)
+	}
+
+	var dest []*DeletionCandidate
+	if err := db.Select(&dest, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list deletion candidates: %w", err)
+	}
+
+	return dest, nil
+}
+
+// PurgeMedia permanently deletes the movies/episodes with the given IDs, regardless of whether
+// they're in the trash. Seasons and series which no longer contain any episodes as a result
+// are also deleted.
+//
+// NB: As with PurgeTrash, the transcodes of the media must be deleted beforehand.
+func (store *Store) PurgeMedia(db database.Queryable, mediaIDs []uuid.UUID) error {
+	var seasonIDs []uuid.UUID
+	if err := db.Select(&seasonIDs, `
+		WITH purged AS (
+			DELETE FROM media WHERE id = ANY($1::UUID[])
+			RETURNING season_id
+		)
+		SELECT DISTINCT season_id FROM purged WHERE season_id IS NOT NULL`,
+		pq.Array(mediaIDs),
+	); err != nil {
+		return fmt.Errorf("purge of media failed: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		WITH emptied_season AS (
+			DELETE FROM season
+			WHERE id = ANY($1::UUID[])
+			  AND NOT EXISTS(SELECT 1 FROM media WHERE media.season_id = season.id)
+			RETURNING id, series_id
+		)
+		DELETE FROM series
+		WHERE id IN (SELECT series_id FROM emptied_season)
+		  AND NOT EXISTS(SELECT 1 FROM season WHERE season.series_id = series.id AND season.id NOT IN (SELECT id FROM emptied_season))`,
+		pq.Array(seasonIDs),
+	); err != nil {
+		return fmt.Errorf("purge of emptied seasons/series failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package media
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+type (
+	// CatalogEpisode is an episode of a series which is known to TMDB, regardless
+	// of whether the episode is present in the library.
+	CatalogEpisode struct {
+		SeasonNumber  int        `db:"season_number"`
+		EpisodeNumber int        `db:"episode_number"`
+		Title         string     `db:"title"`
+		AirDate       *time.Time `db:"air_date"` // Nullable
+	}
+
+	// WantedEpisode is a missing episode (see GetMissingEpisodes) of a monitored series.
+	WantedEpisode struct {
+		SeriesID      uuid.UUID `db:"series_id"`
+		SeriesTitle   string    `db:"series_title"`
+		SeasonNumber  int       `db:"season_number"`
+		EpisodeNumber int       `db:"episode_number"`
+	}
+)
+
+// missingEpisodeClause matches the episodes in the catalog (aliased as 'c') which are
+// not present (or are trashed) in the library. Specials (season zero) and episodes which
+// have not yet aired are not considered missing.
+const missingEpisodeClause = `c.season_number > 0
+	AND c.air_date <= current_date
+	AND NOT EXISTS (
+		SELECT 1 FROM season
+		INNER JOIN media
+		  ON media.season_id = season.id
+		 AND media.type = 'episode'
+		 AND media.deleted_at IS NULL
+		WHERE season.series_id = c.series_id
+		  AND season.season_number = c.season_number
+		  AND season.deleted_at IS NULL
+		  AND media.episode_number = c.episode_number
+	)`
+
+// SaveEpisodeCatalog replaces the episode catalog of the series with the ID provided.
+func (store *Store) SaveEpisodeCatalog(db database.Queryable, seriesID uuid.UUID, episodes []*CatalogEpisode) error {
+	if _, err := db.Exec(`DELETE FROM series_episode_catalog WHERE series_id=$1`, seriesID); err != nil {
+		return fmt.Errorf("failed to clear episode catalog of series %s: %w", seriesID, err)
+	}
+
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	type catalogRow struct {
+		CatalogEpisode
+		SeriesID uuid.UUID `db:"series_id"`
+	}
+	rows := make([]catalogRow, len(episodes))
+	for k, v := range episodes {
+		rows[k] = catalogRow{*v, seriesID}
+	}
+
+	if _, err := db.NamedExec(`
+		INSERT INTO series_episode_catalog(series_id, season_number, episode_number, title, air_date)
+		VALUES (:series_id, :season_number, :episode_number, :title, :air_date)
+		ON CONFLICT DO NOTHING`,
+		rows,
+	); err != nil {
+		return fmt.Errorf("failed to save episode catalog of series %s: %w", seriesID, err)
+	}
+
+	return nil
+}
+
+// GetMissingEpisodes returns the episodes in the catalog of the series with the ID provided
+// which are not present (or are trashed) in the library, ordered by season and episode number.
+func (store *Store) GetMissingEpisodes(db database.Queryable, seriesID uuid.UUID) ([]*CatalogEpisode, error) {
+	var dest []*CatalogEpisode
+	if err := db.Select(&dest, `
+		SELECT c.season_number, c.episode_number, c.title, c.air_date FROM series_episode_catalog c
+		WHERE c.series_id=$1 AND `+missingEpisodeClause+`
+		ORDER BY c.season_number, c.episode_number`,
+		seriesID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to select missing episodes of series %s: %w", seriesID, err)
+	}
+
+	return dest, nil
+}
+
+// ListWantedEpisodes returns the missing episodes of every monitored (non-trashed) series.
+func (store *Store) ListWantedEpisodes(db database.Queryable) ([]*WantedEpisode, error) {
+	var dest []*WantedEpisode
+	if err := db.Select(&dest, `
+		SELECT c.series_id, series.title AS series_title, c.season_number, c.episode_number FROM series_episode_catalog c
+		INNER JOIN series ON series.id = c.series_id
+		WHERE series.monitored AND series.deleted_at IS NULL AND `+missingEpisodeClause+`
+		ORDER BY c.series_id, c.season_number, c.episode_number`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to select wanted episodes: %w", err)
+	}
+
+	return dest, nil
+}
+
+// SetSeriesMonitored marks the series with the ID provided as monitored (or not). If the
+// series does not exist (or is trashed), sql.ErrNoRows is returned.
+func (store *Store) SetSeriesMonitored(db database.Queryable, seriesID uuid.UUID, monitored bool) error {
+	res, err := db.Exec(`UPDATE series SET monitored=$2, updated_at=current_timestamp WHERE id=$1 AND deleted_at IS NULL`, seriesID, monitored)
+	if err != nil {
+		return fmt.Errorf("failed to set monitored state of series %s: %w", seriesID, err)
+	}
+
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -42,7 +42,7 @@ func (store *mediaGenreStore) SaveMovieGenreAssociations(db database.Queryable,
 	}
 
 	_, err := db.Exec(`
-		DELETE FROM movie_genres WHERE media_id=$1`, movieID)
+		DELETE FROM movie_genres WHERE movie_id=$1`, movieID)
 	return err
 }
 
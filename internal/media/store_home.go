@@ -0,0 +1,160 @@
+package media
+
+import (
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/lib/pq"
+)
+
+type (
+	// HomeFeed is the personalised overview of the library shown to a user when they open Thea.
+	HomeFeed struct {
+		// RecentlyAdded contains the movies and episodes most recently added to the library.
+		RecentlyAdded []*HomeFeedItem
+
+		// ContinueWatching contains the movies and episodes the user has started, but not completed,
+		// most recently watched first.
+		ContinueWatching []*HomeFeedItem
+
+		// NextUp contains the next episode the user has not completed of each series they're
+		// watching (the episode after the one they most recently completed), most recently
+		// watched series first.
+		NextUp []*HomeFeedItem
+
+		// RecentTranscodes contains the movies and episodes which most recently completed
+		// transcoding, with the target they were transcoded to. Only populated if requested.
+		RecentTranscodes []*HomeFeedItem
+	}
+
+	// HomeFeedItem is a movie or episode in the home feed. The series, season number and episode
+	// number are only populated for episodes. The progress of the user is included if they've
+	// started watching the media, and the target and transcode time is only populated for the
+	// RecentTranscodes of the feed.
+	HomeFeedItem struct {
+		MediaID         uuid.UUID  `db:"media_id"`
+		Type            string     `db:"type"`
+		Title           string     `db:"title"`
+		CreatedAt       time.Time  `db:"created_at"`
+		SeriesID        *uuid.UUID `db:"series_id"`
+		SeriesTitle     *string    `db:"series_title"`
+		SeasonNumber    *int       `db:"season_number"`
+		EpisodeNumber   *int       `db:"episode_number"`
+		PositionSeconds *int       `db:"position_seconds"`
+		Completed       *bool      `db:"completed"`
+		WatchedAt       *time.Time `db:"watched_at"`
+		TargetID        *uuid.UUID `db:"target_id"`
+		TranscodedAt    *time.Time `db:"transcoded_at"`
+	}
+)
+
+// GetHomeFeed builds the home feed of the user provided, limiting each section of the feed to the
+// number of items given. Only media within the libraries permitted by the library filter is included
+// (nil permits all media). The recent transcodes are only included if includeTranscodes is true.
+func (store *Store) GetHomeFeed(db database.Queryable, userID uuid.UUID, libraryFilter *LibraryFilter, includeTranscodes bool, limit int) (*HomeFeed, error) {
+	feed := &HomeFeed{}
+
+	recent := homeFeedItemQuery(userID, libraryFilter).OrderBy("media.created_at DESC").Limit(uint64(limit))
+	if err := selectHomeFeedItems(db, recent, &feed.RecentlyAdded); err != nil {
+		return nil, fmt.Errorf("failed to select recently added media: %w", err)
+	}
+
+	continueWatching := homeFeedItemQuery(userID, libraryFilter).
+		Where("wp.completed = false AND wp.position_seconds > 0").
+		OrderBy("wp.updated_at DESC").
+		Limit(uint64(limit))
+	if err := selectHomeFeedItems(db, continueWatching, &feed.ContinueWatching); err != nil {
+		return nil, fmt.Errorf("failed to select partially watched media: %w", err)
+	}
+
+	// For each series, find the episode the user most recently completed and select the first
+	// episode after it (by season and episode number) which the user has not completed
+	nextUp := homeFeedItemQuery(userID, libraryFilter).
+		Prefix(`
+			WITH last_completed AS (
+				SELECT DISTINCT ON (season.series_id) season.series_id, season.season_number, media.episode_number, wp.updated_at
+				FROM watch_progress wp
+				INNER JOIN media ON media.id = wp.media_id
+				INNER JOIN season ON season.id = media.season_id
+				WHERE wp.user_id = ? AND wp.completed AND media.deleted_at IS NULL
+				ORDER BY season.series_id, wp.updated_at DESC
+			)`, userID).
+		Where(`media.id IN (
+			SELECT next.id FROM last_completed lc
+			CROSS JOIN LATERAL (
+				SELECT m.id FROM media m
+				INNER JOIN season s ON s.id = m.season_id
+				LEFT JOIN watch_progress p ON p.media_id = m.id AND p.user_id = ?
+				WHERE s.series_id = lc.series_id AND m.deleted_at IS NULL AND s.deleted_at IS NULL
+					AND (s.season_number, m.episode_number) > (lc.season_number, lc.episode_number)
+					AND p.completed IS NOT TRUE
+				ORDER BY s.season_number, m.episode_number
+				LIMIT 1
+			) next
+		)`, userID).
+		OrderBy("(SELECT lc.updated_at FROM last_completed lc WHERE lc.series_id = series.id) DESC").
+		Limit(uint64(limit))
+	if err := selectHomeFeedItems(db, nextUp, &feed.NextUp); err != nil {
+		return nil, fmt.Errorf("failed to select next episodes: %w", err)
+	}
+
+	if !includeTranscodes {
+		feed.RecentTranscodes = []*HomeFeedItem{}
+		return feed, nil
+	}
+
+	// Only the transcodes which still exist are included, as the outcome of a task outlives it's transcode
+	transcodes := homeFeedItemQuery(userID, libraryFilter).
+		Columns("outcome.transcode_target_id AS target_id", "outcome.concluded_at AS transcoded_at").
+		InnerJoin("transcode_outcome outcome ON outcome.media_id = media.id AND outcome.status = 'COMPLETE'").
+		Where(`EXISTS (
+			SELECT 1 FROM media_transcodes mt
+			WHERE mt.media_id = outcome.media_id AND mt.transcode_target_id = outcome.transcode_target_id
+		)`).
+		OrderBy("outcome.concluded_at DESC").
+		Limit(uint64(limit))
+	if err := selectHomeFeedItems(db, transcodes, &feed.RecentTranscodes); err != nil {
+		return nil, fmt.Errorf("failed to select recent transcodes: %w", err)
+	}
+
+	return feed, nil
+}
+
+// homeFeedItemQuery returns a query selecting the (non-trashed) movies and episodes within the libraries
+// permitted by the filter provided, along with the series of each episode, and the progress of the user.
+func homeFeedItemQuery(userID uuid.UUID, libraryFilter *LibraryFilter) sq.SelectBuilder {
+	q := sq.Select(
+		"media.id AS media_id", "media.type", "media.title", "media.created_at",
+		"series.id AS series_id", "series.title AS series_title", "season.season_number", "media.episode_number",
+		"wp.position_seconds", "wp.completed", "wp.updated_at AS watched_at",
+	).
+		From("media").
+		LeftJoin("season ON season.id = media.season_id").
+		LeftJoin("series ON series.id = season.series_id").
+		LeftJoin("watch_progress wp ON wp.media_id = media.id AND wp.user_id = ?", userID).
+		Where("media.deleted_at IS NULL AND season.deleted_at IS NULL AND series.deleted_at IS NULL")
+
+	if libraryFilter != nil {
+		libraryIDs := make(pq.StringArray, len(libraryFilter.IDs))
+		for k, v := range libraryFilter.IDs {
+			libraryIDs[k] = v.String()
+		}
+
+		q = q.Where(`(media.library_id IS NULL OR media.library_id = ANY(CAST(? AS uuid[])))`, libraryIDs)
+	}
+
+	return q
+}
+
+func selectHomeFeedItems(db database.Queryable, q sq.SelectBuilder, dest *[]*HomeFeedItem) error {
+	query, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	*dest = make([]*HomeFeedItem, 0)
+	return db.Select(dest, db.Rebind(query), args...)
+}
@@ -0,0 +1,264 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/lib/pq"
+)
+
+// MetadataField is a field of a movie, episode, season or series which can be manually
+// corrected, and locked so that it is not overwritten when the item is re-ingested.
+type MetadataField string
+
+const (
+	TitleField         MetadataField = "title"
+	OverviewField      MetadataField = "overview"
+	AdultField         MetadataField = "adult"
+	GenresField        MetadataField = "genres"
+	SeasonNumberField  MetadataField = "season_number"
+	EpisodeNumberField MetadataField = "episode_number"
+)
+
+type MetadataItemType string
+
+const (
+	MetadataMovie   MetadataItemType = "movie"
+	MetadataEpisode MetadataItemType = "episode"
+	MetadataSeason  MetadataItemType = "season"
+	MetadataSeries  MetadataItemType = "series"
+)
+
+var (
+	ErrFieldNotLockable = errors.New("field cannot be locked for this type of media")
+	ErrSiblingConflict  = errors.New("another episode/season with the same number already exists")
+
+	// lockableFields are the metadata fields supported by each type of item.
+	lockableFields = map[MetadataItemType][]MetadataField{
+		MetadataMovie:   {TitleField, OverviewField, AdultField, GenresField},
+		MetadataEpisode: {TitleField, OverviewField, AdultField, EpisodeNumberField},
+		MetadataSeason:  {TitleField, OverviewField, SeasonNumberField},
+		MetadataSeries:  {TitleField, OverviewField, GenresField},
+	}
+
+	metadataTables = map[MetadataItemType]string{
+		MetadataMovie:   MediaTable,
+		MetadataEpisode: MediaTable,
+		MetadataSeason:  SeasonTable,
+		MetadataSeries:  SeriesTable,
+	}
+)
+
+type (
+	// MetadataItem is the metadata of a (non-trashed) movie, episode, season or series, along
+	// with the fields of the metadata which are locked.
+	MetadataItem struct {
+		ID           uuid.UUID        `db:"id"`
+		Type         MetadataItemType `db:"type"`
+		Title        string           `db:"title"`
+		Overview     string           `db:"overview"`
+		LockedFields pq.StringArray   `db:"locked_fields"`
+	}
+
+	// MetadataUpdate contains the manual corrections to the metadata of an item. Nil
+	// fields are left unchanged. All corrected fields are locked.
+	MetadataUpdate struct {
+		Title         *string
+		Overview      *string
+		Adult         *bool
+		Genres        []string
+		SeasonNumber  *int
+		EpisodeNumber *int
+	}
+
+	mediaMetadataStore struct{}
+)
+
+// IsLocked returns true if the given metadata field of this model is locked.
+func (model *Model) IsLocked(field MetadataField) bool {
+	return slices.Contains(model.LockedFields, string(field))
+}
+
+// Fields returns the metadata fields which are corrected by this update.
+func (update MetadataUpdate) Fields() []MetadataField {
+	fields := make([]MetadataField, 0, 6)
+	if update.Title != nil {
+		fields = append(fields, TitleField)
+	}
+	if update.Overview != nil {
+		fields = append(fields, OverviewField)
+	}
+	if update.Adult != nil {
+		fields = append(fields, AdultField)
+	}
+	if update.Genres != nil {
+		fields = append(fields, GenresField)
+	}
+	if update.SeasonNumber != nil {
+		fields = append(fields, SeasonNumberField)
+	}
+	if update.EpisodeNumber != nil {
+		fields = append(fields, EpisodeNumberField)
+	}
+
+	return fields
+}
+
+// GetMetadataItem returns the metadata of the movie, episode, season or series with
+// the given ID. If no (non-trashed) item exists, sql.ErrNoRows is returned.
+func (store *mediaMetadataStore) GetMetadataItem(db database.Queryable, id uuid.UUID) (*MetadataItem, error) {
+	var dest MetadataItem
+	if err := db.Get(&dest, `
+		SELECT id, type, title, overview, locked_fields FROM media WHERE id=$1 AND deleted_at IS NULL
+		UNION ALL
+		SELECT id, 'season', title, overview, locked_fields FROM season WHERE id=$1 AND deleted_at IS NULL
+		UNION ALL
+		SELECT id, 'series', title, overview, locked_fields FROM series WHERE id=$1 AND deleted_at IS NULL`,
+		id,
+	); err != nil {
+		return nil, fmt.Errorf("failed to get metadata of %s: %w", id, err)
+	}
+
+	return &dest, nil
+}
+
+// SetLockedFields replaces the locked fields of the item provided. If any of the
+// fields are not supported by the type of the item, ErrFieldNotLockable is returned.
+func (store *mediaMetadataStore) SetLockedFields(db database.Queryable, item *MetadataItem, fields []MetadataField) error {
+	if err := validateLockableFields(item.Type, fields); err != nil {
+		return err
+	}
+
+	locked := make(pq.StringArray, 0, len(fields))
+	for _, field := range fields {
+		if !slices.Contains(locked, string(field)) {
+			locked = append(locked, string(field))
+		}
+	}
+
+	if _, err := db.Exec(
+		fmt.Sprintf(`UPDATE %s SET locked_fields=$2, updated_at=current_timestamp WHERE id=$1`, metadataTables[item.Type]),
+		item.ID, locked,
+	); err != nil {
+		return fmt.Errorf("failed to set locked fields of %s: %w", item.ID, err)
+	}
+
+	item.LockedFields = locked
+	return nil
+}
+
+// UpdateMetadata applies the corrections of the update to the item provided, and locks all the
+// fields corrected by the update. The genre associations of the item are NOT updated (see
+// SaveMovieGenreAssociations and SaveSeriesGenreAssociations). If any of the corrected fields
+// are not supported by the type of the item, ErrFieldNotLockable is returned. If a corrected
+// season/episode number is already used by another season of the series (or episode of the
+// season), ErrSiblingConflict is returned.
+func (store *mediaMetadataStore) UpdateMetadata(db database.Queryable, item *MetadataItem, update MetadataUpdate) error {
+	fields := update.Fields()
+	if err := validateLockableFields(item.Type, fields); err != nil {
+		return err
+	}
+
+	if update.SeasonNumber != nil {
+		if err := checkSiblingConflict(db, SeasonTable, "series_id", "season_number", item.ID, *update.SeasonNumber); err != nil {
+			return err
+		}
+	}
+	if update.EpisodeNumber != nil {
+		if err := checkSiblingConflict(db, MediaTable, "season_id", "episode_number", item.ID, *update.EpisodeNumber); err != nil {
+			return err
+		}
+	}
+
+	locked := slices.Clone(item.LockedFields)
+	for _, field := range fields {
+		if !slices.Contains(locked, string(field)) {
+			locked = append(locked, string(field))
+		}
+	}
+
+	q := sq.Update(metadataTables[item.Type]).
+		Set("locked_fields", locked).
+		Set("updated_at", sq.Expr("current_timestamp")).
+		Where(sq.Eq{"id": item.ID}).
+		PlaceholderFormat(sq.Dollar)
+	if update.Title != nil {
+		q = q.Set("title", *update.Title)
+	}
+	if update.Overview != nil {
+		q = q.Set("overview", *update.Overview)
+	}
+	if update.Adult != nil {
+		q = q.Set("adult", *update.Adult)
+	}
+	if update.SeasonNumber != nil {
+		q = q.Set("season_number", *update.SeasonNumber)
+	}
+	if update.EpisodeNumber != nil {
+		q = q.Set("episode_number", *update.EpisodeNumber)
+	}
+
+	query, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to construct metadata update query: %w", err)
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to update metadata of %s: %w", item.ID, err)
+	}
+
+	if update.Title != nil {
+		item.Title = *update.Title
+	}
+	if update.Overview != nil {
+		item.Overview = *update.Overview
+	}
+	item.LockedFields = locked
+	return nil
+}
+
+func validateLockableFields(itemType MetadataItemType, fields []MetadataField) error {
+	for _, field := range fields {
+		if !slices.Contains(lockableFields[itemType], field) {
+			return fmt.Errorf("%w: '%s' of %s", ErrFieldNotLockable, field, itemType)
+		}
+	}
+
+	return nil
+}
+
+// checkSiblingConflict returns ErrSiblingConflict if another (non-trashed) row of the table, which
+// shares the same parent as the row with the given ID, already uses the number provided.
+func checkSiblingConflict(db database.Queryable, table string, parentCol string, numberCol string, id uuid.UUID, number int) error {
+	var conflict bool
+	if err := db.Get(&conflict, fmt.Sprintf(`
+		SELECT EXISTS(
+			SELECT 1 FROM %[1]s sibling
+			INNER JOIN %[1]s target
+			  ON target.%[2]s = sibling.%[2]s
+			WHERE target.id=$1
+			  AND sibling.id<>$1
+			  AND sibling.%[3]s=$2
+			  AND sibling.deleted_at IS NULL
+		)`, table, parentCol, numberCol),
+		id, number,
+	); err != nil {
+		return fmt.Errorf("failed to check for conflicting %s: %w", numberCol, err)
+	}
+
+	if conflict {
+		return fmt.Errorf("%w: %s %d", ErrSiblingConflict, numberCol, number)
+	}
+
+	return nil
+}
+
+// lockedColumn returns an SQL expression for use in the 'DO UPDATE' clause of an upsert on the
+// table provided, which retains the existing value of the column for the given field if the field
+// is locked, and otherwise takes the EXCLUDED value. The column must be named after the field.
+func lockedColumn(table string, field MetadataField) string {
+	return fmt.Sprintf(`CASE WHEN '%[2]s' = ANY(%[1]s.locked_fields) THEN %[1]s.%[2]s ELSE EXCLUDED.%[2]s END`, table, field)
+}
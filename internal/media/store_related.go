@@ -0,0 +1,130 @@
+package media
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/lib/pq"
+)
+
+type (
+	// Relation describes why TMDB considers content to be related to a movie/series.
+	Relation string
+
+	// TmdbRelations are the TMDB IDs of the content TMDB recommends alongside a movie/series,
+	// and the content it considers similar, each ordered by relevance. The related content
+	// is always of the same type as the movie/series (i.e. movies are only related to movies).
+	TmdbRelations struct {
+		Recommended []string
+		Similar     []string
+	}
+
+	// RelatedItem is a movie/series in the library which TMDB considers related to another.
+	RelatedItem struct {
+		ID       uuid.UUID     `db:"id"`
+		Type     MediaListType `db:"type"`
+		TmdbID   string        `db:"tmdb_id"`
+		Title    string        `db:"title"`
+		Relation Relation      `db:"relation"`
+	}
+)
+
+const (
+	RecommendedRelation Relation = "recommended"
+	SimilarRelation     Relation = "similar"
+)
+
+// SaveMovieRelations replaces the related TMDB IDs of the movie with the ID provided.
+func (store *Store) SaveMovieRelations(db database.Queryable, movieID uuid.UUID, relations *TmdbRelations) error {
+	return saveRelations(db, "movie_related", "movie_id", movieID, relations)
+}
+
+// SaveSeriesRelations replaces the related TMDB IDs of the series with the ID provided.
+func (store *Store) SaveSeriesRelations(db database.Queryable, seriesID uuid.UUID, relations *TmdbRelations) error {
+	return saveRelations(db, "series_related", "series_id", seriesID, relations)
+}
+
+// GetRelatedMovies returns the (non-trashed) movies in the library which TMDB considers related
+// to the movie with the ID provided, most relevant first. Only movies within the libraries
+// permitted by the library filter are included (nil permits all movies).
+func (store *Store) GetRelatedMovies(db database.Queryable, movieID uuid.UUID, libraryFilter *LibraryFilter) ([]*RelatedItem, error) {
+	q := sq.Select("DISTINCT ON (media.id) media.id", "'movie' AS type", "media.tmdb_id", "media.title", "r.relation", "r.rank").
+		From("movie_related r").
+		InnerJoin("media ON media.tmdb_id = r.related_tmdb_id AND media.type = 'movie'").
+		Where("r.movie_id = ? AND media.id <> r.movie_id AND media.deleted_at IS NULL", movieID)
+
+	return selectRelatedItems(db, withRelatedLibraryFilter(q, "media", libraryFilter), "media.id")
+}
+
+// GetRelatedSeries returns the (non-trashed) series in the library which TMDB considers related
+// to the series with the ID provided, most relevant first. Only series within the libraries
+// permitted by the library filter are included (nil permits all series).
+func (store *Store) GetRelatedSeries(db database.Queryable, seriesID uuid.UUID, libraryFilter *LibraryFilter) ([]*RelatedItem, error) {
+	q := sq.Select("DISTINCT ON (series.id) series.id", "'series' AS type", "series.tmdb_id", "series.title", "r.relation", "r.rank").
+		From("series_related r").
+		InnerJoin("series ON series.tmdb_id = r.related_tmdb_id").
+		Where("r.series_id = ? AND series.id <> r.series_id AND series.deleted_at IS NULL", seriesID)
+
+	return selectRelatedItems(db, withRelatedLibraryFilter(q, "series", libraryFilter), "series.id")
+}
+
+func saveRelations(db database.Queryable, table string, column string, id uuid.UUID, relations *TmdbRelations) error {
+	if _, err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s=$1`, table, column), id); err != nil {
+		return fmt.Errorf("failed to clear related content of %s: %w", id, err)
+	}
+
+	q := sq.Insert(table).Columns(column, "related_tmdb_id", "relation", "rank").Suffix("ON CONFLICT DO NOTHING")
+	rows := 0
+	for relation, tmdbIDs := range map[Relation][]string{RecommendedRelation: relations.Recommended, SimilarRelation: relations.Similar} {
+		for rank, tmdbID := range tmdbIDs {
+			q = q.Values(id, tmdbID, relation, rank)
+			rows++
+		}
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	query, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	if _, err := db.Exec(db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("failed to save related content of %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func withRelatedLibraryFilter(q sq.SelectBuilder, table string, libraryFilter *LibraryFilter) sq.SelectBuilder {
+	if libraryFilter == nil {
+		return q
+	}
+
+	libraryIDs := make(pq.StringArray, len(libraryFilter.IDs))
+	for k, v := range libraryFilter.IDs {
+		libraryIDs[k] = v.String()
+	}
+
+	return q.Where(fmt.Sprintf(`(%[1]s.library_id IS NULL OR %[1]s.library_id = ANY(CAST(? AS uuid[])))`, table), libraryIDs)
+}
+
+// selectRelatedItems selects the related items using the query provided, which must select
+// DISTINCT ON the ID column given. Items which are both recommended and similar are
+// only included once (as a recommendation), and items are ordered by their rank.
+func selectRelatedItems(db database.Queryable, q sq.SelectBuilder, idColumn string) ([]*RelatedItem, error) {
+	distinct, args, err := q.OrderBy(idColumn, "r.relation", "r.rank").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	dest := make([]*RelatedItem, 0)
+	query := fmt.Sprintf(`SELECT id, type, tmdb_id, title, relation FROM (%s) related ORDER BY rank, relation`, distinct)
+	if err := db.Select(&dest, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to select related content: %w", err)
+	}
+
+	return dest, nil
+}
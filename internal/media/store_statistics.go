@@ -0,0 +1,54 @@
+package media
+
+import (
+	"fmt"
+
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+type (
+	// Statistics contains aggregated information about the (non-trashed) media in the library.
+	Statistics struct {
+		MovieCount   int   `db:"movie_count"`
+		SeriesCount  int   `db:"series_count"`
+		EpisodeCount int   `db:"episode_count"`
+		SourceBytes  int64 `db:"source_bytes"`
+		Codecs       []*CodecCount
+	}
+
+	// CodecCount is the number of media whose source uses the given video codec. Media
+	// for which the codec was not recorded during ingestion are reported as 'unknown'.
+	CodecCount struct {
+		Codec string `db:"codec"`
+		Count int    `db:"count"`
+	}
+)
+
+// GetStatistics aggregates the totals, source disk usage and codec distribution of all
+// non-trashed media in the library.
+func (store *Store) GetStatistics(db database.Queryable) (*Statistics, error) {
+	dest := &Statistics{}
+	if err := db.Get(dest, `
+		SELECT
+			COUNT(*) FILTER (WHERE type = 'movie') AS movie_count,
+			COUNT(*) FILTER (WHERE type = 'episode') AS episode_count,
+			COALESCE(SUM(source_size), 0) AS source_bytes,
+			(SELECT COUNT(*) FROM series WHERE deleted_at IS NULL) AS series_count
+		FROM media
+		WHERE deleted_at IS NULL`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to aggregate media totals: %w", err)
+	}
+
+	if err := db.Select(&dest.Codecs, `
+		SELECT COALESCE(NULLIF(video_codec, ''), 'unknown') AS codec, COUNT(*) AS count
+		FROM media
+		WHERE deleted_at IS NULL
+		GROUP BY codec
+		ORDER BY count DESC, codec`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to aggregate media codecs: %w", err)
+	}
+
+	return dest, nil
+}
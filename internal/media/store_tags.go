@@ -0,0 +1,192 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/lib/pq"
+)
+
+type (
+	// Tag is a user-defined label (e.g. 'kids' or 'anime') which can be attached to
+	// movies and series. Unlike genres, tags are never populated from TMDB.
+	Tag struct {
+		ID        uuid.UUID `db:"id"`
+		Label     string    `db:"label"`
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	// TaggedItem is a single movie or series which a tag is attached to.
+	TaggedItem struct {
+		ID    uuid.UUID `db:"id"`
+		Type  string    `db:"type"`
+		Title string    `db:"title"`
+	}
+
+	// InflatedTag is a tag along with the movies and series it's attached to.
+	InflatedTag struct {
+		*Tag
+		Items []*TaggedItem
+	}
+
+	// TagFilter filters media by the tags attached to them. If MatchAll is true, media
+	// must have ALL of the tags attached, otherwise ANY of the tags is sufficient.
+	TagFilter struct {
+		IDs      []uuid.UUID
+		MatchAll bool
+	}
+
+	mediaTagStore struct{}
+)
+
+const TagTable = "tag"
+
+var (
+	ErrTagItemNotFound  = errors.New("one or more of the items to tag is not a known movie or series")
+	ErrTagLabelConflict = errors.New("a tag with the same label already exists")
+)
+
+// CreateTag inserts the tag provided. The timestamps of the
+// tag are set to match the inserted row.
+func (store *mediaTagStore) CreateTag(db database.Queryable, tag *Tag) error {
+	if err := db.QueryRowx(`
+		INSERT INTO tag(id, label, created_at, updated_at)
+		VALUES ($1, $2, current_timestamp, current_timestamp)
+		RETURNING *`,
+		tag.ID, tag.Label,
+	).StructScan(tag); err != nil {
+		return fmt.Errorf("failed to create tag: %w", wrapTagLabelError(err))
+	}
+
+	return nil
+}
+
+// UpdateTag updates the label of the tag with the ID provided. A nil
+// label is left unchanged.
+func (store *mediaTagStore) UpdateTag(db database.Queryable, tagID uuid.UUID, label *string) (*Tag, error) {
+	var dest Tag
+	if err := db.Get(&dest, `
+		UPDATE tag
+		SET label=COALESCE($2, label), updated_at=current_timestamp
+		WHERE id=$1
+		RETURNING *`,
+		tagID, label,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update tag %s: %w", tagID, wrapTagLabelError(err))
+	}
+
+	return &dest, nil
+}
+
+func (store *mediaTagStore) GetTag(db database.Queryable, tagID uuid.UUID) (*Tag, error) {
+	return queryRow[Tag](db, TagTable, IDCol, tagID, "")
+}
+
+// ListTags returns all tags, ordered by their label.
+func (store *mediaTagStore) ListTags(db database.Queryable) ([]*Tag, error) {
+	var dest []*Tag
+	if err := db.Select(&dest, `SELECT * FROM tag ORDER BY label, id`); err != nil {
+		return nil, fmt.Errorf("failed to select all tags: %w", err)
+	}
+
+	return dest, nil
+}
+
+// DeleteTag deletes the tag with the ID provided, detaching it
+// from all movies and series.
+func (store *mediaTagStore) DeleteTag(db database.Queryable, tagID uuid.UUID) error {
+	var id uuid.UUID
+	if err := db.Get(&id, `DELETE FROM tag WHERE id=$1 RETURNING id`, tagID); err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", tagID, err)
+	}
+
+	return nil
+}
+
+// GetTagItems returns the (non-trashed) movies and series which the tag
+// with the ID provided is attached to, ordered by their title.
+func (store *mediaTagStore) GetTagItems(db database.Queryable, tagID uuid.UUID) ([]*TaggedItem, error) {
+	var dest []*TaggedItem
+	if err := db.Select(&dest, `
+		SELECT media.id, media.type, media.title FROM movie_tags mt
+		INNER JOIN media
+		  ON media.id = mt.movie_id
+		 AND media.deleted_at IS NULL
+		WHERE mt.tag_id=$1
+		UNION ALL
+		SELECT series.id, 'series', series.title FROM series_tags st
+		INNER JOIN series
+		  ON series.id = st.series_id
+		 AND series.deleted_at IS NULL
+		WHERE st.tag_id=$1
+		ORDER BY title, id`,
+		tagID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to select items of tag %s: %w", tagID, err)
+	}
+
+	return dest, nil
+}
+
+// SetTagItems replaces the movies and series which the tag is attached to with the
+// items provided. If any of the IDs is not a known movie or series, ErrTagItemNotFound
+// is returned (and the caller is expected to rollback the transaction).
+func (store *mediaTagStore) SetTagItems(db database.Queryable, tagID uuid.UUID, itemIDs []uuid.UUID) error {
+	if _, err := db.Exec(`DELETE FROM movie_tags WHERE tag_id=$1`, tagID); err != nil {
+		return fmt.Errorf("failed to clear movies of tag %s: %w", tagID, err)
+	}
+	if _, err := db.Exec(`DELETE FROM series_tags WHERE tag_id=$1`, tagID); err != nil {
+		return fmt.Errorf("failed to clear series of tag %s: %w", tagID, err)
+	}
+
+	if len(itemIDs) == 0 {
+		return nil
+	}
+
+	distinct := make(map[uuid.UUID]struct{}, len(itemIDs))
+	ids := make(pq.StringArray, 0, len(itemIDs))
+	for _, id := range itemIDs {
+		if _, ok := distinct[id]; !ok {
+			distinct[id] = struct{}{}
+			ids = append(ids, id.String())
+		}
+	}
+
+	var tagged int
+	if err := db.Get(&tagged, `
+		WITH movies AS (
+			INSERT INTO movie_tags(movie_id, tag_id)
+			SELECT id, $1 FROM media WHERE id = ANY($2::uuid[]) AND type = 'movie'
+			RETURNING 1
+		), series AS (
+			INSERT INTO series_tags(series_id, tag_id)
+			SELECT id, $1 FROM series WHERE id = ANY($2::uuid[])
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM movies) + (SELECT COUNT(*) FROM series)`,
+		tagID, ids,
+	); err != nil {
+		return fmt.Errorf("failed to insert items of tag %s: %w", tagID, err)
+	}
+
+	if tagged != len(ids) {
+		return fmt.Errorf("%w: %d of %d items found", ErrTagItemNotFound, tagged, len(ids))
+	}
+
+	return nil
+}
+
+// wrapTagLabelError wraps the error provided with ErrTagLabelConflict if
+// it's a violation of the unique constraint on the label of tags.
+func wrapTagLabelError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "tag_uk_label" {
+		return fmt.Errorf("%w: %s", ErrTagLabelConflict, pqErr.Detail)
+	}
+
+	return err
+}
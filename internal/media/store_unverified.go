@@ -0,0 +1,94 @@
+package media
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+// unverifiedTmdbIDPrefix prefixes the placeholder TMDB IDs of models which were ingested while
+// TMDB was unavailable, using only the metadata parsed from the filename (see UnverifiedTmdbID).
+const unverifiedTmdbIDPrefix = "unverified:"
+
+// UnverifiedTmdbID returns a placeholder TMDB ID for a model which is ingested while TMDB is
+// unavailable. The ID is derived from the kind of model and the parts provided (e.g. the
+// title and season number of a season), so that the episodes of the same unverified
+// season/series share the same placeholder season/series.
+func UnverifiedTmdbID(kind string, parts ...any) string {
+	id := unverifiedTmdbIDPrefix + kind
+	for _, part := range parts {
+		id += fmt.Sprintf(":%v", part)
+	}
+
+	return strings.ToLower(id)
+}
+
+// IsUnverified returns true if this model was ingested while TMDB was unavailable, and so
+// has a placeholder TMDB ID and metadata parsed from the filename of the source file.
+func (model *Model) IsUnverified() bool {
+	return strings.HasPrefix(model.TmdbID, unverifiedTmdbIDPrefix)
+}
+
+// ListUnverifiedMovies returns the (non-trashed) movies which were ingested while TMDB was unavailable.
+func (store *Store) ListUnverifiedMovies(db database.Queryable) ([]*Movie, error) {
+	var dest []*media
+	if err := db.Select(&dest, `SELECT * FROM media WHERE type='movie' AND tmdb_id LIKE $1 AND deleted_at IS NULL`, unverifiedTmdbIDPrefix+"%"); err != nil {
+		return nil, fmt.Errorf("failed to select unverified movies: %w", err)
+	}
+
+	movies := make([]*Movie, len(dest))
+	for k, m := range dest {
+		movies[k] = mediaToMovie(m)
+	}
+
+	return movies, nil
+}
+
+// ListUnverifiedEpisodes returns the (non-trashed) episodes which were ingested while TMDB was unavailable.
+func (store *Store) ListUnverifiedEpisodes(db database.Queryable) ([]*Episode, error) {
+	var dest []*media
+	if err := db.Select(&dest, `SELECT * FROM media WHERE type='episode' AND tmdb_id LIKE $1 AND deleted_at IS NULL`, unverifiedTmdbIDPrefix+"%"); err != nil {
+		return nil, fmt.Errorf("failed to select unverified episodes: %w", err)
+	}
+
+	episodes := make([]*Episode, len(dest))
+	for k, m := range dest {
+		episodes[k] = mediaToEpisode(m)
+	}
+
+	return episodes, nil
+}
+
+// VerifyMedia replaces the placeholder TMDB ID of the unverified movie/episode with the ID provided
+// with the TMDB ID given, so that the media is updated (rather than duplicated) when it's saved
+// using the metadata found in TMDB.
+func (store *Store) VerifyMedia(db database.Queryable, mediaID uuid.UUID, tmdbID string) error {
+	if _, err := db.Exec(`UPDATE media SET tmdb_id=$2 WHERE id=$1 AND tmdb_id LIKE $3`, mediaID, tmdbID, unverifiedTmdbIDPrefix+"%"); err != nil {
+		return fmt.Errorf("failed to verify media %s as TMDB ID %s: %w", mediaID, tmdbID, err)
+	}
+
+	return nil
+}
+
+// DeleteEmptyUnverifiedContainers deletes the unverified seasons which no longer contain any episodes,
+// and then the unverified series which no longer contain any seasons, as their episodes have been
+// moved to the (verified) seasons found in TMDB.
+func (store *Store) DeleteEmptyUnverifiedContainers(db database.Queryable) error {
+	if _, err := db.Exec(`
+		DELETE FROM season
+		WHERE tmdb_id LIKE $1 AND NOT EXISTS (SELECT 1 FROM media WHERE media.season_id = season.id)
+	`, unverifiedTmdbIDPrefix+"%"); err != nil {
+		return fmt.Errorf("failed to delete empty unverified seasons: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		DELETE FROM series
+		WHERE tmdb_id LIKE $1 AND NOT EXISTS (SELECT 1 FROM season WHERE season.series_id = series.id)
+	`, unverifiedTmdbIDPrefix+"%"); err != nil {
+		return fmt.Errorf("failed to delete empty unverified series: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UnverifiedTmdbID(t *testing.T) {
+	assert.Equal(t, "unverified:movie:thematrix:1999", UnverifiedTmdbID("movie", "TheMatrix", 1999))
+	assert.Equal(t, "unverified:episode:theshow:1:2", UnverifiedTmdbID("episode", "theshow", 1, 2))
+	assert.Equal(t, UnverifiedTmdbID("season", "theshow", 1), UnverifiedTmdbID("season", "TheShow", 1), "placeholder IDs should be case insensitive")
+
+	assert.True(t, (&Model{TmdbID: UnverifiedTmdbID("series", "theshow")}).IsUnverified())
+	assert.False(t, (&Model{TmdbID: "603"}).IsUnverified())
+}
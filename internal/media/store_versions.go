@@ -0,0 +1,101 @@
+package media
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/jmoiron/sqlx"
+)
+
+// Version is an additional source file for a movie or episode, which is tracked
+// alongside the primary source of the media (e.g. a duplicate which was kept
+// during ingestion because it differs in quality from the existing source). The
+// label describes the edition the version represents (e.g. "Director's Cut").
+type Version struct {
+	ID        uuid.UUID
+	MediaID   uuid.UUID `db:"media_id"`
+	Label     string    `db:"label"`
+	CreatedAt time.Time `db:"created_at"`
+	MediaResolution
+	SourcePath string `db:"source_path"`
+	SourceSize int64  `db:"source_size"`
+	VideoCodec string `db:"video_codec"`
+}
+
+// SaveVersion upserts the version provided. Existing versions are found using the
+// source path, as a file can only be a version of a single media. The label of an
+// existing version is not changed (see UpdateVersionLabel).
+//
+// NOTE: the ID of the version may be UPDATED to match existing DB entry (if any).
+func (store *Store) SaveVersion(db database.Queryable, version *Version) error {
+	var updatedVersion Version
+	if err := db.QueryRowx(`
+		INSERT INTO media_versions(id, media_id, label, source_path, source_size, video_codec, frame_width, frame_height, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, current_timestamp)
+		ON CONFLICT(source_path) DO UPDATE
+			SET (media_id, source_size, video_codec, frame_width, frame_height) =
+				(EXCLUDED.media_id, EXCLUDED.source_size, EXCLUDED.video_codec, EXCLUDED.frame_width, EXCLUDED.frame_height)
+		RETURNING *;
+	`, version.ID, version.MediaID, version.Label, version.SourcePath, version.SourceSize, version.VideoCodec, version.Width, version.Height).StructScan(&updatedVersion); err != nil {
+		return fmt.Errorf("failed to save version %s of media %s: %w", version.SourcePath, version.MediaID, err)
+	}
+
+	version.ID = updatedVersion.ID
+	version.Label = updatedVersion.Label
+	version.CreatedAt = updatedVersion.CreatedAt
+	return nil
+}
+
+// GetVersion returns the version with the ID provided.
+func (store *Store) GetVersion(db database.Queryable, versionID uuid.UUID) (*Version, error) {
+	return queryRow[Version](db, VersionTable, IDCol, versionID, "")
+}
+
+// UpdateVersionLabel sets the label of the version with the ID provided, returning
+// the updated version.
+func (store *Store) UpdateVersionLabel(db database.Queryable, versionID uuid.UUID, label string) (*Version, error) {
+	var dest Version
+	if err := db.Get(&dest, `UPDATE media_versions SET label=$2 WHERE id=$1 RETURNING *`, versionID, label); err != nil {
+		return nil, fmt.Errorf("failed to update label of version %s: %w", versionID, err)
+	}
+
+	return &dest, nil
+}
+
+// GetVersionsForMedia returns the additional versions of the media with the ID
+// provided, oldest first. The primary source of the media is not included.
+func (store *Store) GetVersionsForMedia(db database.Queryable, mediaID uuid.UUID) ([]*Version, error) {
+	var dest []*Version
+	if err := db.Select(&dest, `SELECT * FROM media_versions WHERE media_id=$1 ORDER BY created_at`, mediaID); err != nil {
+		return nil, fmt.Errorf("failed to select versions of media %s: %w", mediaID, err)
+	}
+
+	return dest, nil
+}
+
+// GetVersionsForMedias returns the additional versions of all the medias with the IDs
+// provided, keyed by the ID of the media they belong to.
+func (store *Store) GetVersionsForMedias(db database.Queryable, mediaIDs []uuid.UUID) (map[uuid.UUID][]*Version, error) {
+	result := make(map[uuid.UUID][]*Version, len(mediaIDs))
+	if len(mediaIDs) == 0 {
+		return result, nil
+	}
+
+	query, args, err := sqlx.In(`SELECT * FROM media_versions WHERE media_id IN (?) ORDER BY created_at`, mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var dest []*Version
+	if err := db.Select(&dest, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to select versions of medias %v: %w", mediaIDs, err)
+	}
+
+	for _, version := range dest {
+		result[version.MediaID] = append(result[version.MediaID], version)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package media
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+// SaveWatchProgress records the playback position of the user provided in the media given, and
+// whether they've completed it, replacing any progress previously recorded.
+func (store *Store) SaveWatchProgress(db database.Queryable, userID uuid.UUID, mediaID uuid.UUID, positionSeconds int, completed bool) error {
+	if _, err := db.Exec(`
+		INSERT INTO watch_progress(user_id, media_id, position_seconds, completed, updated_at)
+		VALUES ($1, $2, $3, $4, current_timestamp)
+		ON CONFLICT(user_id, media_id) DO UPDATE SET
+			position_seconds=EXCLUDED.position_seconds,
+			completed=EXCLUDED.completed,
+			updated_at=EXCLUDED.updated_at
+	`, userID, mediaID, positionSeconds, completed); err != nil {
+		return fmt.Errorf("failed to save watch progress: %w", err)
+	}
+
+	return nil
+}
+
+// ImportWatched records that the user provided completed the media given at the time provided (e.g. when
+// importing their watch history from another service). Progress recorded after this time is retained.
+func (store *Store) ImportWatched(db database.Queryable, userID uuid.UUID, mediaID uuid.UUID, watchedAt time.Time) error {
+	if _, err := db.Exec(`
+		INSERT INTO watch_progress(user_id, media_id, position_seconds, completed, updated_at)
+		VALUES ($1, $2, 0, TRUE, $3)
+		ON CONFLICT(user_id, media_id) DO UPDATE SET
+			position_seconds=EXCLUDED.position_seconds,
+			completed=EXCLUDED.completed,
+			updated_at=EXCLUDED.updated_at
+		WHERE watch_progress.updated_at < EXCLUDED.updated_at
+	`, userID, mediaID, watchedAt); err != nil {
+		return fmt.Errorf("failed to import watched media: %w", err)
+	}
+
+	return nil
+}
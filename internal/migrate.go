@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+// PrintMigrationStatus prints the migrations known to Thea, and which of them have been
+// applied to the database described by the configuration provided. No migrations are run.
+func PrintMigrationStatus(config TheaConfig, out io.Writer) error {
+	db := database.New()
+	if err := db.Open(config.Database); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Schema version %d (latest supported %d)\n\n", status.Current, status.Latest)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tMIGRATION\tAPPLIED AT")
+	for _, m := range status.Migrations {
+		appliedAt := "pending"
+		if m.AppliedAt != nil {
+			appliedAt = m.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, appliedAt)
+	}
+
+	return w.Flush()
+}
+
+// MigrateTo migrates the database described by the configuration provided to the schema
+// version provided. Migrating to an older version is destructive, and so is refused
+// unless allowDowngrade is true. A backup should be taken before downgrading.
+func MigrateTo(config TheaConfig, version int64, allowDowngrade bool) error {
+	db := database.New()
+	if err := db.Open(config.Database); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return db.MigrateTo(version, allowDowngrade)
+}
@@ -0,0 +1,129 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	telegramBaseURL = "https://api.telegram.org"
+	requestTimeout  = time.Second * 10
+)
+
+type (
+	DiscordConfig struct {
+		WebhookURL string `toml:"webhook_url"`
+	}
+
+	TelegramConfig struct {
+		BotToken string `toml:"bot_token"`
+		ChatID   string `toml:"chat_id"`
+	}
+
+	EmailConfig struct {
+		Host     string   `toml:"host"`
+		Port     int      `toml:"port"`
+		Username string   `toml:"username"`
+		Password string   `toml:"password"`
+		From     string   `toml:"from"`
+		To       []string `toml:"to"`
+	}
+
+	GotifyConfig struct {
+		URL      string `toml:"url"`
+		Token    string `toml:"token"`
+		Priority int    `toml:"priority"`
+	}
+
+	// discordConnector posts notifications to a Discord channel using a webhook.
+	discordConnector struct{ config DiscordConfig }
+
+	// telegramConnector sends notifications to a Telegram chat using the Bot API.
+	telegramConnector struct{ config TelegramConfig }
+
+	// emailConnector sends notifications as plain-text emails using SMTP.
+	emailConnector struct{ config EmailConfig }
+
+	// gotifyConnector pushes notifications to a Gotify server.
+	gotifyConnector struct{ config GotifyConfig }
+)
+
+func (conn *discordConnector) Send(ctx context.Context, _ string, message string) error {
+	return postJSON(ctx, conn.config.WebhookURL, map[string]any{"content": message})
+}
+
+func (conn *telegramConnector) Send(ctx context.Context, _ string, message string) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", telegramBaseURL, conn.config.BotToken)
+	return postJSON(ctx, endpoint, map[string]any{"chat_id": conn.config.ChatID, "text": message})
+}
+
+func (conn *gotifyConnector) Send(ctx context.Context, subject string, message string) error {
+	endpoint := fmt.Sprintf("%s/message?token=%s", strings.TrimSuffix(conn.config.URL, "/"), url.QueryEscape(conn.config.Token))
+	return postJSON(ctx, endpoint, map[string]any{"title": subject, "message": message, "priority": conn.config.Priority})
+}
+
+func (conn *emailConnector) Send(_ context.Context, subject string, message string) error {
+	var auth smtp.Auth
+	if conn.config.Username != "" {
+		auth = smtp.PlainAuth("", conn.config.Username, conn.config.Password, conn.config.Host)
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", conn.config.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(conn.config.To, ", "))
+	fmt.Fprintf(&body, "Subject: Thea: %s\r\n", subject)
+	fmt.Fprintf(&body, "Content-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n", message)
+
+	addr := net.JoinHostPort(conn.config.Host, strconv.Itoa(conn.config.Port))
+	return smtp.SendMail(addr, auth, conn.config.From, conn.config.To, body.Bytes())
+}
+
+// postJSON POSTs the body provided, encoded as JSON, to the URL
+// provided. An error is returned if the response is not a 2xx.
+func postJSON(ctx context.Context, endpoint string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The error may contain the URL, which contains secrets for some connectors
+		return fmt.Errorf("request failed: %w", unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,384 @@
+// Package notification delivers notifications of significant events within Thea (such as an
+// ingest completing, or a transcode failing) to external services (Discord, Telegram,
+// email and Gotify). Each configured connector can be restricted to a subset of
+// the notification kinds, and can override the message template of each kind.
+package notification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"text/template"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var log = logger.Get("Notify")
+
+// Kind is the kind of event being notified, and is used to filter the
+// notifications sent by a connector, and to select the template used.
+type Kind string
+
+const (
+	IngestComplete  Kind = "ingest_complete"
+	IngestTroubled  Kind = "ingest_troubled"
+	TranscodeFailed Kind = "transcode_failed"
+	NewMedia        Kind = "new_media"
+)
+
+// defaultTemplates are the message templates used for each kind of notification,
+// unless overridden by the connector. Templates are executed using a Notification.
+var defaultTemplates = map[Kind]string{
+	IngestComplete:  `Ingest of '{{.Path}}' complete`,
+	IngestTroubled:  `Ingest of '{{.Path}}' requires attention: {{.Error}}`,
+	TranscodeFailed: `Transcode of '{{.Title}}' to target '{{.Target}}' failed: {{.Error}}`,
+	NewMedia:        `'{{.Title}}' has been added to the library`,
+}
+
+// subjects are the titles/subjects of each kind of notification, for the
+// connectors which support them (email and Gotify).
+var subjects = map[Kind]string{
+	IngestComplete:  "Ingest complete",
+	IngestTroubled:  "Ingest troubled",
+	TranscodeFailed: "Transcode failed",
+	NewMedia:        "New media",
+}
+
+type (
+	Config struct {
+		Connectors []ConnectorConfig `toml:"connectors"`
+	}
+
+	// ConnectorConfig configures a single connector. The Type of the connector determines
+	// which of the connector specific configurations (Discord, Telegram, Email or Gotify)
+	// is used. If Events is empty, all kinds of notification are sent using the connector.
+	ConnectorConfig struct {
+		Name      string          `toml:"name"`
+		Type      string          `toml:"type"`
+		Events    []Kind          `toml:"events"`
+		Templates map[Kind]string `toml:"templates"`
+
+		Discord  DiscordConfig  `toml:"discord"`
+		Telegram TelegramConfig `toml:"telegram"`
+		Email    EmailConfig    `toml:"email"`
+		Gotify   GotifyConfig   `toml:"gotify"`
+	}
+
+	// Notification describes an event being notified, and is the data
+	// available to the message templates.
+	Notification struct {
+		Kind Kind
+		// Title is the title of the media the notification concerns. For
+		// ingests, this is the title scraped from the file (if any).
+		Title string
+		// Path is the path of the file the notification concerns.
+		Path   string
+		Target string
+		Error  string
+	}
+
+	Store interface {
+		GetMedia(mediaID uuid.UUID) *media.Container
+	}
+
+	IngestProvider interface {
+		GetIngest(ingestID uuid.UUID) *ingest.IngestItem
+	}
+
+	TaskProvider interface {
+		Task(taskID uuid.UUID) *transcode.TranscodeTask
+	}
+
+	connector interface {
+		Send(ctx context.Context, subject string, message string) error
+	}
+
+	notifier struct {
+		name      string
+		connector connector
+		events    []Kind
+		templates map[Kind]*template.Template
+	}
+
+	// Service sends notifications using the configured connectors. Notifications for ingests and
+	// transcodes are captured when the event is dispatched (as the ingest/task may be removed
+	// shortly after), and are delivered in the background so as not to block the dispatcher.
+	Service struct {
+		notifiers      []*notifier
+		notifiersMutex sync.RWMutex
+		store          Store
+		ingests        IngestProvider
+		tasks          TaskProvider
+		eventBus       event.EventHandler
+		pending        chan *Notification
+
+		// troubled contains the IDs of the ingests/tasks which have already been notified as
+		// troubled, to avoid repeated notifications as further updates for them are dispatched.
+		troubled      map[uuid.UUID]struct{}
+		troubledMutex sync.Mutex
+	}
+)
+
+const pendingBufferSize = 100
+
+func New(config Config, store Store, ingests IngestProvider, tasks TaskProvider, eventBus event.EventHandler) (*Service, error) {
+	notifiers, err := newNotifiers(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		notifiers: notifiers,
+		store:     store,
+		ingests:   ingests,
+		tasks:     tasks,
+		eventBus:  eventBus,
+		pending:   make(chan *Notification, pendingBufferSize),
+		troubled:  make(map[uuid.UUID]struct{}),
+	}, nil
+}
+
+// ApplyConfig replaces the connectors used to send notifications. If any of the connectors
+// are invalid, an error is returned and the existing connectors remain in use.
+func (service *Service) ApplyConfig(config Config) error {
+	notifiers, err := newNotifiers(config)
+	if err != nil {
+		return err
+	}
+
+	service.notifiersMutex.Lock()
+	defer service.notifiersMutex.Unlock()
+	service.notifiers = notifiers
+	log.Emit(logger.INFO, "Notification connectors reloaded, now using %d connector(s)\n", len(notifiers))
+
+	return nil
+}
+
+// Validate returns an error if any of the connectors in the configuration are invalid.
+func (config Config) Validate() error {
+	_, err := newNotifiers(config)
+	return err
+}
+
+func newNotifiers(config Config) ([]*notifier, error) {
+	notifiers := make([]*notifier, 0, len(config.Connectors))
+	for k, conf := range config.Connectors {
+		name := conf.Name
+		if name == "" {
+			name = fmt.Sprintf("%s#%d", conf.Type, k)
+		}
+
+		n, err := newNotifier(name, conf)
+		if err != nil {
+			return nil, fmt.Errorf("notification connector '%s' is invalid: %w", name, err)
+		}
+		notifiers = append(notifiers, n)
+	}
+
+	return notifiers, nil
+}
+
+func newNotifier(name string, conf ConnectorConfig) (*notifier, error) {
+	var conn connector
+	switch conf.Type {
+	case "discord":
+		conn = &discordConnector{conf.Discord}
+	case "telegram":
+		conn = &telegramConnector{conf.Telegram}
+	case "email":
+		conn = &emailConnector{conf.Email}
+	case "gotify":
+		conn = &gotifyConnector{conf.Gotify}
+	default:
+		return nil, fmt.Errorf("unknown connector type '%s'", conf.Type)
+	}
+
+	templates := make(map[Kind]*template.Template, len(defaultTemplates))
+	for kind, text := range defaultTemplates {
+		if override, ok := conf.Templates[kind]; ok {
+			text = override
+		}
+
+		tmpl, err := template.New(string(kind)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template for '%s' is invalid: %w", kind, err)
+		}
+		templates[kind] = tmpl
+	}
+	for kind := range conf.Templates {
+		if _, ok := defaultTemplates[kind]; !ok {
+			return nil, fmt.Errorf("template provided for unknown notification kind '%s'", kind)
+		}
+	}
+	for _, kind := range conf.Events {
+		if _, ok := defaultTemplates[kind]; !ok {
+			return nil, fmt.Errorf("unknown notification kind '%s'", kind)
+		}
+	}
+
+	return &notifier{name: name, connector: conn, events: conf.Events, templates: templates}, nil
+}
+
+func (service *Service) Run(ctx context.Context) error {
+	// Ingests and tasks are inspected as soon as the event is dispatched, as the
+	// ingest service removes completed ingests in response to the same event.
+	service.eventBus.RegisterHandlerFunction(event.IngestUpdateEvent, service.handleIngestEvent)
+	service.eventBus.RegisterHandlerFunction(event.IngestCompleteEvent, service.handleIngestEvent)
+	service.eventBus.RegisterHandlerFunction(event.TranscodeUpdateEvent, service.handleTranscodeEvent)
+
+	handlerChannelSize := 100
+	ev := make(event.HandlerChannel, handlerChannelSize)
+	service.eventBus.RegisterHandlerChannel(ev, event.NewMediaEvent)
+
+	service.notifiersMutex.RLock()
+	log.Emit(logger.NEW, "Sending notifications using %d connector(s)\n", len(service.notifiers))
+	service.notifiersMutex.RUnlock()
+	for {
+		select {
+		case message := <-ev:
+			mediaID, ok := message.Payload.(uuid.UUID)
+			if !ok {
+				log.Emit(logger.ERROR, "failed to extract UUID from %s event (payload %#v)\n", message.Event, message.Payload)
+				continue
+			}
+
+			container := service.store.GetMedia(mediaID)
+			if container == nil {
+				log.Warnf("Unable to notify of new media %s, media not found\n", mediaID)
+				continue
+			}
+
+			service.notify(ctx, &Notification{Kind: NewMedia, Title: mediaTitle(container), Path: container.Source()})
+		case notification := <-service.pending:
+			service.notify(ctx, notification)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// handleIngestEvent queues a notification if the ingest has completed, or
+// has become troubled (once per trouble).
+func (service *Service) handleIngestEvent(ev event.Event, payload event.Payload) {
+	itemID := payload.(uuid.UUID) //nolint:forcetypeassert
+	item := service.ingests.GetIngest(itemID)
+	if item == nil {
+		return
+	}
+
+	notification := &Notification{Path: item.Path}
+	if item.ScrapedMetadata != nil {
+		notification.Title = item.ScrapedMetadata.Title
+	}
+
+	service.troubledMutex.Lock()
+	defer service.troubledMutex.Unlock()
+	if ev == event.IngestCompleteEvent {
+		delete(service.troubled, itemID)
+		notification.Kind = IngestComplete
+		service.queue(notification)
+		return
+	}
+
+	if item.State != ingest.Troubled || item.Trouble == nil {
+		delete(service.troubled, itemID)
+		return
+	}
+	if _, ok := service.troubled[itemID]; ok {
+		return
+	}
+
+	service.troubled[itemID] = struct{}{}
+	notification.Kind = IngestTroubled
+	notification.Error = item.Trouble.Error()
+	service.queue(notification)
+}
+
+// handleTranscodeEvent queues a notification if the task has failed. Tasks which are
+// re-queued to be retried are not TROUBLED, and so are only notified once the retries
+// are exhausted.
+func (service *Service) handleTranscodeEvent(_ event.Event, payload event.Payload) {
+	taskID := payload.(uuid.UUID) //nolint:forcetypeassert
+	task := service.tasks.Task(taskID)
+
+	service.troubledMutex.Lock()
+	defer service.troubledMutex.Unlock()
+	if task == nil || task.Status() != transcode.TROUBLED {
+		delete(service.troubled, taskID)
+		return
+	}
+	if _, ok := service.troubled[taskID]; ok {
+		return
+	}
+
+	service.troubled[taskID] = struct{}{}
+	notification := &Notification{Kind: TranscodeFailed, Title: mediaTitle(task.Media()), Path: task.Source()}
+	if task.Target() != nil {
+		notification.Target = task.Target().Label
+	}
+	if task.Trouble() != nil {
+		notification.Error = task.Trouble().Error()
+	}
+
+	service.queue(notification)
+}
+
+// queue submits the notification to be sent in the background. If the queue is
+// full, the notification is dropped rather than blocking the dispatcher.
+func (service *Service) queue(notification *Notification) {
+	select {
+	case service.pending <- notification:
+	default:
+		log.Warnf("Notification queue is full, dropping %s notification\n", notification.Kind)
+	}
+}
+
+// notify sends the notification using each connector which accepts notifications
+// of it's kind. Failure to send a notification is logged, but not retried.
+func (service *Service) notify(ctx context.Context, notification *Notification) {
+	service.notifiersMutex.RLock()
+	notifiers := service.notifiers
+	service.notifiersMutex.RUnlock()
+
+	for _, n := range notifiers {
+		if len(n.events) > 0 && !slices.Contains(n.events, notification.Kind) {
+			continue
+		}
+
+		message, err := n.render(notification)
+		if err != nil {
+			log.Errorf("Failed to render %s notification for connector %s: %v\n", notification.Kind, n.name, err)
+			continue
+		}
+
+		if err := n.connector.Send(ctx, subjects[notification.Kind], message); err != nil {
+			log.Warnf("Failed to send %s notification using connector %s: %v\n", notification.Kind, n.name, err)
+		}
+	}
+}
+
+func (n *notifier) render(notification *Notification) (string, error) {
+	var out bytes.Buffer
+	if err := n.templates[notification.Kind].Execute(&out, notification); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+// mediaTitle returns the title of the media, including the series
+// and episode number if the media is an episode.
+func mediaTitle(container *media.Container) string {
+	if container.Type != media.EpisodeContainerType || container.Series == nil {
+		return container.Title()
+	}
+
+	return fmt.Sprintf("%s S%02dE%02d - %s", container.Series.Title, container.SeasonNumber(), container.EpisodeNumber(), container.Title())
+}
@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NotifyFiltersAndRendersTemplates(t *testing.T) {
+	received := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Content string }
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received = append(received, body.Content)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	service, err := New(Config{Connectors: []ConnectorConfig{{
+		Type:      "discord",
+		Events:    []Kind{NewMedia, TranscodeFailed},
+		Templates: map[Kind]string{NewMedia: `New: {{.Title}}`},
+		Discord:   DiscordConfig{WebhookURL: server.URL},
+	}}}, nil, nil, nil, nil)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	service.notify(ctx, &Notification{Kind: IngestComplete, Path: "/a.mkv"})
+	service.notify(ctx, &Notification{Kind: NewMedia, Title: "The Matrix"})
+	service.notify(ctx, &Notification{Kind: TranscodeFailed, Title: "The Matrix", Target: "1080p", Error: "exit status 1"})
+
+	assert.Equal(t, []string{
+		"New: The Matrix",
+		"Transcode of 'The Matrix' to target '1080p' failed: exit status 1",
+	}, received)
+}
+
+func Test_NewRejectsInvalidConnectors(t *testing.T) {
+	for _, conf := range []ConnectorConfig{
+		{Type: "carrier-pigeon"},
+		{Type: "gotify", Events: []Kind{"media_deleted"}},
+		{Type: "gotify", Templates: map[Kind]string{NewMedia: `{{.Title`}},
+	} {
+		_, err := New(Config{Connectors: []ConnectorConfig{conf}}, nil, nil, nil, nil)
+		assert.Error(t, err)
+	}
+}
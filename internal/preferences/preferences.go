@@ -0,0 +1,92 @@
+// Package preferences stores the default playback preferences of each user. The preferences are
+// consulted when the watch targets of media are listed for the user, and are recorded against
+// the playback sessions started by the user, so that the client (or streaming server) can
+// select the streams of the media to play without asking the user each time.
+package preferences
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+type (
+	// Preferences are the default playback preferences of a user. The languages are ISO 639-2 codes,
+	// and a nil language or bitrate indicates the user has no preference. MaxBitrateKbps is the
+	// maximum bitrate the user wishes to stream, in kilobits per second.
+	Preferences struct {
+		UserID              uuid.UUID  `db:"user_id"`
+		UpdatedAt           *time.Time `db:"updated_at"`
+		AudioLanguage       *string    `db:"audio_language"`
+		SubtitleLanguage    *string    `db:"subtitle_language"`
+		MaxBitrateKbps      *int       `db:"max_bitrate_kbps"`
+		AutoplayNextEpisode bool       `db:"autoplay_next_episode"`
+	}
+
+	Store struct{}
+)
+
+var (
+	ErrLanguageInvalid = errors.New("preferred languages must be three-letter (ISO 639-2) language codes")
+	ErrBitrateInvalid  = errors.New("maximum bitrate must be positive")
+)
+
+// Default returns the preferences of a user which has not saved any preferences.
+func Default(userID uuid.UUID) *Preferences {
+	return &Preferences{UserID: userID, AutoplayNextEpisode: true}
+}
+
+// Validate returns an error if the preferences cannot be saved.
+func (preferences *Preferences) Validate() error {
+	for _, language := range []*string{preferences.AudioLanguage, preferences.SubtitleLanguage} {
+		if language != nil && !validLanguage(*language) {
+			return ErrLanguageInvalid
+		}
+	}
+	if preferences.MaxBitrateKbps != nil && *preferences.MaxBitrateKbps <= 0 {
+		return ErrBitrateInvalid
+	}
+
+	return nil
+}
+
+// AllowsBitrate returns true if the user is willing to stream media of the bitrate provided (in kbps).
+func (preferences *Preferences) AllowsBitrate(kbps int) bool {
+	return preferences.MaxBitrateKbps == nil || kbps <= *preferences.MaxBitrateKbps
+}
+
+// Get returns the preferences of the user with the ID provided. If the user
+// has not saved any preferences, then the default preferences are returned.
+func (store *Store) Get(db database.Queryable, userID uuid.UUID) (*Preferences, error) {
+	var result Preferences
+	if err := db.Get(&result, `SELECT * FROM user_preferences WHERE user_id=$1`, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Default(userID), nil
+		}
+
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Save creates or replaces the preferences of the user.
+func (store *Store) Save(db database.Queryable, preferences *Preferences) error {
+	_, err := db.NamedExec(`
+		INSERT INTO user_preferences(user_id, updated_at, audio_language, subtitle_language, max_bitrate_kbps, autoplay_next_episode)
+		VALUES (:user_id, current_timestamp, :audio_language, :subtitle_language, :max_bitrate_kbps, :autoplay_next_episode)
+		ON CONFLICT(user_id) DO UPDATE
+		SET (updated_at, audio_language, subtitle_language, max_bitrate_kbps, autoplay_next_episode) =
+			(current_timestamp, EXCLUDED.audio_language, EXCLUDED.subtitle_language, EXCLUDED.max_bitrate_kbps, EXCLUDED.autoplay_next_episode)
+	`, preferences)
+
+	return err
+}
+
+func validLanguage(language string) bool {
+	return len(language) == 3 && strings.IndexFunc(language, func(r rune) bool { return r < 'a' || r > 'z' }) < 0
+}
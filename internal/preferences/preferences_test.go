@@ -0,0 +1,29 @@
+package preferences
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+
+	assert.NoError(t, Default(uuid.New()).Validate())
+	assert.NoError(t, (&Preferences{AudioLanguage: str("jpn"), SubtitleLanguage: str("eng"), MaxBitrateKbps: num(8000)}).Validate())
+	assert.ErrorIs(t, (&Preferences{AudioLanguage: str("japanese")}).Validate(), ErrLanguageInvalid)
+	assert.ErrorIs(t, (&Preferences{SubtitleLanguage: str("ENG")}).Validate(), ErrLanguageInvalid)
+	assert.ErrorIs(t, (&Preferences{MaxBitrateKbps: num(0)}).Validate(), ErrBitrateInvalid)
+}
+
+func Test_AllowsBitrate(t *testing.T) {
+	t.Parallel()
+	limit := 8000
+
+	assert.True(t, Default(uuid.New()).AllowsBitrate(50000))
+	assert.True(t, (&Preferences{MaxBitrateKbps: &limit}).AllowsBitrate(8000))
+	assert.False(t, (&Preferences{MaxBitrateKbps: &limit}).AllowsBitrate(8001))
+}
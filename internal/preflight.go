@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/preflight"
+	"github.com/jmoiron/sqlx"
+)
+
+// systemPreflightChecks returns the pre-flight checks which validate the
+// system Thea is running on. These checks do not require a database connection.
+func systemPreflightChecks(config TheaConfig) []preflight.Check {
+	ingestPath := config.IngestService.GetIngestPath()
+	checks := []preflight.Check{
+		preflight.FfmpegVersionCheck(config.Format.FfmpegBinaryPath),
+		preflight.FfprobeVersionCheck(config.Format.FfprobeBinaryPath),
+		preflight.DirectoryCheck("ingest directory", ingestPath, "ingestion.dir_path", false),
+		preflight.DirectoryCheck("transcode output directory", config.Format.OutputPath, "transcode.default_output_dir", true),
+		preflight.InotifyCheck(ingestPath),
+	}
+	for k, pool := range config.Format.StoragePools {
+		checks = append(checks, preflight.DirectoryCheck(fmt.Sprintf("storage pool '%s'", pool.Name), pool.Path, fmt.Sprintf("transcode.storage_pools[%d].path", k), true))
+	}
+
+	return checks
+}
+
+// databasePreflightChecks returns the pre-flight checks which require a database
+// connection, including ensuring that ffmpeg supports the encoders used by
+// the transcode targets provided.
+func databasePreflightChecks(config TheaConfig, db *sqlx.DB, targets []*ffmpeg.Target) []preflight.Check {
+	return []preflight.Check{
+		preflight.PostgresServerVersionCheck(db),
+		preflight.EncodersCheck(config.Format.FfmpegBinaryPath, targetEncoders(targets)),
+	}
+}
+
+// targetEncoders returns the video and audio encoders used by the targets provided.
+func targetEncoders(targets []*ffmpeg.Target) []string {
+	encoders := make([]string, 0, len(targets)*2)
+	for _, target := range targets {
+		if target.FfmpegOptions == nil {
+			continue
+		}
+		if target.FfmpegOptions.VideoCodec != nil {
+			encoders = append(encoders, *target.FfmpegOptions.VideoCodec)
+		}
+		if target.FfmpegOptions.AudioCodec != nil {
+			encoders = append(encoders, *target.FfmpegOptions.AudioCodec)
+		}
+	}
+
+	return encoders
+}
+
+// logPreflightResults emits the results provided to the log, including the remediation
+// for any unsuccessful checks. Failed checks do not prevent Thea from starting, as not
+// all functionality depends on every requirement being met.
+func logPreflightResults(results []preflight.Result) {
+	for _, r := range results {
+		//exhaustive:enforce
+		switch r.Status {
+		case preflight.Pass:
+			log.Debugf("Pre-flight check '%s' passed: %s\n", r.Name, r.Message)
+		case preflight.Warn:
+			log.Warnf("Pre-flight check '%s' raised a warning: %s. %s\n", r.Name, r.Message, r.Remediation)
+		case preflight.Fail:
+			log.Errorf("Pre-flight check '%s' failed: %s. %s\n", r.Name, r.Message, r.Remediation)
+		}
+	}
+}
+
+// Doctor loads and validates the configuration file at the path provided, and then runs all
+// of Thea's pre-flight checks (as well as checks of the database migrations and TMDB API key)
+// using it, printing the results to stdout. Unlike the checks performed when Thea starts, this
+// does not start any supporting services, and so any database must already be running.
+// True is returned if none of the checks failed.
+func Doctor(configPath string) bool {
+	config := TheaConfig{}
+	if err := config.LoadFromFile(configPath); err != nil {
+		printPreflightResults([]preflight.Result{{
+			Name:        "configuration",
+			Status:      preflight.Fail,
+			Message:     err.Error(),
+			Remediation: "Ensure the configuration file exists, is valid TOML, and provides all required options (e.g. 'tmdb_api_key', 'ingestion.dir_path' and 'transcode.default_output_dir')",
+		}})
+		return false
+	}
+
+	results := []preflight.Result{validateConfig(config)}
+	results = append(results, preflight.Run(systemPreflightChecks(config)...)...)
+	results = append(results, preflight.Run(preflight.TmdbKeyCheck(config.TmdbKey))...)
+
+	db, err := sqlx.Open(database.SQLDialect, config.Database.DSN())
+	if err != nil {
+		results = append(results, preflight.Result{
+			Name:        "postgres connection",
+			Status:      preflight.Fail,
+			Message:     fmt.Sprintf("unable to open connection to postgres: %v", err),
+			Remediation: "Check the 'database' section of your configuration",
+		})
+	} else {
+		defer db.Close()
+
+		dbResults := preflight.Run(preflight.PostgresServerVersionCheck(db))
+		if !preflight.AnyFailed(dbResults) {
+			dbResults = append(dbResults, preflight.Run(preflight.MigrationStatusCheck(db))...)
+
+			// Targets can only be queried if the database is reachable and
+			// has been migrated
+			if !preflight.AnyFailed(dbResults) {
+				targets := (&ffmpeg.Store{}).GetAll(db)
+				dbResults = append(dbResults, preflight.Run(preflight.EncodersCheck(config.Format.FfmpegBinaryPath, targetEncoders(targets)))...)
+			}
+		}
+		results = append(results, dbResults...)
+	}
+
+	printPreflightResults(results)
+	return !preflight.AnyFailed(results)
+}
+
+// validateConfig returns the result of validating the configuration provided (see TheaConfig.Validate).
+func validateConfig(config TheaConfig) preflight.Result {
+	if err := config.Validate(); err != nil {
+		return preflight.Result{
+			Name:        "configuration",
+			Status:      preflight.Fail,
+			Message:     strings.ReplaceAll(err.Error(), "\n", "; "),
+			Remediation: fmt.Sprintf("Correct the listed options in '%s' (or their environment variables)", config.path),
+		}
+	}
+
+	return preflight.Result{Name: "configuration", Status: preflight.Pass, Message: fmt.Sprintf("configuration '%s' is valid", config.path)}
+}
+
+func printPreflightResults(results []preflight.Result) {
+	for _, r := range results {
+		fmt.Printf("[%s] %s: %s\n", r.Status, r.Name, r.Message)
+		if r.Remediation != "" {
+			fmt.Printf("       -> %s\n", r.Remediation)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package preflight
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	minFfmpegMajorVersion = 4
+	minPostgresVersionNum = 120000
+
+	inotifyMaxWatchesPath = "/proc/sys/fs/inotify/max_user_watches"
+
+	tmdbConfigurationURL = "https://api.themoviedb.org/3/configuration"
+	tmdbCheckTimeout     = 10 * time.Second
+)
+
+var ffmpegVersionRegex = regexp.MustCompile(`version n?(\d+)\.(\d+)`)
+
+// FfmpegVersionCheck ensures that the ffmpeg binary at the path provided
+// can be executed, and is at least the minimum supported version.
+func FfmpegVersionCheck(binPath string) Check {
+	return binaryVersionCheck("ffmpeg", binPath, "transcode.ffmpeg_binary_path")
+}
+
+// FfprobeVersionCheck ensures that the ffprobe binary at the path provided
+// can be executed, and is at least the minimum supported version.
+func FfprobeVersionCheck(binPath string) Check {
+	return binaryVersionCheck("ffprobe", binPath, "transcode.ffprobe_binary_path")
+}
+
+func binaryVersionCheck(binName string, binPath string, configKey string) Check {
+	name := fmt.Sprintf("%s version", binName)
+	return func() Result {
+		out, err := exec.Command(binPath, "-version").Output() //nolint:gosec
+		if err != nil {
+			return fail(name,
+				fmt.Sprintf("Install %s (version %d or newer), or set '%s' in your configuration to the path of an existing installation", binName, minFfmpegMajorVersion, configKey),
+				"unable to execute %s at '%s': %v", binName, binPath, err)
+		}
+
+		matches := ffmpegVersionRegex.FindSubmatch(out)
+		if matches == nil {
+			return warn(name,
+				fmt.Sprintf("Ensure the %s build in use is version %d or newer", binName, minFfmpegMajorVersion),
+				"unable to determine version of %s at '%s' (custom builds may not report a version)", binName, binPath)
+		}
+
+		major, _ := strconv.Atoi(string(matches[1]))
+		if major < minFfmpegMajorVersion {
+			return fail(name,
+				fmt.Sprintf("Upgrade %s to version %d or newer", binName, minFfmpegMajorVersion),
+				"%s at '%s' is version %s.%s, which is not supported", binName, binPath, matches[1], matches[2])
+		}
+
+		return pass(name, "%s at '%s' is version %s.%s", binName, binPath, matches[1], matches[2])
+	}
+}
+
+// EncodersCheck ensures that the ffmpeg binary at the path provided has support
+// for all of the encoders provided. The special 'copy' codec is ignored.
+func EncodersCheck(ffmpegBinPath string, required []string) Check {
+	const name = "ffmpeg encoders"
+	return func() Result {
+		out, err := exec.Command(ffmpegBinPath, "-hide_banner", "-encoders").Output() //nolint:gosec
+		if err != nil {
+			return fail(name, "Ensure ffmpeg is installed and executable", "unable to list encoders using ffmpeg at '%s': %v", ffmpegBinPath, err)
+		}
+
+		available := parseEncoders(out)
+		missing := make([]string, 0)
+		for _, enc := range required {
+			if enc != "copy" && !slices.Contains(available, enc) && !slices.Contains(missing, enc) {
+				missing = append(missing, enc)
+			}
+		}
+
+		if len(missing) > 0 {
+			return fail(name,
+				"Install an ffmpeg build which includes these encoders, or update your transcode targets to use encoders which are available",
+				"ffmpeg at '%s' is missing required encoder(s): %s", ffmpegBinPath, strings.Join(missing, ", "))
+		}
+
+		return pass(name, "all %d required encoder(s) are available", len(required))
+	}
+}
+
+// parseEncoders extracts the encoder names from the output of 'ffmpeg -encoders'. Each
+// encoder is listed on it's own line after a ' ------' separator, in the format
+// ' V....D libx264              libx264 H.264 / AVC / ...'.
+func parseEncoders(output []byte) []string {
+	encoders := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	seenSeparator := false
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if !seenSeparator {
+			seenSeparator = len(fields) == 1 && strings.HasPrefix(fields[0], "---")
+			continue
+		}
+
+		if len(fields) >= 2 {
+			encoders = append(encoders, fields[1])
+		}
+	}
+
+	return encoders
+}
+
+// PostgresVersionCheck connects to the database described by the configuration
+// provided and ensures the server is at least the minimum supported version.
+func PostgresVersionCheck(config database.DatabaseConfig) Check {
+	return func() Result {
+		db, err := sqlx.Open(database.SQLDialect, config.DSN())
+		if err != nil {
+			return fail("postgres version", "Check the 'database' section of your configuration", "unable to open connection to postgres: %v", err)
+		}
+		defer db.Close()
+
+		return PostgresServerVersionCheck(db)()
+	}
+}
+
+// PostgresServerVersionCheck ensures that the server the provided database handle is
+// connected to is at least the minimum supported version.
+func PostgresServerVersionCheck(db *sqlx.DB) Check {
+	const name = "postgres version"
+	return func() Result {
+		var versionNum int
+		if err := db.Get(&versionNum, `SHOW server_version_num`); err != nil {
+			return fail(name,
+				"Ensure postgres is running and reachable, and that the 'database' section of your configuration is correct",
+				"unable to query postgres server version: %v", err)
+		}
+
+		if versionNum < minPostgresVersionNum {
+			return fail(name,
+				fmt.Sprintf("Upgrade postgres to version %d or newer", minPostgresVersionNum/10000),
+				"postgres server version %d is not supported", versionNum)
+		}
+
+		return pass(name, "postgres server version %d", versionNum)
+	}
+}
+
+// MigrationStatusCheck ensures that the schema of the database provided is not newer than
+// this version of Thea supports. Outstanding migrations are reported, but do not fail the
+// check as they are applied automatically when Thea starts.
+func MigrationStatusCheck(db *sqlx.DB) Check {
+	const name = "database migrations"
+	return func() Result {
+		latest, err := database.LatestSchemaVersion()
+		if err != nil {
+			return fail(name, "This build of Thea is corrupt, reinstall Thea", "unable to determine latest migration: %v", err)
+		}
+
+		applied, err := database.AppliedSchemaVersion(db)
+		if err != nil {
+			return fail(name, "Ensure the database user has permission to read the 'goose_db_version' table", "unable to determine applied migrations: %v", err)
+		}
+
+		if applied > latest {
+			return fail(name,
+				"Upgrade Thea to the version which last migrated this database, or restore a backup taken using this version",
+				"database schema version %d is newer than the latest version supported (%d)", applied, latest)
+		} else if applied < latest {
+			return warn(name,
+				"No action required, outstanding migrations are applied when Thea starts. Consider taking a backup first",
+				"database schema version %d is behind the latest version (%d)", applied, latest)
+		}
+
+		return pass(name, "database schema is up to date (version %d)", applied)
+	}
+}
+
+// TmdbKeyCheck ensures that TMDB accepts the API key provided. Failure to reach TMDB only
+// raises a warning, as the outage may be temporary.
+func TmdbKeyCheck(apiKey string) Check {
+	const name = "tmdb api key"
+	return func() Result {
+		client := &http.Client{Timeout: tmdbCheckTimeout}
+		resp, err := client.Get(fmt.Sprintf("%s?api_key=%s", tmdbConfigurationURL, url.QueryEscape(apiKey)))
+		if err != nil {
+			// The error includes the URL, which contains the API key
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return warn(name, "Ensure Thea has internet access, ingestion requires TMDB to be reachable", "unable to reach TMDB: %v", err)
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			return fail(name,
+				"Set 'tmdb_api_key' in your configuration (or the TMDB_API_KEY environment variable) to a valid TMDB API key. See https://developer.themoviedb.org/docs/getting-started",
+				"TMDB rejected the configured API key")
+		case resp.StatusCode != http.StatusOK:
+			return warn(name, "Try again later, TMDB may be experiencing an outage", "unable to verify API key, TMDB responded with status %d", resp.StatusCode)
+		}
+
+		return pass(name, "TMDB accepted the configured API key")
+	}
+}
+
+// DirectoryCheck ensures that the directory at the path provided exists and is
+// readable. If writable is true, the directory must also allow files to be created.
+func DirectoryCheck(label string, path string, configKey string, writable bool) Check {
+	name := fmt.Sprintf("%s permissions", label)
+	return func() Result {
+		remediation := fmt.Sprintf("Ensure the directory '%s' exists and is accessible by the user running Thea, or set '%s' in your configuration", path, configKey)
+		info, err := os.Stat(path)
+		if err != nil {
+			return fail(name, remediation, "unable to access %s '%s': %v", label, path, err)
+		} else if !info.IsDir() {
+			return fail(name, remediation, "%s '%s' is not a directory", label, path)
+		}
+
+		if _, err := os.ReadDir(path); err != nil {
+			return fail(name, remediation, "%s '%s' is not readable: %v", label, path, err)
+		}
+
+		if writable {
+			f, err := os.CreateTemp(path, ".thea-preflight-*")
+			if err != nil {
+				return fail(name, remediation, "%s '%s' is not writable: %v", label, path, err)
+			}
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+
+		return pass(name, "%s '%s' is accessible", label, path)
+	}
+}
+
+// InotifyCheck ensures that the number of inotify watches available to the
+// user is enough to recursively watch the directory provided. This check
+// only applies to Linux; on other platforms it always passes.
+func InotifyCheck(watchPath string) Check {
+	const name = "inotify watches"
+	return func() Result {
+		raw, err := os.ReadFile(inotifyMaxWatchesPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return pass(name, "inotify is not used on this platform")
+		} else if err != nil {
+			return warn(name, "Ensure Thea can read "+inotifyMaxWatchesPath, "unable to read inotify watch limit: %v", err)
+		}
+
+		maxWatches, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+		if err != nil {
+			return warn(name, "Ensure Thea can read "+inotifyMaxWatchesPath, "unable to parse inotify watch limit '%s': %v", raw, err)
+		}
+
+		required := 0
+		if err := filepath.WalkDir(watchPath, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				required++
+			}
+			return nil
+		}); err != nil {
+			return warn(name, "Ensure the ingest directory is readable", "unable to count directories in '%s': %v", watchPath, err)
+		}
+
+		if required > maxWatches {
+			return warn(name,
+				fmt.Sprintf("Increase the limit, e.g. 'sysctl fs.inotify.max_user_watches=%d'. Thea will fall back to periodic polling of the ingest directory until then", required*2),
+				"watching '%s' requires %d inotify watches, but the limit is %d", watchPath, required, maxWatches)
+		}
+
+		return pass(name, "%d of %d available inotify watches required", required, maxWatches)
+	}
+}
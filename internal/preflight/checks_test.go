@@ -0,0 +1,52 @@
+package preflight_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/preflight"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DirectoryCheck(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	assert.NoError(t, os.WriteFile(file, []byte("hello"), 0o600))
+
+	tests := []struct {
+		summary  string
+		path     string
+		writable bool
+		expected preflight.Status
+	}{
+		{summary: "Readable directory", path: dir, writable: false, expected: preflight.Pass},
+		{summary: "Writable directory", path: dir, writable: true, expected: preflight.Pass},
+		{summary: "Missing directory", path: filepath.Join(dir, "missing"), writable: false, expected: preflight.Fail},
+		{summary: "Path is a file", path: file, writable: false, expected: preflight.Fail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.summary, func(t *testing.T) {
+			result := preflight.DirectoryCheck("test dir", tt.path, "test_dir", tt.writable)()
+			assert.Equal(t, tt.expected, result.Status, "unexpected status for result %#v", result)
+			if tt.expected != preflight.Pass {
+				assert.NotEmpty(t, result.Remediation, "expected remediation for unsuccessful result")
+			}
+		})
+	}
+
+	// Ensure the writable check cleans up after itself
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func Test_AnyFailed(t *testing.T) {
+	passing := func() preflight.Result { return preflight.Result{Name: "passing", Status: preflight.Pass} }
+	warning := func() preflight.Result { return preflight.Result{Name: "warning", Status: preflight.Warn} }
+	failing := func() preflight.Result { return preflight.Result{Name: "failing", Status: preflight.Fail} }
+
+	assert.False(t, preflight.AnyFailed(preflight.Run(passing, warning)))
+	assert.True(t, preflight.AnyFailed(preflight.Run(passing, warning, failing)))
+}
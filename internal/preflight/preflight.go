@@ -0,0 +1,77 @@
+// Package preflight contains the checks used to validate that the environment
+// Thea is running in meets it's requirements. Each check produces a result
+// which, if unsuccessful, includes an actionable remediation message so that
+// problems are reported up-front rather than failing cryptically later.
+package preflight
+
+import "fmt"
+
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) String() string {
+	//exhaustive:enforce
+	switch s {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	}
+
+	panic(fmt.Sprintf("illegal preflight status %d", s))
+}
+
+type (
+	// Result is the outcome of a single check. The Remediation is
+	// only populated if the status of the result is not Pass.
+	Result struct {
+		Name        string
+		Status      Status
+		Message     string
+		Remediation string
+	}
+
+	// Check is a single pre-flight check, which returns a result
+	// describing whether the environment satisfies it.
+	Check func() Result
+)
+
+func pass(name string, message string, args ...any) Result {
+	return Result{Name: name, Status: Pass, Message: fmt.Sprintf(message, args...)}
+}
+
+func warn(name string, remediation string, message string, args ...any) Result {
+	return Result{Name: name, Status: Warn, Message: fmt.Sprintf(message, args...), Remediation: remediation}
+}
+
+func fail(name string, remediation string, message string, args ...any) Result {
+	return Result{Name: name, Status: Fail, Message: fmt.Sprintf(message, args...), Remediation: remediation}
+}
+
+// Run executes all the checks provided, in order, and returns their results.
+func Run(checks ...Check) []Result {
+	results := make([]Result, len(checks))
+	for k, check := range checks {
+		results[k] = check()
+	}
+
+	return results
+}
+
+// AnyFailed returns true if any of the results provided have a Fail status.
+func AnyFailed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+
+	return false
+}
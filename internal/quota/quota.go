@@ -0,0 +1,172 @@
+// Package quota allows admins to cap the number of manual transcode tasks a user may have active
+// at once, the number of tasks they may request within a rolling day, and the number of playback
+// (live-stream) sessions they may have at once. Tasks created by workflows (or batches) are not
+// requested by a user, and so do not count towards any quota.
+package quota
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+// Window is the period over which the daily transcode limit is counted.
+const Window = 24 * time.Hour
+
+type (
+	// Quota is the limits applied to a user. A nil limit is unlimited.
+	Quota struct {
+		UserID                  uuid.UUID  `db:"user_id"`
+		UpdatedAt               *time.Time `db:"updated_at"`
+		MaxConcurrentTranscodes *int       `db:"max_concurrent_transcodes"`
+		MaxDailyTranscodes      *int       `db:"max_daily_transcodes"`
+		MaxConcurrentSessions   *int       `db:"max_concurrent_sessions"`
+	}
+
+	// Usage is the quota of a user, alongside the number of tasks the user has active, the
+	// number they've requested within the daily window, and the number of playback sessions
+	// the user has ongoing.
+	Usage struct {
+		Quota               *Quota
+		ActiveTranscodes    int
+		RequestedTranscodes int
+		ActiveSessions      int
+	}
+
+	Store struct{}
+)
+
+var (
+	// ErrConcurrentLimitReached is returned when a user requests a transcode while
+	// they already have as many active tasks as their quota allows.
+	ErrConcurrentLimitReached = errors.New("concurrent transcode limit reached, wait for an active task to finish")
+
+	// ErrDailyLimitReached is returned when a user has already requested as
+	// many transcodes as their quota allows within the daily window.
+	ErrDailyLimitReached = errors.New("daily transcode limit reached")
+
+	// ErrSessionLimitReached is returned when a user starts a playback session while they
+	// already have as many playback sessions ongoing as their quota allows.
+	ErrSessionLimitReached = errors.New("concurrent playback session limit reached, end an ongoing session first")
+
+	ErrLimitInvalid = errors.New("quota limits must not be negative")
+)
+
+// Validate returns an error if the quota cannot be saved.
+func (quota *Quota) Validate() error {
+	if (quota.MaxConcurrentTranscodes != nil && *quota.MaxConcurrentTranscodes < 0) ||
+		(quota.MaxDailyTranscodes != nil && *quota.MaxDailyTranscodes < 0) ||
+		(quota.MaxConcurrentSessions != nil && *quota.MaxConcurrentSessions < 0) {
+		return ErrLimitInvalid
+	}
+
+	return nil
+}
+
+// Check returns an error if the usage does not permit another transcode to be requested. The
+// daily limit is checked first, as waiting for an active task to finish will not lift it.
+func (usage *Usage) Check() error {
+	if remaining := usage.RemainingDailyTranscodes(); remaining != nil && *remaining == 0 {
+		return ErrDailyLimitReached
+	}
+	if remaining := usage.RemainingConcurrentTranscodes(); remaining != nil && *remaining == 0 {
+		return ErrConcurrentLimitReached
+	}
+
+	return nil
+}
+
+// CheckSession returns an error if the usage does not permit another playback session to be started.
+func (usage *Usage) CheckSession() error {
+	if remaining := usage.RemainingConcurrentSessions(); remaining != nil && *remaining == 0 {
+		return ErrSessionLimitReached
+	}
+
+	return nil
+}
+
+// RemainingConcurrentTranscodes returns the number of additional tasks
+// the user may have active, or nil if the user is not limited.
+func (usage *Usage) RemainingConcurrentTranscodes() *int {
+	return remaining(usage.Quota.MaxConcurrentTranscodes, usage.ActiveTranscodes)
+}
+
+// RemainingDailyTranscodes returns the number of additional tasks the user may
+// request within the daily window, or nil if the user is not limited.
+func (usage *Usage) RemainingDailyTranscodes() *int {
+	return remaining(usage.Quota.MaxDailyTranscodes, usage.RequestedTranscodes)
+}
+
+// RemainingConcurrentSessions returns the number of additional playback
+// sessions the user may start, or nil if the user is not limited.
+func (usage *Usage) RemainingConcurrentSessions() *int {
+	return remaining(usage.Quota.MaxConcurrentSessions, usage.ActiveSessions)
+}
+
+func remaining(limit *int, used int) *int {
+	if limit == nil {
+		return nil
+	}
+
+	r := max(*limit-used, 0)
+	return &r
+}
+
+// Get returns the quota of the user with the ID provided. If the user has
+// no quota, then an unlimited quota is returned.
+func (store *Store) Get(db database.Queryable, userID uuid.UUID) (*Quota, error) {
+	var result Quota
+	if err := db.Get(&result, `SELECT * FROM user_quota WHERE user_id=$1`, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &Quota{UserID: userID}, nil
+		}
+
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Save creates or replaces the quota of the user.
+func (store *Store) Save(db database.Queryable, quota *Quota) error {
+	_, err := db.NamedExec(`
+		INSERT INTO user_quota(user_id, updated_at, max_concurrent_transcodes, max_daily_transcodes, max_concurrent_sessions)
+		VALUES (:user_id, current_timestamp, :max_concurrent_transcodes, :max_daily_transcodes, :max_concurrent_sessions)
+		ON CONFLICT(user_id) DO UPDATE
+		SET (updated_at, max_concurrent_transcodes, max_daily_transcodes, max_concurrent_sessions) =
+			(current_timestamp, EXCLUDED.max_concurrent_transcodes, EXCLUDED.max_daily_transcodes, EXCLUDED.max_concurrent_sessions)
+	`, quota)
+
+	return err
+}
+
+// RecordTranscodeRequest records that the user requested the transcode task with the ID provided. Requests
+// made by the user which have fallen outside of the daily window are pruned, as they're no longer counted.
+func (store *Store) RecordTranscodeRequest(db database.Queryable, userID uuid.UUID, taskID uuid.UUID) error {
+	if _, err := db.Exec(`DELETE FROM user_transcode_request WHERE user_id=$1 AND requested_at < $2`, userID, time.Now().Add(-Window)); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO user_transcode_request(task_id, user_id, requested_at)
+		VALUES ($1, $2, current_timestamp)
+	`, taskID, userID)
+
+	return err
+}
+
+// CountTranscodeRequests returns the number of transcodes the user has requested within the daily window.
+func (store *Store) CountTranscodeRequests(db database.Queryable, userID uuid.UUID) (int, error) {
+	var count int
+	if err := db.Get(&count, `
+		SELECT COUNT(*) FROM user_transcode_request
+		WHERE user_id=$1 AND requested_at >= $2
+	`, userID, time.Now().Add(-Window)); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
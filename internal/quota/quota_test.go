@@ -0,0 +1,55 @@
+package quota
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UsageCheck(t *testing.T) {
+	t.Parallel()
+	limit := func(n int) *int { return &n }
+
+	tests := []struct {
+		name  string
+		usage Usage
+		err   error
+	}{
+		{name: "Unlimited", usage: Usage{Quota: &Quota{}, ActiveTranscodes: 50, RequestedTranscodes: 500}},
+		{name: "WithinLimits", usage: Usage{Quota: &Quota{MaxConcurrentTranscodes: limit(2), MaxDailyTranscodes: limit(5)}, ActiveTranscodes: 1, RequestedTranscodes: 4}},
+		{name: "ConcurrentLimitReached", usage: Usage{Quota: &Quota{MaxConcurrentTranscodes: limit(2)}, ActiveTranscodes: 2}, err: ErrConcurrentLimitReached},
+		{name: "DailyLimitReached", usage: Usage{Quota: &Quota{MaxDailyTranscodes: limit(5)}, RequestedTranscodes: 6}, err: ErrDailyLimitReached},
+		{name: "BothLimitsReached", usage: Usage{Quota: &Quota{MaxConcurrentTranscodes: limit(0), MaxDailyTranscodes: limit(0)}}, err: ErrDailyLimitReached},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.ErrorIs(t, test.usage.Check(), test.err)
+		})
+	}
+}
+
+func Test_UsageRemaining(t *testing.T) {
+	t.Parallel()
+	limit := 3
+
+	usage := Usage{Quota: &Quota{MaxDailyTranscodes: &limit}, ActiveTranscodes: 1, RequestedTranscodes: 5}
+	assert.Nil(t, usage.RemainingConcurrentTranscodes())
+	assert.Equal(t, 0, *usage.RemainingDailyTranscodes(), "remaining quota is never negative")
+
+	assert.NoError(t, (&Quota{MaxDailyTranscodes: new(int)}).Validate())
+	negative := -1
+	assert.ErrorIs(t, (&Quota{MaxConcurrentTranscodes: &negative}).Validate(), ErrLimitInvalid)
+	assert.ErrorIs(t, (&Quota{MaxConcurrentSessions: &negative}).Validate(), ErrLimitInvalid)
+}
+
+func Test_UsageCheckSession(t *testing.T) {
+	t.Parallel()
+	limit := 2
+
+	assert.NoError(t, (&Usage{Quota: &Quota{}, ActiveSessions: 10}).CheckSession())
+	assert.NoError(t, (&Usage{Quota: &Quota{MaxConcurrentSessions: &limit}, ActiveSessions: 1}).CheckSession())
+	assert.ErrorIs(t, (&Usage{Quota: &Quota{MaxConcurrentSessions: &limit}, ActiveSessions: 2}).CheckSession(), ErrSessionLimitReached)
+	assert.NoError(t, (&Usage{Quota: &Quota{MaxConcurrentTranscodes: new(int)}}).CheckSession(), "transcode limits do not apply to sessions")
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type (
+	reclaimStore interface {
+		ListTranscodeReclaimCandidates() ([]*transcode.ReclaimCandidate, error)
+		SetTranscodeLocation(id uuid.UUID, path string, pool string) error
+		DeleteTranscode(id uuid.UUID) error
+	}
+
+	// reclaimJanitor periodically archives, and evicts, the transcodes selected by
+	// the reclaim policy of the transcode config (see transcode.ReclaimConfig).
+	reclaimJanitor struct {
+		config transcode.Config
+		store  reclaimStore
+	}
+)
+
+func newReclaimJanitor(config transcode.Config, store reclaimStore) *reclaimJanitor {
+	return &reclaimJanitor{config: config, store: store}
+}
+
+func (janitor *reclaimJanitor) Run(ctx context.Context) error {
+	policy := janitor.config.Reclaim
+	if policy.Interval <= 0 || (policy.ArchivePool == "" && policy.MaxStorageGB == 0) {
+		log.Emit(logger.INFO, "Transcode archival and eviction are disabled, transcodes will be retained until deleted\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+
+	log.Emit(logger.NEW, "Reclaim janitor started (archive_pool=%q, max_storage_gb=%d)\n", policy.ArchivePool, policy.MaxStorageGB)
+	for {
+		janitor.reclaim()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Emit(logger.STOP, "Reclaim janitor closed\n")
+			return nil
+		}
+	}
+}
+
+// reclaim archives, and then evicts, the transcodes selected by the reclaim policy. Failures
+// are logged, and will be retried on the next tick.
+func (janitor *reclaimJanitor) reclaim() {
+	candidates, err := janitor.store.ListTranscodeReclaimCandidates()
+	if err != nil {
+		log.Errorf("Failed to list transcodes to reclaim: %v\n", err)
+		return
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate.Path); err == nil {
+			candidate.Size = info.Size()
+		} else {
+			log.Warnf("Failed to stat output of transcode %s at '%s': %v\n", candidate.ID, candidate.Path, err)
+		}
+	}
+
+	now := time.Now()
+	archived := 0
+	for _, candidate := range janitor.config.Reclaim.SelectArchivals(candidates, now) {
+		if err := janitor.archive(candidate); err != nil {
+			log.Errorf("Failed to archive transcode %s: %v\n", candidate.ID, err)
+			continue
+		}
+
+		archived++
+	}
+
+	evicted := 0
+	for _, candidate := range janitor.config.Reclaim.SelectEvictions(candidates, now) {
+		if err := janitor.store.DeleteTranscode(candidate.ID); err != nil {
+			log.Errorf("Failed to evict transcode %s: %v\n", candidate.ID, err)
+			continue
+		}
+
+		evicted++
+	}
+
+	if archived > 0 || evicted > 0 {
+		log.Emit(logger.REMOVE, "Reclaimed transcode storage: archived %d and evicted %d transcode(s)\n", archived, evicted)
+	}
+}
+
+// archive moves the output file of the candidate in to the archive pool, retaining it's path
+// relative to the pool it was written to.
+func (janitor *reclaimJanitor) archive(candidate *transcode.ReclaimCandidate) error {
+	archive := janitor.config.GetStoragePool(janitor.config.Reclaim.ArchivePool)
+	if archive == nil {
+		return fmt.Errorf("archive pool '%s' is not configured", janitor.config.Reclaim.ArchivePool)
+	}
+	pool := janitor.config.GetStoragePool(candidate.Pool)
+	if pool == nil {
+		return fmt.Errorf("storage pool '%s' of the transcode is no longer configured", candidate.Pool)
+	}
+
+	relative, err := filepath.Rel(pool.Path, candidate.Path)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return fmt.Errorf("output '%s' is not inside of storage pool '%s'", candidate.Path, pool.Name)
+	}
+
+	destination := filepath.Join(archive.Path, relative)
+	if err := moveFile(candidate.Path, destination); err != nil {
+		return err
+	}
+	if err := janitor.store.SetTranscodeLocation(candidate.ID, destination, archive.Name); err != nil {
+		if moveErr := moveFile(destination, candidate.Path); moveErr != nil {
+			return fmt.Errorf("%w (and the output could not be moved back to '%s': %w)", err, candidate.Path, moveErr)
+		}
+
+		return err
+	}
+
+	candidate.Path = destination
+	candidate.Pool = archive.Name
+	return nil
+}
+
+// moveFile moves the file at the source path to the destination, creating the destination's
+// directory if required. Files are copied (and the source removed) when moving across devices.
+func moveFile(source string, destination string) error {
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		return err
+	}
+
+	err := os.Rename(source, destination)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(destination)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(destination)
+		return err
+	}
+
+	return os.Remove(source)
+}
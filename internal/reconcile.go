@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/http/tmdb"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type (
+	reconcileStore interface {
+		ListUnverifiedMovies() ([]*media.Movie, error)
+		ListUnverifiedEpisodes() ([]*media.Episode, error)
+		ReconcileMovie(movieID uuid.UUID, movie *media.Movie) error
+		ReconcileEpisode(episodeID uuid.UUID, episode *media.Episode, season *media.Season, series *media.Series) error
+	}
+
+	reconcileSearcher interface {
+		BreakerStatus() tmdb.BreakerStatus
+		SearchForMovie(metadata *media.FileMediaMetadata) (string, error)
+		SearchForSeries(metadata *media.FileMediaMetadata) (string, error)
+		GetMovie(movieID string) (*tmdb.Movie, error)
+		GetSeries(seriesID string) (*tmdb.Series, error)
+		GetSeason(seriesID string, seasonNumber int) (*tmdb.Season, error)
+		GetEpisode(seriesID string, seasonNumber int, episodeNumber int) (*tmdb.Episode, error)
+	}
+
+	// unverifiedReconciler periodically searches TMDB for the media which was ingested while
+	// TMDB was unavailable (see ingest.Config.OfflineMode), upgrading the unverified media
+	// using the metadata found in TMDB once it is available.
+	unverifiedReconciler struct {
+		config   ReconcileConfig
+		searcher reconcileSearcher
+		store    reconcileStore
+	}
+)
+
+func newUnverifiedReconciler(config ReconcileConfig, searcher reconcileSearcher, store reconcileStore) *unverifiedReconciler {
+	return &unverifiedReconciler{config: config, searcher: searcher, store: store}
+}
+
+func (reconciler *unverifiedReconciler) Run(ctx context.Context) error {
+	if reconciler.config.Interval <= 0 {
+		log.Emit(logger.WARNING, "Reconcile interval is not positive, unverified media will not be reconciled with TMDB\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(reconciler.config.Interval)
+	defer ticker.Stop()
+
+	log.Emit(logger.NEW, "Unverified media reconciler started (interval=%s)\n", reconciler.config.Interval)
+	for {
+		reconciler.reconcile(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Emit(logger.STOP, "Unverified media reconciler closed\n")
+			return nil
+		}
+	}
+}
+
+// reconcile attempts to upgrade all unverified media. The pass is abandoned if TMDB is
+// (or becomes) unavailable, and the remaining media will be retried on the next tick.
+// Other failures are logged, and the affected media retried on the next tick.
+func (reconciler *unverifiedReconciler) reconcile(ctx context.Context) {
+	if reconciler.searcher.BreakerStatus().State == tmdb.BreakerOpen {
+		log.Emit(logger.DEBUG, "Skipping reconciliation of unverified media as TMDB is unavailable\n")
+		return
+	}
+
+	movies, err := reconciler.store.ListUnverifiedMovies()
+	if err != nil {
+		log.Errorf("Failed to list unverified movies for reconciliation: %v\n", err)
+		return
+	}
+
+	episodes, err := reconciler.store.ListUnverifiedEpisodes()
+	if err != nil {
+		log.Errorf("Failed to list unverified episodes for reconciliation: %v\n", err)
+		return
+	}
+
+	if len(movies) == 0 && len(episodes) == 0 {
+		return
+	}
+
+	reconciled := 0
+	for _, movie := range movies {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := reconciler.reconcileMovie(movie); err != nil {
+			if errors.Is(err, tmdb.ErrUnavailable) {
+				log.Warnf("Abandoning reconciliation of unverified media as TMDB is unavailable: %v\n", err)
+				return
+			}
+
+			log.Warnf("Failed to reconcile unverified movie %s: %v\n", movie.ID, err)
+			continue
+		}
+		reconciled++
+	}
+
+	for _, episode := range episodes {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := reconciler.reconcileEpisode(episode); err != nil {
+			if errors.Is(err, tmdb.ErrUnavailable) {
+				log.Warnf("Abandoning reconciliation of unverified media as TMDB is unavailable: %v\n", err)
+				return
+			}
+
+			log.Warnf("Failed to reconcile unverified episode %s: %v\n", episode.ID, err)
+			continue
+		}
+		reconciled++
+	}
+
+	log.Emit(logger.INFO, "Reconciled %d/%d unverified media with TMDB\n", reconciled, len(movies)+len(episodes))
+}
+
+func (reconciler *unverifiedReconciler) reconcileMovie(unverified *media.Movie) error {
+	meta, err := unverifiedMetadata(&unverified.Watchable)
+	if err != nil {
+		return err
+	}
+
+	movieID, err := reconciler.searcher.SearchForMovie(meta)
+	if err != nil {
+		return err
+	}
+
+	found, err := reconciler.searcher.GetMovie(movieID)
+	if err != nil {
+		return err
+	}
+
+	movie := tmdb.TmdbMovieToMedia(found, meta)
+	movie.Watchable = watchableWithAdult(unverified.Watchable, found.Adult)
+	return reconciler.store.ReconcileMovie(unverified.ID, movie)
+}
+
+func (reconciler *unverifiedReconciler) reconcileEpisode(unverified *media.Episode) error {
+	meta, err := unverifiedMetadata(&unverified.Watchable)
+	if err != nil {
+		return err
+	}
+
+	seriesID, err := reconciler.searcher.SearchForSeries(meta)
+	if err != nil {
+		return err
+	}
+
+	series, err := reconciler.searcher.GetSeries(seriesID)
+	if err != nil {
+		return err
+	}
+
+	season, err := reconciler.searcher.GetSeason(series.ID.String(), meta.SeasonNumber)
+	if err != nil {
+		return err
+	}
+
+	found, err := reconciler.searcher.GetEpisode(series.ID.String(), meta.SeasonNumber, meta.EpisodeNumber)
+	if err != nil {
+		return err
+	}
+
+	episode := tmdb.TmdbEpisodeToMedia(found, series.Adult, meta)
+	episode.Watchable = watchableWithAdult(unverified.Watchable, series.Adult)
+	return reconciler.store.ReconcileEpisode(unverified.ID, episode, tmdb.TmdbSeasonToMedia(season), tmdb.TmdbSeriesToMedia(series))
+}
+
+// unverifiedMetadata reconstructs the metadata of unverified media (which was parsed
+// from the filename of it's source) so that it can be searched for in TMDB.
+func unverifiedMetadata(watchable *media.Watchable) (*media.FileMediaMetadata, error) {
+	parsed, err := media.ParseFilename(filepath.Base(watchable.SourcePath))
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &media.FileMediaMetadata{
+		Title:    parsed.Title,
+		Year:     parsed.Year,
+		Episodic: parsed.Episodic(),
+		Path:     watchable.SourcePath,
+		Filename: parsed,
+	}
+	if meta.Episodic {
+		meta.SeasonNumber = parsed.Season
+		meta.EpisodeNumber = parsed.Episodes[0]
+	}
+
+	return meta, nil
+}
+
+// watchableWithAdult returns a copy of the watchable provided with it's adult flag
+// set, so that the information about the source file is retained when reconciled.
+func watchableWithAdult(watchable media.Watchable, adult bool) media.Watchable {
+	watchable.Adult = adult
+	return watchable
+}
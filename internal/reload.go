@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/reload"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// hotReloadableOptions are the configuration options (named by their TOML keys) which are
+// applied to the running services when the configuration is reloaded. Changes to any other
+// option only take effect once Thea is restarted.
+var hotReloadableOptions = []string{
+	"log_level",
+	"tmdb_api_key",
+	"notifications",
+	"transcode.max_thread_consumption",
+	"transcode.minimum_free_space_mb",
+	"transcode.stall_timeout",
+	"transcode.log_size_kb",
+	"transcode.retries",
+	"transcode.hooks",
+	"ingestion.parallelism",
+}
+
+var ErrNotRunning = errors.New("thea services are not running")
+
+// ReloadConfig re-reads the configuration file Thea was started with, and applies the changes
+// to the options which do not require a restart (see hotReloadableOptions) to the running services.
+// If the new configuration is invalid, an error is returned and no changes are applied. The report
+// returned describes which options changed, and which of those require Thea to be restarted.
+func (thea *theaImpl) ReloadConfig() (*reload.Report, error) {
+	if !thea.running.Load() {
+		return nil, ErrNotRunning
+	}
+
+	thea.reloadMutex.Lock()
+	defer thea.reloadMutex.Unlock()
+
+	updated := TheaConfig{}
+	if err := updated.LoadFromFile(thea.config.path); err != nil {
+		return nil, err
+	}
+
+	// Options provided as flags are not present in the file
+	updated.Backup.RestoreFrom = thea.config.Backup.RestoreFrom
+
+	// Validate the new configuration before applying any of it, so that an
+	// invalid configuration does not result in a partial reload.
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+	if err := thea.notifications.ApplyConfig(updated.Notifications); err != nil {
+		return nil, fmt.Errorf("failed to apply notification config: %w", err)
+	}
+
+	if updated.LogLevel != "" {
+		level, _ := logger.ParseLevel(updated.LogLevel)
+		logger.SetMinLoggingLevel(level)
+	}
+	if updated.IngestService.IngestionParallelism != thea.config.IngestService.IngestionParallelism {
+		if err := thea.ingestService.SetParallelism(updated.IngestService.IngestionParallelism); err != nil {
+			return nil, fmt.Errorf("failed to apply ingest parallelism: %w", err)
+		}
+	}
+	tmdbConfig := updated.Tmdb
+	tmdbConfig.APIKey = updated.TmdbKey
+	thea.searcher.ApplyConfig(tmdbConfig)
+	thea.transcodeService.ApplyConfig(updated.Format)
+
+	report := reload.Diff(thea.config, updated, hotReloadableOptions)
+	thea.config.LogLevel = updated.LogLevel
+	thea.config.TmdbKey = updated.TmdbKey
+	thea.config.Notifications = updated.Notifications
+	thea.config.Format.MaximumThreadConsumption = updated.Format.MaximumThreadConsumption
+	thea.config.Format.MinimumFreeSpaceMB = updated.Format.MinimumFreeSpaceMB
+	thea.config.Format.StallTimeout = updated.Format.StallTimeout
+	thea.config.Format.LogSizeKB = updated.Format.LogSizeKB
+	thea.config.Format.Retries = updated.Format.Retries
+	thea.config.Format.Hooks = updated.Format.Hooks
+	thea.config.IngestService.IngestionParallelism = updated.IngestService.IngestionParallelism
+
+	log.Emit(logger.SUCCESS, "Configuration reloaded from '%s' (applied: [%s])\n", thea.config.path, strings.Join(report.Applied, ", "))
+	if len(report.RequiresRestart) > 0 {
+		log.Warnf("Configuration options changed which require Thea to be restarted: [%s]\n", strings.Join(report.RequiresRestart, ", "))
+	}
+
+	return report, nil
+}
@@ -0,0 +1,57 @@
+// Package reload determines which configuration options changed when Thea's configuration
+// is re-read, and which of those changes can be applied without restarting Thea.
+package reload
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Report describes the options which changed when the configuration was reloaded. Options
+// are named using their (dot-separated) TOML keys, e.g. 'transcode.max_thread_consumption'.
+type Report struct {
+	// Applied are the options which changed, and have been applied to the running services.
+	Applied []string
+	// RequiresRestart are the options which changed, but which only take effect once Thea restarts.
+	RequiresRestart []string
+}
+
+// Diff compares two configurations (which must be structs of the same type), returning the options
+// which differ. Options in hot (or nested within an option in hot) are reported as Applied, and all
+// other options as RequiresRestart. Values are never included in the report, as options may be secret.
+func Diff(current any, updated any, hot []string) *Report {
+	report := &Report{Applied: make([]string, 0), RequiresRestart: make([]string, 0)}
+	diffStruct(report, "", reflect.ValueOf(current), reflect.ValueOf(updated), hot)
+
+	return report
+}
+
+func diffStruct(report *Report, prefix string, current reflect.Value, updated reflect.Value, hot []string) {
+	for i := range current.NumField() {
+		field := current.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		key := prefix + name
+
+		a, b := current.Field(i), updated.Field(i)
+		switch {
+		case reflect.DeepEqual(a.Interface(), b.Interface()):
+			continue
+		case slices.Contains(hot, key):
+			report.Applied = append(report.Applied, key)
+		case a.Kind() == reflect.Struct:
+			diffStruct(report, key+".", a, b, hot)
+		default:
+			report.RequiresRestart = append(report.RequiresRestart, key)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package reload_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/reload"
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	transcodeConfig struct {
+		OutputPath string        `toml:"default_output_dir"`
+		Threads    int           `toml:"max_thread_consumption"`
+		Timeout    time.Duration `toml:"stall_timeout"`
+	}
+
+	config struct {
+		Transcode transcodeConfig `toml:"transcode"`
+		APIKey    string          `toml:"tmdb_api_key"`
+		Hooks     []string        `toml:"hooks"`
+		path      string
+	}
+)
+
+func Test_DiffPartitionsChangedOptions(t *testing.T) {
+	current := config{Transcode: transcodeConfig{OutputPath: "/a", Threads: 4, Timeout: time.Minute}, APIKey: "key", path: "a"}
+	updated := config{Transcode: transcodeConfig{OutputPath: "/b", Threads: 8, Timeout: time.Minute}, APIKey: "new-key", Hooks: []string{"x"}, path: "b"}
+
+	report := reload.Diff(current, updated, []string{"transcode.max_thread_consumption", "tmdb_api_key"})
+	assert.Equal(t, []string{"transcode.max_thread_consumption", "tmdb_api_key"}, report.Applied)
+	assert.Equal(t, []string{"transcode.default_output_dir", "hooks"}, report.RequiresRestart)
+
+	report = reload.Diff(current, current, nil)
+	assert.Empty(t, report.Applied)
+	assert.Empty(t, report.RequiresRestart)
+}
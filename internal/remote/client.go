@@ -0,0 +1,81 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dialTimeout is the maximum time allowed to establish a connection to a remote source.
+const dialTimeout = 30 * time.Second
+
+var ErrURLInvalid = errors.New("url is invalid")
+
+// client lists and downloads the files within the directory of a remote source.
+type client interface {
+	// List returns the files beneath the directory of the source, recursively.
+	List(ctx context.Context) ([]File, error)
+
+	// Download downloads the file at the path provided (relative to the
+	// directory of the source) to the local path provided.
+	Download(ctx context.Context, path string, localPath string) error
+
+	Close() error
+}
+
+// newClient connects to the source provided, using the password provided (if any).
+func newClient(ctx context.Context, config Config, source *Source, password string) (client, error) {
+	u, err := parseSourceURL(source)
+	if err != nil {
+		return nil, err
+	}
+
+	switch source.Protocol {
+	case SFTP:
+		return dialSFTP(ctx, u, source.Username, password, source.HostKeyFingerprint)
+	case SMB:
+		return newSMBClient(config.SmbclientBinaryPath, u, source.Username, password), nil
+	case WebDAV:
+		return newWebDAVClient(u, source.Username, password), nil
+	}
+
+	return nil, ErrProtocolInvalid
+}
+
+// parseSourceURL parses the URL of the source, ensuring that the scheme matches the protocol of
+// the source. Credentials must not be included in the URL, as the URL is stored unencrypted.
+func parseSourceURL(source *Source) (*url.URL, error) {
+	u, err := url.Parse(source.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrURLInvalid, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: a host is required", ErrURLInvalid)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: credentials must be provided separately, not as part of the url", ErrURLInvalid)
+	}
+
+	switch source.Protocol {
+	case SFTP:
+		if u.Scheme != "sftp" {
+			return nil, fmt.Errorf("%w: sftp sources must use the 'sftp' scheme", ErrURLInvalid)
+		}
+	case SMB:
+		if u.Scheme != "smb" {
+			return nil, fmt.Errorf("%w: smb sources must use the 'smb' scheme", ErrURLInvalid)
+		}
+		if share, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/"); share == "" {
+			return nil, fmt.Errorf("%w: smb sources must include the share, e.g. 'smb://host/share'", ErrURLInvalid)
+		}
+	case WebDAV:
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("%w: webdav sources must use the 'http' or 'https' scheme", ErrURLInvalid)
+		}
+	}
+
+	return u, nil
+}
@@ -0,0 +1,468 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/credential"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// syncQueueSize is the number of manually requested syncs which can be queued at once.
+const syncQueueSize = 16
+
+var (
+	log = logger.Get("Remote")
+
+	ErrSyncInProgress       = errors.New("a sync of this remote source is already queued or in progress")
+	ErrCredentialKeyMissing = errors.New("a credential key must be configured to store the passwords of remote sources")
+	ErrTargetExists         = errors.New("a file already exists in the ingest directory at the path of the remote file")
+)
+
+type (
+	Config struct {
+		// StagingPath is the directory files are downloaded to, before being moved in to
+		// the ingest directory once complete. Defaults to a directory inside of the cache
+		// directory. For the move to be atomic, this should be on the same volume as the
+		// ingest directory.
+		StagingPath string `toml:"staging_dir" env:"REMOTE_STAGING_DIR"`
+
+		// CredentialKey is used to encrypt the passwords of remote sources. If the key is
+		// changed, the passwords of existing sources must be provided again.
+		CredentialKey string `toml:"credential_key" env:"REMOTE_CREDENTIAL_KEY"`
+
+		SmbclientBinaryPath string `toml:"smbclient_binary_path" env:"REMOTE_SMBCLIENT_BINARY_PATH" env-default:"/usr/bin/smbclient"`
+
+		// CheckInterval controls how often the sources are checked to see if
+		// they are due to be synced (according to their own sync interval).
+		CheckInterval time.Duration `toml:"check_interval" env:"REMOTE_CHECK_INTERVAL" env-default:"1m"`
+
+		// MinimumFileAge is how long ago a remote file must have last been modified before
+		// it's fetched, as it may still be being written to the remote location.
+		MinimumFileAge time.Duration `toml:"minimum_file_age" env:"REMOTE_MINIMUM_FILE_AGE" env-default:"2m"`
+	}
+
+	DataStore interface {
+		GetRemoteSource(id uuid.UUID) (*Source, error)
+		GetAllRemoteSources() ([]*Source, error)
+		SaveRemoteSource(source *Source) error
+		DeleteRemoteSource(id uuid.UUID) error
+		GetRemoteSourceFiles(sourceID uuid.UUID) ([]*File, error)
+		SaveRemoteSourceFile(file *File) error
+		SaveRemoteSourceSyncResult(id uuid.UUID, syncedAt time.Time, lastError *string) error
+	}
+
+	// SourceUpdate describes the changes to make to an existing source. Nil fields are
+	// left unchanged, and an empty password removes the password of the source. The
+	// protocol of a source cannot be changed.
+	SourceUpdate struct {
+		Label               *string
+		URL                 *string
+		Username            *string
+		Password            *string
+		HostKeyFingerprint  *string
+		SyncIntervalSeconds *int
+		Enabled             *bool
+	}
+
+	// Service periodically syncs each enabled remote source, fetching the files which are new (or
+	// modified) since the last sync in to the ingest directory. The files of each source are
+	// placed in a directory named after the label of the source, preserving the structure
+	// of the remote directory. Sources are synced one at a time.
+	Service struct {
+		config     Config
+		ingestPath string
+		store      DataStore
+		cipher     *credential.Cipher
+
+		syncRequests chan uuid.UUID
+		syncingMutex sync.Mutex
+		syncing      map[uuid.UUID]struct{}
+	}
+)
+
+func New(config Config, ingestPath string, store DataStore) *Service {
+	service := &Service{
+		config:       config,
+		ingestPath:   ingestPath,
+		store:        store,
+		syncRequests: make(chan uuid.UUID, syncQueueSize),
+		syncing:      make(map[uuid.UUID]struct{}),
+	}
+
+	// Without a credential key, only sources which do not require a password can be used
+	if cipher, err := credential.NewCipher(config.CredentialKey); err == nil {
+		service.cipher = cipher
+	}
+
+	return service
+}
+
+// Run is the main entry point for this service. Sources are synced as they become due,
+// and when requested (see RequestSync), until the context provided is cancelled.
+func (service *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(service.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			service.syncDueSources(ctx)
+		case sourceID := <-service.syncRequests:
+			if source, err := service.store.GetRemoteSource(sourceID); err == nil {
+				service.sync(ctx, source)
+			} else {
+				log.Warnf("Unable to sync remote source %s: %v\n", sourceID, err)
+				service.clearSyncing(sourceID)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// CreateSource validates and saves the source provided, encrypting the password provided (if any).
+func (service *Service) CreateSource(source *Source, password string) error {
+	source.ID = uuid.New()
+	if err := service.setPassword(source, password); err != nil {
+		return err
+	}
+	if err := source.Validate(); err != nil {
+		return err
+	}
+
+	return service.store.SaveRemoteSource(source)
+}
+
+// UpdateSource applies the update provided to the source with the ID provided, returning the updated source.
+func (service *Service) UpdateSource(id uuid.UUID, update SourceUpdate) (*Source, error) {
+	source, err := service.store.GetRemoteSource(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Label != nil {
+		source.Label = *update.Label
+	}
+	if update.URL != nil {
+		source.URL = *update.URL
+	}
+	if update.Username != nil {
+		source.Username = *update.Username
+	}
+	if update.HostKeyFingerprint != nil {
+		source.HostKeyFingerprint = *update.HostKeyFingerprint
+	}
+	if update.SyncIntervalSeconds != nil {
+		source.SyncIntervalSeconds = *update.SyncIntervalSeconds
+	}
+	if update.Enabled != nil {
+		source.Enabled = *update.Enabled
+	}
+	if update.Password != nil {
+		if err := service.setPassword(source, *update.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := source.Validate(); err != nil {
+		return nil, err
+	}
+	if err := service.store.SaveRemoteSource(source); err != nil {
+		return nil, err
+	}
+
+	return service.store.GetRemoteSource(id)
+}
+
+func (service *Service) GetSource(id uuid.UUID) (*Source, error) {
+	return service.store.GetRemoteSource(id)
+}
+
+func (service *Service) ListSources() ([]*Source, error) {
+	return service.store.GetAllRemoteSources()
+}
+
+// DeleteSource deletes the source with the ID provided. Files already fetched
+// from the source are not removed from the ingest directory.
+func (service *Service) DeleteSource(id uuid.UUID) error {
+	if err := service.store.DeleteRemoteSource(id); err != nil {
+		return err
+	}
+
+	_ = os.RemoveAll(service.stagingDir(id))
+	return nil
+}
+
+// RequestSync queues a sync of the source with the ID provided, regardless of whether the
+// source is enabled or due to be synced. If a sync of the source is already queued or in
+// progress, ErrSyncInProgress is returned.
+func (service *Service) RequestSync(id uuid.UUID) error {
+	if _, err := service.store.GetRemoteSource(id); err != nil {
+		return err
+	}
+	if !service.markSyncing(id) {
+		return ErrSyncInProgress
+	}
+
+	select {
+	case service.syncRequests <- id:
+		return nil
+	default:
+		service.clearSyncing(id)
+		return ErrSyncInProgress
+	}
+}
+
+func (service *Service) syncDueSources(ctx context.Context) {
+	sources, err := service.store.GetAllRemoteSources()
+	if err != nil {
+		log.Errorf("Failed to retrieve remote sources: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			return
+		}
+		if source.isDue(now) && service.markSyncing(source.ID) {
+			service.sync(ctx, source)
+		}
+	}
+}
+
+// sync syncs the source provided, recording the outcome against the source. The
+// source must have been marked as syncing, and is unmarked once complete.
+func (service *Service) sync(ctx context.Context, source *Source) {
+	defer service.clearSyncing(source.ID)
+
+	log.Emit(logger.INFO, "Syncing %s\n", source)
+	fetched, err := service.fetchNewFiles(ctx, source)
+	if ctx.Err() != nil {
+		// Thea is shutting down, the source will be synced again once restarted
+		return
+	}
+
+	var lastError *string
+	if err != nil {
+		log.Warnf("Sync of %s failed (fetched %d files): %v\n", source, fetched, err)
+		message := err.Error()
+		lastError = &message
+	} else {
+		log.Emit(logger.SUCCESS, "Synced %s (fetched %d files)\n", source, fetched)
+	}
+
+	if err := service.store.SaveRemoteSourceSyncResult(source.ID, time.Now(), lastError); err != nil {
+		log.Errorf("Failed to save outcome of sync of %s: %v\n", source, err)
+	}
+}
+
+// fetchNewFiles lists the files within the source, fetching those which have not been fetched
+// before (or have been modified since), returning the number of files fetched. A failure to fetch
+// one file does not prevent the remaining files from being fetched, and the errors are returned
+// together once all files have been attempted.
+func (service *Service) fetchNewFiles(ctx context.Context, source *Source) (int, error) {
+	password := ""
+	if len(source.EncryptedPassword) > 0 {
+		if service.cipher == nil {
+			return 0, ErrCredentialKeyMissing
+		}
+
+		decrypted, err := service.cipher.Decrypt(source.EncryptedPassword)
+		if err != nil {
+			return 0, err
+		}
+		password = decrypted
+	}
+
+	conn, err := newClient(ctx, service.config, source, password)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	remoteFiles, err := conn.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	previous, err := service.store.GetRemoteSourceFiles(source.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve previously fetched files: %w", err)
+	}
+	fetchedFiles := make(map[string]*File, len(previous))
+	for _, file := range previous {
+		fetchedFiles[file.Path] = file
+	}
+
+	// Any files left in the staging directory are from an interrupted sync
+	stagingDir := service.stagingDir(source.ID)
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return 0, fmt.Errorf("failed to clean staging directory: %w", err)
+	}
+	if err := os.MkdirAll(stagingDir, 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	now := time.Now()
+	count := 0
+	errs := make([]error, 0)
+	for _, file := range remoteFiles {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		if prev, ok := fetchedFiles[file.Path]; ok && prev.Size == file.Size && prev.ModifiedAt.Equal(file.ModifiedAt) {
+			continue
+		}
+		if now.Sub(file.ModifiedAt) < service.config.MinimumFileAge {
+			log.Debugf("Remote file '%s' of %s was modified recently, deferring fetch until a later sync\n", file.Path, source)
+			continue
+		}
+
+		file.SourceID = source.ID
+		if err := service.fetchFile(ctx, conn, source, stagingDir, file); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Path, err))
+			continue
+		}
+		count++
+	}
+
+	return count, errors.Join(errs...)
+}
+
+// fetchFile downloads the file provided to the staging directory, before moving it in to the
+// ingest directory. The modification time of the file is set to that of the remote file, so
+// that the ingest service does not wait for the file to settle before ingesting it.
+func (service *Service) fetchFile(ctx context.Context, conn client, source *Source, stagingDir string, file File) error {
+	target, err := service.targetPath(source, file.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(target); err == nil {
+		return ErrTargetExists
+	}
+
+	staged := filepath.Join(stagingDir, uuid.NewString()+".part")
+	if err := conn.Download(ctx, file.Path, staged); err != nil {
+		_ = os.Remove(staged)
+		return err
+	}
+	if !file.ModifiedAt.IsZero() {
+		if err := os.Chtimes(staged, file.ModifiedAt, file.ModifiedAt); err != nil {
+			log.Warnf("Failed to set modification time of '%s': %v\n", staged, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		_ = os.Remove(staged)
+		return err
+	}
+	if err := moveFile(staged, target); err != nil {
+		_ = os.Remove(staged)
+		return fmt.Errorf("failed to move file in to ingest directory: %w", err)
+	}
+
+	log.Emit(logger.NEW, "Fetched '%s' from %s\n", file.Path, source)
+	return service.store.SaveRemoteSourceFile(&file)
+}
+
+// targetPath returns the path in the ingest directory which the remote file with the path provided
+// should be moved to. An error is returned if the path would be outside of the directory of the source.
+func (service *Service) targetPath(source *Source, remotePath string) (string, error) {
+	sourceDir := filepath.Join(service.ingestPath, source.Label)
+	target := filepath.Join(sourceDir, filepath.FromSlash(remotePath))
+	if !strings.HasPrefix(target, sourceDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("remote path '%s' is outside of the directory of the source", remotePath)
+	}
+
+	return target, nil
+}
+
+func (service *Service) stagingDir(sourceID uuid.UUID) string {
+	return filepath.Join(service.config.StagingPath, sourceID.String())
+}
+
+func (service *Service) setPassword(source *Source, password string) error {
+	if password == "" {
+		source.EncryptedPassword = nil
+		return nil
+	}
+	if service.cipher == nil {
+		return ErrCredentialKeyMissing
+	}
+
+	encrypted, err := service.cipher.Encrypt(password)
+	if err != nil {
+		return err
+	}
+
+	source.EncryptedPassword = encrypted
+	return nil
+}
+
+// markSyncing marks the source with the ID provided as syncing, returning false if it's already syncing.
+func (service *Service) markSyncing(id uuid.UUID) bool {
+	service.syncingMutex.Lock()
+	defer service.syncingMutex.Unlock()
+
+	if _, ok := service.syncing[id]; ok {
+		return false
+	}
+
+	service.syncing[id] = struct{}{}
+	return true
+}
+
+func (service *Service) clearSyncing(id uuid.UUID) {
+	service.syncingMutex.Lock()
+	defer service.syncingMutex.Unlock()
+
+	delete(service.syncing, id)
+}
+
+// moveFile moves the file at the source path to the target path, without replacing an existing file. If the
+// paths are on different volumes the file is copied instead, in which case the move is not atomic.
+func moveFile(sourcePath string, targetPath string) error {
+	if _, err := os.Lstat(targetPath); err == nil {
+		return ErrTargetExists
+	}
+
+	err := os.Rename(sourcePath, targetPath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(targetPath, source); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			_ = os.Remove(targetPath)
+		}
+		return err
+	}
+	if err := os.Chtimes(targetPath, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Remove(sourcePath)
+}
@@ -0,0 +1,140 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrHostKeyMismatch = errors.New("host key of the server does not match the fingerprint of the source")
+
+// sftpClient adapts an SFTP client to the client interface of a remote source,
+// listing and downloading the files within the root directory of the source.
+type sftpClient struct {
+	conn      *ssh.Client
+	sftp      *sftp.Client
+	root      string
+	stopWatch func() bool
+}
+
+// dialSFTP connects to the SFTP server at the URL provided, authenticating using the username and
+// password provided. The host key presented by the server must match the fingerprint provided. The
+// connection is closed if the context is cancelled.
+func dialSFTP(ctx context.Context, u *url.URL, username string, password string, fingerprint string) (*sftpClient, error) {
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	config := &ssh.ClientConfig{
+		User: username,
+		Auth: []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			if actual := ssh.FingerprintSHA256(key); actual != fingerprint {
+				return fmt.Errorf("%w (server presented %s)", ErrHostKeyMismatch, actual)
+			}
+			return nil
+		},
+		Timeout: dialTimeout,
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to '%s': %w", address, err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, address, config)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("ssh handshake with '%s' failed: %w", address, err)
+	}
+
+	client := &sftpClient{conn: ssh.NewClient(sshConn, chans, reqs), root: u.Path}
+	if client.root == "" {
+		client.root = "."
+	}
+	client.stopWatch = context.AfterFunc(ctx, func() { _ = client.conn.Close() })
+
+	client.sftp, err = sftp.NewClient(client.conn)
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to start sftp subsystem: %w", err)
+	}
+
+	return client, nil
+}
+
+func (c *sftpClient) List(ctx context.Context) ([]File, error) {
+	files := make([]File, 0)
+	walker := c.sftp.Walk(c.root)
+	for walker.Step() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := walker.Err(); err != nil {
+			return nil, fmt.Errorf("failed to walk '%s': %w", walker.Path(), err)
+		}
+
+		info := walker.Stat()
+		if info.IsDir() {
+			continue
+		}
+
+		relative, err := relativePath(c.root, walker.Path())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: relative, Size: info.Size(), ModifiedAt: info.ModTime()})
+	}
+
+	return files, nil
+}
+
+func (c *sftpClient) Download(_ context.Context, filePath string, localPath string) error {
+	// Cancellation of the context closes the connection (see dialSFTP), which aborts the transfer.
+	f, err := c.sftp.Open(path.Join(c.root, filePath))
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", filePath, err)
+	}
+
+	err = writeFile(localPath, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (c *sftpClient) Close() error {
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+	if c.sftp != nil {
+		_ = c.sftp.Close()
+	}
+
+	return c.conn.Close()
+}
+
+// relativePath returns the walked path provided relative to the root of the source.
+func relativePath(root string, p string) (string, error) {
+	root = path.Clean(root)
+	if root == "." {
+		return path.Clean(p), nil
+	}
+
+	relative, ok := strings.CutPrefix(path.Clean(p), strings.TrimSuffix(root, "/")+"/")
+	if !ok {
+		return "", fmt.Errorf("walked path '%s' is not beneath root '%s'", p, root)
+	}
+
+	return relative, nil
+}
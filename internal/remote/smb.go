@@ -0,0 +1,144 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smbListPattern matches the entries printed by the 'ls' command of smbclient, e.g.
+//
+//	movie.mkv                           A 1073741824  Mon Jan  1 12:00:00 2024
+var smbListPattern = regexp.MustCompile(`^\s+(.+?)\s+([A-Z]*)\s+(\d+)\s+(\w{3} \w{3} [ \d]\d \d\d:\d\d:\d\d \d{4})$`)
+
+var errSMBPathUnsupported = errors.New("path contains characters which cannot be passed to smbclient")
+
+// smbClient lists and downloads files from an SMB share using the smbclient
+// binary, as there is no SMB implementation available to Thea. A new
+// smbclient process is spawned for each command.
+type smbClient struct {
+	binaryPath string
+	service    string
+	port       string
+	root       string
+	username   string
+	password   string
+}
+
+func newSMBClient(binaryPath string, u *url.URL, username string, password string) *smbClient {
+	share, root, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	return &smbClient{
+		binaryPath: binaryPath,
+		service:    fmt.Sprintf("//%s/%s", u.Hostname(), share),
+		port:       u.Port(),
+		root:       strings.Trim(root, "/"),
+		username:   username,
+		password:   password,
+	}
+}
+
+func (c *smbClient) List(ctx context.Context) ([]File, error) {
+	files := make([]File, 0)
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		output, err := c.run(ctx, "ls", path.Join(c.root, dir, "*"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list '%s': %w", dir, err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(output))
+		for scanner.Scan() {
+			matches := smbListPattern.FindStringSubmatch(scanner.Text())
+			if matches == nil || matches[1] == "." || matches[1] == ".." {
+				continue
+			}
+
+			entry := path.Join(dir, matches[1])
+			if strings.Contains(matches[2], "D") {
+				pending = append(pending, entry)
+				continue
+			}
+
+			size, _ := strconv.ParseInt(matches[3], 10, 64)
+			modified, _ := time.ParseInLocation(time.ANSIC, matches[4], time.Local)
+			files = append(files, File{Path: entry, Size: size, ModifiedAt: modified})
+		}
+	}
+
+	return files, nil
+}
+
+func (c *smbClient) Download(ctx context.Context, filePath string, localPath string) error {
+	// smbclient overwrites existing files, so the file is created beforehand to
+	// ensure that (like the other clients) an existing file is never replaced.
+	f, err := os.OpenFile(localPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_ = f.Close()
+
+	if _, err := c.run(ctx, "get", path.Join(c.root, filePath), localPath); err != nil {
+		return fmt.Errorf("failed to download '%s': %w", filePath, err)
+	}
+
+	return nil
+}
+
+func (c *smbClient) Close() error { return nil }
+
+// run runs a single smbclient command with the arguments provided, returning the output
+// of the command. Remote paths are converted to use the backslash separator.
+func (c *smbClient) run(ctx context.Context, command string, remotePath string, args ...string) ([]byte, error) {
+	quoted := []string{command, smbQuote(strings.ReplaceAll(remotePath, "/", `\`))}
+	for _, arg := range append([]string{remotePath}, args...) {
+		if strings.ContainsAny(arg, "\";\n") {
+			return nil, fmt.Errorf("%w: %s", errSMBPathUnsupported, arg)
+		}
+	}
+	for _, arg := range args {
+		quoted = append(quoted, smbQuote(arg))
+	}
+
+	cmdArgs := []string{c.service, "--command", strings.Join(quoted, " ")}
+	if c.port != "" {
+		cmdArgs = append(cmdArgs, "--port", c.port)
+	}
+	if c.username != "" {
+		cmdArgs = append(cmdArgs, "--user", c.username)
+	} else {
+		cmdArgs = append(cmdArgs, "--no-pass")
+	}
+
+	// The password is provided via the environment to avoid
+	// it being visible in the arguments of the process.
+	cmd := exec.CommandContext(ctx, c.binaryPath, cmdArgs...) //nolint:gosec
+	cmd.Env = append(os.Environ(), "PASSWD="+c.password)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		// smbclient reports most errors (e.g. NT_STATUS_*) on stdout
+		message := strings.TrimSpace(stderr.String() + "\n" + string(output))
+		return nil, fmt.Errorf("smbclient failed: %w: %s", err, message)
+	}
+
+	return output, nil
+}
+
+func smbQuote(arg string) string {
+	return `"` + arg + `"`
+}
@@ -0,0 +1,195 @@
+// Package remote periodically fetches new files from remote locations (SFTP servers, SMB shares
+// and WebDAV servers) in to the ingest directory, so that media which is not stored on the
+// host can be ingested. Files are downloaded to a staging directory, and only moved in
+// to the ingest directory once complete.
+package remote
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+type (
+	Protocol string
+
+	// Source is a remote location which is periodically listed for new files. The URL
+	// describes the host and the directory beneath which files are fetched, e.g.
+	// 'sftp://host:22/media', 'smb://host/share/media' or 'https://host/dav/media'.
+	Source struct {
+		ID        uuid.UUID `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+		Label     string    `db:"label"`
+		Protocol  Protocol  `db:"protocol"`
+		URL       string    `db:"url"`
+		Username  string    `db:"username"`
+
+		// EncryptedPassword is the password of the source, encrypted using
+		// the configured credential key. Empty if the source has no password.
+		EncryptedPassword []byte `db:"encrypted_password"`
+
+		// HostKeyFingerprint is the SHA256 fingerprint of the host key of
+		// an SFTP server (as printed by 'ssh-keygen -l'), e.g. 'SHA256:...'
+		HostKeyFingerprint string `db:"host_key_fingerprint"`
+
+		SyncIntervalSeconds int        `db:"sync_interval_seconds"`
+		Enabled             bool       `db:"enabled"`
+		LastSyncedAt        *time.Time `db:"last_synced_at"`
+		LastError           *string    `db:"last_error"`
+	}
+
+	// File is a file within a remote source. The path is relative to
+	// the directory of the source, and always uses forward slashes.
+	File struct {
+		SourceID   uuid.UUID `db:"source_id"`
+		Path       string    `db:"path"`
+		Size       int64     `db:"size"`
+		ModifiedAt time.Time `db:"modified_at"`
+		FetchedAt  time.Time `db:"fetched_at"`
+	}
+
+	Store struct{}
+)
+
+const (
+	SFTP   Protocol = "sftp"
+	SMB    Protocol = "smb"
+	WebDAV Protocol = "webdav"
+)
+
+var (
+	ErrSourceNotFound     = errors.New("remote source not found")
+	ErrLabelInvalid       = errors.New("label must be a valid directory name")
+	ErrProtocolInvalid    = errors.New("protocol must be one of 'sftp', 'smb' or 'webdav'")
+	ErrHostKeyRequired    = errors.New("sftp sources require the fingerprint of the host key of the server")
+	ErrSyncIntervalTooLow = errors.New("sync interval must be at least one minute")
+)
+
+// Validate returns an error if the source cannot be used to fetch files.
+func (source *Source) Validate() error {
+	errs := make([]error, 0)
+	// The label is used as the name of the directory the files of the source are fetched in to
+	if source.Label == "" {
+		errs = append(errs, errors.New("label is required"))
+	} else if source.Label == "." || source.Label == ".." || strings.ContainsAny(source.Label, `/\`) {
+		errs = append(errs, ErrLabelInvalid)
+	}
+	switch source.Protocol {
+	case SFTP:
+		if source.HostKeyFingerprint == "" {
+			errs = append(errs, ErrHostKeyRequired)
+		}
+	case SMB, WebDAV:
+	default:
+		errs = append(errs, ErrProtocolInvalid)
+	}
+	if _, err := parseSourceURL(source); err != nil {
+		errs = append(errs, err)
+	}
+	if source.SyncInterval() < time.Minute {
+		errs = append(errs, ErrSyncIntervalTooLow)
+	}
+
+	return errors.Join(errs...)
+}
+
+// isDue returns true if the source is enabled, and has not been synced within it's sync interval.
+func (source *Source) isDue(now time.Time) bool {
+	return source.Enabled && (source.LastSyncedAt == nil || now.Sub(*source.LastSyncedAt) >= source.SyncInterval())
+}
+
+func (source *Source) SyncInterval() time.Duration {
+	return time.Duration(source.SyncIntervalSeconds) * time.Second
+}
+
+func (source *Source) String() string {
+	return fmt.Sprintf("RemoteSource{ID=%s label=%s protocol=%s}", source.ID, source.Label, source.Protocol)
+}
+
+// Save creates or updates the source provided. The outcome of the last sync of an
+// existing source is not updated (see SaveSyncResult).
+func (store *Store) Save(db database.Queryable, source *Source) error {
+	_, err := db.NamedExec(`
+		INSERT INTO remote_source(id, created_at, updated_at, label, protocol, url, username, encrypted_password, host_key_fingerprint, sync_interval_seconds, enabled, last_synced_at, last_error)
+		VALUES (:id, current_timestamp, current_timestamp, :label, :protocol, :url, :username, :encrypted_password, :host_key_fingerprint, :sync_interval_seconds, :enabled, :last_synced_at, :last_error)
+		ON CONFLICT(id) DO UPDATE
+		SET (updated_at, label, protocol, url, username, encrypted_password, host_key_fingerprint, sync_interval_seconds, enabled) =
+			(current_timestamp, EXCLUDED.label, EXCLUDED.protocol, EXCLUDED.url, EXCLUDED.username, EXCLUDED.encrypted_password,
+			EXCLUDED.host_key_fingerprint, EXCLUDED.sync_interval_seconds, EXCLUDED.enabled)
+	`, source)
+
+	return err
+}
+
+// SaveSyncResult records the outcome of a sync of the source with the ID provided. The outcome is
+// saved separately to the rest of the source, so that a sync does not overwrite changes made
+// to the source while the sync was in progress (and vice versa).
+func (store *Store) SaveSyncResult(db database.Queryable, id uuid.UUID, syncedAt time.Time, lastError *string) error {
+	_, err := db.Exec(`UPDATE remote_source SET last_synced_at=$2, last_error=$3 WHERE id=$1`, id, syncedAt, lastError)
+	return err
+}
+
+func (store *Store) Get(db database.Queryable, id uuid.UUID) (*Source, error) {
+	var result Source
+	if err := db.Get(&result, `SELECT * FROM remote_source WHERE id=$1`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSourceNotFound
+		}
+
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (store *Store) GetAll(db database.Queryable) ([]*Source, error) {
+	var results []*Source
+	if err := db.Select(&results, `SELECT * FROM remote_source ORDER BY label`); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Delete deletes the source with the ID provided, along with the record of the files fetched from it.
+func (store *Store) Delete(db database.Queryable, id uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM remote_source WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrSourceNotFound
+	}
+
+	return nil
+}
+
+// GetFiles returns the files which have been fetched from the source with the ID provided.
+func (store *Store) GetFiles(db database.Queryable, sourceID uuid.UUID) ([]*File, error) {
+	var results []*File
+	if err := db.Select(&results, `SELECT * FROM remote_source_file WHERE source_id=$1`, sourceID); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SaveFile records that the file provided has been fetched, replacing any previous
+// record of the file (e.g. if the file has since been modified).
+func (store *Store) SaveFile(db database.Queryable, file *File) error {
+	_, err := db.NamedExec(`
+		INSERT INTO remote_source_file(source_id, path, size, modified_at, fetched_at)
+		VALUES (:source_id, :path, :size, :modified_at, current_timestamp)
+		ON CONFLICT(source_id, path) DO UPDATE
+		SET (size, modified_at, fetched_at) = (EXCLUDED.size, EXCLUDED.modified_at, EXCLUDED.fetched_at)
+	`, file)
+
+	return err
+}
@@ -0,0 +1,200 @@
+package remote
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+type (
+	// webdavClient lists directories using PROPFIND requests (one directory at a time, as
+	// many servers refuse infinite depth requests), and downloads files using GET requests.
+	webdavClient struct {
+		base     *url.URL
+		username string
+		password string
+		http     *http.Client
+	}
+
+	multistatus struct {
+		Responses []struct {
+			Href      string `xml:"DAV: href"`
+			Propstats []struct {
+				Prop struct {
+					ResourceType struct {
+						Collection *struct{} `xml:"DAV: collection"`
+					} `xml:"DAV: resourcetype"`
+					ContentLength string `xml:"DAV: getcontentlength"`
+					LastModified  string `xml:"DAV: getlastmodified"`
+				} `xml:"DAV: prop"`
+			} `xml:"DAV: propstat"`
+		} `xml:"DAV: response"`
+	}
+)
+
+func newWebDAVClient(base *url.URL, username string, password string) *webdavClient {
+	dir := *base
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+	}
+	dir.RawPath = ""
+
+	return &webdavClient{base: &dir, username: username, password: password, http: &http.Client{}}
+}
+
+func (c *webdavClient) List(ctx context.Context) ([]File, error) {
+	files := make([]File, 0)
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		entries, err := c.propfind(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.isDir {
+				pending = append(pending, entry.Path)
+			} else {
+				files = append(files, entry.File)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+type webdavEntry struct {
+	File
+	isDir bool
+}
+
+// propfind lists the entries within the directory provided (relative to the base directory).
+func (c *webdavClient) propfind(ctx context.Context, dir string) ([]webdavEntry, error) {
+	dirURL := c.resolve(dir)
+	if !strings.HasSuffix(dirURL.Path, "/") {
+		dirURL.Path += "/"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", dirURL.String(), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	c.authenticate(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list '%s': %w", dirURL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("failed to list '%s': unexpected status %s", dirURL.Path, resp.Status)
+	}
+
+	var result multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse listing of '%s': %w", dirURL.Path, err)
+	}
+
+	entries := make([]webdavEntry, 0, len(result.Responses))
+	for _, response := range result.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+
+		// Entries outside of the base directory (including the base directory
+		// itself) and the directory being listed are excluded
+		entryPath := dirURL.ResolveReference(href).Path
+		relative := strings.Trim(strings.TrimPrefix(entryPath, c.base.Path), "/")
+		if !strings.HasPrefix(entryPath, c.base.Path) || relative == "" || relative == strings.Trim(dir, "/") {
+			continue
+		}
+
+		entry := webdavEntry{File: File{Path: relative}}
+		for _, propstat := range response.Propstats {
+			prop := propstat.Prop
+			if prop.ResourceType.Collection != nil {
+				entry.isDir = true
+			}
+			if size, err := strconv.ParseInt(strings.TrimSpace(prop.ContentLength), 10, 64); err == nil {
+				entry.Size = size
+			}
+			if modified, err := http.ParseTime(strings.TrimSpace(prop.LastModified)); err == nil {
+				entry.ModifiedAt = modified
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (c *webdavClient) Download(ctx context.Context, filePath string, localPath string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.resolve(filePath).String(), nil)
+	if err != nil {
+		return err
+	}
+	c.authenticate(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download '%s': %w", filePath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download '%s': unexpected status %s", filePath, resp.Status)
+	}
+
+	return writeFile(localPath, resp.Body)
+}
+
+func (c *webdavClient) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// resolve returns the URL of the path provided, relative to the base directory.
+func (c *webdavClient) resolve(relative string) *url.URL {
+	out := *c.base
+	out.Path = path.Join(c.base.Path, relative)
+
+	return &out
+}
+
+func (c *webdavClient) authenticate(req *http.Request) {
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// writeFile writes the content of the reader provided to a new file at the path provided.
+func writeFile(localPath string, content io.Reader) error {
+	f, err := os.OpenFile(localPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, content); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/library"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationMetadataKey = "authorization"
+	bearerPrefix             = "Bearer "
+)
+
+// userContextKey is the key of the authenticated user in the context of unary RPCs.
+type userContextKey struct{}
+
+// methodPermissions contains the permissions required to call each RPC, matching
+// the permissions required by the equivalent REST endpoints. RPCs which are not
+// present in this map cannot be called.
+var methodPermissions = map[string][]string{
+	"/thea.v1.Thea/ListMedia":           {permissions.AccessMediaPermission},
+	"/thea.v1.Thea/GetMedia":            {permissions.AccessMediaPermission},
+	"/thea.v1.Thea/ListIngests":         {permissions.AccessIngestsPermission},
+	"/thea.v1.Thea/GetIngest":           {permissions.AccessIngestsPermission},
+	"/thea.v1.Thea/WatchIngests":        {permissions.AccessIngestsPermission},
+	"/thea.v1.Thea/ListTranscodeTasks":  {permissions.AccessTranscodePermission},
+	"/thea.v1.Thea/GetTranscodeTask":    {permissions.AccessTranscodePermission},
+	"/thea.v1.Thea/CreateTranscodeTask": {permissions.CreateTranscodePermission},
+	"/thea.v1.Thea/CancelTranscodeTask": {permissions.AccessTranscodePermission, permissions.DeleteTranscodePermission},
+	"/thea.v1.Thea/WatchTranscodeTasks": {permissions.AccessTranscodePermission},
+	"/thea.v1.Thea/ListWorkflows":       {permissions.AccessWorkflowPermission},
+	"/thea.v1.Thea/GetWorkflow":         {permissions.AccessWorkflowPermission},
+}
+
+// authenticate validates the auth token provided in the metadata of the incoming
+// context, and ensures the user has the permissions required to call the method.
+func (server *Server) authenticate(ctx context.Context, method string) (*jwt.AuthenticatedUser, error) {
+	required, ok := methodPermissions[method]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "method %s is not supported", method)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationMetadataKey)
+	if len(values) == 0 || !strings.HasPrefix(values[0], bearerPrefix) {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token in 'authorization' metadata")
+	}
+
+	user, err := server.authenticator.ValidateAuthToken(strings.TrimPrefix(values[0], bearerPrefix))
+	if err != nil {
+		log.Debugf("Rejected RPC %s due to invalid auth token: %v\n", method, err)
+		return nil, status.Error(codes.Unauthenticated, "invalid auth token")
+	}
+
+	for _, perm := range required {
+		if !slices.Contains(user.Permissions, perm) {
+			log.Warnf("User %s failed permissions check while calling %s: missing permission '%s'\n", user.UserID, method, perm)
+			return nil, status.Error(codes.PermissionDenied, "insufficient permissions")
+		}
+	}
+
+	return user, nil
+}
+
+func (server *Server) unaryAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	user, err := server.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(context.WithValue(ctx, userContextKey{}, user), req)
+}
+
+func (server *Server) streamAuthInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := server.authenticate(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+
+	return handler(srv, stream)
+}
+
+// contextUser returns the authenticated user of the unary RPC.
+func contextUser(ctx context.Context) (*jwt.AuthenticatedUser, error) {
+	user, ok := ctx.Value(userContextKey{}).(*jwt.AuthenticatedUser)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "request is not authenticated")
+	}
+
+	return user, nil
+}
+
+// libraryAccess returns the libraries which the user of the unary RPC may access.
+func (server *Server) libraryAccess(ctx context.Context) (*library.Access, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := server.store.GetLibraryAccess(user.UserID, user.Permissions)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return access, nil
+}
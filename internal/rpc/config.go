@@ -0,0 +1,7 @@
+package rpc
+
+type Config struct {
+	// Enabled starts the gRPC server alongside the REST API.
+	Enabled  bool   `toml:"enabled" env:"RPC_ENABLED" env-default:"false"`
+	HostAddr string `toml:"host_address" env:"RPC_HOST_ADDR" env-default:"0.0.0.0:8081"`
+}
@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/rpc/gen"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ingestStateMapping = map[ingest.IngestItemState]gen.IngestState{
+		ingest.Idle:        gen.IngestState_INGEST_STATE_IDLE,
+		ingest.ImportHold:  gen.IngestState_INGEST_STATE_IMPORT_HOLD,
+		ingest.Ingesting:   gen.IngestState_INGEST_STATE_INGESTING,
+		ingest.Troubled:    gen.IngestState_INGEST_STATE_TROUBLED,
+		ingest.Complete:    gen.IngestState_INGEST_STATE_COMPLETE,
+		ingest.Paused:      gen.IngestState_INGEST_STATE_PAUSED,
+		ingest.Quarantined: gen.IngestState_INGEST_STATE_QUARANTINED,
+		ingest.RetryLater:  gen.IngestState_INGEST_STATE_RETRY_LATER,
+	}
+
+	transcodeStatusMapping = map[transcode.TranscodeTaskStatus]gen.TranscodeTaskStatus{
+		transcode.WAITING:   gen.TranscodeTaskStatus_TRANSCODE_TASK_STATUS_WAITING,
+		transcode.WORKING:   gen.TranscodeTaskStatus_TRANSCODE_TASK_STATUS_WORKING,
+		transcode.SUSPENDED: gen.TranscodeTaskStatus_TRANSCODE_TASK_STATUS_SUSPENDED,
+		transcode.CANCELLED: gen.TranscodeTaskStatus_TRANSCODE_TASK_STATUS_CANCELLED,
+		transcode.COMPLETE:  gen.TranscodeTaskStatus_TRANSCODE_TASK_STATUS_COMPLETE,
+		transcode.TROUBLED:  gen.TranscodeTaskStatus_TRANSCODE_TASK_STATUS_TROUBLED,
+	}
+)
+
+func fromContainer(container *media.Container) *gen.Media {
+	out := &gen.Media{
+		Id:        container.ID().String(),
+		Title:     container.Title(),
+		TmdbId:    container.TmdbID(),
+		CreatedAt: timestamppb.New(container.CreatedAt()),
+		UpdatedAt: timestamppb.New(container.UpdatedAt()),
+	}
+
+	//exhaustive:enforce
+	switch container.Type {
+	case media.MovieContainerType:
+		out.Type = gen.MediaType_MEDIA_TYPE_MOVIE
+		out.SourcePath = container.Source()
+	case media.EpisodeContainerType:
+		out.Type = gen.MediaType_MEDIA_TYPE_EPISODE
+		out.SourcePath = container.Source()
+		out.EpisodeNumber = int32(container.EpisodeNumber()) //nolint:gosec
+		if container.Season != nil {
+			out.SeasonNumber = int32(container.SeasonNumber()) //nolint:gosec
+		}
+		if container.Series != nil {
+			out.SeriesId = container.Series.ID.String()
+		}
+	case media.SeriesContainerType:
+		out.Type = gen.MediaType_MEDIA_TYPE_SERIES
+	}
+
+	return out
+}
+
+func fromMediaListResult(result *media.MediaListResult) *gen.Media {
+	if result.IsMovie() {
+		movie := result.Movie
+		return &gen.Media{Id: movie.ID.String(), Type: gen.MediaType_MEDIA_TYPE_MOVIE, Title: movie.Title, TmdbId: movie.TmdbID, UpdatedAt: timestamppb.New(movie.UpdatedAt)}
+	}
+
+	series := result.Series
+	return &gen.Media{Id: series.ID.String(), Type: gen.MediaType_MEDIA_TYPE_SERIES, Title: series.Title, TmdbId: series.TmdbID, UpdatedAt: timestamppb.New(series.UpdatedAt)}
+}
+
+func fromIngest(item *ingest.IngestItem) *gen.Ingest {
+	out := &gen.Ingest{Id: item.ID.String(), Path: item.Path, State: ingestStateMapping[item.State]}
+	if item.Trouble != nil {
+		out.Trouble = item.Trouble.Error()
+	}
+
+	return out
+}
+
+func fromTranscodeTask(task *transcode.TranscodeTask) *gen.TranscodeTask {
+	out := &gen.TranscodeTask{
+		Id:         task.ID().String(),
+		MediaId:    task.Media().ID().String(),
+		TargetId:   task.Target().ID.String(),
+		VersionId:  optionalID(task.VersionID()),
+		OutputPath: task.OutputPath(),
+		Status:     transcodeStatusMapping[task.Status()],
+	}
+	if progress := task.LastProgress(); progress != nil {
+		out.Progress = &gen.TranscodeTaskProgress{
+			FramesProcessed: progress.FramesProcessed,
+			CurrentTime:     progress.CurrentTime,
+			CurrentBitrate:  progress.CurrentBitrate,
+			Progress:        progress.Progress,
+			Speed:           progress.Speed,
+		}
+	}
+	if trouble := task.Trouble(); trouble != nil {
+		out.Trouble = trouble.Error()
+	}
+
+	return out
+}
+
+// fromTranscode converts a completed transcode to a TranscodeTask message.
+func fromTranscode(model *transcode.Transcode) *gen.TranscodeTask {
+	return &gen.TranscodeTask{
+		Id:         model.ID.String(),
+		MediaId:    model.MediaID.String(),
+		TargetId:   model.TargetID.String(),
+		VersionId:  optionalID(model.VersionID),
+		OutputPath: model.MediaPath,
+		Status:     gen.TranscodeTaskStatus_TRANSCODE_TASK_STATUS_COMPLETE,
+	}
+}
+
+func fromWorkflow(model *workflow.Workflow) *gen.Workflow {
+	targetIDs := make([]string, len(model.Targets))
+	for k, target := range model.Targets {
+		targetIDs[k] = target.ID.String()
+	}
+
+	return &gen.Workflow{Id: model.ID.String(), Label: model.Label, Enabled: model.Enabled, TargetIds: targetIDs, Sequential: model.Sequential}
+}
+
+func optionalID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+
+	return id.String()
+}
@@ -0,0 +1,3 @@
+package gen
+
+//go:generate protoc --proto_path=../proto --go_out=. --go_opt=module=github.com/hbomb79/Thea/internal/rpc/gen --go-grpc_out=. --go-grpc_opt=module=github.com/hbomb79/Thea/internal/rpc/gen thea/v1/thea.proto
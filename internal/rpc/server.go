@@ -0,0 +1,164 @@
+// Package rpc implements Thea's gRPC API, which mirrors the core resources of the REST
+// API (media, ingests, transcodes and workflows) for use by CLI tools and other services.
+// Unlike the REST API, updates to ingests and transcodes are available as streaming RPCs.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api/jwt"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/library"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/rpc/gen"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"github.com/hbomb79/Thea/pkg/logger"
+	"google.golang.org/grpc"
+)
+
+var log = logger.Get("RPC")
+
+// subscriberBufferSize is the number of events buffered for each streaming RPC. Events
+// for a stream which is not keeping up are dropped, rather than blocking the event bus.
+const subscriberBufferSize = 100
+
+type (
+	Authenticator interface {
+		ValidateAuthToken(token string) (*jwt.AuthenticatedUser, error)
+	}
+
+	IngestService interface {
+		GetIngest(ingestID uuid.UUID) *ingest.IngestItem
+		GetAllIngests() []*ingest.IngestItem
+	}
+
+	TranscodeService interface {
+		NewTask(ctx context.Context, userID uuid.UUID, mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID, important bool) error
+		CancelTask(taskID uuid.UUID) error
+		AllTasks() []*transcode.TranscodeTask
+		Task(taskID uuid.UUID) *transcode.TranscodeTask
+		ActiveTaskForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) *transcode.TranscodeTask
+	}
+
+	Store interface {
+		GetMedia(mediaID uuid.UUID) *media.Container
+		ListMedia(opts media.ListMediaOptions, access *library.Access) (*media.MediaListPage, error)
+		GetTranscode(transcodeID uuid.UUID) *transcode.Transcode
+		GetLibraryAccess(userID uuid.UUID, userPermissions []string) (*library.Access, error)
+		GetWorkflow(workflowID uuid.UUID) *workflow.Workflow
+		GetAllWorkflows() []*workflow.Workflow
+	}
+
+	// Server is the gRPC server, which serves the Thea service defined
+	// in proto/thea/v1/thea.proto.
+	Server struct {
+		gen.UnimplementedTheaServer
+
+		config        Config
+		authenticator Authenticator
+		ingests       IngestService
+		transcodes    TranscodeService
+		store         Store
+		eventBus      event.EventHandler
+
+		subscribersMutex sync.Mutex
+		subscribers      map[chan event.HandlerEvent]struct{}
+
+		// stopping is closed when the server begins shutting down,
+		// ending any streaming RPCs.
+		stopping chan struct{}
+	}
+)
+
+func New(config Config, authenticator Authenticator, ingests IngestService, transcodes TranscodeService, store Store, eventBus event.EventHandler) *Server {
+	return &Server{
+		config:        config,
+		authenticator: authenticator,
+		ingests:       ingests,
+		transcodes:    transcodes,
+		store:         store,
+		eventBus:      eventBus,
+		subscribers:   make(map[chan event.HandlerEvent]struct{}),
+		stopping:      make(chan struct{}),
+	}
+}
+
+// Run serves the gRPC API until the context provided is cancelled.
+func (server *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", server.config.HostAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", server.config.HostAddr, err)
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(server.unaryAuthInterceptor),
+		grpc.StreamInterceptor(server.streamAuthInterceptor),
+	)
+	gen.RegisterTheaServer(grpcServer, server)
+
+	events := make(chan event.HandlerEvent, subscriberBufferSize)
+	server.eventBus.RegisterHandlerChannel(events, event.IngestUpdateEvent, event.IngestCompleteEvent, event.TranscodeUpdateEvent, event.TranscodeTaskProgressEvent, event.TranscodeCompleteEvent)
+	go server.relayEvents(ctx, events)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- grpcServer.Serve(listener) }()
+
+	log.Emit(logger.NEW, "Started gRPC server at %s\n", server.config.HostAddr)
+	select {
+	case err := <-serveErr:
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			return fmt.Errorf("gRPC server failed: %w", err)
+		}
+	case <-ctx.Done():
+		close(server.stopping)
+		grpcServer.GracefulStop()
+	}
+
+	log.Emit(logger.STOP, "gRPC server closed\n")
+	return nil
+}
+
+// relayEvents forwards the events received from the event bus to the
+// subscribed streams, until the context provided is cancelled.
+func (server *Server) relayEvents(ctx context.Context, events <-chan event.HandlerEvent) {
+	for {
+		select {
+		case ev := <-events:
+			server.subscribersMutex.Lock()
+			for sub := range server.subscribers {
+				select {
+				case sub <- ev:
+				default:
+				}
+			}
+			server.subscribersMutex.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// subscribe returns a channel which receives the events received by the server, which
+// must be passed to unsubscribe once the subscriber no longer requires the events.
+func (server *Server) subscribe() chan event.HandlerEvent {
+	server.subscribersMutex.Lock()
+	defer server.subscribersMutex.Unlock()
+
+	sub := make(chan event.HandlerEvent, subscriberBufferSize)
+	server.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (server *Server) unsubscribe(sub chan event.HandlerEvent) {
+	server.subscribersMutex.Lock()
+	defer server.subscribersMutex.Unlock()
+
+	delete(server.subscribers, sub)
+}
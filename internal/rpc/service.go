@@ -0,0 +1,275 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/quota"
+	"github.com/hbomb79/Thea/internal/rpc/gen"
+	"github.com/hbomb79/Thea/internal/transcode"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var mediaTypeMapping = map[gen.MediaType]media.MediaListType{
+	gen.MediaType_MEDIA_TYPE_MOVIE:  media.MovieType,
+	gen.MediaType_MEDIA_TYPE_SERIES: media.SeriesType,
+}
+
+func (server *Server) ListMedia(ctx context.Context, request *gen.ListMediaRequest) (*gen.ListMediaResponse, error) {
+	types := make([]media.MediaListType, len(request.GetTypes()))
+	for k, t := range request.GetTypes() {
+		listType, ok := mediaTypeMapping[t]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "media type %s cannot be listed", t)
+		}
+		types[k] = listType
+	}
+
+	access, err := server.libraryAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := server.store.ListMedia(media.ListMediaOptions{Types: types, Title: request.GetTitleFilter(), Limit: int(request.GetLimit()), Cursor: request.GetCursor()}, access)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	out := &gen.ListMediaResponse{Media: make([]*gen.Media, len(page.Results)), HasMore: page.HasMore, NextCursor: page.NextCursor}
+	for k, result := range page.Results {
+		out.Media[k] = fromMediaListResult(result)
+	}
+
+	return out, nil
+}
+
+func (server *Server) GetMedia(ctx context.Context, request *gen.GetMediaRequest) (*gen.Media, error) {
+	id, err := parseID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := server.libraryAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Media in a library the user may not access is reported as not found
+	container := server.store.GetMedia(id)
+	if container == nil || !access.Allows(container.LibraryID()) {
+		return nil, status.Errorf(codes.NotFound, "media %s not found", id)
+	}
+
+	return fromContainer(container), nil
+}
+
+func (server *Server) ListIngests(_ context.Context, _ *gen.ListIngestsRequest) (*gen.ListIngestsResponse, error) {
+	items := server.ingests.GetAllIngests()
+	out := &gen.ListIngestsResponse{Ingests: make([]*gen.Ingest, len(items))}
+	for k, item := range items {
+		out.Ingests[k] = fromIngest(item)
+	}
+
+	return out, nil
+}
+
+func (server *Server) GetIngest(_ context.Context, request *gen.GetIngestRequest) (*gen.Ingest, error) {
+	id, err := parseID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	item := server.ingests.GetIngest(id)
+	if item == nil {
+		return nil, status.Errorf(codes.NotFound, "ingest %s not found", id)
+	}
+
+	return fromIngest(item), nil
+}
+
+// WatchIngests sends the current state of an ingest each time it is updated. Ingests
+// which have completed are removed from the queue, and so the last message received
+// for an ingest may not be COMPLETE.
+func (server *Server) WatchIngests(_ *gen.WatchIngestsRequest, stream gen.Thea_WatchIngestsServer) error {
+	return server.watch(stream.Context(), func(ev event.HandlerEvent, id uuid.UUID) error {
+		if ev.Event != event.IngestUpdateEvent && ev.Event != event.IngestCompleteEvent {
+			return nil
+		}
+
+		if item := server.ingests.GetIngest(id); item != nil {
+			return stream.Send(fromIngest(item))
+		}
+
+		return nil
+	})
+}
+
+func (server *Server) ListTranscodeTasks(_ context.Context, _ *gen.ListTranscodeTasksRequest) (*gen.ListTranscodeTasksResponse, error) {
+	tasks := server.transcodes.AllTasks()
+	out := &gen.ListTranscodeTasksResponse{Tasks: make([]*gen.TranscodeTask, len(tasks))}
+	for k, task := range tasks {
+		out.Tasks[k] = fromTranscodeTask(task)
+	}
+
+	return out, nil
+}
+
+func (server *Server) GetTranscodeTask(_ context.Context, request *gen.GetTranscodeTaskRequest) (*gen.TranscodeTask, error) {
+	id, err := parseID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if task := server.transcodes.Task(id); task != nil {
+		return fromTranscodeTask(task), nil
+	}
+	if model := server.store.GetTranscode(id); model != nil {
+		return fromTranscode(model), nil
+	}
+
+	return nil, status.Errorf(codes.NotFound, "transcode task %s not found", id)
+}
+
+func (server *Server) CreateTranscodeTask(ctx context.Context, request *gen.CreateTranscodeTaskRequest) (*gen.TranscodeTask, error) {
+	user, err := contextUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaID, err := parseID(request.GetMediaId())
+	if err != nil {
+		return nil, err
+	}
+	targetID, err := parseID(request.GetTargetId())
+	if err != nil {
+		return nil, err
+	}
+
+	var versionID *uuid.UUID
+	if request.GetVersionId() != "" {
+		id, err := parseID(request.GetVersionId())
+		if err != nil {
+			return nil, err
+		}
+		versionID = &id
+	}
+
+	if err := server.transcodes.NewTask(ctx, user.UserID, mediaID, targetID, versionID, request.GetImportant()); err != nil {
+		if errors.Is(err, transcode.ErrDraining) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		if errors.Is(err, quota.ErrConcurrentLimitReached) || errors.Is(err, quota.ErrDailyLimitReached) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		return nil, status.Errorf(codes.InvalidArgument, "task creation failed: %v", err)
+	}
+
+	task := server.transcodes.ActiveTaskForMediaAndTarget(mediaID, targetID, versionID)
+	if task == nil {
+		// The task may have already completed (or been cancelled)
+		return nil, status.Error(codes.NotFound, "task was created, but is no longer active")
+	}
+
+	return fromTranscodeTask(task), nil
+}
+
+func (server *Server) CancelTranscodeTask(_ context.Context, request *gen.CancelTranscodeTaskRequest) (*gen.CancelTranscodeTaskResponse, error) {
+	id, err := parseID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.transcodes.CancelTask(id); err != nil {
+		if errors.Is(err, transcode.ErrTaskNotFound) {
+			return nil, status.Errorf(codes.NotFound, "transcode task %s not found", id)
+		}
+
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to cancel task %s: %v", id, err)
+	}
+
+	return &gen.CancelTranscodeTaskResponse{}, nil
+}
+
+// WatchTranscodeTasks sends the current state of an active transcode task each time it
+// is updated, including when it's progress changes.
+func (server *Server) WatchTranscodeTasks(_ *gen.WatchTranscodeTasksRequest, stream gen.Thea_WatchTranscodeTasksServer) error {
+	return server.watch(stream.Context(), func(ev event.HandlerEvent, id uuid.UUID) error {
+		switch ev.Event {
+		case event.TranscodeUpdateEvent, event.TranscodeTaskProgressEvent, event.TranscodeCompleteEvent:
+		default:
+			return nil
+		}
+
+		if task := server.transcodes.Task(id); task != nil {
+			return stream.Send(fromTranscodeTask(task))
+		} else if ev.Event == event.TranscodeCompleteEvent {
+			if model := server.store.GetTranscode(id); model != nil {
+				return stream.Send(fromTranscode(model))
+			}
+		}
+
+		return nil
+	})
+}
+
+func (server *Server) ListWorkflows(_ context.Context, _ *gen.ListWorkflowsRequest) (*gen.ListWorkflowsResponse, error) {
+	workflows := server.store.GetAllWorkflows()
+	out := &gen.ListWorkflowsResponse{Workflows: make([]*gen.Workflow, len(workflows))}
+	for k, wf := range workflows {
+		out.Workflows[k] = fromWorkflow(wf)
+	}
+
+	return out, nil
+}
+
+func (server *Server) GetWorkflow(_ context.Context, request *gen.GetWorkflowRequest) (*gen.Workflow, error) {
+	id, err := parseID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	wf := server.store.GetWorkflow(id)
+	if wf == nil {
+		return nil, status.Errorf(codes.NotFound, "workflow %s not found", id)
+	}
+
+	return fromWorkflow(wf), nil
+}
+
+// watch calls the handler for each event received by the server, until the context
+// provided is cancelled, the server is stopped, or the handler returns an error.
+func (server *Server) watch(ctx context.Context, handler func(event.HandlerEvent, uuid.UUID) error) error {
+	sub := server.subscribe()
+	defer server.unsubscribe(sub)
+
+	for {
+		select {
+		case ev := <-sub:
+			id, ok := ev.Payload.(uuid.UUID)
+			if !ok {
+				continue
+			}
+			if err := handler(ev, id); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		case <-server.stopping:
+			return nil
+		}
+	}
+}
+
+func parseID(id string) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "'%s' is not a valid ID", id)
+	}
+
+	return parsed, nil
+}
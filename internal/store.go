@@ -1,17 +1,28 @@
 package internal
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/audit"
+	"github.com/hbomb79/Thea/internal/cache"
 	"github.com/hbomb79/Thea/internal/database"
 	"github.com/hbomb79/Thea/internal/event"
 	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/library"
 	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/preferences"
+	"github.com/hbomb79/Thea/internal/quota"
+	"github.com/hbomb79/Thea/internal/remote"
+	"github.com/hbomb79/Thea/internal/trakt"
 	"github.com/hbomb79/Thea/internal/transcode"
 	"github.com/hbomb79/Thea/internal/user"
+	"github.com/hbomb79/Thea/internal/user/permissions"
 	"github.com/hbomb79/Thea/internal/workflow"
 	"github.com/hbomb79/Thea/internal/workflow/match"
 	"github.com/jmoiron/sqlx"
@@ -24,7 +35,9 @@ const (
 
 var (
 	ErrDatabaseNotConnected    = errors.New("cannot construct thea data store with a disconnected db")
-	ErrWorkflowTargetIDMissing = errors.New("one or more of the targets provided cannot be found")
+	ErrWorkflowTargetIDMissing = workflow.ErrTargetIDMissing
+	ErrUserRoleIDMissing       = user.ErrRoleIDMissing
+	ErrPermissionsInvalid      = user.ErrPermissionsInvalid
 )
 
 // storeOrchestrator is responsible for managing all of Thea's resources,
@@ -35,17 +48,27 @@ var (
 // If consumers need to be able to access data stores directly, they're
 // welcome to do so - however caution should be taken as stores have no
 // obligation to take care of relational data (which is the orchestrator's job).
+//
+// Frequently requested data (targets, workflows, genres and movie/episode models) is
+// cached, see invalidateCacheOnEvents for details of how this cache is invalidated.
 type storeOrchestrator struct {
 	db             database.Manager
 	ev             event.EventDispatcher
+	cache          cache.Cache
 	mediaStore     *media.Store
 	transcodeStore *transcode.Store
 	workflowStore  *workflow.Store
 	targetStore    *ffmpeg.Store
 	userStore      *user.Store
+	auditStore     *audit.Store
+	traktStore     *trakt.Store
+	remoteStore    *remote.Store
+	libraryStore   *library.Store
+	quotaStore     *quota.Store
+	prefsStore     *preferences.Store
 }
 
-func newStoreOrchestrator(db database.Manager, eventBus event.EventDispatcher) (*storeOrchestrator, error) {
+func newStoreOrchestrator(db database.Manager, eventBus event.EventDispatcher, dataCache cache.Cache, hashConfig user.HashConfig) (*storeOrchestrator, error) {
 	if db.GetSqlxDB() == nil {
 		return nil, ErrDatabaseNotConnected
 	}
@@ -53,20 +76,53 @@ func newStoreOrchestrator(db database.Manager, eventBus event.EventDispatcher) (
 	return &storeOrchestrator{
 		db:             db,
 		ev:             eventBus,
+		cache:          dataCache,
 		mediaStore:     &media.Store{},
 		transcodeStore: &transcode.Store{},
 		workflowStore:  &workflow.Store{},
 		targetStore:    &ffmpeg.Store{},
-		userStore:      user.NewStore(),
+		userStore:      user.NewStore(hashConfig),
+		auditStore:     &audit.Store{},
+		traktStore:     &trakt.Store{},
+		remoteStore:    &remote.Store{},
+		libraryStore:   &library.Store{},
+		quotaStore:     &quota.Store{},
+		prefsStore:     &preferences.Store{},
 	}, nil
 }
 
+// MigrationStatus returns the schema version of the database, and
+// which of the migrations known to Thea have been applied.
+func (orchestrator *storeOrchestrator) MigrationStatus() (*database.MigrationStatus, error) {
+	return orchestrator.db.MigrationStatus()
+}
+
 func (orchestrator *storeOrchestrator) GetMedia(mediaID uuid.UUID) *media.Container {
-	return orchestrator.mediaStore.GetMedia(orchestrator.db.GetSqlxDB(), mediaID)
+	return orchestrator.mediaStore.GetMedia(orchestrator.db.Queryable(), mediaID)
+}
+
+func (orchestrator *storeOrchestrator) GetMediaStatistics() (*media.Statistics, error) {
+	return orchestrator.mediaStore.GetStatistics(orchestrator.db.Queryable())
+}
+
+// GetMedias fetches the movies and episodes with the given IDs in
+// a single query. See media.Store.GetMedias for details.
+func (orchestrator *storeOrchestrator) GetMedias(mediaIDs []uuid.UUID) ([]*media.Container, error) {
+	return orchestrator.mediaStore.GetMedias(orchestrator.db.Queryable(), mediaIDs)
+}
+
+// GetContainers fetches the movies, episodes and series with the given IDs
+// in a single query. See media.Store.GetContainers for details.
+func (orchestrator *storeOrchestrator) GetContainers(ids []uuid.UUID) ([]*media.Container, error) {
+	return orchestrator.mediaStore.GetContainers(orchestrator.db.Queryable(), ids)
 }
 
 func (orchestrator *storeOrchestrator) GetMovie(movieID uuid.UUID) (*media.Movie, error) {
 	var movie *media.Movie
+	if orchestrator.cache.Get(movieCacheKey(movieID), &movie) {
+		return movie, nil
+	}
+
 	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
 		m, err := orchestrator.mediaStore.GetMovie(tx, movieID)
 		if err != nil {
@@ -86,45 +142,241 @@ func (orchestrator *storeOrchestrator) GetMovie(movieID uuid.UUID) (*media.Movie
 		return nil, err
 	}
 
+	orchestrator.cache.Set(movieCacheKey(movieID), movie)
 	return movie, nil
 }
 
+func (orchestrator *storeOrchestrator) GetMovieWithTmdbID(tmdbID string) (*media.Movie, error) {
+	return orchestrator.mediaStore.GetMovieWithTmdbID(orchestrator.db.Queryable(), tmdbID)
+}
+
 func (orchestrator *storeOrchestrator) GetEpisode(episodeID uuid.UUID) (*media.Episode, error) {
-	return orchestrator.mediaStore.GetEpisode(orchestrator.db.GetSqlxDB(), episodeID)
+	var episode *media.Episode
+	if orchestrator.cache.Get(episodeCacheKey(episodeID), &episode) {
+		return episode, nil
+	}
+
+	episode, err := orchestrator.mediaStore.GetEpisode(orchestrator.db.Queryable(), episodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	orchestrator.cache.Set(episodeCacheKey(episodeID), episode)
+	return episode, nil
 }
 
 func (orchestrator *storeOrchestrator) GetEpisodeWithTmdbID(tmdbID string) (*media.Episode, error) {
-	return orchestrator.mediaStore.GetEpisodeWithTmdbID(orchestrator.db.GetSqlxDB(), tmdbID)
+	return orchestrator.mediaStore.GetEpisodeWithTmdbID(orchestrator.db.Queryable(), tmdbID)
 }
 
 func (orchestrator *storeOrchestrator) GetSeason(seasonID uuid.UUID) (*media.Season, error) {
-	return orchestrator.mediaStore.GetSeason(orchestrator.db.GetSqlxDB(), seasonID)
+	return orchestrator.mediaStore.GetSeason(orchestrator.db.Queryable(), seasonID)
 }
 
 func (orchestrator *storeOrchestrator) GetSeasonWithTmdbID(tmdbID string) (*media.Season, error) {
-	return orchestrator.mediaStore.GetSeasonWithTmdbID(orchestrator.db.GetSqlxDB(), tmdbID)
+	return orchestrator.mediaStore.GetSeasonWithTmdbID(orchestrator.db.Queryable(), tmdbID)
 }
 
 func (orchestrator *storeOrchestrator) GetSeries(seriesID uuid.UUID) (*media.Series, error) {
-	return orchestrator.mediaStore.GetSeries(orchestrator.db.GetSqlxDB(), seriesID)
+	return orchestrator.mediaStore.GetSeries(orchestrator.db.Queryable(), seriesID)
 }
 
 func (orchestrator *storeOrchestrator) GetSeriesWithTmdbID(tmdbID string) (*media.Series, error) {
-	return orchestrator.mediaStore.GetSeriesWithTmdbID(orchestrator.db.GetSqlxDB(), tmdbID)
+	return orchestrator.mediaStore.GetSeriesWithTmdbID(orchestrator.db.Queryable(), tmdbID)
 }
 
 func (orchestrator *storeOrchestrator) GetAllMediaSourcePaths() ([]string, error) {
-	return orchestrator.mediaStore.GetAllSourcePaths(orchestrator.db.GetSqlxDB())
+	return orchestrator.mediaStore.GetAllSourcePaths(orchestrator.db.Queryable())
 }
 
-// SaveMovie transactionally saves the given Movie model and it's genre
-// information to the database.
-func (orchestrator *storeOrchestrator) SaveMovie(movie *media.Movie) error {
-	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
-		if err := orchestrator.mediaStore.SaveMovie(tx, movie); err != nil {
+func (orchestrator *storeOrchestrator) ListMediaSourceFiles() ([]*media.SourceFile, error) {
+	return orchestrator.mediaStore.ListSourceFiles(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) SetMediaDegraded(mediaID uuid.UUID, degraded bool) error {
+	defer orchestrator.evictMedia(mediaID)
+	return orchestrator.mediaStore.SetMediaDegraded(orchestrator.db.Queryable(), mediaID, degraded)
+}
+
+func (orchestrator *storeOrchestrator) SetMediaChecksum(mediaID uuid.UUID, checksum string) error {
+	defer orchestrator.evictMedia(mediaID)
+	return orchestrator.mediaStore.SetMediaChecksum(orchestrator.db.Queryable(), mediaID, checksum)
+}
+
+func (orchestrator *storeOrchestrator) SetMediaCorrupted(mediaID uuid.UUID, corrupted bool) error {
+	defer orchestrator.evictMedia(mediaID)
+	return orchestrator.mediaStore.SetMediaCorrupted(orchestrator.db.Queryable(), mediaID, corrupted)
+}
+
+// SaveMediaVersion saves the given version of a movie or episode. See media.Store.SaveVersion.
+func (orchestrator *storeOrchestrator) SaveMediaVersion(version *media.Version) error {
+	return orchestrator.mediaStore.SaveVersion(orchestrator.db.Queryable(), version)
+}
+
+func (orchestrator *storeOrchestrator) GetMediaVersion(versionID uuid.UUID) (*media.Version, error) {
+	return orchestrator.mediaStore.GetVersion(orchestrator.db.Queryable(), versionID)
+}
+
+func (orchestrator *storeOrchestrator) GetMediaVersions(mediaID uuid.UUID) ([]*media.Version, error) {
+	return orchestrator.mediaStore.GetVersionsForMedia(orchestrator.db.Queryable(), mediaID)
+}
+
+func (orchestrator *storeOrchestrator) GetMediaVersionsForMedias(mediaIDs []uuid.UUID) (map[uuid.UUID][]*media.Version, error) {
+	return orchestrator.mediaStore.GetVersionsForMedias(orchestrator.db.Queryable(), mediaIDs)
+}
+
+func (orchestrator *storeOrchestrator) UpdateMediaVersionLabel(versionID uuid.UUID, label string) (*media.Version, error) {
+	return orchestrator.mediaStore.UpdateVersionLabel(orchestrator.db.Queryable(), versionID, label)
+}
+
+// CreateCollection transactionally creates a new user-curated collection
+// containing the movies/episodes provided, in order.
+func (orchestrator *storeOrchestrator) CreateCollection(title string, description string, mediaIDs []uuid.UUID) (*media.InflatedCollection, error) {
+	collection := &media.Collection{ID: uuid.New(), Title: title, Description: description}
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.mediaStore.CreateCollection(tx, collection); err != nil {
+			return err
+		}
+
+		return orchestrator.mediaStore.SetCollectionItems(tx, collection.ID, mediaIDs)
+	}); err != nil {
+		return nil, err
+	}
+
+	return orchestrator.GetCollection(collection.ID)
+}
+
+// UpdateCollection transactionally updates the collection with the ID provided. If
+// media IDs are provided, they replace the existing members of the collection.
+func (orchestrator *storeOrchestrator) UpdateCollection(collectionID uuid.UUID, title *string, description *string, mediaIDs *[]uuid.UUID) (*media.InflatedCollection, error) {
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if _, err := orchestrator.mediaStore.UpdateCollection(tx, collectionID, title, description); err != nil {
+			return err
+		}
+
+		if mediaIDs == nil {
+			return nil
+		}
+
+		return orchestrator.mediaStore.SetCollectionItems(tx, collectionID, *mediaIDs)
+	}); err != nil {
+		return nil, err
+	}
+
+	return orchestrator.GetCollection(collectionID)
+}
+
+// GetCollection returns the collection with the ID provided, along with it's members.
+func (orchestrator *storeOrchestrator) GetCollection(collectionID uuid.UUID) (*media.InflatedCollection, error) {
+	db := orchestrator.db.Queryable()
+	collection, err := orchestrator.mediaStore.GetCollection(db, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := orchestrator.mediaStore.GetCollectionItems(db, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &media.InflatedCollection{Collection: collection, Items: items}, nil
+}
+
+func (orchestrator *storeOrchestrator) ListCollections() ([]*media.Collection, error) {
+	return orchestrator.mediaStore.ListCollections(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) DeleteCollection(collectionID uuid.UUID) error {
+	return orchestrator.mediaStore.DeleteCollection(orchestrator.db.Queryable(), collectionID)
+}
+
+// CreateTag transactionally creates a new tag with the given label,
+// attached to the movies and series with the IDs provided.
+func (orchestrator *storeOrchestrator) CreateTag(label string, itemIDs []uuid.UUID) (*media.InflatedTag, error) {
+	tag := &media.Tag{ID: uuid.New(), Label: label}
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.mediaStore.CreateTag(tx, tag); err != nil {
+			return err
+		}
+
+		return orchestrator.mediaStore.SetTagItems(tx, tag.ID, itemIDs)
+	}); err != nil {
+		return nil, err
+	}
+
+	return orchestrator.GetTag(tag.ID)
+}
+
+// UpdateTag transactionally updates the tag with the ID provided. If item
+// IDs are provided, they replace the movies and series the tag is attached to.
+func (orchestrator *storeOrchestrator) UpdateTag(tagID uuid.UUID, label *string, itemIDs *[]uuid.UUID) (*media.InflatedTag, error) {
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if _, err := orchestrator.mediaStore.UpdateTag(tx, tagID, label); err != nil {
 			return err
 		}
 
+		if itemIDs == nil {
+			return nil
+		}
+
+		return orchestrator.mediaStore.SetTagItems(tx, tagID, *itemIDs)
+	}); err != nil {
+		return nil, err
+	}
+
+	return orchestrator.GetTag(tagID)
+}
+
+// GetTag returns the tag with the ID provided, along with the movies and series it's attached to.
+func (orchestrator *storeOrchestrator) GetTag(tagID uuid.UUID) (*media.InflatedTag, error) {
+	db := orchestrator.db.Queryable()
+	tag, err := orchestrator.mediaStore.GetTag(db, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := orchestrator.mediaStore.GetTagItems(db, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &media.InflatedTag{Tag: tag, Items: items}, nil
+}
+
+func (orchestrator *storeOrchestrator) ListTags() ([]*media.Tag, error) {
+	return orchestrator.mediaStore.ListTags(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) DeleteTag(tagID uuid.UUID) error {
+	return orchestrator.mediaStore.DeleteTag(orchestrator.db.Queryable(), tagID)
+}
+
+// SaveMovie transactionally saves the given Movie model and it's genre (and
+// related content) information to the database. If the movie belongs to a TMDB collection, the
+// collection is saved too and the movie is added to it. The movie is assigned
+// to the library containing it's source path (if any).
+func (orchestrator *storeOrchestrator) SaveMovie(movie *media.Movie) error {
+	// The ID of the movie is updated if it already exists, so must not be evaluated until saved
+	defer func() { orchestrator.evictMedia(movie.ID) }()
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		return orchestrator.saveMovie(tx, movie)
+	})
+}
+
+func (orchestrator *storeOrchestrator) saveMovie(tx *sqlx.Tx, movie *media.Movie) error {
+	libraryID, err := orchestrator.libraryStore.MatchPath(tx, movie.SourcePath)
+	if err != nil {
+		return err
+	}
+
+	movie.LibraryID = libraryID
+	if err := orchestrator.mediaStore.SaveMovie(tx, movie); err != nil {
+		return err
+	}
+
+	if movie.IsLocked(media.GenresField) {
+		log.Verbosef("Genres of movie_id=%s are locked, skipping genres %v\n", movie.ID, movie.Genres)
+	} else {
 		log.Verbosef("Saving genres %v\n", movie.Genres)
 		genres, err := orchestrator.mediaStore.SaveGenres(tx, movie.Genres)
 		if err != nil {
@@ -132,14 +384,35 @@ func (orchestrator *storeOrchestrator) SaveMovie(movie *media.Movie) error {
 		}
 
 		log.Verbosef("Saving genres assocations %v for movie_id=%s\n", genres, movie.ID)
-		return orchestrator.mediaStore.SaveMovieGenreAssociations(tx, movie.ID, genres)
-	})
+		if err := orchestrator.mediaStore.SaveMovieGenreAssociations(tx, movie.ID, genres); err != nil {
+			return err
+		}
+	}
+
+	if movie.Related != nil {
+		log.Verbosef("Saving related content for movie_id=%s\n", movie.ID)
+		if err := orchestrator.mediaStore.SaveMovieRelations(tx, movie.ID, movie.Related); err != nil {
+			return err
+		}
+	}
+
+	if movie.Collection == nil {
+		return nil
+	}
+
+	log.Verbosef("Saving collection %v for movie_id=%s\n", movie.Collection, movie.ID)
+	if err := orchestrator.mediaStore.SaveTmdbCollection(tx, movie.Collection); err != nil {
+		return err
+	}
+
+	return orchestrator.mediaStore.AddToCollection(tx, movie.Collection.ID, movie.ID)
 }
 
 // SaveEpisode transactionally saves the episode provided, as well as the season and series
 // it's associatted with. Existing models are updating ON CONFLICT with the TmdbID unique
 // identifier. The PK's and relational FK's of the models will automatically be
-// set during saving.
+// set during saving. The episode and series are assigned to the library containing the
+// source path of the episode (if any).
 //
 // Note: If the season/series are not provided, and the FK-constraint of the episode cannot
 // be fulfilled because of this, then the save will fail. It is recommended to supply all parameters.
@@ -152,11 +425,42 @@ func (orchestrator *storeOrchestrator) SaveEpisode(episode *media.Episode, seaso
 	seasonFk := season.SeriesID
 
 	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
-		log.Verbosef("Saving series %#v\n", series)
-		if err := orchestrator.mediaStore.SaveSeries(tx, series); err != nil {
-			return err
-		}
+		return orchestrator.saveEpisode(tx, episode, season, series)
+	}); err != nil {
+		log.Warnf(
+			"Episode save failed, rolling back model keys (epID=%s, epFK=%s, seasonID=%s, seasonFK=%s, seriesID=%s)",
+			episodeID, episodeFk, seasonID, seasonFk, seriesID,
+		)
+
+		episode.ID = episodeID
+		season.ID = seasonID
+		series.ID = seriesID
+
+		episode.SeasonID = episodeFk
+		season.SeriesID = seasonFk
+		return err
+	}
+
+	orchestrator.evictMedia(episode.ID)
+	return nil
+}
+
+func (orchestrator *storeOrchestrator) saveEpisode(tx *sqlx.Tx, episode *media.Episode, season *media.Season, series *media.Series) error {
+	libraryID, err := orchestrator.libraryStore.MatchPath(tx, episode.SourcePath)
+	if err != nil {
+		return err
+	}
+	episode.LibraryID = libraryID
+	series.LibraryID = libraryID
+
+	log.Verbosef("Saving series %#v\n", series)
+	if err := orchestrator.mediaStore.SaveSeries(tx, series); err != nil {
+		return err
+	}
 
+	if series.IsLocked(media.GenresField) {
+		log.Verbosef("Genres of series_id=%s are locked, skipping genres %v\n", series.ID, series.Genres)
+	} else {
 		log.Verbosef("Saving genres %v\n", series.Genres)
 		genres, err := orchestrator.mediaStore.SaveGenres(tx, series.Genres)
 		if err != nil {
@@ -167,63 +471,80 @@ func (orchestrator *storeOrchestrator) SaveEpisode(episode *media.Episode, seaso
 		if err := orchestrator.mediaStore.SaveSeriesGenreAssociations(tx, series.ID, genres); err != nil {
 			return err
 		}
+	}
 
-		log.Verbosef("Saving season %#v with series_id=%s\n", season, series.ID)
-		season.SeriesID = series.ID
-		if err := orchestrator.mediaStore.SaveSeason(tx, season); err != nil {
+	if series.Related != nil {
+		log.Verbosef("Saving related content for series_id=%s\n", series.ID)
+		if err := orchestrator.mediaStore.SaveSeriesRelations(tx, series.ID, series.Related); err != nil {
 			return err
 		}
+	}
 
-		log.Verbosef("Saving episode %#v with season_id=%s\n", episode, seasonID)
-		episode.SeasonID = season.ID
-		return orchestrator.mediaStore.SaveEpisode(tx, episode)
-	}); err != nil {
-		log.Warnf(
-			"Episode save failed, rolling back model keys (epID=%s, epFK=%s, seasonID=%s, seasonFK=%s, seriesID=%s)",
-			episodeID, episodeFk, seasonID, seasonFk, seriesID,
-		)
-
-		episode.ID = episodeID
-		season.ID = seasonID
-		series.ID = seriesID
-
-		episode.SeasonID = episodeFk
-		season.SeriesID = seasonFk
+	log.Verbosef("Saving season %#v with series_id=%s\n", season, series.ID)
+	season.SeriesID = series.ID
+	if err := orchestrator.mediaStore.SaveSeason(tx, season); err != nil {
 		return err
 	}
 
-	return nil
+	log.Verbosef("Saving episode %#v with season_id=%s\n", episode, season.ID)
+	episode.SeasonID = season.ID
+	return orchestrator.mediaStore.SaveEpisode(tx, episode)
 }
 
 func (orchestrator *storeOrchestrator) ListMovie() ([]*media.Movie, error) {
-	return orchestrator.mediaStore.ListMovie(orchestrator.db.GetSqlxDB())
+	return orchestrator.mediaStore.ListMovie(orchestrator.db.Queryable())
 }
 
 func (orchestrator *storeOrchestrator) ListSeries() ([]*media.Series, error) {
-	return orchestrator.mediaStore.ListSeries(orchestrator.db.GetSqlxDB())
+	return orchestrator.mediaStore.ListSeries(orchestrator.db.Queryable())
 }
 
 func (orchestrator *storeOrchestrator) ListGenres() ([]*media.Genre, error) {
-	return orchestrator.mediaStore.ListGenres(orchestrator.db.GetSqlxDB())
+	var genres []*media.Genre
+	if orchestrator.cache.Get(genresCacheKey, &genres) {
+		return genres, nil
+	}
+
+	genres, err := orchestrator.mediaStore.ListGenres(orchestrator.db.Queryable())
+	if err != nil {
+		return nil, err
+	}
+
+	orchestrator.cache.Set(genresCacheKey, genres)
+	return genres, nil
 }
 
-func (orchestrator *storeOrchestrator) ListMedia(
-	includeTypes []media.MediaListType,
-	titleFilter string,
-	includeGenres []int,
-	orderBy []media.MediaListOrderBy,
-	offset int,
-	limit int,
-) ([]*media.MediaListResult, error) {
-	return orchestrator.mediaStore.ListMedia(orchestrator.db.GetSqlxDB(), titleFilter, includeTypes, includeGenres, orderBy, offset, limit)
+// ListMedia lists the movies and series using the options provided (see media.ListMediaOptions),
+// only including the media belonging to the libraries which may be accessed.
+func (orchestrator *storeOrchestrator) ListMedia(opts media.ListMediaOptions, access *library.Access) (*media.MediaListPage, error) {
+	if access != nil && !access.All {
+		opts.Libraries = &media.LibraryFilter{IDs: access.LibraryIDs}
+	}
+
+	if !opts.IncludeTotal {
+		return orchestrator.mediaStore.ListMedia(orchestrator.db.Queryable(), opts)
+	}
+
+	// The page and the total are selected using separate queries, which must observe
+	// the same snapshot of the library for the total to be consistent with the page
+	var page *media.MediaListPage
+	if err := orchestrator.db.WrapReadTx(func(tx *sqlx.Tx) error {
+		p, err := orchestrator.mediaStore.ListMedia(tx, opts)
+		page = p
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return page, nil
 }
 
 func (orchestrator *storeOrchestrator) CountSeasonsInSeries(seriesIDs []uuid.UUID) (map[uuid.UUID]int, error) {
-	return orchestrator.mediaStore.CountSeasonsInSeries(orchestrator.db.GetSqlxDB(), seriesIDs)
+	return orchestrator.mediaStore.CountSeasonsInSeries(orchestrator.db.Queryable(), seriesIDs)
 }
 
 func (orchestrator *storeOrchestrator) GetEpisodesForSeries(seriesID uuid.UUID) ([]*media.Episode, error) {
-	episodes, err := orchestrator.mediaStore.GetEpisodesForSeries(orchestrator.db.GetSqlxDB(), []uuid.UUID{seriesID})
+	episodes, err := orchestrator.mediaStore.GetEpisodesForSeries(orchestrator.db.Queryable(), []uuid.UUID{seriesID})
 	if err != nil {
 		return nil, err
 	}
@@ -236,7 +557,7 @@ func (orchestrator *storeOrchestrator) GetEpisodesForSeries(seriesID uuid.UUID)
 }
 
 func (orchestrator *storeOrchestrator) GetEpisodesForSeason(seasonID uuid.UUID) ([]*media.Episode, error) {
-	episodes, err := orchestrator.mediaStore.GetEpisodesForSeasons(orchestrator.db.GetSqlxDB(), []uuid.UUID{seasonID})
+	episodes, err := orchestrator.mediaStore.GetEpisodesForSeasons(orchestrator.db.Queryable(), []uuid.UUID{seasonID})
 	if err != nil {
 		return nil, err
 	}
@@ -291,9 +612,15 @@ func (orchestrator *storeOrchestrator) GetInflatedSeries(seriesID uuid.UUID) (*m
 			inflatedSeasons[k] = &media.InflatedSeason{Season: v, Episodes: eps}
 		}
 
+		missing, err := orchestrator.mediaStore.GetMissingEpisodes(tx, seriesID)
+		if err != nil {
+			return err
+		}
+
 		inflated = &media.InflatedSeries{
-			Series:  series,
-			Seasons: inflatedSeasons,
+			Series:          series,
+			Seasons:         inflatedSeasons,
+			MissingEpisodes: missing,
 		}
 		return nil
 	}); err != nil {
@@ -303,6 +630,41 @@ func (orchestrator *storeOrchestrator) GetInflatedSeries(seriesID uuid.UUID) (*m
 	return inflated, nil
 }
 
+// GetMissingEpisodes returns the aired episodes of the series which are known to TMDB, but are
+// not present in the library. If the series does not exist, an error is returned.
+func (orchestrator *storeOrchestrator) GetMissingEpisodes(seriesID uuid.UUID) ([]*media.CatalogEpisode, error) {
+	var missing []*media.CatalogEpisode
+	if err := orchestrator.db.WrapReadTx(func(tx *sqlx.Tx) error {
+		if _, err := orchestrator.mediaStore.GetSeries(tx, seriesID); err != nil {
+			return err
+		}
+
+		m, err := orchestrator.mediaStore.GetMissingEpisodes(tx, seriesID)
+		missing = m
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return missing, nil
+}
+
+func (orchestrator *storeOrchestrator) SaveEpisodeCatalog(seriesID uuid.UUID, episodes []*media.CatalogEpisode) error {
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		return orchestrator.mediaStore.SaveEpisodeCatalog(tx, seriesID, episodes)
+	})
+}
+
+// ListWantedEpisodes returns the missing episodes of every monitored series.
+func (orchestrator *storeOrchestrator) ListWantedEpisodes() ([]*media.WantedEpisode, error) {
+	return orchestrator.mediaStore.ListWantedEpisodes(orchestrator.db.Queryable())
+}
+
+// SetSeriesMonitored marks the series with the ID provided as monitored (or not).
+func (orchestrator *storeOrchestrator) SetSeriesMonitored(seriesID uuid.UUID, monitored bool) error {
+	return orchestrator.mediaStore.SetSeriesMonitored(orchestrator.db.Queryable(), seriesID, monitored)
+}
+
 // Transactionally lists all series in the DB, and then submits a second query to fetch the number of seasons
 // associated with the series we found. This information is then packaged inside the SeriesStub struct.
 func (orchestrator *storeOrchestrator) ListSeriesStubs() ([]*media.SeriesStub, error) {
@@ -311,7 +673,7 @@ func (orchestrator *storeOrchestrator) ListSeriesStubs() ([]*media.SeriesStub, e
 	}
 
 	var inflated []*media.SeriesStub
-	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+	if err := orchestrator.db.WrapReadTx(func(tx *sqlx.Tx) error {
 		series, err := orchestrator.mediaStore.ListSeries(tx)
 		if err != nil {
 			return err
@@ -347,19 +709,17 @@ func (orchestrator *storeOrchestrator) ListSeriesStubs() ([]*media.SeriesStub, e
 	return inflated, nil
 }
 
-// ** Media deletion is a little bit tricky, but the general shape is:
-// 1. Fetch completed transcodes for the media (or it's children, if we're deleting a series/season).
-// 2. Delete the above completed transcodes from the database, *and* the filesystem.
-// 3. Now, delete the media itself from the database. This will FAIL if a new transcode for the media
-//	  was inserted between this step and the last due to the use of ON DELETE RESTRICT on the FK
-// 4. Finally, cancel all on-going transcodes (via the event bus) for the relevant medias now that we've dealt with the
-//    database entries.
+// ** Media deletion is performed in two stages:
+// 1. When media is deleted, it is moved to the trash (soft-deleted) and all on-going transcodes for
+//    the affected medias are cancelled (via the event bus). Completed transcodes are left untouched so
+//    that the media can be restored from the trash.
+// 2. Once the trash retention window has elapsed, the trash janitor purges the media. This involves
+//    deleting the completed transcodes for the media from the database *and* the filesystem, before
+//    deleting the media itself from the database. The latter will FAIL if a transcode for the media
+//    remains due to the use of ON DELETE RESTRICT on the FK.
 
 func (orchestrator *storeOrchestrator) DeleteMovie(movieID uuid.UUID) error {
-	if err := orchestrator.DeleteTranscodesForMedia(movieID); err != nil {
-		return fmt.Errorf("failed to delete existing transcodes: %w", err)
-	}
-	if err := orchestrator.mediaStore.DeleteMovie(orchestrator.db.GetSqlxDB(), movieID); err != nil {
+	if err := orchestrator.mediaStore.TrashMovie(orchestrator.db.Queryable(), movieID); err != nil {
 		return err
 	}
 
@@ -373,20 +733,12 @@ func (orchestrator *storeOrchestrator) DeleteSeries(seriesID uuid.UUID) error {
 		return err
 	}
 
-	episodeIDs := make([]uuid.UUID, len(episodes))
-	for k, v := range episodes {
-		episodeIDs[k] = v.ID
-	}
-
-	if err := orchestrator.DeleteTranscodesForMedias(episodeIDs); err != nil {
-		return fmt.Errorf("failed to delete existing transcodes: %w", err)
-	}
-	if err := orchestrator.mediaStore.DeleteSeries(orchestrator.db.GetSqlxDB(), seriesID); err != nil {
+	if err := orchestrator.mediaStore.TrashSeries(orchestrator.db.Queryable(), seriesID); err != nil {
 		return err
 	}
 
-	for _, id := range episodeIDs {
-		orchestrator.ev.Dispatch(event.DeleteMediaEvent, id)
+	for _, episode := range episodes {
+		orchestrator.ev.Dispatch(event.DeleteMediaEvent, episode.ID)
 	}
 
 	return nil
@@ -398,30 +750,19 @@ func (orchestrator *storeOrchestrator) DeleteSeason(seasonID uuid.UUID) error {
 		return err
 	}
 
-	episodeIDs := make([]uuid.UUID, len(episodes))
-	for k, v := range episodes {
-		episodeIDs[k] = v.ID
-	}
-
-	if err := orchestrator.DeleteTranscodesForMedias(episodeIDs); err != nil {
-		return fmt.Errorf("failed to delete existing transcodes: %w", err)
-	}
-	if err := orchestrator.mediaStore.DeleteSeason(orchestrator.db.GetSqlxDB(), seasonID); err != nil {
+	if err := orchestrator.mediaStore.TrashSeason(orchestrator.db.Queryable(), seasonID); err != nil {
 		return err
 	}
 
-	for _, id := range episodeIDs {
-		orchestrator.ev.Dispatch(event.DeleteMediaEvent, id)
+	for _, episode := range episodes {
+		orchestrator.ev.Dispatch(event.DeleteMediaEvent, episode.ID)
 	}
 
 	return nil
 }
 
 func (orchestrator *storeOrchestrator) DeleteEpisode(episodeID uuid.UUID) error {
-	if err := orchestrator.DeleteTranscodesForMedia(episodeID); err != nil {
-		return fmt.Errorf("failed to delete existing transcodes: %w", err)
-	}
-	if err := orchestrator.mediaStore.DeleteEpisode(orchestrator.db.GetSqlxDB(), episodeID); err != nil {
+	if err := orchestrator.mediaStore.TrashEpisode(orchestrator.db.Queryable(), episodeID); err != nil {
 		return err
 	}
 
@@ -429,43 +770,212 @@ func (orchestrator *storeOrchestrator) DeleteEpisode(episodeID uuid.UUID) error
 	return nil
 }
 
-// Workflows
-
-// CreateWorkflow uses the information provided to construct and save a new workflow
-// in a single DB transaction.
-//
-// Error will be returned if any of the target IDs provided do not refer to existing Target
-// DB entries, or if the workflow infringes on any uniqueness constraints (label).
-func (orchestrator *storeOrchestrator) CreateWorkflow(workflowID uuid.UUID, label string, criteria []match.Criteria, targetIDs []uuid.UUID, enabled bool) (*workflow.Workflow, error) {
-	db := orchestrator.db.GetSqlxDB()
-	if err := orchestrator.workflowStore.Create(db, workflowID, label, enabled, targetIDs, criteria); err != nil {
-		return nil, err
-	}
-
-	return orchestrator.workflowStore.Get(db, workflowID), nil
+func (orchestrator *storeOrchestrator) ListTrash() ([]*media.TrashedItem, error) {
+	return orchestrator.mediaStore.ListTrash(orchestrator.db.Queryable())
 }
 
-// UpdateWorkflow transactionally updates an existing Workflow model
-// using the optional parameters provided. If a param is `nil` then the
-// corresponding value in the model is NOT changed.
-func (orchestrator *storeOrchestrator) UpdateWorkflow(workflowID uuid.UUID, newLabel *string, newCriteria *[]match.Criteria, newTargetIDs *[]uuid.UUID, newEnabled *bool) (*workflow.Workflow, error) {
-	fail := func(desc string, err error) error {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) {
-			if pqErr.Code == PgFkConstraintViolationCode && pqErr.Table == "workflow_transcode_targets" {
-				log.Debugf("DB query failure; apparent target ID FK violation %#v\n", err)
-				return ErrWorkflowTargetIDMissing
+// RestoreFromTrash restores the trashed series, season, movie or episode with the given ID. Items
+// which were trashed alongside the restored item (e.g. the episodes of a season) are also restored.
+// If the ID does not refer to an item in the trash, or the items parent is in the trash, then
+// media.ErrNotRestorable is returned.
+func (orchestrator *storeOrchestrator) RestoreFromTrash(id uuid.UUID) error {
+	// The movies/episodes restored are not known, so all cached media is evicted
+	defer orchestrator.cache.DeletePrefix(mediaCachePrefix)
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		restorers := []func(database.Queryable, uuid.UUID) error{
+			orchestrator.mediaStore.RestoreSeries,
+			orchestrator.mediaStore.RestoreSeason,
+			orchestrator.mediaStore.RestoreMedia,
+		}
+		for _, restore := range restorers {
+			if err := restore(tx, id); !errors.Is(err, media.ErrNotRestorable) {
+				return err
 			}
 		}
 
-		log.Errorf("Unexpected query failure: %v\n", err)
-		return fmt.Errorf("failed to %s due to unexpected query error: %w", desc, err)
-	}
+		return media.ErrNotRestorable
+	})
+}
 
-	err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
-		if newLabel != nil || newEnabled != nil {
-			if err := orchestrator.workflowStore.UpdateWorkflowTx(tx, workflowID, newLabel, newEnabled); err != nil {
-				return fail("update workflow row", err)
+// UpdateMetadata applies manual corrections to the metadata of the movie, episode, season or series
+// with the given ID, and locks the corrected fields so that they are not overwritten if the item is
+// re-ingested. If allowed types are provided, the item must be one of these types. If the ID does not
+// refer to a (non-trashed) item of an allowed type, sql.ErrNoRows is returned.
+//
+// An UpdateMediaEvent is dispatched for the movie/episode corrected, or for each of the episodes
+// of the season/series corrected.
+func (orchestrator *storeOrchestrator) UpdateMetadata(id uuid.UUID, update media.MetadataUpdate, allowedTypes ...media.MetadataItemType) (*media.MetadataItem, error) {
+	var item *media.MetadataItem
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		var err error
+		item, err = orchestrator.mediaStore.GetMetadataItem(tx, id)
+		if err != nil {
+			return err
+		}
+		if len(allowedTypes) > 0 && !slices.Contains(allowedTypes, item.Type) {
+			return fmt.Errorf("%s is a %s, expected one of %v: %w", id, item.Type, allowedTypes, sql.ErrNoRows)
+		}
+
+		if err := orchestrator.mediaStore.UpdateMetadata(tx, item, update); err != nil {
+			return err
+		}
+
+		if update.Genres == nil {
+			return nil
+		}
+
+		genreModels := make([]*media.Genre, len(update.Genres))
+		for k, label := range update.Genres {
+			genreModels[k] = &media.Genre{Label: label}
+		}
+		genres, err := orchestrator.mediaStore.SaveGenres(tx, genreModels)
+		if err != nil {
+			return err
+		}
+
+		if item.Type == media.MetadataSeries {
+			return orchestrator.mediaStore.SaveSeriesGenreAssociations(tx, item.ID, genres)
+		}
+		return orchestrator.mediaStore.SaveMovieGenreAssociations(tx, item.ID, genres)
+	}); err != nil {
+		return nil, err
+	}
+
+	orchestrator.evictMedia(item.ID)
+
+	var episodes []*media.Episode
+	var err error
+	//exhaustive:enforce
+	switch item.Type {
+	case media.MetadataMovie, media.MetadataEpisode:
+		orchestrator.ev.Dispatch(event.UpdateMediaEvent, item.ID)
+		return item, nil
+	case media.MetadataSeason:
+		episodes, err = orchestrator.GetEpisodesForSeason(item.ID)
+	case media.MetadataSeries:
+		episodes, err = orchestrator.GetEpisodesForSeries(item.ID)
+	}
+	if err != nil {
+		log.Warnf("Failed to notify of metadata update for episodes of %s %s: %v\n", item.Type, item.ID, err)
+		return item, nil
+	}
+
+	for _, episode := range episodes {
+		orchestrator.ev.Dispatch(event.UpdateMediaEvent, episode.ID)
+	}
+
+	return item, nil
+}
+
+// SetLockedFields replaces the locked metadata fields of the movie, episode, season or series with the
+// given ID. If the ID does not refer to a (non-trashed) item, sql.ErrNoRows is returned.
+func (orchestrator *storeOrchestrator) SetLockedFields(id uuid.UUID, fields []media.MetadataField) (*media.MetadataItem, error) {
+	var item *media.MetadataItem
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		var err error
+		item, err = orchestrator.mediaStore.GetMetadataItem(tx, id)
+		if err != nil {
+			return err
+		}
+
+		return orchestrator.mediaStore.SetLockedFields(tx, item, fields)
+	}); err != nil {
+		return nil, err
+	}
+
+	orchestrator.evictMedia(item.ID)
+
+	return item, nil
+}
+
+// PurgeTrash permanently deletes all media which was moved to the trash before the
+// given time, including all related transcodes (from both the database and the filesystem).
+// The number of movies/episodes purged is returned.
+func (orchestrator *storeOrchestrator) PurgeTrash(trashedBefore time.Time) (int, error) {
+	mediaIDs, err := orchestrator.mediaStore.ListTrashedMediaIDs(orchestrator.db.Queryable(), trashedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(mediaIDs) > 0 {
+		if err := orchestrator.DeleteTranscodesForMedias(mediaIDs); err != nil {
+			return 0, fmt.Errorf("failed to delete existing transcodes: %w", err)
+		}
+	}
+
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		return orchestrator.mediaStore.PurgeTrash(tx, trashedBefore)
+	}); err != nil {
+		return 0, err
+	}
+
+	return len(mediaIDs), nil
+}
+
+// ListDeletionCandidates returns the movies and episodes matching the bulk deletion filter provided.
+func (orchestrator *storeOrchestrator) ListDeletionCandidates(filter media.DeletionFilter) ([]*media.DeletionCandidate, error) {
+	return orchestrator.mediaStore.ListDeletionCandidates(orchestrator.db.Queryable(), filter)
+}
+
+// PurgeMedia permanently deletes the movies/episodes with the given IDs, bypassing the trash. The
+// transcodes of the media are deleted (from both the database and the filesystem) first, and
+// a DeleteMediaEvent is dispatched for each media so that any on-going transcodes are cancelled.
+func (orchestrator *storeOrchestrator) PurgeMedia(mediaIDs []uuid.UUID) error {
+	if err := orchestrator.DeleteTranscodesForMedias(mediaIDs); err != nil {
+		return fmt.Errorf("failed to delete existing transcodes: %w", err)
+	}
+
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		return orchestrator.mediaStore.PurgeMedia(tx, mediaIDs)
+	}); err != nil {
+		return err
+	}
+
+	for _, mediaID := range mediaIDs {
+		orchestrator.ev.Dispatch(event.DeleteMediaEvent, mediaID)
+	}
+
+	return nil
+}
+
+// Workflows
+
+// CreateWorkflow uses the information provided to construct and save a new workflow
+// in a single DB transaction.
+//
+// Error will be returned if any of the target IDs provided do not refer to existing Target
+// DB entries, or if the workflow infringes on any uniqueness constraints (label).
+func (orchestrator *storeOrchestrator) CreateWorkflow(workflowID uuid.UUID, label string, criteria []match.Criteria, targetIDs []uuid.UUID, enabled bool, sequential bool, hooks workflow.Hooks) (*workflow.Workflow, error) {
+	db := orchestrator.db.GetSqlxDB()
+	if err := orchestrator.workflowStore.Create(db, workflowID, label, enabled, sequential, targetIDs, criteria, hooks); err != nil {
+		return nil, err
+	}
+
+	orchestrator.ev.Dispatch(event.WorkflowUpdateEvent, workflowID)
+	return orchestrator.workflowStore.Get(db, workflowID), nil
+}
+
+// UpdateWorkflow transactionally updates an existing Workflow model
+// using the optional parameters provided. If a param is `nil` then the
+// corresponding value in the model is NOT changed.
+func (orchestrator *storeOrchestrator) UpdateWorkflow(workflowID uuid.UUID, newLabel *string, newCriteria *[]match.Criteria, newTargetIDs *[]uuid.UUID, newEnabled *bool, newSequential *bool, newHooks *workflow.Hooks) (*workflow.Workflow, error) {
+	fail := func(desc string, err error) error {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			if pqErr.Code == PgFkConstraintViolationCode && pqErr.Table == "workflow_transcode_targets" {
+				log.Debugf("DB query failure; apparent target ID FK violation %#v\n", err)
+				return ErrWorkflowTargetIDMissing
+			}
+		}
+
+		log.Errorf("Unexpected query failure: %v\n", err)
+		return fmt.Errorf("failed to %s due to unexpected query error: %w", desc, err)
+	}
+
+	err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if newLabel != nil || newEnabled != nil || newSequential != nil || newHooks != nil {
+			if err := orchestrator.workflowStore.UpdateWorkflowTx(tx, workflowID, newLabel, newEnabled, newSequential, newHooks); err != nil {
+				return fail("update workflow row", err)
 			}
 		}
 		if newCriteria != nil {
@@ -485,46 +995,135 @@ func (orchestrator *storeOrchestrator) UpdateWorkflow(workflowID uuid.UUID, newL
 		return nil, err
 	}
 
-	return orchestrator.workflowStore.Get(orchestrator.db.GetSqlxDB(), workflowID), nil
+	orchestrator.ev.Dispatch(event.WorkflowUpdateEvent, workflowID)
+	return orchestrator.workflowStore.Get(orchestrator.db.Queryable(), workflowID), nil
 }
 
 func (orchestrator *storeOrchestrator) GetWorkflow(id uuid.UUID) *workflow.Workflow {
-	return orchestrator.workflowStore.Get(orchestrator.db.GetSqlxDB(), id)
+	var wf *workflow.Workflow
+	if orchestrator.cache.Get(workflowCacheKey(id), &wf) {
+		return wf
+	}
+
+	if wf = orchestrator.workflowStore.Get(orchestrator.db.Queryable(), id); wf != nil {
+		orchestrator.cache.Set(workflowCacheKey(id), wf)
+	}
+	return wf
 }
 
 func (orchestrator *storeOrchestrator) GetAllWorkflows() []*workflow.Workflow {
-	all := orchestrator.workflowStore.GetAll(orchestrator.db.GetSqlxDB())
+	var all []*workflow.Workflow
+	if orchestrator.cache.Get(workflowsCacheKey, &all) {
+		return all
+	}
+
+	if all = orchestrator.workflowStore.GetAll(orchestrator.db.Queryable()); len(all) > 0 {
+		orchestrator.cache.Set(workflowsCacheKey, all)
+	}
 	return all
 }
 
 func (orchestrator *storeOrchestrator) DeleteWorkflow(id uuid.UUID) {
-	orchestrator.workflowStore.Delete(orchestrator.db.GetSqlxDB(), id)
+	orchestrator.workflowStore.Delete(orchestrator.db.Queryable(), id)
+	orchestrator.ev.Dispatch(event.WorkflowUpdateEvent, id)
 }
 
 // Transcodes
 
-func (orchestrator *storeOrchestrator) SaveTranscode(transcode *transcode.TranscodeTask) error {
-	return orchestrator.transcodeStore.SaveTranscode(orchestrator.db.GetSqlxDB(), transcode)
+// SaveTranscode transactionally saves the completed transcode task, and
+// records the successful outcome of the task.
+func (orchestrator *storeOrchestrator) SaveTranscode(task *transcode.TranscodeTask) error {
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.transcodeStore.SaveTranscode(tx, task); err != nil {
+			return err
+		}
+
+		return orchestrator.transcodeStore.SaveOutcome(tx, task, transcode.OutcomeComplete)
+	})
+}
+
+// SaveRecoveredTranscode transactionally saves a completed transcode which could
+// not be saved when the task completed (see transcode.RecoveredTranscode).
+func (orchestrator *storeOrchestrator) SaveRecoveredTranscode(record *transcode.RecoveredTranscode) error {
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		return orchestrator.transcodeStore.SaveRecovered(tx, record)
+	})
+}
+
+// SavePassthroughTranscode records the source of a media as the transcode of a target, as
+// the source already satisfies the target.
+func (orchestrator *storeOrchestrator) SavePassthroughTranscode(passthrough *transcode.Transcode) error {
+	return orchestrator.transcodeStore.SavePassthrough(orchestrator.db.Queryable(), passthrough)
+}
+
+// SaveTranscodeFailure records the failed outcome of the transcode task.
+func (orchestrator *storeOrchestrator) SaveTranscodeFailure(task *transcode.TranscodeTask) error {
+	return orchestrator.transcodeStore.SaveOutcome(orchestrator.db.Queryable(), task, transcode.OutcomeFailed)
+}
+
+// SaveTranscodeCancellation records the cancelled outcome of the transcode task.
+func (orchestrator *storeOrchestrator) SaveTranscodeCancellation(task *transcode.TranscodeTask) error {
+	return orchestrator.transcodeStore.SaveOutcome(orchestrator.db.Queryable(), task, transcode.OutcomeCancelled)
+}
+
+// ListTranscodeHistory returns the concluded transcode tasks matching the filter provided.
+func (orchestrator *storeOrchestrator) ListTranscodeHistory(filter transcode.HistoryFilter) ([]*transcode.HistoryEntry, error) {
+	return orchestrator.transcodeStore.ListHistory(orchestrator.db.Queryable(), filter)
+}
+
+// PruneTranscodeHistory deletes the history of the transcode tasks which concluded
+// before the time provided, returning the number of entries deleted.
+func (orchestrator *storeOrchestrator) PruneTranscodeHistory(concludedBefore time.Time) (int, error) {
+	return orchestrator.transcodeStore.PruneHistory(orchestrator.db.Queryable(), concludedBefore)
+}
+
+func (orchestrator *storeOrchestrator) GetTranscodeOutput(id uuid.UUID) (string, error) {
+	return orchestrator.transcodeStore.GetOutput(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) GetTranscodeStatistics(since time.Time) (*transcode.Statistics, error) {
+	return orchestrator.transcodeStore.GetStatistics(orchestrator.db.Queryable(), since)
 }
 
 func (orchestrator *storeOrchestrator) GetTranscode(id uuid.UUID) *transcode.Transcode {
-	return orchestrator.transcodeStore.Get(orchestrator.db.GetSqlxDB(), id)
+	return orchestrator.transcodeStore.Get(orchestrator.db.Queryable(), id)
 }
 
 func (orchestrator *storeOrchestrator) GetAllTranscodes() ([]*transcode.Transcode, error) {
-	return orchestrator.transcodeStore.GetAll(orchestrator.db.GetSqlxDB())
+	return orchestrator.transcodeStore.GetAll(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) SetTranscodeDegraded(id uuid.UUID, degraded bool) error {
+	return orchestrator.transcodeStore.SetDegraded(orchestrator.db.Queryable(), id, degraded)
+}
+
+func (orchestrator *storeOrchestrator) SetTranscodeChecksum(id uuid.UUID, checksum string) error {
+	return orchestrator.transcodeStore.SetChecksum(orchestrator.db.Queryable(), id, checksum)
+}
+
+func (orchestrator *storeOrchestrator) SetTranscodeCorrupted(id uuid.UUID, corrupted bool) error {
+	return orchestrator.transcodeStore.SetCorrupted(orchestrator.db.Queryable(), id, corrupted)
 }
 
 func (orchestrator *storeOrchestrator) GetTranscodesForMedia(mediaID uuid.UUID) ([]*transcode.Transcode, error) {
-	return orchestrator.transcodeStore.GetForMedia(orchestrator.db.GetSqlxDB(), mediaID)
+	return orchestrator.transcodeStore.GetForMedia(orchestrator.db.Queryable(), mediaID)
+}
+
+func (orchestrator *storeOrchestrator) GetTranscodesForMedias(mediaIDs []uuid.UUID) ([]*transcode.Transcode, error) {
+	return orchestrator.transcodeStore.GetForMedias(orchestrator.db.Queryable(), mediaIDs)
 }
 
 func (orchestrator *storeOrchestrator) DeleteTranscode(id uuid.UUID) error {
-	transcodePath, err := orchestrator.transcodeStore.Delete(orchestrator.db.GetSqlxDB(), id)
+	transcodePath, err := orchestrator.transcodeStore.Delete(orchestrator.db.Queryable(), id)
 	if err != nil {
 		return err
 	}
 
+	if transcodePath == "" {
+		// Passthrough transcodes reference the source of the media, which must be retained
+		return nil
+	}
+
 	if err := os.Remove(transcodePath); err != nil {
 		log.Warnf("Cleanup of transcode at path '%s' failed: %v\n", transcodePath, err)
 	}
@@ -532,17 +1131,17 @@ func (orchestrator *storeOrchestrator) DeleteTranscode(id uuid.UUID) error {
 	return nil
 }
 
-func (orchestrator *storeOrchestrator) DeleteTranscodesForMedia(mediaID uuid.UUID) error {
-	return orchestrator.DeleteTranscodesForMedias([]uuid.UUID{mediaID})
-}
-
 func (orchestrator *storeOrchestrator) DeleteTranscodesForMedias(mediaIDs []uuid.UUID) error {
-	paths, err := orchestrator.transcodeStore.DeleteForMedias(orchestrator.db.GetSqlxDB(), mediaIDs)
+	paths, err := orchestrator.transcodeStore.DeleteForMedias(orchestrator.db.Queryable(), mediaIDs)
 	if err != nil {
 		return err
 	}
 
 	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+
 		if err := os.Remove(path); err != nil {
 			log.Warnf("Cleanup of transcode at path '%s' failed: %v\n", path, err)
 		}
@@ -551,45 +1150,113 @@ func (orchestrator *storeOrchestrator) DeleteTranscodesForMedias(mediaIDs []uuid
 	return nil
 }
 
-func (orchestrator *storeOrchestrator) GetForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID) (*transcode.Transcode, error) {
-	return orchestrator.transcodeStore.GetForMediaAndTarget(orchestrator.db.GetSqlxDB(), mediaID, targetID)
+func (orchestrator *storeOrchestrator) GetForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) (*transcode.Transcode, error) {
+	return orchestrator.transcodeStore.GetForMediaAndTarget(orchestrator.db.Queryable(), mediaID, targetID, versionID)
+}
+
+func (orchestrator *storeOrchestrator) RecordTranscodePlaybackStart(id uuid.UUID) error {
+	return orchestrator.transcodeStore.RecordPlaybackStart(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) RecordTranscodePlaybackCompletion(id uuid.UUID) error {
+	return orchestrator.transcodeStore.RecordPlaybackCompletion(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) GetTargetPopularity() ([]*transcode.TargetPopularity, error) {
+	return orchestrator.transcodeStore.GetTargetPopularity(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) ListTranscodeReclaimCandidates() ([]*transcode.ReclaimCandidate, error) {
+	return orchestrator.transcodeStore.ListReclaimCandidates(orchestrator.db.Queryable())
+}
+
+// SetTranscodeLocation updates the path, and storage pool, of a transcode whose output was moved.
+func (orchestrator *storeOrchestrator) SetTranscodeLocation(id uuid.UUID, path string, pool string) error {
+	return orchestrator.transcodeStore.SetLocation(orchestrator.db.Queryable(), id, path, pool)
+}
+
+func (orchestrator *storeOrchestrator) SaveTranscodeQueueSnapshot(tasks []transcode.QueuedTask) error {
+	return orchestrator.transcodeStore.SaveQueueSnapshot(orchestrator.db.Queryable(), tasks)
+}
+
+func (orchestrator *storeOrchestrator) PopTranscodeQueueSnapshot() ([]transcode.QueuedTask, error) {
+	return orchestrator.transcodeStore.PopQueueSnapshot(orchestrator.db.Queryable())
 }
 
 // Targets
 
 func (orchestrator *storeOrchestrator) SaveTarget(target *ffmpeg.Target) error {
-	return orchestrator.targetStore.Save(orchestrator.db.GetSqlxDB(), target)
+	if err := orchestrator.targetStore.Save(orchestrator.db.Queryable(), target); err != nil {
+		return err
+	}
+
+	orchestrator.ev.Dispatch(event.TargetUpdateEvent, target.ID)
+	return nil
+}
+
+func (orchestrator *storeOrchestrator) SaveTargetBenchmark(targetID uuid.UUID, benchmark *ffmpeg.TargetBenchmark) error {
+	if err := orchestrator.targetStore.SaveBenchmark(orchestrator.db.Queryable(), targetID, benchmark); err != nil {
+		return err
+	}
+
+	orchestrator.ev.Dispatch(event.TargetUpdateEvent, targetID)
+	return nil
 }
 
 func (orchestrator *storeOrchestrator) GetTarget(id uuid.UUID) *ffmpeg.Target {
-	return orchestrator.targetStore.Get(orchestrator.db.GetSqlxDB(), id)
+	var target *ffmpeg.Target
+	if orchestrator.cache.Get(targetCacheKey(id), &target) {
+		return target
+	}
+
+	if target = orchestrator.targetStore.Get(orchestrator.db.Queryable(), id); target != nil {
+		orchestrator.cache.Set(targetCacheKey(id), target)
+	}
+	return target
 }
 
 func (orchestrator *storeOrchestrator) GetAllTargets() []*ffmpeg.Target {
-	return orchestrator.targetStore.GetAll(orchestrator.db.GetSqlxDB())
+	var all []*ffmpeg.Target
+	if orchestrator.cache.Get(targetsCacheKey, &all) {
+		return all
+	}
+
+	if all = orchestrator.targetStore.GetAll(orchestrator.db.Queryable()); len(all) > 0 {
+		orchestrator.cache.Set(targetsCacheKey, all)
+	}
+	return all
 }
 
 func (orchestrator *storeOrchestrator) GetManyTargets(ids ...uuid.UUID) []*ffmpeg.Target {
-	return orchestrator.targetStore.GetMany(orchestrator.db.GetSqlxDB(), ids...)
+	return orchestrator.targetStore.GetMany(orchestrator.db.Queryable(), ids...)
 }
 
 func (orchestrator *storeOrchestrator) DeleteTarget(id uuid.UUID) {
-	orchestrator.targetStore.Delete(orchestrator.db.GetSqlxDB(), id)
+	orchestrator.targetStore.Delete(orchestrator.db.Queryable(), id)
+	orchestrator.ev.Dispatch(event.TargetUpdateEvent, id)
 }
 
 // User Management
 
 func (orchestrator *storeOrchestrator) GetUserWithUsernameAndPassword(username []byte, password []byte) (*user.User, error) {
-	return orchestrator.userStore.GetWithUsernameAndPassword(orchestrator.db.GetSqlxDB(), username, password)
+	return orchestrator.userStore.GetWithUsernameAndPassword(orchestrator.db.Queryable(), username, password)
 }
 
 func (orchestrator *storeOrchestrator) GetUserWithID(id uuid.UUID) (*user.User, error) {
-	return orchestrator.userStore.GetWithID(orchestrator.db.GetSqlxDB(), id)
+	return orchestrator.userStore.GetWithID(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) GetUserWithUsername(username []byte) (*user.User, error) {
+	return orchestrator.userStore.GetWithUsername(orchestrator.db.Queryable(), username)
+}
+
+func (orchestrator *storeOrchestrator) UpdateUserPassword(userID uuid.UUID, password []byte) error {
+	return orchestrator.userStore.UpdatePassword(orchestrator.db.Queryable(), userID, password)
 }
 
 func (orchestrator *storeOrchestrator) CreateUser(username []byte, password []byte, permissions ...string) (*user.User, error) {
 	if len(permissions) == 0 {
-		return orchestrator.userStore.Create(orchestrator.db.GetSqlxDB(), username, password)
+		return orchestrator.userStore.Create(orchestrator.db.Queryable(), username, password)
 	}
 
 	var outputUser *user.User
@@ -609,15 +1276,15 @@ func (orchestrator *storeOrchestrator) CreateUser(username []byte, password []by
 }
 
 func (orchestrator *storeOrchestrator) ListUsers() ([]*user.User, error) {
-	return orchestrator.userStore.List(orchestrator.db.GetSqlxDB())
+	return orchestrator.userStore.List(orchestrator.db.Queryable())
 }
 
 func (orchestrator *storeOrchestrator) RecordUserLogin(userID uuid.UUID) error {
-	return orchestrator.userStore.RecordLogin(orchestrator.db.GetSqlxDB(), userID)
+	return orchestrator.userStore.RecordLogin(orchestrator.db.Queryable(), userID)
 }
 
 func (orchestrator *storeOrchestrator) RecordUserRefresh(userID uuid.UUID) error {
-	return orchestrator.userStore.RecordRefresh(orchestrator.db.GetSqlxDB(), userID)
+	return orchestrator.userStore.RecordRefresh(orchestrator.db.Queryable(), userID)
 }
 
 func (orchestrator *storeOrchestrator) UpdateUserPermissions(userID uuid.UUID, newPermissions []string) error {
@@ -634,16 +1301,237 @@ func (orchestrator *storeOrchestrator) updateUserPermissionsQuery(tx *sqlx.Tx, u
 	}
 
 	if len(newPermissions) > 0 {
-		perms, err := orchestrator.userStore.GetPermissionsByLabel(tx, newPermissions)
+		perms, err := orchestrator.getPermissionsByLabelQuery(tx, newPermissions)
 		if err != nil {
 			return err
 		}
 
-		if len(perms) != len(newPermissions) {
-			return errors.New("permissions provided are invalid")
+		if err := orchestrator.userStore.InsertUserPermissions(tx, userID, perms); err != nil {
+			return err
 		}
+	}
 
-		if err := orchestrator.userStore.InsertUserPermissions(tx, userID, perms); err != nil {
+	return nil
+}
+
+// UpdateUserRoles replaces the roles the user is a member of with the
+// roles provided. If any of the role IDs do not refer to an existing role
+// then the update fails and ErrUserRoleIDMissing is returned.
+func (orchestrator *storeOrchestrator) UpdateUserRoles(userID uuid.UUID, newRoleIDs []uuid.UUID) error {
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.userStore.DropUserRoles(tx, userID); err != nil {
+			return err
+		}
+
+		if err := orchestrator.userStore.RecordUpdate(tx, userID); err != nil {
+			return err
+		}
+
+		if len(newRoleIDs) > 0 {
+			if err := orchestrator.userStore.InsertUserRoles(tx, userID, newRoleIDs); err != nil {
+				var pqErr *pq.Error
+				if errors.As(err, &pqErr) && pqErr.Code == PgFkConstraintViolationCode && pqErr.Table == "user_roles" {
+					return ErrUserRoleIDMissing
+				}
+
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Invites
+
+// CreateInvite transactionally creates a new invite, and the associations to the
+// permissions and roles the invite grants. The token returned is required to
+// consume the invite and cannot be retrieved again, as only it's hash is persisted.
+func (orchestrator *storeOrchestrator) CreateInvite(inviteID uuid.UUID, createdBy uuid.UUID, expiresAt time.Time, permissions []string, roleIDs []uuid.UUID) (*user.Invite, string, error) {
+	token, err := user.NewInviteToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.userStore.CreateInvite(tx, inviteID, token, createdBy, expiresAt); err != nil {
+			return err
+		}
+
+		if len(permissions) > 0 {
+			perms, err := orchestrator.getPermissionsByLabelQuery(tx, permissions)
+			if err != nil {
+				return err
+			}
+
+			if err := orchestrator.userStore.InsertInvitePermissions(tx, inviteID, perms); err != nil {
+				return err
+			}
+		}
+
+		if len(roleIDs) > 0 {
+			if err := orchestrator.userStore.InsertInviteRoles(tx, inviteID, roleIDs); err != nil {
+				var pqErr *pq.Error
+				if errors.As(err, &pqErr) && pqErr.Code == PgFkConstraintViolationCode && pqErr.Table == "invites_roles" {
+					return ErrUserRoleIDMissing
+				}
+
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, "", err
+	}
+
+	invite, err := orchestrator.userStore.GetInvite(orchestrator.db.Queryable(), inviteID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return invite, token, nil
+}
+
+func (orchestrator *storeOrchestrator) ListInvites() ([]*user.Invite, error) {
+	return orchestrator.userStore.ListInvites(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) DeleteInvite(inviteID uuid.UUID) error {
+	return orchestrator.userStore.DeleteInvite(orchestrator.db.Queryable(), inviteID)
+}
+
+// RegisterUserWithInvite transactionally creates a new user, consuming the
+// invite matching the token provided and granting the new user the
+// permissions and roles that the invite is bound to. If the invite
+// is not valid then no user is created.
+func (orchestrator *storeOrchestrator) RegisterUserWithInvite(token string, username []byte, password []byte) (*user.User, error) {
+	var userID uuid.UUID
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		invite, err := orchestrator.userStore.ConsumeInviteTx(tx, token)
+		if err != nil {
+			return err
+		}
+
+		newUser, err := orchestrator.userStore.Create(tx, username, password)
+		if err != nil {
+			return err
+		}
+		userID = newUser.ID
+
+		if err := orchestrator.userStore.RecordInviteConsumer(tx, invite.ID, userID); err != nil {
+			return err
+		}
+
+		if err := orchestrator.updateUserPermissionsQuery(tx, userID, invite.Permissions); err != nil {
+			return err
+		}
+
+		if len(invite.Roles) > 0 {
+			return orchestrator.userStore.InsertUserRoles(tx, userID, invite.Roles)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return orchestrator.userStore.GetWithID(orchestrator.db.Queryable(), userID)
+}
+
+// Share links
+
+// CreateShareLink creates the share link provided, protected by the password given (if not empty).
+// Expired share links are deleted at the same time, as they can no longer be used.
+func (orchestrator *storeOrchestrator) CreateShareLink(link *user.ShareLink, password []byte) error {
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.userStore.DeleteExpiredShareLinks(tx); err != nil {
+			return err
+		}
+
+		return orchestrator.userStore.CreateShareLink(tx, link, password)
+	})
+}
+
+func (orchestrator *storeOrchestrator) GetShareLink(linkID uuid.UUID) (*user.ShareLink, error) {
+	return orchestrator.userStore.GetShareLink(orchestrator.db.Queryable(), linkID)
+}
+
+func (orchestrator *storeOrchestrator) ListShareLinks(createdBy uuid.UUID) ([]*user.ShareLink, error) {
+	return orchestrator.userStore.ListShareLinks(orchestrator.db.Queryable(), createdBy)
+}
+
+func (orchestrator *storeOrchestrator) DeleteShareLink(linkID uuid.UUID, createdBy uuid.UUID) error {
+	return orchestrator.userStore.DeleteShareLink(orchestrator.db.Queryable(), linkID, createdBy)
+}
+
+func (orchestrator *storeOrchestrator) VerifySharePassword(link *user.ShareLink, password []byte) error {
+	return orchestrator.userStore.VerifySharePassword(link, password)
+}
+
+// Roles
+
+// CreateRole transactionally creates a new role, and the associations
+// to the permissions provided.
+func (orchestrator *storeOrchestrator) CreateRole(roleID uuid.UUID, label string, permissions []string) (*user.Role, error) {
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.userStore.CreateRole(tx, roleID, label); err != nil {
+			return err
+		}
+
+		return orchestrator.updateRolePermissionsQuery(tx, roleID, permissions)
+	}); err != nil {
+		return nil, err
+	}
+
+	return orchestrator.userStore.GetRole(orchestrator.db.Queryable(), roleID)
+}
+
+// UpdateRole transactionally updates an existing role using the optional
+// parameters provided. If a param is `nil` then the corresponding value
+// in the model is NOT changed.
+func (orchestrator *storeOrchestrator) UpdateRole(roleID uuid.UUID, newLabel *string, newPermissions *[]string) (*user.Role, error) {
+	if err := orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.userStore.UpdateRoleTx(tx, roleID, newLabel); err != nil {
+			return err
+		}
+
+		if newPermissions != nil {
+			return orchestrator.updateRolePermissionsQuery(tx, roleID, *newPermissions)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return orchestrator.userStore.GetRole(orchestrator.db.Queryable(), roleID)
+}
+
+func (orchestrator *storeOrchestrator) GetRole(roleID uuid.UUID) (*user.Role, error) {
+	return orchestrator.userStore.GetRole(orchestrator.db.Queryable(), roleID)
+}
+
+func (orchestrator *storeOrchestrator) ListRoles() ([]*user.Role, error) {
+	return orchestrator.userStore.ListRoles(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) DeleteRole(roleID uuid.UUID) error {
+	return orchestrator.userStore.DeleteRole(orchestrator.db.Queryable(), roleID)
+}
+
+func (orchestrator *storeOrchestrator) updateRolePermissionsQuery(tx *sqlx.Tx, roleID uuid.UUID, newPermissions []string) error {
+	if err := orchestrator.userStore.DropRolePermissions(tx, roleID); err != nil {
+		return err
+	}
+
+	if len(newPermissions) > 0 {
+		perms, err := orchestrator.getPermissionsByLabelQuery(tx, newPermissions)
+		if err != nil {
+			return err
+		}
+
+		if err := orchestrator.userStore.InsertRolePermissions(tx, roleID, perms); err != nil {
 			return err
 		}
 	}
@@ -651,6 +1539,21 @@ func (orchestrator *storeOrchestrator) updateUserPermissionsQuery(tx *sqlx.Tx, u
 	return nil
 }
 
+// getPermissionsByLabelQuery returns the permissions matching the labels provided. If
+// any of the labels do not match a known permission, ErrPermissionsInvalid is returned.
+func (orchestrator *storeOrchestrator) getPermissionsByLabelQuery(tx *sqlx.Tx, labels []string) ([]user.Permission, error) {
+	perms, err := orchestrator.userStore.GetPermissionsByLabel(tx, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(perms) != len(labels) {
+		return nil, ErrPermissionsInvalid
+	}
+
+	return perms, nil
+}
+
 func (orchestrator *storeOrchestrator) anyOutstandingPermissions(permissions ...string) (bool, error) {
 	query, args, err := sqlx.In(`SELECT label FROM permissions WHERE label NOT IN(?)`, permissions)
 	if err != nil {
@@ -658,7 +1561,7 @@ func (orchestrator *storeOrchestrator) anyOutstandingPermissions(permissions ...
 	}
 
 	var labels []string
-	db := orchestrator.db.GetSqlxDB()
+	db := orchestrator.db.Queryable()
 	if err := db.Select(&labels, db.Rebind(query), args...); err != nil {
 		return false, err
 	}
@@ -682,10 +1585,295 @@ func (orchestrator *storeOrchestrator) createPermissions(permissions ...string)
 		perms[k] = p{uuid.New(), v}
 	}
 
-	_, err := orchestrator.db.GetSqlxDB().NamedExec(
+	_, err := orchestrator.db.Queryable().NamedExec(
 		`INSERT INTO permissions(id, label) VALUES (:id, :label) ON CONFLICT(label) DO NOTHING`,
 		perms,
 	)
 
 	return err
 }
+
+// Audit
+
+func (orchestrator *storeOrchestrator) RecordAuditEntry(entry *audit.Entry) error {
+	return orchestrator.auditStore.Record(orchestrator.db.Queryable(), entry)
+}
+
+func (orchestrator *storeOrchestrator) ListAuditEntries(filter audit.Filter) ([]*audit.Entry, error) {
+	return orchestrator.auditStore.List(orchestrator.db.Queryable(), filter)
+}
+
+// Watch progress
+
+// SaveWatchProgress records the playback position of the user provided in the movie/episode given.
+func (orchestrator *storeOrchestrator) SaveWatchProgress(userID uuid.UUID, mediaID uuid.UUID, positionSeconds int, completed bool) error {
+	return orchestrator.mediaStore.SaveWatchProgress(orchestrator.db.Queryable(), userID, mediaID, positionSeconds, completed)
+}
+
+// ImportWatchedMedia records that the user provided completed the movie/episode given at the time provided.
+func (orchestrator *storeOrchestrator) ImportWatchedMedia(userID uuid.UUID, mediaID uuid.UUID, watchedAt time.Time) error {
+	return orchestrator.mediaStore.ImportWatched(orchestrator.db.Queryable(), userID, mediaID, watchedAt)
+}
+
+// Trakt accounts
+
+func (orchestrator *storeOrchestrator) GetTraktAccount(userID uuid.UUID) (*trakt.Account, error) {
+	return orchestrator.traktStore.Get(orchestrator.db.Queryable(), userID)
+}
+
+func (orchestrator *storeOrchestrator) SaveTraktAccount(account *trakt.Account) error {
+	return orchestrator.traktStore.Save(orchestrator.db.Queryable(), account)
+}
+
+func (orchestrator *storeOrchestrator) SaveTraktSyncResult(userID uuid.UUID, syncedAt time.Time, lastError *string) error {
+	return orchestrator.traktStore.SaveSyncResult(orchestrator.db.Queryable(), userID, syncedAt, lastError)
+}
+
+func (orchestrator *storeOrchestrator) DeleteTraktAccount(userID uuid.UUID) error {
+	return orchestrator.traktStore.Delete(orchestrator.db.Queryable(), userID)
+}
+
+// Remote sources
+
+func (orchestrator *storeOrchestrator) GetRemoteSource(id uuid.UUID) (*remote.Source, error) {
+	return orchestrator.remoteStore.Get(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) GetAllRemoteSources() ([]*remote.Source, error) {
+	return orchestrator.remoteStore.GetAll(orchestrator.db.Queryable())
+}
+
+func (orchestrator *storeOrchestrator) SaveRemoteSource(source *remote.Source) error {
+	return orchestrator.remoteStore.Save(orchestrator.db.Queryable(), source)
+}
+
+func (orchestrator *storeOrchestrator) DeleteRemoteSource(id uuid.UUID) error {
+	return orchestrator.remoteStore.Delete(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) GetRemoteSourceFiles(sourceID uuid.UUID) ([]*remote.File, error) {
+	return orchestrator.remoteStore.GetFiles(orchestrator.db.Queryable(), sourceID)
+}
+
+func (orchestrator *storeOrchestrator) SaveRemoteSourceFile(file *remote.File) error {
+	return orchestrator.remoteStore.SaveFile(orchestrator.db.Queryable(), file)
+}
+
+func (orchestrator *storeOrchestrator) SaveRemoteSourceSyncResult(id uuid.UUID, syncedAt time.Time, lastError *string) error {
+	return orchestrator.remoteStore.SaveSyncResult(orchestrator.db.Queryable(), id, syncedAt, lastError)
+}
+
+// Libraries
+
+func (orchestrator *storeOrchestrator) GetLibrary(id uuid.UUID) (*library.Library, error) {
+	return orchestrator.libraryStore.Get(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) GetAllLibraries() ([]*library.Library, error) {
+	return orchestrator.libraryStore.GetAll(orchestrator.db.Queryable())
+}
+
+// SaveLibrary validates and saves the library provided. Existing media is not reassigned
+// if the ingest path of the library changes, only media ingested after the change is affected.
+func (orchestrator *storeOrchestrator) SaveLibrary(lib *library.Library) error {
+	if err := lib.Validate(); err != nil {
+		return err
+	}
+
+	return orchestrator.libraryStore.Save(orchestrator.db.Queryable(), lib)
+}
+
+// DeleteLibrary deletes the library with the ID provided. The media within the library
+// is not deleted, but no longer belongs to a library (and so is visible to all users).
+func (orchestrator *storeOrchestrator) DeleteLibrary(id uuid.UUID) error {
+	defer orchestrator.cache.DeletePrefix(mediaCachePrefix)
+	return orchestrator.libraryStore.Delete(orchestrator.db.Queryable(), id)
+}
+
+func (orchestrator *storeOrchestrator) GetUserLibraries(userID uuid.UUID) ([]*library.Library, error) {
+	return orchestrator.libraryStore.GetUserLibraries(orchestrator.db.Queryable(), userID)
+}
+
+func (orchestrator *storeOrchestrator) GetUserLibraryIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	return orchestrator.libraryStore.GetUserLibraryIDs(orchestrator.db.Queryable(), userID)
+}
+
+// UpdateUserLibraries replaces the libraries the user with the ID provided has been granted access to.
+func (orchestrator *storeOrchestrator) UpdateUserLibraries(userID uuid.UUID, libraryIDs []uuid.UUID) error {
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.userStore.RecordUpdate(tx, userID); err != nil {
+			return err
+		}
+
+		return orchestrator.libraryStore.SetUserLibraries(tx, userID, libraryIDs)
+	})
+}
+
+// GetLibraryAccess returns the libraries the user with the ID and permissions provided may
+// access. Users with the permission to access all libraries are not restricted.
+func (orchestrator *storeOrchestrator) GetLibraryAccess(userID uuid.UUID, userPermissions []string) (*library.Access, error) {
+	if slices.Contains(userPermissions, permissions.AccessAllLibrariesPermission) {
+		return library.FullAccess(), nil
+	}
+
+	libraryIDs, err := orchestrator.GetUserLibraryIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &library.Access{LibraryIDs: libraryIDs}, nil
+}
+
+// AuthorizeLibraryAccess returns library.ErrAccessDenied if the movie, episode, series or season
+// with the ID provided belongs to a library which is not permitted by the access provided.
+func (orchestrator *storeOrchestrator) AuthorizeLibraryAccess(access *library.Access, id uuid.UUID) error {
+	if access == nil || access.All {
+		return nil
+	}
+
+	libraryID, err := orchestrator.libraryStore.GetItemLibraryID(orchestrator.db.Queryable(), id)
+	if err != nil {
+		return err
+	}
+	if !access.Allows(libraryID) {
+		return library.ErrAccessDenied
+	}
+
+	return nil
+}
+
+// Quotas
+
+// GetUserQuota returns the quota of the user with the ID provided. Users
+// without a quota are given an unlimited quota.
+func (orchestrator *storeOrchestrator) GetUserQuota(userID uuid.UUID) (*quota.Quota, error) {
+	return orchestrator.quotaStore.Get(orchestrator.db.Queryable(), userID)
+}
+
+// SaveUserQuota validates and saves the quota provided, replacing any existing quota of the user.
+func (orchestrator *storeOrchestrator) SaveUserQuota(userQuota *quota.Quota) error {
+	if err := userQuota.Validate(); err != nil {
+		return err
+	}
+
+	return orchestrator.quotaStore.Save(orchestrator.db.Queryable(), userQuota)
+}
+
+func (orchestrator *storeOrchestrator) CountUserTranscodeRequests(userID uuid.UUID) (int, error) {
+	return orchestrator.quotaStore.CountTranscodeRequests(orchestrator.db.Queryable(), userID)
+}
+
+func (orchestrator *storeOrchestrator) RecordUserTranscodeRequest(userID uuid.UUID, taskID uuid.UUID) error {
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		return orchestrator.quotaStore.RecordTranscodeRequest(tx, userID, taskID)
+	})
+}
+
+// Playback preferences
+
+// GetUserPreferences returns the playback preferences of the user with the ID provided. Users
+// which have not saved any preferences are given the default preferences.
+func (orchestrator *storeOrchestrator) GetUserPreferences(userID uuid.UUID) (*preferences.Preferences, error) {
+	return orchestrator.prefsStore.Get(orchestrator.db.Queryable(), userID)
+}
+
+// SaveUserPreferences validates and saves the preferences provided, replacing any existing preferences of the user.
+func (orchestrator *storeOrchestrator) SaveUserPreferences(prefs *preferences.Preferences) error {
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+
+	return orchestrator.prefsStore.Save(orchestrator.db.Queryable(), prefs)
+}
+
+// Home feed
+
+// GetHomeFeed builds the home feed of the user provided, containing only the media within the libraries
+// they may access. The sections of the feed are selected in a single read transaction, so that they're
+// consistent with one another.
+func (orchestrator *storeOrchestrator) GetHomeFeed(userID uuid.UUID, access *library.Access, includeTranscodes bool, limit int) (*media.HomeFeed, error) {
+	var libraryFilter *media.LibraryFilter
+	if access != nil && !access.All {
+		libraryFilter = &media.LibraryFilter{IDs: access.LibraryIDs}
+	}
+
+	var feed *media.HomeFeed
+	if err := orchestrator.db.WrapReadTx(func(tx *sqlx.Tx) error {
+		f, err := orchestrator.mediaStore.GetHomeFeed(tx, userID, libraryFilter, includeTranscodes, limit)
+		feed = f
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// Related content
+
+// GetRelatedMovies returns the movies in the library (which the user may access) that TMDB
+// considers related to the movie with the ID provided.
+func (orchestrator *storeOrchestrator) GetRelatedMovies(movieID uuid.UUID, access *library.Access) ([]*media.RelatedItem, error) {
+	var libraryFilter *media.LibraryFilter
+	if access != nil && !access.All {
+		libraryFilter = &media.LibraryFilter{IDs: access.LibraryIDs}
+	}
+
+	return orchestrator.mediaStore.GetRelatedMovies(orchestrator.db.Queryable(), movieID, libraryFilter)
+}
+
+// GetRelatedSeries returns the series in the library (which the user may access) that TMDB
+// considers related to the series with the ID provided.
+func (orchestrator *storeOrchestrator) GetRelatedSeries(seriesID uuid.UUID, access *library.Access) ([]*media.RelatedItem, error) {
+	var libraryFilter *media.LibraryFilter
+	if access != nil && !access.All {
+		libraryFilter = &media.LibraryFilter{IDs: access.LibraryIDs}
+	}
+
+	return orchestrator.mediaStore.GetRelatedSeries(orchestrator.db.Queryable(), seriesID, libraryFilter)
+}
+
+// Unverified media
+
+// ListUnverifiedMovies returns the movies which were ingested while TMDB was unavailable.
+func (orchestrator *storeOrchestrator) ListUnverifiedMovies() ([]*media.Movie, error) {
+	return orchestrator.mediaStore.ListUnverifiedMovies(orchestrator.db.Queryable())
+}
+
+// ListUnverifiedEpisodes returns the episodes which were ingested while TMDB was unavailable.
+func (orchestrator *storeOrchestrator) ListUnverifiedEpisodes() ([]*media.Episode, error) {
+	return orchestrator.mediaStore.ListUnverifiedEpisodes(orchestrator.db.Queryable())
+}
+
+// ReconcileMovie transactionally upgrades the unverified movie with the ID provided using
+// the movie given, which has been found in TMDB (see SaveMovie).
+func (orchestrator *storeOrchestrator) ReconcileMovie(movieID uuid.UUID, movie *media.Movie) error {
+	defer orchestrator.evictMedia(movieID)
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.mediaStore.VerifyMedia(tx, movieID, movie.TmdbID); err != nil {
+			return err
+		}
+
+		movie.ID = movieID
+		return orchestrator.saveMovie(tx, movie)
+	})
+}
+
+// ReconcileEpisode transactionally upgrades the unverified episode with the ID provided using the
+// episode, season and series given, which have been found in TMDB (see SaveEpisode). The
+// unverified season and series the episode belonged to are deleted once empty.
+func (orchestrator *storeOrchestrator) ReconcileEpisode(episodeID uuid.UUID, episode *media.Episode, season *media.Season, series *media.Series) error {
+	defer orchestrator.evictMedia(episodeID)
+	return orchestrator.db.WrapTx(func(tx *sqlx.Tx) error {
+		if err := orchestrator.mediaStore.VerifyMedia(tx, episodeID, episode.TmdbID); err != nil {
+			return err
+		}
+
+		episode.ID = episodeID
+		if err := orchestrator.saveEpisode(tx, episode, season, series); err != nil {
+			return err
+		}
+
+		return orchestrator.mediaStore.DeleteEmptyUnverifiedContainers(tx)
+	})
+}
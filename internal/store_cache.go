@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+)
+
+// Keys of the values cached by the store orchestrator. Movies and episodes share the
+// mediaCachePrefix so that they can be invalidated together when the trash is restored.
+const (
+	targetsCacheKey   = "targets"
+	workflowsCacheKey = "workflows"
+	genresCacheKey    = "genres"
+	mediaCachePrefix  = "media:"
+)
+
+func targetCacheKey(id uuid.UUID) string   { return "target:" + id.String() }
+func workflowCacheKey(id uuid.UUID) string { return "workflow:" + id.String() }
+func movieCacheKey(id uuid.UUID) string    { return mediaCachePrefix + "movie:" + id.String() }
+func episodeCacheKey(id uuid.UUID) string  { return mediaCachePrefix + "episode:" + id.String() }
+
+// invalidateCacheOnEvents registers handlers with the event handler provided which evict the
+// cached values affected by each event. The orchestrator dispatches these events when the cached
+// data is modified, and the event transport relays them from other Thea instances, keeping the
+// cache coherent even when it is not shared between instances.
+//
+// The handlers are synchronous, so values are evicted before Dispatch returns.
+func (orchestrator *storeOrchestrator) invalidateCacheOnEvents(handler event.EventHandler) {
+	handler.RegisterHandlerFunction(event.TargetUpdateEvent, func(_ event.Event, payload event.Payload) {
+		// Workflows contain the IDs of their targets, which are removed when a target is deleted
+		orchestrator.cache.Delete(targetsCacheKey, targetCacheKey(payload.(uuid.UUID)), workflowsCacheKey)
+		orchestrator.cache.DeletePrefix("workflow:")
+	})
+
+	handler.RegisterHandlerFunction(event.WorkflowUpdateEvent, func(_ event.Event, payload event.Payload) {
+		orchestrator.cache.Delete(workflowsCacheKey, workflowCacheKey(payload.(uuid.UUID)))
+	})
+
+	for _, ev := range []event.Event{
+		event.NewMediaEvent, event.UpdateMediaEvent, event.DeleteMediaEvent,
+		event.DegradedMediaEvent, event.CorruptedMediaEvent,
+	} {
+		handler.RegisterHandlerFunction(ev, func(_ event.Event, payload event.Payload) {
+			orchestrator.evictMedia(payload.(uuid.UUID))
+		})
+	}
+}
+
+// evictMedia evicts the cached movie/episode models for the IDs provided. The
+// genre list is also evicted, as modifying media may introduce new genres.
+func (orchestrator *storeOrchestrator) evictMedia(mediaIDs ...uuid.UUID) {
+	keys := make([]string, 0, len(mediaIDs)*2+1)
+	for _, id := range mediaIDs {
+		keys = append(keys, movieCacheKey(id), episodeCacheKey(id))
+	}
+
+	orchestrator.cache.Delete(append(keys, genresCacheKey)...)
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/sysinfo"
+)
+
+// SystemInfo describes the environment Thea is running in, including the build of Thea, the
+// ffmpeg binary and database in use, the directories Thea uses and which optional features
+// are enabled. The ffmpeg binary and database are probed on each call.
+func (thea *theaImpl) SystemInfo(ctx context.Context) *sysinfo.Info {
+	thea.reloadMutex.Lock()
+	config := thea.config
+	thea.reloadMutex.Unlock()
+
+	return &sysinfo.Info{
+		Build:     sysinfo.GetBuild(),
+		StartedAt: thea.startedAt,
+		Ffmpeg:    probeFfmpeg(config.Format.FfmpegBinaryPath),
+		Database:  thea.probeDatabase(ctx),
+		Paths:     config.GetMonitoredPaths(),
+		Features: map[string]bool{
+			"embedded_postgres":     config.Services.EnablePostgres,
+			"graphql":               config.RestConfig.EnableGraphQL,
+			"debug_endpoints":       config.RestConfig.EnableDebugEndpoints,
+			"rpc":                   config.RPC.Enabled,
+			"segmented_transcoding": config.Format.Segmentation.Enabled,
+			"distributed_events":    thea.eventRelay != nil,
+			"maintenance_mode":      thea.maintenance.State().Enabled,
+		},
+	}
+}
+
+// DatabaseMetrics returns the statistics of the database connection pool, along
+// with the metrics of the queries and transactions made since Thea started.
+func (thea *theaImpl) DatabaseMetrics() *database.Metrics {
+	return thea.storeOrchestrator.db.Metrics()
+}
+
+func probeFfmpeg(binPath string) sysinfo.Ffmpeg {
+	out := sysinfo.Ffmpeg{Path: binPath}
+	version, err := ffmpeg.ProbeVersion(binPath)
+	if err != nil {
+		out.Error = err
+		return out
+	}
+
+	capabilities, err := ffmpeg.ProbeCapabilities(binPath)
+	if err != nil {
+		out.Error = err
+		return out
+	}
+
+	out.Version = version
+	out.Encoders = capabilities.Encoders
+	out.Muxers = capabilities.Muxers
+	return out
+}
+
+func (thea *theaImpl) probeDatabase(ctx context.Context) sysinfo.Database {
+	out := sysinfo.Database{}
+	if err := thea.storeOrchestrator.db.GetSqlxDB().GetContext(ctx, &out.ServerVersion, `SHOW server_version`); err != nil {
+		out.Error = fmt.Errorf("unable to query postgres server version: %w", err)
+		return out
+	}
+
+	version, err := thea.storeOrchestrator.db.SchemaVersion()
+	if err != nil {
+		out.Error = fmt.Errorf("unable to determine schema version: %w", err)
+		return out
+	}
+
+	out.SchemaVersion = version
+	return out
+}
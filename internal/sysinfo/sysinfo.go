@@ -0,0 +1,82 @@
+// Package sysinfo describes the environment Thea is running in (e.g. the version of Thea,
+// ffmpeg and the database), so that it can be captured in a single call when diagnosing
+// problems.
+package sysinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Version is the version of Thea. Release builds may override this
+// using '-ldflags "-X github.com/hbomb79/Thea/internal/sysinfo.Version=..."'.
+var Version = "1.0"
+
+type (
+	// Build describes the build of the Thea binary. The commit and build time are
+	// only known if the binary was built from a VCS checkout (see 'go help buildvcs').
+	Build struct {
+		Version   string
+		Commit    string
+		Modified  bool
+		BuiltAt   *time.Time
+		GoVersion string
+		OS        string
+		Arch      string
+	}
+
+	// Ffmpeg describes the ffmpeg binary used for transcoding. If the binary could
+	// not be probed, only the path and the error are populated.
+	Ffmpeg struct {
+		Path     string
+		Version  string
+		Encoders []string
+		Muxers   []string
+		Error    error
+	}
+
+	// Database describes the database server, and the schema version of Thea's database.
+	Database struct {
+		ServerVersion string
+		SchemaVersion int64
+		Error         error
+	}
+
+	Info struct {
+		Build     Build
+		StartedAt time.Time
+		Ffmpeg    Ffmpeg
+		Database  Database
+
+		// Paths are the directories Thea reads from/writes to, keyed by their purpose.
+		Paths map[string]string
+
+		// Features are the optional features of Thea, and whether they are enabled.
+		Features map[string]bool
+	}
+)
+
+// GetBuild returns the build information embedded in the running binary.
+func GetBuild() Build {
+	build := Build{Version: Version, Commit: "unknown", GoVersion: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Commit = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		case "vcs.time":
+			if builtAt, err := time.Parse(time.RFC3339, setting.Value); err == nil {
+				build.BuiltAt = &builtAt
+			}
+		}
+	}
+
+	return build
+}
@@ -6,15 +6,32 @@ import (
 	"fmt"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/api"
+	"github.com/hbomb79/Thea/internal/artwork"
+	"github.com/hbomb79/Thea/internal/backup"
+	"github.com/hbomb79/Thea/internal/benchmark"
+	"github.com/hbomb79/Thea/internal/cache"
+	"github.com/hbomb79/Thea/internal/consistency"
 	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/deletion"
 	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/export"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/health"
 	"github.com/hbomb79/Thea/internal/http/tmdb"
 	"github.com/hbomb79/Thea/internal/ingest"
+	"github.com/hbomb79/Thea/internal/maintenance"
 	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/notification"
+	"github.com/hbomb79/Thea/internal/preflight"
+	"github.com/hbomb79/Thea/internal/quota"
+	"github.com/hbomb79/Thea/internal/remote"
+	"github.com/hbomb79/Thea/internal/rpc"
+	"github.com/hbomb79/Thea/internal/trakt"
 	"github.com/hbomb79/Thea/internal/transcode"
 	"github.com/hbomb79/Thea/internal/user/permissions"
 	"github.com/hbomb79/Thea/pkg/docker"
@@ -34,20 +51,44 @@ type (
 		BroadcastTaskProgressUpdate(taskID uuid.UUID) error
 		BroadcastWorkflowUpdate(workflowID uuid.UUID) error
 		BroadcastMediaUpdate(mediaID uuid.UUID) error
+		BroadcastWatchTargetReady(mediaID uuid.UUID) error
 		BroadcastIngestUpdate(ingestID uuid.UUID) error
+		BroadcastConsistencyReport(reportID uuid.UUID) error
+		BroadcastShutdown(drainTimeout time.Duration)
 	}
 
 	TranscodeService interface {
 		RunnableService
-		NewTask(mediaID uuid.UUID, targetID uuid.UUID) error
+		NewTask(ctx context.Context, userID uuid.UUID, mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID, important bool) error
+		Quota(userID uuid.UUID) (*quota.Usage, error)
 		CancelTask(taskID uuid.UUID) error
 		AllTasks() []*transcode.TranscodeTask
 		Task(taskID uuid.UUID) *transcode.TranscodeTask
 		PauseTask(taskID uuid.UUID) error
 		ResumeTask(taskID uuid.UUID) error
-		ActiveTaskForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID) *transcode.TranscodeTask
+		ActiveTaskForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) *transcode.TranscodeTask
 		ActiveTasksForMedia(mediaID uuid.UUID) []*transcode.TranscodeTask
 		CancelTasksForMedia(mediaID uuid.UUID)
+		PauseQueue(suspendRunning bool)
+		ResumeQueue()
+		IsQueuePaused() bool
+		ConsumedThreads() int
+		CreatePreview(ctx context.Context, mediaID uuid.UUID, targetID uuid.UUID) (*transcode.Preview, error)
+		Preview(previewID uuid.UUID) *transcode.Preview
+		ApplyConfig(config transcode.Config)
+		ApplyWorkflow(ctx context.Context, label string, workflowID uuid.UUID, targetID *uuid.UUID, filter transcode.BatchFilter) (*transcode.Batch, error)
+		Batches() []*transcode.Batch
+		Batch(batchID uuid.UUID) *transcode.Batch
+		BatchProgress(batch *transcode.Batch) transcode.BatchProgress
+		StartPlayback(userID uuid.UUID, mediaID uuid.UUID, versionID *uuid.UUID) (*transcode.PlaybackSession, error)
+		RefreshPlayback(userID uuid.UUID, sessionID uuid.UUID) (*transcode.PlaybackSession, error)
+		EndPlayback(userID uuid.UUID, sessionID uuid.UUID) error
+		PlaybackSessions() []*transcode.PlaybackSession
+	}
+
+	TmdbSearcher interface {
+		ApplyConfig(config tmdb.Config)
+		BreakerStatus() tmdb.BreakerStatus
 	}
 
 	IngestService interface {
@@ -56,7 +97,25 @@ type (
 		GetIngest(ingestID uuid.UUID) *ingest.IngestItem
 		GetAllIngests() []*ingest.IngestItem
 		DiscoverNewFiles()
-		ResolveTroubledIngest(itemID uuid.UUID, method ingest.ResolutionType, context map[string]string) error
+		ResolveTroubledIngest(ctx context.Context, itemID uuid.UUID, method ingest.ResolutionType, context map[string]string) error
+		GetAllIngestGroups() []*ingest.IngestGroup
+		RemoveIngestGroup(groupID uuid.UUID) error
+		ResolveTroubledGroup(ctx context.Context, groupID uuid.UUID, method ingest.ResolutionType, context map[string]string) error
+		PauseIngest(itemID uuid.UUID) error
+		ResumeIngest(itemID uuid.UUID) error
+		PrioritizeIngest(itemID uuid.UUID) error
+		GetQuarantinedIngests() []*ingest.IngestItem
+		ReleaseIngest(itemID uuid.UUID) error
+		DeleteQuarantinedIngest(itemID uuid.UUID) error
+		DryRunIngest(ctx context.Context, path string) (*ingest.DryRun, error)
+		PauseQueue()
+		ResumeQueue()
+		IsQueuePaused() bool
+		StageMetrics() []ingest.StageMetrics
+		SetParallelism(parallelism int) error
+		Parallelism() int
+		WatcherStatus() ingest.WatcherStatus
+		NotifyDownloadComplete(ctx context.Context, path string, infoHash *string) ([]*ingest.IngestItem, error)
 	}
 )
 
@@ -71,14 +130,38 @@ const (
 // handling, et cetera...
 type theaImpl struct {
 	eventBus          event.EventCoordinator
+	eventRelay        event.DistributedEventCoordinator
 	dockerManager     docker.DockerManager
 	storeOrchestrator *storeOrchestrator
 	activityService   *activityService
+	reclaimJanitor    *reclaimJanitor
 	config            TheaConfig
 
 	restGateway      RestGateway
 	ingestService    IngestService
 	transcodeService TranscodeService
+	backupService    *backup.Service
+	trashJanitor     *trashJanitor
+	historyJanitor   *historyJanitor
+	catalogRefresher *catalogRefresher
+	reconciler       *unverifiedReconciler
+	consistency      *consistency.Service
+	exportService    *export.Service
+	trakt            *trakt.Service
+	deletionService  *deletion.Service
+	benchmarks       *benchmark.Service
+	notifications    *notification.Service
+	remoteSources    *remote.Service
+	artwork          *artwork.Service
+	searcher         TmdbSearcher
+	maintenance      *maintenance.Mode
+	rpcServer        *rpc.Server
+
+	// running is set once all services have been spawned, after
+	// which the configuration may be reloaded (see ReloadConfig).
+	running     atomic.Bool
+	reloadMutex sync.Mutex
+	startedAt   time.Time
 }
 
 func New(config TheaConfig) *theaImpl {
@@ -101,6 +184,7 @@ func New(config TheaConfig) *theaImpl {
 // To stop Thea, the provided context must be cancelled. Errors from which Thea cannot recover
 // will also cause Thea to stop.
 func (thea *theaImpl) Run(parent context.Context) error {
+	thea.startedAt = time.Now()
 	thea.dockerManager = docker.NewDockerManager()
 	defer thea.dockerManager.Shutdown(dockerShutdownTimeout)
 
@@ -110,21 +194,56 @@ func (thea *theaImpl) Run(parent context.Context) error {
 		cancel()
 	}
 
+	log.Emit(logger.NEW, "Performing pre-flight checks...\n")
+	logPreflightResults(preflight.Run(systemPreflightChecks(thea.config)...))
+
 	log.Emit(logger.NEW, "Initialising Docker services...\n")
 	if err := thea.initialiseDockerServices(thea.config, crashHandler); err != nil {
 		return fmt.Errorf("failed to initialise docker services: %w", err)
 	}
 
+	// When a transport is configured, the event bus is replaced with one which
+	// relays events to/from the other Thea instances using the transport
+	transport, err := event.NewTransport(ctx, thea.config.Events)
+	if err != nil {
+		return fmt.Errorf("failed to initialise event transport: %w", err)
+	} else if transport != nil {
+		log.Emit(logger.NEW, "Using '%s' event transport\n", thea.config.Events.Backend)
+		thea.eventRelay = event.NewDistributed(transport)
+		thea.eventBus = thea.eventRelay
+	}
+
 	log.Emit(logger.NEW, "Connecting to database...\n")
 	db := database.New()
 	if err := db.Connect(thea.config.Database); err != nil {
 		return fmt.Errorf("failed to initialise connection to DB: %w", err)
 	}
 
-	store, err := newStoreOrchestrator(db, thea.eventBus)
+	backupConfig := thea.config.Backup
+	backupConfig.Dir = thea.config.GetBackupDir()
+	if backupConfig.RestoreFrom != "" {
+		log.Emit(logger.NEW, "Restoring database from backup '%s'...\n", backupConfig.RestoreFrom)
+		if _, err := backup.Restore(backupConfig, thea.config.Database, db.GetSqlxDB(), backupConfig.RestoreFrom); err != nil {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+
+		// The restored backup may be from an older version of Thea
+		if err := db.Migrate(); err != nil {
+			return fmt.Errorf("failed to migrate restored backup: %w", err)
+		}
+	}
+	thea.backupService = backup.New(backupConfig, thea.config.Database, db)
+
+	dataCache, err := cache.New(ctx, thea.config.Cache)
+	if err != nil {
+		return fmt.Errorf("failed to initialise cache: %w", err)
+	}
+
+	store, err := newStoreOrchestrator(db, thea.eventBus, dataCache, thea.config.Passwords)
 	if err != nil {
 		return fmt.Errorf("failed to construct data orchestrator: %w", err)
 	}
+	store.invalidateCacheOnEvents(thea.eventBus)
 	thea.storeOrchestrator = store
 	if err := thea.syncDBPermissions(); err != nil {
 		return fmt.Errorf("failed to sync db permissions: %w", err)
@@ -132,9 +251,17 @@ func (thea *theaImpl) Run(parent context.Context) error {
 	if err := thea.createInitialUserIfNonePresent(); err != nil {
 		return fmt.Errorf("failed to create initial user: %w", err)
 	}
-
-	searcher := tmdb.NewSearcher(tmdb.Config{APIKey: thea.config.TmdbKey})
-	scraper := media.NewScraper(media.ScraperConfig{FfprobeBinPath: thea.config.Format.FfprobeBinaryPath})
+	logPreflightResults(preflight.Run(databasePreflightChecks(thea.config, db.GetSqlxDB(), store.GetAllTargets())...))
+
+	tmdbConfig := thea.config.Tmdb
+	tmdbConfig.APIKey = thea.config.TmdbKey
+	searcher := tmdb.NewSearcher(tmdbConfig)
+	thea.searcher = searcher
+	scraper := media.NewScraper(media.ScraperConfig{
+		FfprobeBinPath:   thea.config.Format.FfprobeBinaryPath,
+		ProbeConcurrency: thea.config.IngestService.ProbeConcurrency,
+		ProbeTimeout:     thea.config.IngestService.ProbeTimeout,
+	})
 	if serv, err := ingest.New(thea.config.IngestService, searcher, scraper, thea.storeOrchestrator, thea.eventBus); err == nil {
 		thea.ingestService = serv
 	} else {
@@ -147,18 +274,87 @@ func (thea *theaImpl) Run(parent context.Context) error {
 		return fmt.Errorf("failed to construct transcode service due to error: %w", err)
 	}
 
-	thea.restGateway = api.NewRestGateway(&thea.config.RestConfig, thea.ingestService, thea.transcodeService, thea.storeOrchestrator)
+	thea.consistency = consistency.New(thea.config.Consistency, thea.config.GetTranscodeOutputPaths(), thea.storeOrchestrator, thea.transcodeService, thea.eventBus)
+	thea.exportService = export.New(thea.config.Export, searcher, thea.storeOrchestrator, thea.eventBus)
+	thea.deletionService = deletion.New(thea.storeOrchestrator)
+	thea.benchmarks = benchmark.New(thea.config.Benchmark, thea.config.Format.FfmpegBinaryPath, thea.config.Format.MaximumThreadConsumption, thea.storeOrchestrator, thea.transcodeService, thea.eventBus)
+	if serv, err := notification.New(thea.config.Notifications, thea.storeOrchestrator, thea.ingestService, thea.transcodeService, thea.eventBus); err == nil {
+		thea.notifications = serv
+	} else {
+		return fmt.Errorf("failed to construct notification service due to error: %w", err)
+	}
+
+	thea.trakt = trakt.New(thea.config.Trakt, thea.config.Format.FfprobeBinaryPath, thea.storeOrchestrator)
+	remoteConfig := thea.config.Remote
+	remoteConfig.StagingPath = thea.config.GetRemoteStagingDir()
+	thea.remoteSources = remote.New(remoteConfig, thea.config.IngestService.GetIngestPath(), thea.storeOrchestrator)
+
+	artworkConfig := thea.config.Artwork
+	artworkConfig.CachePath = thea.config.GetArtworkCacheDir()
+	thea.artwork = artwork.New(artworkConfig, searcher)
+
+	thea.maintenance = maintenance.New(thea.config.Maintenance, thea.ingestService, thea.transcodeService)
+	targetValidator := ffmpeg.NewTargetValidator(thea.config.Format.FfmpegBinaryPath)
+	gateway := api.NewRestGateway(&thea.config.RestConfig, thea.ingestService, thea.transcodeService, thea.backupService, thea.consistency, thea.exportService, thea.deletionService, thea.benchmarks, scraper, thea, thea, thea.maintenance, health.New(0, thea.readinessProbes(db)...), thea.remoteSources, thea.artwork, thea.trakt, thea.storeOrchestrator, thea.config.GetMonitoredPaths(), targetValidator)
+	thea.restGateway = gateway
+	if thea.config.RPC.Enabled {
+		thea.rpcServer = rpc.New(thea.config.RPC, gateway, thea.ingestService, thea.transcodeService, thea.storeOrchestrator, thea.eventBus)
+	}
 	thea.activityService = newActivityService(thea.restGateway, thea.eventBus)
+	thea.reclaimJanitor = newReclaimJanitor(thea.config.Format, thea.storeOrchestrator)
+	thea.trashJanitor = newTrashJanitor(thea.config.Trash, thea.storeOrchestrator)
+	thea.historyJanitor = newHistoryJanitor(thea.config.History, thea.storeOrchestrator)
+	thea.catalogRefresher = newCatalogRefresher(thea.config.Catalog, searcher, thea.storeOrchestrator)
+	thea.reconciler = newUnverifiedReconciler(thea.config.Reconcile, searcher, thea.storeOrchestrator)
+
+	// The transcode service is stopped before the other services, so that
+	// clients can continue to monitor transcodes while they are drained.
+	transcodeCtx, cancelTranscode := context.WithCancel(context.Background())
+	servicesCtx, cancelServices := context.WithCancel(context.Background())
+	transcodeWg := &sync.WaitGroup{}
+	transcodeWg.Add(1)
+	go thea.spawnService(transcodeCtx, transcodeWg, thea.transcodeService, "transcode-service", crashHandler)
 
 	wg := &sync.WaitGroup{}
-	wg.Add(4)
-	go thea.spawnService(ctx, wg, thea.ingestService, "ingest-service", crashHandler)
-	go thea.spawnService(ctx, wg, thea.transcodeService, "transcode-service", crashHandler)
-	go thea.spawnService(ctx, wg, thea.restGateway, "rest-gateway", crashHandler)
-	go thea.spawnService(ctx, wg, thea.activityService, "activity-service", crashHandler)
+	wg.Add(17)
+	go thea.spawnService(servicesCtx, wg, thea.ingestService, "ingest-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.backupService, "backup-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.restGateway, "rest-gateway", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.activityService, "activity-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.reclaimJanitor, "reclaim-janitor", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.trashJanitor, "trash-janitor", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.historyJanitor, "history-janitor", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.catalogRefresher, "catalog-refresher", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.reconciler, "unverified-reconciler", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.consistency, "consistency-checker", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.exportService, "export-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.trakt, "trakt-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.deletionService, "deletion-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.benchmarks, "benchmark-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.notifications, "notification-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.remoteSources, "remote-source-service", crashHandler)
+	go thea.spawnService(servicesCtx, wg, thea.artwork, "artwork-service", crashHandler)
+	if thea.eventRelay != nil {
+		wg.Add(1)
+		go thea.spawnService(servicesCtx, wg, thea.eventRelay, "event-relay", crashHandler)
+	}
+	if thea.rpcServer != nil {
+		wg.Add(1)
+		go thea.spawnService(servicesCtx, wg, thea.rpcServer, "rpc-server", crashHandler)
+	}
+	thea.running.Store(true)
 	log.Emit(logger.SUCCESS, "Thea services spawned! [CTRL+C to stop]\n")
 
+	<-ctx.Done()
+	thea.running.Store(false)
+	log.Emit(logger.STOP, "Shutting down Thea services...\n")
+	thea.restGateway.BroadcastShutdown(thea.config.Format.DrainTimeout)
+
+	cancelTranscode()
+	transcodeWg.Wait()
+	cancelServices()
 	wg.Wait()
+
 	return nil
 }
 
@@ -231,7 +427,8 @@ func (thea *theaImpl) createInitialUserIfNonePresent() error {
 		return nil
 	}
 
-	log.Emit(logger.NEW, "No existing users found, creating initial user [username='admin', password=REDACTED {refer to your configuration}]\n")
-	_, err = thea.storeOrchestrator.CreateUser([]byte("admin"), []byte("admin"), permissions.All()...)
+	bootstrap := thea.config.Bootstrap
+	log.Emit(logger.NEW, "No existing users found, creating initial user [username='%s', password=REDACTED {refer to your configuration}]\n", bootstrap.AdminUsername)
+	_, err = thea.storeOrchestrator.CreateUser([]byte(bootstrap.AdminUsername), []byte(bootstrap.AdminPassword), permissions.All()...)
 	return err
 }
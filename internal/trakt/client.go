@@ -0,0 +1,248 @@
+package trakt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// apiVersion is the version of the Trakt API used, sent with every request.
+	apiVersion = "2"
+
+	// historyPageSize is the number of history items requested per page during an import.
+	historyPageSize = 100
+
+	// requestTimeout bounds the time each request to Trakt may take.
+	requestTimeout = 30 * time.Second
+)
+
+var (
+	// errAuthorizationPending is returned while polling for the token of a device code
+	// which the user has not yet approved, and errSlowDown when polling too quickly.
+	errAuthorizationPending = errors.New("authorization of the device code is pending")
+	errSlowDown             = errors.New("device code is being polled too quickly")
+
+	ErrDeviceCodeExpired = errors.New("the device code expired before it was approved")
+	ErrDeviceCodeDenied  = errors.New("the user denied access to their trakt account")
+)
+
+type (
+	// client performs requests to the Trakt API on behalf of the configured application.
+	client struct {
+		config Config
+		http   *http.Client
+	}
+
+	// DeviceCode is issued when linking an account using the OAuth device flow. The user must visit
+	// the verification URL and enter the user code to approve access to their Trakt account.
+	DeviceCode struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURL string `json:"verification_url"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+
+	token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		CreatedAt    int64  `json:"created_at"`
+	}
+
+	// ids are the identifiers of a movie or episode. Only the TMDB ID is used, as
+	// this is how Thea identifies movies and episodes.
+	ids struct {
+		Tmdb int `json:"tmdb,omitempty"`
+	}
+
+	item struct {
+		IDs ids `json:"ids"`
+	}
+
+	scrobbleRequest struct {
+		Movie    *item   `json:"movie,omitempty"`
+		Episode  *item   `json:"episode,omitempty"`
+		Progress float64 `json:"progress"`
+	}
+
+	// historyItem is a movie or episode the user has watched, and when.
+	historyItem struct {
+		WatchedAt time.Time `json:"watched_at"`
+		Type      string    `json:"type"`
+		Movie     *item     `json:"movie"`
+		Episode   *item     `json:"episode"`
+	}
+)
+
+func newClient(config Config) *client {
+	return &client{config: config, http: &http.Client{Timeout: requestTimeout}}
+}
+
+// requestDeviceCode starts the OAuth device flow, returning the code which the user must approve.
+func (c *client) requestDeviceCode(ctx context.Context) (*DeviceCode, error) {
+	var code DeviceCode
+	if _, err := c.do(ctx, http.MethodPost, "/oauth/device/code", "", map[string]string{"client_id": c.config.ClientID}, &code); err != nil {
+		return nil, fmt.Errorf("failed to request device code: %w", err)
+	}
+
+	return &code, nil
+}
+
+// pollDeviceToken returns the token issued once the user approves the device code provided. While the
+// code is awaiting approval, errAuthorizationPending (or errSlowDown) is returned.
+func (c *client) pollDeviceToken(ctx context.Context, deviceCode string) (*token, error) {
+	body := map[string]string{"code": deviceCode, "client_id": c.config.ClientID, "client_secret": c.config.ClientSecret}
+
+	var result token
+	status, err := c.do(ctx, http.MethodPost, "/oauth/device/token", "", body, &result)
+	if err == nil {
+		return &result, nil
+	}
+
+	switch status {
+	case http.StatusBadRequest:
+		return nil, errAuthorizationPending
+	case http.StatusTooManyRequests:
+		return nil, errSlowDown
+	case http.StatusNotFound, http.StatusConflict, http.StatusGone:
+		return nil, ErrDeviceCodeExpired
+	case http.StatusTeapot:
+		return nil, ErrDeviceCodeDenied
+	}
+
+	return nil, fmt.Errorf("failed to poll device token: %w", err)
+}
+
+// refreshToken exchanges the refresh token provided for a new token.
+func (c *client) refreshToken(ctx context.Context, refreshToken string) (*token, error) {
+	body := map[string]string{
+		"refresh_token": refreshToken,
+		"client_id":     c.config.ClientID,
+		"client_secret": c.config.ClientSecret,
+		"redirect_uri":  "urn:ietf:wg:oauth:2.0:oob",
+		"grant_type":    "refresh_token",
+	}
+
+	var result token
+	if _, err := c.do(ctx, http.MethodPost, "/oauth/token", "", body, &result); err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+
+	return &result, nil
+}
+
+// revokeToken revokes the access token provided, so that it can no longer be used.
+func (c *client) revokeToken(ctx context.Context, accessToken string) error {
+	body := map[string]string{"token": accessToken, "client_id": c.config.ClientID, "client_secret": c.config.ClientSecret}
+	if _, err := c.do(ctx, http.MethodPost, "/oauth/revoke", "", body, nil); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// scrobble reports the playback progress (a percentage) of the movie or episode provided. If stopped, Trakt
+// records the item as watched once the progress is at least 80%, otherwise the progress is saved so that
+// playback can be resumed.
+func (c *client) scrobble(ctx context.Context, accessToken string, request scrobbleRequest, stopped bool) error {
+	path := "/scrobble/pause"
+	if stopped {
+		path = "/scrobble/stop"
+	}
+
+	if _, err := c.do(ctx, http.MethodPost, path, accessToken, request, nil); err != nil {
+		return fmt.Errorf("failed to scrobble: %w", err)
+	}
+
+	return nil
+}
+
+// history returns the movies or episodes (depending on the type provided) watched by the user.
+func (c *client) history(ctx context.Context, accessToken string, typ string) ([]historyItem, error) {
+	items := make([]historyItem, 0)
+	for page := 1; ; page++ {
+		query := url.Values{"page": {strconv.Itoa(page)}, "limit": {strconv.Itoa(historyPageSize)}}
+
+		var result []historyItem
+		resp, err := c.request(ctx, http.MethodGet, "/sync/history/"+typ+"?"+query.Encode(), accessToken, nil, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s history: %w", typ, err)
+		}
+
+		items = append(items, result...)
+		if pages, err := strconv.Atoi(resp.Header.Get("X-Pagination-Page-Count")); err != nil || page >= pages {
+			return items, nil
+		}
+	}
+}
+
+// do performs a request using the access token provided (if any), decoding the JSON response
+// in to the result provided (if any). The status of the response is returned, even if the
+// request failed because the status was not successful.
+func (c *client) do(ctx context.Context, method string, path string, accessToken string, body any, result any) (int, error) {
+	resp, err := c.request(ctx, method, path, accessToken, body, result)
+	if resp == nil {
+		return 0, err
+	}
+
+	return resp.StatusCode, err
+}
+
+func (c *client) request(ctx context.Context, method string, path string, accessToken string, body any, result any) (*http.Response, error) {
+	var content io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		content = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.config.BaseURL+path, content)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("trakt-api-version", apiVersion)
+	req.Header.Set("trakt-api-key", c.config.ClientID)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, fmt.Errorf("unexpected response status %d from %s", resp.StatusCode, path)
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return resp, fmt.Errorf("failed to decode response from %s: %w", path, err)
+		}
+	}
+
+	return resp, nil
+}
+
+// expiresAt returns the time the token expires. Tokens which do not report when they
+// were created are assumed to have been created now.
+func (t *token) expiresAt() time.Time {
+	createdAt := time.Now()
+	if t.CreatedAt > 0 {
+		createdAt = time.Unix(t.CreatedAt, 0)
+	}
+
+	return createdAt.Add(time.Duration(t.ExpiresIn) * time.Second)
+}
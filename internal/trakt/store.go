@@ -0,0 +1,72 @@
+package trakt
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+type (
+	// Account is the Trakt account linked to a user. The tokens are encrypted using
+	// the credential key of the integration (see Config).
+	Account struct {
+		UserID                uuid.UUID  `db:"user_id"`
+		EncryptedAccessToken  []byte     `db:"encrypted_access_token"`
+		EncryptedRefreshToken []byte     `db:"encrypted_refresh_token"`
+		ExpiresAt             time.Time  `db:"expires_at"`
+		LinkedAt              time.Time  `db:"linked_at"`
+		SyncedAt              *time.Time `db:"synced_at"`
+		LastError             *string    `db:"last_error"`
+	}
+
+	Store struct{}
+)
+
+// Save creates or updates the account provided. The outcome of the import of the
+// watched history of an existing account is not updated (see SaveSyncResult).
+func (store *Store) Save(db database.Queryable, account *Account) error {
+	_, err := db.NamedExec(`
+		INSERT INTO trakt_account(user_id, encrypted_access_token, encrypted_refresh_token, expires_at, linked_at, synced_at, last_error)
+		VALUES (:user_id, :encrypted_access_token, :encrypted_refresh_token, :expires_at, :linked_at, :synced_at, :last_error)
+		ON CONFLICT(user_id) DO UPDATE
+		SET (encrypted_access_token, encrypted_refresh_token, expires_at, linked_at) =
+			(EXCLUDED.encrypted_access_token, EXCLUDED.encrypted_refresh_token, EXCLUDED.expires_at, EXCLUDED.linked_at)
+	`, account)
+
+	return err
+}
+
+// SaveSyncResult records the outcome of the import of the watched history of the account linked to the user provided.
+func (store *Store) SaveSyncResult(db database.Queryable, userID uuid.UUID, syncedAt time.Time, lastError *string) error {
+	_, err := db.Exec(`UPDATE trakt_account SET synced_at=$2, last_error=$3 WHERE user_id=$1`, userID, syncedAt, lastError)
+	return err
+}
+
+func (store *Store) Get(db database.Queryable, userID uuid.UUID) (*Account, error) {
+	var result Account
+	if err := db.Get(&result, `SELECT * FROM trakt_account WHERE user_id=$1`, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotLinked
+		}
+
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (store *Store) Delete(db database.Queryable, userID uuid.UUID) error {
+	result, err := db.Exec(`DELETE FROM trakt_account WHERE user_id=$1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrNotLinked
+	}
+
+	return nil
+}
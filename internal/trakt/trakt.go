@@ -0,0 +1,552 @@
+// Package trakt integrates Thea with Trakt (https://trakt.tv). Users link their Trakt account using the
+// OAuth device flow, after which the movies and episodes they've watched on Trakt are imported in to
+// their watch progress, and their playback progress in Thea is scrobbled to Trakt.
+package trakt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/credential"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+const (
+	// scrobbleQueueSize is the number of scrobbles which can be queued at once. Scrobbles
+	// received while the queue is full are dropped.
+	scrobbleQueueSize = 64
+
+	// pollInterval controls how often the device codes of pending links are checked to
+	// see if they're due to be polled (according to the interval Trakt requested).
+	pollInterval = time.Second
+
+	// slowDownBackoff is added to the poll interval of a device code each time
+	// Trakt reports that the code is being polled too quickly.
+	slowDownBackoff = 5 * time.Second
+
+	// scrobbleInterval is the minimum time between scrobbling the progress of a user in the same
+	// media, so that Trakt is not sent every progress update. Completions are always scrobbled.
+	scrobbleInterval = 5 * time.Minute
+
+	// tokenRefreshWindow is how long before an access token expires that it is refreshed.
+	tokenRefreshWindow = time.Hour
+)
+
+const (
+	Unlinked LinkState = "UNLINKED"
+	Pending  LinkState = "PENDING"
+	Linked   LinkState = "LINKED"
+)
+
+var (
+	log = logger.Get("Trakt")
+
+	ErrNotConfigured = errors.New("the trakt integration is not configured")
+	ErrNotLinked     = errors.New("no trakt account is linked to this user")
+)
+
+type (
+	LinkState string
+
+	// Config configures the Trakt application used by the integration. The integration is
+	// disabled unless the client ID, client secret and credential key are all provided.
+	Config struct {
+		ClientID     string `toml:"client_id" env:"TRAKT_CLIENT_ID"`
+		ClientSecret string `toml:"client_secret" env:"TRAKT_CLIENT_SECRET"`
+
+		// CredentialKey is used to encrypt the tokens of linked accounts. If the key is
+		// changed, users must link their accounts again.
+		CredentialKey string `toml:"credential_key" env:"TRAKT_CREDENTIAL_KEY"`
+
+		BaseURL string `toml:"base_url" env:"TRAKT_BASE_URL" env-default:"https://api.trakt.tv"`
+	}
+
+	DataStore interface {
+		GetTraktAccount(userID uuid.UUID) (*Account, error)
+		SaveTraktAccount(account *Account) error
+		SaveTraktSyncResult(userID uuid.UUID, syncedAt time.Time, lastError *string) error
+		DeleteTraktAccount(userID uuid.UUID) error
+		GetMedia(mediaID uuid.UUID) *media.Container
+		GetMovieWithTmdbID(tmdbID string) (*media.Movie, error)
+		GetEpisodeWithTmdbID(tmdbID string) (*media.Episode, error)
+		ImportWatchedMedia(userID uuid.UUID, mediaID uuid.UUID, watchedAt time.Time) error
+	}
+
+	// Link describes the link between a user and their Trakt account. While the link is pending, the
+	// user must visit the verification URL and enter the user code before the code expires. Once
+	// linked, the outcome of the import of their watched history is included.
+	Link struct {
+		State           LinkState
+		UserCode        *string
+		VerificationURL *string
+		ExpiresAt       *time.Time
+		LinkedAt        *time.Time
+		SyncedAt        *time.Time
+		LastError       *string
+	}
+
+	pendingLink struct {
+		code       *DeviceCode
+		expiresAt  time.Time
+		interval   time.Duration
+		nextPollAt time.Time
+	}
+
+	scrobble struct {
+		userID          uuid.UUID
+		mediaID         uuid.UUID
+		positionSeconds int
+		completed       bool
+	}
+
+	scrobbleKey struct {
+		userID  uuid.UUID
+		mediaID uuid.UUID
+	}
+
+	// Service links users to their Trakt accounts, importing their watched history once linked, and
+	// scrobbles their playback progress to Trakt. Requests to Trakt are made one at a time.
+	Service struct {
+		config      Config
+		ffprobePath string
+		store       DataStore
+		client      *client
+		cipher      *credential.Cipher
+
+		scrobbles     chan scrobble
+		lastScrobbled map[scrobbleKey]time.Time
+
+		pendingMutex sync.Mutex
+		pending      map[uuid.UUID]*pendingLink
+	}
+)
+
+func New(config Config, ffprobePath string, store DataStore) *Service {
+	service := &Service{
+		config:        config,
+		ffprobePath:   ffprobePath,
+		store:         store,
+		client:        newClient(config),
+		scrobbles:     make(chan scrobble, scrobbleQueueSize),
+		lastScrobbled: make(map[scrobbleKey]time.Time),
+		pending:       make(map[uuid.UUID]*pendingLink),
+	}
+
+	// Without a credential key, the tokens of linked accounts cannot be stored
+	if cipher, err := credential.NewCipher(config.CredentialKey); err == nil {
+		service.cipher = cipher
+	}
+
+	return service
+}
+
+// Run is the main entry point for this service. The device codes of pending links are polled, and
+// scrobbles are sent to Trakt, until the context provided is cancelled.
+func (service *Service) Run(ctx context.Context) error {
+	if !service.enabled() {
+		log.Emit(logger.INFO, "Trakt integration is not configured\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			service.pollPendingLinks(ctx)
+		case s := <-service.scrobbles:
+			if err := service.scrobble(ctx, s); err != nil {
+				log.Warnf("Failed to scrobble media %s for user %s: %v\n", s.mediaID, s.userID, err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Link starts linking the user provided to their Trakt account, returning the code which the
+// user must approve. If a link is already pending for the user, the existing code is returned.
+func (service *Service) Link(ctx context.Context, userID uuid.UUID) (*Link, error) {
+	if !service.enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	service.pendingMutex.Lock()
+	if link, ok := service.pending[userID]; ok && time.Now().Before(link.expiresAt) {
+		service.pendingMutex.Unlock()
+		return link.status(), nil
+	}
+	service.pendingMutex.Unlock()
+
+	code, err := service.client.requestDeviceCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	interval := time.Duration(code.Interval) * time.Second
+	link := &pendingLink{
+		code:       code,
+		expiresAt:  now.Add(time.Duration(code.ExpiresIn) * time.Second),
+		interval:   interval,
+		nextPollAt: now.Add(interval),
+	}
+
+	service.pendingMutex.Lock()
+	service.pending[userID] = link
+	service.pendingMutex.Unlock()
+
+	return link.status(), nil
+}
+
+// Status returns the link between the user provided and their Trakt account.
+func (service *Service) Status(userID uuid.UUID) (*Link, error) {
+	if !service.enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	service.pendingMutex.Lock()
+	link, ok := service.pending[userID]
+	service.pendingMutex.Unlock()
+	if ok {
+		return link.status(), nil
+	}
+
+	account, err := service.store.GetTraktAccount(userID)
+	if err != nil {
+		if errors.Is(err, ErrNotLinked) {
+			return &Link{State: Unlinked}, nil
+		}
+
+		return nil, err
+	}
+
+	return &Link{State: Linked, LinkedAt: &account.LinkedAt, SyncedAt: account.SyncedAt, LastError: account.LastError}, nil
+}
+
+// Unlink cancels the pending link of the user provided, and deletes their linked Trakt account (if any),
+// revoking the access token of the account. The account is deleted even if the token cannot be revoked.
+func (service *Service) Unlink(ctx context.Context, userID uuid.UUID) error {
+	if !service.enabled() {
+		return ErrNotConfigured
+	}
+
+	service.pendingMutex.Lock()
+	_, wasPending := service.pending[userID]
+	delete(service.pending, userID)
+	service.pendingMutex.Unlock()
+
+	account, err := service.store.GetTraktAccount(userID)
+	if err != nil {
+		if errors.Is(err, ErrNotLinked) && wasPending {
+			return nil
+		}
+
+		return err
+	}
+
+	if accessToken, err := service.cipher.Decrypt(account.EncryptedAccessToken); err == nil {
+		if err := service.client.revokeToken(ctx, accessToken); err != nil {
+			log.Warnf("Failed to revoke Trakt token of user %s: %v\n", userID, err)
+		}
+	}
+
+	return service.store.DeleteTraktAccount(userID)
+}
+
+// Scrobble queues the playback progress of the user provided in the media given to be
+// scrobbled to Trakt, if the user has linked their account. Progress is scrobbled at
+// most once every few minutes, unless the media has been completed.
+func (service *Service) Scrobble(userID uuid.UUID, mediaID uuid.UUID, positionSeconds int, completed bool) {
+	if !service.enabled() {
+		return
+	}
+
+	select {
+	case service.scrobbles <- scrobble{userID: userID, mediaID: mediaID, positionSeconds: positionSeconds, completed: completed}:
+	default:
+		log.Warnf("Scrobble queue is full, dropping progress of user %s in media %s\n", userID, mediaID)
+	}
+}
+
+func (service *Service) enabled() bool {
+	return service.config.ClientID != "" && service.config.ClientSecret != "" && service.cipher != nil
+}
+
+// pollPendingLinks polls the device codes of the pending links which are due, linking the
+// accounts of the users who have approved their code. Links whose codes have expired, or
+// have been denied, are discarded.
+func (service *Service) pollPendingLinks(ctx context.Context) {
+	now := time.Now()
+
+	due := make(map[uuid.UUID]*pendingLink)
+	service.pendingMutex.Lock()
+	for userID, link := range service.pending {
+		if now.After(link.expiresAt) {
+			log.Emit(logger.INFO, "Trakt device code of user %s expired before it was approved\n", userID)
+			delete(service.pending, userID)
+		} else if !now.Before(link.nextPollAt) {
+			due[userID] = link
+		}
+	}
+	service.pendingMutex.Unlock()
+
+	for userID, link := range due {
+		token, err := service.client.pollDeviceToken(ctx, link.code.DeviceCode)
+		switch {
+		case err == nil:
+			service.completeLink(ctx, userID, link, token)
+		case errors.Is(err, errAuthorizationPending):
+			link.nextPollAt = now.Add(link.interval)
+		case errors.Is(err, errSlowDown):
+			link.interval += slowDownBackoff
+			link.nextPollAt = now.Add(link.interval)
+		case errors.Is(err, ErrDeviceCodeExpired), errors.Is(err, ErrDeviceCodeDenied):
+			log.Emit(logger.INFO, "Trakt link of user %s failed: %v\n", userID, err)
+			service.removePendingLink(userID, link)
+		default:
+			log.Warnf("Failed to poll Trakt device code of user %s: %v\n", userID, err)
+			link.nextPollAt = now.Add(link.interval)
+		}
+	}
+}
+
+// completeLink saves the account of the user provided using the token issued for their approved
+// device code, and imports their watched history. If the link was cancelled while the code was
+// being polled, the token is discarded.
+func (service *Service) completeLink(ctx context.Context, userID uuid.UUID, link *pendingLink, token *token) {
+	if !service.removePendingLink(userID, link) {
+		return
+	}
+
+	if err := service.saveToken(userID, token, time.Now()); err != nil {
+		log.Errorf("Failed to save Trakt account of user %s: %v\n", userID, err)
+		return
+	}
+
+	log.Emit(logger.SUCCESS, "Linked Trakt account of user %s\n", userID)
+	service.importHistory(ctx, userID, token.AccessToken)
+}
+
+// removePendingLink removes the pending link provided, returning false if the link is no
+// longer pending (e.g. because it was cancelled, or replaced by a new link).
+func (service *Service) removePendingLink(userID uuid.UUID, link *pendingLink) bool {
+	service.pendingMutex.Lock()
+	defer service.pendingMutex.Unlock()
+
+	if service.pending[userID] != link {
+		return false
+	}
+
+	delete(service.pending, userID)
+	return true
+}
+
+// importHistory imports the movies and episodes the user provided has watched on Trakt in to their
+// watch progress. Items which are not known to Thea are skipped, and progress recorded in Thea since
+// the item was watched is retained. The outcome of the import is saved against the user's account.
+func (service *Service) importHistory(ctx context.Context, userID uuid.UUID, accessToken string) {
+	imported := 0
+	var lastError *string
+	for _, typ := range []string{"movies", "episodes"} {
+		items, err := service.client.history(ctx, accessToken, typ)
+		if err != nil {
+			message := err.Error()
+			lastError = &message
+			break
+		}
+
+		for _, item := range items {
+			mediaID, ok := service.findMedia(item)
+			if !ok {
+				continue
+			}
+
+			if err := service.store.ImportWatchedMedia(userID, mediaID, item.WatchedAt); err != nil {
+				message := err.Error()
+				lastError = &message
+				continue
+			}
+
+			imported++
+		}
+	}
+
+	if lastError != nil {
+		log.Warnf("Import of Trakt history of user %s failed: %s\n", userID, *lastError)
+	} else {
+		log.Emit(logger.SUCCESS, "Imported %d watched item(s) from Trakt history of user %s\n", imported, userID)
+	}
+
+	if err := service.store.SaveTraktSyncResult(userID, time.Now(), lastError); err != nil {
+		log.Errorf("Failed to save outcome of Trakt history import of user %s: %v\n", userID, err)
+	}
+}
+
+// findMedia returns the ID of the movie or episode in Thea with the TMDB ID of the history item provided.
+func (service *Service) findMedia(item historyItem) (uuid.UUID, bool) {
+	if item.Movie != nil && item.Movie.IDs.Tmdb > 0 {
+		if movie, err := service.store.GetMovieWithTmdbID(strconv.Itoa(item.Movie.IDs.Tmdb)); err == nil {
+			return movie.ID, true
+		}
+	}
+	if item.Episode != nil && item.Episode.IDs.Tmdb > 0 {
+		if episode, err := service.store.GetEpisodeWithTmdbID(strconv.Itoa(item.Episode.IDs.Tmdb)); err == nil {
+			return episode.ID, true
+		}
+	}
+
+	return uuid.Nil, false
+}
+
+// scrobble sends the progress provided to Trakt, if the user has linked their account and the progress
+// of the user in the media has not been scrobbled recently. Progress is sent as a percentage of the
+// duration of the media, and completed media is scrobbled as fully watched.
+func (service *Service) scrobble(ctx context.Context, s scrobble) error {
+	now := time.Now()
+	for key, scrobbledAt := range service.lastScrobbled {
+		if now.Sub(scrobbledAt) >= scrobbleInterval {
+			delete(service.lastScrobbled, key)
+		}
+	}
+
+	key := scrobbleKey{userID: s.userID, mediaID: s.mediaID}
+	if _, ok := service.lastScrobbled[key]; ok && !s.completed {
+		return nil
+	}
+
+	account, err := service.store.GetTraktAccount(s.userID)
+	if err != nil {
+		if errors.Is(err, ErrNotLinked) {
+			return nil
+		}
+
+		return err
+	}
+
+	container := service.store.GetMedia(s.mediaID)
+	if container == nil {
+		return fmt.Errorf("media %s not found", s.mediaID)
+	}
+
+	request, err := newScrobbleRequest(container)
+	if err != nil {
+		return err
+	}
+
+	request.Progress = 100
+	if !s.completed {
+		duration, err := ffmpeg.ProbeDuration(container.Source(), service.ffprobePath)
+		if err != nil {
+			return fmt.Errorf("failed to probe duration of media: %w", err)
+		}
+
+		request.Progress = scrobbleProgress(s.positionSeconds, duration)
+	}
+
+	accessToken, err := service.accessToken(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	if err := service.client.scrobble(ctx, accessToken, *request, s.completed); err != nil {
+		return err
+	}
+
+	if s.completed {
+		delete(service.lastScrobbled, key)
+	} else {
+		service.lastScrobbled[key] = now
+	}
+
+	return nil
+}
+
+// accessToken returns the decrypted access token of the account provided, refreshing
+// the tokens of the account if the access token is due to expire.
+func (service *Service) accessToken(ctx context.Context, account *Account) (string, error) {
+	if time.Until(account.ExpiresAt) > tokenRefreshWindow {
+		return service.cipher.Decrypt(account.EncryptedAccessToken)
+	}
+
+	refreshToken, err := service.cipher.Decrypt(account.EncryptedRefreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := service.client.refreshToken(ctx, refreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	if err := service.saveToken(account.UserID, token, account.LinkedAt); err != nil {
+		return "", fmt.Errorf("failed to save refreshed token: %w", err)
+	}
+
+	return token.AccessToken, nil
+}
+
+// saveToken encrypts and saves the token provided as the account of the user given.
+func (service *Service) saveToken(userID uuid.UUID, token *token, linkedAt time.Time) error {
+	accessToken, err := service.cipher.Encrypt(token.AccessToken)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := service.cipher.Encrypt(token.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	return service.store.SaveTraktAccount(&Account{
+		UserID:                userID,
+		EncryptedAccessToken:  accessToken,
+		EncryptedRefreshToken: refreshToken,
+		ExpiresAt:             token.expiresAt(),
+		LinkedAt:              linkedAt,
+	})
+}
+
+func (link *pendingLink) status() *Link {
+	return &Link{
+		State:           Pending,
+		UserCode:        &link.code.UserCode,
+		VerificationURL: &link.code.VerificationURL,
+		ExpiresAt:       &link.expiresAt,
+	}
+}
+
+// newScrobbleRequest returns a scrobble request identifying the movie or episode provided by its TMDB ID.
+func newScrobbleRequest(container *media.Container) (*scrobbleRequest, error) {
+	tmdbID, err := strconv.Atoi(container.TmdbID())
+	if err != nil {
+		return nil, fmt.Errorf("media %s has an invalid TMDB ID '%s'", container.ID(), container.TmdbID())
+	}
+
+	item := &item{IDs: ids{Tmdb: tmdbID}}
+	switch container.Type {
+	case media.MovieContainerType:
+		return &scrobbleRequest{Movie: item}, nil
+	case media.EpisodeContainerType:
+		return &scrobbleRequest{Episode: item}, nil
+	}
+
+	return nil, fmt.Errorf("media %s cannot be scrobbled", container.ID())
+}
+
+// scrobbleProgress returns the position provided as a percentage of the duration given.
+func scrobbleProgress(positionSeconds int, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+
+	return min(100, max(0, 100*float64(positionSeconds)/duration.Seconds()))
+}
@@ -0,0 +1,180 @@
+package trakt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/stretchr/testify/assert"
+)
+
+type accountStore struct {
+	DataStore
+	account  *Account
+	movie    *media.Movie
+	watched  map[uuid.UUID]time.Time
+	syncedAt *time.Time
+}
+
+func (store *accountStore) GetTraktAccount(userID uuid.UUID) (*Account, error) {
+	if store.account == nil || store.account.UserID != userID {
+		return nil, ErrNotLinked
+	}
+
+	return store.account, nil
+}
+
+func (store *accountStore) SaveTraktAccount(account *Account) error {
+	store.account = account
+	return nil
+}
+
+func (store *accountStore) SaveTraktSyncResult(_ uuid.UUID, syncedAt time.Time, _ *string) error {
+	store.syncedAt = &syncedAt
+	return nil
+}
+
+func (store *accountStore) GetMedia(mediaID uuid.UUID) *media.Container {
+	if store.movie == nil || store.movie.ID != mediaID {
+		return nil
+	}
+
+	return &media.Container{Type: media.MovieContainerType, Movie: store.movie}
+}
+
+func (store *accountStore) GetMovieWithTmdbID(tmdbID string) (*media.Movie, error) {
+	if store.movie == nil || store.movie.TmdbID != tmdbID {
+		return nil, fmt.Errorf("movie with TMDB ID %s not found", tmdbID)
+	}
+
+	return store.movie, nil
+}
+
+func (store *accountStore) GetEpisodeWithTmdbID(tmdbID string) (*media.Episode, error) {
+	return nil, fmt.Errorf("episode with TMDB ID %s not found", tmdbID)
+}
+
+func (store *accountStore) ImportWatchedMedia(_ uuid.UUID, mediaID uuid.UUID, watchedAt time.Time) error {
+	store.watched[mediaID] = watchedAt
+	return nil
+}
+
+func newTestService(t *testing.T, handler http.HandlerFunc, store DataStore) *Service {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return New(Config{ClientID: "id", ClientSecret: "secret", CredentialKey: "key", BaseURL: server.URL}, "", store)
+}
+
+func Test_Client_PollDeviceToken(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		status int
+		err    error
+	}{
+		{http.StatusBadRequest, errAuthorizationPending},
+		{http.StatusTooManyRequests, errSlowDown},
+		{http.StatusNotFound, ErrDeviceCodeExpired},
+		{http.StatusGone, ErrDeviceCodeExpired},
+		{http.StatusTeapot, ErrDeviceCodeDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			t.Parallel()
+			service := newTestService(t, func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(tt.status) }, nil)
+
+			_, err := service.client.pollDeviceToken(context.Background(), "code")
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		t.Parallel()
+		service := newTestService(t, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","expires_in":60,"created_at":1700000000}`))
+		}, nil)
+
+		token, err := service.client.pollDeviceToken(context.Background(), "code")
+		assert.NoError(t, err)
+		assert.Equal(t, "access", token.AccessToken)
+		assert.Equal(t, time.Unix(1700000060, 0), token.expiresAt())
+	})
+}
+
+func Test_Client_History_Paginates(t *testing.T) {
+	t.Parallel()
+	service := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/sync/history/movies", r.URL.Path)
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+
+		page := r.URL.Query().Get("page")
+		w.Header().Set("X-Pagination-Page-Count", "2")
+		_, _ = fmt.Fprintf(w, `[{"type":"movie","watched_at":"2024-01-0%sT00:00:00Z","movie":{"ids":{"tmdb":%s}}}]`, page, page)
+	}, nil)
+
+	items, err := service.client.history(context.Background(), "access", "movies")
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, 2, items[1].Movie.IDs.Tmdb)
+}
+
+func Test_Service_ImportHistory(t *testing.T) {
+	t.Parallel()
+	movie := &media.Movie{Model: media.Model{ID: uuid.New(), TmdbID: "603"}}
+	store := &accountStore{movie: movie, watched: make(map[uuid.UUID]time.Time)}
+	service := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sync/history/movies" {
+			_, _ = w.Write([]byte(`[{"type":"movie","watched_at":"2024-01-01T00:00:00Z","movie":{"ids":{"tmdb":603}}},` +
+				`{"type":"movie","watched_at":"2024-01-02T00:00:00Z","movie":{"ids":{"tmdb":604}}}]`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`[]`))
+	}, store)
+
+	service.importHistory(context.Background(), uuid.New(), "access")
+	assert.Equal(t, map[uuid.UUID]time.Time{movie.ID: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, store.watched,
+		"only movies known to Thea are imported")
+	assert.NotNil(t, store.syncedAt)
+}
+
+func Test_Service_Scrobble(t *testing.T) {
+	t.Parallel()
+	userID := uuid.New()
+	movie := &media.Movie{Model: media.Model{ID: uuid.New(), TmdbID: "603"}}
+	store := &accountStore{movie: movie}
+
+	var paths []string
+	var requests []scrobbleRequest
+	service := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+
+		var request scrobbleRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		paths = append(paths, r.URL.Path)
+		requests = append(requests, request)
+	}, store)
+	assert.NoError(t, service.saveToken(userID, &token{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 86400}, time.Now()))
+
+	assert.NoError(t, service.scrobble(context.Background(), scrobble{userID: userID, mediaID: movie.ID, completed: true}))
+	assert.NoError(t, service.scrobble(context.Background(), scrobble{userID: uuid.New(), mediaID: movie.ID, completed: true}),
+		"users without a linked account are not scrobbled")
+
+	assert.Equal(t, []string{"/scrobble/stop"}, paths)
+	assert.Equal(t, []scrobbleRequest{{Movie: &item{IDs: ids{Tmdb: 603}}, Progress: 100}}, requests)
+}
+
+func Test_ScrobbleProgress(t *testing.T) {
+	t.Parallel()
+	assert.InDelta(t, 50.0, scrobbleProgress(30, time.Minute), 0.001)
+	assert.InDelta(t, 100.0, scrobbleProgress(90, time.Minute), 0.001, "progress is clamped to 100")
+	assert.InDelta(t, 0.0, scrobbleProgress(30, 0), 0.001, "unknown durations have no progress")
+}
@@ -0,0 +1,223 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"github.com/hbomb79/Thea/internal/workflow/match"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+var (
+	ErrBatchWorkflowNotFound = errors.New("workflow for batch not found")
+	ErrBatchTargetNotFound   = errors.New("target for batch is not a target of the workflow")
+)
+
+type (
+	// Batch is a named set of transcode tasks, queued by applying a workflow (or one of it's
+	// targets) to media which had already been ingested. Batches are not persisted.
+	Batch struct {
+		ID         uuid.UUID
+		Label      string
+		WorkflowID uuid.UUID
+		TargetID   *uuid.UUID
+		CreatedAt  time.Time
+
+		matched   int
+		skipped   int
+		taskIDs   []uuid.UUID
+		completed map[uuid.UUID]struct{}
+	}
+
+	// BatchFilter selects the media which a batch is applied to.
+	BatchFilter struct {
+		// Types restricts the batch to movies and/or episodes. If empty, both are included.
+		Types []media.ContainerType
+
+		// Criteria is evaluated in the same way as the criteria of a workflow. If nil,
+		// the criteria of the workflow being applied is used instead.
+		Criteria []match.Criteria
+	}
+
+	// BatchProgress counts the tasks of a batch by their current state. Matched is the number of
+	// media which matched the filter of the batch, and Skipped is the number of tasks which were
+	// not queued because the media already has an active or completed transcode for the target.
+	BatchProgress struct {
+		Matched   int
+		Skipped   int
+		Total     int
+		Waiting   int
+		Working   int
+		Completed int
+		Failed    int
+		Cancelled int
+	}
+)
+
+// ApplyWorkflow queues transcode tasks for the targets of a workflow (or only the target with
+// the ID provided, which must be one of the workflows targets) against all media in the library
+// which match the filter. The workflow is applied regardless of whether it is enabled. The
+// batch returned can be used to track the progress of the tasks queued.
+func (service *transcodeService) ApplyWorkflow(ctx context.Context, label string, workflowID uuid.UUID, targetID *uuid.UUID, filter BatchFilter) (*Batch, error) {
+	wf := service.dataStore.GetWorkflow(workflowID)
+	if wf == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBatchWorkflowNotFound, workflowID)
+	}
+
+	targets := wf.Targets
+	if targetID != nil {
+		idx := slices.IndexFunc(wf.Targets, func(t *ffmpeg.Target) bool { return t.ID == *targetID })
+		if idx == -1 {
+			return nil, fmt.Errorf("%w: %s", ErrBatchTargetNotFound, *targetID)
+		}
+		targets = wf.Targets[idx : idx+1]
+	}
+
+	matcher := wf
+	if filter.Criteria != nil {
+		matcher = &workflow.Workflow{ID: wf.ID, Label: wf.Label}
+		if err := matcher.SetCriteria(filter.Criteria); err != nil {
+			return nil, err
+		}
+	}
+
+	sources, err := service.dataStore.ListMediaSourceFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+	mediaIDs := make([]uuid.UUID, len(sources))
+	for k, source := range sources {
+		mediaIDs[k] = source.MediaID
+	}
+	medias, err := service.dataStore.GetMedias(mediaIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch media: %w", err)
+	}
+
+	batch := &Batch{
+		ID:         uuid.New(),
+		Label:      label,
+		WorkflowID: wf.ID,
+		TargetID:   targetID,
+		CreatedAt:  time.Now(),
+		taskIDs:    make([]uuid.UUID, 0),
+		completed:  make(map[uuid.UUID]struct{}),
+	}
+
+	service.Lock()
+	if service.draining {
+		service.Unlock()
+		return nil, ErrDraining
+	}
+	service.batches = append(service.batches, batch)
+	service.Unlock()
+
+	ctx = logger.ContextWithFields(ctx, logger.Fields{"workflow_id": wf.ID, "batch_id": batch.ID})
+	for _, m := range medias {
+		if len(filter.Types) > 0 && !slices.Contains(filter.Types, m.Type) {
+			continue
+		}
+		if !matcher.MatchesMedia(m) {
+			continue
+		}
+
+		service.Lock()
+		batch.matched++
+		service.Unlock()
+
+		for _, target := range targets {
+			task, err := service.spawnFfmpegTarget(ctx, m, nil, target, &wf.ID, nil, false)
+
+			service.Lock()
+			if err != nil {
+				log.WithContext(ctx).Debugf("Skipping target %s for media %s in batch '%s': %v\n", target, m.ID(), batch.Label, err)
+				batch.skipped++
+			} else {
+				batch.taskIDs = append(batch.taskIDs, task.ID())
+			}
+			service.Unlock()
+		}
+	}
+
+	progress := service.BatchProgress(batch)
+	log.WithContext(ctx).Emit(logger.NEW, "Batch '%s' queued %d transcode task(s) for %d matching media (%d skipped)\n", batch.Label, progress.Total, progress.Matched, progress.Skipped)
+	return batch, nil
+}
+
+// Batches returns all the batches created since Thea started.
+func (service *transcodeService) Batches() []*Batch {
+	service.Lock()
+	defer service.Unlock()
+
+	return slices.Clone(service.batches)
+}
+
+// Batch returns the batch with the ID provided, or nil if no such batch exists.
+func (service *transcodeService) Batch(id uuid.UUID) *Batch {
+	service.Lock()
+	defer service.Unlock()
+
+	for _, batch := range service.batches {
+		if batch.ID == id {
+			return batch
+		}
+	}
+
+	return nil
+}
+
+// BatchProgress returns the progress of the tasks queued by the batch provided. Tasks which
+// are no longer in the queue, and were not completed, are considered cancelled.
+func (service *transcodeService) BatchProgress(batch *Batch) BatchProgress {
+	service.Lock()
+	defer service.Unlock()
+
+	progress := BatchProgress{Matched: batch.matched, Skipped: batch.skipped, Total: len(batch.taskIDs)}
+	for _, id := range batch.taskIDs {
+		if _, ok := batch.completed[id]; ok {
+			progress.Completed++
+			continue
+		}
+
+		task := service.Task(id)
+		if task == nil {
+			progress.Cancelled++
+			continue
+		}
+
+		//exhaustive:enforce
+		switch task.Status() {
+		case WAITING:
+			progress.Waiting++
+		case WORKING, SUSPENDED:
+			progress.Working++
+		case TROUBLED:
+			progress.Failed++
+		case CANCELLED:
+			progress.Cancelled++
+		case COMPLETE:
+			progress.Completed++
+		}
+	}
+
+	return progress
+}
+
+// recordBatchCompletion marks the task provided as completed in any batch which queued it.
+func (service *transcodeService) recordBatchCompletion(taskID uuid.UUID) {
+	service.Lock()
+	defer service.Unlock()
+
+	for _, batch := range service.batches {
+		if slices.Contains(batch.taskIDs, taskID) {
+			batch.completed[taskID] = struct{}{}
+		}
+	}
+}
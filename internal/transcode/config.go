@@ -1,8 +1,134 @@
 package transcode
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+)
+
 type Config struct {
 	OutputPath               string `toml:"default_output_dir" env:"FORMAT_DEFAULT_OUTPUT_DIR" env-required:"true"`
 	FfmpegBinaryPath         string `toml:"ffmpeg_binary_path" env:"FORMAT_FFMPEG_BINARY_PATH" env-default:"/usr/bin/ffmpeg"`
 	FfprobeBinaryPath        string `toml:"ffprobe_binary_path" env:"FORMAT_FFPROBE_BINARY_PATH" env-default:"/usr/bin/ffprobe"`
 	MaximumThreadConsumption int    `toml:"max_thread_consumption" env-default:"8"`
+
+	// Reclaim controls how rarely watched transcodes are archived and evicted
+	// to reclaim storage (see ReclaimConfig).
+	Reclaim ReclaimConfig `toml:"reclaim"`
+
+	// DrainTimeout is the maximum amount of time the service will wait for running transcodes
+	// to finish when Thea is shutting down. Tasks which have not finished by this time are
+	// cancelled, and re-queued when Thea next starts. A zero value disables draining.
+	DrainTimeout time.Duration `toml:"drain_timeout" env:"FORMAT_DRAIN_TIMEOUT" env-default:"0s"`
+
+	// MinimumFreeSpaceMB is the amount of space (in megabytes) which must remain available on the
+	// output volume for a transcode to be started. Tasks are held in the queue while the volume is
+	// below this reserve. A zero value disables the check.
+	MinimumFreeSpaceMB uint64 `toml:"minimum_free_space_mb" env:"FORMAT_MINIMUM_FREE_SPACE_MB" env-default:"1024"`
+
+	// StoragePools are the locations transcode outputs are written to (see StoragePool). If
+	// none are configured, outputs are written to the default output directory, subject to
+	// the minimum free space above.
+	StoragePools []StoragePool `toml:"storage_pools"`
+
+	// StallTimeout is how long ffmpeg may go without reporting progress before the transcode is
+	// considered stalled and is stopped (see Retries). A zero value disables the watchdog.
+	StallTimeout time.Duration `toml:"stall_timeout" env:"FORMAT_STALL_TIMEOUT" env-default:"5m"`
+
+	// LogSizeKB is the amount of ffmpeg output (in kilobytes) retained for each task. The
+	// output is persisted when the task concludes, so that failures can be debugged.
+	LogSizeKB int `toml:"log_size_kb" env:"FORMAT_LOG_SIZE_KB" env-default:"64"`
+
+	// PreviewTTL is how long previews (short samples of a media transcoded using a
+	// target) are retained before being deleted.
+	PreviewTTL time.Duration `toml:"preview_ttl" env:"FORMAT_PREVIEW_TTL" env-default:"1h"`
+
+	// Retries controls how many times a TROUBLED task is automatically re-queued, based
+	// on the type of trouble it encountered.
+	Retries RetryPolicy `toml:"retries"`
+
+	// SkipCompliantSources checks the source of each task against it's target before the task is
+	// created. Sources which already satisfy the target are recorded as the transcode of the
+	// target without being transcoded, and sources which only differ in container are remuxed
+	// rather than re-encoded (see ffmpeg.CheckCompliance).
+	SkipCompliantSources bool `toml:"skip_compliant_sources" env:"FORMAT_SKIP_COMPLIANT_SOURCES" env-default:"false"`
+
+	// Segmentation controls whether long media are transcoded as several time segments
+	// which are encoded in parallel (see SegmentationConfig).
+	Segmentation SegmentationConfig `toml:"segmentation"`
+
+	// Priority controls the CPU/IO priority of the ffmpeg processes spawned
+	// for each task (see PriorityConfig).
+	Priority PriorityConfig `toml:"priority"`
+
+	// Contention controls whether background tasks are suspended to make room
+	// for live-stream playback sessions (see ContentionConfig).
+	Contention ContentionConfig `toml:"contention"`
+
+	// Hooks controls whether the hooks of workflows are executed
+	// once their transcodes complete (see HooksConfig).
+	Hooks HooksConfig `toml:"hooks"`
+}
+
+// HooksConfig controls the execution of workflow hooks (see workflow.Hook). As hooks execute arbitrary
+// commands on the host as the user running Thea, they are disabled by default and must be enabled by
+// the operator. Workflows may still be saved with hooks while disabled, but the hooks are not executed.
+// Hooks which do not specify a timeout are killed once the DefaultTimeout has elapsed.
+type HooksConfig struct {
+	Enabled        bool          `toml:"enabled" env:"FORMAT_HOOKS_ENABLED" env-default:"false"`
+	DefaultTimeout time.Duration `toml:"default_timeout" env:"FORMAT_HOOKS_DEFAULT_TIMEOUT" env-default:"5m"`
+}
+
+// PriorityConfig controls the CPU and I/O scheduling priority of the ffmpeg processes spawned for each task,
+// so that transcodes do not starve interactive streaming sessions on the same host. By default, transcodes
+// are run with a lower CPU and disk priority than Thea itself. The idle I/O class should be used with care, as
+// tasks which are starved of disk access for longer than the stall timeout are stopped.
+//
+// If a CPU weight and cgroup are configured, each process is placed in a child of the cgroup (which must be
+// a cgroup v2 directory delegated to Thea) using the CPU weight, rather than relying on niceness. Tasks which
+// are marked important when manually requested use the important priority instead (see ffmpeg.Priority).
+type PriorityConfig struct {
+	Nice      int    `toml:"nice" env:"FORMAT_PRIORITY_NICE" env-default:"10"`
+	IOClass   string `toml:"io_class" env:"FORMAT_PRIORITY_IO_CLASS" env-default:"best-effort"`
+	IOLevel   int    `toml:"io_level" env:"FORMAT_PRIORITY_IO_LEVEL" env-default:"7"`
+	CPUWeight int    `toml:"cpu_weight" env:"FORMAT_PRIORITY_CPU_WEIGHT" env-default:"0"`
+	Cgroup    string `toml:"cgroup" env:"FORMAT_PRIORITY_CGROUP"`
+
+	ImportantNice      int    `toml:"important_nice" env:"FORMAT_PRIORITY_IMPORTANT_NICE" env-default:"0"`
+	ImportantIOClass   string `toml:"important_io_class" env:"FORMAT_PRIORITY_IMPORTANT_IO_CLASS" env-default:"best-effort"`
+	ImportantIOLevel   int    `toml:"important_io_level" env:"FORMAT_PRIORITY_IMPORTANT_IO_LEVEL" env-default:"4"`
+	ImportantCPUWeight int    `toml:"important_cpu_weight" env:"FORMAT_PRIORITY_IMPORTANT_CPU_WEIGHT" env-default:"0"`
+}
+
+// priority returns the priority of the tasks which are, or are not, important.
+func (config PriorityConfig) priority(important bool) ffmpeg.Priority {
+	if important {
+		return ffmpeg.Priority{Nice: config.ImportantNice, IOClass: config.ImportantIOClass, IOLevel: config.ImportantIOLevel, CPUWeight: config.ImportantCPUWeight}
+	}
+
+	return ffmpeg.Priority{Nice: config.Nice, IOClass: config.IOClass, IOLevel: config.IOLevel, CPUWeight: config.CPUWeight}
+}
+
+// Validate returns an error if either of the priorities configured are invalid.
+func (config PriorityConfig) Validate() error {
+	if err := config.priority(false).Validate(); err != nil {
+		return err
+	}
+	if err := config.priority(true).Validate(); err != nil {
+		return fmt.Errorf("important %w", err)
+	}
+
+	return nil
+}
+
+// SegmentationConfig controls segmented transcoding, where the input of a task is split in to
+// time segments which are encoded in parallel before being concatenated in to the output. Only
+// media which are at least MinimumDuration long are segmented, and targets whose video encoder
+// cannot be segmented (see ffmpeg.SupportsSegmentation) are always transcoded in a single pass.
+// Segmented tasks consume the thread budget of each of their segments.
+type SegmentationConfig struct {
+	Enabled         bool          `toml:"enabled" env:"FORMAT_SEGMENTATION_ENABLED" env-default:"false"`
+	Segments        int           `toml:"segments" env:"FORMAT_SEGMENTATION_SEGMENTS" env-default:"4"`
+	MinimumDuration time.Duration `toml:"minimum_duration" env:"FORMAT_SEGMENTATION_MINIMUM_DURATION" env-default:"1h"`
 }
@@ -0,0 +1,331 @@
+package transcode
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/preferences"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+const (
+	// ContentionNone leaves running transcodes untouched when playback sessions are started.
+	ContentionNone ContentionPolicy = "none"
+
+	// ContentionSuspend suspends background transcodes when a playback session is started while
+	// the thread budget is exhausted, and resumes them once enough sessions have ended.
+	ContentionSuspend ContentionPolicy = "suspend"
+)
+
+// sessionExpiryInterval is how often playback sessions which have not been refreshed are ended.
+const sessionExpiryInterval = 15 * time.Second
+
+var (
+	ErrPlaybackSessionNotFound = errors.New("playback session not found")
+	ErrPlaybackMediaNotFound   = errors.New("media for playback session not found")
+)
+
+type (
+	ContentionPolicy string
+
+	// ContentionConfig controls how the transcode queue responds to playback sessions (see
+	// PlaybackSession). Each session reserves SessionThreads of the thread budget, and sessions
+	// which are not refreshed within the SessionTimeout are ended automatically.
+	ContentionConfig struct {
+		Policy         ContentionPolicy `toml:"policy" env:"FORMAT_CONTENTION_POLICY" env-default:"none"`
+		SessionThreads int              `toml:"session_threads" env:"FORMAT_CONTENTION_SESSION_THREADS" env-default:"2"`
+		SessionTimeout time.Duration    `toml:"session_timeout" env:"FORMAT_CONTENTION_SESSION_TIMEOUT" env-default:"2m"`
+	}
+
+	// PlaybackSession is a live-stream playback of a media by a user. Thea does not serve live streams
+	// itself, and so sessions are reported by the client (or streaming server) when playback starts,
+	// refreshed periodically while playback continues, and ended when playback stops.
+	//
+	// The playback preferences of the user are applied when the session starts: the source is probed
+	// to select the audio and subtitle streams using the preferred languages of the user, and to decide
+	// whether the source can be played directly without exceeding the maximum bitrate of the user. If
+	// not, the client should play a watch target within the bitrate instead (see BuildWatchTargets).
+	PlaybackSession struct {
+		ID          uuid.UUID
+		UserID      uuid.UUID
+		MediaID     uuid.UUID
+		VersionID   *uuid.UUID
+		Preferences *preferences.Preferences
+		StartedAt   time.Time
+		ExpiresAt   time.Time
+
+		// DirectPlay is false if the bitrate of the source exceeds the maximum bitrate of the user.
+		DirectPlay bool
+
+		// AudioStreamIndex and SubtitleStreamIndex are the indices of the source streams selected
+		// using the preferred languages of the user, or nil if the user has no preference (or in the
+		// case of subtitles, no subtitle stream uses the preferred language).
+		AudioStreamIndex    *int
+		SubtitleStreamIndex *int
+	}
+)
+
+// Validate returns an error if the contention config is not valid.
+func (config ContentionConfig) Validate() error {
+	if !slices.Contains([]ContentionPolicy{ContentionNone, ContentionSuspend}, config.Policy) {
+		return fmt.Errorf("policy: unknown contention policy '%s'", config.Policy)
+	}
+	if config.SessionThreads < 1 {
+		return fmt.Errorf("session_threads: must be at least 1, got %d", config.SessionThreads)
+	}
+	if config.SessionTimeout <= 0 {
+		return fmt.Errorf("session_timeout: must be positive, got %s", config.SessionTimeout)
+	}
+
+	return nil
+}
+
+// StartPlayback records a playback session of the media (or version) provided by the user given. If
+// the user already has as many sessions as their quota allows, quota.ErrSessionLimitReached is returned.
+// If the contention policy is ContentionSuspend and the threads reserved by the playback sessions cannot
+// be satisfied by the thread budget, background transcodes are suspended to make room.
+func (service *transcodeService) StartPlayback(userID uuid.UUID, mediaID uuid.UUID, versionID *uuid.UUID) (*PlaybackSession, error) {
+	m := service.dataStore.GetMedia(mediaID)
+	if m == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPlaybackMediaNotFound, mediaID)
+	}
+	version, err := service.mediaVersion(mediaID, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPlaybackMediaNotFound, err)
+	}
+	prefs, err := service.dataStore.GetUserPreferences(userID)
+	if err != nil {
+		log.Warnf("Unable to get playback preferences of user %s, default preferences will be used for playback session: %v\n", userID, err)
+		prefs = preferences.Default(userID)
+	}
+
+	// Probing the source is performed before the mutex is acquired, as ffprobe may take some time
+	source, err := ffmpeg.ProbePlaybackSource(sourcePath(m, version), service.config.FfprobeBinaryPath)
+	if err != nil {
+		log.Warnf("Unable to probe source of media %s, playback preferences of user %s will only be partially applied: %v\n", mediaID, userID, err)
+	}
+
+	service.Lock()
+	defer service.Unlock()
+
+	usage, err := service.quotaUsage(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := usage.CheckSession(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &PlaybackSession{
+		ID:          uuid.New(),
+		UserID:      userID,
+		MediaID:     mediaID,
+		VersionID:   versionID,
+		Preferences: prefs,
+		StartedAt:   now,
+		ExpiresAt:   now.Add(service.config.Contention.SessionTimeout),
+	}
+	session.applyPreferences(source)
+	service.sessions = append(service.sessions, session)
+	log.Emit(logger.DEBUG, "Playback session %s started for media %s by user %s\n", session.ID, mediaID, userID)
+
+	service.suspendForContention()
+	return session, nil
+}
+
+// applyPreferences decides how the source (if it could be probed) of the session is played, using
+// the preferences of the session. If the source is nil, direct play is always permitted, and no
+// audio or subtitle streams are selected.
+func (session *PlaybackSession) applyPreferences(source *ffmpeg.PlaybackSource) {
+	session.DirectPlay = true
+	if source == nil {
+		return
+	}
+
+	session.DirectPlay = source.AllowsDirectPlay(session.Preferences.MaxBitrateKbps)
+	if language := session.Preferences.AudioLanguage; language != nil {
+		if audio := ffmpeg.SelectAudioStream(source.Audio, []string{*language}); audio != nil {
+			session.AudioStreamIndex = &audio.Index
+		}
+	}
+	if language := session.Preferences.SubtitleLanguage; language != nil {
+		if subtitle := ffmpeg.SelectSubtitleStream(source.Subtitles, *language); subtitle != nil {
+			session.SubtitleStreamIndex = &subtitle.Index
+		}
+	}
+}
+
+// RefreshPlayback extends the playback session of the user provided, so that
+// it's not ended automatically while playback continues.
+func (service *transcodeService) RefreshPlayback(userID uuid.UUID, sessionID uuid.UUID) (*PlaybackSession, error) {
+	service.Lock()
+	defer service.Unlock()
+
+	session := service.playbackSession(userID, sessionID)
+	if session == nil {
+		return nil, ErrPlaybackSessionNotFound
+	}
+
+	session.ExpiresAt = time.Now().Add(service.config.Contention.SessionTimeout)
+	return session, nil
+}
+
+// EndPlayback ends the playback session of the user provided, resuming any
+// transcodes which were suspended for the session if the budget now permits.
+func (service *transcodeService) EndPlayback(userID uuid.UUID, sessionID uuid.UUID) error {
+	service.Lock()
+	defer service.Unlock()
+
+	if service.playbackSession(userID, sessionID) == nil {
+		return ErrPlaybackSessionNotFound
+	}
+
+	service.sessions = slices.DeleteFunc(service.sessions, func(s *PlaybackSession) bool { return s.ID == sessionID })
+	log.Emit(logger.DEBUG, "Playback session %s ended\n", sessionID)
+
+	service.relieveContention()
+	return nil
+}
+
+// PlaybackSessions returns the playback sessions which are ongoing.
+func (service *transcodeService) PlaybackSessions() []*PlaybackSession {
+	service.Lock()
+	defer service.Unlock()
+
+	return slices.Clone(service.sessions)
+}
+
+// removeExpiredSessions ends the playback sessions which have not been refreshed within the session timeout.
+//
+// Note: This function takes ownership of the mutex and releases it on return.
+func (service *transcodeService) removeExpiredSessions() {
+	service.Lock()
+	defer service.Unlock()
+
+	now := time.Now()
+	expired := 0
+	service.sessions = slices.DeleteFunc(service.sessions, func(s *PlaybackSession) bool {
+		if now.After(s.ExpiresAt) {
+			expired++
+			return true
+		}
+
+		return false
+	})
+
+	if expired > 0 {
+		log.Emit(logger.DEBUG, "Ended %d playback session(s) which were not refreshed\n", expired)
+		service.relieveContention()
+	}
+}
+
+// playbackSession returns the session with the ID provided if it belongs to the user given.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) playbackSession(userID uuid.UUID, sessionID uuid.UUID) *PlaybackSession {
+	for _, session := range service.sessions {
+		if session.ID == sessionID && session.UserID == userID {
+			return session
+		}
+	}
+
+	return nil
+}
+
+// reservedThreads returns the threads of the budget reserved by the ongoing playback sessions.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) reservedThreads() int {
+	if service.config.Contention.Policy != ContentionSuspend {
+		return 0
+	}
+
+	return len(service.sessions) * service.config.Contention.SessionThreads
+}
+
+// workingThreads returns the threads consumed by the tasks which are WORKING. Unlike
+// consumedThreads, the threads of SUSPENDED tasks are excluded.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) workingThreads() int {
+	threads := 0
+	for _, task := range service.tasksWithStatus(WORKING) {
+		threads += task.requiredThreads()
+	}
+
+	return threads
+}
+
+// suspendForContention suspends background tasks (those created automatically, rather than requested
+// by a user) until the working tasks and playback sessions fit within the thread budget. The most
+// recently queued tasks are suspended first. Manually requested tasks are never suspended.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) suspendForContention() {
+	if service.config.Contention.Policy != ContentionSuspend {
+		return
+	}
+
+	for service.workingThreads()+service.reservedThreads() > service.config.MaximumThreadConsumption {
+		var candidate *TranscodeTask
+		for i := len(service.tasks) - 1; i >= 0; i-- {
+			if task := service.tasks[i]; task.status == WORKING && task.requestedBy == nil && !task.important {
+				candidate = task
+				break
+			}
+		}
+		if candidate == nil {
+			return
+		}
+
+		if err := candidate.pause(); err != nil {
+			candidate.log.Warnf("Failed to suspend %s for playback contention: %v\n", candidate, err)
+			return
+		}
+
+		service.contentionSuspended = append(service.contentionSuspended, candidate.id)
+		candidate.log.Emit(logger.STOP, "Suspended %s to make room for %d playback session(s)\n", candidate, len(service.sessions))
+		service.eventBus.Dispatch(event.TranscodeUpdateEvent, candidate.id)
+	}
+}
+
+// relieveContention resumes the tasks suspended by suspendForContention, in the reverse order they
+// were suspended, while the thread budget permits. If the queue has since been paused, the tasks
+// are instead resumed when the queue is resumed.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) relieveContention() {
+	for len(service.contentionSuspended) > 0 {
+		last := len(service.contentionSuspended) - 1
+		task := service.Task(service.contentionSuspended[last])
+		if task == nil || task.status != SUSPENDED {
+			// The task has since been cancelled, or manually resumed
+			service.contentionSuspended = service.contentionSuspended[:last]
+			continue
+		}
+
+		if service.reservedThreads() > 0 && service.workingThreads()+task.requiredThreads()+service.reservedThreads() > service.config.MaximumThreadConsumption {
+			return
+		}
+
+		service.contentionSuspended = service.contentionSuspended[:last]
+		if service.queuePaused {
+			service.queueSuspended = append(service.queueSuspended, task.id)
+			continue
+		}
+
+		if err := task.resume(); err != nil {
+			task.log.Warnf("Failed to resume %s after playback contention: %v\n", task, err)
+			continue
+		}
+
+		task.log.Emit(logger.NEW, "Resumed %s as playback contention has eased\n", task)
+		service.eventBus.Dispatch(event.TranscodeUpdateEvent, task.id)
+	}
+}
@@ -0,0 +1,140 @@
+package transcode
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/floostack/transcoder"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/preferences"
+	"github.com/hbomb79/Thea/internal/quota"
+	"github.com/hbomb79/Thea/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// suspendableCommand is a Command which only records whether it's suspended.
+type suspendableCommand struct{ suspended bool }
+
+func (cmd *suspendableCommand) Run(context.Context, transcoder.Options, func(*ffmpeg.Progress)) error {
+	return nil
+}
+func (cmd *suspendableCommand) Suspend() error     { cmd.suspended = true; return nil }
+func (cmd *suspendableCommand) Continue() error    { cmd.suspended = false; return nil }
+func (cmd *suspendableCommand) OutputTail() string { return "" }
+
+func newWorkingTask(requestedBy *uuid.UUID) *TranscodeTask {
+	task := newTestTask(newTestMedia(), WORKING)
+	task.target = &ffmpeg.Target{}
+	task.command = &suspendableCommand{}
+	task.requestedBy = requestedBy
+	task.log = logger.Get("Test")
+	return task
+}
+
+func Test_Contention_SuspendsBackgroundTasks(t *testing.T) {
+	userID := uuid.New()
+	manual, first, second := newWorkingTask(&userID), newWorkingTask(nil), newWorkingTask(nil)
+	service := &transcodeService{
+		Mutex:    &sync.Mutex{},
+		config:   &Config{MaximumThreadConsumption: 6, Contention: ContentionConfig{Policy: ContentionSuspend, SessionThreads: 2}},
+		tasks:    []*TranscodeTask{manual, first, second},
+		eventBus: event.New(),
+	}
+
+	service.sessions = []*PlaybackSession{{ID: uuid.New()}}
+	service.suspendForContention()
+	assert.Equal(t, SUSPENDED, second.Status(), "most recently queued background task is suspended first")
+	assert.Equal(t, WORKING, first.Status())
+
+	service.sessions = append(service.sessions, &PlaybackSession{ID: uuid.New()})
+	service.suspendForContention()
+	assert.Equal(t, SUSPENDED, first.Status())
+	assert.Equal(t, WORKING, manual.Status(), "manually requested tasks are never suspended")
+
+	service.sessions = service.sessions[:1]
+	service.relieveContention()
+	assert.Equal(t, WORKING, first.Status(), "tasks are resumed in the reverse order they were suspended")
+	assert.Equal(t, SUSPENDED, second.Status())
+
+	service.sessions = nil
+	service.relieveContention()
+	assert.Equal(t, WORKING, second.Status())
+	assert.Empty(t, service.contentionSuspended)
+}
+
+type playbackDataStore struct {
+	DataStore
+	quota *quota.Quota
+}
+
+func (store *playbackDataStore) GetMedia(mediaID uuid.UUID) *media.Container { return newTestMedia() }
+
+func (store *playbackDataStore) GetUserQuota(userID uuid.UUID) (*quota.Quota, error) {
+	return store.quota, nil
+}
+
+func (store *playbackDataStore) CountUserTranscodeRequests(userID uuid.UUID) (int, error) {
+	return 0, nil
+}
+
+func (store *playbackDataStore) GetUserPreferences(userID uuid.UUID) (*preferences.Preferences, error) {
+	return preferences.Default(userID), nil
+}
+
+func Test_StartPlayback_EnforcesSessionQuota(t *testing.T) {
+	userID, otherUserID := uuid.New(), uuid.New()
+	limit := 2
+	service := &transcodeService{
+		Mutex:     &sync.Mutex{},
+		config:    &Config{MaximumThreadConsumption: 6, Contention: ContentionConfig{Policy: ContentionNone, SessionThreads: 2}},
+		dataStore: &playbackDataStore{quota: &quota.Quota{MaxConcurrentSessions: &limit}},
+		eventBus:  event.New(),
+	}
+
+	first, err := service.StartPlayback(userID, uuid.New(), nil)
+	assert.NoError(t, err)
+	_, err = service.StartPlayback(userID, uuid.New(), nil)
+	assert.NoError(t, err)
+
+	_, err = service.StartPlayback(userID, uuid.New(), nil)
+	assert.ErrorIs(t, err, quota.ErrSessionLimitReached)
+	_, err = service.StartPlayback(otherUserID, uuid.New(), nil)
+	assert.NoError(t, err, "sessions of other users do not count towards the quota")
+
+	usage, err := service.Quota(userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, usage.ActiveSessions)
+
+	assert.NoError(t, service.EndPlayback(userID, first.ID))
+	_, err = service.StartPlayback(userID, uuid.New(), nil)
+	assert.NoError(t, err, "ending a session frees the quota")
+}
+
+func Test_PlaybackSession_AppliesPreferences(t *testing.T) {
+	language, subtitleLanguage, maxBitrate := "jpn", "eng", 8000
+	source := &ffmpeg.PlaybackSource{
+		Audio:       []ffmpeg.AudioStream{{Index: 1, Language: "eng", Default: true}, {Index: 2, Language: "jpn"}},
+		Subtitles:   []ffmpeg.SubtitleStream{{Index: 3, Language: "eng"}},
+		BitrateKbps: 12000,
+	}
+
+	session := &PlaybackSession{Preferences: &preferences.Preferences{AudioLanguage: &language, SubtitleLanguage: &subtitleLanguage, MaxBitrateKbps: &maxBitrate}}
+	session.applyPreferences(source)
+	assert.False(t, session.DirectPlay, "source exceeds the maximum bitrate of the user")
+	assert.Equal(t, 2, *session.AudioStreamIndex)
+	assert.Equal(t, 3, *session.SubtitleStreamIndex)
+
+	session = &PlaybackSession{Preferences: preferences.Default(uuid.New())}
+	session.applyPreferences(source)
+	assert.True(t, session.DirectPlay)
+	assert.Nil(t, session.AudioStreamIndex, "no stream is selected without a preference")
+	assert.Nil(t, session.SubtitleStreamIndex)
+
+	session = &PlaybackSession{Preferences: &preferences.Preferences{MaxBitrateKbps: &maxBitrate}}
+	session.applyPreferences(nil)
+	assert.True(t, session.DirectPlay, "sources which cannot be probed may be played directly")
+}
@@ -0,0 +1,46 @@
+package transcode
+
+import (
+	"context"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// runWorkflowHooks executes the hooks of the workflow which queued the completed task provided (if
+// any), in order, if hooks are enabled (see HooksConfig). The latest version of the workflow is used,
+// so that changes made to the hooks while the task was queued are respected. Hooks are executed in
+// the background, and the outcome (and captured output) of each hook is logged against the task.
+// A hook failing does not prevent the subsequent hooks from being executed.
+func (service *transcodeService) runWorkflowHooks(task *TranscodeTask) {
+	if task.workflowID == nil {
+		return
+	}
+
+	service.Lock()
+	config := service.config.Hooks
+	service.Unlock()
+
+	wf := service.dataStore.GetWorkflow(*task.workflowID)
+	if wf == nil || len(wf.Hooks) == 0 {
+		return
+	}
+	if !config.Enabled {
+		task.log.Debugf("Workflow %s has %d hook(s), but hooks are disabled. No hooks will be executed for task %s\n", wf.ID, len(wf.Hooks), task)
+		return
+	}
+
+	env := wf.HookEnvironment(task.media, sourcePath(task.media, task.version), task.target, task.id, task.OutputPath())
+	go func() {
+		for k, hook := range wf.Hooks {
+			task.log.Infof("Executing hook %d/%d (%s) of workflow %s for task %s\n", k+1, len(wf.Hooks), hook, wf.ID, task)
+
+			result := hook.Run(context.Background(), env, config.DefaultTimeout)
+			if result.Err != nil {
+				task.log.Emit(logger.WARNING, "Hook %d/%d (%s) of workflow %s failed after %s (exit code %d): %v. Output:\n%s\n", k+1, len(wf.Hooks), hook, wf.ID, result.Duration, result.ExitCode, result.Err, result.Output)
+				continue
+			}
+
+			task.log.Emit(logger.SUCCESS, "Hook %d/%d (%s) of workflow %s completed in %s. Output:\n%s\n", k+1, len(wf.Hooks), hook, wf.ID, result.Duration, result.Output)
+		}
+	}()
+}
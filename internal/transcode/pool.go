@@ -0,0 +1,119 @@
+package transcode
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hbomb79/Thea/internal/disk"
+)
+
+// DefaultPoolName is the name of the storage pool used when no storage
+// pools are configured, which writes to the default output directory.
+const DefaultPoolName = "default"
+
+const bytesPerMegabyte = 1024 * 1024
+
+// StoragePool is a named location (typically a mount point) which the outputs of transcode tasks
+// can be written to. When a task is started, the pool with the highest priority which has more
+// than it's minimum free space available is selected. Where several pools share the highest
+// priority, the pool with the most space available is selected.
+type StoragePool struct {
+	Name               string `toml:"name"`
+	Path               string `toml:"path"`
+	Priority           int    `toml:"priority"`
+	MinimumFreeSpaceMB uint64 `toml:"minimum_free_space_mb"`
+}
+
+// GetStoragePools returns the configured storage pools, ordered by descending priority. If no
+// pools are configured, a single pool for the default output directory is returned, which uses
+// the configured minimum free space.
+func (config *Config) GetStoragePools() []StoragePool {
+	if len(config.StoragePools) == 0 {
+		return []StoragePool{{Name: DefaultPoolName, Path: config.OutputPath, MinimumFreeSpaceMB: config.MinimumFreeSpaceMB}}
+	}
+
+	pools := slices.Clone(config.StoragePools)
+	slices.SortStableFunc(pools, func(a, b StoragePool) int { return b.Priority - a.Priority })
+	return pools
+}
+
+// GetStoragePool returns the storage pool with the name provided, or nil if no such pool exists.
+func (config *Config) GetStoragePool(name string) *StoragePool {
+	for _, pool := range config.GetStoragePools() {
+		if pool.Name == name {
+			return &pool
+		}
+	}
+
+	return nil
+}
+
+// ValidateStoragePools returns an error if any of the configured storage pools are
+// missing a name or path, or if multiple pools share the same name.
+func (config *Config) ValidateStoragePools() error {
+	errs := make([]error, 0)
+	seen := make(map[string]struct{}, len(config.StoragePools))
+	for k, pool := range config.StoragePools {
+		if pool.Name == "" {
+			errs = append(errs, fmt.Errorf("storage_pools[%d]: name is required", k))
+		} else if _, ok := seen[pool.Name]; ok {
+			errs = append(errs, fmt.Errorf("storage_pools[%d]: name '%s' is used by another pool", k, pool.Name))
+		}
+		if pool.Path == "" {
+			errs = append(errs, fmt.Errorf("storage_pools[%d]: path is required", k))
+		}
+
+		seen[pool.Name] = struct{}{}
+	}
+
+	return errors.Join(errs...)
+}
+
+// selectStoragePool returns the pool which the output of the next transcode should be written
+// to (see StoragePool). If none of the pools have more than their minimum free space
+// available, an InsufficientSpaceError for the highest priority pool is returned. Pools
+// which cannot be inspected are skipped, however if no pools can be inspected
+// then the error encountered is returned.
+func selectStoragePool(pools []StoragePool) (*StoragePool, error) {
+	var spaceErr *disk.InsufficientSpaceError
+	var usageErr error
+	for start := 0; start < len(pools); {
+		var selected *StoragePool
+		var selectedAvailable uint64
+
+		end := start
+		for ; end < len(pools) && pools[end].Priority == pools[start].Priority; end++ {
+			pool := &pools[end]
+			usage, err := disk.GetUsage(pool.Path)
+			if err != nil {
+				usageErr = err
+				continue
+			}
+
+			required := pool.MinimumFreeSpaceMB * bytesPerMegabyte
+			if usage.Available < required {
+				if spaceErr == nil {
+					spaceErr = &disk.InsufficientSpaceError{Path: pool.Path, Available: usage.Available, Required: required}
+				}
+				continue
+			}
+
+			if selected == nil || usage.Available > selectedAvailable {
+				selected = pool
+				selectedAvailable = usage.Available
+			}
+		}
+
+		if selected != nil {
+			return selected, nil
+		}
+		start = end
+	}
+
+	if spaceErr != nil {
+		return nil, spaceErr
+	}
+
+	return nil, usageErr
+}
@@ -0,0 +1,45 @@
+package transcode_test
+
+import (
+	"testing"
+
+	"github.com/hbomb79/Thea/internal/transcode"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStoragePools_DefaultsToOutputPath(t *testing.T) {
+	config := transcode.Config{OutputPath: "/output", MinimumFreeSpaceMB: 512}
+
+	pools := config.GetStoragePools()
+	assert.Equal(t, []transcode.StoragePool{{Name: transcode.DefaultPoolName, Path: "/output", MinimumFreeSpaceMB: 512}}, pools)
+}
+
+func TestGetStoragePools_OrderedByPriority(t *testing.T) {
+	config := transcode.Config{
+		OutputPath: "/output",
+		StoragePools: []transcode.StoragePool{
+			{Name: "slow", Path: "/mnt/slow", Priority: 1},
+			{Name: "fast", Path: "/mnt/fast", Priority: 10},
+			{Name: "fast-2", Path: "/mnt/fast-2", Priority: 10},
+		},
+	}
+
+	names := make([]string, 0)
+	for _, pool := range config.GetStoragePools() {
+		names = append(names, pool.Name)
+	}
+	assert.Equal(t, []string{"fast", "fast-2", "slow"}, names)
+	assert.Equal(t, "/mnt/slow", config.GetStoragePool("slow").Path)
+	assert.Nil(t, config.GetStoragePool(transcode.DefaultPoolName))
+}
+
+func TestValidateStoragePools(t *testing.T) {
+	valid := transcode.Config{StoragePools: []transcode.StoragePool{{Name: "a", Path: "/a"}, {Name: "b", Path: "/b"}}}
+	assert.NoError(t, valid.ValidateStoragePools())
+
+	invalid := transcode.Config{StoragePools: []transcode.StoragePool{{Name: "a", Path: "/a"}, {Name: "a"}, {Path: "/c"}}}
+	err := invalid.ValidateStoragePools()
+	assert.ErrorContains(t, err, "storage_pools[1]: name 'a' is used by another pool")
+	assert.ErrorContains(t, err, "storage_pools[1]: path is required")
+	assert.ErrorContains(t, err, "storage_pools[2]: name is required")
+}
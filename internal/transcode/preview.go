@@ -0,0 +1,138 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+const (
+	// previewLength is the maximum length of the sample transcoded for a preview.
+	previewLength = 30 * time.Second
+
+	// previewExpiryInterval is how often expired previews are removed.
+	previewExpiryInterval = time.Minute
+
+	// previewDirectoryName is the name of the directory (inside of the output
+	// directory) which previews are written to.
+	previewDirectoryName = ".previews"
+)
+
+var (
+	ErrPreviewMediaNotFound  = errors.New("media for preview not found")
+	ErrPreviewTargetNotFound = errors.New("target for preview not found")
+)
+
+// Preview is a short sample of a media transcoded using a target, so that the
+// output of the target can be evaluated before committing to a full transcode.
+// Previews are not persisted, and are deleted once they expire.
+type Preview struct {
+	ID        uuid.UUID
+	MediaID   uuid.UUID
+	TargetID  uuid.UUID
+	Path      string
+	ExpiresAt time.Time
+}
+
+// CreatePreview transcodes a sample (from the middle) of the media using the target specified,
+// blocking until the sample has been transcoded. Only one preview is transcoded at a time, and
+// previews do not consume the thread budget of the queue, as they are short-lived.
+// If the context provided is cancelled, the preview is abandoned.
+func (service *transcodeService) CreatePreview(ctx context.Context, mediaID uuid.UUID, targetID uuid.UUID) (*Preview, error) {
+	m := service.dataStore.GetMedia(mediaID)
+	if m == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPreviewMediaNotFound, mediaID)
+	}
+
+	target := service.dataStore.GetTarget(targetID)
+	if target == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPreviewTargetNotFound, targetID)
+	}
+
+	select {
+	case service.previewSlot <- struct{}{}:
+		defer func() { <-service.previewSlot }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	duration, err := ffmpeg.ProbeDuration(m.Source(), service.config.FfprobeBinaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine duration of media %s: %w", mediaID, err)
+	}
+
+	length := min(previewLength, duration)
+	offset := max(0, (duration-length)/2)
+
+	preview := &Preview{ID: uuid.New(), MediaID: mediaID, TargetID: targetID}
+	preview.Path = filepath.Join(service.previewDirectory(), fmt.Sprintf("%s.%s", preview.ID, target.Ext))
+
+	log.Emit(logger.NEW, "Transcoding %s preview of media %s (from %s) using target %s\n", length, mediaID, offset, target)
+	audio := ffmpeg.SelectTargetAudio(m.Source(), service.config.FfprobeBinaryPath, target)
+	options := ffmpeg.WithAudioStream(target.FfmpegOptions, audio)
+	if target.RemuxOnly {
+		options = ffmpeg.ProbeRemuxOptions(m.Source(), service.config.FfprobeBinaryPath, target.Ext, audio)
+	}
+
+	cmd := ffmpeg.NewSampleCmd(m.Source(), preview.Path, service.ffmpegConfig(), offset, length)
+	if err := cmd.Run(ctx, options, func(*ffmpeg.Progress) {}); err != nil {
+		_ = os.Remove(preview.Path)
+		return nil, fmt.Errorf("%w: %w. FFmpeg output:\n%s", ErrFfmpegProblem, err, cmd.OutputTail())
+	}
+
+	service.Lock()
+	defer service.Unlock()
+
+	preview.ExpiresAt = time.Now().Add(service.config.PreviewTTL)
+	service.previews = append(service.previews, preview)
+	return preview, nil
+}
+
+// Preview returns the preview with the ID provided, or nil if no such preview
+// exists (or if it has expired).
+func (service *transcodeService) Preview(id uuid.UUID) *Preview {
+	service.Lock()
+	defer service.Unlock()
+
+	for _, preview := range service.previews {
+		if preview.ID == id && time.Now().Before(preview.ExpiresAt) {
+			return preview
+		}
+	}
+
+	return nil
+}
+
+// removeExpiredPreviews deletes any previews which have expired. If all is true,
+// then every preview is deleted regardless of whether it has expired.
+func (service *transcodeService) removeExpiredPreviews(all bool) {
+	service.Lock()
+	defer service.Unlock()
+
+	now := time.Now()
+	remaining := make([]*Preview, 0, len(service.previews))
+	for _, preview := range service.previews {
+		if !all && now.Before(preview.ExpiresAt) {
+			remaining = append(remaining, preview)
+			continue
+		}
+
+		if err := os.Remove(preview.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("Failed to remove expired preview %s: %v\n", preview.Path, err)
+		}
+	}
+
+	service.previews = remaining
+}
+
+// previewDirectory returns the path of the directory which previews are written to.
+func (service *transcodeService) previewDirectory() string {
+	return filepath.Join(service.config.OutputPath, previewDirectoryName)
+}
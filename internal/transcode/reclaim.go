@@ -0,0 +1,113 @@
+package transcode
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const bytesPerGigabyte = 1024 * bytesPerMegabyte
+
+type (
+	// ReclaimConfig controls how the storage used by transcodes is reclaimed. Transcodes which have not
+	// been played for ArchiveAfter are moved to the ArchivePool (an empty pool disables archival), and
+	// once the transcodes use more than MaxStorageGB (zero disables eviction), transcodes which have
+	// not been played for MinimumIdle are deleted until the transcodes are within the limit. In both
+	// cases, rarely watched transcodes are reclaimed first (see ReclaimOrder).
+	ReclaimConfig struct {
+		Interval     time.Duration `toml:"interval" env:"FORMAT_RECLAIM_INTERVAL" env-default:"6h"`
+		ArchivePool  string        `toml:"archive_pool" env:"FORMAT_RECLAIM_ARCHIVE_POOL"`
+		ArchiveAfter time.Duration `toml:"archive_after" env:"FORMAT_RECLAIM_ARCHIVE_AFTER" env-default:"720h"`
+		MaxStorageGB uint64        `toml:"max_storage_gb" env:"FORMAT_RECLAIM_MAX_STORAGE_GB" env-default:"0"`
+		MinimumIdle  time.Duration `toml:"minimum_idle" env:"FORMAT_RECLAIM_MINIMUM_IDLE" env-default:"168h"`
+	}
+
+	// ReclaimCandidate is a transcode which may be archived or evicted, along with how often it has been
+	// streamed. LastUsedAt is when the transcode was last streamed, or when it was saved if it has never
+	// been. The Size (in bytes) of the output file is populated by the caller before selection.
+	ReclaimCandidate struct {
+		ID          uuid.UUID `db:"id"`
+		MediaID     uuid.UUID `db:"media_id"`
+		TargetID    uuid.UUID `db:"transcode_target_id"`
+		Path        string    `db:"path"`
+		Pool        string    `db:"pool"`
+		Starts      int       `db:"starts"`
+		Completions int       `db:"completions"`
+		LastUsedAt  time.Time `db:"last_used_at"`
+		Size        int64     `db:"-"`
+	}
+)
+
+// ReclaimOrder orders the candidates which should be reclaimed first before others: transcodes
+// which have been played the fewest times come first, with the least recently used first among
+// transcodes played equally often.
+func ReclaimOrder(a, b *ReclaimCandidate) int {
+	if a.Starts != b.Starts {
+		return cmp.Compare(a.Starts, b.Starts)
+	}
+
+	return a.LastUsedAt.Compare(b.LastUsedAt)
+}
+
+// ValidateReclaim returns an error if the archive pool of the reclaim config is not a configured storage pool.
+func (config *Config) ValidateReclaim() error {
+	if config.Reclaim.ArchivePool != "" && config.GetStoragePool(config.Reclaim.ArchivePool) == nil {
+		return fmt.Errorf("archive_pool: no storage pool named '%s' is configured", config.Reclaim.ArchivePool)
+	}
+
+	return nil
+}
+
+// SelectArchivals returns the candidates which should be moved to the archive pool, being those
+// which have not been used for ArchiveAfter and are not in the archive pool already. The order
+// of the candidates provided is retained.
+func (config ReclaimConfig) SelectArchivals(candidates []*ReclaimCandidate, now time.Time) []*ReclaimCandidate {
+	selected := make([]*ReclaimCandidate, 0)
+	if config.ArchivePool == "" {
+		return selected
+	}
+
+	for _, candidate := range candidates {
+		if candidate.Pool == config.ArchivePool {
+			continue
+		}
+		if now.Sub(candidate.LastUsedAt) >= config.ArchiveAfter {
+			selected = append(selected, candidate)
+		}
+	}
+
+	return selected
+}
+
+// SelectEvictions returns the candidates which should be deleted so that the total size of the
+// candidates is within MaxStorageGB. Candidates are selected in the order provided (see ReclaimOrder),
+// skipping those which have been used within the MinimumIdle. If the candidates cannot be brought
+// within the limit, all eligible candidates are selected.
+func (config ReclaimConfig) SelectEvictions(candidates []*ReclaimCandidate, now time.Time) []*ReclaimCandidate {
+	selected := make([]*ReclaimCandidate, 0)
+	if config.MaxStorageGB == 0 {
+		return selected
+	}
+
+	var total int64
+	for _, candidate := range candidates {
+		total += candidate.Size
+	}
+
+	limit := int64(config.MaxStorageGB * bytesPerGigabyte)
+	for _, candidate := range candidates {
+		if total <= limit {
+			break
+		}
+		if now.Sub(candidate.LastUsedAt) < config.MinimumIdle {
+			continue
+		}
+
+		selected = append(selected, candidate)
+		total -= candidate.Size
+	}
+
+	return selected
+}
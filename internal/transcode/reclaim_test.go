@@ -0,0 +1,67 @@
+package transcode
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReclaimCandidate(starts int, idle time.Duration, sizeGB int64, pool string, now time.Time) *ReclaimCandidate {
+	return &ReclaimCandidate{ID: uuid.New(), Starts: starts, LastUsedAt: now.Add(-idle), Size: sizeGB * bytesPerGigabyte, Pool: pool}
+}
+
+func Test_ReclaimOrder_PrefersRarelyWatched(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	popular := newReclaimCandidate(10, 90*24*time.Hour, 1, DefaultPoolName, now)
+	recent := newReclaimCandidate(1, time.Hour, 1, DefaultPoolName, now)
+	stale := newReclaimCandidate(1, 30*24*time.Hour, 1, DefaultPoolName, now)
+	unwatched := newReclaimCandidate(0, time.Hour, 1, DefaultPoolName, now)
+
+	candidates := []*ReclaimCandidate{popular, recent, stale, unwatched}
+	slices.SortStableFunc(candidates, ReclaimOrder)
+	assert.Equal(t, []*ReclaimCandidate{unwatched, stale, recent, popular}, candidates,
+		"fewest plays first, least recently used first among equally played transcodes")
+}
+
+func Test_ReclaimConfig_SelectArchivals(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	idle := newReclaimCandidate(0, 60*24*time.Hour, 1, DefaultPoolName, now)
+	archived := newReclaimCandidate(0, 60*24*time.Hour, 1, "archive", now)
+	recent := newReclaimCandidate(0, 24*time.Hour, 1, DefaultPoolName, now)
+	candidates := []*ReclaimCandidate{idle, archived, recent}
+
+	config := ReclaimConfig{ArchivePool: "archive", ArchiveAfter: 30 * 24 * time.Hour}
+	assert.Equal(t, []*ReclaimCandidate{idle}, config.SelectArchivals(candidates, now))
+
+	config.ArchivePool = ""
+	assert.Empty(t, config.SelectArchivals(candidates, now), "archival is disabled without an archive pool")
+}
+
+func Test_ReclaimConfig_SelectEvictions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	week := 7 * 24 * time.Hour
+	unwatched := newReclaimCandidate(0, 2*week, 4, DefaultPoolName, now)
+	fresh := newReclaimCandidate(0, time.Hour, 4, DefaultPoolName, now)
+	stale := newReclaimCandidate(2, 3*week, 4, DefaultPoolName, now)
+	popular := newReclaimCandidate(9, 2*week, 4, DefaultPoolName, now)
+	candidates := []*ReclaimCandidate{unwatched, fresh, stale, popular}
+
+	config := ReclaimConfig{MaxStorageGB: 8, MinimumIdle: week}
+	assert.Equal(t, []*ReclaimCandidate{unwatched, stale}, config.SelectEvictions(candidates, now),
+		"candidates used within the minimum idle are skipped")
+
+	config.MaxStorageGB = 16
+	assert.Empty(t, config.SelectEvictions(candidates, now), "nothing is evicted within the limit")
+
+	config.MaxStorageGB = 0
+	assert.Empty(t, config.SelectEvictions(candidates, now), "eviction is disabled without a limit")
+}
@@ -0,0 +1,163 @@
+package transcode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+const (
+	// recoveryDirectoryName is the name of the directory (inside of the output
+	// directory) which recovery records are written to. The directory is hidden
+	// so that it is ignored by the consistency checks.
+	recoveryDirectoryName = ".recovery"
+
+	// saveAttempts is the number of times saving a completed transcode is attempted before
+	// a recovery record is written instead. The delay between attempts doubles each time.
+	saveAttempts         = 4
+	saveRetryBaseBackoff = 500 * time.Millisecond
+)
+
+// RecoveredTranscode describes a completed transcode which could not be saved to the
+// database. The record is written to disk, and replayed when Thea next starts, so that
+// the output of the transcode is not orphaned.
+type RecoveredTranscode struct {
+	ID          uuid.UUID  `json:"id"`
+	MediaID     uuid.UUID  `json:"media_id"`
+	TargetID    uuid.UUID  `json:"target_id"`
+	VersionID   *uuid.UUID `json:"version_id,omitempty"`
+	Path        string     `json:"path"`
+	Pool        string     `json:"pool"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	ConcludedAt time.Time  `json:"concluded_at"`
+	Output      string     `json:"output"`
+}
+
+// saveCompletedTranscode saves the completed task to the data store, retrying with an
+// exponential backoff if the save fails. If all the attempts fail, a recovery record
+// is written to disk so that the transcode can be saved when Thea next starts.
+func (service *transcodeService) saveCompletedTranscode(task *TranscodeTask) error {
+	var err error
+	backoff := saveRetryBaseBackoff
+	for attempt := 1; attempt <= saveAttempts; attempt++ {
+		if err = service.dataStore.SaveTranscode(task); err == nil {
+			return nil
+		}
+
+		task.log.Warnf("Attempt %d/%d to save transcode %s failed: %v\n", attempt, saveAttempts, task, err)
+		if attempt < saveAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	if recoveryErr := service.writeRecoveryRecord(task); recoveryErr != nil {
+		return fmt.Errorf("%w (and failed to write recovery record: %v)", err, recoveryErr)
+	}
+
+	task.log.Warnf("Transcode %s could not be saved, a recovery record has been written and will be replayed when Thea next starts\n", task)
+	return err
+}
+
+func (service *transcodeService) writeRecoveryRecord(task *TranscodeTask) error {
+	record := RecoveredTranscode{
+		ID:          task.id,
+		MediaID:     task.media.ID(),
+		TargetID:    task.target.ID,
+		VersionID:   task.VersionID(),
+		Path:        task.OutputPath(),
+		Pool:        task.Pool(),
+		StartedAt:   task.StartedAt(),
+		ConcludedAt: time.Now(),
+		Output:      task.Output(),
+	}
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recovery record: %w", err)
+	}
+
+	if err := os.MkdirAll(service.recoveryDirectory(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create recovery directory: %w", err)
+	}
+
+	// Write to a temporary file first, so that a partially written record is never replayed
+	path := service.recoveryRecordPath(task.id)
+	if err := os.WriteFile(path+".tmp", content, 0o600); err != nil {
+		return fmt.Errorf("failed to write recovery record: %w", err)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// replayRecoveryRecords saves any completed transcodes which could not be saved the last time
+// Thea was running (see saveCompletedTranscode). Records are only removed once saved, so records
+// which fail to replay will be attempted again when Thea next starts.
+func (service *transcodeService) replayRecoveryRecords() {
+	entries, err := os.ReadDir(service.recoveryDirectory())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Errorf("Failed to read transcode recovery records: %v\n", err)
+		}
+		return
+	}
+
+	replayed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(service.recoveryDirectory(), entry.Name())
+		if err := service.replayRecoveryRecord(path); err != nil {
+			log.Errorf("Failed to replay transcode recovery record %s: %v\n", path, err)
+			continue
+		}
+
+		replayed++
+	}
+
+	if replayed > 0 {
+		log.Emit(logger.NEW, "Saved %d completed transcode(s) recovered from a previous run\n", replayed)
+	}
+}
+
+func (service *transcodeService) replayRecoveryRecord(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read record: %w", err)
+	}
+
+	var record RecoveredTranscode
+	if err := json.Unmarshal(content, &record); err != nil {
+		return fmt.Errorf("failed to unmarshal record: %w", err)
+	}
+
+	if _, err := os.Stat(record.Path); err != nil {
+		return fmt.Errorf("output of transcode %s is not available: %w", record.ID, err)
+	}
+
+	if err := service.dataStore.SaveRecoveredTranscode(&record); err != nil {
+		return err
+	}
+
+	service.eventBus.Dispatch(event.TranscodeCompleteEvent, record.ID)
+	return os.Remove(path)
+}
+
+// recoveryDirectory returns the path of the directory which recovery records are written to.
+func (service *transcodeService) recoveryDirectory() string {
+	return filepath.Join(service.config.OutputPath, recoveryDirectoryName)
+}
+
+func (service *transcodeService) recoveryRecordPath(taskID uuid.UUID) string {
+	return filepath.Join(service.recoveryDirectory(), taskID.String()+".json")
+}
@@ -0,0 +1,72 @@
+package transcode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/event"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+type recoveryDataStore struct {
+	DataStore
+	recovered []*RecoveredTranscode
+}
+
+func (store *recoveryDataStore) SaveRecoveredTranscode(record *RecoveredTranscode) error {
+	store.recovered = append(store.recovered, record)
+	return nil
+}
+
+type recoveryEventBus struct {
+	event.EventCoordinator
+	dispatched []event.Payload
+}
+
+func (bus *recoveryEventBus) Dispatch(_ event.Event, payload event.Payload) {
+	bus.dispatched = append(bus.dispatched, payload)
+}
+
+func Test_RecoveryRecordsAreReplayed(t *testing.T) {
+	outputDir := t.TempDir()
+	outputPath := filepath.Join(outputDir, "output.mp4")
+	assert.NoError(t, os.WriteFile(outputPath, []byte("transcoded"), 0o600))
+
+	store, bus := &recoveryDataStore{}, &recoveryEventBus{}
+	service := &transcodeService{config: &Config{OutputPath: outputDir}, dataStore: store, eventBus: bus}
+
+	task := newTestTask(newTestMedia(), COMPLETE)
+	task.target = &ffmpeg.Target{ID: uuid.New()}
+	task.outputPath = outputPath
+	assert.NoError(t, service.writeRecoveryRecord(task))
+
+	service.replayRecoveryRecords()
+	assert.Len(t, store.recovered, 1)
+	assert.Equal(t, task.id, store.recovered[0].ID)
+	assert.Equal(t, task.media.ID(), store.recovered[0].MediaID)
+	assert.Equal(t, task.target.ID, store.recovered[0].TargetID)
+	assert.Equal(t, outputPath, store.recovered[0].Path)
+	assert.Equal(t, []event.Payload{task.id}, bus.dispatched)
+
+	// Replayed records are removed, so are not replayed again
+	service.replayRecoveryRecords()
+	assert.Len(t, store.recovered, 1)
+}
+
+func Test_RecoveryRecordsAreRetainedIfOutputMissing(t *testing.T) {
+	outputDir := t.TempDir()
+	store := &recoveryDataStore{}
+	service := &transcodeService{config: &Config{OutputPath: outputDir}, dataStore: store, eventBus: &recoveryEventBus{}}
+
+	task := newTestTask(newTestMedia(), COMPLETE)
+	task.target = &ffmpeg.Target{ID: uuid.New()}
+	task.outputPath = filepath.Join(outputDir, "missing.mp4")
+	assert.NoError(t, service.writeRecoveryRecord(task))
+
+	service.replayRecoveryRecords()
+	assert.Empty(t, store.recovered)
+	assert.FileExists(t, service.recoveryRecordPath(task.id))
+}
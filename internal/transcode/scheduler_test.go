@@ -0,0 +1,60 @@
+package transcode
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMedia() *media.Container {
+	return &media.Container{Type: media.MovieContainerType, Movie: &media.Movie{Model: media.Model{ID: uuid.New()}}}
+}
+
+func newTestTask(m *media.Container, status TranscodeTaskStatus) *TranscodeTask {
+	return &TranscodeTask{id: uuid.New(), media: m, status: status}
+}
+
+func newTestRequestedTask(m *media.Container, status TranscodeTaskStatus, userID uuid.UUID) *TranscodeTask {
+	return &TranscodeTask{id: uuid.New(), media: m, status: status, requestedBy: &userID}
+}
+
+func Test_FairQueue_InterleavesMedia(t *testing.T) {
+	t.Parallel()
+	movieA, movieB, movieC := newTestMedia(), newTestMedia(), newTestMedia()
+	a1, a2, a3 := newTestTask(movieA, WAITING), newTestTask(movieA, WAITING), newTestTask(movieA, WAITING)
+	b1, b2 := newTestTask(movieB, WAITING), newTestTask(movieB, WAITING)
+	c1 := newTestTask(movieC, WAITING)
+
+	service := &transcodeService{tasks: []*TranscodeTask{a1, a2, a3, b1, b2, c1}}
+	assert.Equal(t, []*TranscodeTask{a1, b1, c1, a2, b2, a3}, service.fairQueue())
+}
+
+func Test_FairQueue_PrefersMediaWithFewestRunningTasks(t *testing.T) {
+	t.Parallel()
+	movieA, movieB := newTestMedia(), newTestMedia()
+	running := newTestTask(movieA, WORKING)
+	a1 := newTestTask(movieA, WAITING)
+	b1, b2 := newTestTask(movieB, WAITING), newTestTask(movieB, WAITING)
+
+	service := &transcodeService{tasks: []*TranscodeTask{running, a1, b1, b2, newTestTask(movieB, COMPLETE)}}
+	assert.Equal(t, []*TranscodeTask{b1, a1, b2}, service.fairQueue())
+}
+
+func Test_FairQueue_SharesPoolAcrossUsers(t *testing.T) {
+	t.Parallel()
+
+	userA, userB := uuid.New(), uuid.New()
+	movieA1, movieA2, movieA3, movieB := newTestMedia(), newTestMedia(), newTestMedia(), newTestMedia()
+
+	// User A already holds two tasks of the pool, using a different media for each of their
+	// tasks. User B should be given the next two turns, despite queueing after user A.
+	runningA1, runningA2 := newTestRequestedTask(movieA1, WORKING, userA), newTestRequestedTask(movieA2, SUSPENDED, userA)
+	a3 := newTestRequestedTask(movieA3, WAITING, userA)
+	b1, b2 := newTestRequestedTask(movieB, WAITING, userB), newTestRequestedTask(movieB, WAITING, userB)
+	b3 := newTestRequestedTask(movieB, WAITING, userB)
+
+	service := &transcodeService{tasks: []*TranscodeTask{runningA1, runningA2, a3, b1, b2, b3}}
+	assert.Equal(t, []*TranscodeTask{b1, b2, a3, b3}, service.fairQueue())
+}
@@ -0,0 +1,79 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+// sequence is the remainder of the targets of a sequential workflow which are yet to be queued for a
+// media. The next target is queued once the task for the prior target has left the queue (whether it
+// completed or was cancelled). Sequences are not persisted, and so the remaining targets of a sequence
+// are not queued if Thea is restarted before the sequence finishes.
+type sequence struct {
+	ctx        context.Context
+	media      *media.Container
+	workflowID uuid.UUID
+	targets    []*ffmpeg.Target
+}
+
+// queueSequence queues the first of the targets (of the workflow with the ID provided) for the media which
+// results in a task. Targets for which no task is created (e.g. because a transcode already exists, or the
+// source satisfies the target) are skipped. The remaining targets are queued once the task created has left the queue.
+func (service *transcodeService) queueSequence(ctx context.Context, m *media.Container, workflowID uuid.UUID, targets []*ffmpeg.Target) {
+	for k, target := range targets {
+		log.WithContext(ctx).Infof("Starting task for media %s target %s (%d of sequence)\n", m.ID(), target.ID, k+1)
+		task, err := service.spawnFfmpegTarget(ctx, m, nil, target, &workflowID, nil, false)
+		if errors.Is(err, ErrDraining) {
+			return
+		} else if err != nil {
+			if !errors.Is(err, ErrPassthrough) {
+				log.Emit(logger.ERROR, "failed to spawn ffmpeg target %s for media %s: %v\n", target, m.ID(), err)
+			}
+
+			continue
+		}
+
+		if remaining := targets[k+1:]; len(remaining) > 0 {
+			service.Lock()
+			service.sequences[task.id] = &sequence{ctx: ctx, media: m, workflowID: workflowID, targets: remaining}
+			if service.Task(task.id) == nil {
+				// The task left the queue before the sequence was recorded
+				service.advanceSequence(task.id)
+			}
+			service.Unlock()
+		}
+
+		return
+	}
+}
+
+// advanceSequence queues the next target of the sequence awaiting the task with the ID provided, if any. The
+// target is queued asynchronously, as the task may leave the queue while the service mutex is held.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) advanceSequence(taskID uuid.UUID) {
+	seq, ok := service.sequences[taskID]
+	if !ok {
+		return
+	}
+
+	delete(service.sequences, taskID)
+	go service.queueSequence(seq.ctx, seq.media, seq.workflowID, seq.targets)
+}
+
+// dropSequences discards the sequences of the media provided, so that
+// no further targets are queued for it (e.g. because it's been deleted).
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) dropSequences(mediaID uuid.UUID) {
+	for taskID, seq := range service.sequences {
+		if seq.media.ID() == mediaID {
+			delete(service.sequences, taskID)
+		}
+	}
+}
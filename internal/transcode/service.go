@@ -4,12 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/disk"
 	"github.com/hbomb79/Thea/internal/event"
 	"github.com/hbomb79/Thea/internal/ffmpeg"
 	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/preferences"
+	"github.com/hbomb79/Thea/internal/quota"
 	"github.com/hbomb79/Thea/internal/workflow"
 	"github.com/hbomb79/Thea/pkg/logger"
 )
@@ -18,15 +24,38 @@ var (
 	log = logger.Get("TranscodeServ")
 
 	ErrTaskNotFound = errors.New("no task found")
+	ErrDraining     = errors.New("transcode service is shutting down and is not accepting new tasks")
+
+	// ErrPassthrough is returned when a task is not created because the source already satisfies
+	// the target (see Config.SkipCompliantSources). The source is instead recorded as the transcode.
+	ErrPassthrough = errors.New("source already satisfies the target, and has been recorded as the transcode without transcoding")
 )
 
+// diskSpaceRecheckInterval is how often the free space of the output volume is re-checked
+// while tasks are being held due to insufficient disk space.
+const diskSpaceRecheckInterval = time.Minute
+
 type (
 	DataStore interface {
 		SaveTranscode(task *TranscodeTask) error
+		SaveRecoveredTranscode(record *RecoveredTranscode) error
+		SaveTranscodeFailure(task *TranscodeTask) error
+		SaveTranscodeCancellation(task *TranscodeTask) error
+		SavePassthroughTranscode(transcode *Transcode) error
 		GetAllWorkflows() []*workflow.Workflow
+		GetWorkflow(workflowID uuid.UUID) *workflow.Workflow
+		ListMediaSourceFiles() ([]*media.SourceFile, error)
 		GetMedia(mediaID uuid.UUID) *media.Container
+		GetMedias(mediaIDs []uuid.UUID) ([]*media.Container, error)
+		GetMediaVersion(versionID uuid.UUID) (*media.Version, error)
 		GetTarget(targetID uuid.UUID) *ffmpeg.Target
-		GetForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID) (*Transcode, error)
+		GetForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) (*Transcode, error)
+		SaveTranscodeQueueSnapshot(tasks []QueuedTask) error
+		PopTranscodeQueueSnapshot() ([]QueuedTask, error)
+		GetUserQuota(userID uuid.UUID) (*quota.Quota, error)
+		CountUserTranscodeRequests(userID uuid.UUID) (int, error)
+		RecordUserTranscodeRequest(userID uuid.UUID, taskID uuid.UUID) error
+		GetUserPreferences(userID uuid.UUID) (*preferences.Preferences, error)
 	}
 
 	// transcodeService is Thea's solution to pre-transcoding of user media.
@@ -35,12 +64,46 @@ type (
 	//   - Manual transcode requests for ingested media
 	//   - Live-tracking and reporting of ongoing transcodes over the event bus
 	// 	 - Persistence of completed transcodes to the transcode store
+	//   - Draining of running transcodes, and snapshotting of the queue, on shutdown
 	transcodeService struct {
 		*sync.Mutex
 		taskWg          *sync.WaitGroup
 		config          *Config
 		tasks           []*TranscodeTask
 		consumedThreads int
+		draining        bool
+
+		// queuePaused prevents WAITING tasks from being started. Tasks which
+		// were suspended when the queue was paused are resumed alongside it.
+		queuePaused    bool
+		queueSuspended []uuid.UUID
+
+		// previews are the temporary samples created by CreatePreview, and previewSlot
+		// ensures only one preview is transcoded at a time.
+		previews    []*Preview
+		previewSlot chan struct{}
+
+		// batches are the batches created by ApplyWorkflow since Thea started.
+		batches []*Batch
+
+		// sequences are the sequential workflows awaiting the task with the ID
+		// of their key to leave the queue before queueing their next target.
+		sequences map[uuid.UUID]*sequence
+
+		// sessions are the ongoing playback sessions, and contentionSuspended are the IDs of
+		// the tasks suspended to make room for them, in the order they were suspended.
+		sessions            []*PlaybackSession
+		contentionSuspended []uuid.UUID
+
+		// lowDiskSpace is true while WAITING tasks are being held because the output
+		// volume has less space available than the configured reserve.
+		lowDiskSpace bool
+
+		// taskCtx is the context used by all running tasks. It is
+		// not derived from the context provided to Run, as tasks
+		// are allowed to finish while the service is draining.
+		taskCtx     context.Context
+		cancelTasks context.CancelFunc
 
 		eventBus  event.EventCoordinator
 		dataStore DataStore
@@ -59,13 +122,19 @@ func New(config Config, eventBus event.EventCoordinator, dataStore DataStore) (*
 
 	// Ensure maximum thread consumption is reasonable (>2)
 
+	taskCtx, cancelTasks := context.WithCancel(context.Background())
 	return &transcodeService{
 		Mutex:       &sync.Mutex{},
+		taskCtx:     taskCtx,
+		cancelTasks: cancelTasks,
 		taskWg:      &sync.WaitGroup{},
 		config:      &config,
 		tasks:       make([]*TranscodeTask, 0),
 		eventBus:    eventBus,
 		dataStore:   dataStore,
+		previews:    make([]*Preview, 0),
+		sequences:   make(map[uuid.UUID]*sequence),
+		previewSlot: make(chan struct{}, 1),
 		queueChange: make(chan bool, 128),
 		taskChange:  make(chan uuid.UUID, 128),
 	}, nil
@@ -74,43 +143,246 @@ func New(config Config, eventBus event.EventCoordinator, dataStore DataStore) (*
 // Run is the main entry point for this service. This method will block
 // until the provided context is cancelled.
 // Note: when context is cancelled this method will not immediately return as it
-// will wait for it's running transcode tasks to cancel.
+// will drain the running transcode tasks (see shutdown).
 func (service *transcodeService) Run(ctx context.Context) error {
 	eventChannel := make(event.HandlerChannel, 100)
 	service.eventBus.RegisterHandlerChannel(eventChannel, event.NewMediaEvent, event.DeleteMediaEvent)
 
+	// Recovered transcodes are saved before the queue is restored, so that
+	// restored tasks are not duplicates of the recovered transcodes
+	go func() {
+		service.replayRecoveryRecords()
+		service.restoreQueueSnapshot()
+	}()
+
+	// Previews are not persisted, so any left behind by a previous run are unreachable
+	if err := os.RemoveAll(service.previewDirectory()); err != nil {
+		log.Warnf("Failed to remove previews from previous run: %v\n", err)
+	}
+
+	diskRecheck := time.NewTicker(diskSpaceRecheckInterval)
+	defer diskRecheck.Stop()
+	previewExpiry := time.NewTicker(previewExpiryInterval)
+	defer previewExpiry.Stop()
+	sessionExpiry := time.NewTicker(sessionExpiryInterval)
+	defer sessionExpiry.Stop()
+
 	for {
 		select {
 		case <-service.queueChange:
-			service.startWaitingTasks(ctx)
+			service.startWaitingTasks()
+		case <-diskRecheck.C:
+			if service.isLowOnDiskSpace() {
+				service.startWaitingTasks()
+			}
+		case <-previewExpiry.C:
+			service.removeExpiredPreviews(false)
+		case <-sessionExpiry.C:
+			service.removeExpiredSessions()
 		case taskID := <-service.taskChange:
 			service.handleTaskUpdate(taskID)
 		case message := <-eventChannel:
-			//exhaustive:ignore
-			switch message.Event {
-			case event.NewMediaEvent:
-				if mediaID, ok := message.Payload.(uuid.UUID); ok {
-					log.Emit(logger.DEBUG, "newly ingested media with ID %s detected\n", mediaID)
-					service.createWorkflowTasksForMedia(mediaID)
-				} else {
-					log.Emit(logger.ERROR, "failed to extract UUID from %s event (payload %#v)\n", message.Event, message.Payload)
-				}
-			case event.DeleteMediaEvent:
-				if mediaID, ok := message.Payload.(uuid.UUID); ok {
-					log.Emit(logger.DEBUG, "media with ID %s deleted, cancelling any ongoing transcodes\n", mediaID)
-					service.CancelTasksForMedia(mediaID)
-				} else {
-					log.Emit(logger.ERROR, "failed to extract UUID from %s event (payload %#v)\n", message.Event, message.Payload)
-				}
-			}
+			service.handleMediaEvents(message, eventChannel)
 		case <-ctx.Done():
-			log.Emit(logger.STOP, "Shutting down (context cancelled). Waiting for transcode tasks to cancel.\n")
-			service.taskWg.Wait()
+			service.shutdown(eventChannel)
+			service.removeExpiredPreviews(true)
 			return nil
 		}
 	}
 }
 
+// handleMediaEvents handles the media event provided, as well as any other media events already
+// waiting in the event channel. Newly ingested media is collected so that the workflows for all of
+// the media can be evaluated together, rather than fetching the media/workflows once per event (which
+// is costly during bulk ingests).
+func (service *transcodeService) handleMediaEvents(message event.HandlerEvent, eventChannel event.HandlerChannel) {
+	newMediaIDs := make([]uuid.UUID, 0)
+	for {
+		//exhaustive:ignore
+		switch message.Event {
+		case event.NewMediaEvent:
+			if mediaID, ok := message.Payload.(uuid.UUID); ok {
+				log.Emit(logger.DEBUG, "newly ingested media with ID %s detected\n", mediaID)
+				newMediaIDs = append(newMediaIDs, mediaID)
+			} else {
+				log.Emit(logger.ERROR, "failed to extract UUID from %s event (payload %#v)\n", message.Event, message.Payload)
+			}
+		case event.DeleteMediaEvent:
+			if mediaID, ok := message.Payload.(uuid.UUID); ok {
+				log.Emit(logger.DEBUG, "media with ID %s deleted, cancelling any ongoing transcodes\n", mediaID)
+				service.CancelTasksForMedia(mediaID)
+			} else {
+				log.Emit(logger.ERROR, "failed to extract UUID from %s event (payload %#v)\n", message.Event, message.Payload)
+			}
+		}
+
+		select {
+		case message = <-eventChannel:
+			continue
+		default:
+		}
+
+		break
+	}
+
+	if len(newMediaIDs) > 0 {
+		service.createWorkflowTasksForMedias(newMediaIDs)
+	}
+}
+
+// shutdown stops the service from accepting new tasks, and waits for running tasks to
+// finish (up to the configured drain timeout). Any tasks which have not completed by then are
+// snapshotted (so they can be restored when Thea next starts) before being cancelled.
+func (service *transcodeService) shutdown(eventChannel event.HandlerChannel) {
+	service.Lock()
+	service.draining = true
+	running := service.tasksWithStatus(WORKING, SUSPENDED)
+	service.Unlock()
+
+	if service.config.DrainTimeout > 0 && len(running) > 0 {
+		log.Emit(logger.STOP, "Shutting down (context cancelled). Waiting up to %s for %d running transcode task(s) to finish.\n", service.config.DrainTimeout, len(running))
+
+		done := make(chan struct{})
+		go func() {
+			service.taskWg.Wait()
+			close(done)
+		}()
+
+		timeout := time.NewTimer(service.config.DrainTimeout)
+		defer timeout.Stop()
+
+	drain:
+		for {
+			select {
+			case taskID := <-service.taskChange:
+				service.handleTaskUpdate(taskID)
+			case <-service.queueChange:
+				// New tasks are not started while draining
+			case message := <-eventChannel:
+				// Consume events to avoid blocking the dispatcher, however new
+				// tasks cannot be created while draining.
+				log.Warnf("Ignoring %s event (payload %v) as service is draining\n", message.Event, message.Payload)
+			case <-done:
+				log.Emit(logger.SUCCESS, "All running transcode tasks have finished\n")
+				break drain
+			case <-timeout.C:
+				log.Warnf("Drain timeout of %s elapsed, cancelling remaining transcode tasks\n", service.config.DrainTimeout)
+				break drain
+			}
+		}
+	} else {
+		log.Emit(logger.STOP, "Shutting down (context cancelled). Waiting for transcode tasks to cancel.\n")
+	}
+
+	service.snapshotQueue()
+	service.cancelTasks()
+	service.taskWg.Wait()
+
+	// Flush any task updates which occurred as the tasks were finishing, to
+	// ensure that tasks which completed are persisted to the database.
+	for {
+		select {
+		case taskID := <-service.taskChange:
+			service.handleTaskUpdate(taskID)
+		case <-service.queueChange:
+		default:
+			return
+		}
+	}
+}
+
+// snapshotQueue persists the tasks which have not yet completed to the data store,
+// so that they can be restored when Thea next starts.
+func (service *transcodeService) snapshotQueue() {
+	service.Lock()
+	defer service.Unlock()
+
+	unfinished := service.tasksWithStatus(WAITING, WORKING, SUSPENDED)
+	if len(unfinished) == 0 {
+		return
+	}
+
+	snapshot := make([]QueuedTask, len(unfinished))
+	for k, task := range unfinished {
+		snapshot[k] = QueuedTask{MediaID: task.media.ID(), TargetID: task.target.ID, VersionID: task.VersionID(), WorkflowID: task.workflowID}
+	}
+
+	if err := service.dataStore.SaveTranscodeQueueSnapshot(snapshot); err != nil {
+		log.Errorf("Failed to snapshot %d unfinished transcode task(s), these tasks will not be restored: %v\n", len(snapshot), err)
+		return
+	}
+
+	log.Emit(logger.STOP, "Snapshotted %d unfinished transcode task(s), these will be restored when Thea next starts\n", len(snapshot))
+}
+
+// restoreQueueSnapshot re-queues any tasks which were unfinished the last time
+// Thea was shutdown (see snapshotQueue).
+func (service *transcodeService) restoreQueueSnapshot() {
+	snapshot, err := service.dataStore.PopTranscodeQueueSnapshot()
+	if err != nil {
+		log.Errorf("Failed to restore transcode queue snapshot: %v\n", err)
+		return
+	}
+
+	mediaIDs := make([]uuid.UUID, len(snapshot))
+	for k, queued := range snapshot {
+		mediaIDs[k] = queued.MediaID
+	}
+	medias, err := service.dataStore.GetMedias(mediaIDs)
+	if err != nil {
+		log.Errorf("Failed to restore transcode queue snapshot: %v\n", err)
+		return
+	}
+	mediaByID := make(map[uuid.UUID]*media.Container, len(medias))
+	for _, m := range medias {
+		mediaByID[m.ID()] = m
+	}
+
+	ctx := logger.ContextWithFields(context.Background(), logger.Fields{"restored": true})
+	for _, queued := range snapshot {
+		if err := service.restoreTask(ctx, mediaByID[queued.MediaID], queued); err != nil {
+			log.Warnf("Failed to restore transcode task for media %s and target %s: %v\n", queued.MediaID, queued.TargetID, err)
+		}
+	}
+
+	if len(snapshot) > 0 {
+		log.Emit(logger.NEW, "Restored %d transcode task(s) from previous shutdown\n", len(snapshot))
+	}
+}
+
+func (service *transcodeService) restoreTask(ctx context.Context, m *media.Container, queued QueuedTask) error {
+	if m == nil {
+		return fmt.Errorf("media %s not found", queued.MediaID)
+	}
+
+	target := service.dataStore.GetTarget(queued.TargetID)
+	if target == nil {
+		return fmt.Errorf("target %s not found", queued.TargetID)
+	}
+
+	version, err := service.mediaVersion(m.ID(), queued.VersionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.spawnFfmpegTarget(ctx, m, version, target, queued.WorkflowID, nil, false)
+	return err
+}
+
+// tasksWithStatus returns all of the tasks in the service which have one of the statuses provided.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) tasksWithStatus(statuses ...TranscodeTaskStatus) []*TranscodeTask {
+	tasks := make([]*TranscodeTask, 0)
+	for _, t := range service.tasks {
+		if slices.Contains(statuses, t.Status()) {
+			tasks = append(tasks, t)
+		}
+	}
+
+	return tasks
+}
+
 // AllTasks returns the array/slice of the transcode task pointers.
 func (service *transcodeService) AllTasks() []*TranscodeTask { return service.tasks }
 
@@ -145,6 +417,7 @@ func (service *transcodeService) CancelTasksForMedia(mediaID uuid.UUID) {
 	service.Lock()
 	defer service.Unlock()
 
+	service.dropSequences(mediaID)
 	toDelete := make([]uuid.UUID, 0)
 	for _, t := range service.tasks {
 		if t.Media().ID() == mediaID {
@@ -161,11 +434,12 @@ func (service *transcodeService) CancelTasksForMedia(mediaID uuid.UUID) {
 }
 
 // TaskForMediaAndTarget searches through all the tasks in this service and looks for one
-// which was created for the media and target matching the IDs provided. If no such task exists
-// then nil is returned.
-func (service *transcodeService) ActiveTaskForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID) *TranscodeTask {
+// which was created for the media, target and version matching the IDs provided. A nil
+// version ID matches only tasks transcoding the primary source of the media. If no such
+// task exists then nil is returned.
+func (service *transcodeService) ActiveTaskForMediaAndTarget(mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) *TranscodeTask {
 	for _, t := range service.tasks {
-		if t.media.ID() == mediaID && t.target.ID == targetID {
+		if t.media.ID() == mediaID && t.target.ID == targetID && sameVersion(t.VersionID(), versionID) {
 			return t
 		}
 	}
@@ -174,10 +448,15 @@ func (service *transcodeService) ActiveTaskForMediaAndTarget(mediaID uuid.UUID,
 }
 
 // NewTask fetches the media and target corresponding to the IDs provided and attempts to spawn
-// a task using the result.
-// If the media/target fail to be retrieved, or if a transcode task for the
-// media+target already exists, an error is returned.
-func (service *transcodeService) NewTask(mediaID uuid.UUID, targetID uuid.UUID) error {
+// a task using the result, on behalf of the user with the ID given. If a version ID is provided,
+// the task transcodes that version of the media rather than it's primary source.
+// If the media/target/version fail to be retrieved, if a transcode task for the
+// media+target+version already exists, or if the quota of the user does not permit
+// another task (see quota.Usage.Check), an error is returned.
+// Any logging fields stored in the context provided are included in the log
+// lines emitted for the new task. Important tasks are run with a higher priority than other tasks
+// (see PriorityConfig), so that they're not slowed by the transcodes queued by workflows.
+func (service *transcodeService) NewTask(ctx context.Context, userID uuid.UUID, mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID, important bool) error {
 	media := service.dataStore.GetMedia(mediaID)
 	if media == nil {
 		return fmt.Errorf("media %s not found", mediaID)
@@ -188,7 +467,73 @@ func (service *transcodeService) NewTask(mediaID uuid.UUID, targetID uuid.UUID)
 		return fmt.Errorf("target %s not found", targetID)
 	}
 
-	return service.spawnFfmpegTarget(media, target)
+	version, err := service.mediaVersion(mediaID, versionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.spawnFfmpegTarget(ctx, media, version, target, nil, &userID, important)
+	return err
+}
+
+// Quota returns the quota of the user with the ID provided, alongside the number of manual transcode
+// tasks the user currently has active, has requested within the daily window, and the number of
+// playback sessions the user has ongoing.
+func (service *transcodeService) Quota(userID uuid.UUID) (*quota.Usage, error) {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.quotaUsage(userID)
+}
+
+// quotaUsage returns the quota usage of the user with the ID provided. Tasks which
+// are troubled are not counted as active, as they're not consuming any resources.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) quotaUsage(userID uuid.UUID) (*quota.Usage, error) {
+	userQuota, err := service.dataStore.GetUserQuota(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quota of user %s: %w", userID, err)
+	}
+
+	requested, err := service.dataStore.CountUserTranscodeRequests(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count transcode requests of user %s: %w", userID, err)
+	}
+
+	active := 0
+	for _, task := range service.tasksWithStatus(WAITING, WORKING, SUSPENDED) {
+		if requestedBy := task.RequestedBy(); requestedBy != nil && *requestedBy == userID {
+			active++
+		}
+	}
+
+	sessions := 0
+	for _, session := range service.sessions {
+		if session.UserID == userID {
+			sessions++
+		}
+	}
+
+	return &quota.Usage{Quota: userQuota, ActiveTranscodes: active, RequestedTranscodes: requested, ActiveSessions: sessions}, nil
+}
+
+// mediaVersion fetches the version with the ID provided, ensuring it is a version
+// of the media given. If the version ID is nil, then nil is returned.
+func (service *transcodeService) mediaVersion(mediaID uuid.UUID, versionID *uuid.UUID) (*media.Version, error) {
+	if versionID == nil {
+		return nil, nil
+	}
+
+	version, err := service.dataStore.GetMediaVersion(*versionID)
+	if err != nil {
+		return nil, fmt.Errorf("version %s not found: %w", *versionID, err)
+	}
+	if version.MediaID != mediaID {
+		return nil, fmt.Errorf("version %s is not a version of media %s", *versionID, mediaID)
+	}
+
+	return version, nil
 }
 
 // CancelTask will find the transcode task with the ID provided and cancel it. If the task
@@ -202,7 +547,15 @@ func (service *transcodeService) CancelTask(id uuid.UUID) error {
 	if err := task.cancel(); err != nil {
 		// This error usually indicates the task is not the right state to be cancelled, however
 		// we should still proceed with removing it from the queue
-		log.Warnf("failed to cancel task %s command: %s", task, err)
+		task.log.Warnf("failed to cancel task %s command: %s", task, err)
+	}
+
+	// Troubled tasks have already had their failure recorded
+	// in the transcode history.
+	if task.Status() != TROUBLED {
+		if err := service.dataStore.SaveTranscodeCancellation(task); err != nil {
+			task.log.Errorf("Failed to record cancellation of task %s in history: %v\n", task, err)
+		}
 	}
 
 	isBeingMonitored := task.Status() == WORKING || task.Status() == SUSPENDED
@@ -212,7 +565,7 @@ func (service *transcodeService) CancelTask(id uuid.UUID) error {
 		service.removeTaskFromQueue(id)
 	}
 
-	log.Emit(logger.STOP, "Cancelled %s\n", task)
+	task.log.Emit(logger.STOP, "Cancelled %s\n", task)
 	return nil
 }
 
@@ -230,7 +583,7 @@ func (service *transcodeService) PauseTask(id uuid.UUID) error {
 		return err
 	}
 
-	log.Infof("Paused %s\n", task)
+	task.log.Infof("Paused %s\n", task)
 	service.taskChange <- id
 	return nil
 }
@@ -249,25 +602,131 @@ func (service *transcodeService) ResumeTask(id uuid.UUID) error {
 		return err
 	}
 
-	log.Infof("Resumed %s\n", task)
+	task.log.Infof("Resumed %s\n", task)
 	service.taskChange <- id
 	return nil
 }
 
+// PauseQueue prevents any WAITING tasks from being started until the queue is resumed. Running
+// tasks are allowed to finish, unless suspendRunning is true, in which case they are suspended
+// (and are resumed when the queue is resumed).
+func (service *transcodeService) PauseQueue(suspendRunning bool) {
+	service.Lock()
+	service.queuePaused = true
+	suspended := make([]uuid.UUID, 0)
+	if suspendRunning {
+		for _, task := range service.tasksWithStatus(WORKING) {
+			if err := task.pause(); err != nil {
+				task.log.Warnf("Failed to suspend %s while pausing queue: %v\n", task, err)
+				continue
+			}
+
+			suspended = append(suspended, task.ID())
+		}
+		service.queueSuspended = append(service.queueSuspended, suspended...)
+	}
+	service.Unlock()
+
+	log.Emit(logger.STOP, "Transcode queue paused (%d running task(s) suspended)\n", len(suspended))
+	for _, id := range suspended {
+		service.taskChange <- id
+	}
+}
+
+// ResumeQueue allows WAITING tasks to be started again, and resumes any tasks
+// which were suspended when the queue was paused.
+func (service *transcodeService) ResumeQueue() {
+	service.Lock()
+	service.queuePaused = false
+	resumed := make([]uuid.UUID, 0, len(service.queueSuspended))
+	for _, id := range service.queueSuspended {
+		// Tasks may have been cancelled, or manually resumed, since the queue was paused
+		if task := service.Task(id); task != nil && task.Status() == SUSPENDED {
+			if err := task.resume(); err != nil {
+				task.log.Warnf("Failed to resume %s while resuming queue: %v\n", task, err)
+				continue
+			}
+
+			resumed = append(resumed, id)
+		}
+	}
+	service.queueSuspended = nil
+	service.Unlock()
+
+	log.Emit(logger.NEW, "Transcode queue resumed (%d suspended task(s) resumed)\n", len(resumed))
+	for _, id := range resumed {
+		service.taskChange <- id
+	}
+	service.queueChange <- true
+}
+
+// IsQueuePaused returns true if the queue has been paused (see PauseQueue).
+func (service *transcodeService) IsQueuePaused() bool {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.queuePaused
+}
+
+// ConsumedThreads returns the number of threads consumed by the running tasks.
+func (service *transcodeService) ConsumedThreads() int {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.consumedThreads
+}
+
+// ApplyConfig applies the options of the configuration provided which can be changed while
+// the service is running: the thread budget, free space reserve, storage pools, stall timeout,
+// output log size, retry policy, priority, contention policy and workflow hooks. Running tasks are unaffected (other
+// than being suspended/resumed according to the contention policy), however a change to the thread
+// budget or storage pools is considered when next starting waiting tasks. All other options
+// are ignored.
+func (service *transcodeService) ApplyConfig(config Config) {
+	service.Lock()
+	service.config.MaximumThreadConsumption = config.MaximumThreadConsumption
+	service.config.MinimumFreeSpaceMB = config.MinimumFreeSpaceMB
+	service.config.StoragePools = config.StoragePools
+	service.config.StallTimeout = config.StallTimeout
+	service.config.LogSizeKB = config.LogSizeKB
+	service.config.Retries = config.Retries
+	service.config.Priority = config.Priority
+	service.config.Contention = config.Contention
+	service.config.Hooks = config.Hooks
+	service.relieveContention()
+	service.suspendForContention()
+	service.Unlock()
+
+	select {
+	case service.queueChange <- true:
+	default:
+	}
+}
+
 // startWaitingTasks finds any transcode items that are waiting to be started will be started, and any that are
 // finished will be removed from the transcoders. The starting of FFmpeg tasks will be subject to
 // the maximum thread usage defined in the services configuration.
-func (service *transcodeService) startWaitingTasks(ctx context.Context) {
+func (service *transcodeService) startWaitingTasks() {
 	service.Lock()
 	defer service.Unlock()
 
-	if service.consumedThreads == service.config.MaximumThreadConsumption {
+	// Tasks suspended for playback contention take precedence over tasks which are yet to start
+	service.relieveContention()
+	if service.draining || service.queuePaused || service.consumedThreads+service.reservedThreads() >= service.config.MaximumThreadConsumption {
 		return
 	}
 
-	for _, task := range service.tasks {
-		if task.Status() != WAITING {
-			continue
+	pool := service.admitWaitingTasks()
+	if pool == nil {
+		return
+	}
+
+	for _, task := range service.fairQueue() {
+		requiredBudget := task.requiredThreads()
+		availableBudget := service.config.MaximumThreadConsumption - service.consumedThreads - service.reservedThreads()
+		if requiredBudget > availableBudget {
+			task.log.Emit(logger.DEBUG, "Thread requirements of task %s (%d) exceed remaining budget (%d), instance spawning complete\n", task, requiredBudget, availableBudget)
+			return
 		}
 
 		// Set working status as soon as possible. This is to prevent
@@ -278,11 +737,14 @@ func (service *transcodeService) startWaitingTasks(ctx context.Context) {
 		// can easily see the same task spawned multiple times.
 		task.status = WORKING
 
-		requiredBudget := task.Target().RequiredThreads()
-		availableBudget := service.config.MaximumThreadConsumption - service.consumedThreads
-		if requiredBudget > availableBudget {
-			log.Emit(logger.DEBUG, "Thread requirements of task %s (%d) exceed remaining budget (%d), instance spawning complete\n", task, requiredBudget, availableBudget)
-			return
+		if err := task.place(pool); err != nil {
+			task.status = TROUBLED
+			task.trouble = &Trouble{error: err, tType: PermissionDenied}
+			if err := service.dataStore.SaveTranscodeFailure(task); err != nil {
+				task.log.Errorf("Failed to record failure of task %s: %v\n", task, err)
+			}
+			service.eventBus.Dispatch(event.TranscodeUpdateEvent, task.id)
+			continue
 		}
 
 		service.consumedThreads += requiredBudget
@@ -295,7 +757,7 @@ func (service *transcodeService) startWaitingTasks(ctx context.Context) {
 				// status has changed then it seems we've reached some sort of
 				// race condition where another thread started working on this task.
 				// Abort!
-				log.Warnf("Task %s status was not 'WAITING' when attempting to start processing [data race warning, *report this*]", taskToStart)
+				taskToStart.log.Warnf("Task %s status was not 'WAITING' when attempting to start processing [data race warning, *report this*]", taskToStart)
 				return
 			}
 
@@ -305,11 +767,19 @@ func (service *transcodeService) startWaitingTasks(ctx context.Context) {
 			}
 
 			service.taskChange <- taskToStart.id
-			log.Emit(logger.DEBUG, "Starting task %s, consuming %d threads\n", taskToStart, threadCost)
-			if err := taskToStart.Run(ctx, updateHandler); err != nil {
-				log.Emit(logger.WARNING, "Task %s has concluded with error: %v\n", taskToStart, err)
+			taskToStart.log.Emit(logger.DEBUG, "Starting task %s, consuming %d threads\n", taskToStart, threadCost)
+			retrying := false
+			if err := taskToStart.Run(service.taskCtx, updateHandler); err != nil {
+				taskToStart.log.Emit(logger.WARNING, "Task %s has concluded with error: %v\n", taskToStart, err)
+				if taskToStart.Status() == TROUBLED {
+					if retrying = service.retryTroubledTask(taskToStart); !retrying {
+						if err := service.dataStore.SaveTranscodeFailure(taskToStart); err != nil {
+							taskToStart.log.Errorf("Failed to record failure of task %s: %v\n", taskToStart, err)
+						}
+					}
+				}
 			} else {
-				log.Emit(logger.DEBUG, "Task %s has concluded nominally\n", taskToStart)
+				taskToStart.log.Emit(logger.DEBUG, "Task %s has concluded nominally\n", taskToStart)
 			}
 
 			// Submit a non-blocking update to ensure completed/cancelled tasks are correctly dealt with
@@ -325,13 +795,160 @@ func (service *transcodeService) startWaitingTasks(ctx context.Context) {
 			}
 
 			service.Lock()
-			defer service.Unlock()
 			service.consumedThreads -= threadCost
-			log.Emit(logger.DEBUG, "Task %s has released %d threads\n", taskToStart.ID(), threadCost)
+			taskToStart.log.Emit(logger.DEBUG, "Task %s has released %d threads\n", taskToStart.ID(), threadCost)
+			service.Unlock()
+
+			if retrying {
+				select {
+				case service.queueChange <- true:
+				default:
+				}
+			}
 		}(task, service.taskWg, requiredBudget)
 	}
 }
 
+// fairQueue returns the WAITING tasks in the order they should be started. Rather than starting
+// tasks in the order they were queued (which allows a user, or a media with many targets, to starve
+// tasks queued after it), the pool is shared fairly: the next task is always taken from the user
+// holding the fewest in-flight (running or suspended) tasks, and then from the media of that user
+// with the fewest in-flight tasks, with ties broken by the order the media were queued. Tasks which
+// were not requested by a user (e.g. those started by workflows) share a single allocation.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) fairQueue() []*TranscodeTask {
+	owner := func(task *TranscodeTask) uuid.UUID {
+		if task.requestedBy == nil {
+			return uuid.Nil
+		}
+
+		return *task.requestedBy
+	}
+
+	userInFlight := make(map[uuid.UUID]int)
+	mediaInFlight := make(map[uuid.UUID]int)
+	waiting := make(map[uuid.UUID][]*TranscodeTask)
+	mediaOrder := make([]uuid.UUID, 0)
+	for _, task := range service.tasks {
+		mediaID := task.media.ID()
+		//exhaustive:ignore
+		switch task.Status() {
+		case WORKING, SUSPENDED:
+			userInFlight[owner(task)]++
+			mediaInFlight[mediaID]++
+		case WAITING:
+			if _, ok := waiting[mediaID]; !ok {
+				mediaOrder = append(mediaOrder, mediaID)
+			}
+			waiting[mediaID] = append(waiting[mediaID], task)
+		}
+	}
+
+	queue := make([]*TranscodeTask, 0)
+	for len(mediaOrder) > 0 {
+		next := 0
+		for k, mediaID := range mediaOrder {
+			user, nextUser := userInFlight[owner(waiting[mediaID][0])], userInFlight[owner(waiting[mediaOrder[next]][0])]
+			if user < nextUser || (user == nextUser && mediaInFlight[mediaID] < mediaInFlight[mediaOrder[next]]) {
+				next = k
+			}
+		}
+
+		mediaID := mediaOrder[next]
+		task := waiting[mediaID][0]
+		queue = append(queue, task)
+		userInFlight[owner(task)]++
+		mediaInFlight[mediaID]++
+		if waiting[mediaID] = waiting[mediaID][1:]; len(waiting[mediaID]) == 0 {
+			mediaOrder = append(mediaOrder[:next], mediaOrder[next+1:]...)
+		}
+	}
+
+	return queue
+}
+
+// admitWaitingTasks selects the storage pool which the WAITING tasks should be written to (see
+// StoragePool). If none of the pools have more than their configured reserve of free space available,
+// the WAITING tasks are held in the queue with an InsufficientDiskSpace trouble (which is cleared once
+// space becomes available again), and nil is returned.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) admitWaitingTasks() *StoragePool {
+	waiting := service.tasksWithStatus(WAITING)
+	if len(waiting) == 0 {
+		return nil
+	}
+
+	pools := service.config.GetStoragePools()
+	pool, err := selectStoragePool(pools)
+	var spaceErr *disk.InsufficientSpaceError
+	if errors.As(err, &spaceErr) {
+		if !service.lowDiskSpace {
+			log.Errorf("Holding %d transcode task(s) until more disk space is available: %v\n", len(waiting), err)
+		}
+		service.lowDiskSpace = true
+
+		for _, task := range waiting {
+			if task.trouble == nil {
+				task.trouble = &Trouble{error: err, tType: InsufficientDiskSpace}
+				service.eventBus.Dispatch(event.TranscodeUpdateEvent, task.id)
+			}
+		}
+
+		return nil
+	} else if err != nil {
+		// Failing to inspect the pools should not prevent transcodes from being started, as
+		// the transcode itself will fail if the pool is unusable.
+		log.Warnf("Unable to determine free disk space of storage pools, admitting waiting tasks to pool %s anyway: %v\n", pools[0].Name, err)
+		pool = &pools[0]
+	}
+
+	if service.lowDiskSpace {
+		log.Emit(logger.NEW, "Disk space available for transcode output, resuming held transcode task(s)\n")
+	}
+	service.lowDiskSpace = false
+
+	for _, task := range waiting {
+		if task.trouble != nil && task.trouble.Type() == InsufficientDiskSpace {
+			task.trouble = nil
+			service.eventBus.Dispatch(event.TranscodeUpdateEvent, task.id)
+		}
+	}
+
+	return pool
+}
+
+// isLowOnDiskSpace returns true if WAITING tasks are being held due to
+// insufficient disk space (see admitWaitingTasks).
+func (service *transcodeService) isLowOnDiskSpace() bool {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.lowDiskSpace
+}
+
+// retryTroubledTask re-queues the TROUBLED task provided if it has been retried fewer times than the
+// retry policy allows for the type of trouble it encountered. True is returned if the task was re-queued.
+func (service *transcodeService) retryTroubledTask(task *TranscodeTask) bool {
+	service.Lock()
+	defer service.Unlock()
+
+	if service.draining || task.trouble == nil {
+		return false
+	}
+
+	retries := service.config.Retries.Retries(task.trouble.Type())
+	if task.attempts > retries {
+		return false
+	}
+
+	task.log.Warnf("Task %s failed (%v), re-queueing for retry %d of %d. FFmpeg output:\n%s\n", task, task.trouble, task.attempts, retries, task.trouble.Output())
+	task.status = WAITING
+	task.trouble = nil
+	return true
+}
+
 // handleTaskUpdate is the handler for any task updates in this service.
 // Any dead tasks are removed from the queue. Completed tasks are committed
 // to the database before being removed from the queue.
@@ -342,11 +959,13 @@ func (service *transcodeService) handleTaskUpdate(taskID uuid.UUID) {
 	}
 
 	if task.status == COMPLETE {
-		if err := service.dataStore.SaveTranscode(task); err != nil {
-			// TODO: implement a retry logic here because otherwise this transcode is lost
-			log.Errorf("failed to save transcode %s due to error: %v\n", task, err)
+		if err := service.saveCompletedTranscode(task); err != nil {
+			task.log.Errorf("failed to save transcode %s due to error: %v\n", task, err)
 		} else {
+			service.recordBatchCompletion(task.id)
+			service.runWorkflowHooks(task)
 			service.eventBus.Dispatch(event.TranscodeCompleteEvent, taskID)
+			service.eventBus.Dispatch(event.WatchTargetReadyEvent, taskID)
 			service.removeTaskFromQueue(task.id)
 
 			return
@@ -360,61 +979,180 @@ func (service *transcodeService) handleTaskUpdate(taskID uuid.UUID) {
 	service.eventBus.Dispatch(event.TranscodeUpdateEvent, taskID)
 }
 
-// createWorkflowTasksForMedia takes a media ID, and queries the Ffmpeg Store for a workflow
-// matching the media provided. The first workflow to be found as eligible will see the associatted
-// tasks be created, managed and monitored by this service.
-func (service *transcodeService) createWorkflowTasksForMedia(mediaID uuid.UUID) {
-	media := service.dataStore.GetMedia(mediaID)
+// createWorkflowTasksForMedias takes a set of media IDs, and queries the Ffmpeg Store for a workflow
+// matching each of the media provided. The first workflow to be found as eligible for a media will see
+// the associatted tasks be created, managed and monitored by this service. The media and workflows are
+// each fetched using a single query, regardless of the number of media IDs provided.
+func (service *transcodeService) createWorkflowTasksForMedias(mediaIDs []uuid.UUID) {
+	medias, err := service.dataStore.GetMedias(mediaIDs)
+	if err != nil {
+		log.Emit(logger.ERROR, "failed to fetch media %v for workflow evaluation: %v\n", mediaIDs, err)
+		return
+	}
+
 	workflows := service.dataStore.GetAllWorkflows()
+	for _, media := range medias {
+		service.createWorkflowTasksForMedia(media, workflows)
+	}
+}
 
+// createWorkflowTasksForMedia searches the workflows provided for one matching the media. The
+// first workflow to be found as eligible will see the associatted tasks be created, managed and
+// monitored by this service.
+func (service *transcodeService) createWorkflowTasksForMedia(media *media.Container, workflows []*workflow.Workflow) {
 	for _, workflow := range workflows {
 		if workflow.IsMediaEligible(media) {
+			ctx := logger.ContextWithFields(context.Background(), logger.Fields{"workflow_id": workflow.ID})
+			if workflow.Sequential {
+				service.queueSequence(ctx, media, workflow.ID, workflow.Targets)
+				log.Emit(logger.NEW, "Media %s met the conditions of sequential workflow %v... Automated transcodes queued in sequence\n", media.ID(), workflow)
+				return
+			}
+
 			for _, target := range workflow.Targets {
-				log.Infof("STARTING TASK FOR MEDIA %s TARGET %s\n", mediaID, target.ID)
-				if err := service.spawnFfmpegTarget(media, target); err != nil {
+				log.WithContext(ctx).Infof("Starting task for media %s target %s\n", media.ID(), target.ID)
+				if _, err := service.spawnFfmpegTarget(ctx, media, nil, target, &workflow.ID, nil, false); err != nil && !errors.Is(err, ErrPassthrough) {
 					log.Emit(logger.ERROR, "failed to spawn ffmpeg target %s for media %s: %v\n", target, media.ID(), err)
 				}
 			}
 
-			log.Emit(logger.NEW, "Media %s met the conditions of workflow %v... Automated transcodes queued\n", mediaID, workflow)
+			log.Emit(logger.NEW, "Media %s met the conditions of workflow %v... Automated transcodes queued\n", media.ID(), workflow)
 			return
 		}
 	}
 
 	// TODO: Maybe we create some sort of a notification or something about not being able to find an eligible
 	//		 workflow? I could see that being useful.
-	log.Emit(logger.DEBUG, "Media %s did not meet the conditions of any known workflows. No automated transcoding will occur\n", mediaID)
+	log.Emit(logger.DEBUG, "Media %s did not meet the conditions of any known workflows. No automated transcoding will occur\n", media.ID())
 }
 
-// spawnFfmpegTarget will create a new transcode task assigned to the media and target provided,
-// and add the task to the services queue in an 'IDLE' state.
-// An error is returned if a task for this media+target already exists, whether completed (in DB) or active
+// spawnFfmpegTarget will create a new transcode task assigned to the media, version (nil for the
+// primary source) and target provided, and add the task to the services queue in an 'IDLE' state.
+// The ID of the workflow which queued the task should be provided (or nil, if the task was not
+// queued by a workflow) so that the hooks of the workflow are executed once the task completes.
+// The task created is returned.
+// An error is returned if a task for this media+target+version already exists, whether completed (in DB) or active
+// If the ID of the user which requested the task is provided, the task counts towards the quota of the user,
+// and an error is returned if the quota does not permit another task. Tasks created automatically (e.g. by
+// workflows) should provide a nil requester. Important tasks are run using the important priority (see
+// PriorityConfig), and should only be requested manually.
 // Note: This function does not START the transcoding, it only creates the task and adds it to the
 // processing queue.
-func (service *transcodeService) spawnFfmpegTarget(m *media.Container, target *ffmpeg.Target) error {
+func (service *transcodeService) spawnFfmpegTarget(ctx context.Context, m *media.Container, version *media.Version, target *ffmpeg.Target, workflowID *uuid.UUID, requestedBy *uuid.UUID, important bool) (*TranscodeTask, error) {
+	// The source is probed before acquiring the lock, as probing can be slow
+	compliance := service.sourceCompliance(ctx, m, version, target)
+
 	service.Lock()
 	defer service.Unlock()
 
-	if existing := service.ActiveTaskForMediaAndTarget(m.ID(), target.ID); existing != nil {
-		return fmt.Errorf("an active task for media %s and target %s already exists", m.ID(), target.ID)
+	if service.draining {
+		return nil, ErrDraining
 	}
 
-	if existing, _ := service.dataStore.GetForMediaAndTarget(m.ID(), target.ID); existing != nil {
-		return fmt.Errorf("a completed task for media %s and target %s already exists", m.ID(), target.ID)
+	var versionID *uuid.UUID
+	if version != nil {
+		versionID = &version.ID
 	}
 
-	newTask, err := NewTranscodeTask(m, target, ffmpeg.Config{
-		FfmpegBinPath:       service.config.FfmpegBinaryPath,
-		FfprobeBinPath:      service.config.FfprobeBinaryPath,
-		OutputBaseDirectory: service.config.OutputPath,
-	})
+	if existing := service.ActiveTaskForMediaAndTarget(m.ID(), target.ID, versionID); existing != nil {
+		return nil, fmt.Errorf("an active task for media %s and target %s already exists", m.ID(), target.ID)
+	}
+
+	if existing, _ := service.dataStore.GetForMediaAndTarget(m.ID(), target.ID, versionID); existing != nil {
+		return nil, fmt.Errorf("a completed task for media %s and target %s already exists", m.ID(), target.ID)
+	}
+
+	if compliance == ffmpeg.Compliant {
+		return nil, service.savePassthrough(ctx, m, versionID, target, sourcePath(m, version))
+	}
+
+	if requestedBy != nil {
+		usage, err := service.quotaUsage(*requestedBy)
+		if err != nil {
+			return nil, err
+		}
+		if err := usage.Check(); err != nil {
+			return nil, err
+		}
+	}
+
+	config := service.ffmpegConfig()
+	config.Priority = service.config.Priority.priority(important)
+	newTask, err := NewTranscodeTask(ctx, m, version, target, config, service.config.StallTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to create new transcode task: %w", err)
+		return nil, fmt.Errorf("failed to create new transcode task: %w", err)
 	}
+	newTask.remux = target.RemuxOnly || compliance == ffmpeg.RemuxCompliant
+	newTask.important = important
+	newTask.workflowID = workflowID
+	if requestedBy != nil {
+		if err := service.dataStore.RecordUserTranscodeRequest(*requestedBy, newTask.ID()); err != nil {
+			return nil, fmt.Errorf("failed to record transcode request of user %s: %w", *requestedBy, err)
+		}
+		newTask.requestedBy = requestedBy
+	}
+	newTask.segment(service.config.Segmentation, service.config.MaximumThreadConsumption)
 
 	service.tasks = append(service.tasks, newTask)
 	service.queueChange <- true
-	return nil
+	return newTask, nil
+}
+
+// sourceCompliance probes the source of the media (or version) provided, and checks whether it already
+// satisfies the target given (see ffmpeg.CheckCompliance). If SkipCompliantSources is disabled, or the
+// source cannot be probed, the source is considered non-compliant.
+func (service *transcodeService) sourceCompliance(ctx context.Context, m *media.Container, version *media.Version, target *ffmpeg.Target) ffmpeg.Compliance {
+	service.Lock()
+	enabled := service.config.SkipCompliantSources
+	service.Unlock()
+	if !enabled {
+		return ffmpeg.NonCompliant
+	}
+
+	source := sourcePath(m, version)
+	metadata, err := ffmpeg.ProbeFile(source, service.config.FfprobeBinaryPath)
+	if err != nil {
+		log.WithContext(ctx).Warnf("Failed to probe %s, unable to determine if it satisfies target %s: %v\n", source, target, err)
+		return ffmpeg.NonCompliant
+	}
+
+	return ffmpeg.CheckCompliance(metadata, source, target)
+}
+
+// savePassthrough records the source provided as the transcode of the media/version using the
+// target given, as the source already satisfies the target. ErrPassthrough is returned
+// if the transcode was recorded successfully.
+//
+// Note: This function does not take ownership of the mutex.
+func (service *transcodeService) savePassthrough(ctx context.Context, m *media.Container, versionID *uuid.UUID, target *ffmpeg.Target, source string) error {
+	passthrough := &Transcode{
+		ID:          uuid.New(),
+		MediaID:     m.ID(),
+		TargetID:    target.ID,
+		VersionID:   versionID,
+		MediaPath:   source,
+		Passthrough: true,
+	}
+	if err := service.dataStore.SavePassthroughTranscode(passthrough); err != nil {
+		return fmt.Errorf("failed to record source %s as transcode of target %s: %w", source, target, err)
+	}
+
+	log.WithContext(ctx).Emit(logger.SUCCESS, "Source of media %s already satisfies target %s, recorded as transcode %s without transcoding\n", m.ID(), target, passthrough.ID)
+	service.eventBus.Dispatch(event.TranscodeCompleteEvent, passthrough.ID)
+	service.eventBus.Dispatch(event.WatchTargetReadyEvent, passthrough.ID)
+	return ErrPassthrough
+}
+
+// ffmpegConfig returns the configuration used for the ffmpeg commands spawned by this service.
+func (service *transcodeService) ffmpegConfig() ffmpeg.Config {
+	return ffmpeg.Config{
+		FfmpegBinPath:       service.config.FfmpegBinaryPath,
+		FfprobeBinPath:      service.config.FfprobeBinaryPath,
+		OutputBaseDirectory: service.config.OutputPath,
+		OutputTailSize:      service.config.LogSizeKB * 1024,
+		Priority:            service.config.Priority.priority(false),
+		Cgroup:              service.config.Priority.Cgroup,
+	}
 }
 
 // removeTaskFromQueue will look for and remove the task with the ID provided
@@ -424,9 +1162,20 @@ func (service *transcodeService) removeTaskFromQueue(taskID uuid.UUID) {
 	for i, v := range service.tasks {
 		if v.id == taskID {
 			service.tasks = append(service.tasks[:i], service.tasks[i+1:]...)
+			service.advanceSequence(taskID)
 			service.queueChange <- true
 
 			return
 		}
 	}
 }
+
+// sameVersion returns true if the version IDs provided refer to the same version
+// of a media. Nil IDs refer to the primary source of the media.
+func sameVersion(a *uuid.UUID, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
@@ -3,9 +3,14 @@ package transcode
 import (
 	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/internal/file"
 	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/jmoiron/sqlx"
 )
@@ -16,21 +21,140 @@ type (
 	Store struct{}
 
 	Transcode struct {
-		ID        uuid.UUID `db:"id"`
-		MediaID   uuid.UUID `db:"media_id"`
-		TargetID  uuid.UUID `db:"transcode_target_id"`
-		MediaPath string    `db:"path"`
+		ID        uuid.UUID  `db:"id"`
+		MediaID   uuid.UUID  `db:"media_id"`
+		TargetID  uuid.UUID  `db:"transcode_target_id"`
+		VersionID *uuid.UUID `db:"version_id"`
+		MediaPath string     `db:"path"`
+		Size      int64      `db:"size"`
+		CreatedAt time.Time  `db:"created_at"`
+
+		// Pool is the name of the storage pool the output file was written to.
+		Pool string `db:"pool"`
+
+		// Passthrough is true if the source of the media already satisfied the target, and so
+		// the path of this transcode is the source itself. The source is not removed when the
+		// transcode is deleted, and the transcode is not written to any storage pool.
+		Passthrough bool `db:"passthrough"`
+
+		// DegradedAt is non-nil if the output file of this transcode could
+		// not be found on disk during the last consistency check.
+		DegradedAt *time.Time `db:"degraded_at"`
+
+		// Checksum is the SHA-256 digest of the output file, recorded on completion. CorruptedAt
+		// is non-nil if the output file no longer matched this checksum when last verified.
+		Checksum    *string    `db:"checksum"`
+		CorruptedAt *time.Time `db:"corrupted_at"`
+
+		// AudioStreamIndex is the index of the audio stream of the source selected using the audio
+		// language preferences of the target, and AudioLanguage is the language of that stream. Both
+		// are nil if the target had no preferences, and the language is nil if the stream is untagged.
+		AudioStreamIndex *int    `db:"audio_stream_index"`
+		AudioLanguage    *string `db:"audio_language"`
+	}
+
+	// TargetPopularity aggregates how often the transcodes of a target have been streamed. LastPlayedAt
+	// is nil if none of the transcodes of the target have been streamed.
+	TargetPopularity struct {
+		TargetID     uuid.UUID  `db:"transcode_target_id"`
+		Transcodes   int        `db:"transcodes"`
+		Starts       int        `db:"starts"`
+		Completions  int        `db:"completions"`
+		LastPlayedAt *time.Time `db:"last_played_at"`
+	}
+
+	// Statistics contains aggregated information about the completed transcodes, as
+	// well as the outcomes of all transcode tasks which concluded since a given time.
+	Statistics struct {
+		TranscodeCount int   `db:"transcode_count"`
+		TranscodeBytes int64 `db:"transcode_bytes"`
+		History        []*OutcomeHistory
+	}
+
+	// OutcomeHistory is the number of transcode tasks which succeeded/failed on a given day.
+	OutcomeHistory struct {
+		Day       time.Time `db:"day"`
+		Succeeded int       `db:"succeeded"`
+		Failed    int       `db:"failed"`
+	}
+
+	// OutcomeStatus is the status a transcode task concluded with.
+	OutcomeStatus string
+
+	// HistoryEntry describes a transcode task which has concluded, whether it completed, failed or
+	// was cancelled. StartedAt is nil if the task was cancelled before it was started, and the
+	// OutputSize is only available for completed tasks.
+	HistoryEntry struct {
+		ID          uuid.UUID     `db:"id"`
+		MediaID     uuid.UUID     `db:"media_id"`
+		TargetID    uuid.UUID     `db:"transcode_target_id"`
+		VersionID   *uuid.UUID    `db:"version_id"`
+		Status      OutcomeStatus `db:"status"`
+		StartedAt   *time.Time    `db:"started_at"`
+		ConcludedAt time.Time     `db:"concluded_at"`
+		OutputSize  *int64        `db:"output_size"`
+		Error       *string       `db:"error"`
+	}
+
+	// HistoryFilter restricts the history entries returned by ListHistory. Empty
+	// statuses, or a nil since, are not used to filter the entries.
+	HistoryFilter struct {
+		Statuses []OutcomeStatus
+		Since    *time.Time
+		Offset   int
+		Limit    int
+	}
+
+	// QueuedTask describes a transcode task which was queued, but did not complete,
+	// when Thea was shutdown. These are re-queued when Thea next starts.
+	QueuedTask struct {
+		MediaID    uuid.UUID  `db:"media_id"`
+		TargetID   uuid.UUID  `db:"transcode_target_id"`
+		VersionID  *uuid.UUID `db:"version_id"`
+		WorkflowID *uuid.UUID `db:"workflow_id"`
 	}
 )
 
+const (
+	OutcomeComplete  OutcomeStatus = "COMPLETE"
+	OutcomeFailed    OutcomeStatus = "FAILED"
+	OutcomeCancelled OutcomeStatus = "CANCELLED"
+)
+
 // SaveTranscode inserts a row in to the database which represents the provided transcode task. If an existing
 // row which conflicts with this insertion will cause the method to return an error.
 func (store *Store) SaveTranscode(db database.Queryable, task *TranscodeTask) error {
+	// The size is only used for reporting, so failure to stat the output
+	// should not prevent the transcode from being saved
+	var size int64
+	if info, err := os.Stat(task.OutputPath()); err != nil {
+		log.Warnf("Failed to stat output of transcode %s, size will not be recorded: %v\n", task, err)
+	} else {
+		size = info.Size()
+	}
+
+	// Likewise, a missing checksum will be recorded when the transcode is next verified
+	var checksum *string
+	if sum, err := file.Checksum(task.OutputPath()); err != nil {
+		log.Warnf("Failed to checksum output of transcode %s, checksum will not be recorded: %v\n", task, err)
+	} else {
+		checksum = &sum
+	}
+
+	var audioStreamIndex *int
+	var audioLanguage *string
+	if task.audio != nil {
+		audioStreamIndex = &task.audio.Index
+		if task.audio.Language != "" {
+			audioLanguage = &task.audio.Language
+		}
+	}
+
 	// TODO timestamp columns (created_at, updated_at)
 	if _, err := db.Exec(`
-		INSERT INTO media_transcodes(id, media_id, transcode_target_id, path)
-		VALUES ($1, $2, $3, $4)`,
-		task.id, task.media.ID(), task.target.ID, task.OutputPath(),
+		INSERT INTO media_transcodes(id, media_id, transcode_target_id, version_id, path, size, checksum, pool, audio_stream_index, audio_language)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		task.id, task.media.ID(), task.target.ID, task.VersionID(), task.OutputPath(), size, checksum, task.Pool(), audioStreamIndex, audioLanguage,
 	); err != nil {
 		return fmt.Errorf("failed to create transcode row: %w", err)
 	}
@@ -39,6 +163,65 @@ func (store *Store) SaveTranscode(db database.Queryable, task *TranscodeTask) er
 	return nil
 }
 
+// SaveRecovered inserts the transcode, and the successful outcome of the task, described by the
+// recovery record provided (see RecoveredTranscode). Rows which already exist are left unchanged, as
+// the original save may have succeeded despite reporting an error.
+func (store *Store) SaveRecovered(db database.Queryable, record *RecoveredTranscode) error {
+	var size *int64
+	if info, err := os.Stat(record.Path); err == nil {
+		s := info.Size()
+		size = &s
+	}
+
+	var checksum *string
+	if sum, err := file.Checksum(record.Path); err != nil {
+		log.Warnf("Failed to checksum output of recovered transcode %s, checksum will not be recorded: %v\n", record.ID, err)
+	} else {
+		checksum = &sum
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO media_transcodes(id, media_id, transcode_target_id, version_id, path, size, checksum, pool)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, 0), $7, $8)
+		ON CONFLICT (id) DO NOTHING`,
+		record.ID, record.MediaID, record.TargetID, record.VersionID, record.Path, size, checksum, record.Pool,
+	); err != nil {
+		return fmt.Errorf("failed to create recovered transcode row: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO transcode_outcome(id, media_id, transcode_target_id, version_id, status, started_at, concluded_at, output_size, error, output)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULL, $9)
+		ON CONFLICT (id) DO NOTHING`,
+		record.ID, record.MediaID, record.TargetID, record.VersionID, OutcomeComplete, record.StartedAt, record.ConcludedAt, size, record.Output,
+	); err != nil {
+		return fmt.Errorf("failed to save outcome of recovered transcode %s: %w", record.ID, err)
+	}
+
+	return nil
+}
+
+// SavePassthrough inserts a row in to the database which records the source of a media as the
+// transcode of a target, as the source already satisfies the target (see Transcode.Passthrough).
+func (store *Store) SavePassthrough(db database.Queryable, transcode *Transcode) error {
+	var size int64
+	if info, err := os.Stat(transcode.MediaPath); err != nil {
+		log.Warnf("Failed to stat source %s of passthrough transcode %s, size will not be recorded: %v\n", transcode.MediaPath, transcode.ID, err)
+	} else {
+		size = info.Size()
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO media_transcodes(id, media_id, transcode_target_id, version_id, path, size, pool, passthrough)
+		VALUES ($1, $2, $3, $4, $5, $6, '', true)`,
+		transcode.ID, transcode.MediaID, transcode.TargetID, transcode.VersionID, transcode.MediaPath, size,
+	); err != nil {
+		return fmt.Errorf("failed to create passthrough transcode row: %w", err)
+	}
+
+	return nil
+}
+
 // GetAll ...
 func (store *Store) GetAll(db database.Queryable) ([]*Transcode, error) {
 	var dest []*Transcode
@@ -71,24 +254,51 @@ func (store *Store) GetForMedia(db database.Queryable, mediaID uuid.UUID) ([]*Tr
 	return dest, nil
 }
 
+// GetForMedias returns all the saved/completed transcodes associated with any
+// of the media IDs provided.
+func (store *Store) GetForMedias(db database.Queryable, mediaIDs []uuid.UUID) ([]*Transcode, error) {
+	if len(mediaIDs) == 0 {
+		return []*Transcode{}, nil
+	}
+
+	query, args, err := sqlx.In(`SELECT * FROM media_transcodes WHERE media_id IN (?)`, mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var dest []*Transcode
+	if err := db.Select(&dest, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed query for transcodes of medias %v: %w", mediaIDs, err)
+	}
+
+	return dest, nil
+}
+
 // Delete searches for and deletes the transcode with the ID provided. The path for this
-// transcode is returned from the DELETE query, allowing file-system cleanup to be performed.
+// transcode is returned from the DELETE query, allowing file-system cleanup to be performed. An
+// empty path is returned for passthrough transcodes, as their path is the source of the media.
 func (store *Store) Delete(db database.Queryable, id uuid.UUID) (string, error) {
 	var result string
-	if err := db.Get(&result, `DELETE FROM media_transcodes WHERE id=$1 RETURNING path`, id); err != nil {
+	if err := db.Get(&result, `
+		DELETE FROM media_transcodes WHERE id=$1
+		RETURNING CASE WHEN passthrough THEN '' ELSE path END`, id); err != nil {
 		return "", err
 	}
 
 	return result, nil
 }
 
-func (store *Store) GetForMediaAndTarget(db database.Queryable, mediaID uuid.UUID, targetID uuid.UUID) (*Transcode, error) {
+// GetForMediaAndTarget returns the completed transcode of the media using the target provided. If
+// a version ID is provided, only a transcode of that version is returned, otherwise only a transcode
+// of the primary source of the media is returned.
+func (store *Store) GetForMediaAndTarget(db database.Queryable, mediaID uuid.UUID, targetID uuid.UUID, versionID *uuid.UUID) (*Transcode, error) {
 	dest := &Transcode{}
 	if err := db.Get(dest, `
 		SELECT * FROM media_transcodes
 		WHERE media_id=$1
-		  AND transcode_target_id=$2`,
-		mediaID, targetID,
+		  AND transcode_target_id=$2
+		  AND version_id IS NOT DISTINCT FROM $3`,
+		mediaID, targetID, versionID,
 	); err != nil {
 		return nil, fmt.Errorf("failed to find transcode for media %s and target %s: %w", mediaID, targetID, err)
 	}
@@ -96,22 +306,302 @@ func (store *Store) GetForMediaAndTarget(db database.Queryable, mediaID uuid.UUI
 	return dest, nil
 }
 
+// SetDegraded marks the transcode with the ID provided as degraded (or not), indicating
+// whether it's output file could be found on disk.
+func (store *Store) SetDegraded(db database.Queryable, id uuid.UUID, degraded bool) error {
+	if _, err := db.Exec(`
+		UPDATE media_transcodes
+		SET degraded_at=CASE WHEN $2 THEN COALESCE(degraded_at, current_timestamp) ELSE NULL END
+		WHERE id=$1`,
+		id, degraded,
+	); err != nil {
+		return fmt.Errorf("failed to set degraded state of transcode %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// SetChecksum records the checksum of the output file of the transcode with the ID provided.
+func (store *Store) SetChecksum(db database.Queryable, id uuid.UUID, checksum string) error {
+	if _, err := db.Exec(`UPDATE media_transcodes SET checksum=$2 WHERE id=$1`, id, checksum); err != nil {
+		return fmt.Errorf("failed to set checksum of transcode %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// SetCorrupted marks the transcode with the ID provided as corrupted (or not), indicating
+// whether it's output file matched it's recorded checksum.
+func (store *Store) SetCorrupted(db database.Queryable, id uuid.UUID, corrupted bool) error {
+	if _, err := db.Exec(`
+		UPDATE media_transcodes
+		SET corrupted_at=CASE WHEN $2 THEN COALESCE(corrupted_at, current_timestamp) ELSE NULL END
+		WHERE id=$1`,
+		id, corrupted,
+	); err != nil {
+		return fmt.Errorf("failed to set corrupted state of transcode %s: %w", id, err)
+	}
+
+	return nil
+}
+
 // DeleteForMedias deletes all media transcode row associated
 // with any of the given media IDs. The paths of the deleted media
-// transcodes are returned to allow for file-system cleanup.
+// transcodes are returned to allow for file-system cleanup, with
+// passthrough transcodes returning an empty path.
 func (store *Store) DeleteForMedias(db database.Queryable, mediaIDs []uuid.UUID) ([]string, error) {
 	query, args, err := sqlx.In(`
 		DELETE FROM media_transcodes
-		WHERE media_id IN ($1)
-		RETURNING path`, mediaIDs)
+		WHERE media_id IN (?)
+		RETURNING CASE WHEN passthrough THEN '' ELSE path END`, mediaIDs)
 	if err != nil {
 		return nil, err
 	}
 
 	var result []string
-	if err := db.Select(&result, db.Rebind(query), args); err != nil {
+	if err := db.Select(&result, db.Rebind(query), args...); err != nil {
 		return nil, err
 	}
 
 	return result, nil
 }
+
+// RecordPlaybackStart records that the transcode with the ID provided has started being streamed.
+func (store *Store) RecordPlaybackStart(db database.Queryable, id uuid.UUID) error {
+	if _, err := db.Exec(`
+		INSERT INTO transcode_playback(transcode_id, starts, completions, last_played_at)
+		VALUES ($1, 1, 0, current_timestamp)
+		ON CONFLICT (transcode_id) DO UPDATE
+		SET starts=transcode_playback.starts+1, last_played_at=EXCLUDED.last_played_at`,
+		id,
+	); err != nil {
+		return fmt.Errorf("failed to record playback start of transcode %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// RecordPlaybackCompletion records that the transcode with the ID provided was streamed to completion.
+func (store *Store) RecordPlaybackCompletion(db database.Queryable, id uuid.UUID) error {
+	if _, err := db.Exec(`
+		INSERT INTO transcode_playback(transcode_id, starts, completions, last_played_at)
+		VALUES ($1, 0, 1, current_timestamp)
+		ON CONFLICT (transcode_id) DO UPDATE
+		SET completions=transcode_playback.completions+1, last_played_at=EXCLUDED.last_played_at`,
+		id,
+	); err != nil {
+		return fmt.Errorf("failed to record playback completion of transcode %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// GetTargetPopularity aggregates the playback of the transcodes of each target which has at least
+// one transcode, ordered with the most frequently played target first.
+func (store *Store) GetTargetPopularity(db database.Queryable) ([]*TargetPopularity, error) {
+	var dest []*TargetPopularity
+	if err := db.Select(&dest, `
+		SELECT t.transcode_target_id,
+		       COUNT(*) AS transcodes,
+		       COALESCE(SUM(p.starts), 0) AS starts,
+		       COALESCE(SUM(p.completions), 0) AS completions,
+		       MAX(p.last_played_at) AS last_played_at
+		FROM media_transcodes t
+		LEFT JOIN transcode_playback p ON p.transcode_id = t.id
+		GROUP BY t.transcode_target_id
+		ORDER BY starts DESC, completions DESC, t.transcode_target_id`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to aggregate target popularity: %w", err)
+	}
+
+	return dest, nil
+}
+
+// ListReclaimCandidates returns the transcodes which may be archived or evicted (i.e. all transcodes other
+// than passthrough transcodes, which reference the source of the media) along with their playback, ordered
+// by ReclaimOrder. The Size of each candidate is populated by the caller from the output file (see ReclaimCandidate).
+func (store *Store) ListReclaimCandidates(db database.Queryable) ([]*ReclaimCandidate, error) {
+	var dest []*ReclaimCandidate
+	if err := db.Select(&dest, `
+		SELECT t.id, t.media_id, t.transcode_target_id, t.path, t.pool,
+		       COALESCE(p.starts, 0) AS starts,
+		       COALESCE(p.completions, 0) AS completions,
+		       COALESCE(p.last_played_at, t.created_at) AS last_used_at
+		FROM media_transcodes t
+		LEFT JOIN transcode_playback p ON p.transcode_id = t.id
+		WHERE NOT t.passthrough`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to select reclaim candidates: %w", err)
+	}
+
+	slices.SortStableFunc(dest, ReclaimOrder)
+	return dest, nil
+}
+
+// SetLocation updates the path, and storage pool, of the transcode with the ID provided
+// after it's output file has been moved (see ReclaimConfig).
+func (store *Store) SetLocation(db database.Queryable, id uuid.UUID, path string, pool string) error {
+	if _, err := db.Exec(`UPDATE media_transcodes SET path=$2, pool=$3 WHERE id=$1`, id, path, pool); err != nil {
+		return fmt.Errorf("failed to set location of transcode %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// SaveQueueSnapshot persists the queued tasks provided so they can be restored when Thea
+// is next started (see PopQueueSnapshot). Any tasks already present in the snapshot are ignored.
+func (store *Store) SaveQueueSnapshot(db database.Queryable, tasks []QueuedTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if _, err := db.NamedExec(`
+		INSERT INTO transcode_queue_snapshot(media_id, transcode_target_id, version_id, workflow_id, created_at)
+		VALUES (:media_id, :transcode_target_id, :version_id, :workflow_id, current_timestamp)
+		ON CONFLICT DO NOTHING`,
+		tasks,
+	); err != nil {
+		return fmt.Errorf("failed to save transcode queue snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// PopQueueSnapshot deletes all of the queued tasks in the snapshot, returning the deleted rows.
+func (store *Store) PopQueueSnapshot(db database.Queryable) ([]QueuedTask, error) {
+	var result []QueuedTask
+	if err := db.Select(&result, `
+		DELETE FROM transcode_queue_snapshot
+		RETURNING media_id, transcode_target_id, version_id, workflow_id`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to pop transcode queue snapshot: %w", err)
+	}
+
+	return result, nil
+}
+
+// SaveOutcome records that the given task has concluded with the status provided, along with the
+// tail of it's ffmpeg output. These outcomes form the history of the transcode tasks, and are
+// retained even after the media/target is deleted (see GetStatistics and ListHistory).
+func (store *Store) SaveOutcome(db database.Queryable, task *TranscodeTask, status OutcomeStatus) error {
+	var outputSize *int64
+	if status == OutcomeComplete {
+		if info, err := os.Stat(task.OutputPath()); err == nil {
+			size := info.Size()
+			outputSize = &size
+		}
+	}
+
+	var taskErr *string
+	if trouble := task.Trouble(); trouble != nil && status == OutcomeFailed {
+		message := trouble.Error()
+		taskErr = &message
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO transcode_outcome(id, media_id, transcode_target_id, version_id, status, started_at, concluded_at, output_size, error, output)
+		VALUES ($1, $2, $3, $4, $5, $6, current_timestamp, $7, $8, $9)`,
+		task.id, task.media.ID(), task.target.ID, task.VersionID(), status, task.StartedAt(), outputSize, taskErr, task.Output(),
+	); err != nil {
+		return fmt.Errorf("failed to save outcome of transcode %s: %w", task.id, err)
+	}
+
+	return nil
+}
+
+// GetOutput returns the ffmpeg output recorded when the task with the ID provided concluded. If no
+// outcome exists for the task, sql.ErrNoRows is returned.
+func (store *Store) GetOutput(db database.Queryable, id uuid.UUID) (string, error) {
+	var output string
+	if err := db.Get(&output, `SELECT output FROM transcode_outcome WHERE id=$1`, id); err != nil {
+		return "", err
+	}
+
+	return output, nil
+}
+
+// GetStatistics aggregates the count and total size of all completed transcodes, as well as
+// the number of transcode tasks which succeeded/failed on each day since the time provided.
+func (store *Store) GetStatistics(db database.Queryable, since time.Time) (*Statistics, error) {
+	dest := &Statistics{}
+	if err := db.Get(dest, `
+		SELECT COUNT(*) AS transcode_count, COALESCE(SUM(size), 0) AS transcode_bytes
+		FROM media_transcodes`,
+	); err != nil {
+		return nil, fmt.Errorf("failed to aggregate transcode totals: %w", err)
+	}
+
+	if err := db.Select(&dest.History, `
+		SELECT date_trunc('day', concluded_at, 'UTC') AS day,
+		       COUNT(*) FILTER (WHERE status = 'COMPLETE') AS succeeded,
+		       COUNT(*) FILTER (WHERE status = 'FAILED') AS failed
+		FROM transcode_outcome
+		WHERE concluded_at >= $1
+		GROUP BY day
+		ORDER BY day`,
+		since,
+	); err != nil {
+		return nil, fmt.Errorf("failed to aggregate transcode outcomes since %s: %w", since, err)
+	}
+
+	return dest, nil
+}
+
+// ListHistory returns the concluded transcode tasks matching the filter provided, ordered
+// with the most recently concluded first. The limit defaults to 50, with a maximum of 500.
+func (store *Store) ListHistory(db database.Queryable, filter HistoryFilter) ([]*HistoryEntry, error) {
+	q := sq.Select("id", "media_id", "transcode_target_id", "version_id", "status", "started_at", "concluded_at", "output_size", "error").
+		From("transcode_outcome").
+		OrderBy("concluded_at DESC", "id")
+	if len(filter.Statuses) > 0 {
+		q = q.Where(sq.Eq{"status": filter.Statuses})
+	}
+	if filter.Since != nil {
+		q = q.Where(sq.GtOrEq{"concluded_at": *filter.Since})
+	}
+
+	if filter.Limit > 0 {
+		q = q.Limit(uint64(min(filter.Limit, 500)))
+	} else {
+		q = q.Limit(50)
+	}
+
+	query, args, err := q.Offset(uint64(max(filter.Offset, 0))).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transcode history query: %w", err)
+	}
+
+	var dest []*HistoryEntry
+	if err := db.Select(&dest, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to select transcode history: %w", err)
+	}
+
+	return dest, nil
+}
+
+// PruneHistory deletes the history of the transcode tasks which concluded before the time
+// provided, returning the number of entries deleted.
+func (store *Store) PruneHistory(db database.Queryable, concludedBefore time.Time) (int, error) {
+	result, err := db.Exec(`DELETE FROM transcode_outcome WHERE concluded_at < $1`, concludedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune transcode history: %w", err)
+	}
+
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(pruned), nil
+}
+
+// Duration returns how long the task took from when it was first started until it
+// concluded, or nil if the task was never started.
+func (entry *HistoryEntry) Duration() *time.Duration {
+	if entry.StartedAt == nil {
+		return nil
+	}
+
+	duration := entry.ConcludedAt.Sub(*entry.StartedAt)
+	return &duration
+}
@@ -7,12 +7,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync/atomic"
+	"time"
 
 	"github.com/floostack/transcoder"
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/ffmpeg"
 	"github.com/hbomb79/Thea/internal/media"
 	"github.com/hbomb79/Thea/pkg/logger"
+	"github.com/mitchellh/go-homedir"
 )
 
 var (
@@ -22,12 +25,14 @@ var (
 	ErrTranscodeFinishedWithNoOutput = errors.New("the ffmpeg transcoding seems to have completed, however no output can be found at the expected file path")
 	ErrCancelled                     = errors.New("the ffmpeg transcoding was cancelled (via it's context)")
 	ErrFfmpegProblem                 = errors.New("FFmpeg transcode failed")
+	ErrStalled                       = errors.New("the ffmpeg transcoding stalled (no progress was reported within the stall timeout)")
 )
 
 type Command interface {
 	Run(ctx context.Context, transcodeOptions transcoder.Options, updateHandler func(*ffmpeg.Progress)) error
 	Suspend() error
 	Continue() error
+	OutputTail() string
 }
 
 type TranscodeTaskStatus int
@@ -50,46 +55,127 @@ type TranscodeTask struct {
 	config     ffmpeg.Config
 	media      *media.Container
 	target     *ffmpeg.Target
+	version    *media.Version
 	outputPath string
 
+	// pool is the name of the storage pool the output of the task is written
+	// to, which is assigned when the task is started (see place).
+	pool string
+
 	command      Command
 	status       TranscodeTaskStatus
 	lastProgress *ffmpeg.Progress
+	trouble      *Trouble
+
+	// lastOutput is the tail of the output from the most recent
+	// ffmpeg command, retained after the command has exited.
+	lastOutput string
 
 	cancelHandle *context.CancelFunc
+
+	// watchdog stops the task if no progress is reported within the stallTimeout. The
+	// watchdog is stopped while the task is suspended. Attempts counts the number of
+	// times the task has been run, so that stalled tasks are only retried once.
+	watchdog     *time.Timer
+	stallTimeout time.Duration
+	attempts     int
+
+	// remux is true if the source already uses the codecs required by the target (or the
+	// target is remux-only), and so the task copies the streams of the source in to the
+	// container of the target (see ffmpeg.RemuxOptions).
+	remux bool
+
+	// audio is the audio stream of the source selected using the audio language preferences of
+	// the target, or nil if the target has no preferences. The stream is selected when the task
+	// is run, and is recorded against the transcode once complete.
+	audio *ffmpeg.AudioStream
+
+	// segments is the number of segments the task is transcoded in, and is zero if the
+	// task is transcoded in a single pass. Media shorter than the segmentMinimumDuration
+	// are always transcoded in a single pass.
+	segments               int
+	segmentMinimumDuration time.Duration
+
+	// startedAt is the time the task was first run, or nil if
+	// the task has not yet been started.
+	startedAt *time.Time
+
+	// requestedBy is the ID of the user which manually requested the task, or
+	// nil if the task was created automatically (e.g. by a workflow).
+	requestedBy *uuid.UUID
+
+	// important is true if the task was marked important when requested, in which
+	// case it's ffmpeg processes are run using the important priority.
+	important bool
+
+	// workflowID is the ID of the workflow which queued the task, or nil if the task
+	// was not queued by a workflow. The hooks of the workflow are executed once the
+	// task completes (see runWorkflowHooks).
+	workflowID *uuid.UUID
+
+	// log is scoped to this task, so that all log lines emitted
+	// while processing the task can be correlated.
+	log logger.Logger
 }
 
-func NewTranscodeTask(m *media.Container, t *ffmpeg.Target, config ffmpeg.Config) (*TranscodeTask, error) {
-	dir := filepath.Join(config.GetOutputBaseDirectory(), m.ID().String(), t.ID.String())
-	if err := os.MkdirAll(filepath.Dir(dir), 0o777); err != nil {
-		log.Errorf("Failed to create required directories (%s) for transcoding output: %v\n", filepath.Dir(dir), err)
-		return nil, ErrPathDirectoryCreation
+// NewTranscodeTask creates a new task which will transcode the media provided using the target
+// given. If a version is provided, the source of that version is transcoded instead of the primary
+// source of the media. Any logging fields stored in the context provided (e.g. the ID of the request
+// which created the task) will be included in the tasks log lines. If the stall timeout provided
+// is non-zero, the task is stopped if ffmpeg reports no progress for that period of time.
+func NewTranscodeTask(ctx context.Context, m *media.Container, version *media.Version, t *ffmpeg.Target, config ffmpeg.Config, stallTimeout time.Duration) (*TranscodeTask, error) {
+	if !ffmpeg.SupportsExtension(t.Ext) {
+		return nil, ErrTargetExtensionInvalid
 	}
 
-	// TODO: expand this to support other formats, but for now, let's keep it simple
-	if t.Ext != "mp4" {
-		return nil, ErrTargetExtensionInvalid
+	outputPath := outputPathIn(config.GetOutputBaseDirectory(), m, version, t)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o777); err != nil {
+		log.WithContext(ctx).Errorf("Failed to create required directories (%s) for transcoding output: %v\n", filepath.Dir(outputPath), err)
+		return nil, ErrPathDirectoryCreation
 	}
 
+	id := uuid.New()
 	return &TranscodeTask{
-		id:           uuid.New(),
+		id:           id,
 		media:        m,
 		target:       t,
+		version:      version,
 		lastProgress: nil,
-		outputPath:   fmt.Sprintf("%s.%s", dir, t.Ext),
+		outputPath:   outputPath,
 		command:      nil,
 		config:       config,
 		status:       WAITING,
+		stallTimeout: stallTimeout,
+		log:          log.WithContext(ctx).WithFields(logger.Fields{"transcode_id": id, "media_id": m.ID(), "target_id": t.ID}),
 	}, nil
 }
 
+// place assigns the storage pool provided to the task, so that the output of the task is written
+// inside of the pool. This must only be called while the task is not running.
+func (task *TranscodeTask) place(pool *StoragePool) error {
+	baseDir, err := homedir.Expand(pool.Path)
+	if err != nil {
+		baseDir = pool.Path
+	}
+
+	outputPath := outputPathIn(baseDir, task.media, task.version, task.target)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o777); err != nil {
+		task.log.Errorf("Failed to create required directories (%s) for transcoding output in storage pool %s: %v\n", filepath.Dir(outputPath), pool.Name, err)
+		return ErrPathDirectoryCreation
+	}
+
+	task.pool = pool.Name
+	task.outputPath = outputPath
+	return nil
+}
+
 func (task *TranscodeTask) Run(parentCtx context.Context, updateHandler func(*ffmpeg.Progress)) error {
-	log.Emit(logger.NEW, "Initializing transcoding pipeline for task %s\n", task)
+	task.log.Emit(logger.NEW, "Initializing transcoding pipeline for task %s\n", task)
 	if task.command != nil {
 		return errors.New("cannot start transcode task because a command is already set (conflict)")
 	}
 
-	if _, err := os.Stat(task.media.Source()); err != nil {
+	if _, err := os.Stat(task.Source()); err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return ErrMediaSourceNotFound
 		} else {
@@ -101,12 +187,13 @@ func (task *TranscodeTask) Run(parentCtx context.Context, updateHandler func(*ff
 		// Ensure we clear the output path if there's one present already. (if we're running this task then
 		// previous checks to ensure a duplicate transcode entity have been done already, so a duplicate FILE
 		// likely indicates some cleanup failed and this file should be considered unwelcome).
-		log.Warnf("Transcode %s is expected to output to %s, however a file is already present. Removing file\n", task, task.outputPath)
+		task.log.Warnf("Transcode %s is expected to output to %s, however a file is already present. Removing file\n", task, task.outputPath)
 		_ = os.Remove(task.outputPath)
 	}
 
-	task.command = ffmpeg.NewCmd(task.media.Source(), task.outputPath, task.config)
+	task.command = task.newCommand()
 	defer func() {
+		task.lastOutput = task.command.OutputTail()
 		task.command = nil
 		task.lastProgress = nil
 		task.cancelHandle = nil
@@ -114,22 +201,63 @@ func (task *TranscodeTask) Run(parentCtx context.Context, updateHandler func(*ff
 
 	ctx, cancel := context.WithCancel(parentCtx)
 	task.cancelHandle = &cancel
+	task.attempts++
+	if task.startedAt == nil {
+		now := time.Now()
+		task.startedAt = &now
+	}
+
+	// The watchdog is reset every time ffmpeg reports progress. If it fires, then
+	// the command is stopped (by cancelling it's context) and the task is
+	// considered stalled.
+	stalled := &atomic.Bool{}
+	if task.stallTimeout > 0 {
+		task.watchdog = time.AfterFunc(task.stallTimeout, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer task.watchdog.Stop()
+	}
+	progressHandler := func(progress *ffmpeg.Progress) {
+		task.resetWatchdog()
+		updateHandler(progress)
+	}
 
 	task.status = WORKING
-	err := task.command.Run(ctx, task.target.FfmpegOptions, updateHandler)
-	if err != nil {
+	task.audio = ffmpeg.SelectTargetAudio(task.Source(), task.config.FfprobeBinPath, task.target)
+	if task.audio != nil {
+		task.log.Infof("Selected audio stream %s of %s using language preferences %v of target %s\n", task.audio, task.Source(), task.target.AudioLanguages, task.target)
+	}
+
+	options := ffmpeg.WithAudioStream(task.target.FfmpegOptions, task.audio)
+	if task.remux {
+		options = ffmpeg.ProbeRemuxOptions(task.Source(), task.config.FfprobeBinPath, task.target.Ext, task.audio)
+	}
+	err := task.command.Run(ctx, options, progressHandler)
+	if stalled.Load() {
+		stallErr := fmt.Errorf("%w: no progress reported for %s", ErrStalled, task.stallTimeout)
 		task.status = TROUBLED
-		return fmt.Errorf("%w: %w", ErrFfmpegProblem, err)
+		task.trouble = &Trouble{error: stallErr, tType: Stalled, output: task.command.OutputTail()}
+		task.cleanup()
+		return stallErr
 	}
 
 	if ctx.Err() != nil {
-		// Task was stopped because the context was cancelled,
+		// Task was stopped because the context was cancelled. This is checked
+		// before the command error, as the command will have been killed.
 		task.status = CANCELLED
 		task.cleanup()
 		return ErrCancelled
 	}
 
-	log.Infof("Transcode %s closed/finished with no error, validating output...\n", task)
+	if err != nil {
+		ffmpegErr := fmt.Errorf("%w: %w", ErrFfmpegProblem, err)
+		task.status = TROUBLED
+		task.trouble = newFfmpegTrouble(ffmpegErr, task.command.OutputTail())
+		return ffmpegErr
+	}
+
+	task.log.Infof("Transcode %s closed/finished with no error, validating output...\n", task)
 	// Before we blindly mark this transcode as completed, we should do some rudimentary checks
 	// to ensure the transcode was ACTUALLY as we expected. For now, let's just check if a file exists and
 	// is of non-zero size.
@@ -137,18 +265,73 @@ func (task *TranscodeTask) Run(parentCtx context.Context, updateHandler func(*ff
 	// such that we can assert the runtime of the output matches. This is much more rigorous, but will take
 	// a fair bit of work so it's a later-me thing.
 	if _, err := os.Stat(task.outputPath); err != nil {
-		task.status = TROUBLED
+		outputErr := fmt.Errorf("unexpected error occurred when validation ffmpeg transcode output (path = %s): %w", task.outputPath, err)
 		if errors.Is(err, fs.ErrNotExist) {
-			return ErrTranscodeFinishedWithNoOutput
-		} else {
-			return fmt.Errorf("unexpected error occurred when validation ffmpeg transcode output (path = %s): %w", task.outputPath, err)
+			outputErr = ErrTranscodeFinishedWithNoOutput
 		}
+
+		task.status = TROUBLED
+		task.trouble = &Trouble{error: outputErr, tType: UnknownFailure, output: task.command.OutputTail()}
+		return outputErr
 	}
 
 	task.status = COMPLETE
 	return nil
 }
 
+// newCommand creates the ffmpeg command used to run the task. If the task is segmented, and the
+// source is long enough, a segmented command is used. Otherwise, the task is transcoded in a
+// single pass.
+func (task *TranscodeTask) newCommand() Command {
+	if task.segments < 2 {
+		return ffmpeg.NewCmd(task.Source(), task.outputPath, task.config)
+	}
+
+	duration, err := ffmpeg.ProbeDuration(task.Source(), task.config.FfprobeBinPath)
+	if err != nil {
+		task.log.Warnf("Unable to determine duration of %s, falling back to single-pass transcode: %v\n", task.Source(), err)
+		return ffmpeg.NewCmd(task.Source(), task.outputPath, task.config)
+	} else if duration < task.segmentMinimumDuration {
+		return ffmpeg.NewCmd(task.Source(), task.outputPath, task.config)
+	}
+
+	task.log.Infof("Transcoding %s in %d segments\n", task, task.segments)
+	return ffmpeg.NewSegmentedCmd(task.Source(), task.outputPath, task.config, duration, task.segments)
+}
+
+// segment configures the task to be transcoded in segments, using as many segments as allowed by
+// the configuration and thread budget provided. If the target of the task cannot be segmented, or
+// the thread budget only allows for a single segment, the task is transcoded in a single pass.
+func (task *TranscodeTask) segment(config SegmentationConfig, threadBudget int) {
+	if !config.Enabled || task.remux {
+		return
+	}
+
+	if !ffmpeg.SupportsSegmentation(task.target) {
+		task.log.Debugf("Target %s cannot be segmented, %s will be transcoded in a single pass\n", task.target, task)
+		return
+	}
+
+	segments := min(config.Segments, threadBudget/task.target.RequiredThreads())
+	if segments < 2 {
+		return
+	}
+
+	task.segments = segments
+	task.segmentMinimumDuration = config.MinimumDuration
+}
+
+// requiredThreads returns the number of threads consumed by the task, which
+// for segmented tasks is the number consumed by each of it's segments. Remuxing
+// tasks only consume a single thread, as they do not re-encode the source.
+func (task *TranscodeTask) requiredThreads() int {
+	if task.remux {
+		return 1
+	}
+
+	return task.target.RequiredThreads() * max(1, task.segments)
+}
+
 // Cancel will interrupt any running transcode, cleaning up any partially transcoded output
 // if applicable.
 func (task *TranscodeTask) cancel() error {
@@ -171,6 +354,10 @@ func (task *TranscodeTask) pause() error {
 		return err
 	}
 
+	if task.watchdog != nil {
+		task.watchdog.Stop()
+	}
+
 	task.status = SUSPENDED
 	return nil
 }
@@ -185,12 +372,20 @@ func (task *TranscodeTask) resume() error {
 	}
 
 	task.status = WORKING
+	task.resetWatchdog()
 	return nil
 }
 
+// resetWatchdog restarts the stall timeout of a WORKING task.
+func (task *TranscodeTask) resetWatchdog() {
+	if task.watchdog != nil && task.status == WORKING {
+		task.watchdog.Reset(task.stallTimeout)
+	}
+}
+
 func (task *TranscodeTask) cleanup() {
 	if err := os.Remove(task.outputPath); err != nil {
-		log.Errorf("failed to clean-up partially transcoded media after task %s cancellation: %v", task, err)
+		task.log.Errorf("failed to clean-up partially transcoded media after task %s cancellation: %v", task, err)
 	}
 }
 
@@ -201,9 +396,69 @@ func (task *TranscodeTask) LastProgress() *ffmpeg.Progress { return task.lastPro
 func (task *TranscodeTask) ID() uuid.UUID                  { return task.id }
 func (task *TranscodeTask) Media() *media.Container        { return task.media }
 func (task *TranscodeTask) Target() *ffmpeg.Target         { return task.target }
+func (task *TranscodeTask) Version() *media.Version        { return task.version }
 func (task *TranscodeTask) OutputPath() string             { return task.outputPath }
 func (task *TranscodeTask) Status() TranscodeTaskStatus    { return task.status }
-func (task *TranscodeTask) Trouble() any                   { return nil }
+func (task *TranscodeTask) Trouble() *Trouble              { return task.trouble }
+func (task *TranscodeTask) StartedAt() *time.Time          { return task.startedAt }
+func (task *TranscodeTask) Pool() string                   { return task.pool }
+func (task *TranscodeTask) RequestedBy() *uuid.UUID        { return task.requestedBy }
+
+// Output returns the tail of the ffmpeg output for this task. If the task is not
+// running, the output of the most recent run (if any) is returned.
+func (task *TranscodeTask) Output() string {
+	if cmd := task.command; cmd != nil {
+		return cmd.OutputTail()
+	}
+
+	return task.lastOutput
+}
+
+// Source returns the path of the file being transcoded, which is the source of the
+// tasks version if one was provided, or the primary source of the media otherwise.
+func (task *TranscodeTask) Source() string {
+	return sourcePath(task.media, task.version)
+}
+
+// IsRemux returns true if the task remuxes the source, rather than re-encoding it.
+func (task *TranscodeTask) IsRemux() bool { return task.remux }
+
+// IsImportant returns true if the task was marked important when it was requested.
+func (task *TranscodeTask) IsImportant() bool { return task.important }
+
+// VersionID returns the ID of the version being transcoded, or nil if the
+// primary source of the media is being transcoded.
+func (task *TranscodeTask) VersionID() *uuid.UUID {
+	if task.version == nil {
+		return nil
+	}
+
+	return &task.version.ID
+}
+
+// sourcePath returns the path of the source of the version provided, or the
+// primary source of the media if the version is nil.
+func sourcePath(m *media.Container, version *media.Version) string {
+	if version != nil {
+		return version.SourcePath
+	}
+
+	return m.Source()
+}
+
+// outputPathIn returns the path, inside of the base directory provided, that the output of a
+// transcode of the media (and optionally version) using the target given is written to. Media
+// belonging to a library is placed inside of the library's output directory.
+func outputPathIn(baseDir string, m *media.Container, version *media.Version, t *ffmpeg.Target) string {
+	baseDir = filepath.Join(baseDir, m.OutputDirectory)
+	dir := filepath.Join(baseDir, m.ID().String(), t.ID.String())
+	if version != nil {
+		dir = filepath.Join(baseDir, m.ID().String(), version.ID.String(), t.ID.String())
+	}
+
+	return fmt.Sprintf("%s.%s", dir, t.Ext)
+}
+
 func (task *TranscodeTask) String() string {
 	return fmt.Sprintf("Task{ID=%s MediaID=%s TargetID=%s Status=%s OutputPath=%s}", task.id, task.media.ID(), task.target.ID, task.status, task.outputPath)
 }
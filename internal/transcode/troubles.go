@@ -0,0 +1,118 @@
+package transcode
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+type (
+	// TroubleType describes why a task is unable to progress. Unlike a TROUBLED status (which
+	// indicates the task has failed), a trouble may be present on a task which is still
+	// waiting to be started, and is cleared automatically once the problem is resolved.
+	TroubleType int
+	Trouble     struct {
+		error
+		tType TroubleType
+
+		// output is the tail of the ffmpeg output at the time the trouble
+		// occurred, if applicable.
+		output string
+	}
+
+	// RetryPolicy is the number of times a task which failed with each type of trouble
+	// is automatically re-queued before it is considered failed. Failures which are
+	// unlikely to resolve themselves (e.g. a missing codec) are not retried by default.
+	RetryPolicy struct {
+		Stalled               int `toml:"stalled" env:"FORMAT_RETRY_STALLED" env-default:"1"`
+		InsufficientDiskSpace int `toml:"insufficient_disk_space" env:"FORMAT_RETRY_INSUFFICIENT_DISK_SPACE" env-default:"1"`
+		ResourcesExhausted    int `toml:"resources_exhausted" env:"FORMAT_RETRY_RESOURCES_EXHAUSTED" env-default:"1"`
+		MissingCodec          int `toml:"missing_codec" env:"FORMAT_RETRY_MISSING_CODEC" env-default:"0"`
+		CorruptInput          int `toml:"corrupt_input" env:"FORMAT_RETRY_CORRUPT_INPUT" env-default:"0"`
+		PermissionDenied      int `toml:"permission_denied" env:"FORMAT_RETRY_PERMISSION_DENIED" env-default:"0"`
+		UnknownFailure        int `toml:"unknown_failure" env:"FORMAT_RETRY_UNKNOWN_FAILURE" env-default:"0"`
+	}
+)
+
+const (
+	InsufficientDiskSpace TroubleType = iota
+	Stalled
+	MissingCodec
+	CorruptInput
+	PermissionDenied
+	ResourcesExhausted
+	UnknownFailure
+)
+
+// troubleOutputPatterns maps the (lower-case) messages ffmpeg emits for
+// common failures to the type of trouble they indicate. The patterns are
+// checked in order, and the first match wins.
+var troubleOutputPatterns = []struct {
+	pattern string
+	tType   TroubleType
+}{
+	{"no space left on device", InsufficientDiskSpace},
+	{"permission denied", PermissionDenied},
+	{"operation not permitted", PermissionDenied},
+	{"read-only file system", PermissionDenied},
+	{"cannot allocate memory", ResourcesExhausted},
+	{"out of memory", ResourcesExhausted},
+	{"unknown encoder", MissingCodec},
+	{"unknown decoder", MissingCodec},
+	{") not found for", MissingCodec}, // e.g. 'Encoder (codec hevc) not found for output stream #0:0'
+	{"not currently supported in container", MissingCodec},
+	{"invalid data found when processing input", CorruptInput},
+	{"moov atom not found", CorruptInput},
+	{"error while decoding", CorruptInput},
+	{"invalid nal unit", CorruptInput},
+	{"corrupt", CorruptInput},
+}
+
+// newFfmpegTrouble classifies the failure of an ffmpeg command using the error it returned, and
+// the tail of it's output. An ffmpeg process which was killed (e.g. by the OOM killer) is
+// considered to have exhausted the available resources.
+func newFfmpegTrouble(err error, output string) *Trouble {
+	lowerOutput := strings.ToLower(output)
+	for _, p := range troubleOutputPatterns {
+		if strings.Contains(lowerOutput, p.pattern) {
+			return &Trouble{error: err, tType: p.tType, output: output}
+		}
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGKILL {
+			return &Trouble{error: err, tType: ResourcesExhausted, output: output}
+		}
+	}
+
+	return &Trouble{error: err, tType: UnknownFailure, output: output}
+}
+
+func (t *Trouble) Type() TroubleType { return t.tType }
+func (t *Trouble) Output() string    { return t.output }
+
+// Retries returns the number of times a task which failed with the trouble type
+// provided should be retried.
+func (policy RetryPolicy) Retries(tType TroubleType) int {
+	//exhaustive:enforce
+	switch tType {
+	case InsufficientDiskSpace:
+		return policy.InsufficientDiskSpace
+	case Stalled:
+		return policy.Stalled
+	case MissingCodec:
+		return policy.MissingCodec
+	case CorruptInput:
+		return policy.CorruptInput
+	case PermissionDenied:
+		return policy.PermissionDenied
+	case ResourcesExhausted:
+		return policy.ResourcesExhausted
+	case UnknownFailure:
+		return policy.UnknownFailure
+	}
+
+	return 0
+}
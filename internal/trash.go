@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/hbomb79/Thea/pkg/logger"
+)
+
+type (
+	trashPurger interface {
+		PurgeTrash(trashedBefore time.Time) (int, error)
+	}
+
+	// trashJanitor periodically purges media which has been in the
+	// trash for longer than the configured retention window.
+	trashJanitor struct {
+		config TrashConfig
+		store  trashPurger
+	}
+)
+
+func newTrashJanitor(config TrashConfig, store trashPurger) *trashJanitor {
+	return &trashJanitor{config: config, store: store}
+}
+
+func (janitor *trashJanitor) Run(ctx context.Context) error {
+	if janitor.config.PurgeInterval <= 0 {
+		log.Emit(logger.WARNING, "Trash purge interval is not positive, trashed media will never be purged\n")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(janitor.config.PurgeInterval)
+	defer ticker.Stop()
+
+	log.Emit(logger.NEW, "Trash janitor started (retention=%s)\n", janitor.config.Retention)
+	for {
+		janitor.purge()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Emit(logger.STOP, "Trash janitor closed\n")
+			return nil
+		}
+	}
+}
+
+// purge permanently deletes all trashed media whose retention window
+// has elapsed. Failures are logged, and will be retried on the next tick.
+func (janitor *trashJanitor) purge() {
+	purged, err := janitor.store.PurgeTrash(time.Now().Add(-janitor.config.Retention))
+	if err != nil {
+		log.Errorf("Failed to purge expired trash: %v\n", err)
+		return
+	}
+
+	if purged > 0 {
+		log.Emit(logger.REMOVE, "Purged %d expired media from the trash\n", purged)
+	}
+}
@@ -1,62 +1,115 @@
 package user
 
 import (
-	"bytes"
 	"crypto/rand"
+	"crypto/subtle"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"golang.org/x/crypto/argon2"
 )
 
+const (
+	hashKeyLen  = 32
+	hashSaltLen = 16
+)
+
+// legacyHashParams are the parameters used to hash passwords before the parameters were
+// configurable (and recorded alongside each hash). Hashes without recorded parameters were
+// generated using these, and are transparently re-hashed when the user next logs in.
+var legacyHashParams = HashParams{Time: 1, Memory: 64 * 1024, Threads: 1, KeyLen: 128, SaltLen: 64}
+
 type (
-	argonHasher struct {
-		time    uint32
-		memory  uint32
-		threads uint8
-		keyLen  uint32
-		saltLen uint32
+	// HashConfig contains the Argon2id cost parameters used to hash passwords. The defaults are
+	// the minimums recommended by OWASP. The memory cost may be lowered on low-power hardware if
+	// logins are slow, at the expense of resistance to brute-forcing. Existing hashes are
+	// re-hashed using the configured parameters when their user next logs in.
+	HashConfig struct {
+		Time      uint32 `toml:"time_cost" env:"PASSWORD_HASH_TIME_COST" env-default:"2"`
+		MemoryKiB uint32 `toml:"memory_kib" env:"PASSWORD_HASH_MEMORY_KIB" env-default:"19456"`
+		Threads   uint8  `toml:"threads" env:"PASSWORD_HASH_THREADS" env-default:"1"`
+	}
+
+	// HashParams are the Argon2id parameters a password hash was generated with,
+	// which are stored alongside the hash so that it can be verified.
+	HashParams struct {
+		Time    uint32 `json:"time"`
+		Memory  uint32 `json:"memory"`
+		Threads uint8  `json:"threads"`
+		KeyLen  uint32 `json:"key_len"`
+		SaltLen uint32 `json:"salt_len"`
 	}
 
 	hashAndSalt struct {
-		hash []byte
-		salt []byte
+		hash   []byte
+		salt   []byte
+		params HashParams
 	}
 )
 
-func newArgon2IdHasher(time, saltLen uint32, memory uint32, threads uint8, keyLen uint32) *argonHasher {
-	return &argonHasher{time: time, saltLen: saltLen, memory: memory, threads: threads, keyLen: keyLen}
+// Validate returns an error if the hash config is not valid.
+func (config HashConfig) Validate() error {
+	if config.Time < 1 {
+		return fmt.Errorf("time_cost: must be at least 1, got %d", config.Time)
+	}
+	if config.Threads < 1 {
+		return fmt.Errorf("threads: must be at least 1, got %d", config.Threads)
+	}
+	if config.MemoryKiB < 8*uint32(config.Threads) {
+		return fmt.Errorf("memory_kib: must be at least 8 KiB per thread, got %d", config.MemoryKiB)
+	}
+
+	return nil
 }
 
-// GenerateHash using the password and provided salt.
-// If not salt value provided fallback to random value
-// generated of a given length.
-func (a *argonHasher) GenerateHash(password, salt []byte) (*hashAndSalt, error) {
-	var err error
-	// If salt is not provided generate a salt of
-	// the configured salt length.
-	if len(salt) == 0 {
-		salt, err = randomSecret(a.saltLen)
-	}
-	if err != nil {
-		return nil, err
+func (config HashConfig) params() HashParams {
+	return HashParams{Time: config.Time, Memory: config.MemoryKiB, Threads: config.Threads, KeyLen: hashKeyLen, SaltLen: hashSaltLen}
+}
+
+// Scan scan value into Jsonb, implements sql.Scanner interface.
+func (params *HashParams) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New(fmt.Sprint("Failed to unmarshal JSONB value:", value))
 	}
-	hash := argon2.IDKey(password, salt, a.time, a.memory, a.threads, a.keyLen)
-	return &hashAndSalt{hash, salt}, nil
+
+	result := HashParams{}
+	err := json.Unmarshal(bytes, &result)
+	*params = result
+	return err
+}
+
+// Value return json value, implement driver.Valuer interface.
+func (params HashParams) Value() (driver.Value, error) {
+	return json.Marshal(params)
 }
 
-// Compare generated hash with store hash.
-func (a *argonHasher) Compare(hash, salt, password []byte) error {
-	hashSalt, err := a.GenerateHash(password, salt)
+// GenerateHash hashes the password provided using a random salt.
+func (params HashParams) GenerateHash(password []byte) (*hashAndSalt, error) {
+	salt, err := randomSecret(params.SaltLen)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if !bytes.Equal(hash, hashSalt.hash) {
+	return &hashAndSalt{params.hash(password, salt), salt, params}, nil
+}
+
+// Compare returns an error if the password provided does not produce
+// the hash given when hashed using the salt (and these parameters).
+func (params HashParams) Compare(hash, salt, password []byte) error {
+	if subtle.ConstantTimeCompare(hash, params.hash(password, salt)) != 1 {
 		return errors.New("hash doesn't match")
 	}
+
 	return nil
 }
 
+func (params HashParams) hash(password, salt []byte) []byte {
+	return argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+}
+
 // randomSecret generates a random byte slice of the
 // requested length. This is used to create random
 // salts for the hashing of passwords.
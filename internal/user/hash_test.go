@@ -0,0 +1,33 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HashParams_RoundTrip(t *testing.T) {
+	t.Parallel()
+	params := HashConfig{Time: 1, MemoryKiB: 64, Threads: 1}.params()
+
+	hash, err := params.GenerateHash([]byte("hunter2"))
+	assert.NoError(t, err)
+	assert.Len(t, hash.hash, hashKeyLen)
+	assert.Len(t, hash.salt, hashSaltLen)
+	assert.Equal(t, params, hash.params)
+
+	assert.NoError(t, params.Compare(hash.hash, hash.salt, []byte("hunter2")))
+	assert.Error(t, params.Compare(hash.hash, hash.salt, []byte("hunter3")))
+
+	// Hashes must be verified using the parameters they were generated with
+	other := HashConfig{Time: 2, MemoryKiB: 64, Threads: 1}.params()
+	assert.Error(t, other.Compare(hash.hash, hash.salt, []byte("hunter2")))
+}
+
+func Test_HashConfig_Validate(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, HashConfig{Time: 2, MemoryKiB: 19456, Threads: 1}.Validate())
+	assert.Error(t, HashConfig{Time: 0, MemoryKiB: 19456, Threads: 1}.Validate())
+	assert.Error(t, HashConfig{Time: 2, MemoryKiB: 19456, Threads: 0}.Validate())
+	assert.Error(t, HashConfig{Time: 2, MemoryKiB: 15, Threads: 2}.Validate())
+}
@@ -0,0 +1,198 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/jmoiron/sqlx"
+)
+
+const inviteTokenLength = 32
+
+var (
+	ErrInviteNotFound = errors.New("invite does not exist")
+	ErrInviteInvalid  = errors.New("invite is invalid, expired or has already been used")
+)
+
+type (
+	inviteBase struct {
+		ID         uuid.UUID  `db:"id"`
+		CreatedAt  time.Time  `db:"created_at"`
+		ExpiresAt  time.Time  `db:"expires_at"`
+		ConsumedAt *time.Time `db:"consumed_at"`
+		TokenHash  string     `db:"token_hash" json:"-"`
+		CreatedBy  *uuid.UUID `db:"created_by"`
+		ConsumedBy *uuid.UUID `db:"consumed_by"`
+	}
+
+	// inviteModel is a combination of the invites table columns, combined with
+	// a JSON representation of the coalesced permission and role rows which are
+	// joined in to the query.
+	inviteModel struct {
+		inviteBase
+		Permissions database.JSONColumn[[]string]    `db:"permissions"`
+		Roles       database.JSONColumn[[]uuid.UUID] `db:"roles"`
+	}
+
+	// Invite allows a new user to register themselves with Thea. Once
+	// consumed, the new user is granted the permissions and roles
+	// the invite is bound to. Invites can only be used once.
+	Invite struct {
+		inviteBase
+		Permissions []string
+		Roles       []uuid.UUID
+	}
+)
+
+// NewInviteToken generates a new random token which can be used to
+// consume an invite. Only the hash of this token is persisted, so the
+// token must be returned to the caller when the invite is created.
+func NewInviteToken() (string, error) {
+	token := make([]byte, inviteTokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate invite token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func (store *Store) CreateInvite(db database.Queryable, inviteID uuid.UUID, token string, createdBy uuid.UUID, expiresAt time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO invites(id, created_at, expires_at, consumed_at, token_hash, created_by, consumed_by)
+		VALUES ($1, current_timestamp, $2, NULL, $3, $4, NULL)
+	`, inviteID, expiresAt, hashInviteToken(token), createdBy)
+	if err != nil {
+		return fmt.Errorf("failed to insert new invite: %w", err)
+	}
+
+	return nil
+}
+
+func (store *Store) InsertInvitePermissions(db database.Queryable, inviteID uuid.UUID, permissions []Permission) error {
+	_, err := db.NamedExec(`
+		INSERT INTO invites_permissions(invite_id, permission_id)
+		VALUES('`+inviteID.String()+`', :id)
+		ON CONFLICT(invite_id, permission_id) DO NOTHING
+	`, permissions)
+	return err
+}
+
+func (store *Store) InsertInviteRoles(db database.Queryable, inviteID uuid.UUID, roleIDs []uuid.UUID) error {
+	type assoc struct {
+		InviteID uuid.UUID `db:"invite_id"`
+		RoleID   uuid.UUID `db:"role_id"`
+	}
+
+	assocs := make([]assoc, len(roleIDs))
+	for k, v := range roleIDs {
+		assocs[k] = assoc{inviteID, v}
+	}
+
+	_, err := db.NamedExec(`
+		INSERT INTO invites_roles(invite_id, role_id)
+		VALUES(:invite_id, :role_id)
+		ON CONFLICT(invite_id, role_id) DO NOTHING
+	`, assocs)
+	return err
+}
+
+// ConsumeInviteTx marks the invite matching the token provided as consumed, and
+// returns the consumed invite. If no invite matches the token, or the matching invite
+// has expired or already been consumed, then ErrInviteInvalid is returned.
+//
+// NOTE: This action is intended to be used as part of an over-arching transaction; user-story
+// for consuming an invite should include the creation of the user and granting of permissions.
+func (store *Store) ConsumeInviteTx(tx *sqlx.Tx, token string) (*Invite, error) {
+	var inviteID uuid.UUID
+	if err := tx.Get(&inviteID, `
+		UPDATE invites
+		SET consumed_at=current_timestamp
+		WHERE token_hash=$1 AND consumed_at IS NULL AND expires_at > current_timestamp
+		RETURNING id
+	`, hashInviteToken(token)); err != nil {
+		return nil, ErrInviteInvalid
+	}
+
+	return store.GetInvite(tx, inviteID)
+}
+
+// RecordInviteConsumer records the user which was created using the invite provided.
+func (store *Store) RecordInviteConsumer(db database.Queryable, inviteID uuid.UUID, userID uuid.UUID) error {
+	_, err := db.Exec(`UPDATE invites SET consumed_by=$2 WHERE id=$1`, inviteID, userID)
+	return err
+}
+
+func (store *Store) GetInvite(db database.Queryable, inviteID uuid.UUID) (*Invite, error) {
+	query, args, err := selectInviteBuilder().Where("invites.id=?", inviteID).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct select invite query: %w", err)
+	}
+
+	var invite inviteModel
+	if err := db.Get(&invite, db.Rebind(query), args...); err != nil {
+		return nil, ErrInviteNotFound
+	}
+
+	return inviteModelToInvite(&invite), nil
+}
+
+func (store *Store) ListInvites(db database.Queryable) ([]*Invite, error) {
+	query, args, err := selectInviteBuilder().ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct list invites query: %w", err)
+	}
+
+	var results []inviteModel
+	if err := db.Select(&results, query, args...); err != nil {
+		return nil, err
+	}
+
+	output := make([]*Invite, len(results))
+	for i := range results {
+		output[i] = inviteModelToInvite(&results[i])
+	}
+
+	return output, nil
+}
+
+func (store *Store) DeleteInvite(db database.Queryable, inviteID uuid.UUID) error {
+	_, err := db.Exec(`DELETE FROM invites WHERE id=$1`, inviteID)
+	return err
+}
+
+func selectInviteBuilder() squirrel.SelectBuilder {
+	return squirrel.
+		Select(
+			"invites.*",
+			"COALESCE(JSONB_AGG(DISTINCT permissions.label) FILTER (WHERE permissions.id IS NOT NULL), '[]') AS permissions",
+			"COALESCE(JSONB_AGG(DISTINCT invites_roles.role_id) FILTER (WHERE invites_roles.role_id IS NOT NULL), '[]') AS roles",
+		).
+		From("invites").
+		LeftJoin("invites_permissions ON invites_permissions.invite_id = invites.id").
+		LeftJoin("permissions ON permissions.id = invites_permissions.permission_id").
+		LeftJoin("invites_roles ON invites_roles.invite_id = invites.id").
+		GroupBy("invites.id")
+}
+
+func inviteModelToInvite(model *inviteModel) *Invite {
+	return &Invite{
+		inviteBase:  model.inviteBase,
+		Permissions: *model.Permissions.Get(),
+		Roles:       *model.Roles.Get(),
+	}
+}
+
+// hashInviteToken returns the hex-encoded SHA256 hash of the token provided. Invite
+// tokens are generated with enough entropy that a salt is not required.
+func hashInviteToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -7,10 +7,13 @@ const (
 	PollNewIngestsPermission         string = "ingest:poll"
 
 	AccessMediaPermission           string = "media:access"
+	EditMediaPermission             string = "media:modify"
 	DeleteMediaPermission           string = "media:delete"
 	StreamTranscodedMediaPermission string = "media:stream.pre"
 	StreamSourceMediaPermission     string = "media:stream.source"
 	StreamOnTheFlyMediaPermission   string = "media:stream.otf"
+	AccessAllLibrariesPermission    string = "media:library.all"
+	ShareMediaPermission            string = "media:share"
 
 	CreateTranscodePermission string = "transcode:create"
 	AccessTranscodePermission string = "transcode:access"
@@ -26,11 +29,33 @@ const (
 	AccessWorkflowPermission string = "workflow:access"
 	EditWorkflowPermission   string = "workflow:modify"
 	DeleteWorkflowPermission string = "workflow:delete"
+	// ManageWorkflowHooksPermission is required to set the hooks of a workflow, as
+	// hooks execute arbitrary commands on the host once transcodes complete.
+	ManageWorkflowHooksPermission string = "workflow:hooks"
 
 	CreateUserPermission          string = "user:create"
 	AccessUserPermission          string = "user:access"
 	EditUserPermissionsPermission string = "user:modify"
 	DeleteUserPermission          string = "user:delete"
+
+	CreateRolePermission string = "role:create"
+	AccessRolePermission string = "role:access"
+	EditRolePermission   string = "role:modify"
+	DeleteRolePermission string = "role:delete"
+
+	CreateInvitePermission string = "invite:create"
+	AccessInvitePermission string = "invite:access"
+	DeleteInvitePermission string = "invite:delete"
+
+	ReadAuditPermission string = "audit:read"
+
+	ReadDebugPermission string = "debug:read"
+
+	ReadStatisticsPermission string = "statistics:read"
+
+	CreateBackupPermission   string = "system:backup"
+	ReadSystemPermission     string = "system:read"
+	MaintainSystemPermission string = "system:maintain"
 )
 
 func All() []string {
@@ -40,10 +65,13 @@ func All() []string {
 		DeleteIngestsPermission,
 		PollNewIngestsPermission,
 		AccessMediaPermission,
+		EditMediaPermission,
 		DeleteMediaPermission,
 		StreamTranscodedMediaPermission,
 		StreamSourceMediaPermission,
 		StreamOnTheFlyMediaPermission,
+		AccessAllLibrariesPermission,
+		ShareMediaPermission,
 		CreateTranscodePermission,
 		AccessTranscodePermission,
 		ModifyTranscodePermission,
@@ -56,10 +84,24 @@ func All() []string {
 		AccessWorkflowPermission,
 		EditWorkflowPermission,
 		DeleteWorkflowPermission,
+		ManageWorkflowHooksPermission,
 		CreateUserPermission,
 		AccessUserPermission,
 		EditUserPermissionsPermission,
 		DeleteUserPermission,
+		CreateRolePermission,
+		AccessRolePermission,
+		EditRolePermission,
+		DeleteRolePermission,
+		CreateInvitePermission,
+		AccessInvitePermission,
+		DeleteInvitePermission,
+		ReadAuditPermission,
+		ReadDebugPermission,
+		ReadStatisticsPermission,
+		CreateBackupPermission,
+		ReadSystemPermission,
+		MaintainSystemPermission,
 	}
 }
 
@@ -0,0 +1,151 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrRoleNotFound  = errors.New("role does not exist")
+	ErrRoleIDMissing = errors.New("one or more of the roles provided cannot be found")
+)
+
+type (
+	roleBase struct {
+		ID        uuid.UUID `db:"id"`
+		Label     string    `db:"label"`
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	// roleModel is a combination of the roles table columns, combined with
+	// a JSON representation of the coalesced permission rows which are
+	// joined in to the query.
+	roleModel struct {
+		roleBase
+		Permissions database.JSONColumn[[]string] `db:"permissions"`
+	}
+
+	// Role is a named bundle of permissions which can be assigned to
+	// many users. Users inherit all the permissions of the roles
+	// they are a member of.
+	Role struct {
+		roleBase
+		Permissions []string
+	}
+)
+
+func (store *Store) CreateRole(db database.Queryable, roleID uuid.UUID, label string) error {
+	_, err := db.Exec(`
+		INSERT INTO roles(id, created_at, updated_at, label)
+		VALUES ($1, current_timestamp, current_timestamp, $2)
+	`, roleID, label)
+	if err != nil {
+		return fmt.Errorf("failed to insert new role: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRoleTx updates only the roles main data, such as it's label. If the
+// label provided is nil, then only the updated_at timestamp is changed.
+//
+// NOTE: This action is intended to be used as part of an over-arching transaction; user-story
+// for updating a role should consider it's permissions too.
+func (store *Store) UpdateRoleTx(tx *sqlx.Tx, roleID uuid.UUID, newLabel *string) error {
+	var res sql.Result
+	var err error
+	if newLabel != nil {
+		res, err = tx.Exec(`UPDATE roles SET (updated_at, label) = (current_timestamp, $2) WHERE id=$1`, roleID, *newLabel)
+	} else {
+		res, err = tx.Exec(`UPDATE roles SET updated_at=current_timestamp WHERE id=$1`, roleID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (store *Store) DropRolePermissions(db database.Queryable, roleID uuid.UUID) error {
+	_, err := db.Exec(`DELETE FROM roles_permissions WHERE role_id=$1`, roleID)
+	return err
+}
+
+func (store *Store) InsertRolePermissions(db database.Queryable, roleID uuid.UUID, permissions []Permission) error {
+	_, err := db.NamedExec(`
+		INSERT INTO roles_permissions(role_id, permission_id)
+		VALUES('`+roleID.String()+`', :id)
+		ON CONFLICT(role_id, permission_id) DO NOTHING
+	`, permissions)
+	return err
+}
+
+func (store *Store) GetRole(db database.Queryable, roleID uuid.UUID) (*Role, error) {
+	query, args, err := selectRoleBuilder().Where("roles.id=?", roleID).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct select role query: %w", err)
+	}
+
+	var role roleModel
+	if err := db.Get(&role, db.Rebind(query), args...); err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	return roleModelToRole(&role), nil
+}
+
+func (store *Store) ListRoles(db database.Queryable) ([]*Role, error) {
+	query, args, err := selectRoleBuilder().ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct list roles query: %w", err)
+	}
+
+	var results []roleModel
+	if err := db.Select(&results, query, args...); err != nil {
+		return nil, err
+	}
+
+	output := make([]*Role, len(results))
+	for i := range results {
+		output[i] = roleModelToRole(&results[i])
+	}
+
+	return output, nil
+}
+
+// DeleteRole removes the role with the ID provided. The roles permission
+// and user associations are removed by way of cascading deletes.
+func (store *Store) DeleteRole(db database.Queryable, roleID uuid.UUID) error {
+	_, err := db.Exec(`DELETE FROM roles WHERE id=$1`, roleID)
+	return err
+}
+
+func selectRoleBuilder() squirrel.SelectBuilder {
+	return squirrel.
+		Select("roles.*", "COALESCE(JSONB_AGG(DISTINCT permissions.label) FILTER (WHERE permissions.id IS NOT NULL), '[]') AS permissions").
+		From("roles").
+		LeftJoin("roles_permissions ON roles_permissions.role_id = roles.id").
+		LeftJoin("permissions ON permissions.id = roles_permissions.permission_id").
+		GroupBy("roles.id")
+}
+
+func roleModelToRole(model *roleModel) *Role {
+	return &Role{
+		roleBase:    model.roleBase,
+		Permissions: *model.Permissions.Get(),
+	}
+}
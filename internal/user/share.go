@@ -0,0 +1,121 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/database"
+)
+
+var (
+	ErrShareLinkNotFound        = errors.New("share link does not exist")
+	ErrSharePasswordInvalid     = errors.New("share link password is incorrect")
+	ErrShareTargetNotTranscoded = errors.New("media has no completed transcode for the target of the share link")
+	ErrShareExpiryInvalid       = errors.New("share link expiry must be in the future, and within 30 days")
+)
+
+// ShareLink grants access to stream a single movie or episode, without an account, until it
+// expires. If a target is set, the completed transcode of the media for that target is streamed,
+// otherwise the source of the media is. The token used to access a link is signed by the API, and
+// so is not persisted. Links may be protected by a password, of which only the hash is persisted.
+type ShareLink struct {
+	ID           uuid.UUID   `db:"id"`
+	MediaID      uuid.UUID   `db:"media_id"`
+	TargetID     *uuid.UUID  `db:"target_id"`
+	CreatedBy    uuid.UUID   `db:"created_by"`
+	CreatedAt    time.Time   `db:"created_at"`
+	ExpiresAt    time.Time   `db:"expires_at"`
+	PasswordHash []byte      `db:"password" json:"-"`
+	PasswordSalt []byte      `db:"salt" json:"-"`
+	HashParams   *HashParams `db:"hash_params" json:"-"`
+}
+
+// PasswordProtected returns true if a password must be provided to access the link.
+func (link *ShareLink) PasswordProtected() bool {
+	return link.HashParams != nil
+}
+
+// CreateShareLink inserts the share link provided, protected by the password given (if not empty).
+// The created at time, and the password hash of the link are populated.
+func (store *Store) CreateShareLink(db database.Queryable, link *ShareLink, password []byte) error {
+	link.PasswordHash, link.PasswordSalt, link.HashParams = nil, nil, nil
+	if len(password) > 0 {
+		hash, err := store.hashParams.GenerateHash(password)
+		if err != nil {
+			return fmt.Errorf("provided password is invalid: %w", err)
+		}
+
+		link.PasswordHash, link.PasswordSalt, link.HashParams = hash.hash, hash.salt, &hash.params
+	}
+
+	if err := db.Get(&link.CreatedAt, `
+		INSERT INTO share_links(id, media_id, target_id, created_by, created_at, expires_at, password, salt, hash_params)
+		VALUES ($1, $2, $3, $4, current_timestamp, $5, $6, $7, $8)
+		RETURNING created_at
+	`, link.ID, link.MediaID, link.TargetID, link.CreatedBy, link.ExpiresAt, link.PasswordHash, link.PasswordSalt, link.HashParams); err != nil {
+		return fmt.Errorf("failed to insert new share link: %w", err)
+	}
+
+	return nil
+}
+
+// GetShareLink returns the share link with the ID provided, or ErrShareLinkNotFound
+// if no such link exists. Expired links are not returned.
+func (store *Store) GetShareLink(db database.Queryable, linkID uuid.UUID) (*ShareLink, error) {
+	var link ShareLink
+	if err := db.Get(&link, `SELECT * FROM share_links WHERE id=$1 AND expires_at > current_timestamp`, linkID); err != nil {
+		return nil, ErrShareLinkNotFound
+	}
+
+	return &link, nil
+}
+
+// ListShareLinks returns the unexpired share links created by the user provided, most recent first.
+func (store *Store) ListShareLinks(db database.Queryable, createdBy uuid.UUID) ([]*ShareLink, error) {
+	var links []*ShareLink
+	if err := db.Select(&links, `
+		SELECT * FROM share_links
+		WHERE created_by=$1 AND expires_at > current_timestamp
+		ORDER BY created_at DESC
+	`, createdBy); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// DeleteShareLink deletes the share link with the ID provided, which must have been created by
+// the user given. ErrShareLinkNotFound is returned if the user has no such link.
+func (store *Store) DeleteShareLink(db database.Queryable, linkID uuid.UUID, createdBy uuid.UUID) error {
+	res, err := db.Exec(`DELETE FROM share_links WHERE id=$1 AND created_by=$2`, linkID, createdBy)
+	if err != nil {
+		return fmt.Errorf("failed to delete share link: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return ErrShareLinkNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpiredShareLinks deletes the share links which have expired.
+func (store *Store) DeleteExpiredShareLinks(db database.Queryable) error {
+	_, err := db.Exec(`DELETE FROM share_links WHERE expires_at <= current_timestamp`)
+	return err
+}
+
+// VerifySharePassword returns ErrSharePasswordInvalid if the link provided is
+// password protected, and the password given does not match.
+func (store *Store) VerifySharePassword(link *ShareLink, password []byte) error {
+	if !link.PasswordProtected() {
+		return nil
+	}
+
+	if err := link.HashParams.Compare(link.PasswordHash, link.PasswordSalt, password); err != nil {
+		return ErrSharePasswordInvalid
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VerifySharePassword(t *testing.T) {
+	t.Parallel()
+	store := &Store{}
+
+	assert.NoError(t, store.VerifySharePassword(&ShareLink{}, []byte("anything")), "links without a password should not require one")
+
+	params := HashConfig{Time: 1, MemoryKiB: 64, Threads: 1}.params()
+	hash, err := params.GenerateHash([]byte("hunter2"))
+	assert.NoError(t, err)
+
+	link := &ShareLink{PasswordHash: hash.hash, PasswordSalt: hash.salt, HashParams: &hash.params}
+	assert.NoError(t, store.VerifySharePassword(link, []byte("hunter2")))
+	assert.ErrorIs(t, store.VerifySharePassword(link, []byte("hunter3")), ErrSharePasswordInvalid)
+	assert.ErrorIs(t, store.VerifySharePassword(link, []byte{}), ErrSharePasswordInvalid)
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
@@ -8,69 +9,85 @@ import (
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/hbomb79/Thea/internal/database"
+	"github.com/hbomb79/Thea/pkg/logger"
 	"github.com/jmoiron/sqlx"
 )
 
-var ErrUserNotFound = errors.New("user does not exist")
+var (
+	ErrUserNotFound       = errors.New("user does not exist")
+	ErrPermissionsInvalid = errors.New("permissions provided are invalid")
+
+	storeLogger = logger.Get("UserStore")
+)
 
 type (
 	userBase struct {
-		ID             uuid.UUID  `db:"id"`
-		Username       string     `db:"username"`
-		HashedPassword []byte     `db:"password" json:"-"`
-		HashSalt       []byte     `db:"salt" json:"-"`
-		CreatedAt      time.Time  `db:"created_at"`
-		UpdatedAt      time.Time  `db:"updated_at"`
-		LastLoginAt    *time.Time `db:"last_login"`
-		LastRefreshAt  *time.Time `db:"last_refresh"`
+		ID             uuid.UUID   `db:"id"`
+		Username       string      `db:"username"`
+		HashedPassword []byte      `db:"password" json:"-"`
+		HashSalt       []byte      `db:"salt" json:"-"`
+		HashParams     *HashParams `db:"hash_params" json:"-"`
+		CreatedAt      time.Time   `db:"created_at"`
+		UpdatedAt      time.Time   `db:"updated_at"`
+		LastLoginAt    *time.Time  `db:"last_login"`
+		LastRefreshAt  *time.Time  `db:"last_refresh"`
 	}
 
 	// userModel is a combination of the users table columns, combined with
-	// a JSON representation of the coalesced permission rows which are
+	// a JSON representation of the coalesced permission and role rows which are
 	// joined in to the query. We use a separate struct as part of
 	// the public API of this store to hide the use of the JsonColumn container
 	// to prevent against breakages if we change this in the future.
 	userModel struct {
 		userBase
-		Permissions database.JSONColumn[[]string] `db:"permissions"`
+		Permissions       database.JSONColumn[[]string]    `db:"permissions"`
+		DirectPermissions database.JSONColumn[[]string]    `db:"direct_permissions"`
+		Roles             database.JSONColumn[[]uuid.UUID] `db:"roles"`
 	}
 
 	// User is the external/public API for the user model. It uses a special
 	// Permissions type for the users permissions which allows for common
 	// operations to be performed against the set of permissions.
+	//
+	// Permissions is the resolved set of permissions for this user, and
+	// is the union of the permissions assigned directly to the user and
+	// those granted by the roles the user is a member of. DirectPermissions
+	// contains only the former, and is what should be written back when
+	// updating the permissions of a user.
 	User struct {
 		userBase
-		Permissions []string
+		Permissions       []string
+		DirectPermissions []string
+		Roles             []uuid.UUID
 	}
 
+	// Store persists users. Passwords are hashed using Argon2id with the parameters
+	// of the HashConfig provided to NewStore (see HashConfig for details).
 	Store struct {
-		hasher *argonHasher
+		hashParams HashParams
 	}
 )
 
-func NewStore() *Store {
-	return &Store{
-		// TODO figure out the best values for this
-		newArgon2IdHasher(1, 64, 64*1024, 1, 128),
-	}
+func NewStore(config HashConfig) *Store {
+	return &Store{hashParams: config.params()}
 }
 
 func (store *Store) Create(db database.Queryable, username []byte, rawPassword []byte) (*User, error) {
-	hash, err := store.hasher.GenerateHash(rawPassword, []byte{})
+	hash, err := store.hashParams.GenerateHash(rawPassword)
 	if err != nil {
 		return nil, fmt.Errorf("provided password is invalid: %w", err)
 	}
 
 	var user userBase
 	if err := db.Get(&user, `
-		INSERT INTO users(id, username, password, salt, created_at, updated_at, last_login, last_refresh)
-		VALUES ($1, $2, $3, $4, current_timestamp, current_timestamp, NULL, NULL)
+		INSERT INTO users(id, username, password, salt, hash_params, created_at, updated_at, last_login, last_refresh)
+		VALUES ($1, $2, $3, $4, $5, current_timestamp, current_timestamp, NULL, NULL)
 		RETURNING *
-	`, uuid.New(), username, hash.hash, hash.salt); err != nil {
+	`, uuid.New(), username, hash.hash, hash.salt, hash.params); err != nil {
 		return nil, fmt.Errorf("failed to insert new user: %w", err)
 	}
 
-	return &User{user, []string{}}, nil
+	return &User{user, []string{}, []string{}, []uuid.UUID{}}, nil
 }
 
 func (store *Store) List(db database.Queryable) ([]*User, error) {
@@ -94,8 +111,11 @@ func (store *Store) List(db database.Queryable) ([]*User, error) {
 
 // GetWithUsernameAndPassword finds a user with the matching
 // username and returns it IF and ONLY IF the raw (unhashed) password
-// provided is able to be hashed with the same salt as was used with
-// the existing user (if any), and the hashes MATCH.
+// provided is able to be hashed with the same salt (and parameters) as
+// was used with the existing user (if any), and the hashes MATCH.
+//
+// If the users password was hashed using parameters other than those
+// currently configured (e.g. a legacy hash), the password is re-hashed.
 func (store *Store) GetWithUsernameAndPassword(db database.Queryable, username []byte, rawPassword []byte) (*User, error) {
 	query, args, err := selectUserBuilder().Where("users.username=?", username).ToSql()
 	if err != nil {
@@ -107,13 +127,39 @@ func (store *Store) GetWithUsernameAndPassword(db database.Queryable, username [
 		return nil, fmt.Errorf("failed to find user with username %s: %w", username, err)
 	}
 
-	if err := store.hasher.Compare(user.HashedPassword, user.HashSalt, rawPassword); err != nil {
+	params := legacyHashParams
+	if user.HashParams != nil {
+		params = *user.HashParams
+	}
+	if err := params.Compare(user.HashedPassword, user.HashSalt, rawPassword); err != nil {
 		return nil, fmt.Errorf("password supplied for user %s is invalid: %w", username, err)
 	}
 
+	// Failure to re-hash is not fatal, as the existing hash remains valid
+	if params != store.hashParams {
+		if err := store.rehashPassword(db, user.ID, rawPassword); err != nil {
+			storeLogger.Warnf("Failed to re-hash password of user %s: %v\n", user.ID, err)
+		} else {
+			storeLogger.Emit(logger.DEBUG, "Re-hashed password of user %s using the configured hash parameters\n", user.ID)
+		}
+	}
+
 	return userModelToUser(&user), nil
 }
 
+// rehashPassword replaces the password hash of the user with the ID provided with a hash of
+// the same password using the configured hash parameters. Unlike UpdatePassword, the
+// users 'updated_at' timestamp is not changed, as the password itself is unchanged.
+func (store *Store) rehashPassword(db database.Queryable, userID uuid.UUID, rawPassword []byte) error {
+	hash, err := store.hashParams.GenerateHash(rawPassword)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`UPDATE users SET password=$1, salt=$2, hash_params=$3 WHERE id=$4`, hash.hash, hash.salt, hash.params, userID)
+	return err
+}
+
 func (store *Store) GetWithID(db database.Queryable, id uuid.UUID) (*User, error) {
 	query, args, err := selectUserBuilder().Where("users.id=?", id).ToSql()
 	if err != nil {
@@ -128,6 +174,45 @@ func (store *Store) GetWithID(db database.Queryable, id uuid.UUID) (*User, error
 	return userModelToUser(&user), nil
 }
 
+// GetWithUsername returns the user with the username provided, or
+// ErrUserNotFound if no such user exists.
+func (store *Store) GetWithUsername(db database.Queryable, username []byte) (*User, error) {
+	query, args, err := selectUserBuilder().Where("users.username=?", username).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct select user query: %w", err)
+	}
+
+	var user userModel
+	if err := db.Get(&user, db.Rebind(query), args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("failed to find user with username %s: %w", username, err)
+	}
+
+	return userModelToUser(&user), nil
+}
+
+// UpdatePassword replaces the password of the user with the ID provided. ErrUserNotFound
+// is returned if no such user exists.
+func (store *Store) UpdatePassword(db database.Queryable, userID uuid.UUID, rawPassword []byte) error {
+	hash, err := store.hashParams.GenerateHash(rawPassword)
+	if err != nil {
+		return fmt.Errorf("provided password is invalid: %w", err)
+	}
+
+	res, err := db.Exec(`UPDATE users SET password=$1, salt=$2, hash_params=$3, updated_at=current_timestamp WHERE id=$4`, hash.hash, hash.salt, hash.params, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 func (store *Store) RecordUpdate(db database.Queryable, userID uuid.UUID) error {
 	_, err := db.Exec(`UPDATE users SET updated_at=current_timestamp WHERE id = $1`, userID)
 	return err
@@ -176,18 +261,55 @@ func (store *Store) InsertUserPermissions(db database.Queryable, userID uuid.UUI
 	return err
 }
 
+func (store *Store) DropUserRoles(db database.Queryable, userID uuid.UUID) error {
+	_, err := db.Exec(`DELETE FROM user_roles WHERE user_id=$1`, userID)
+	return err
+}
+
+func (store *Store) InsertUserRoles(db database.Queryable, userID uuid.UUID, roleIDs []uuid.UUID) error {
+	type assoc struct {
+		UserID uuid.UUID `db:"user_id"`
+		RoleID uuid.UUID `db:"role_id"`
+	}
+
+	assocs := make([]assoc, len(roleIDs))
+	for k, v := range roleIDs {
+		assocs[k] = assoc{userID, v}
+	}
+
+	_, err := db.NamedExec(`
+		INSERT INTO user_roles(user_id, role_id)
+		VALUES(:user_id, :role_id)
+		ON CONFLICT(user_id, role_id) DO NOTHING
+	`, assocs)
+	return err
+}
+
+// selectUserBuilder constructs the base query used to select users. The permissions
+// column is the union of the permissions directly assigned to the user, and those
+// granted by any of the roles the user is a member of, whereas the direct_permissions
+// column contains only the former.
 func selectUserBuilder() squirrel.SelectBuilder {
 	return squirrel.
-		Select("users.*", "COALESCE(JSONB_AGG(DISTINCT permissions.label) FILTER (WHERE permissions.id IS NOT NULL), '[]') AS permissions").
+		Select(
+			"users.*",
+			"COALESCE(JSONB_AGG(DISTINCT permissions.label) FILTER (WHERE permissions.id IS NOT NULL), '[]') AS permissions",
+			"COALESCE(JSONB_AGG(DISTINCT permissions.label) FILTER (WHERE permissions.id = users_permissions.permission_id), '[]') AS direct_permissions",
+			"COALESCE(JSONB_AGG(DISTINCT user_roles.role_id) FILTER (WHERE user_roles.role_id IS NOT NULL), '[]') AS roles",
+		).
 		From("users").
 		LeftJoin("users_permissions ON users_permissions.user_id = users.id").
-		LeftJoin("permissions ON permissions.id = users_permissions.permission_id").
+		LeftJoin("user_roles ON user_roles.user_id = users.id").
+		LeftJoin("roles_permissions ON roles_permissions.role_id = user_roles.role_id").
+		LeftJoin("permissions ON permissions.id = users_permissions.permission_id OR permissions.id = roles_permissions.permission_id").
 		GroupBy("users.id")
 }
 
 func userModelToUser(model *userModel) *User {
 	return &User{
-		userBase:    model.userBase,
-		Permissions: *model.Permissions.Get(),
+		userBase:          model.userBase,
+		Permissions:       *model.Permissions.Get(),
+		DirectPermissions: *model.DirectPermissions.Get(),
+		Roles:             *model.Roles.Get(),
 	}
 }
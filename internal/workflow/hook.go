@@ -0,0 +1,200 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/media"
+)
+
+// hookOutputLimit is the maximum number of bytes of output retained from a hook. Output
+// beyond this limit is discarded, keeping the most recent output (see HookResult.Output).
+const hookOutputLimit = 16 * 1024
+
+// hookWaitDelay is how long to wait for the output of a hook to be closed once the command has
+// been killed, as processes started by the command may otherwise keep the hook running.
+const hookWaitDelay = 5 * time.Second
+
+var (
+	// ErrHookCommandMissing is returned when a workflow is saved with a hook which has no command.
+	ErrHookCommandMissing = errors.New("hook command must be provided")
+	// ErrHookTimeoutInvalid is returned when a workflow is saved with a hook which has a negative timeout.
+	ErrHookTimeoutInvalid = errors.New("hook timeout must not be negative")
+)
+
+type (
+	// Hook is a user-provided command which is executed once a transcode queued by the workflow
+	// completes, so that Thea can be integrated with software it does not natively support (e.g.
+	// to notify a media server, or to move the output elsewhere). The command is executed directly
+	// (not using a shell), with environment variables describing the media and the transcode
+	// (see HookEnvironment). Hooks are only executed if enabled in the transcode config.
+	Hook struct {
+		Command string   `json:"command"`
+		Args    []string `json:"args"`
+		// TimeoutSeconds is how long the command may run before it is killed. If
+		// zero, the default timeout from the transcode config is used instead.
+		TimeoutSeconds int `json:"timeout_seconds"`
+	}
+
+	// Hooks are the hooks of a workflow, in the order they're executed.
+	Hooks []Hook
+
+	// HookResult describes the outcome of running a hook. Output is the tail of the
+	// combined stdout/stderr of the command, and ExitCode is -1 if the command
+	// could not be started or was killed (e.g. because it timed out).
+	HookResult struct {
+		ExitCode int
+		Output   string
+		Duration time.Duration
+		Err      error
+	}
+)
+
+// Validate returns an error if the hook cannot be executed.
+func (hook *Hook) Validate() error {
+	if strings.TrimSpace(hook.Command) == "" {
+		return ErrHookCommandMissing
+	}
+	if hook.TimeoutSeconds < 0 {
+		return ErrHookTimeoutInvalid
+	}
+
+	return nil
+}
+
+// Run executes the hook with the environment variables provided (in addition to the environment of
+// Thea), waiting for the command to exit. If the hook has no timeout of it's own, the default
+// timeout provided is used. The command is killed if it times out, or the context is cancelled.
+func (hook *Hook) Run(ctx context.Context, env map[string]string, defaultTimeout time.Duration) HookResult {
+	timeout := defaultTimeout
+	if hook.TimeoutSeconds > 0 {
+		timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	output := &hookOutput{}
+	cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = hookWaitDelay
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	result := HookResult{ExitCode: -1, Output: output.String(), Duration: time.Since(start)}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Err = fmt.Errorf("hook timed out after %s", timeout)
+	} else if err != nil {
+		result.Err = err
+	}
+
+	return result
+}
+
+// HookEnvironment returns the environment variables provided to the hooks of the workflow when the
+// transcode with the ID provided completes, describing the media that was transcoded (from the source
+// path given), the target used, and the path of the transcode output. The series, season and episode
+// variables are only provided for episodes.
+func (workflow *Workflow) HookEnvironment(m *media.Container, sourcePath string, target *ffmpeg.Target, transcodeID uuid.UUID, outputPath string) map[string]string {
+	env := map[string]string{
+		"THEA_WORKFLOW_ID":    workflow.ID.String(),
+		"THEA_WORKFLOW_LABEL": workflow.Label,
+		"THEA_TRANSCODE_ID":   transcodeID.String(),
+		"THEA_TARGET_ID":      target.ID.String(),
+		"THEA_TARGET_LABEL":   target.Label,
+		"THEA_OUTPUT_PATH":    outputPath,
+		"THEA_MEDIA_ID":       m.ID().String(),
+		"THEA_MEDIA_TITLE":    m.Title(),
+		"THEA_MEDIA_TMDB_ID":  m.TmdbID(),
+		"THEA_SOURCE_PATH":    sourcePath,
+	}
+
+	if m.Type == media.EpisodeContainerType {
+		env["THEA_MEDIA_TYPE"] = "episode"
+		env["THEA_EPISODE_NUMBER"] = strconv.Itoa(m.EpisodeNumber())
+		if m.Season != nil {
+			env["THEA_SEASON_NUMBER"] = strconv.Itoa(m.SeasonNumber())
+		}
+		if m.Series != nil {
+			env["THEA_SERIES_ID"] = m.Series.ID.String()
+			env["THEA_SERIES_TITLE"] = m.Series.Title
+		}
+	} else {
+		env["THEA_MEDIA_TYPE"] = "movie"
+	}
+
+	return env
+}
+
+func (hook Hook) String() string {
+	return strings.TrimSpace(hook.Command + " " + strings.Join(hook.Args, " "))
+}
+
+// Validate returns an error if any of the hooks cannot be executed.
+func (hooks Hooks) Validate() error {
+	for k, hook := range hooks {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("hook %d is invalid: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+// Scan scan value into Jsonb, implements sql.Scanner interface.
+func (hooks *Hooks) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal JSONB value: %v", value)
+	}
+
+	result := Hooks{}
+	err := json.Unmarshal(bytes, &result)
+	*hooks = result
+	return err
+}
+
+// Value return json value, implement driver.Valuer interface.
+func (hooks Hooks) Value() (driver.Value, error) {
+	if hooks == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(hooks)
+}
+
+// hookOutput retains the most recent output written to it, up to the hookOutputLimit.
+type hookOutput struct{ buf bytes.Buffer }
+
+func (output *hookOutput) Write(p []byte) (int, error) {
+	output.buf.Write(p)
+	if overflow := output.buf.Len() - hookOutputLimit; overflow > 0 {
+		output.buf.Next(overflow)
+	}
+
+	return len(p), nil
+}
+
+func (output *hookOutput) String() string { return output.buf.String() }
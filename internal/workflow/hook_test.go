@@ -0,0 +1,84 @@
+package workflow_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/ffmpeg"
+	"github.com/hbomb79/Thea/internal/media"
+	"github.com/hbomb79/Thea/internal/workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Hook_Run(t *testing.T) {
+	t.Run("Environment provided and output captured", func(t *testing.T) {
+		hook := workflow.Hook{Command: "sh", Args: []string{"-c", `echo "$THEA_MEDIA_TITLE -> $THEA_OUTPUT_PATH"; echo failed >&2`}}
+		result := hook.Run(context.Background(), map[string]string{"THEA_MEDIA_TITLE": "Example Movie", "THEA_OUTPUT_PATH": "/out/movie.mp4"}, time.Second*5)
+
+		assert.NoError(t, result.Err)
+		assert.Equal(t, 0, result.ExitCode)
+		assert.Equal(t, "Example Movie -> /out/movie.mp4\nfailed\n", result.Output)
+	})
+
+	t.Run("Non-zero exit code is an error", func(t *testing.T) {
+		hook := workflow.Hook{Command: "sh", Args: []string{"-c", "exit 3"}}
+		result := hook.Run(context.Background(), nil, time.Second*5)
+
+		assert.Error(t, result.Err)
+		assert.Equal(t, 3, result.ExitCode)
+	})
+
+	t.Run("Killed after timeout", func(t *testing.T) {
+		hook := workflow.Hook{Command: "sleep", Args: []string{"10"}}
+		result := hook.Run(context.Background(), nil, time.Millisecond*100)
+
+		assert.ErrorContains(t, result.Err, "timed out")
+		assert.Less(t, result.Duration, time.Second*5)
+	})
+
+	t.Run("Missing command is an error", func(t *testing.T) {
+		hook := workflow.Hook{Command: "thea-hook-which-does-not-exist"}
+		result := hook.Run(context.Background(), nil, time.Second)
+
+		assert.Error(t, result.Err)
+		assert.Equal(t, -1, result.ExitCode)
+	})
+}
+
+func Test_Hooks_Validate(t *testing.T) {
+	assert.NoError(t, workflow.Hooks{{Command: "notify.sh"}, {Command: "curl", TimeoutSeconds: 10}}.Validate())
+	assert.ErrorIs(t, workflow.Hooks{{Command: "notify.sh"}, {Command: "  "}}.Validate(), workflow.ErrHookCommandMissing)
+	assert.ErrorIs(t, workflow.Hooks{{Command: "notify.sh", TimeoutSeconds: -1}}.Validate(), workflow.ErrHookTimeoutInvalid)
+}
+
+func Test_Workflow_HookEnvironment(t *testing.T) {
+	wf := &workflow.Workflow{ID: uuid.New(), Label: "Example"}
+	target := &ffmpeg.Target{ID: uuid.New(), Label: "1080p"}
+	transcodeID := uuid.New()
+	episode := &media.Container{
+		Type:    media.EpisodeContainerType,
+		Episode: &media.Episode{Model: media.Model{ID: uuid.New(), Title: "Pilot"}, EpisodeNumber: 1},
+		Season:  &media.Season{SeasonNumber: 2},
+		Series:  &media.Series{Model: media.Model{ID: uuid.New(), Title: "Example Series"}},
+	}
+
+	env := wf.HookEnvironment(episode, "/src/pilot.mkv", target, transcodeID, "/out/pilot.mp4")
+	assert.Equal(t, "episode", env["THEA_MEDIA_TYPE"])
+	assert.Equal(t, "Pilot", env["THEA_MEDIA_TITLE"])
+	assert.Equal(t, "Example Series", env["THEA_SERIES_TITLE"])
+	assert.Equal(t, "2", env["THEA_SEASON_NUMBER"])
+	assert.Equal(t, "1", env["THEA_EPISODE_NUMBER"])
+	assert.Equal(t, "/src/pilot.mkv", env["THEA_SOURCE_PATH"])
+	assert.Equal(t, "/out/pilot.mp4", env["THEA_OUTPUT_PATH"])
+	assert.Equal(t, "1080p", env["THEA_TARGET_LABEL"])
+	assert.Equal(t, transcodeID.String(), env["THEA_TRANSCODE_ID"])
+	assert.Equal(t, wf.ID.String(), env["THEA_WORKFLOW_ID"])
+
+	movie := &media.Container{Type: media.MovieContainerType, Movie: &media.Movie{Model: media.Model{ID: uuid.New(), Title: "Example Movie"}}}
+	env = wf.HookEnvironment(movie, "/src/movie.mkv", target, transcodeID, "/out/movie.mp4")
+	assert.Equal(t, "movie", env["THEA_MEDIA_TYPE"])
+	assert.NotContains(t, env, "THEA_EPISODE_NUMBER")
+	assert.NotContains(t, env, "THEA_SERIES_TITLE")
+}
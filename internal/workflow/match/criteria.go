@@ -117,6 +117,13 @@ func (criteria *Criteria) IsMediaAcceptable(m *media.Container) (bool, error) {
 		valueToCheck = filepath.Base(m.Source())
 	case SourcePathKey:
 		valueToCheck = m.Source()
+	case TagKey:
+		isMatch, err := criteria.isAnyValueAcceptable(m.Tags)
+		if err != nil {
+			return false, fmt.Errorf("media %s is not acceptable for criteria %s: %w", m, criteria, err)
+		}
+
+		return isMatch, nil
 	}
 
 	isMatch, err := criteria.isValueAcceptable(valueToCheck)
@@ -174,6 +181,32 @@ func (criteria *Criteria) isValueAcceptable(valToTest interface{}) (bool, error)
 	return false, fmt.Errorf("criteria type %s unknown, cannot test %v and %v", criteria.Type, criteria.Value, valToTest)
 }
 
+// isAnyValueAcceptable is the counterpart of isValueAcceptable for keys which have many values (such
+// as tags). The values are present if there is at least one, and match if ANY of the values match.
+func (criteria *Criteria) isAnyValueAcceptable(values []string) (bool, error) {
+	//exhaustive:ignore
+	switch criteria.Type {
+	case IsPresent:
+		return len(values) > 0, nil
+	case IsNotPresent:
+		return len(values) == 0, nil
+	case Matches, DoesNotMatch:
+		for _, v := range values {
+			match, err := criteria.testStringEquality(v)
+			if err != nil {
+				return false, err
+			}
+			if match {
+				return criteria.Type == Matches, nil
+			}
+		}
+
+		return criteria.Type == DoesNotMatch, nil
+	}
+
+	return false, fmt.Errorf("criteria type %s is not valid for key %s (multi-valued type)", criteria.Type, criteria.Key)
+}
+
 // performStringComparison attempts to test the given value against the criteria Value. If either the
 // criteria Value or the valToTets provided cannot be coerced to a string, an error will be returned.
 //
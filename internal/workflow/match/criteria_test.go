@@ -92,6 +92,7 @@ func Test_ValidateLegal_AcceptableType(t *testing.T) {
 			match.MediaTitleKey, match.SeriesTitleKey, match.SeasonTitleKey,
 			match.ResolutionKey, match.SeasonNumberKey, match.EpisodeNumberKey,
 			match.SourcePathKey, match.SourceNameKey, match.SourceExtensionKey,
+			match.TagKey,
 		} {
 			tests = append(tests, criteriaTest{
 				summary:   k.String(),
@@ -121,6 +122,7 @@ func Test_ValidateLegal_AcceptableType(t *testing.T) {
 		match.SourcePathKey,
 		match.SourceNameKey,
 		match.SourceExtensionKey,
+		match.TagKey,
 	})
 	runTests(t, match.DoesNotMatch, "str", []match.Key{
 		match.MediaTitleKey,
@@ -130,6 +132,7 @@ func Test_ValidateLegal_AcceptableType(t *testing.T) {
 		match.SourcePathKey,
 		match.SourceNameKey,
 		match.SourceExtensionKey,
+		match.TagKey,
 	})
 	runTests(t, match.LessThan, "0", []match.Key{
 		match.SeasonNumberKey,
@@ -149,6 +152,7 @@ func Test_ValidateLegal_AcceptableType(t *testing.T) {
 		match.SourcePathKey,
 		match.SourceNameKey,
 		match.SourceExtensionKey,
+		match.TagKey,
 	})
 	runTests(t, match.IsNotPresent, "true", []match.Key{
 		match.MediaTitleKey,
@@ -160,6 +164,7 @@ func Test_ValidateLegal_AcceptableType(t *testing.T) {
 		match.SourcePathKey,
 		match.SourceNameKey,
 		match.SourceExtensionKey,
+		match.TagKey,
 	})
 }
 
@@ -808,3 +813,38 @@ func runCommonMediaAcceptableTests(t *testing.T, media *media.Container) {
 		runMediaAcceptableTests(t, media, tests)
 	})
 }
+
+func Test_TagAcceptable(t *testing.T) {
+	tagged := &media.Container{
+		Type:  media.MovieContainerType,
+		Movie: &media.Movie{Model: media.Model{Title: "Example Movie"}},
+		Tags:  []string{"kids", "anime"},
+	}
+	untagged := &media.Container{
+		Type:  media.MovieContainerType,
+		Movie: &media.Movie{Model: media.Model{Title: "Example Movie"}},
+		Tags:  []string{},
+	}
+
+	t.Run("Tagged", func(t *testing.T) {
+		runMediaAcceptableTests(t, tagged, []criteriaTest{
+			{summary: "Is Present", criteria: match.Criteria{Key: match.TagKey, Type: match.IsPresent}, isValid: true},
+			{summary: "Not Present", criteria: match.Criteria{Key: match.TagKey, Type: match.IsNotPresent}, isValid: false},
+			{summary: "Positive string match", criteria: match.Criteria{Key: match.TagKey, Type: match.Matches, Value: "anime"}, isValid: true},
+			{summary: "Negative string match", criteria: match.Criteria{Key: match.TagKey, Type: match.Matches, Value: "horror"}, isValid: false},
+			{summary: "Positive string regexp match", criteria: match.Criteria{Key: match.TagKey, Type: match.Matches, Value: "/^ki/"}, isValid: true},
+			{summary: "Positive does not match", criteria: match.Criteria{Key: match.TagKey, Type: match.DoesNotMatch, Value: "horror"}, isValid: true},
+			{summary: "Negative does not match", criteria: match.Criteria{Key: match.TagKey, Type: match.DoesNotMatch, Value: "kids"}, isValid: false},
+			{summary: "Invalid match type", criteria: match.Criteria{Key: match.TagKey, Type: match.Equals, Value: "kids"}, isValid: false, shouldErr: true},
+		})
+	})
+
+	t.Run("Untagged", func(t *testing.T) {
+		runMediaAcceptableTests(t, untagged, []criteriaTest{
+			{summary: "Is Present", criteria: match.Criteria{Key: match.TagKey, Type: match.IsPresent}, isValid: false},
+			{summary: "Not Present", criteria: match.Criteria{Key: match.TagKey, Type: match.IsNotPresent}, isValid: true},
+			{summary: "Matches", criteria: match.Criteria{Key: match.TagKey, Type: match.Matches, Value: "kids"}, isValid: false},
+			{summary: "Does not match", criteria: match.Criteria{Key: match.TagKey, Type: match.DoesNotMatch, Value: "kids"}, isValid: true},
+		})
+	})
+}
@@ -24,6 +24,11 @@ const (
 	SourcePathKey
 	SourceNameKey
 	SourceExtensionKey
+
+	// TagKey matches against the labels of the user-defined tags
+	// attached to the movie, or the series of an episode. The
+	// criteria matches if ANY of the tags match.
+	TagKey
 )
 
 func (e Key) Values() []string {
@@ -31,6 +36,7 @@ func (e Key) Values() []string {
 		"MEDIA_TITLE", "SERIES_TITLE", "SEASON_TITLE",
 		"RESOLUTION", "SEASON_NUMBER", "EPISODE_NUMBER",
 		"SOURCE_PATH", "SOURCE_NAME", "SOURCE_EXTENSION",
+		"TAG",
 	}
 }
 
@@ -75,6 +81,7 @@ func keyAcceptableTypes() map[Key][]Type {
 		SourcePathKey:      {Matches, DoesNotMatch, IsPresent, IsNotPresent},
 		SourceNameKey:      {Matches, DoesNotMatch, IsPresent, IsNotPresent},
 		SourceExtensionKey: {Matches, DoesNotMatch, IsPresent, IsNotPresent},
+		TagKey:             {Matches, DoesNotMatch, IsPresent, IsNotPresent},
 	}
 }
 
@@ -15,13 +15,15 @@ import (
 
 type (
 	workflowModel struct {
-		ID        uuid.UUID                             `db:"id"`
-		UpdatedAt time.Time                             `db:"updated_at"`
-		CreatedAt time.Time                             `db:"created_at"`
-		Enabled   bool                                  `db:"enabled"`
-		Label     string                                `db:"label"`
-		Criteria  database.JSONColumn[[]criteriaModel]  `db:"criteria"`
-		Targets   database.JSONColumn[[]*ffmpeg.Target] `db:"targets"`
+		ID         uuid.UUID                             `db:"id"`
+		UpdatedAt  time.Time                             `db:"updated_at"`
+		CreatedAt  time.Time                             `db:"created_at"`
+		Enabled    bool                                  `db:"enabled"`
+		Label      string                                `db:"label"`
+		Sequential bool                                  `db:"sequential"`
+		Criteria   database.JSONColumn[[]criteriaModel]  `db:"criteria"`
+		Targets    database.JSONColumn[[]*ffmpeg.Target] `db:"targets"`
+		Hooks      Hooks                                 `db:"hooks"`
 	}
 
 	criteriaModel struct {
@@ -33,23 +35,25 @@ type (
 		ID         uuid.UUID `db:"id"`
 		WorkflowID uuid.UUID `db:"workflow_id"`
 		TargetID   uuid.UUID `db:"target_id"`
+		Position   int       `db:"position"`
 	}
 
 	Store struct{}
 )
 
 // Create transactionally creates the workflow row, and the accompanying
-// criteria table and workflow_target join table rows as needed.
-func (store *Store) Create(db *sqlx.DB, workflowID uuid.UUID, label string, enabled bool, targetIDs []uuid.UUID, criteria []match.Criteria) error {
+// criteria table and workflow_target join table rows as needed. The targets
+// and hooks of the workflow are ordered as provided.
+func (store *Store) Create(db *sqlx.DB, workflowID uuid.UUID, label string, enabled bool, sequential bool, targetIDs []uuid.UUID, criteria []match.Criteria, hooks Hooks) error {
 	fail := func(desc string, err error) error {
 		return fmt.Errorf("failed to %s: %w", desc, err)
 	}
 
 	return database.WrapTx(db, func(tx *sqlx.Tx) error {
 		if _, err := tx.Exec(`
-			INSERT INTO workflow(id, created_at, updated_at, enabled, label)
-			VALUES ($1, current_timestamp, current_timestamp, $2, $3)`,
-			workflowID, enabled, label); err != nil {
+			INSERT INTO workflow(id, created_at, updated_at, enabled, label, sequential, hooks)
+			VALUES ($1, current_timestamp, current_timestamp, $2, $3, $4, $5)`,
+			workflowID, enabled, label, sequential, hooks); err != nil {
 			return fail("create workflow row", err)
 		}
 
@@ -64,14 +68,15 @@ func (store *Store) Create(db *sqlx.DB, workflowID uuid.UUID, label string, enab
 	})
 }
 
-// UpdateWorkflowTx updates only the workflows main data, such as it's label.
+// UpdateWorkflowTx updates only the workflows main data, such as it's label and hooks.
 //
 // NOTE: This action is intended to be used as part of an over-arching transaction; user-story
 // for updating a workflow should consider all related data too.
-func (store *Store) UpdateWorkflowTx(tx *sqlx.Tx, workflowID uuid.UUID, newLabel *string, newEnabled *bool) error {
+func (store *Store) UpdateWorkflowTx(tx *sqlx.Tx, workflowID uuid.UUID, newLabel *string, newEnabled *bool, newSequential *bool, newHooks *Hooks) error {
 	var labelToSet string
-	var enabledToSet bool
-	if err := tx.QueryRowx(`SELECT label, enabled FROM workflow WHERE id=$1`, workflowID).Scan(&labelToSet, &enabledToSet); err != nil {
+	var enabledToSet, sequentialToSet bool
+	var hooksToSet Hooks
+	if err := tx.QueryRowx(`SELECT label, enabled, sequential, hooks FROM workflow WHERE id=$1`, workflowID).Scan(&labelToSet, &enabledToSet, &sequentialToSet, &hooksToSet); err != nil {
 		return err
 	}
 
@@ -81,12 +86,18 @@ func (store *Store) UpdateWorkflowTx(tx *sqlx.Tx, workflowID uuid.UUID, newLabel
 	if newEnabled != nil {
 		enabledToSet = *newEnabled
 	}
+	if newSequential != nil {
+		sequentialToSet = *newSequential
+	}
+	if newHooks != nil {
+		hooksToSet = *newHooks
+	}
 
 	_, err := tx.Exec(`
 		UPDATE workflow
-		SET (updated_at, label, enabled) = (current_timestamp, $2, $3)
+		SET (updated_at, label, enabled, sequential, hooks) = (current_timestamp, $2, $3, $4, $5)
 		WHERE id=$1
-	`, workflowID, labelToSet, enabledToSet)
+	`, workflowID, labelToSet, enabledToSet, sequentialToSet, hooksToSet)
 
 	return err
 }
@@ -142,7 +153,7 @@ func (store *Store) UpdateWorkflowCriteriaTx(tx *sqlx.Tx, workflowID uuid.UUID,
 
 // UpdateWorkflowTargetsTx updates a workflows transcode targets by modifying the rows
 // in the join table as needed. For simplicity, this function will drop all rows
-// for the given workflow and re-create them.
+// for the given workflow and re-create them. The targets are ordered as provided.
 //
 // NOTE: This DB action is intended to be used as part of an over-arching transaction; user-story
 // for updating a workflow should consider all related data too.
@@ -153,8 +164,8 @@ func (store *Store) UpdateWorkflowTargetsTx(tx *sqlx.Tx, workflowID uuid.UUID, t
 
 	if len(targetIDs) > 0 {
 		_, err := tx.NamedExec(`
-			INSERT INTO workflow_transcode_targets(id, workflow_id, transcode_target_id)
-			VALUES(:id, :workflow_id, :target_id)
+			INSERT INTO workflow_transcode_targets(id, workflow_id, transcode_target_id, position)
+			VALUES(:id, :workflow_id, :target_id, :position)
 			`, buildWorkflowTargetAssocs(workflowID, targetIDs),
 		)
 
@@ -175,7 +186,7 @@ func (store *Store) Get(db database.Queryable, id uuid.UUID) *Workflow {
 		return nil
 	}
 
-	return &Workflow{dest.ID, dest.Enabled, dest.Label, processCriteriaModels(*dest.Criteria.Get()), *dest.Targets.Get()}
+	return &Workflow{dest.ID, dest.Enabled, dest.Label, dest.Sequential, processCriteriaModels(*dest.Criteria.Get()), *dest.Targets.Get(), dest.Hooks}
 }
 
 // GetAll queries the database for all workflows, and all the related information.
@@ -191,7 +202,7 @@ func (store *Store) GetAll(db database.Queryable) []*Workflow {
 
 	output := make([]*Workflow, len(dest))
 	for i, v := range dest {
-		output[i] = &Workflow{v.ID, v.Enabled, v.Label, processCriteriaModels(*v.Criteria.Get()), *v.Targets.Get()}
+		output[i] = &Workflow{v.ID, v.Enabled, v.Label, v.Sequential, processCriteriaModels(*v.Criteria.Get()), *v.Targets.Get(), v.Hooks}
 	}
 	return output
 }
@@ -211,14 +222,16 @@ func getWorkflowSQL(whereClause string) string {
 		SELECT
 			w.*,
 			COALESCE(JSONB_AGG(DISTINCT wc.*) FILTER (WHERE wc.id IS NOT NULL), '[]') AS criteria,
-			COALESCE(JSONB_AGG(DISTINCT tt.*) FILTER (WHERE tt.id IS NOT NULL), '[]') AS targets
+			COALESCE((
+				SELECT JSONB_AGG(tt.* ORDER BY wtt.position)
+				FROM workflow_transcode_targets wtt
+				INNER JOIN transcode_target tt
+					ON tt.id = wtt.transcode_target_id
+				WHERE wtt.workflow_id = w.id
+			), '[]') AS targets
 		FROM workflow w
 		LEFT JOIN workflow_criteria wc
 			ON wc.workflow_id = w.id
-		LEFT JOIN workflow_transcode_targets wtt
-			ON wtt.workflow_id = w.id
-		LEFT JOIN transcode_target tt
-			ON tt.id = wtt.transcode_target_id
 		%s
 		GROUP BY w.id
 	`, whereClause)
@@ -227,7 +240,7 @@ func getWorkflowSQL(whereClause string) string {
 func buildWorkflowTargetAssocs(workflowID uuid.UUID, targetIDs []uuid.UUID) []workflowTargetAssoc {
 	assocs := make([]workflowTargetAssoc, len(targetIDs))
 	for i, v := range targetIDs {
-		assocs[i] = workflowTargetAssoc{uuid.New(), workflowID, v}
+		assocs[i] = workflowTargetAssoc{uuid.New(), workflowID, v, i}
 	}
 
 	return assocs
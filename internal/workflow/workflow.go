@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -13,12 +14,22 @@ import (
 
 var log = logger.Get("Workflow")
 
+// ErrTargetIDMissing is returned when a workflow is saved with targets which do not exist.
+var ErrTargetIDMissing = errors.New("one or more of the targets provided cannot be found")
+
 type Workflow struct {
-	ID       uuid.UUID
-	Enabled  bool
-	Label    string // unique
-	Criteria []match.Criteria
-	Targets  []*ffmpeg.Target // join table
+	ID      uuid.UUID
+	Enabled bool
+	Label   string // unique
+
+	// Sequential workflows queue the task for each of their targets only once the task
+	// for the prior target of the same media has finished (see Targets).
+	Sequential bool
+	Criteria   []match.Criteria
+	Targets    []*ffmpeg.Target // join table, in order
+
+	// Hooks are executed, in order, once each transcode queued by the workflow completes.
+	Hooks Hooks
 }
 
 func (workflow *Workflow) IsMediaEligible(media *media.Container) bool {
@@ -29,6 +40,12 @@ func (workflow *Workflow) IsMediaEligible(media *media.Container) bool {
 		return false
 	}
 
+	return workflow.MatchesMedia(media)
+}
+
+// MatchesMedia returns true if the media provided satisfies the criteria of the
+// workflow, regardless of whether the workflow is enabled.
+func (workflow *Workflow) MatchesMedia(media *media.Container) bool {
 	// Check that this item matches the conditions specified by the profile. If there
 	// are no conditions then just default to true
 	if len(workflow.Criteria) == 0 {
@@ -81,6 +81,18 @@ func Test_Workflow_EpisodeEligible(t *testing.T) {
 	runCommonMediaWorkflowTests(t, episode)
 }
 
+func Test_Workflow_DisabledMatchesMedia(t *testing.T) {
+	movie := &media.Container{
+		Type:  media.MovieContainerType,
+		Movie: &media.Movie{Model: media.Model{Title: "Example Movie"}},
+	}
+
+	wf := createEmptyWorkflow([]match.Criteria{{Key: match.MediaTitleKey, Type: match.Matches, Value: "/Example/"}})
+	wf.Enabled = false
+	assert.False(t, wf.IsMediaEligible(movie), "disabled workflows should not be eligible for new media")
+	assert.True(t, wf.MatchesMedia(movie), "disabled workflows should still match media when applied manually")
+}
+
 // runCommonMediaWorkflowTests runs a set of workflowTests which should
 // be valid for any type of media (movie or episode).
 func runCommonMediaWorkflowTests(t *testing.T, container *media.Container) {
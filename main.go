@@ -11,24 +11,34 @@ import (
 	"syscall"
 
 	"github.com/hbomb79/Thea/internal"
+	"github.com/hbomb79/Thea/internal/reload"
+	"github.com/hbomb79/Thea/internal/sysinfo"
 	"github.com/hbomb79/Thea/pkg/logger"
 )
 
-const VERSION = 1.0
+type reloadable interface {
+	ReloadConfig() (*reload.Report, error)
+}
 
 var (
 	log = logger.Get("Bootstrap")
 
-	conf         = &internal.TheaConfig{}
-	logLevelFlag = flag.String("log-level", "info", "Define logging level from one of [verbose, debug, info, important, warning, error]")
-	helpFlag     = flag.Bool("help", false, "Whether to display help information")
-	configFlag   = flag.String("config", filepath.Join(conf.GetConfigDir(), "/config.toml"), "The path to the config file that Thea will load")
+	conf          = &internal.TheaConfig{}
+	logLevelFlag  = flag.String("log-level", "info", "Define logging level from one of [verbose, debug, info, important, warning, error]")
+	logFormatFlag = flag.String("log-format", "text", "Define logging output format from one of [text, json]")
+	helpFlag      = flag.Bool("help", false, "Whether to display help information. Run 'thea [flags] doctor' to validate the configuration and check the system meets Thea's requirements, or 'thea [flags] user <create|list|set-password|set-permissions>' to manage users")
+	restoreFlag   = flag.String("restore", "", "The path to a database backup to restore when Thea starts. WARNING: all existing data will be replaced")
+	configFlag    = flag.String("config", filepath.Join(conf.GetConfigDir(), "/config.toml"), "The path to the config file that Thea will load")
+
+	migrationStatusFlag = flag.Bool("migration-status", false, "Print the status of the database migrations and exit")
+	migrateToFlag       = flag.Int64("migrate-to", -1, "Migrate the database to the schema version provided and exit")
+	allowDowngradeFlag  = flag.Bool("allow-downgrade", false, "Allow -migrate-to to reverse migrations. WARNING: data stored by the reversed migrations is deleted, take a backup first")
 )
 
 func main() {
 	flag.Parse()
 
-	level, err := parseLogLevelFromString(*logLevelFlag)
+	level, err := logger.ParseLevel(*logLevelFlag)
 	if err != nil {
 		fmt.Println(err)
 		flag.Usage()
@@ -37,25 +47,91 @@ func main() {
 	}
 	logger.SetMinLoggingLevel(level)
 
+	format, err := parseLogFormatFromString(*logFormatFlag)
+	if err != nil {
+		fmt.Println(err)
+		flag.Usage()
+
+		return
+	}
+	logger.SetOutputFormat(format)
+
 	if *helpFlag {
 		flag.Usage()
-	} else {
-		log.Emit(logger.DEBUG, "Loading configuration from '%s'\n", *configFlag)
-		if err := conf.LoadFromFile(*configFlag); err != nil {
-			panic(err)
+		return
+	}
+
+	switch flag.Arg(0) {
+	case "", "user":
+	case "doctor":
+		if !internal.Doctor(*configFlag) {
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Printf("Unknown command '%s'. Supported commands: doctor, user\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	log.Emit(logger.DEBUG, "Loading configuration from '%s'\n", *configFlag)
+	if err := conf.LoadFromFile(*configFlag); err != nil {
+		exitWithConfigError(err)
+	}
+	if err := conf.Validate(); err != nil {
+		exitWithConfigError(err)
+	}
+
+	// The log level flag takes precedence over the level in the config
+	if conf.LogLevel != "" && !isFlagProvided("log-level") {
+		level, _ := logger.ParseLevel(conf.LogLevel)
+		logger.SetMinLoggingLevel(level)
+	}
+
+	if *migrationStatusFlag {
+		if err := internal.PrintMigrationStatus(*conf, os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
+		return
+	} else if *migrateToFlag >= 0 {
+		if err := internal.MigrateTo(*conf, *migrateToFlag, *allowDowngradeFlag); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Database migrated to schema version %d\n", *migrateToFlag)
+		return
+	}
 
-		startThea(conf)
+	if flag.Arg(0) == "user" {
+		if err := internal.RunUserCommand(*conf, flag.Args()[1:], os.Stdin, os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
 	}
+
+	conf.Backup.RestoreFrom = *restoreFlag
+	startThea(conf)
+}
+
+// exitWithConfigError reports that the configuration is invalid (or could not
+// be loaded), and exits Thea.
+func exitWithConfigError(err error) {
+	fmt.Printf("Configuration is invalid:\n%v\n\nRun 'thea doctor' for help diagnosing the problem.\n", err)
+	os.Exit(1)
 }
 
 func startThea(config *internal.TheaConfig) {
-	log.Emit(logger.INFO, " --- Starting Thea (version %.1f) ---\n", VERSION)
+	log.Emit(logger.INFO, " --- Starting Thea (version %s) ---\n", sysinfo.Version)
 
 	ctx, ctxCancel := context.WithCancel(context.Background())
 	go listenForInterrupt(ctxCancel)
 
-	if err := internal.New(*config).Run(ctx); err != nil {
+	thea := internal.New(*config)
+	go listenForReload(ctx, thea)
+
+	if err := thea.Run(ctx); err != nil {
 		log.Fatalf("Failed to start Thea: %v\n", err)
 		os.Exit(1)
 	}
@@ -72,21 +148,44 @@ func listenForInterrupt(ctxCancel context.CancelFunc) {
 	ctxCancel()
 }
 
-func parseLogLevelFromString(l string) (logger.LogLevel, error) {
-	switch strings.ToLower(l) {
-	case "verbose":
-		return logger.VERBOSE.Level(), nil
-	case "debug":
-		return logger.DEBUG.Level(), nil
-	case "info":
-		return logger.INFO.Level(), nil
-	case "important":
-		return logger.SUCCESS.Level(), nil
-	case "warning":
-		return logger.WARNING.Level(), nil
-	case "error":
-		return logger.ERROR.Level(), nil
+// listenForReload reloads Thea's configuration each time a SIGHUP
+// is received, until the context provided is cancelled.
+func listenForReload(ctx context.Context, thea reloadable) {
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+	defer signal.Stop(reloadChannel)
+
+	for {
+		select {
+		case <-reloadChannel:
+			log.Emit(logger.INFO, "SIGHUP received, reloading configuration...\n")
+			if _, err := thea.ReloadConfig(); err != nil {
+				log.Errorf("Failed to reload configuration: %v\n", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func isFlagProvided(name string) bool {
+	provided := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			provided = true
+		}
+	})
+
+	return provided
+}
+
+func parseLogFormatFromString(f string) (logger.OutputFormat, error) {
+	switch strings.ToLower(f) {
+	case "text":
+		return logger.TextFormat, nil
+	case "json":
+		return logger.JSONFormat, nil
 	default:
-		return logger.INFO.Level(), fmt.Errorf("logging level %s is not recognized", l)
+		return logger.TextFormat, fmt.Errorf("logging format %s is not recognized", f)
 	}
 }
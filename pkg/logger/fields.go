@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fields are key-value pairs which are attached to every log line
+// emitted by a logger, allowing related log lines (e.g. all lines
+// emitted for a single request or transcode) to be correlated.
+type Fields map[string]any
+
+type fieldsContextKey struct{}
+
+// merge returns a new set of fields containing the receivers fields,
+// combined with the fields provided. If a key is present in both,
+// the value from the provided fields is used.
+func (f Fields) merge(other Fields) Fields {
+	merged := make(Fields, len(f)+len(other))
+	for k, v := range f {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// String returns the fields formatted as space-separated 'key=value'
+// pairs, sorted by key. An empty string is returned if there are no fields.
+func (f Fields) String() string {
+	if len(f) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf(" %s=%v", k, f[k]))
+	}
+
+	return sb.String()
+}
+
+// ContextWithFields returns a copy of the context provided which carries the
+// fields given, in addition to any fields already stored in the context. Loggers
+// derived from this context (see Logger.WithContext) will include these fields.
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	return context.WithValue(ctx, fieldsContextKey{}, FieldsFromContext(ctx).merge(fields))
+}
+
+// FieldsFromContext returns the fields stored in the context provided, or
+// nil if the context has no fields.
+func FieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+
+	if fields, ok := ctx.Value(fieldsContextKey{}).(Fields); ok {
+		return fields
+	}
+
+	return nil
+}
@@ -1,8 +1,12 @@
 package logger
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
@@ -82,6 +86,27 @@ func (e LogStatus) Level() LogLevel {
 	}
 }
 
+// ParseLevel returns the LogLevel with the name provided, which is one of
+// verbose, debug, info, important, warning or error (case-insensitive).
+func ParseLevel(l string) (LogLevel, error) {
+	switch strings.ToLower(l) {
+	case "verbose":
+		return VERBOSE.Level(), nil
+	case "debug":
+		return DEBUG.Level(), nil
+	case "info":
+		return INFO.Level(), nil
+	case "important":
+		return SUCCESS.Level(), nil
+	case "warning":
+		return WARNING.Level(), nil
+	case "error":
+		return ERROR.Level(), nil
+	default:
+		return INFO.Level(), fmt.Errorf("logging level %s is not recognized", l)
+	}
+}
+
 func (e LogStatus) String() string {
 	return []string{
 		"V",
@@ -97,6 +122,23 @@ func (e LogStatus) String() string {
 	}[e]
 }
 
+// Name returns the human-readable name of the status, used
+// when emitting structured (JSON) logs.
+func (e LogStatus) Name() string {
+	return []string{
+		"verbose",
+		"debug",
+		"info",
+		"success",
+		"new",
+		"remove",
+		"stop",
+		"warning",
+		"error",
+		"fatal",
+	}[e]
+}
+
 func (e LogStatus) Color() *color.Color {
 	return []*color.Color{
 		color.New(color.FgWhite, color.Faint, color.Italic),   // Verbose
@@ -112,7 +154,27 @@ func (e LogStatus) Color() *color.Color {
 	}[e]
 }
 
+// OutputFormat controls how log lines are written to the output.
+type OutputFormat int
+
+const (
+	// TextFormat emits colored, human-readable log lines.
+	TextFormat OutputFormat = iota
+
+	// JSONFormat emits a single JSON object per log line, suitable
+	// for consumption by log aggregation tools.
+	JSONFormat
+)
+
 type Logger interface {
+	// WithFields returns a new Logger which includes the fields provided (in addition
+	// to any fields already present on this logger) in every log line it emits.
+	WithFields(fields Fields) Logger
+
+	// WithContext returns a new Logger which includes any fields stored
+	// in the context provided (see ContextWithFields).
+	WithContext(ctx context.Context) Logger
+
 	Emit(status LogStatus, pattern string, args ...any)
 	Verbosef(pattern string, args ...any)
 	Debugf(pattern string, args ...any)
@@ -124,11 +186,20 @@ type Logger interface {
 }
 
 type loggerImpl struct {
-	name string
+	name   string
+	fields Fields
+}
+
+func (l *loggerImpl) WithFields(fields Fields) Logger {
+	return &loggerImpl{name: l.name, fields: l.fields.merge(fields)}
+}
+
+func (l *loggerImpl) WithContext(ctx context.Context) Logger {
+	return l.WithFields(FieldsFromContext(ctx))
 }
 
 func (l *loggerImpl) Emit(status LogStatus, message string, interpolations ...interface{}) {
-	manager.Emit(status, l.name, message, interpolations...)
+	manager.Emit(status, l.name, l.fields, message, interpolations...)
 }
 
 func (l *loggerImpl) Verbosef(m string, v ...any) { l.Emit(VERBOSE, m, v...) }
@@ -140,38 +211,76 @@ func (l *loggerImpl) Errorf(m string, v ...any)   { l.Emit(ERROR, m, v...) }
 func (l *loggerImpl) Fatalf(m string, v ...any)   { l.Emit(FATAL, m, v...) }
 
 var manager = &loggerMgr{
+	Mutex:             &sync.Mutex{},
 	offset:            0,
 	minLevel:          info,
 	includeTimestamps: true,
+	format:            TextFormat,
 }
 
 type loggerMgr struct {
+	*sync.Mutex
 	offset            int
 	minLevel          LogLevel
 	includeTimestamps bool
+	format            OutputFormat
 }
 
 func (l *loggerMgr) GetLogger(name string) *loggerImpl {
 	return &loggerImpl{name: name}
 }
 
-func (l *loggerMgr) Emit(status LogStatus, name string, message string, interpolations ...interface{}) {
+func (l *loggerMgr) Emit(status LogStatus, name string, fields Fields, message string, interpolations ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+
 	if status.Level() < l.minLevel {
 		return
 	}
 
+	message = strings.TrimSpace(fmt.Sprintf(message, interpolations...))
+	if l.format == JSONFormat {
+		l.emitJSON(status, name, fields, message)
+		return
+	}
+
 	l.setNameOffset(len(name))
 	padding := strings.Repeat(" ", l.offset-len(name))
+	message += fields.String()
 
 	if l.includeTimestamps {
-		msg := fmt.Sprintf("%s [%s] %s(%s) %s", time.Now().Format(time.RFC3339), name, padding, status, strings.TrimSpace(fmt.Sprintf(message, interpolations...))+"\n")
+		msg := fmt.Sprintf("%s [%s] %s(%s) %s", time.Now().Format(time.RFC3339), name, padding, status, message+"\n")
 		_, _ = status.Color().Print(msg)
 	} else {
-		msg := fmt.Sprintf("[%s] %s(%s) %s", name, padding, status, strings.TrimSpace(fmt.Sprintf(message, interpolations...))+"\n")
+		msg := fmt.Sprintf("[%s] %s(%s) %s", name, padding, status, message+"\n")
 		_, _ = status.Color().Print(msg)
 	}
 }
 
+// emitJSON writes the log line as a single JSON object. The fields provided
+// are included as top-level keys, however they cannot override the
+// keys used by the logger itself (time, level, logger and message).
+func (l *loggerMgr) emitJSON(status LogStatus, name string, fields Fields, message string) {
+	line := make(map[string]any, len(fields)+4)
+	for k, v := range fields {
+		line[k] = v
+	}
+
+	if l.includeTimestamps {
+		line["time"] = time.Now().Format(time.RFC3339)
+	}
+	line["level"] = status.Name()
+	line["logger"] = name
+	line["message"] = message
+
+	out, err := json.Marshal(line)
+	if err != nil {
+		out, _ = json.Marshal(map[string]any{"level": ERROR.Name(), "logger": name, "message": fmt.Sprintf("failed to marshal log line: %v", err)})
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, string(out))
+}
+
 func (l *loggerMgr) setNameOffset(offset int) {
 	if offset > l.offset {
 		l.offset = offset
@@ -186,6 +295,10 @@ func (l *loggerMgr) setIncludeTimestamp(include bool) {
 	l.includeTimestamps = include
 }
 
+func (l *loggerMgr) setOutputFormat(format OutputFormat) {
+	l.format = format
+}
+
 func Get(name string) *loggerImpl {
 	return manager.GetLogger(name)
 }
@@ -197,3 +310,15 @@ func SetIncludeTimestamps(include bool) {
 func SetMinLoggingLevel(level LogLevel) {
 	manager.setMinLoggingLevel(level)
 }
+
+func SetOutputFormat(format OutputFormat) {
+	manager.Lock()
+	defer manager.Unlock()
+	manager.setOutputFormat(format)
+}
+
+func GetOutputFormat() OutputFormat {
+	manager.Lock()
+	defer manager.Unlock()
+	return manager.format
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"errors"
+	"slices"
 	"sync"
 )
 
@@ -10,7 +11,12 @@ import (
 // automatically controlled by the WorkerPool. The 'workers'
 // field is a slice that contains all the workers
 // attached to this WorkerPool.
+//
+// Workers can be pushed to, and removed from, a started pool. Removed
+// workers are drained: they finish their current task before exiting,
+// and Close continues to wait for them.
 type WorkerPool struct {
+	sync.Mutex
 	workers []Worker
 	Wg      sync.WaitGroup
 	started bool
@@ -31,17 +37,16 @@ func NewWorkerPool() *WorkerPool {
 // can wait on the WaitGroup in the pool if they
 // wish.
 func (pool *WorkerPool) Start() error {
+	pool.Lock()
+	defer pool.Unlock()
+
 	if pool.started {
 		return errors.New("cannot start an already started worker pool")
 	}
 
 	pool.started = true
 	for _, worker := range pool.workers {
-		pool.Wg.Add(1)
-		go func(wg *sync.WaitGroup, w Worker) {
-			defer wg.Done()
-			w.Start()
-		}(&pool.Wg, worker)
+		pool.startWorker(worker)
 	}
 
 	return nil
@@ -49,19 +54,64 @@ func (pool *WorkerPool) Start() error {
 
 // PushWorker inserts the worker provided in to the worker pool,
 // this method will first lock the mutex to ensure mutually exclusive
-// access to the worker pool slice.
+// access to the worker pool slice. If the pool has already been
+// started, the workers are started immediately.
 func (pool *WorkerPool) PushWorker(workers ...Worker) error {
+	pool.Lock()
+	defer pool.Unlock()
+
+	pool.workers = append(pool.workers, workers...)
 	if pool.started {
-		return errors.New("cannot push worker to already started worker pool")
+		for _, worker := range workers {
+			pool.startWorker(worker)
+		}
 	}
 
-	pool.workers = append(pool.workers, workers...)
 	return nil
 }
 
+// RemoveWorkers removes up to 'count' workers from the pool (the most recently
+// pushed first) and closes them, returning the workers removed. Workers which are
+// performing a task are not interrupted, and exit once their task is complete.
+func (pool *WorkerPool) RemoveWorkers(count int) []Worker {
+	pool.Lock()
+	defer pool.Unlock()
+
+	count = min(count, len(pool.workers))
+	removed := slices.Clone(pool.workers[len(pool.workers)-count:])
+	pool.workers = pool.workers[:len(pool.workers)-count]
+	if pool.started {
+		for _, w := range removed {
+			w.Close()
+		}
+	}
+
+	return removed
+}
+
+// Size returns the number of workers in the pool. Workers which have been
+// removed but are still draining are not included.
+func (pool *WorkerPool) Size() int {
+	pool.Lock()
+	defer pool.Unlock()
+
+	return len(pool.workers)
+}
+
+func (pool *WorkerPool) startWorker(worker Worker) {
+	pool.Wg.Add(1)
+	go func(wg *sync.WaitGroup, w Worker) {
+		defer wg.Done()
+		w.Start()
+	}(&pool.Wg, worker)
+}
+
 // WakeupWorkers will search for sleeping workers in the pool
 // and will send on their WakeupChannel to wake up sleeping workers.
 func (pool *WorkerPool) WakeupWorkers() error {
+	pool.Lock()
+	defer pool.Unlock()
+
 	if !pool.started {
 		return errors.New("cannot wakeup workers on worker pool that is not started")
 	}
@@ -81,13 +131,17 @@ func (pool *WorkerPool) WakeupWorkers() error {
 // Close will cycle through all the workers inside this
 // worker pool and close their wakeup channels.
 func (pool *WorkerPool) Close() {
+	pool.Lock()
 	if !pool.started {
+		pool.Unlock()
 		return
 	}
 
 	for _, w := range pool.workers {
 		w.Close()
 	}
-	pool.Wg.Wait()
 	pool.started = false
+	pool.Unlock()
+
+	pool.Wg.Wait()
 }
@@ -138,7 +138,7 @@ func (client *APIClient) ListMedia(t *testing.T) []gen.MediaListItem {
 	assert.NotNil(t, resp)
 	assert.NotNil(t, resp.JSON200)
 
-	return *resp.JSON200
+	return resp.JSON200.Items
 }
 
 type (
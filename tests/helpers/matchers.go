@@ -1,7 +1,8 @@
 package helpers
 
 import (
-	"github.com/hbomb79/Thea/internal/api/controllers/ingests"
+	"github.com/hbomb79/Thea/internal/api"
+	"github.com/hbomb79/Thea/internal/api/dto"
 	"github.com/hbomb79/Thea/internal/http/websocket"
 	"github.com/hbomb79/Thea/internal/ingest"
 	"github.com/hbomb79/go-chanassert"
@@ -13,12 +14,20 @@ func MatchSocketMessage(title string, typ websocket.SocketMessageType) chanasser
 	return chanassert.MatchStructPartial(websocket.SocketMessage{Title: title, Type: typ})
 }
 
+// MatchValidActivity returns a matcher which will match activity messages
+// with the title provided whose arguments match the activity schema catalog.
+func MatchValidActivity(title string) chanassert.Matcher[websocket.SocketMessage] {
+	return chanassert.MatchPredicate(func(message websocket.SocketMessage) bool {
+		return message.Title == title && api.ValidateActivityMessage(&message) == nil
+	})
+}
+
 // MatchIngestUpdate returns a chanassert matcher which will
 // match any websocket messages regarding ingestion updates
 // which contain the given ingest.
 func MatchIngestUpdate(path string, state ingest.IngestItemState) chanassert.Matcher[websocket.SocketMessage] {
 	return chanassert.MatchPredicate(func(message websocket.SocketMessage) bool {
-		if message.Title != "INGEST_UPDATE" {
+		if message.Title != api.TitleIngestUpdate || api.ValidateActivityMessage(&message) != nil {
 			return false
 		}
 
@@ -27,13 +36,13 @@ func MatchIngestUpdate(path string, state ingest.IngestItemState) chanassert.Mat
 			return false
 		}
 
-		return updatedIngest["path"] == path && updatedIngest["state"] == string(ingests.IngestStateModelToDto(state))
+		return updatedIngest["path"] == path && updatedIngest["state"] == string(dto.FromIngestState(state))
 	})
 }
 
 func MatchMovieEvent(path string) chanassert.Matcher[websocket.SocketMessage] {
 	return chanassert.MatchPredicate(func(message websocket.SocketMessage) bool {
-		if message.Title != "MEDIA_UPDATE" {
+		if message.Title != api.TitleMediaUpdate || api.ValidateActivityMessage(&message) != nil {
 			return false
 		}
 
@@ -23,28 +23,30 @@ func AssertErrorResponse[T any](t *testing.T, response T, expectedStatusCode int
 
 	if httpResponse, ok := responseValue.Interface().(*http.Response); ok {
 		assert.Equal(t, httpResponse.StatusCode, expectedStatusCode, "HTTPResponse status code did not match expected")
+		assert.Equal(t, httpResponse.Header.Get("Content-Type"), gen.ProblemContentType)
 	}
 
 	if bodyBytes, ok := bodyValue.Interface().([]byte); ok {
-		apiErr := ExtractErrorResponse(t, bodyBytes)
+		problem := ExtractErrorResponse(t, bodyBytes)
 		if expectedMessage == "" {
-			assert.Equal(t, apiErr.Message, http.StatusText(expectedStatusCode))
+			assert.Equal(t, problem.Detail, http.StatusText(expectedStatusCode))
 		} else {
-			assert.Equal(t, apiErr.Message, expectedMessage)
+			assert.Equal(t, problem.Detail, expectedMessage)
 		}
 		if expectedErrorCode != "" {
-			assert.Equal(t, apiErr.Code, expectedErrorCode)
+			assert.Equal(t, problem.Code, expectedErrorCode)
 		}
-		assert.Equal(t, apiErr.InternalMessage, "") // Internal message should never leak
-		assert.Equal(t, apiErr.Status, 0)           // Status should not be included
+		assert.Equal(t, problem.Status, expectedStatusCode)
+		assert.Equal(t, problem.Title, http.StatusText(expectedStatusCode))
+		assert.Assert(t, problem.Code != "", "Problem should always carry an error code")
 	}
 }
 
-func ExtractErrorResponse(t *testing.T, body []byte) gen.APIError {
-	var apiError gen.APIError
-	if err := json.Unmarshal(body, &apiError); err != nil {
-		t.Errorf("Could not extract APIError from HTTP response body: %s", err)
+func ExtractErrorResponse(t *testing.T, body []byte) gen.Problem {
+	var problem gen.Problem
+	if err := json.Unmarshal(body, &problem); err != nil {
+		t.Errorf("Could not extract Problem from HTTP response body: %s", err)
 	}
 
-	return apiError
+	return problem
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api"
 	"github.com/hbomb79/Thea/internal/http/websocket"
 	"github.com/hbomb79/Thea/internal/user/permissions"
 	"github.com/hbomb79/Thea/tests/gen"
@@ -99,6 +100,23 @@ func TestLogout_BlacklistsTokens(t *testing.T) {
 	helpers.AssertErrorResponse(t, *failedCurrentUserResponse, http.StatusForbidden, "", "")
 }
 
+// Ensures that linking a Trakt account is rejected while the Trakt
+// integration is not configured.
+func TestTrakt_NotConfigured(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, authedClient := srv.NewClientWithRandomUser(t)
+
+	linkResp, err := authedClient.GetOwnTraktLinkWithResponse(ctx)
+	assert.Nil(t, err, "Failed to get Trakt link")
+	assert.Equal(t, http.StatusServiceUnavailable, linkResp.StatusCode())
+
+	startResp, err := authedClient.LinkOwnTraktWithResponse(ctx)
+	assert.Nil(t, err, "Failed to link Trakt account")
+	assert.Equal(t, http.StatusServiceUnavailable, startResp.StatusCode())
+}
+
 // Ensures that all tokens for a specific user are blacklisted
 // when 'LogoutAll' is called. Other users active on Thea should
 // not be impacted by this.
@@ -178,7 +196,7 @@ func makeActivityListener(
 	}
 
 	if ingests {
-		combiners = append(combiners, chanassert.AtLeastNOf(1, helpers.MatchMessageTitle("INGEST_UPDATE")))
+		combiners = append(combiners, chanassert.AtLeastNOf(1, helpers.MatchValidActivity(api.TitleIngestUpdate)))
 	}
 
 	if transcodes {
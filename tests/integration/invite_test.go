@@ -0,0 +1,105 @@
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/hbomb79/Thea/tests/gen"
+	"github.com/hbomb79/Thea/tests/helpers"
+	"github.com/labstack/gommon/random"
+	"github.com/stretchr/testify/assert"
+)
+
+const inviteInvalidMessage = "invite is invalid, expired or has already been used"
+
+// Ensures that an invite can only be consumed once, and that the user
+// registered with it is granted the permissions the invite is bound to.
+func TestInvite_SingleUse(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, adminClient := srv.NewClientWithDefaultAdminUser(t)
+	invite := createInvite(t, adminClient, gen.CreateInviteRequest{Permissions: &[]string{permissions.AccessMediaPermission}})
+
+	registered := registerWithInvite(t, srv.NewClient(t), *invite.Token)
+	assert.Equal(t, http.StatusCreated, registered.StatusCode())
+	assert.NotNil(t, registered.JSON201)
+	assert.ElementsMatch(t, []string{permissions.AccessMediaPermission}, registered.JSON201.Permissions)
+
+	reused := registerWithInvite(t, srv.NewClient(t), *invite.Token)
+	assert.Nil(t, reused.JSON201)
+	helpers.AssertErrorResponse(t, *reused, http.StatusBadRequest, inviteInvalidMessage, "invite.invalid")
+}
+
+// Ensures that an expired invite cannot be consumed.
+func TestInvite_Expired(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, adminClient := srv.NewClientWithDefaultAdminUser(t)
+	expiresAt := time.Now().Add(-time.Hour)
+	invite := createInvite(t, adminClient, gen.CreateInviteRequest{ExpiresAt: &expiresAt})
+
+	registered := registerWithInvite(t, srv.NewClient(t), *invite.Token)
+	assert.Nil(t, registered.JSON201)
+	helpers.AssertErrorResponse(t, *registered, http.StatusBadRequest, inviteInvalidMessage, "invite.invalid")
+}
+
+// Ensures that an invite cannot grant permissions (directly, or via
+// a role) which the user creating the invite does not hold.
+func TestInvite_CannotGrantUnheldPermissions(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, adminClient := srv.NewClientWithDefaultAdminUser(t)
+	_, client := srv.NewClientWithRandomUserPermissions(t, []string{
+		permissions.CreateInvitePermission,
+		permissions.AccessMediaPermission,
+	})
+
+	expectedMessage := fmt.Sprintf("Cannot create invite which grants permission '%s' as you do not hold it", permissions.DeleteMediaPermission)
+	resp, err := client.CreateInviteWithResponse(ctx, gen.CreateInviteRequest{
+		Permissions: &[]string{permissions.AccessMediaPermission, permissions.DeleteMediaPermission},
+	})
+	assert.Nil(t, err)
+	helpers.AssertErrorResponse(t, *resp, http.StatusForbidden, expectedMessage, "")
+
+	roleResp, err := adminClient.CreateRoleWithResponse(ctx, gen.CreateRoleRequest{
+		Label:       fmt.Sprintf("TestRole%s", random.String(16)),
+		Permissions: []string{permissions.DeleteMediaPermission},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, roleResp.StatusCode())
+
+	resp, err = client.CreateInviteWithResponse(ctx, gen.CreateInviteRequest{RoleIds: &[]uuid.UUID{roleResp.JSON201.Id}})
+	assert.Nil(t, err)
+	helpers.AssertErrorResponse(t, *resp, http.StatusForbidden, expectedMessage, "")
+
+	invite := createInvite(t, client, gen.CreateInviteRequest{Permissions: &[]string{permissions.AccessMediaPermission}})
+	assert.ElementsMatch(t, []string{permissions.AccessMediaPermission}, invite.Permissions)
+}
+
+func createInvite(t *testing.T, client *helpers.APIClient, request gen.CreateInviteRequest) gen.Invite {
+	resp, err := client.CreateInviteWithResponse(ctx, request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	assert.NotNil(t, resp.JSON201)
+	assert.NotNil(t, resp.JSON201.Token)
+
+	return *resp.JSON201
+}
+
+func registerWithInvite(t *testing.T, client *helpers.APIClient, token string) *gen.RegisterResponse {
+	usernameAndPassword := fmt.Sprintf("TestUser%s", random.String(16))
+	resp, err := client.RegisterWithResponse(ctx, &gen.RegisterParams{Invite: token}, gen.RegisterRequest{
+		Username: usernameAndPassword,
+		Password: usernameAndPassword,
+	})
+	assert.Nil(t, err)
+
+	return resp
+}
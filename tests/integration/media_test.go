@@ -0,0 +1,150 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/tests/gen"
+	"github.com/hbomb79/Thea/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMedia_Trash ensures that the trash is initially empty, and that
+// attempting to restore media which is not in the trash is rejected.
+func TestMedia_Trash(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	listResp, err := client.ListTrashWithResponse(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, listResp.StatusCode())
+	if assert.NotNil(t, listResp.JSON200) {
+		assert.Empty(t, *listResp.JSON200)
+	}
+
+	restoreResp, err := client.RestoreFromTrashWithResponse(ctx, uuid.New())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, restoreResp.StatusCode())
+}
+
+// TestMedia_Batch ensures that unknown IDs are omitted from the
+// batch response, and that the maximum batch size is enforced.
+func TestMedia_Batch(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	resp, err := client.GetMediaBatchWithResponse(ctx, gen.GetMediaBatchJSONRequestBody{Ids: []uuid.UUID{uuid.New(), uuid.New()}})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	if assert.NotNil(t, resp.JSON200) {
+		assert.Empty(t, *resp.JSON200)
+	}
+
+	ids := make([]uuid.UUID, 101)
+	for k := range ids {
+		ids[k] = uuid.New()
+	}
+	resp, err = client.GetMediaBatchWithResponse(ctx, gen.GetMediaBatchJSONRequestBody{Ids: ids})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+}
+
+// TestMedia_WatchProgress ensures that recording progress for
+// unknown media is rejected.
+func TestMedia_WatchProgress(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	resp, err := client.UpdateWatchProgressWithResponse(ctx, uuid.New(), gen.UpdateWatchProgressJSONRequestBody{PositionSeconds: 60})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+}
+
+// TestMedia_Metadata ensures that correcting or locking the metadata
+// of media which does not exist is rejected.
+func TestMedia_Metadata(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	title := "Corrected Title"
+	updateResp, err := client.UpdateMediaMetadataWithResponse(ctx, uuid.New(), gen.UpdateMediaMetadataJSONRequestBody{Title: &title})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, updateResp.StatusCode())
+
+	lockResp, err := client.SetMediaMetadataLocksWithResponse(ctx, uuid.New(), gen.SetMediaMetadataLocksJSONRequestBody{
+		LockedFields: []gen.MediaMetadataField{gen.MediaMetadataField("TITLE")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, lockResp.StatusCode())
+}
+
+// TestMedia_Update ensures that correcting the metadata of a movie, series,
+// season or episode which does not exist is rejected.
+func TestMedia_Update(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	title := "Corrected Title"
+	movieResp, err := client.UpdateMovieWithResponse(ctx, uuid.New(), gen.UpdateMovieJSONRequestBody{Title: &title})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, movieResp.StatusCode())
+
+	seriesResp, err := client.UpdateSeriesWithResponse(ctx, uuid.New(), gen.UpdateSeriesJSONRequestBody{Title: &title})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, seriesResp.StatusCode())
+
+	seasonNumber := 2
+	seasonResp, err := client.UpdateSeasonWithResponse(ctx, uuid.New(), gen.UpdateSeasonJSONRequestBody{SeasonNumber: &seasonNumber})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, seasonResp.StatusCode())
+
+	episodeNumber := 3
+	episodeResp, err := client.UpdateEpisodeWithResponse(ctx, uuid.New(), gen.UpdateEpisodeJSONRequestBody{EpisodeNumber: &episodeNumber})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, episodeResp.StatusCode())
+}
+
+// TestMedia_WatchTargets ensures that fetching the aggregated watch
+// targets of a series or season which does not exist is rejected.
+func TestMedia_WatchTargets(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	seriesResp, err := client.GetSeriesWatchTargetsWithResponse(ctx, uuid.New())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, seriesResp.StatusCode())
+
+	seasonResp, err := client.GetSeasonWatchTargetsWithResponse(ctx, uuid.New())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, seasonResp.StatusCode())
+}
+
+// TestMedia_HomeFeed ensures that the home feed of a user who has
+// not watched anything has no items to continue watching.
+func TestMedia_HomeFeed(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	resp, err := client.GetHomeFeedWithResponse(ctx, &gen.GetHomeFeedParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	if assert.NotNil(t, resp.JSON200) {
+		assert.Empty(t, resp.JSON200.ContinueWatching)
+		assert.Empty(t, resp.JSON200.NextUp)
+	}
+}
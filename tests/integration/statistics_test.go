@@ -0,0 +1,44 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hbomb79/Thea/tests/gen"
+	"github.com/hbomb79/Thea/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStatistics_EmptyLibrary ensures that the statistics for a
+// fresh Thea instance report an empty library.
+func TestStatistics_EmptyLibrary(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	resp, err := client.GetStatisticsWithResponse(ctx, &gen.GetStatisticsParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	if assert.NotNil(t, resp.JSON200) {
+		assert.Zero(t, resp.JSON200.MovieCount)
+		assert.Zero(t, resp.JSON200.SeriesCount)
+		assert.Zero(t, resp.JSON200.EpisodeCount)
+		assert.Zero(t, resp.JSON200.TranscodeCount)
+		assert.Empty(t, resp.JSON200.Codecs)
+		assert.Empty(t, resp.JSON200.TranscodeHistory)
+	}
+}
+
+func TestStatistics_InvalidWindow(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+
+	for _, days := range []int{0, 366} {
+		resp, err := client.GetStatisticsWithResponse(ctx, &gen.GetStatisticsParams{Days: &days})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	}
+}
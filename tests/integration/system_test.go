@@ -0,0 +1,66 @@
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hbomb79/Thea/tests/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHealth_Probes ensures that the unauthenticated liveness and readiness
+// probes agree with the detailed health report.
+func TestHealth_Probes(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+	resp, err := client.GetHealthWithResponse(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	if !assert.NotNil(t, resp.JSON200) {
+		return
+	}
+
+	names := make([]string, 0, len(resp.JSON200.Checks))
+	for _, check := range resp.JSON200.Checks {
+		names = append(names, check.Name)
+	}
+	assert.ElementsMatch(t, []string{"database", "migrations", "ffmpeg", "event-bus"}, names)
+
+	probe := func(path string) int {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://localhost:%d/%s", srv.Port, path), nil)
+		assert.NoError(t, err)
+
+		probeResp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer probeResp.Body.Close()
+
+		return probeResp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, probe("healthz"))
+	if resp.JSON200.Ready {
+		assert.Equal(t, http.StatusOK, probe("readyz"))
+	} else {
+		assert.Equal(t, http.StatusServiceUnavailable, probe("readyz"))
+	}
+}
+
+func TestSystemInfo(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, client := srv.NewClientWithDefaultAdminUser(t)
+	resp, err := client.GetSystemInfoWithResponse(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	if assert.NotNil(t, resp.JSON200) {
+		assert.NotEmpty(t, resp.JSON200.Version)
+		assert.Nil(t, resp.JSON200.Database.Error)
+		assert.NotNil(t, resp.JSON200.Database.SchemaVersion)
+		assert.Contains(t, resp.JSON200.Paths, "ingest")
+		assert.Contains(t, resp.JSON200.Features, "graphql")
+	}
+}
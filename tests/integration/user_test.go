@@ -0,0 +1,98 @@
+package integration_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/user/permissions"
+	"github.com/hbomb79/Thea/tests/gen"
+	"github.com/hbomb79/Thea/tests/helpers"
+	"github.com/labstack/gommon/random"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensures that the permissions of a user are the union of those assigned
+// directly and those granted by their roles, and that the direct permissions
+// are exposed separately so they can be written back without absorbing the
+// permissions of the users roles.
+func TestUserRoles_PermissionsUnion(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, adminClient := srv.NewClientWithDefaultAdminUser(t)
+	testUser, _ := srv.NewClientWithRandomUserPermissions(t, []string{permissions.AccessMediaPermission})
+
+	roleResp, err := adminClient.CreateRoleWithResponse(ctx, gen.CreateRoleRequest{
+		Label:       fmt.Sprintf("TestRole%s", random.String(16)),
+		Permissions: []string{permissions.AccessMediaPermission, permissions.EditMediaPermission},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, roleResp.StatusCode())
+	role := roleResp.JSON201
+
+	rolesResp, err := adminClient.UpdateUserRolesWithResponse(ctx, testUser.User.Id, gen.UpdateUserRolesRequest{RoleIds: []uuid.UUID{role.Id}})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rolesResp.StatusCode())
+
+	u := getUser(t, adminClient, testUser.User.Id)
+	assert.ElementsMatch(t, []string{permissions.AccessMediaPermission, permissions.EditMediaPermission}, u.Permissions)
+	assert.ElementsMatch(t, []string{permissions.AccessMediaPermission}, u.DirectPermissions)
+	assert.ElementsMatch(t, []uuid.UUID{role.Id}, u.Roles)
+
+	// Writing back the direct permissions must not grant the role permissions directly
+	permsResp, err := adminClient.UpdateUserPermissionsWithResponse(ctx, u.Id, gen.UpdateUserPermissionsRequest{
+		Permissions: append(u.DirectPermissions, permissions.DeleteMediaPermission),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, permsResp.StatusCode())
+
+	rolesResp, err = adminClient.UpdateUserRolesWithResponse(ctx, u.Id, gen.UpdateUserRolesRequest{RoleIds: []uuid.UUID{}})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rolesResp.StatusCode())
+
+	u = getUser(t, adminClient, u.Id)
+	assert.ElementsMatch(t, []string{permissions.AccessMediaPermission, permissions.DeleteMediaPermission}, u.Permissions)
+	assert.ElementsMatch(t, []string{permissions.AccessMediaPermission, permissions.DeleteMediaPermission}, u.DirectPermissions)
+	assert.Empty(t, u.Roles)
+}
+
+// Ensures that updating the roles of a user fails if any of the roles
+// cannot be found, and that the existing roles of the user are unchanged.
+func TestUserRoles_UnknownRole(t *testing.T) {
+	srv := helpers.RequireDefaultThea(t)
+	t.Parallel()
+
+	_, adminClient := srv.NewClientWithDefaultAdminUser(t)
+	testUser, _ := srv.NewClientWithRandomUserPermissions(t, []string{})
+
+	roleResp, err := adminClient.CreateRoleWithResponse(ctx, gen.CreateRoleRequest{
+		Label:       fmt.Sprintf("TestRole%s", random.String(16)),
+		Permissions: []string{permissions.AccessMediaPermission},
+	})
+	assert.Nil(t, err)
+	role := roleResp.JSON201
+
+	rolesResp, err := adminClient.UpdateUserRolesWithResponse(ctx, testUser.User.Id, gen.UpdateUserRolesRequest{RoleIds: []uuid.UUID{role.Id}})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rolesResp.StatusCode())
+
+	rolesResp, err = adminClient.UpdateUserRolesWithResponse(ctx, testUser.User.Id, gen.UpdateUserRolesRequest{RoleIds: []uuid.UUID{role.Id, uuid.New()}})
+	assert.Nil(t, err)
+	helpers.AssertErrorResponse(t, *rolesResp, http.StatusBadRequest, "one or more of the roles provided cannot be found", "user.role_missing")
+
+	u := getUser(t, adminClient, testUser.User.Id)
+	assert.ElementsMatch(t, []uuid.UUID{role.Id}, u.Roles)
+	assert.ElementsMatch(t, []string{permissions.AccessMediaPermission}, u.Permissions)
+	assert.Empty(t, u.DirectPermissions)
+}
+
+func getUser(t *testing.T, client *helpers.APIClient, userID uuid.UUID) gen.User {
+	resp, err := client.GetUserWithResponse(ctx, userID)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.NotNil(t, resp.JSON200)
+
+	return *resp.JSON200
+}
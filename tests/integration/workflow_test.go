@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hbomb79/Thea/internal/api"
 	"github.com/hbomb79/Thea/internal/http/websocket"
 	"github.com/hbomb79/Thea/tests/gen"
 	"github.com/hbomb79/Thea/tests/helpers"
@@ -25,7 +26,7 @@ func TestWorkflow_CRUD(t *testing.T) {
 	_, client := srv.NewClientWithRandomUser(t)
 	initialTargets := client.CreateRandomTargets(t, 3).IDs()
 	workflow := client.CreateWorkflow(t, &[]gen.WorkflowCriteria{
-		{CombineType: gen.OR, Key: gen.RESOLUTION, Type: gen.NOTEQUALS, Value: "10"},
+		{CombineType: gen.OR, Key: gen.WorkflowCriteriaKeyRESOLUTION, Type: gen.NOTEQUALS, Value: "10"},
 	}, true, random.String(64), &initialTargets)
 
 	// Check creation DTO is correct compared to a subsequent fetch
@@ -68,7 +69,7 @@ func TestWorkflow_CRUD(t *testing.T) {
 		newTargets := client.CreateRandomTargets(t, 3)
 		targetIDs := newTargets.IDs()
 		updatedWorkflow := client.UpdateWorkflow(t, workflow.Id, &[]gen.WorkflowCriteria{
-			{CombineType: gen.AND, Key: gen.MEDIATITLE, Type: gen.EQUALS, Value: "atitle"},
+			{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.EQUALS, Value: "atitle"},
 		}, &helpers.Boolean{}, &helpers.String{String: random.String(64)}, &targetIDs)
 
 		assert.Equal(t, workflow.Id, updatedWorkflow.Id, "ID of workflow changed after update")
@@ -125,7 +126,7 @@ func TestWorkflow_Creation(t *testing.T) {
 			Label:         "ValidComplete",
 			Enabled:       false,
 			Criteria: &[]gen.WorkflowCriteria{
-				{CombineType: gen.AND, Key: gen.MEDIATITLE, Type: gen.NOTEQUALS, Value: "FooBar"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.NOTEQUALS, Value: "FooBar"},
 			},
 			TargetIDs: &aIDs,
 		},
@@ -142,7 +143,7 @@ func TestWorkflow_Creation(t *testing.T) {
 			Label:         "ValidNoTargets",
 			Enabled:       false,
 			Criteria: &[]gen.WorkflowCriteria{
-				{CombineType: gen.AND, Key: gen.MEDIATITLE, Type: gen.EQUALS, Value: "FooBar"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.EQUALS, Value: "FooBar"},
 			},
 		},
 		{
@@ -229,9 +230,9 @@ func TestWorkflow_Update(t *testing.T) {
 			Label:   &helpers.String{String: "UpdatedME"},
 			Enabled: &helpers.Boolean{Bool: false},
 			Criteria: &[]gen.WorkflowCriteria{
-				{CombineType: gen.AND, Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "foobar"},
-				{CombineType: gen.AND, Key: gen.RESOLUTION, Type: gen.MATCHES, Value: "1920x1080"},
-				{CombineType: gen.AND, Key: gen.SOURCEEXTENSION, Type: gen.MATCHES, Value: ".mp4"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "foobar"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeyRESOLUTION, Type: gen.MATCHES, Value: "1920x1080"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeySOURCEEXTENSION, Type: gen.MATCHES, Value: ".mp4"},
 			},
 			TargetIDs:     &[]uuid.UUID{initialTargetIDs[0]},
 			ShouldSucceed: true,
@@ -249,9 +250,9 @@ func TestWorkflow_Update(t *testing.T) {
 		{
 			Summary: "Valid update criteria (order)",
 			Criteria: &[]gen.WorkflowCriteria{
-				{CombineType: gen.AND, Key: gen.SOURCEEXTENSION, Type: gen.MATCHES, Value: ".mp4"},
-				{CombineType: gen.AND, Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "foobar"},
-				{CombineType: gen.AND, Key: gen.RESOLUTION, Type: gen.MATCHES, Value: "1920x1080"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeySOURCEEXTENSION, Type: gen.MATCHES, Value: ".mp4"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "foobar"},
+				{CombineType: gen.AND, Key: gen.WorkflowCriteriaKeyRESOLUTION, Type: gen.MATCHES, Value: "1920x1080"},
 			},
 			ShouldSucceed: true,
 		},
@@ -272,7 +273,7 @@ func TestWorkflow_Update(t *testing.T) {
 		{
 			Summary: "Invalid update criteria (schema violation)",
 			Criteria: &[]gen.WorkflowCriteria{
-				{CombineType: "NOTACOMBINETYPE", Key: gen.MEDIATITLE, Type: gen.EQUALS, Value: "foo"},
+				{CombineType: "NOTACOMBINETYPE", Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.EQUALS, Value: "foo"},
 			},
 		},
 		{
@@ -338,7 +339,7 @@ func TestWorkflow_Ingestion(t *testing.T) {
 		{
 			summary: "Enabled with matching simple criteria",
 			criteria: &[]gen.WorkflowCriteria{
-				{Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND},
+				{Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND},
 			},
 			enabled:                 true,
 			shouldInitiateTranscode: true,
@@ -346,11 +347,11 @@ func TestWorkflow_Ingestion(t *testing.T) {
 		{
 			summary: "Enabled with matching complex criteria",
 			criteria: &[]gen.WorkflowCriteria{
-				{Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "SIMPLE", CombineType: gen.OR},             // false OR
-				{Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND}, // true AND
-				{Key: gen.RESOLUTION, Type: gen.MATCHES, Value: "1920x1080", CombineType: gen.OR},          // false OR
-				{Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND}, // true AND
-				{Key: gen.RESOLUTION, Type: gen.MATCHES, Value: "1280x720", CombineType: gen.AND},          // true
+				{Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "SIMPLE", CombineType: gen.OR},             // false OR
+				{Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND}, // true AND
+				{Key: gen.WorkflowCriteriaKeyRESOLUTION, Type: gen.MATCHES, Value: "1920x1080", CombineType: gen.OR},          // false OR
+				{Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND}, // true AND
+				{Key: gen.WorkflowCriteriaKeyRESOLUTION, Type: gen.MATCHES, Value: "1280x720", CombineType: gen.AND},          // true
 			},
 			enabled:                 true,
 			shouldInitiateTranscode: true,
@@ -358,10 +359,10 @@ func TestWorkflow_Ingestion(t *testing.T) {
 		{
 			summary: "Enabled with non-matching criteria",
 			criteria: &[]gen.WorkflowCriteria{
-				{Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "SIMPLE", CombineType: gen.OR},             // false OR
-				{Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND}, // true AND
-				{Key: gen.RESOLUTION, Type: gen.MATCHES, Value: "1920x1080", CombineType: gen.OR},          // false OR
-				{Key: gen.MEDIATITLE, Type: gen.MATCHES, Value: "notthetitle", CombineType: gen.AND},       // false
+				{Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "SIMPLE", CombineType: gen.OR},             // false OR
+				{Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "Shaun of the Dead", CombineType: gen.AND}, // true AND
+				{Key: gen.WorkflowCriteriaKeyRESOLUTION, Type: gen.MATCHES, Value: "1920x1080", CombineType: gen.OR},          // false OR
+				{Key: gen.WorkflowCriteriaKeyMEDIATITLE, Type: gen.MATCHES, Value: "notthetitle", CombineType: gen.AND},       // false
 			},
 			enabled:                 true,
 			shouldInitiateTranscode: false,
@@ -403,7 +404,7 @@ func TestWorkflow_Ingestion(t *testing.T) {
 				// We expect duplicates here; one for the ingestion creation, and another for it's completion.
 				// However, due to debouncing of the events, by the time the first message is released, the ingestion
 				// is likely to have completed already
-				chanassert.AtLeastNOf(2, helpers.MatchMessageTitle("INGEST_UPDATE")),
+				chanassert.AtLeastNOf(2, helpers.MatchValidActivity(api.TitleIngestUpdate)),
 				// We only expect a single event for the media creation
 				chanassert.OneOf(helpers.MatchMovieEvent(paths[0])),
 			}
@@ -478,11 +479,12 @@ func TestWorkflow_Ingestion(t *testing.T) {
 			assert.NotNil(t, resp)
 			assert.NotNil(t, resp.JSON200)
 
-			episode := resp.JSON200
-			assert.Len(t, episode.WatchTargets, numTargetsToCreate+1) // +1 as we create a 'fake' watch target for 'direct streaming' of the content
+			movie := resp.JSON200
+			assert.Len(t, movie.WatchTargets, numTargetsToCreate+1) // +1 as we create a 'fake' watch target for 'direct streaming' of the content
+			assert.NotNil(t, movie.Related, "expected related movies to be present on the movie fetched by ID")
 
 			seenDirect := false
-			for _, wt := range episode.WatchTargets {
+			for _, wt := range movie.WatchTargets {
 				assert.True(t, wt.Enabled)
 
 				//nolint:gocritic